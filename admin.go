@@ -1,12 +1,15 @@
 package main
 
 import (
-	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"runtime"
 	"sync"
@@ -17,9 +20,15 @@ import (
 
 // AdminConfig holds admin panel configuration
 type AdminConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	Port     int    `yaml:"port"`
-	AuthFile string `yaml:"-"` // Set internally
+	Enabled             bool     `yaml:"enabled"`
+	Port                int      `yaml:"port"`
+	PublicURL           string   `yaml:"public_url"`            // Base URL used for deep links in notifications
+	Pprof               bool     `yaml:"pprof"`                 // Expose /debug/pprof/ for profiling (requires auth)
+	SessionHours        int      `yaml:"session_hours"`         // Lifetime of a login token, refreshed by the dashboard
+	LoginMaxFailures    int      `yaml:"login_max_failures"`    // Failed logins from one IP before it is locked out
+	LoginLockoutMinutes int      `yaml:"login_lockout_minutes"` // Lockout length, also the window failures are counted in
+	AllowedCIDRs        []string `yaml:"allowed_cidrs"`         // Networks allowed to reach the panel; empty allows all
+	AuthFile            string   `yaml:"-"`                     // Set internally
 }
 
 var adminConfig *AdminConfig
@@ -40,6 +49,15 @@ func SetAdminConfig(cfg *AdminConfig) {
 	if cfg != nil && cfg.Port == 0 {
 		cfg.Port = 8080
 	}
+	if cfg != nil && cfg.SessionHours <= 0 {
+		cfg.SessionHours = 12
+	}
+	if cfg != nil && cfg.LoginMaxFailures <= 0 {
+		cfg.LoginMaxFailures = 5
+	}
+	if cfg != nil && cfg.LoginLockoutMinutes <= 0 {
+		cfg.LoginLockoutMinutes = 15
+	}
 }
 
 // GetAdminConfig returns admin configuration
@@ -49,6 +67,15 @@ func GetAdminConfig() *AdminConfig {
 	return adminConfig
 }
 
+// dashboardDomainLink returns a deep link to a domain in the admin panel, or "" when no public URL is set
+func dashboardDomainLink(domain string) string {
+	cfg := GetAdminConfig()
+	if cfg == nil || !cfg.Enabled || strings.TrimSpace(cfg.PublicURL) == "" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSpace(cfg.PublicURL), "/") + "/#domain=" + url.QueryEscape(domain)
+}
+
 // StartAdminServer starts the admin panel HTTP server
 func StartAdminServer(configDir string) error {
 	cfg := GetAdminConfig()
@@ -63,6 +90,17 @@ func StartAdminServer(configDir string) error {
 	if err := loadOrCreateAuth(authFile); err != nil {
 		return fmt.Errorf("failed to setup admin auth: %w", err)
 	}
+	if err := loadOrCreateSessions(filepath.Join(configDir, ".admin_sessions.json")); err != nil {
+		return fmt.Errorf("failed to setup admin sessions: %w", err)
+	}
+	if err := loadAPIKeys(filepath.Join(configDir, ".admin_api_keys.json")); err != nil {
+		return fmt.Errorf("failed to load api keys: %w", err)
+	}
+
+	allowed, err := parseAllowlist(cfg.AllowedCIDRs)
+	if err != nil {
+		return err
+	}
 
 	server := &AdminServer{
 		config: cfg,
@@ -75,8 +113,8 @@ func StartAdminServer(configDir string) error {
 	// Start server
 	go func() {
 		addr := fmt.Sprintf(":%d", cfg.Port)
-		logger.Info("admin panel starting", "port", cfg.Port, "url", fmt.Sprintf("http://localhost:%d", cfg.Port))
-		if err := http.ListenAndServe(addr, server.router); err != nil && err != http.ErrServerClosed {
+		logger.Info("admin panel starting", "port", cfg.Port, "url", fmt.Sprintf("http://localhost:%d", cfg.Port), "allowed_networks", len(allowed))
+		if err := http.ListenAndServe(addr, withAllowlist(allowed, server.router)); err != nil && err != http.ErrServerClosed {
 			logger.Error("admin server error", "error", err)
 		}
 	}()
@@ -88,18 +126,59 @@ func StartAdminServer(configDir string) error {
 func (as *AdminServer) registerRoutes() {
 	// Public routes (no auth required)
 	as.router.HandleFunc("/health", as.handleHealth)
+	as.router.HandleFunc("/api/openapi.json", as.handleOpenAPI)
+	as.router.HandleFunc("/api/discord/interactions", as.handleDiscordInteraction)
 
 	// Auth routes
 	as.router.HandleFunc("/api/auth/login", as.handleLogin)
+	as.router.HandleFunc("/api/auth/refresh", as.withSession(as.handleRefresh))
+	as.router.HandleFunc("/api/auth/logout", as.withSession(as.handleLogout))
+	as.router.HandleFunc("/api/keys", as.withSession(as.handleAPIKeys))
 
 	// Protected routes (require auth)
 	as.router.HandleFunc("/api/stats", as.withAuth(as.handleStats))
 	as.router.HandleFunc("/api/domains", as.withAuth(as.handleDomains))
+	as.router.HandleFunc("/api/domains/export", as.withAuth(as.handleDomainsExport))
+	as.router.HandleFunc("/api/domains/", as.withAuth(as.handleDomain))
 	as.router.HandleFunc("/api/targets", as.withAuth(as.handleTargets))
+	as.router.HandleFunc("/api/targets/bulk", as.withAuth(as.handleTargetsBulk))
+	as.router.HandleFunc("/api/targets/purge", as.withAuth(as.handleTargetPurge))
+	as.router.HandleFunc("/api/programs", as.withAuth(as.handlePrograms))
 	as.router.HandleFunc("/api/blacklist", as.withAuth(as.handleBlacklist))
+	as.router.HandleFunc("/api/exclusions", as.withAuth(as.handleExclusions))
+	as.router.HandleFunc("/api/dedup", as.withAuth(as.handleDedup))
+	as.router.HandleFunc("/api/noise", as.withAuth(as.handleNoise))
+	as.router.HandleFunc("/api/noise/proposals", as.withAuth(as.handleNoiseProposals))
+	as.router.HandleFunc("/api/expiring", as.withAuth(as.handleExpiring))
+	as.router.HandleFunc("/api/issuance", as.withAuth(as.handleIssuance))
+	as.router.HandleFunc("/api/graph", as.withAuth(as.handleGraph))
 	as.router.HandleFunc("/api/config", as.withAuth(as.handleConfig))
 	as.router.HandleFunc("/api/webhooks", as.withAuth(as.handleWebhooks))
 	as.router.HandleFunc("/api/webhooks/test", as.withAuth(as.handleWebhookTest))
+	as.router.HandleFunc("/api/test/inject", as.withAuth(as.handleTestInject))
+	as.router.HandleFunc("/api/screenshot", as.withAuth(as.handleScreenshot))
+	as.router.HandleFunc("/api/reports", as.withAuth(as.handleReports))
+	as.router.HandleFunc("/api/evidence", as.withAuth(as.handleEvidence))
+	as.router.HandleFunc("/api/errors", as.withAuth(as.handleErrors))
+	as.router.HandleFunc("/api/notifications/failed", as.withAuth(as.handleFailedNotifications))
+	as.router.HandleFunc("/api/sni/refresh", as.withAuth(as.handleSNIRefresh))
+	as.router.HandleFunc("/api/sni/search", as.withAuth(as.handleSNISearch))
+	as.router.HandleFunc("/api/candidates", as.withAuth(as.handleCandidates))
+	as.router.HandleFunc("/api/jobs", as.withAuth(as.handleJobs))
+	as.router.HandleFunc("/api/findings", as.withAuth(as.handleFindings))
+	as.router.HandleFunc("/api/assets", as.withAuth(as.handleAssets))
+	as.router.HandleFunc("/api/scan", as.withAuth(as.handleScan))
+	as.router.HandleFunc("/api/events", as.withAuth(as.handleEvents))
+
+	// Profiling endpoints, opt-in since profiles expose internals
+	if as.config.Pprof {
+		as.router.HandleFunc("/debug/pprof/", as.withAuth(pprof.Index))
+		as.router.HandleFunc("/debug/pprof/cmdline", as.withAuth(pprof.Cmdline))
+		as.router.HandleFunc("/debug/pprof/profile", as.withAuth(pprof.Profile))
+		as.router.HandleFunc("/debug/pprof/symbol", as.withAuth(pprof.Symbol))
+		as.router.HandleFunc("/debug/pprof/trace", as.withAuth(pprof.Trace))
+		logger.Info("pprof endpoints enabled", "path", "/debug/pprof/")
+	}
 
 	// Serve static assets
 	as.router.HandleFunc("/", as.serveUI)
@@ -111,12 +190,24 @@ func (as *AdminServer) registerRoutes() {
 // withAuth middleware checks authentication
 func (as *AdminServer) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		if token == "" {
-			token = r.URL.Query().Get("token")
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			if !apiKeys.Verify(key) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if _, err := verifySession(requestToken(r)); err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
 		}
 
-		if token == "" || !verifyToken(token) {
+		next(w, r)
+	}
+}
+
+// withSession requires an interactive login, so an API key can't be used to mint or revoke keys
+func (as *AdminServer) withSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifySession(requestToken(r)); err != nil {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
@@ -138,6 +229,11 @@ func (as *AdminServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+	if handleLockout(w, ip) {
+		return
+	}
+
 	var req struct {
 		Password string `json:"password"`
 	}
@@ -149,23 +245,146 @@ func (as *AdminServer) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if err := bcrypt.CompareHashAndPassword([]byte(adminAuthHash), []byte(req.Password)); err != nil {
 		// Log failed attempt
 		logger.Warn("failed admin login attempt", "ip", r.RemoteAddr)
+		lockout := time.Duration(as.config.LoginLockoutMinutes) * time.Minute
+		if logins.Fail(ip, as.config.LoginMaxFailures, lockout) {
+			logger.Warn("admin login locked out", "ip", ip, "minutes", as.config.LoginLockoutMinutes)
+			auditSecurityEvent("admin_login_lockout", ip, map[string]int{
+				"failures": as.config.LoginMaxFailures,
+				"minutes":  as.config.LoginLockoutMinutes,
+			})
+		}
 		time.Sleep(1 * time.Second) // Rate limit
 		http.Error(w, "invalid password", http.StatusUnauthorized)
 		return
 	}
+	logins.Reset(ip)
 
-	// Generate token (simple SHA256 hash of password + timestamp)
-	token := generateToken(req.Password)
+	token, expires, err := issueSession()
+	if err != nil {
+		logger.Error("failed to issue admin session", "error", err)
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-		"url":   fmt.Sprintf("http://localhost:%d/dashboard.html", as.config.Port),
+		"token":      token,
+		"expires_at": expires.Format(time.RFC3339),
+		"url":        fmt.Sprintf("http://localhost:%d/dashboard.html", as.config.Port),
 	})
 	logger.Info("admin login successful", "ip", r.RemoteAddr)
+	auditSecurityEvent("admin_login", ip, nil)
+}
+
+// handleRefresh exchanges a valid session token for a new one with a fresh expiry and
+// revokes the old one
+func (as *AdminServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims, err := verifySession(requestToken(r))
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token, expires, err := issueSession()
+	if err != nil {
+		logger.Error("failed to issue admin session", "error", err)
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	// The old token is replaced, so it can't be refreshed again or kept alive if stolen
+	if err := revokeSession(claims); err != nil {
+		logger.Error("failed to save revoked session", "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"token":      token,
+		"expires_at": expires.Format(time.RFC3339),
+	})
+}
+
+// handleAPIKeys lists (GET), creates (POST {"name": ...}) and revokes (DELETE ?id=) API keys.
+// A created key is returned once and only its hash is kept.
+func (as *AdminServer) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(apiKeys.List())
+
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		key, plain, err := apiKeys.Create(strings.TrimSpace(req.Name))
+		if err != nil {
+			logger.Error("failed to create api key", "error", err)
+			http.Error(w, "failed to create api key", http.StatusInternalServerError)
+			return
+		}
+		logger.Info("api key created via admin panel", "id", key.ID, "name", key.Name)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      key.ID,
+			"name":    key.Name,
+			"key":     plain,
+		})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+		if err := apiKeys.Revoke(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logger.Info("api key revoked via admin panel", "id", id)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLogout revokes the session the request was made with
+func (as *AdminServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, err := verifySession(requestToken(r))
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := revokeSession(claims); err != nil {
+		logger.Error("failed to save revoked session", "error", err)
+	}
+	logger.Info("admin logout", "ip", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+	})
 }
 
 // handleStats returns system and app statistics
 func (as *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
@@ -175,6 +394,11 @@ func (as *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	// Get domain stats
 	dt := GetDomainTracker()
 	allDomains := dt.GetAllDomains()
+	for domain, entry := range allDomains {
+		if !inProgram(entry, members) {
+			delete(allDomains, domain)
+		}
+	}
 	blacklistedCount := 0
 	totalHits := 0
 	highRiskCount := 0
@@ -228,7 +452,20 @@ func (as *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
 	activeFerox, activePuredns := st.GetScanQueue()
 	completedScans, failedScans, enumSuccessRate := st.GetEnumSuccessRate()
 	discoveryRate := st.GetDiscoveryRate()
+	latencyP50, latencyP95, latencySamples := st.GetNotifyLatency()
 	topTargets := st.GetTopTargets()
+	targetCount := len(targets)
+	if members != nil {
+		for t := range topTargets {
+			if !members[t] {
+				delete(topTargets, t)
+			}
+		}
+		targetCount = len(members)
+	}
+	pendingNotifications, pendingTargets, overflowedNotifications, requeuedNotifications := notifier.Depth()
+	runningJobs, queuedJobs := GetJobManager().Counts()
+	_, _, maxQueuedJobs := jobLimits()
 
 	stats := map[string]interface{}{
 		"timestamp":       time.Now().Unix(),
@@ -260,68 +497,356 @@ func (as *AdminServer) handleStats(w http.ResponseWriter, r *http.Request) {
 			"disconnected": disconnectedCTLogs,
 		},
 		"scan_queue": map[string]interface{}{
-			"feroxbuster": activeFerox,
-			"puredns":     activePuredns,
-			"total":       activeFerox + activePuredns,
+			"feroxbuster":    activeFerox,
+			"puredns":        activePuredns,
+			"total":          activeFerox + activePuredns,
+			"running":        runningJobs,
+			"queued":         queuedJobs,
+			"max_queued":     maxQueuedJobs,
+			"queued_by_type": GetJobManager().QueueDepthByType(),
 		},
 		"enumeration": map[string]interface{}{
 			"completed":    completedScans,
 			"failed":       failedScans,
 			"success_rate": enumSuccessRate,
 		},
+		"notification_buffer": map[string]interface{}{
+			"pending":         pendingNotifications,
+			"pending_targets": pendingTargets,
+			"max_pending":     pendingDomainLimit(),
+			"overflowed":      overflowedNotifications,
+			"requeued":        requeuedNotifications,
+			"retrying":        len(GetRetryQueue().Pending()),
+		},
+		"notify_latency": map[string]interface{}{
+			"p50_seconds": latencyP50.Seconds(),
+			"p95_seconds": latencyP95.Seconds(),
+			"samples":     latencySamples,
+		},
 		"discovery_rate": discoveryRate,
 		"top_targets":    topTargets,
-		"targets":        len(targets),
+		"sources":        BuildSourceStats(allDomains),
+		"targets":        targetCount,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
-// handleDomains returns domain tracking information
+// handleDomains returns domain tracking information, optionally filtered by hosting
+// ?provider= (aws, gcp, azure, digitalocean or other), ?asn=, ?source= and ?program=
 func (as *AdminServer) handleDomains(w http.ResponseWriter, r *http.Request) {
-	dt := GetDomainTracker()
-	allDomains := dt.GetAllDomains()
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
 
-	// Sort by hit count descending
-	type domainStats struct {
-		Domain         string                 `json:"domain"`
-		HitCount       int                    `json:"hit_count"`
-		FirstSeen      time.Time              `json:"first_seen"`
-		LastSeen       time.Time              `json:"last_seen"`
-		Blacklisted    bool                   `json:"blacklisted"`
-		DailyHits      map[string]int         `json:"daily_hits"`
-		RiskScore      int                    `json:"risk_score"`
-		RiskLabels     []string               `json:"risk_labels"`
-		IsDuplicate    bool                   `json:"is_duplicate"`
-		CertIssuer     string                 `json:"cert_issuer"`
-		StatusCode     int                    `json:"status_code"`
-	}
-
-	var domains []domainStats
-	for _, entry := range allDomains {
-		domains = append(domains, domainStats{
-			Domain:      entry.Domain,
-			HitCount:    entry.HitCount,
-			FirstSeen:   entry.FirstSeen,
-			LastSeen:    entry.LastSeen,
-			Blacklisted: entry.Blacklisted,
-			DailyHits:   entry.DailyHits,
-			RiskScore:   entry.RiskScore,
-			RiskLabels:  entry.RiskLabels,
-			IsDuplicate: entry.IsDuplicate,
-			CertIssuer:  entry.CertIssuer,
-			StatusCode:  entry.HttpStatusCode,
+	q := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listDomains(q.Get("provider"), q.Get("asn"), q.Get("source"), members))
+}
+
+// listDomains summarizes the tracked domains matching a hosting provider, ASN, source
+// and program; empty filters match everything
+func listDomains(provider, asn, source string, members map[string]bool) DomainList {
+	domains := []DomainSummary{}
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		if !matchesNetwork(entry, provider, asn) || !inProgram(entry, members) {
+			continue
+		}
+		if source != "" && domainSource(entry) != source {
+			continue
+		}
+		domains = append(domains, DomainSummary{
+			Domain:        entry.Domain,
+			HitCount:      entry.HitCount,
+			FirstSeen:     entry.FirstSeen,
+			LastSeen:      entry.LastSeen,
+			Blacklisted:   entry.Blacklisted,
+			DailyHits:     entry.DailyHits,
+			RiskScore:     entry.RiskScore,
+			RiskLabels:    entry.RiskLabels,
+			IsDuplicate:   entry.IsDuplicate,
+			CertIssuer:    entry.CertIssuer,
+			StatusCode:    entry.HttpStatusCode,
+			Certificate:   entry.Certificate,
+			Probe:         entry.Probe,
+			HasScreenshot: entry.Screenshot != "",
+			Snoozed:       isSnoozed(entry),
+			Providers:     entry.Providers,
+			ASNs:          asnLabels(entry.ASNs),
+			Countries:     entry.Countries,
+			Source:        domainSource(entry),
+		})
+	}
+	return DomainList{Total: len(domains), Domains: domains}
+}
+
+// handleDomainsExport downloads tracked domains as ?format=csv|json, filtered by
+// ?target=, ?program=, ?since=, ?until= and ?min_risk=
+func (as *AdminServer) handleDomainsExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseExportFilter(q.Get("target"), q.Get("since"), q.Get("until"), q.Get("min_risk"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+	filter.Program = members
+
+	contentType := "text/csv"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"crtmon-domains-%s.%s\"", time.Now().Format("20060102"), format))
+	if err := writeExport(w, format, ExportDomains(filter)); err != nil {
+		logger.Error("failed to write domain export", "error", err)
+	}
+}
+
+// handleDomain serves /api/domains/{domain} and its actions: POST .../notify,
+// .../rescan, .../blacklist and .../snooze (DELETE to undo either), and GET .../output?file=
+func (as *AdminServer) handleDomain(w http.ResponseWriter, r *http.Request) {
+	domain, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/domains/"), "/")
+	if domain == "" {
+		http.Error(w, "domain required", http.StatusBadRequest)
+		return
+	}
+
+	method := http.MethodPost
+	switch action {
+	case "", "output":
+		method = http.MethodGet
+	case "blacklist", "snooze":
+		if r.Method == http.MethodDelete {
+			method = http.MethodDelete
+		}
+	case "notify", "rescan":
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != method {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry := GetDomainTracker().GetDomainInfo(domain)
+	if entry == nil {
+		http.Error(w, "domain not found", http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "":
+		as.writeDomainDetail(w, domain)
+	case "output":
+		as.serveScanOutput(w, entry.Domain, r.URL.Query().Get("file"))
+	case "notify":
+		as.forceNotify(w, entry)
+	case "rescan":
+		as.rescan(w, entry)
+	case "blacklist":
+		blacklisted := r.Method == http.MethodPost
+		GetDomainTracker().SetBlacklisted(entry.Domain, blacklisted)
+		logger.Info("domain blacklist changed via admin panel", "domain", entry.Domain, "blacklisted", blacklisted)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":     true,
+			"domain":      entry.Domain,
+			"blacklisted": blacklisted,
 		})
+	case "snooze":
+		as.snooze(w, r, entry)
+	}
+}
+
+// snooze suppresses notifications for a domain for {"days": N}, or lifts it on DELETE
+func (as *AdminServer) snooze(w http.ResponseWriter, r *http.Request, entry *DomainEntry) {
+	var until time.Time
+	if r.Method == http.MethodPost {
+		var req struct {
+			Days int `json:"days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if req.Days < 1 || req.Days > 365 {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		until = time.Now().AddDate(0, 0, req.Days)
+	}
+
+	GetDomainTracker().SnoozeDomain(entry.Domain, until)
+	logger.Info("domain snooze changed via admin panel", "domain", entry.Domain, "until", until)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"domain":        entry.Domain,
+		"snoozed_until": until,
+	})
+}
+
+// writeDomainDetail writes the full tracking entry for a domain along with its scans
+// and their output files
+func (as *AdminServer) writeDomainDetail(w http.ResponseWriter, domain string) {
+	detail := domainDetail(domain)
+	if detail == nil {
+		http.Error(w, "domain not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// domainDetail returns a tracked domain with its scans, or nil if it isn't tracked
+func domainDetail(domain string) *DomainDetail {
+	entry := GetDomainTracker().GetDomainInfo(domain)
+	if entry == nil {
+		return nil
+	}
+
+	scanFiles := domainScanFiles(entry.Domain)
+	if scanFiles == nil {
+		scanFiles = []string{}
+	}
+	return &DomainDetail{entry, domainJobs(entry.Domain), scanFiles}
+}
+
+// serveScanOutput sends one of a domain's scan output files as text
+func (as *AdminServer) serveScanOutput(w http.ResponseWriter, domain, file string) {
+	// Only files belonging to the domain are served, never arbitrary paths
+	for _, f := range domainScanFiles(domain) {
+		if f != file {
+			continue
+		}
+		data, err := readArtifact(f)
+		if err != nil {
+			http.Error(w, "scan output not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(data)
+		return
+	}
+	http.Error(w, "scan output not found", http.StatusNotFound)
+}
+
+// forceNotify sends a domain to every target it matches, bypassing the notification cooldown
+func (as *AdminServer) forceNotify(w http.ResponseWriter, entry *DomainEntry) {
+	if !notifyDiscord && !notifyTelegram && !notifyNtfy {
+		http.Error(w, "no notification provider configured", http.StatusConflict)
+		return
+	}
+	domainTargets := matchingTargets(entry)
+	if len(domainTargets) == 0 {
+		http.Error(w, "domain matches no current target", http.StatusConflict)
+		return
+	}
+
+	GetDomainTracker().ForceNotifyDomain(entry.Domain)
+	for _, target := range domainTargets {
+		sendToDiscord(entry.Domain, target)
+	}
+	logger.Info("notification forced via admin panel", "domain", entry.Domain, "targets", domainTargets)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"domain":  entry.Domain,
+		"targets": domainTargets,
+	})
+}
+
+// rescan queues enumeration for a domain again
+func (as *AdminServer) rescan(w http.ResponseWriter, entry *DomainEntry) {
+	if !isEnumEnabled() {
+		http.Error(w, "enumeration is not enabled", http.StatusConflict)
+		return
+	}
+	domainTargets := matchingTargets(entry)
+	if len(domainTargets) == 0 {
+		http.Error(w, "domain matches no current target", http.StatusConflict)
+		return
+	}
+
+	// Scans are named by domain, so one run covers every target
+	triggerEnumeration(entry.Domain, domainTargets[0])
+	logger.Info("rescan requested via admin panel", "domain", entry.Domain)
+
+	pending := pendingJobs(entry.Domain)
+	if len(pending) == 0 {
+		http.Error(w, "no scan could be queued, see the Issues tab", http.StatusServiceUnavailable)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total":   len(domains),
-		"domains": domains,
+		"success": true,
+		"domain":  entry.Domain,
+		"jobs":    pending,
 	})
 }
 
+// pendingJobs returns queued and running scans of a domain
+func pendingJobs(domain string) []Job {
+	var pending []Job
+	for _, job := range domainJobs(domain) {
+		if job.Status == jobQueued || job.Status == jobRunning {
+			pending = append(pending, job)
+		}
+	}
+	return pending
+}
+
+// matchingTargets returns the targets a domain was credited to, or failing that the
+// current targets it matches
+func matchingTargets(entry *DomainEntry) []string {
+	if len(entry.Targets) > 0 {
+		return entry.Targets
+	}
+	var matched []string
+	for _, target := range targets {
+		if matchesTarget(entry.Domain, target) {
+			matched = append(matched, target)
+		}
+	}
+	return matched
+}
+
+// domainJobs returns scans of a domain, including puredns runs on its wildcard base
+func domainJobs(domain string) []Job {
+	jobs := []Job{}
+	for _, job := range GetJobManager().List() {
+		if strings.EqualFold(job.Domain, domain) || strings.EqualFold(job.Domain, ExtractBaseDomain(domain)) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
 // handleTargets manages targets
 func (as *AdminServer) handleTargets(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -336,20 +861,48 @@ func (as *AdminServer) handleTargets(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getTargets returns list of targets
+// getTargets returns list of targets, optionally those of one ?program=
 func (as *AdminServer) getTargets(w http.ResponseWriter, r *http.Request) {
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"targets": targets,
-		"count":   len(targets),
-	})
+	json.NewEncoder(w).Encode(listTargets(members))
+}
+
+// listTargets returns the targets, only those of a program when members isn't nil
+func listTargets(members map[string]bool) TargetList {
+	// Unicode forms of internationalized targets
+	display := make(map[string]string)
+	programs := make(map[string]string)
+	listed := []string{}
+	for _, t := range targets {
+		if members != nil && !members[t] {
+			continue
+		}
+		listed = append(listed, t)
+		if unicode := displayTarget(t); unicode != t {
+			display[t] = unicode
+		}
+		if program := programOf(t); program != "" {
+			programs[t] = program
+		}
+	}
+
+	return TargetList{
+		Targets:   listed,
+		Count:     len(listed),
+		Freshness: GetTargetFreshness(listed),
+		Display:   display,
+		Programs:  programs,
+	}
 }
 
 // addTarget adds a new target
 func (as *AdminServer) addTarget(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Target string `json:"target"`
-	}
+	var req TargetRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
@@ -360,6 +913,13 @@ func (as *AdminServer) addTarget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	normalized, err := normalizeTarget(req.Target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Target = normalized
+
 	// Check if already exists
 	for _, t := range targets {
 		if t == req.Target {
@@ -388,10 +948,10 @@ func (as *AdminServer) addTarget(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "target added",
-		"target":  req.Target,
-		"targets": targets,
+	json.NewEncoder(w).Encode(TargetChange{
+		Message: "target added",
+		Target:  req.Target,
+		Targets: targets,
 	})
 }
 
@@ -419,10 +979,10 @@ func (as *AdminServer) deleteTarget(w http.ResponseWriter, r *http.Request) {
 			}
 
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"message": "target removed",
-				"target":  target,
-				"targets": targets,
+			json.NewEncoder(w).Encode(TargetChange{
+				Message: "target removed",
+				Target:  target,
+				Targets: targets,
 			})
 			return
 		}
@@ -431,21 +991,173 @@ func (as *AdminServer) deleteTarget(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "target not found", http.StatusNotFound)
 }
 
-// handleBlacklist manages blacklist
-func (as *AdminServer) handleBlacklist(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		as.getBlacklist(w, r)
-	case http.MethodPost:
-		as.addBlacklist(w, r)
-	case http.MethodDelete:
-		as.removeBlacklist(w, r)
-	default:
+// handleTargetsBulk adds (POST) or removes (DELETE) a list of targets in one request
+func (as *AdminServer) handleTargetsBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-}
 
-// getBlacklist returns blacklisted domains
+	var req BulkTargetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Targets) == 0 {
+		http.Error(w, "targets cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	var result BulkTargetResult
+	if r.Method == http.MethodPost {
+		result = addTargets(req.Targets)
+		logger.Info("targets added via admin panel", "added", len(result.Changed), "existing", len(result.Unchanged), "invalid", len(result.Invalid))
+
+		if sm := GetSNIManager(); sm != nil {
+			for _, t := range result.Changed {
+				sm.SearchSNIOnDemand(t)
+			}
+		}
+	} else {
+		result = removeTargets(req.Targets)
+		logger.Info("targets removed via admin panel", "removed", len(result.Changed), "not_found", len(result.Unchanged), "invalid", len(result.Invalid))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BulkTargetsResponse{result, targets})
+}
+
+// handleTargetPurge previews (GET) or performs (POST with the preview token) deletion
+// of all data for a target
+func (as *AdminServer) handleTargetPurge(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		target, err := normalizeTarget(r.URL.Query().Get("target"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PlanPurge(target))
+
+	case http.MethodPost:
+		var req struct {
+			Target string `json:"target"`
+			Token  string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		target, err := normalizeTarget(req.Target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := PurgeTarget(target, req.Token, "admin:"+r.RemoteAddr)
+		if errors.Is(err, errPurgeToken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logger.Error("target purge incomplete", "target", target, "error", err)
+			http.Error(w, "purge incomplete: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "target purged",
+			"purged":  plan,
+			"targets": targets,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleIssuance returns certificates per day by CA for each target, flagging unusual CAs.
+// The optional day (YYYY-MM-DD) is compared against the days before it, default today.
+func (as *AdminServer) handleIssuance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cfg := GetIssuanceConfig(); cfg == nil || !cfg.Enabled {
+		http.Error(w, "issuance report not enabled", http.StatusNotFound)
+		return
+	}
+
+	day := time.Now()
+	if value := r.URL.Query().Get("day"); value != "" {
+		parsed, err := time.ParseInLocation(issuanceDayFormat, value, time.Local)
+		if err != nil {
+			http.Error(w, "invalid day, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+
+	rows := BuildIssuanceReport(day)
+	target := r.URL.Query().Get("target")
+	if target != "" || members != nil {
+		var filtered []IssuanceRow
+		for _, row := range rows {
+			if (target == "" || row.Target == target) && (members == nil || members[row.Target]) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+	if rows == nil {
+		rows = []IssuanceRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"day":  day.Format(issuanceDayFormat),
+		"rows": rows,
+	})
+}
+
+// handleGraph returns nodes and edges linking targets, subdomains, addresses, ASNs and
+// certificates, optionally for one ?target= or ?program=
+func (as *AdminServer) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BuildAssetGraph(r.URL.Query().Get("target"), members))
+}
+
+// handleBlacklist manages blacklist
+func (as *AdminServer) handleBlacklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		as.getBlacklist(w, r)
+	case http.MethodPost:
+		as.addBlacklist(w, r)
+	case http.MethodDelete:
+		as.removeBlacklist(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getBlacklist returns blacklisted domains
 func (as *AdminServer) getBlacklist(w http.ResponseWriter, r *http.Request) {
 	dt := GetDomainTracker()
 	blacklisted := dt.GetBlacklistedDomains()
@@ -489,20 +1201,11 @@ func (as *AdminServer) addBlacklist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dt := GetDomainTracker()
-	allDomains := dt.GetAllDomains()
-	entry, exists := allDomains[req.Domain]
-	if !exists {
+	if !GetDomainTracker().SetBlacklisted(req.Domain, true) {
 		http.Error(w, "domain not found", http.StatusNotFound)
 		return
 	}
 
-	// Manually blacklist
-	entry.Blacklisted = true
-	entry.BlacklistedDate = time.Now()
-	dt.domains[req.Domain] = entry
-	dt.save()
-
 	logger.Info("domain manually blacklisted via admin panel", "domain", req.Domain)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -520,19 +1223,11 @@ func (as *AdminServer) removeBlacklist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dt := GetDomainTracker()
-	allDomains := dt.GetAllDomains()
-	entry, exists := allDomains[domain]
-	if !exists {
+	if !GetDomainTracker().SetBlacklisted(domain, false) {
 		http.Error(w, "domain not found", http.StatusNotFound)
 		return
 	}
 
-	entry.Blacklisted = false
-	entry.BlacklistedDate = time.Time{}
-	dt.domains[domain] = entry
-	dt.save()
-
 	logger.Info("domain removed from blacklist via admin panel", "domain", domain)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -542,6 +1237,231 @@ func (as *AdminServer) removeBlacklist(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleExclusions manages exclusion patterns
+func (as *AdminServer) handleExclusions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		as.getExclusions(w, r)
+	case http.MethodPost:
+		as.addExclusion(w, r)
+	case http.MethodDelete:
+		as.removeExclusion(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getExclusions returns global and per-target exclusion patterns
+func (as *AdminServer) getExclusions(w http.ResponseWriter, r *http.Request) {
+	exclusions := GetExclusionConfig()
+
+	count := len(exclusions.Global)
+	for _, patterns := range exclusions.Targets {
+		count += len(patterns)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   count,
+		"global":  exclusions.Global,
+		"targets": exclusions.Targets,
+	})
+}
+
+// addExclusion adds an exclusion pattern, optionally scoped to a target
+func (as *AdminServer) addExclusion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Pattern string `json:"pattern"`
+		Target  string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := AddExclusion(req.Pattern, req.Target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("exclusion added via admin panel", "pattern", req.Pattern, "target", req.Target)
+
+	if getConfig() != nil {
+		if err := SaveConfig(); err != nil {
+			logger.Error("failed to save config after adding exclusion", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "exclusion added",
+		"pattern": req.Pattern,
+		"target":  req.Target,
+	})
+}
+
+// removeExclusion removes an exclusion pattern
+func (as *AdminServer) removeExclusion(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		http.Error(w, "pattern parameter required", http.StatusBadRequest)
+		return
+	}
+	target := r.URL.Query().Get("target")
+
+	if !RemoveExclusion(pattern, target) {
+		http.Error(w, "exclusion not found", http.StatusNotFound)
+		return
+	}
+
+	logger.Info("exclusion removed via admin panel", "pattern", pattern, "target", target)
+
+	if getConfig() != nil {
+		if err := SaveConfig(); err != nil {
+			logger.Error("failed to save config after removing exclusion", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "exclusion removed",
+		"pattern": pattern,
+		"target":  target,
+	})
+}
+
+// handleNoise marks a domain as noise: it is blacklisted and, with learn, kept as an
+// example for proposing exclusion patterns
+func (as *AdminServer) handleNoise(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+		Learn  bool   `json:"learn"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Domain == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	nf := GetNoiseFeedback()
+	if err := nf.MarkNoise(req.Domain, req.Learn); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.Info("domain marked as noise via admin panel", "domain", req.Domain, "learn", req.Learn)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"domain":    req.Domain,
+		"proposals": nf.Proposals(noisePending),
+	})
+}
+
+// handleNoiseProposals lists learned exclusion patterns, or approves/rejects one
+func (as *AdminServer) handleNoiseProposals(w http.ResponseWriter, r *http.Request) {
+	nf := GetNoiseFeedback()
+
+	switch r.Method {
+	case http.MethodGet:
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = noisePending
+		} else if status == "all" {
+			status = ""
+		}
+		proposals := nf.Proposals(status)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"proposals": proposals,
+			"count":     len(proposals),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Pattern string `json:"pattern"`
+			Target  string `json:"target"`
+			Action  string `json:"action"` // approve or reject
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Pattern == "" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		status := ""
+		switch req.Action {
+		case "approve":
+			status = noiseApproved
+		case "reject":
+			status = noiseRejected
+		default:
+			http.Error(w, "action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+
+		if err := nf.Review(req.Pattern, req.Target, status); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		logger.Info("noise pattern reviewed via admin panel", "pattern", req.Pattern, "target", req.Target, "status", status)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"pattern": req.Pattern,
+			"target":  req.Target,
+			"status":  status,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleExpiring returns certificates expiring within ?days= (default: warn_days or 30)
+func (as *AdminServer) handleExpiring(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if cfg := GetExpiryConfig(); cfg != nil && cfg.WarnDays > 0 {
+		days = cfg.WarnDays
+	}
+	if v := r.URL.Query().Get("days"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid days parameter", http.StatusBadRequest)
+			return
+		}
+		days = parsed
+	}
+
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+
+	expiring := GetExpiringCerts(days)
+	if members != nil {
+		dt := GetDomainTracker()
+		var filtered []ExpiringCert
+		for _, cert := range expiring {
+			if entry := dt.GetDomainInfo(cert.Domain); entry != nil && inProgram(entry, members) {
+				filtered = append(filtered, cert)
+			}
+		}
+		expiring = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"days":     days,
+		"count":    len(expiring),
+		"expiring": expiring,
+	})
+}
+
 // handleConfig returns current configuration
 func (as *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 	cfg := GetConfig()
@@ -565,6 +1485,46 @@ func (as *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleDedup returns the notification dedup policy, or replaces it and saves it to the config file
+func (as *AdminServer) handleDedup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(GetDedupConfig())
+
+	case http.MethodPost:
+		var req DedupConfig
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if err := req.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := updateDedupConfig(req); err != nil {
+			logger.Error("failed to update dedup config", "error", err)
+			http.Error(w, "failed to save dedup policy", http.StatusInternalServerError)
+			return
+		}
+		if cfg := GetConfig(); cfg != nil {
+			cfg.Dedup = req
+			SetDedupConfig(&cfg.Dedup)
+		} else {
+			SetDedupConfig(&req)
+		}
+		logger.Info("dedup policy updated via admin panel", "cooldown_hours", req.CooldownHours, "max_per_day", req.MaxPerDay,
+			"blacklist_hits_per_day", req.BlacklistHitsPerDay, "blacklist_days", req.BlacklistDays)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // handleWebhooks handles webhook configuration GET and POST
 func (as *AdminServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 	cfg := GetConfig()
@@ -581,6 +1541,7 @@ func (as *AdminServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 			"subdomain_scans":     maskValue(webhookConfig.SubdomainScans),
 			"directory_scans":     maskValue(webhookConfig.DirectoryScans),
 			"daily_summary":       maskValue(webhookConfig.DailySummary),
+			"nuclei_findings":     maskValue(webhookConfig.NucleiFindings),
 		})
 	case http.MethodPost:
 		var req struct {
@@ -591,12 +1552,23 @@ func (as *AdminServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 			SubdomainScans string `json:"subdomain_scans"`
 			DirectoryScans string `json:"directory_scans"`
 			DailySummary   string `json:"daily_summary"`
+			NucleiFindings string `json:"nuclei_findings"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid request", http.StatusBadRequest)
 			return
 		}
 
+		// Fields left as the masked value from GET keep their current secret
+		req.MainWebhook = unmaskValue(req.MainWebhook, cfg.Webhook)
+		req.TelegramBot = unmaskValue(req.TelegramBot, cfg.TelegramBotToken)
+		req.TelegramChat = unmaskValue(req.TelegramChat, cfg.TelegramChatID)
+		req.NewDomains = unmaskValue(req.NewDomains, cfg.Webhooks.NewDomains)
+		req.SubdomainScans = unmaskValue(req.SubdomainScans, cfg.Webhooks.SubdomainScans)
+		req.DirectoryScans = unmaskValue(req.DirectoryScans, cfg.Webhooks.DirectoryScans)
+		req.DailySummary = unmaskValue(req.DailySummary, cfg.Webhooks.DailySummary)
+		req.NucleiFindings = unmaskValue(req.NucleiFindings, cfg.Webhooks.NucleiFindings)
+
 		// Update config file
 		if err := updateWebhooksConfig(req); err != nil {
 			logger.Error("failed to update webhooks config", "error", err)
@@ -612,6 +1584,7 @@ func (as *AdminServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 		cfg.Webhooks.SubdomainScans = req.SubdomainScans
 		cfg.Webhooks.DirectoryScans = req.DirectoryScans
 		cfg.Webhooks.DailySummary = req.DailySummary
+		cfg.Webhooks.NucleiFindings = req.NucleiFindings
 
 		// Update runtime globals used for notifications
 		webhookURL = strings.TrimSpace(cfg.Webhook)
@@ -619,6 +1592,7 @@ func (as *AdminServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 		telegramChatID = strings.TrimSpace(cfg.TelegramChatID)
 		// Update webhook config singleton
 		SetWebhookConfig(&cfg.Webhooks)
+		registerSecrets(cfg)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -672,13 +1646,16 @@ func (as *AdminServer) handleWebhookTest(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		// Reuse existing telegram sender with sample data
-		sendToTelegram("test-target", []string{"alpha.test.example", "beta.test.example"})
+		if !sendToTelegram("test-target", []string{"alpha.test.example", "beta.test.example"}) {
+			http.Error(w, "failed to send test to telegram", http.StatusBadGateway)
+			return
+		}
 	case "new_domains":
 		if wc := GetWebhookConfig(); wc == nil || strings.TrimSpace(wc.NewDomains) == "" {
 			http.Error(w, "new domains webhook not configured", http.StatusBadRequest)
 			return
 		}
-		if err := SendNewDomainNotification("test.example.com", "example.com", 200, 12345, 234, 567); err != nil {
+		if err := SendNewDomainNotification("test.example.com", "example.com", 200, 12345, 234, 567, nil); err != nil {
 			http.Error(w, "failed to send test: "+err.Error(), http.StatusBadGateway)
 			return
 		}
@@ -702,31 +1679,443 @@ func (as *AdminServer) handleWebhookTest(w http.ResponseWriter, r *http.Request)
 			http.Error(w, "failed to send test: "+err.Error(), http.StatusBadGateway)
 			return
 		}
+	case "nuclei_findings":
+		if wc := GetWebhookConfig(); wc == nil || strings.TrimSpace(wc.NucleiFindings) == "" {
+			http.Error(w, "nuclei findings webhook not configured", http.StatusBadRequest)
+			return
+		}
+		sample := []string{"[tech-detect:nginx] [http] [info] https://test.example.com", "[git-config] [http] [medium] https://test.example.com/.git/config"}
+		if err := SendNucleiFindings("test.example.com", sample); err != nil {
+			http.Error(w, "failed to send test: "+err.Error(), http.StatusBadGateway)
+			return
+		}
 	case "daily_summary":
 		if wc := GetWebhookConfig(); wc == nil || strings.TrimSpace(wc.DailySummary) == "" {
 			http.Error(w, "daily summary webhook not configured", http.StatusBadRequest)
 			return
 		}
-		summary := map[string]interface{}{
-			"discovered_count": 5,
-			"blacklisted_domains": []string{"spam.example", "noise.example"},
-			"top_hit_domains": []map[string]interface{}{
-				{"domain": "alpha.example", "hits": 42},
-				{"domain": "beta.example", "hits": 37},
-				{"domain": "gamma.example", "hits": 29},
-			},
+		summary := map[string]interface{}{
+			"discovered_count": 5,
+			"blacklisted_domains": []string{"spam.example", "noise.example"},
+			"top_hit_domains": []map[string]interface{}{
+				{"domain": "alpha.example", "hits": 42},
+				{"domain": "beta.example", "hits": 37},
+				{"domain": "gamma.example", "hits": 29},
+			},
+		}
+		if err := SendDailySummary(summary); err != nil {
+			http.Error(w, "failed to send test: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	default:
+		http.Error(w, "unknown test type", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "type": req.Type})
+}
+
+// handleTestInject feeds a synthetic certificate entry through the processing pipeline
+func (as *AdminServer) handleTestInject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Domains []string `json:"domains"`
+		Issuer  string   `json:"issuer"`
+		DryRun  *bool    `json:"dry_run"` // Defaults to true
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Domains) == 0 {
+		http.Error(w, "domains cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	dryRun := req.DryRun == nil || *req.DryRun
+	if req.Issuer == "" {
+		req.Issuer = "crtmon test CA"
+	}
+
+	now := time.Now()
+	entry := CertEntry{
+		Domains:      req.Domains,
+		NotBefore:    now,
+		NotAfter:     now.Add(90 * 24 * time.Hour),
+		Issuer:       req.Issuer,
+		LogURL:       "test://inject",
+		SerialNumber: fmt.Sprintf("%x", now.UnixNano()),
+		SANs:         req.Domains,
+		Source:       sourceManual,
+	}
+
+	// A live injection goes through the whole pipeline, like an entry from a CT log
+	decisions := evaluateEntry(entry, dryRun)
+	if !dryRun {
+		fanOutDecisions(entry, decisions)
+	}
+	logger.Info("synthetic entry injected via admin panel", "domains", len(req.Domains), "dry_run", dryRun)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run":   dryRun,
+		"decisions": decisions,
+	})
+}
+
+// handleScreenshot serves the last captured screenshot for ?domain=
+func (as *AdminServer) handleScreenshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "domain required", http.StatusBadRequest)
+		return
+	}
+
+	entry := GetDomainTracker().GetDomainInfo(domain)
+	if entry == nil || entry.Screenshot == "" {
+		http.Error(w, "screenshot not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if _, inBucket := artifactKey(entry.Screenshot); inBucket {
+		data, err := readArtifact(entry.Screenshot)
+		if err != nil {
+			http.Error(w, "screenshot not found", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+		return
+	}
+	http.ServeFile(w, r, entry.Screenshot)
+}
+
+// handleEvidence downloads the stored PEM for ?domain=, with which=first or latest
+func (as *AdminServer) handleEvidence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	if domain == "" {
+		http.Error(w, "domain required", http.StatusBadRequest)
+		return
+	}
+
+	entry := GetDomainTracker().GetDomainInfo(domain)
+	if entry == nil {
+		http.Error(w, "domain not found", http.StatusNotFound)
+		return
+	}
+	which := r.URL.Query().Get("which")
+	path, err := evidencePath(entry, which)
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "no certificate evidence stored", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", entry.Domain+"-"+filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// handleCandidates lists org-matched apex candidates, or approves/rejects one
+func (as *AdminServer) handleCandidates(w http.ResponseWriter, r *http.Request) {
+	q := GetCandidateQueue()
+
+	switch r.Method {
+	case http.MethodGet:
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = candidatePending
+		} else if status == "all" {
+			status = ""
+		}
+		candidates := q.List(status)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": candidates,
+			"count":      len(candidates),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Apex   string `json:"apex"`
+			Action string `json:"action"` // approve or reject
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		status := ""
+		switch req.Action {
+		case "approve":
+			status = candidateApproved
+		case "reject":
+			status = candidateRejected
+		default:
+			http.Error(w, "action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+
+		// Approved apexes become monitored targets, normalized like any other
+		apex := req.Apex
+		if status == candidateApproved {
+			normalized, err := normalizeTarget(req.Apex)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			apex = normalized
+		}
+
+		if err := q.SetStatus(req.Apex, status); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if status == candidateApproved && !isKnownApex(apex) {
+			targets = append(targets, apex)
+			if cfg := getConfig(); cfg != nil {
+				cfg.Targets = targets
+				if err := SaveConfig(); err != nil {
+					logger.Error("failed to save config after approving candidate", "error", err)
+				}
+			}
+			if sm := GetSNIManager(); sm != nil {
+				go sm.SearchSNIOnDemand(apex)
+			}
+		}
+
+		logger.Info("org candidate reviewed via admin panel", "apex", req.Apex, "status", status)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"apex":    req.Apex,
+			"status":  status,
+			"targets": targets,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleJobs lists running, queued and finished scans, or cancels one with ?id=
+func (as *AdminServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	jm := GetJobManager()
+
+	switch r.Method {
+	case http.MethodGet:
+		running, queued := jm.Counts()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"jobs":    jm.List(),
+			"running": running,
+			"queued":  queued,
+		})
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, "id required", http.StatusBadRequest)
+			return
+		}
+		if err := jm.Cancel(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logger.Info("scan cancelled via admin panel", "id", id)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      id,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScan queues enumeration of a tracked domain on demand. Type is ferox, puredns,
+// nuclei or all; puredns brute forces names under the domain.
+func (as *AdminServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+		Type   string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Type == "" {
+		req.Type = "all"
+	}
+	if req.Type != "ferox" && req.Type != "puredns" && req.Type != "nuclei" && req.Type != "all" {
+		http.Error(w, "type must be ferox, puredns, nuclei or all", http.StatusBadRequest)
+		return
+	}
+
+	entry := GetDomainTracker().GetDomainInfo(req.Domain)
+	if entry == nil {
+		http.Error(w, "domain not found", http.StatusNotFound)
+		return
+	}
+	if !isEnumEnabled() {
+		http.Error(w, "enumeration is not enabled", http.StatusConflict)
+		return
+	}
+	domainTargets := matchingTargets(entry)
+	if len(domainTargets) == 0 {
+		http.Error(w, "domain matches no current target", http.StatusConflict)
+		return
+	}
+	domain, target := entry.Domain, domainTargets[0]
+	if IsWildcardDomain(domain) && (req.Type == "ferox" || req.Type == "nuclei") {
+		http.Error(w, req.Type+" needs a host, not a wildcard", http.StatusBadRequest)
+		return
+	}
+
+	var errs []string
+	queue := func(scanType string, run func(string, string) (string, error), scanDomain string) {
+		if _, err := run(scanDomain, target); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", scanType, err))
+		}
+	}
+	if req.Type == "puredns" || req.Type == "all" {
+		queue("puredns", RunPuredns, ExtractBaseDomain(domain))
+	}
+	if !IsWildcardDomain(domain) {
+		if req.Type == "ferox" || req.Type == "all" {
+			queue("feroxbuster", RunFeroxbuster, domain)
 		}
-		if err := SendDailySummary(summary); err != nil {
-			http.Error(w, "failed to send test: "+err.Error(), http.StatusBadGateway)
-			return
+		if req.Type == "nuclei" || (req.Type == "all" && isNucleiEnabled()) {
+			queue("nuclei", RunNuclei, domain)
 		}
-	default:
-		http.Error(w, "unknown test type", http.StatusBadRequest)
+	}
+
+	pending := pendingJobs(domain)
+	if len(pending) == 0 {
+		http.Error(w, "no scan queued: "+strings.Join(errs, "; "), http.StatusServiceUnavailable)
 		return
 	}
+	logger.Info("scan requested via admin panel", "domain", domain, "type", req.Type, "queued", len(pending))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "type": req.Type})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"domain":  domain,
+		"jobs":    pending,
+		"errors":  errs,
+	})
+}
+
+// handleErrors returns recent errors and per-category counts, or clears them
+func (as *AdminServer) handleErrors(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors":  GetRecentErrors(),
+			"summary": GetErrorSummary(),
+		})
+
+	case http.MethodDelete:
+		ClearErrors()
+		logger.Info("error log cleared via admin panel")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "errors cleared",
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEvents streams discoveries, scan progress and CT log health as server-sent
+// events
+func (as *AdminServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch, backlog := live.Subscribe()
+	defer live.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	send := func(event LiveEvent) bool {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return true
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	for _, event := range backlog {
+		if !send(event) {
+			return
+		}
+	}
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	// Comments keep proxies from closing an idle stream
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-ch:
+			if !send(event) {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
 }
 
 // serveUI serves the dashboard HTML
@@ -770,41 +2159,12 @@ func loadOrCreateAuth(authFile string) error {
 		return err
 	}
 
-	logger.Warn("admin panel first-time setup", "default_password", defaultPass, "auth_file", authFile)
+	logger.Warn("admin panel first-time setup, log in with the default password \"admin\"", "auth_file", authFile)
 	logger.Warn("IMPORTANT: Change the default password immediately via admin panel!")
 
 	return nil
 }
 
-// generateToken generates an auth token from password
-func generateToken(password string) string {
-	hash := sha256.Sum256([]byte(password + time.Now().Format("20060102")))
-	return fmt.Sprintf("%x", hash)
-}
-
-// verifyToken verifies an auth token
-func verifyToken(token string) bool {
-	// Simple token verification: check if it matches today's or yesterday's hash
-	if adminAuthHash == "" || token == "" {
-		return false
-	}
-
-	// We need to check against the stored password
-	// Since we can't reverse bcrypt, we accept any valid token
-	// In a real system, would use JWT or session management
-	// For now, accept tokens that look valid (64 char hex SHA256)
-	if len(token) != 64 {
-		return false
-	}
-	// Validate hex chars
-	for _, c := range token {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
-			return false
-		}
-	}
-	return true
-}
-
 // Helper functions
 
 func formatDuration(d time.Duration) string {
@@ -825,16 +2185,6 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%ds", seconds)
 }
 
-func maskValue(value string) string {
-	if value == "" {
-		return ""
-	}
-	if len(value) <= 4 {
-		return "****"
-	}
-	return value[:4] + "****" + value[len(value)-4:]
-}
-
 // GetConfig returns the global config
 func GetConfig() *Config {
 	return globalConfig
@@ -853,6 +2203,10 @@ func init() {
 let authToken = localStorage.getItem('adminToken');
 let updateInterval = null;
 let topDomainsChart = null;
+let liveSource = null;
+let refreshTimer = null;
+let liveStatsTimer = null;
+const maxFeedItems = 200;
 
 // Initialize
 document.addEventListener('DOMContentLoaded', () => {
@@ -860,14 +2214,168 @@ document.addEventListener('DOMContentLoaded', () => {
         showDashboard();
         loadStats();
         updateInterval = setInterval(loadStats, 5000);
+        scheduleRefresh(localStorage.getItem('adminTokenExpiry'));
+        connectLiveFeed();
+        openFromHash();
     } else {
         showLogin();
     }
 	document.getElementById('loginForm').addEventListener('submit', handleLogin);
 	document.getElementById('addTargetForm')?.addEventListener('submit', handleAddTarget);
 	document.getElementById('webhookForm')?.addEventListener('submit', saveWebhooks);
+	document.getElementById('apiKeyForm')?.addEventListener('submit', createAPIKey);
+	document.getElementById('dedupForm')?.addEventListener('submit', saveDedup);
+	window.addEventListener('hashchange', openFromHash);
 });
 
+// Deep links from notifications look like #domain=<name> or #report=<file>
+function openFromHash() {
+    if (!authToken) return;
+    if (location.hash.startsWith('#domain=')) {
+        showDomainDetail(decodeURIComponent(location.hash.substring('#domain='.length)));
+    } else if (location.hash.startsWith('#report=')) {
+        const name = decodeURIComponent(location.hash.substring('#report='.length));
+        // Cleared so a reload doesn't open the report again
+        history.replaceState(null, '', location.pathname + location.search);
+        openWithToken('/api/reports?name=' + encodeURIComponent(name), false);
+    }
+}
+
+async function showDomainDetail(domain) {
+    switchTab('domainDetail');
+    document.getElementById('domainDetailTitle').textContent = domain;
+    const tbody = document.getElementById('domainDetailTable');
+    try {
+        const d = await apiCall('/api/domains/' + encodeURIComponent(domain));
+        detailDomain = d;
+        document.getElementById('detailBlacklistBtn').textContent = d.blacklisted ? 'Unblacklist' : 'Blacklist';
+        const snoozed = d.snoozed_until && new Date(d.snoozed_until) > new Date();
+        document.getElementById('detailSnoozeBtn').textContent = snoozed ? 'Unsnooze' : 'Snooze';
+        const rows = [
+            ['Hits', d.hit_count],
+            ['Source', d.source || 'certstream'],
+            ['Targets', d.targets && d.targets.length ? d.targets.join(', ') : '-'],
+            ['First Seen', new Date(d.first_seen).toLocaleString()],
+            ['Last Seen', new Date(d.last_seen).toLocaleString()],
+            ['Last Rescan', d.last_rescan && !d.last_rescan.startsWith('0001') ? new Date(d.last_rescan).toLocaleString() : '-'],
+            ['Resolved', d.resolved ? 'yes' : 'no'],
+            ['Asset State', (d.asset_state || 'new') + (d.state_changed && !d.state_changed.startsWith('0001') ? ' since ' + new Date(d.state_changed).toLocaleString() : '')],
+            ['Addresses', d.addrs && d.addrs.length ? d.addrs.join(', ') : '-'],
+            ['Countries', d.countries && d.countries.length ? d.countries.join(', ') : '-'],
+            ['CNAME Chain', d.cnames && d.cnames.length ? d.cnames.join(' -> ') : '-'],
+            ['Takeover Candidate', d.takeover || 'no'],
+            ['Unexpected Issuer', d.unexpected_issuer || 'no'],
+            ['CAA Violation', d.caa_violation || 'no'],
+            ['Time to Notify', d.notify_latency_ms ? formatLatency(d.notify_latency_ms / 1000) : '-'],
+            ['Blacklisted', d.blacklisted ? 'yes' : 'no'],
+            ['Snoozed Until', snoozed ? new Date(d.snoozed_until).toLocaleString() : '-'],
+            ['Risk', (d.risk_score || 0) + (d.risk_labels && d.risk_labels.length ? ' (' + d.risk_labels.join(', ') + ')' : '')],
+            ['HTTP Status', d.http_status_code || '-'],
+            ['Status History', d.status_code_history && d.status_code_history.length ? d.status_code_history.join(', ') : '-'],
+            ['Title', d.probe ? d.probe.title : '-'],
+            ['Server', d.probe ? d.probe.server : '-'],
+            ['Issuer', d.cert_issuer || '-'],
+            ['Previous Issuer', d.previous_issuer || '-'],
+            ['Certificate Expiry', d.cert_expiry && !d.cert_expiry.startsWith('0001') ? new Date(d.cert_expiry).toLocaleDateString() : '-'],
+            ['SANs', d.certificate && d.certificate.sans ? d.certificate.sans.join(', ') : '-'],
+        ];
+        const days = Object.keys(d.daily_hits || {}).sort().reverse().slice(0, 14);
+        if (days.length) {
+            rows.push(['Daily Hits', days.map(day => day + ': ' + d.daily_hits[day]).join(', ')]);
+        }
+        (d.scans || []).forEach(j => rows.push(['Scan ' + j.type, j.status + (j.finished_at && !j.finished_at.startsWith('0001') ? ' at ' + new Date(j.finished_at).toLocaleString() : '') + (j.error ? ' (' + j.error + ')' : '')]));
+        if (d.scan_files && d.scan_files.length) {
+            rows.push(['Scan Output', d.scan_files.map(f => '<a href="#" data-href="' + escapeHtml('/api/domains/' + encodeURIComponent(domain) + '/output?file=' + encodeURIComponent(f)) + '">' + escapeHtml(f.split('/').pop()) + '</a>').join(' | ')]);
+        }
+        if (d.port_scan) {
+            const scanned = (d.port_scan.open || []).map(p => d.port_scan.services && d.port_scan.services[p] ? p + ' (' + d.port_scan.services[p] + ')' : String(p));
+            rows.push(['Scanned Ports', (scanned.length ? scanned.join(', ') : 'none open') + ' via ' + d.port_scan.scanner + ' at ' + new Date(d.port_scan.scanned_at).toLocaleString()]);
+        }
+        if (d.shodan) {
+            rows.push(['Open Ports', d.shodan.ports && d.shodan.ports.length ? d.shodan.ports.join(', ') : '-']);
+            rows.push(['Exposed Services', d.shodan.notable && d.shodan.notable.length ? d.shodan.notable.join(', ') : 'none']);
+            if (d.shodan.vulns && d.shodan.vulns.length) {
+                rows.push(['Vulnerabilities', d.shodan.vulns.join(', ')]);
+            }
+            (d.shodan.services || []).forEach(s => rows.push(['Port ' + Number(s.port) + ' on ' + escapeHtml(s.addr),
+                [[s.product, s.version].filter(Boolean).join(' '), s.banner, s.tls_subject ? 'TLS ' + s.tls_subject + (s.tls_expires ? ' until ' + s.tls_expires : '') : ''].filter(Boolean).join(' | ') || '-']));
+        }
+        if (d.reputation && d.reputation.virustotal) {
+            const vt = d.reputation.virustotal;
+            rows.push(['VirusTotal', vt.known ? vt.malicious + ' malicious, ' + vt.suspicious + ' suspicious, ' + vt.harmless + ' harmless (reputation ' + vt.reputation + ')' : 'not seen before' + (vt.analysis_id ? ', analysis ' + vt.analysis_id : '')]);
+        }
+        if (d.reputation && d.reputation.urlscan) {
+            const us = d.reputation.urlscan;
+            const id = encodeURIComponent(us.scan_id);
+            const verdict = !us.done ? 'pending' : us.malicious ? 'malicious, score ' + Number(us.score) : 'no verdict';
+            rows.push(['urlscan.io', escapeHtml(verdict + (us.categories && us.categories.length ? ' (' + us.categories.join(', ') + ')' : '')) + ' | <a href="https://urlscan.io/result/' + id + '/" target="_blank">Result</a> | <a href="https://urlscan.io/screenshots/' + id + '.png" target="_blank">Screenshot</a>']);
+        }
+        if (d.code_hits && d.code_hits.length) {
+            const safeURL = u => /^https?:\/\//.test(u) ? escapeHtml(u).replace(/"/g, '%22') : '#';
+            rows.push(['Code mentions', d.code_hits.map(h => '<a href="' + safeURL(h.url) + '" target="_blank" rel="noopener">' + escapeHtml(h.repo + '/' + h.path) + '</a> (' + escapeHtml(h.service) + ')').join('<br>')]);
+        }
+        if (d.vantage_answers) {
+            Object.keys(d.vantage_answers).sort().forEach(v => rows.push(['DNS via ' + v, d.vantage_answers[v].length ? d.vantage_answers[v].join(', ') : 'no answer']));
+        }
+        const evidence = [];
+        if (d.evidence_first) evidence.push('<a href="#" data-href="' + escapeHtml('/api/evidence?which=first&domain=' + encodeURIComponent(domain)) + '" data-download>First seen</a>');
+        if (d.evidence_latest) evidence.push('<a href="#" data-href="' + escapeHtml('/api/evidence?which=latest&domain=' + encodeURIComponent(domain)) + '" data-download>Most recent</a>');
+        if (evidence.length) {
+            rows.push(['Certificate PEM', evidence.join(' | ')]);
+        }
+        if (d.screenshot) {
+            rows.push(['Screenshot', '<a href="#" data-href="' + escapeHtml('/api/screenshot?domain=' + encodeURIComponent(domain)) + '">View</a>']);
+        }
+        const links = ['Screenshot', 'Certificate PEM', 'Scan Output', 'urlscan.io', 'Code mentions'];
+        tbody.innerHTML = rows.map(r => '<tr><td>' + r[0] + '</td><td>' + (links.includes(r[0]) ? r[1] : escapeHtml(String(r[1]))) + '</td></tr>').join('');
+    } catch (err) {
+        detailDomain = null;
+        tbody.innerHTML = '<tr><td colspan="2" style="text-align: center; padding: 20px;">Domain not found</td></tr>';
+        console.error('Failed to load domain:', err);
+    }
+}
+
+// Actions on the domain open in the detail view
+let detailDomain = null;
+
+async function domainAction(action) {
+    if (!detailDomain) return;
+    const domain = detailDomain.domain;
+    const unblacklist = action === 'blacklist' && detailDomain.blacklisted;
+    if (action === 'blacklist' && !confirm((unblacklist ? 'Unblacklist ' : 'Blacklist ') + domain + '?')) return;
+    const unsnooze = action === 'snooze' && detailDomain.snoozed_until && new Date(detailDomain.snoozed_until) > new Date();
+    const options = {method: unblacklist || unsnooze ? 'DELETE' : 'POST'};
+    if (action === 'snooze' && !unsnooze) {
+        const days = parseInt(prompt('Mute notifications for ' + domain + ' for how many days?', '7'), 10);
+        if (!days) return;
+        options.headers = {'Content-Type': 'application/json'};
+        options.body = JSON.stringify({days});
+    }
+    try {
+        const data = await apiCall('/api/domains/' + encodeURIComponent(domain) + '/' + action, options);
+        if (action === 'snooze') showSuccessMessage(unsnooze ? domain + ' unsnoozed' : domain + ' snoozed until ' + new Date(data.snoozed_until).toLocaleString(), 'domainDetailSuccess');
+        else if (action === 'notify') showSuccessMessage('Notification sent to ' + data.targets.join(', '), 'domainDetailSuccess');
+        else showSuccessMessage(domain + (data.blacklisted ? ' blacklisted' : ' removed from blacklist'), 'domainDetailSuccess');
+        showDomainDetail(domain);
+    } catch (err) {
+        alert('Action failed: ' + err.message);
+    }
+}
+
+// Queued scans show up in the Scans rows of the detail view and the scan queue card
+async function scanDomain() {
+    if (!detailDomain) return;
+    const domain = detailDomain.domain;
+    const type = document.getElementById('detailScanType').value;
+    try {
+        const data = await apiCall('/api/scan', {method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify({domain, type})});
+        showSuccessMessage(data.jobs.length + ' scan(s) queued for ' + domain + (data.errors && data.errors.length ? ' (' + data.errors.join('; ') + ')' : ''), 'domainDetailSuccess');
+        showDomainDetail(domain);
+    } catch (err) {
+        alert('Scan failed: ' + err.message);
+    }
+}
+
 async function handleLogin(e) {
     e.preventDefault();
     const password = document.getElementById('password').value;
@@ -881,35 +2389,174 @@ async function handleLogin(e) {
         });
         if (!response.ok) throw new Error('Invalid password');
         const data = await response.json();
-        authToken = data.token;
-        localStorage.setItem('adminToken', authToken);
+        setSession(data);
         document.getElementById('password').value = '';
         showDashboard();
         loadStats();
         updateInterval = setInterval(loadStats, 5000);
+        connectLiveFeed();
+        openFromHash();
     } catch (err) {
         errorDiv.textContent = err.message;
         errorDiv.style.display = 'block';
     }
 }
 
+function setSession(data) {
+    authToken = data.token;
+    localStorage.setItem('adminToken', authToken);
+    localStorage.setItem('adminTokenExpiry', data.expires_at);
+    scheduleRefresh(data.expires_at);
+}
+
+// Sessions expire, so swap the token for a new one 5 minutes before it does
+function scheduleRefresh(expiresAt) {
+    clearTimeout(refreshTimer);
+    if (!expiresAt) return;
+    const delay = Math.max(new Date(expiresAt).getTime() - Date.now() - 5 * 60 * 1000, 10000);
+    refreshTimer = setTimeout(refreshSession, delay);
+}
+
+async function refreshSession() {
+    try {
+        setSession(await apiCall('/api/auth/refresh', {method: 'POST'}));
+        // The live feed was opened with the old token, which is now revoked
+        if (liveSource) {
+            liveSource.close();
+            liveSource = null;
+            connectLiveFeed();
+        }
+    } catch (err) {
+        console.error('Failed to refresh session:', err);
+    }
+}
+
 function logout() {
+    if (authToken) {
+        fetch('/api/auth/logout', {method: 'POST', headers: {'Authorization': authToken}}).catch(() => {});
+    }
     authToken = null;
     localStorage.removeItem('adminToken');
+    localStorage.removeItem('adminTokenExpiry');
     clearInterval(updateInterval);
+    clearTimeout(refreshTimer);
+    if (liveSource) {
+        liveSource.close();
+        liveSource = null;
+    }
     showLogin();
 }
 
+// Live feed: /api/events pushes discoveries, scan progress and CT log health.
+// While it's connected stats only need a slow poll plus a refresh after each event.
+// EventSource can't send the Authorization header, so the stream is read with fetch
+// and reconnected after 5 seconds when it drops.
+function connectLiveFeed() {
+    if (liveSource || !window.ReadableStream) return;
+    const controller = new AbortController();
+    const source = {close: () => controller.abort()};
+    liveSource = source;
+    const disconnected = () => {
+        setLiveStatus(false);
+        clearInterval(updateInterval);
+        updateInterval = setInterval(loadStats, 5000);
+        setTimeout(() => {
+            if (liveSource === source) {
+                liveSource = null;
+                connectLiveFeed();
+            }
+        }, 5000);
+    };
+    fetch('/api/events', {headers: {'Authorization': authToken}, signal: controller.signal}).then(async response => {
+        if (response.status === 401) {
+            logout();
+            return;
+        }
+        if (!response.ok || !response.body) throw new Error('HTTP ' + response.status);
+        setLiveStatus(true);
+        clearInterval(updateInterval);
+        updateInterval = setInterval(loadStats, 30000);
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        for (;;) {
+            const {done, value} = await reader.read();
+            if (done) break;
+            buffer += decoder.decode(value, {stream: true});
+            let end;
+            while ((end = buffer.indexOf('\n\n')) >= 0) {
+                handleLiveMessage(buffer.slice(0, end));
+                buffer = buffer.slice(end + 2);
+            }
+        }
+        disconnected();
+    }).catch(() => {
+        if (!controller.signal.aborted) disconnected();
+    });
+}
+
+// handleLiveMessage handles one server-sent event; comments only keep the stream open
+function handleLiveMessage(message) {
+    let type = '', data = '';
+    message.split('\n').forEach(line => {
+        if (line.startsWith('event: ')) type = line.slice(7);
+        else if (line.startsWith('data: ')) data += line.slice(6);
+    });
+    if (!['discovery', 'scan_started', 'scan_finished', 'ct_health'].includes(type) || !data) return;
+    addFeedItem(JSON.parse(data));
+    clearTimeout(liveStatsTimer);
+    liveStatsTimer = setTimeout(loadStats, 1000);
+}
+
+function setLiveStatus(connected) {
+    const el = document.getElementById('liveStatus');
+    if (!el) return;
+    el.className = 'badge ' + (connected ? 'badge-success' : 'badge-warning');
+    el.textContent = connected ? 'Live' : 'Reconnecting...';
+}
+
+function addFeedItem(event) {
+    const list = document.getElementById('activityFeed');
+    if (!list) return;
+    const d = event.data || {};
+    let badge = 'badge-success', label = event.type, text = '';
+    if (event.type === 'discovery') {
+        label = 'New domain';
+        text = '<a href="#domain=' + encodeURIComponent(d.domain) + '">' + escapeHtml(d.domain) + '</a> (' + escapeHtml(d.target) + ')' + (d.issuer ? ' issued by ' + escapeHtml(d.issuer) : '') + (d.resolves ? '' : ', does not resolve');
+    } else if (event.type === 'scan_started') {
+        badge = 'badge-warning';
+        label = 'Scan started';
+        text = escapeHtml(d.type) + ' ' + escapeHtml(d.domain);
+    } else if (event.type === 'scan_finished') {
+        badge = d.status === 'finished' ? 'badge-success' : 'badge-danger';
+        label = 'Scan ' + d.status;
+        text = escapeHtml(d.type) + ' ' + escapeHtml(d.domain);
+    } else if (event.type === 'ct_health') {
+        badge = d.disconnected > 0 ? 'badge-danger' : 'badge-success';
+        label = 'CT logs';
+        text = d.active + ' active, ' + d.disconnected + ' disconnected';
+    }
+    const placeholder = list.querySelector('.feed-empty');
+    if (placeholder) placeholder.remove();
+    const row = document.createElement('tr');
+    row.innerHTML = '<td>' + new Date(event.time).toLocaleTimeString() + '</td><td><span class="badge ' + badge + '">' + escapeHtml(label) + '</span></td><td>' + text + '</td>';
+    list.insertBefore(row, list.firstChild);
+    while (list.children.length > maxFeedItems) list.removeChild(list.lastChild);
+}
+
 function switchTab(tab) {
     document.querySelectorAll('.content-section').forEach(el => el.classList.remove('active'));
     document.getElementById(tab).classList.add('active');
     document.querySelectorAll('.nav-link').forEach(el => el.classList.remove('active'));
-    document.querySelector('[data-tab="' + tab + '"]').classList.add('active');
+    document.querySelector('[data-tab="' + tab + '"]')?.classList.add('active');
     if (tab === 'domains') loadDomains();
+    else if (tab === 'assets') loadAssets();
+    else if (tab === 'graph') loadGraph();
     else if (tab === 'targets') loadTargets();
-    else if (tab === 'blacklist') loadBlacklist();
-		else if (tab === 'config') loadConfig();
+    else if (tab === 'blacklist') { loadBlacklist(); loadNoiseProposals(); }
+		else if (tab === 'config') { loadConfig(); loadDedup(); loadAPIKeys(); }
 		else if (tab === 'webhooks') loadWebhooks();
+		else if (tab === 'issues') loadIssues();
 }
 // Webhooks panel
 async function loadWebhooks() {
@@ -924,6 +2571,7 @@ async function loadWebhooks() {
 		setVal('subdomainScansWebhook', data.subdomain_scans);
 		setVal('directoryScansWebhook', data.directory_scans);
 		setVal('dailySummaryWebhook', data.daily_summary);
+		setVal('nucleiFindingsWebhook', data.nuclei_findings);
 	} catch (err) {
 		console.error('Failed to load webhooks:', err);
 	}
@@ -939,6 +2587,7 @@ async function saveWebhooks(e) {
 		subdomain_scans: document.getElementById('subdomainScansWebhook').value.trim(),
 		directory_scans: document.getElementById('directoryScansWebhook').value.trim(),
 		daily_summary: document.getElementById('dailySummaryWebhook').value.trim(),
+		nuclei_findings: document.getElementById('nucleiFindingsWebhook').value.trim(),
 	};
 	try {
 		await apiCall('/api/webhooks', {
@@ -1008,11 +2657,15 @@ async function loadStats() {
         
         const enumSuccess = stats.enumeration?.success_rate || 0;
         document.getElementById('enumSuccessValue').textContent = enumSuccess.toFixed(1) + '%';
+
+        const latency = stats.notify_latency || {};
+        document.getElementById('notifyLatencyValue').textContent = latency.samples ? formatLatency(latency.p50_seconds) + ' / ' + formatLatency(latency.p95_seconds) : '--';
         
         // Update charts
         updateStatusCodeChart(stats.domains.status_code_dist || {});
         updateDiscoveryRateChart(stats.discovery_rate || []);
         updateTopTargetsChart(stats.top_targets || {});
+        updateSourcesTable(stats.sources || []);
     } catch (err) {
         console.error('Failed to load stats:', err);
     }
@@ -1020,35 +2673,115 @@ async function loadStats() {
 
 async function loadDomains() {
     try {
-        const data = await apiCall('/api/domains');
+        const provider = document.getElementById('domainsProvider').value;
+        const data = await apiCall('/api/domains' + (provider ? '?provider=' + encodeURIComponent(provider) : ''));
         const tbody = document.getElementById('domainsTable');
         if (data.domains.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="7" style="text-align: center; padding: 20px;">No domains tracked yet</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="10" style="text-align: center; padding: 20px;">' + (provider ? 'No domains hosted there' : 'No domains tracked yet') + '</td></tr>';
             return;
         }
         data.domains.sort((a, b) => b.hit_count - a.hit_count);
         tbody.innerHTML = data.domains.map(d => {
             const riskColor = d.risk_score >= 70 ? '#fca5a5' : (d.risk_score >= 50 ? '#fdba74' : '#86efac');
             const riskBadge = '<span style="color: ' + riskColor + '; font-weight: bold;">' + (d.risk_score || 0) + '</span>';
-            const labels = d.risk_labels && d.risk_labels.length > 0 ? d.risk_labels.map(l => '<span class="badge badge-warning" style="margin: 2px;">' + l + '</span>').join(' ') : '-';
-            const statusBadge = d.blacklisted ? '<span class="badge badge-danger">Blacklisted</span>' : (d.is_duplicate ? '<span class="badge badge-warning">Duplicate</span>' : '<span class="badge badge-success">Active</span>');
-            return '<tr><td>' + d.domain + '</td><td>' + d.hit_count + '</td><td>' + riskBadge + '</td><td>' + labels + '</td><td>' + new Date(d.first_seen).toLocaleDateString() + '</td><td>' + new Date(d.last_seen).toLocaleDateString() + '</td><td>' + statusBadge + '</td></tr>';
+            const labels = d.risk_labels && d.risk_labels.length > 0 ? d.risk_labels.map(l => '<span class="badge badge-warning" style="margin: 2px;">' + escapeHtml(l) + '</span>').join(' ') : '-';
+            const statusBadge = d.blacklisted ? '<span class="badge badge-danger">Blacklisted</span>' : d.snoozed ? '<span class="badge badge-warning">Snoozed</span>' : (d.is_duplicate ? '<span class="badge badge-warning">Duplicate</span>' : '<span class="badge badge-success">Active</span>');
+            const network = (d.providers || []).map(p => '<span class="badge badge-info" style="margin: 2px;">' + p + '</span>').join(' ') + (d.asns && d.asns.length ? ' ' + escapeHtml(d.asns.join(', ')) : '') + (d.countries && d.countries.length ? ' [' + escapeHtml(d.countries.join(', ')) + ']' : '') || '-';
+            const screenshot = d.has_screenshot ? '<a href="#" data-href="' + escapeHtml('/api/screenshot?domain=' + encodeURIComponent(d.domain)) + '">View</a>' : '-';
+            return '<tr class="domain-row" data-domain="' + escapeHtml(d.domain) + '"><td><a href="#domain=' + encodeURIComponent(d.domain) + '">' + escapeHtml(d.domain) + '</a></td><td>' + d.hit_count + '</td><td>' + riskBadge + '</td><td>' + labels + '</td><td>' + network + '</td><td>' + new Date(d.first_seen).toLocaleDateString() + '</td><td>' + new Date(d.last_seen).toLocaleDateString() + '</td><td>' + statusBadge + '</td><td>' + screenshot + '</td><td>' + (d.blacklisted ? '-' : '<div class="action-buttons"><button class="action-btn action-btn-danger noise-btn" data-domain="' + escapeHtml(d.domain) + '">Noise</button></div>') + '</td></tr>';
         }).join('');
+        tbody.querySelectorAll('.noise-btn').forEach(btn => {
+            btn.addEventListener('click', () => markNoise(btn.getAttribute('data-domain')));
+        });
+        tbody.querySelectorAll('.domain-row').forEach(row => {
+            row.addEventListener('click', e => {
+                if (e.target.closest('a, button')) return;
+                const hash = '#domain=' + encodeURIComponent(row.getAttribute('data-domain'));
+                if (location.hash === hash) openFromHash();
+                else location.hash = hash;
+            });
+        });
         updateTopDomainsChart(data.domains);
     } catch (err) {
         console.error('Failed to load domains:', err);
     }
 }
 
+// Asset inventory: tracked domains grouped by target with their lifecycle state
+const assetStateBadges = {new: 'badge-info', live: 'badge-success', offline: 'badge-warning', retired: 'badge-danger'};
+
+async function loadAssets() {
+    const select = document.getElementById('assetsTarget');
+    const state = document.getElementById('assetsState').value;
+    const tbody = document.getElementById('assetsTable');
+    try {
+        if (select.options.length === 1) {
+            const t = await apiCall('/api/targets');
+            t.targets.forEach(target => select.add(new Option((t.display && t.display[target]) || target, target)));
+        }
+        const params = new URLSearchParams();
+        if (select.value) params.set('target', select.value);
+        if (state) params.set('state', state);
+        const data = await apiCall('/api/assets?' + params.toString());
+        if (data.targets.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="6" style="text-align: center; padding: 20px;">No assets tracked yet</td></tr>';
+            return;
+        }
+        const date = t => t && !t.startsWith('0001') ? new Date(t).toLocaleDateString() : '-';
+        tbody.innerHTML = data.targets.map(g => {
+            const counts = ['new', 'live', 'offline', 'retired'].map(s => '<span class="badge ' + assetStateBadges[s] + '" style="margin: 2px;">' + s + ' ' + g.counts[s] + '</span>').join(' ');
+            const header = '<tr><td colspan="6" style="background: rgba(59, 130, 246, 0.1);"><strong>' + escapeHtml(g.target) + '</strong> ' + counts + '</td></tr>';
+            return header + g.assets.map(a => '<tr class="asset-row" data-domain="' + escapeHtml(a.domain) + '"><td><a href="#domain=' + encodeURIComponent(a.domain) + '">' + escapeHtml(a.domain) + '</a></td><td><span class="badge ' + (assetStateBadges[a.state] || 'badge-info') + '">' + a.state + '</span></td><td>' + date(a.state_changed) + '</td><td>' + date(a.last_live) + '</td><td>' + (a.status_code || '-') + (a.title ? ' ' + escapeHtml(a.title) : '') + '</td><td>' + (a.risk_score || 0) + '</td></tr>').join('');
+        }).join('');
+    } catch (err) {
+        console.error('Failed to load assets:', err);
+    }
+}
+
+// Asset graph: target -> subdomain -> address -> ASN, and subdomain -> certificate
+const graphColors = {target: '#3b82f6', domain: '#22c55e', ip: '#f59e0b', asn: '#a855f7', cert: '#ef4444'};
+let assetNetwork = null;
+
+async function loadGraph() {
+    const select = document.getElementById('graphTarget');
+    const note = document.getElementById('graphNote');
+    try {
+        if (select.options.length === 1) {
+            const t = await apiCall('/api/targets');
+            t.targets.forEach(target => select.add(new Option((t.display && t.display[target]) || target, target)));
+        }
+        const data = await apiCall('/api/graph' + (select.value ? '?target=' + encodeURIComponent(select.value) : ''));
+        if (typeof vis === 'undefined') {
+            note.textContent = 'The graph library could not be loaded.';
+            return;
+        }
+        const nodes = data.nodes.map(n => ({id: n.id, label: n.label, group: n.type, color: graphColors[n.type], shape: n.type === 'domain' ? 'dot' : 'box', font: {color: '#e2e8f0'}}));
+        const edges = data.edges.map(e => ({from: e.from, to: e.to, arrows: 'to', color: {color: '#475569'}}));
+        note.textContent = data.nodes.length + ' nodes, ' + data.edges.length + ' edges' + (data.truncated ? ' (most recently seen domains only)' : '') + '. Click a domain to open it.';
+        const options = {physics: {stabilization: {iterations: 200}}, nodes: {size: 10}, interaction: {hover: true}};
+        if (assetNetwork) assetNetwork.destroy();
+        assetNetwork = new vis.Network(document.getElementById('graphCanvas'), {nodes: new vis.DataSet(nodes), edges: new vis.DataSet(edges)}, options);
+        assetNetwork.on('click', params => {
+            const id = params.nodes[0];
+            if (id && id.startsWith('domain:')) location.hash = '#domain=' + encodeURIComponent(id.substring('domain:'.length));
+        });
+    } catch (err) {
+        console.error('Failed to load graph:', err);
+    }
+}
+
 async function loadTargets() {
     try {
         const data = await apiCall('/api/targets');
         const tbody = document.getElementById('targetsTable');
         if (data.targets.length === 0) {
-            tbody.innerHTML = '<tr><td colspan="2" style="text-align: center; padding: 20px;">No targets configured</td></tr>';
+            tbody.innerHTML = '<tr><td colspan="3" style="text-align: center; padding: 20px;">No targets configured</td></tr>';
             return;
         }
-        tbody.innerHTML = data.targets.map(t => '<tr><td>' + t + '</td><td><div class="action-buttons"><button class="action-btn action-btn-danger" data-target="' + t + '">Remove</button></div></td></tr>').join('');
+        const freshness = {};
+        (data.freshness || []).forEach(f => freshness[f.target] = f);
+        const display = data.display || {};
+        tbody.innerHTML = data.targets.map(t => '<tr><td>' + (display[t] ? display[t] + ' <span style="color: #94a3b8;">(' + t + ')</span>' : t) + '</td><td>' + freshnessBadge(freshness[t]) + '</td><td><div class="action-buttons"><button class="action-btn action-btn-danger" data-target="' + t + '">Remove</button></div></td></tr>').join('');
         // Attach event listeners after rendering
         tbody.querySelectorAll('.action-btn-danger').forEach(btn => {
             btn.addEventListener('click', () => deleteTarget(btn.getAttribute('data-target')));
@@ -1058,6 +2791,13 @@ async function loadTargets() {
     }
 }
 
+function freshnessBadge(f) {
+    if (!f) return '-';
+    const classes = {fresh: 'badge-success', stale: 'badge-warning', dead: 'badge-danger', pending: 'badge-warning'};
+    const lastSeen = f.last_cert_seen && !f.last_cert_seen.startsWith('0001') ? new Date(f.last_cert_seen).toLocaleDateString() : 'never';
+    return '<span class="badge ' + (classes[f.status] || 'badge-warning') + '" title="Last certificate: ' + lastSeen + '">' + f.status + ' (' + f.idle_days + 'd)</span>';
+}
+
 async function handleAddTarget(e) {
     e.preventDefault();
     const target = document.getElementById('newTarget').value.trim();
@@ -1103,6 +2843,149 @@ async function loadBlacklist() {
     }
 }
 
+// Marking noise blacklists the domain; learning keeps it as an example so similar
+// noise can be proposed as an exclusion pattern
+async function markNoise(domain) {
+    if (!confirm('Mark ' + domain + ' as noise? It will be blacklisted.')) return;
+    const learn = confirm('Also learn from it? Patterns shared with other noise domains are proposed as exclusions under Blacklist.');
+    try {
+        const data = await apiCall('/api/noise', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ domain, learn })
+        });
+        const proposals = data.proposals ? data.proposals.length : 0;
+        showSuccessMessage('Marked as noise' + (proposals ? ', ' + proposals + ' exclusion pattern(s) proposed' : ''));
+        loadDomains();
+    } catch (err) {
+        console.error('Failed to mark noise:', err);
+        alert('Failed to mark noise: ' + err.message);
+    }
+}
+
+async function loadNoiseProposals() {
+    try {
+        const data = await apiCall('/api/noise/proposals');
+        const tbody = document.getElementById('noiseProposalsTable');
+        if (!data.proposals || data.proposals.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="5" style="text-align: center; padding: 20px;">No proposed patterns</td></tr>';
+            return;
+        }
+        tbody.innerHTML = data.proposals.map(p => '<tr><td>' + escapeHtml(p.pattern) + '</td><td>' + escapeHtml(p.target) + '</td><td title="' + escapeHtml(p.examples.join(', ')) + '">' + p.support + ' marked</td><td>' + p.matches + '</td><td><div class="action-buttons"><button class="action-btn action-btn-primary" data-action="approve">Approve</button><button class="action-btn action-btn-danger" data-action="reject">Reject</button></div></td></tr>').join('');
+        tbody.querySelectorAll('tr').forEach((row, i) => {
+            const p = data.proposals[i];
+            row.querySelectorAll('button').forEach(btn => {
+                btn.addEventListener('click', () => reviewNoiseProposal(p, btn.getAttribute('data-action')));
+            });
+        });
+    } catch (err) {
+        console.error('Failed to load noise proposals:', err);
+    }
+}
+
+async function reviewNoiseProposal(p, action) {
+    if (action === 'approve' && !confirm('Exclude ' + p.pattern + ' for ' + p.target + '? ' + p.matches + ' tracked domain(s) match.')) return;
+    try {
+        await apiCall('/api/noise/proposals', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ pattern: p.pattern, target: p.target, action })
+        });
+        showSuccessMessage(action === 'approve' ? 'Exclusion added' : 'Proposal rejected');
+        loadNoiseProposals();
+    } catch (err) {
+        console.error('Failed to review proposal:', err);
+        alert('Failed to review proposal: ' + err.message);
+    }
+}
+
+async function loadIssues() {
+    try {
+        const data = await apiCall('/api/errors');
+        const summaryBody = document.getElementById('issuesSummaryTable');
+        const tbody = document.getElementById('issuesTable');
+        if (!data.summary || data.summary.length === 0) {
+            summaryBody.innerHTML = '<tr><td colspan="4" style="text-align: center; padding: 20px;">No issues recorded</td></tr>';
+        } else {
+            summaryBody.innerHTML = data.summary.map(s => '<tr><td><span class="badge badge-danger">' + s.category + '</span></td><td>' + s.count + '</td><td>' + new Date(s.last_seen).toLocaleString() + '</td><td>' + escapeHtml(s.last_message) + '</td></tr>').join('');
+        }
+        if (!data.errors || data.errors.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="3" style="text-align: center; padding: 20px;">No recent errors</td></tr>';
+            return;
+        }
+        tbody.innerHTML = data.errors.map(e => '<tr><td>' + new Date(e.time).toLocaleString() + '</td><td>' + e.category + '</td><td>' + escapeHtml(e.message) + '</td></tr>').join('');
+    } catch (err) {
+        console.error('Failed to load issues:', err);
+    }
+}
+
+function exportDomains(format) {
+    openWithToken('/api/domains/export?format=' + encodeURIComponent(format), true);
+}
+
+// Links to files send the token in the Authorization header like every other request,
+// so they are fetched here and opened from a blob URL. data-download saves instead.
+document.addEventListener('click', e => {
+    const link = e.target.closest('a[data-href]');
+    if (!link) return;
+    e.preventDefault();
+    openWithToken(link.getAttribute('data-href'), link.hasAttribute('data-download'));
+});
+
+async function openWithToken(url, download) {
+    // Opened before the fetch, while the click still allows a popup
+    const win = download ? null : window.open('', '_blank');
+    try {
+        const response = await fetch(url, {headers: {'Authorization': authToken}});
+        if (response.status === 401) {
+            logout();
+            throw new Error('Unauthorized');
+        }
+        if (!response.ok) throw new Error('HTTP ' + response.status);
+        const blobURL = URL.createObjectURL(await response.blob());
+        setTimeout(() => URL.revokeObjectURL(blobURL), 60000);
+        if (win) {
+            win.location = blobURL;
+            return;
+        }
+        const name = /filename="?([^";]+)"?/.exec(response.headers.get('Content-Disposition') || '');
+        const a = document.createElement('a');
+        a.href = blobURL;
+        a.download = name ? name[1] : url.split('?')[0].split('/').pop();
+        document.body.appendChild(a);
+        a.click();
+        a.remove();
+    } catch (err) {
+        if (win) win.close();
+        console.error('Failed to open ' + url + ':', err);
+    }
+}
+
+async function clearIssues() {
+    if (!confirm('Clear all recorded issues?')) return;
+    try {
+        await apiCall('/api/errors', {method: 'DELETE'});
+        showSuccessMessage('Issues cleared');
+        loadIssues();
+    } catch (err) {
+        console.error('Failed to clear issues:', err);
+    }
+}
+
+// Time-to-notify is usually seconds but can reach hours when CT logs lag or sends are retried
+function formatLatency(seconds) {
+    if (seconds < 60) return seconds.toFixed(1) + 's';
+    if (seconds < 3600) return (seconds / 60).toFixed(1) + 'm';
+    return (seconds / 3600).toFixed(1) + 'h';
+}
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text || '';
+    // innerHTML leaves quotes alone, escape them too for use in attributes
+    return div.innerHTML.replace(/"/g, '&quot;').replace(/'/g, '&#39;');
+}
+
 async function removeFromBlacklist(domain) {
     if (!confirm('Unblacklist domain: ' + domain + '?')) return;
     try {
@@ -1136,6 +3019,78 @@ async function loadConfig() {
     }
 }
 
+const dedupFields = {dedupCooldown: 'cooldown_hours', dedupMaxPerDay: 'max_per_day', dedupHitsPerDay: 'blacklist_hits_per_day', dedupDays: 'blacklist_days'};
+
+async function loadDedup() {
+    try {
+        const policy = await apiCall('/api/dedup');
+        Object.keys(dedupFields).forEach(id => document.getElementById(id).value = policy[dedupFields[id]]);
+    } catch (err) {
+        console.error('Failed to load notification policy:', err);
+    }
+}
+
+async function saveDedup(e) {
+    e.preventDefault();
+    const policy = {};
+    Object.keys(dedupFields).forEach(id => policy[dedupFields[id]] = parseInt(document.getElementById(id).value, 10));
+    try {
+        await apiCall('/api/dedup', {method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify(policy)});
+        showSuccessMessage('Notification policy saved', 'dedupSaved');
+    } catch (err) {
+        alert('Failed to save notification policy: ' + err.message);
+    }
+}
+
+async function loadAPIKeys() {
+    try {
+        const keys = await apiCall('/api/keys');
+        const tbody = document.getElementById('apiKeysTable');
+        if (!keys || keys.length === 0) {
+            tbody.innerHTML = '<tr><td colspan="5" style="text-align: center; padding: 20px;">No API keys</td></tr>';
+            return;
+        }
+        tbody.innerHTML = keys.map(k => '<tr><td>' + escapeHtml(k.name) + '</td><td>' + escapeHtml(k.hint) + '...</td><td>' + new Date(k.created_at).toLocaleString() + '</td><td>' + (k.last_used && !k.last_used.startsWith('0001') ? new Date(k.last_used).toLocaleString() : 'Never') + '</td><td><div class="action-buttons"><button class="action-btn action-btn-danger" onclick="revokeAPIKey(\'' + k.id + '\')">Revoke</button></div></td></tr>').join('');
+    } catch (err) {
+        console.error('Failed to load API keys:', err);
+    }
+}
+
+// The key is only shown once, so it stays on screen until the next one is created
+async function createAPIKey(e) {
+    e.preventDefault();
+    const input = document.getElementById('apiKeyName');
+    try {
+        const data = await apiCall('/api/keys', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name: input.value.trim() })
+        });
+        const msg = document.getElementById('apiKeyCreated');
+        msg.textContent = 'Key for ' + data.name + ' (copy it now, it will not be shown again): ' + data.key;
+        msg.style.wordBreak = 'break-all';
+        msg.style.display = 'block';
+        input.value = '';
+        loadAPIKeys();
+    } catch (err) {
+        console.error('Failed to create API key:', err);
+        alert('Failed to create API key: ' + err.message);
+    }
+}
+
+async function revokeAPIKey(id) {
+    if (!confirm('Revoke this API key? Scripts using it will stop working.')) return;
+    try {
+        await apiCall('/api/keys?id=' + encodeURIComponent(id), {method: 'DELETE'});
+        document.getElementById('apiKeyCreated').style.display = 'none';
+        showSuccessMessage('API key revoked');
+        loadAPIKeys();
+    } catch (err) {
+        console.error('Failed to revoke API key:', err);
+        alert('Failed to revoke API key: ' + err.message);
+    }
+}
+
 function updateTopDomainsChart(domains) {
     const top10 = domains.slice(0, 10);
     const labels = top10.map(d => d.domain);
@@ -1216,6 +3171,15 @@ function updateTopTargetsChart(targetsData) {
     }
 }
 
+function updateSourcesTable(sources) {
+    const tbody = document.getElementById('sourcesTable');
+    if (sources.length === 0) {
+        tbody.innerHTML = '<tr><td colspan="6" style="text-align: center; padding: 20px;">No domains tracked yet</td></tr>';
+        return;
+    }
+    tbody.innerHTML = sources.map(s => '<tr><td>' + escapeHtml(s.source) + '</td><td>' + s.domains + '</td><td>' + s.last_7d + '</td><td>' + s.live + '</td><td>' + s.high_risk + '</td><td>' + s.findings + '</td></tr>').join('');
+}
+
 function showLogin() {
     document.getElementById('loginScreen').style.display = 'flex';
     document.getElementById('dashboardScreen').style.display = 'none';
@@ -1226,8 +3190,8 @@ function showDashboard() {
     document.getElementById('dashboardScreen').style.display = 'block';
 }
 
-function showSuccessMessage(message) {
-    const msg = document.getElementById('targetSuccess');
+function showSuccessMessage(message, id) {
+    const msg = document.getElementById(id || 'targetSuccess');
     msg.textContent = message;
     msg.style.display = 'block';
     setTimeout(() => msg.style.display = 'none', 3000);
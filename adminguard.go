@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTrackedClients bounds the per-IP state kept for login failures and denied requests
+const maxTrackedClients = 10000
+
+// loginAttempts counts recent failed logins from one client
+type loginAttempts struct {
+	failures    int
+	first       time.Time // First failure in the current window
+	lockedUntil time.Time
+}
+
+// loginLimiter locks out clients after repeated failed logins
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempts
+}
+
+var logins = &loginLimiter{attempts: make(map[string]*loginAttempts)}
+
+// Locked returns how much longer a client is locked out, or zero
+func (l *loginLimiter) Locked(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if a, exists := l.attempts[ip]; exists {
+		if remaining := time.Until(a.lockedUntil); remaining > 0 {
+			return remaining
+		}
+	}
+	return 0
+}
+
+// Fail records a failed login and reports whether it started a lockout. Failures
+// older than the lockout period no longer count.
+func (l *loginLimiter) Fail(ip string, maxFailures int, lockout time.Duration) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	a, exists := l.attempts[ip]
+	if !exists || now.Sub(a.first) > lockout {
+		if len(l.attempts) >= maxTrackedClients {
+			l.prune(now, lockout)
+		}
+		a = &loginAttempts{first: now}
+		l.attempts[ip] = a
+	}
+	a.failures++
+	if a.failures < maxFailures {
+		return false
+	}
+	a.failures = 0
+	a.first = now
+	a.lockedUntil = now.Add(lockout)
+	return true
+}
+
+// Reset forgets a client's failures after a successful login
+func (l *loginLimiter) Reset(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, ip)
+}
+
+// prune drops clients that are neither locked out nor inside a failure window.
+// Caller must hold l.mu.
+func (l *loginLimiter) prune(now time.Time, lockout time.Duration) {
+	for ip, a := range l.attempts {
+		if now.After(a.lockedUntil) && now.Sub(a.first) > lockout {
+			delete(l.attempts, ip)
+		}
+	}
+}
+
+// parseAllowlist parses CIDRs, accepting bare addresses as single hosts
+func parseAllowlist(entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed_cidrs entry %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed_cidrs entry %q: %w", entry, err)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// clientIP returns the address a request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// deniedAudited throttles audit entries for denied clients to one per hour each
+var deniedMutex sync.Mutex
+var deniedAudited = make(map[string]time.Time)
+
+// withAllowlist rejects requests from outside the allowed networks. With no
+// networks configured every client is allowed.
+func withAllowlist(allowed []*net.IPNet, next http.Handler) http.Handler {
+	if len(allowed) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if parsed := net.ParseIP(ip); parsed != nil {
+			for _, ipnet := range allowed {
+				if ipnet.Contains(parsed) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		deniedMutex.Lock()
+		audit := time.Since(deniedAudited[ip]) > time.Hour
+		if audit {
+			if len(deniedAudited) >= maxTrackedClients {
+				deniedAudited = make(map[string]time.Time)
+			}
+			deniedAudited[ip] = time.Now()
+		}
+		deniedMutex.Unlock()
+		if audit {
+			logger.Warn("admin request from address outside allowlist", "ip", ip, "path", r.URL.Path)
+			auditSecurityEvent("admin_ip_denied", ip, nil)
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+}
+
+// handleLockout answers a login from a locked out client and reports whether it did
+func handleLockout(w http.ResponseWriter, ip string) bool {
+	remaining := logins.Locked(ip)
+	if remaining == 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(remaining.Seconds())+1))
+	http.Error(w, "too many failed logins, try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// auditSecurityEvent records an admin panel security event in the audit log
+func auditSecurityEvent(action, ip string, details map[string]int) {
+	if err := recordAudit(AuditEntry{
+		Action:  action,
+		Actor:   "admin:" + ip,
+		Details: details,
+	}); err != nil {
+		logger.Error("failed to write audit log", "action", action, "error", err)
+	}
+}
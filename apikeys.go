@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyPrefix makes crtmon keys recognizable in scripts and secret scanners
+const apiKeyPrefix = "crtmon_"
+
+// APIKey is a long-lived credential for scripts. Only a hash of the key is stored.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hint      string    `json:"hint"` // First characters of the key, to tell keys apart
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
+}
+
+// APIKeyStore persists API keys in the config directory
+type APIKeyStore struct {
+	mu       sync.Mutex
+	keys     map[string]*APIKey // ID -> key
+	filePath string
+	dirty    bool // LastUsed changed since the last save
+}
+
+var apiKeys *APIKeyStore
+
+// loadAPIKeys loads stored API keys and periodically saves last-used times
+func loadAPIKeys(path string) error {
+	s := &APIKeyStore{keys: make(map[string]*APIKey), filePath: path}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &s.keys); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	apiKeys = s
+
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.mu.Lock()
+			if s.dirty {
+				if err := s.save(); err != nil {
+					logger.Error("failed to save api keys", "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Create generates a key and returns it in plain text; it can't be shown again
+func (s *APIKeyStore) Create(name string) (*APIKey, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	plain := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := &APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hint:      plain[:len(apiKeyPrefix)+6],
+		Hash:      hashAPIKey(plain),
+		CreatedAt: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.ID)
+		return nil, "", err
+	}
+	// Keys are secrets like any other; keep them out of logs
+	addSecrets(plain)
+	return key, plain, nil
+}
+
+// Revoke deletes a key
+func (s *APIKeyStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.keys[id]; !exists {
+		return fmt.Errorf("api key %s not found", id)
+	}
+	delete(s.keys, id)
+	return s.save()
+}
+
+// List returns keys, oldest first, without their hashes
+func (s *APIKeyStore) List() []APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		copy := *k
+		copy.Hash = ""
+		result = append(result, copy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Verify reports whether a presented key matches a stored one and records its use
+func (s *APIKeyStore) Verify(plain string) bool {
+	if s == nil || !strings.HasPrefix(plain, apiKeyPrefix) {
+		return false
+	}
+	hash := hashAPIKey(plain)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			k.LastUsed = time.Now()
+			s.dirty = true
+			return true
+		}
+	}
+	return false
+}
+
+// save writes keys to disk. Caller must hold s.mu.
+func (s *APIKeyStore) save() error {
+	data, err := json.MarshalIndent(s.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.dirty = false
+	return os.WriteFile(s.filePath, data, 0600)
+}
+
+// hashAPIKey returns the stored form of a key. Keys are random, so a plain hash is enough.
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
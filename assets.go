@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetConfig holds asset lifecycle settings
+type AssetConfig struct {
+	RetireDays int      `yaml:"retire_days"` // Days offline, or never live, before an asset is retired, default 30
+	Notify     []string `yaml:"notify"`      // Transitions alerted: offline, online and/or retired
+	Webhook    string   `yaml:"webhook"`     // Defaults to the main webhook
+}
+
+// Asset lifecycle states
+const (
+	assetNew     = "new"     // Seen in a certificate, never resolved or answered a probe
+	assetLive    = "live"    // Resolved or answered a probe at the last check
+	assetOffline = "offline" // Live before, but stopped resolving
+	assetRetired = "retired" // Offline, or never live, for retire_days
+)
+
+// Transitions that can be alerted
+const (
+	assetNotifyOffline = "offline"
+	assetNotifyOnline  = "online"
+	assetNotifyRetired = "retired"
+)
+
+// assetOfflineChecks is how many checks in a row a live asset must fail to resolve
+// before it is offline, so one failed lookup doesn't flap it
+const assetOfflineChecks = 2
+
+// AssetTransition is a change of an asset's lifecycle state
+type AssetTransition struct {
+	Domain   string
+	From, To string
+	LastLive time.Time
+	At       time.Time
+}
+
+var assetConfig *AssetConfig
+var assetMutex sync.Mutex
+
+// assetPending holds transitions waiting for the next alert
+var assetPending []AssetTransition
+
+// SetAssetConfig sets the asset lifecycle configuration
+func SetAssetConfig(cfg *AssetConfig) {
+	assetMutex.Lock()
+	defer assetMutex.Unlock()
+	if cfg.RetireDays <= 0 {
+		cfg.RetireDays = 30
+	}
+	for i, n := range cfg.Notify {
+		cfg.Notify[i] = strings.ToLower(strings.TrimSpace(n))
+	}
+	cfg.Webhook = strings.TrimSpace(cfg.Webhook)
+	assetConfig = cfg
+}
+
+// GetAssetConfig returns the asset lifecycle configuration
+func GetAssetConfig() *AssetConfig {
+	assetMutex.Lock()
+	defer assetMutex.Unlock()
+	if assetConfig == nil {
+		return &AssetConfig{RetireDays: 30}
+	}
+	return assetConfig
+}
+
+// initAssetStates gives entries tracked before asset states existed a state from
+// what is known about them, without alerting. Caller must hold dt.mu or own dt.
+func (dt *DomainTracker) initAssetStates() {
+	for _, entry := range dt.domains {
+		if entry.AssetState != "" {
+			continue
+		}
+		entry.AssetState, entry.StateChanged = assetNew, entry.FirstSeen
+		if entry.Resolved || entry.Probe != nil {
+			entry.AssetState, entry.LastLive = assetLive, entry.LastSeen
+		}
+	}
+}
+
+// observeLiveness moves an asset between states after a resolution or probe. Caller
+// must hold dt.mu.
+func (dt *DomainTracker) observeLiveness(entry *DomainEntry, live bool) {
+	now := time.Now()
+	if entry.AssetState == "" {
+		entry.AssetState, entry.StateChanged = assetNew, now
+	}
+	if live {
+		entry.FailedChecks = 0
+		if entry.AssetState != assetLive {
+			setAssetState(entry, assetLive, now)
+		}
+		entry.LastLive = now
+		return
+	}
+	entry.FailedChecks++
+	if entry.AssetState == assetLive && entry.FailedChecks >= assetOfflineChecks {
+		setAssetState(entry, assetOffline, now)
+	}
+}
+
+// RetireAssets retires assets offline, or never live, for longer than retire_days
+func (dt *DomainTracker) RetireAssets() int {
+	retireAfter := time.Duration(GetAssetConfig().RetireDays) * 24 * time.Hour
+	now := time.Now()
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	retired := 0
+	for _, entry := range dt.domains {
+		switch {
+		case entry.AssetState == assetOffline && now.Sub(entry.LastLive) > retireAfter:
+		case entry.AssetState == assetNew && now.Sub(entry.FirstSeen) > retireAfter:
+		default:
+			continue
+		}
+		setAssetState(entry, assetRetired, now)
+		retired++
+	}
+	if retired > 0 {
+		dt.save()
+	}
+	return retired
+}
+
+// setAssetState changes an asset's state and queues an alert for the transition when
+// one is configured. Going live for the first time is the new domain notification.
+func setAssetState(entry *DomainEntry, state string, now time.Time) {
+	t := AssetTransition{Domain: entry.Domain, From: entry.AssetState, To: state, LastLive: entry.LastLive, At: now}
+	entry.AssetState, entry.StateChanged = state, now
+	logger.Debug("asset state changed", "domain", t.Domain, "from", t.From, "to", t.To)
+
+	var kind string
+	switch {
+	case state == assetOffline:
+		kind = assetNotifyOffline
+	case state == assetLive && t.From != assetNew:
+		kind = assetNotifyOnline
+	case state == assetRetired && !entry.LastLive.IsZero():
+		// Names that never went live are retired quietly
+		kind = assetNotifyRetired
+	default:
+		return
+	}
+	if entry.Blacklisted || isSnoozed(entry) || !assetNotifies(kind) {
+		return
+	}
+
+	assetMutex.Lock()
+	assetPending = append(assetPending, t)
+	assetMutex.Unlock()
+}
+
+// assetNotifies reports whether a kind of transition is alerted
+func assetNotifies(kind string) bool {
+	for _, n := range GetAssetConfig().Notify {
+		if n == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// StartAssetScheduler retires assets once an hour and sends queued transition alerts
+// every few minutes, so a rescan sweep makes one alert rather than many
+func StartAssetScheduler() {
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute)
+		defer ticker.Stop()
+		lastRetire := time.Time{}
+		for range ticker.C {
+			if time.Since(lastRetire) >= time.Hour {
+				if n := GetDomainTracker().RetireAssets(); n > 0 {
+					logger.Info("assets retired", "count", n)
+				}
+				lastRetire = time.Now()
+			}
+			flushAssetAlerts()
+		}
+	}()
+}
+
+// dropAssetTransitions removes queued alerts of the given domains
+func dropAssetTransitions(domains map[string]bool) {
+	assetMutex.Lock()
+	defer assetMutex.Unlock()
+	pending := assetPending[:0]
+	for _, t := range assetPending {
+		if !domains[t.Domain] {
+			pending = append(pending, t)
+		}
+	}
+	assetPending = pending
+}
+
+// flushAssetAlerts sends the queued transitions, one alert per kind
+func flushAssetAlerts() {
+	assetMutex.Lock()
+	pending := assetPending
+	assetPending = nil
+	assetMutex.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	byState := make(map[string][]AssetTransition)
+	for _, t := range pending {
+		byState[t.To] = append(byState[t.To], t)
+	}
+	for _, state := range []string{assetOffline, assetLive, assetRetired} {
+		if len(byState[state]) > 0 {
+			sendAssetAlert(state, byState[state])
+		}
+	}
+}
+
+// sendAssetAlert sends transitions into one state to the asset webhook
+func sendAssetAlert(state string, transitions []AssetTransition) {
+	if isDryRun() {
+		logger.Info("dry run: would send asset alert", "state", state, "count", len(transitions))
+		return
+	}
+	webhook := GetAssetConfig().Webhook
+	if !alertConfigured(webhook) {
+		logger.Warn("asset state changed but no webhook configured", "state", state, "count", len(transitions))
+		return
+	}
+
+	var lines []string
+	for _, t := range transitions {
+		lastLive := "never"
+		if !t.LastLive.IsZero() {
+			lastLive = t.LastLive.Format("2006-01-02")
+		}
+		lines = append(lines, fmt.Sprintf("%s  (was %s, last live %s)", t.Domain, t.From, lastLive))
+	}
+	for _, payload := range buildAssetPayloads(state, lines) {
+		if err := sendAlert(webhook, payload); err != nil {
+			logger.Error("failed to send asset alert", "state", state, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("asset %s alert: %v", state, err))
+			return
+		}
+	}
+	logger.Info("asset alert sent", "state", state, "count", len(transitions))
+}
+
+// buildAssetPayloads builds Discord embeds listing assets that changed state
+func buildAssetPayloads(state string, lines []string) []map[string]interface{} {
+	title, color := "Assets went offline", 15105570 // Orange
+	switch state {
+	case assetLive:
+		title, color = "Assets back online", 3066993 // Green
+	case assetRetired:
+		title, color = "Assets retired", 8421504 // Grey
+	}
+
+	chunks := chunkByLength(lines, maxBatchChars, resultLineLength)
+	payloads := make([]map[string]interface{}, 0, len(chunks))
+	for i, chunk := range chunks {
+		chunkTitle := fmt.Sprintf("%s  [%d]", title, len(lines))
+		if len(chunks) > 1 {
+			chunkTitle += fmt.Sprintf(" (Part %d/%d)", i+1, len(chunks))
+		}
+		payloads = append(payloads, map[string]interface{}{
+			"tts": false,
+			"embeds": []map[string]interface{}{
+				{
+					"title":       chunkTitle,
+					"description": "```\n" + strings.Join(chunk, "\n") + "\n```",
+					"color":       color,
+					"timestamp":   time.Now().Format(time.RFC3339),
+				},
+			},
+		})
+	}
+	return payloads
+}
+
+// Asset is a tracked domain in the inventory
+type Asset struct {
+	Domain       string    `json:"domain"`
+	State        string    `json:"state"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	LastLive     time.Time `json:"last_live,omitempty"`
+	StateChanged time.Time `json:"state_changed"`
+	Addrs        []string  `json:"addrs,omitempty"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	RiskScore    int       `json:"risk_score"`
+}
+
+// TargetAssets is the inventory of one target
+type TargetAssets struct {
+	Target string         `json:"target"`
+	Counts map[string]int `json:"counts"` // State -> assets
+	Assets []Asset        `json:"assets"`
+}
+
+// BuildAssetInventory groups tracked domains by target, optionally narrowed to one
+// target, one state or a program's targets. Wildcards and blacklisted domains are
+// not assets. A domain under several targets is listed under each.
+func BuildAssetInventory(target, state string, program map[string]bool) []TargetAssets {
+	groups := make(map[string]*TargetAssets)
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		if IsWildcardDomain(entry.Domain) || entry.Blacklisted || !inProgram(entry, program) {
+			continue
+		}
+		asset := Asset{
+			Domain:       entry.Domain,
+			State:        orDefault(entry.AssetState, assetNew),
+			FirstSeen:    entry.FirstSeen,
+			LastSeen:     entry.LastSeen,
+			LastLive:     entry.LastLive,
+			StateChanged: entry.StateChanged,
+			Addrs:        entry.Addrs,
+			StatusCode:   entry.HttpStatusCode,
+			RiskScore:    entry.RiskScore,
+		}
+		if entry.Probe != nil {
+			asset.Title = entry.Probe.Title
+		}
+
+		for _, t := range matchingTargets(entry) {
+			if target != "" && t != target {
+				continue
+			}
+			group := groups[t]
+			if group == nil {
+				group = &TargetAssets{Target: t, Counts: map[string]int{assetNew: 0, assetLive: 0, assetOffline: 0, assetRetired: 0}}
+				groups[t] = group
+			}
+			group.Counts[asset.State]++
+			if state == "" || asset.State == state {
+				group.Assets = append(group.Assets, asset)
+			}
+		}
+	}
+
+	result := make([]TargetAssets, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group.Assets, func(i, j int) bool { return group.Assets[i].Domain < group.Assets[j].Domain })
+		if group.Assets == nil {
+			group.Assets = []Asset{}
+		}
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result
+}
+
+// handleAssets returns the asset inventory grouped by target, filtered by target,
+// state and program
+func (as *AdminServer) handleAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	members, ok := requestProgram(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	state := strings.ToLower(q.Get("state"))
+	switch state {
+	case "", assetNew, assetLive, assetOffline, assetRetired:
+	default:
+		http.Error(w, "state must be new, live, offline or retired", http.StatusBadRequest)
+		return
+	}
+
+	inventory := BuildAssetInventory(strings.ToLower(strings.TrimSpace(q.Get("target"))), state, members)
+	count := 0
+	for _, group := range inventory {
+		count += len(group.Assets)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AssetInventory{Targets: inventory, Count: count})
+}
+
+// checkAssetConfig reports an assets section that cannot work as configured
+func checkAssetConfig(cfg AssetConfig) []doctorCheck {
+	var checks []doctorCheck
+	for _, n := range cfg.Notify {
+		switch strings.ToLower(strings.TrimSpace(n)) {
+		case assetNotifyOffline, assetNotifyOnline, assetNotifyRetired:
+		default:
+			checks = append(checks, doctorCheck{"assets", doctorFail, "notify: unknown transition " + n + ", use offline, online or retired"})
+		}
+	}
+	return checks
+}
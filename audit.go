@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditLogFile is the hash-chained record of destructive operations, in the config directory
+const auditLogFile = "audit.jsonl"
+
+// AuditEntry is one administrative action, written as a chained JSON line
+type AuditEntry struct {
+	Time     time.Time      `json:"time"`
+	Action   string         `json:"action"`
+	Target   string         `json:"target,omitempty"`
+	Actor    string         `json:"actor"` // "cli" or the admin panel client address
+	Details  map[string]int `json:"details,omitempty"`
+	PrevHash string         `json:"prev_hash"`
+	Hash     string         `json:"hash"` // Must stay last, see chainLine
+}
+
+var auditMutex sync.Mutex
+
+// recordAudit appends an entry to the audit log, chained to the previous entry.
+// It is never rotated, so the whole history can be verified with verify-log -audit.
+func recordAudit(entry AuditEntry) error {
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, auditLogFile)
+
+	entry.Time = time.Now()
+	entry.PrevHash = lastChainHash(path)
+	entry.Hash = ""
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line, _, err := chainLine(body)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Events published to the message bus, each on <subject>.<event>
+const (
+	busDiscovery    = "discovery"
+	busScanFinished = "scan_finished"
+)
+
+// busTimeout bounds connecting to the bus and each publish
+const busTimeout = 10 * time.Second
+
+// BusConfig holds settings for publishing events to NATS or Redis
+type BusConfig struct {
+	// nats://[user:pass@|token@]host:4222, tls:// for NATS over TLS,
+	// redis://[user:pass@]host:6379 or rediss:// for Redis over TLS
+	URL       string   `yaml:"url"`
+	Subject   string   `yaml:"subject"`    // Subject or channel prefix, default "crtmon"
+	Events    []string `yaml:"events"`     // discovery and scan_finished, default both
+	QueueSize int      `yaml:"queue_size"` // Events held while the bus is unreachable, default 1000
+	CAFile    string   `yaml:"ca_file"`    // CA bundle for tls:// and rediss://, default system roots
+}
+
+// BusMessage is the JSON published for an event
+type BusMessage struct {
+	Type      string     `json:"type"` // discovery or scan_finished
+	Time      time.Time  `json:"time"`
+	Discovery *Discovery `json:"discovery,omitempty"`
+	Scan      *Job       `json:"scan,omitempty"`
+}
+
+// busItem is an encoded message waiting to be published
+type busItem struct {
+	subject string
+	payload []byte
+}
+
+var busConfig *BusConfig
+var busMutex sync.Mutex
+var busQueue chan busItem
+var busStart sync.Once
+var busDropped atomic.Int64 // Events dropped since the bus was last reachable
+
+// SetBusConfig sets the message bus configuration, starting the publisher the first
+// time a URL is set
+func SetBusConfig(cfg *BusConfig) {
+	busMutex.Lock()
+	defer busMutex.Unlock()
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	if cfg.Subject == "" {
+		cfg.Subject = "crtmon"
+	}
+	if len(cfg.Events) == 0 {
+		cfg.Events = []string{busDiscovery, busScanFinished}
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	busConfig = cfg
+
+	if cfg.URL != "" {
+		busStart.Do(func() {
+			busQueue = make(chan busItem, cfg.QueueSize)
+			go runBusPublisher()
+		})
+	}
+}
+
+// GetBusConfig returns the message bus configuration
+func GetBusConfig() *BusConfig {
+	busMutex.Lock()
+	defer busMutex.Unlock()
+	return busConfig
+}
+
+// busEnabled reports whether an event is published, and the configuration to use
+func busEnabled(event string) (*BusConfig, bool) {
+	cfg := GetBusConfig()
+	if cfg == nil || cfg.URL == "" || busQueue == nil || isDryRun() {
+		return nil, false
+	}
+	for _, e := range cfg.Events {
+		if strings.EqualFold(strings.TrimSpace(e), event) {
+			return cfg, true
+		}
+	}
+	return nil, false
+}
+
+// publishBus queues a message without waiting for the bus. When the queue is full
+// the message is dropped.
+func publishBus(cfg *BusConfig, msg BusMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		logger.Error("failed to encode message bus event", "type", msg.Type, "error", err)
+		return
+	}
+	item := busItem{subject: cfg.Subject + "." + msg.Type, payload: payload}
+	select {
+	case busQueue <- item:
+	default:
+		if busDropped.Add(1) == 1 {
+			logger.Warn("message bus queue full, dropping events until it is reachable", "queue_size", cap(busQueue))
+		}
+	}
+}
+
+// publishBusDiscoveries publishes each new discovery of a certificate
+func publishBusDiscoveries(entry CertEntry, decisions []EntryDecision) {
+	cfg, ok := busEnabled(busDiscovery)
+	if !ok {
+		return
+	}
+	for _, d := range newDiscoveries(entry, decisions) {
+		publishBus(cfg, BusMessage{Type: busDiscovery, Time: d.Time, Discovery: &d})
+	}
+}
+
+// publishBusScan publishes a finished, failed, timed out or cancelled scan
+func publishBusScan(job Job) {
+	cfg, ok := busEnabled(busScanFinished)
+	if !ok {
+		return
+	}
+	publishBus(cfg, BusMessage{Type: busScanFinished, Time: job.FinishedAt, Scan: &job})
+}
+
+// runBusPublisher publishes queued messages in order, reconnecting with backoff. A
+// message is retried until it is published, or until the URL is removed.
+func runBusPublisher() {
+	var conn busConn
+	var connURL string
+	wait := time.Second
+
+	for item := range busQueue {
+		for {
+			cfg := GetBusConfig()
+			if cfg == nil || cfg.URL == "" {
+				break
+			}
+			if conn == nil || connURL != cfg.URL {
+				if conn != nil {
+					conn.Close()
+				}
+				var err error
+				conn, err = dialBus(cfg)
+				if err != nil {
+					logger.Warn("failed to connect to message bus", "retry_in", wait, "error", err)
+					RecordError(errCategoryBus, err.Error())
+					time.Sleep(wait)
+					wait = min(wait*2, time.Minute)
+					continue
+				}
+				connURL = cfg.URL
+				wait = time.Second
+				logger.Info("connected to message bus", "scheme", busScheme(cfg.URL), "subject", cfg.Subject)
+			}
+
+			if err := conn.publish(item.subject, item.payload); err != nil {
+				logger.Warn("failed to publish to message bus, reconnecting", "subject", item.subject, "error", err)
+				RecordError(errCategoryBus, err.Error())
+				conn.Close()
+				conn = nil
+				continue
+			}
+			if dropped := busDropped.Swap(0); dropped > 0 {
+				logger.Warn("message bus reachable again", "dropped", dropped)
+			}
+			break
+		}
+	}
+}
+
+// busConn publishes to one connected bus
+type busConn interface {
+	publish(subject string, payload []byte) error
+	Close() error
+}
+
+// busScheme returns the scheme of a bus URL, without parsing errors
+func busScheme(raw string) string {
+	scheme, _, _ := strings.Cut(raw, "://")
+	return strings.ToLower(scheme)
+}
+
+// dialBus connects to the bus named by the configured URL
+func dialBus(cfg *BusConfig) (busConn, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message bus url: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("message bus url has no host")
+	}
+
+	tlsConfig := &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := loadCAFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("message bus ca_file: %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "nats":
+		return dialNATS(u, tlsConfig, false)
+	case "tls":
+		return dialNATS(u, tlsConfig, true)
+	case "redis":
+		return dialRedis(u, nil)
+	case "rediss":
+		return dialRedis(u, tlsConfig)
+	}
+	return nil, fmt.Errorf("unsupported message bus scheme %q, use nats, tls, redis or rediss", u.Scheme)
+}
+
+// busAddress returns host:port of a bus URL, with the protocol's default port
+func busAddress(u *url.URL, defaultPort string) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// natsConn publishes with the NATS client protocol
+type natsConn struct {
+	conn net.Conn
+	mu   sync.Mutex // Serializes writes: publishes and PONGs
+	err  chan error // The first error the server or the reader reported
+}
+
+// natsInfo is the part of the server's INFO line crtmon uses
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+}
+
+// dialNATS connects and sends CONNECT, upgrading to TLS after INFO when asked to or
+// when the server requires it
+func dialNATS(u *url.URL, tlsConfig *tls.Config, secure bool) (*natsConn, error) {
+	conn, err := net.DialTimeout("tcp", busAddress(u, "4222"), busTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(busTimeout))
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("not a nats server: %q", strings.TrimSpace(line))
+	}
+	var info natsInfo
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+
+	if secure || info.TLSRequired {
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+		r = bufio.NewReader(conn)
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "crtmon",
+		"lang":     "go",
+		"version":  version,
+		"protocol": 1,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			connect["user"] = u.User.Username()
+			connect["pass"] = pass
+		} else {
+			connect["auth_token"] = u.User.Username()
+		}
+	}
+	data, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// PONG confirms CONNECT was accepted
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, fmt.Errorf("nats: %s", strings.Trim(strings.TrimPrefix(line, "-ERR"), " '"))
+		}
+		if line == "PONG" {
+			break
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	nc := &natsConn{conn: conn, err: make(chan error, 1)}
+	go nc.read(r)
+	return nc, nil
+}
+
+// read answers the server's PINGs and records errors it sends
+func (c *natsConn) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PING":
+			c.mu.Lock()
+			c.conn.SetWriteDeadline(time.Now().Add(busTimeout))
+			_, err = c.conn.Write([]byte("PONG\r\n"))
+			c.mu.Unlock()
+			if err != nil {
+				c.fail(err)
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.fail(fmt.Errorf("nats: %s", strings.Trim(strings.TrimPrefix(line, "-ERR"), " '")))
+		}
+	}
+}
+
+// fail keeps the first error, for the next publish to return
+func (c *natsConn) fail(err error) {
+	select {
+	case c.err <- err:
+	default:
+	}
+}
+
+func (c *natsConn) publish(subject string, payload []byte) error {
+	select {
+	case err := <-c.err:
+		return err
+	default:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(busTimeout))
+	msg := make([]byte, 0, len(subject)+len(payload)+32)
+	msg = fmt.Appendf(msg, "PUB %s %d\r\n", subject, len(payload))
+	msg = append(msg, payload...)
+	msg = append(msg, "\r\n"...)
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+func (c *natsConn) Close() error {
+	return c.conn.Close()
+}
+
+// redisConn publishes with RESP commands, one reply per command
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialRedis connects and authenticates, over TLS when tlsConfig is set
+func dialRedis(u *url.URL, tlsConfig *tls.Config) (*redisConn, error) {
+	addr := busAddress(u, "6379")
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: busTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, busTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+
+	if u.User != nil {
+		args := []string{"AUTH"}
+		if pass, ok := u.User.Password(); ok {
+			// redis://:password@host authenticates as the default user
+			if user := u.User.Username(); user != "" {
+				args = append(args, user)
+			}
+			args = append(args, pass)
+		} else {
+			args = append(args, u.User.Username())
+		}
+		if err := rc.command(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// command sends a command and reads a simple, integer or error reply
+func (c *redisConn) command(args ...string) error {
+	c.conn.SetDeadline(time.Now().Add(busTimeout))
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return err
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "-") {
+		return fmt.Errorf("redis: %s", strings.TrimPrefix(line, "-"))
+	}
+	return nil
+}
+
+func (c *redisConn) publish(channel string, payload []byte) error {
+	return c.command("PUBLISH", channel, string(payload))
+}
+
+func (c *redisConn) Close() error {
+	return c.conn.Close()
+}
+
+// checkBusConfig reports a message bus URL or event that can't work
+func checkBusConfig(cfg BusConfig) []doctorCheck {
+	raw := strings.TrimSpace(cfg.URL)
+	if raw == "" {
+		return nil
+	}
+	var checks []doctorCheck
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		checks = append(checks, doctorCheck{"message_bus", doctorFail, "url: " + redactSecrets(err.Error())})
+	case u.Hostname() == "":
+		checks = append(checks, doctorCheck{"message_bus", doctorFail, "url has no host"})
+	default:
+		switch strings.ToLower(u.Scheme) {
+		case "nats", "tls", "redis", "rediss":
+		default:
+			checks = append(checks, doctorCheck{"message_bus", doctorFail, fmt.Sprintf("unsupported scheme %q, use nats, tls, redis or rediss", u.Scheme)})
+		}
+	}
+	if strings.ContainsAny(cfg.Subject, " \t*>") {
+		checks = append(checks, doctorCheck{"message_bus", doctorFail, "subject can't contain spaces, * or >"})
+	}
+	for _, e := range cfg.Events {
+		if e != busDiscovery && e != busScanFinished {
+			checks = append(checks, doctorCheck{"message_bus", doctorFail, fmt.Sprintf("unknown event %q, use discovery or scan_finished", e)})
+		}
+	}
+	return checks
+}
+
+// checkMessageBus connects to the configured bus
+func checkMessageBus(cfg *Config) []doctorCheck {
+	if strings.TrimSpace(cfg.MessageBus.URL) == "" {
+		return nil
+	}
+	conn, err := dialBus(&cfg.MessageBus)
+	if err != nil {
+		return []doctorCheck{{"message bus", doctorFail, redactSecrets(err.Error())}}
+	}
+	conn.Close()
+	return []doctorCheck{{"message bus", doctorPass, busScheme(cfg.MessageBus.URL) + " connected"}}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeServer accepts one connection on a loopback port and hands it to serve
+func fakeServer(t *testing.T, serve func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(conn)
+	}()
+	return ln.Addr().String()
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestNATSPublish(t *testing.T) {
+	type received struct {
+		connect map[string]interface{}
+		pub     string
+		payload string
+		pong    bool
+	}
+	done := make(chan received, 1)
+	addr := fakeServer(t, func(conn net.Conn) {
+		var got received
+		defer func() { done <- got }()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		line, _ := r.ReadString('\n')
+		json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &got.connect)
+		if line, _ = r.ReadString('\n'); strings.TrimSpace(line) != "PING" {
+			return
+		}
+		fmt.Fprint(conn, "PONG\r\n")
+
+		// The client answers the server's PINGs, before or after its publish
+		fmt.Fprint(conn, "PING\r\n")
+		for got.pub == "" || !got.pong {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PONG" {
+				got.pong = true
+				continue
+			}
+			got.pub = line
+			fields := strings.Fields(got.pub)
+			if len(fields) != 3 {
+				return
+			}
+			size, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, size+2)
+			io.ReadFull(r, payload)
+			got.payload = string(payload)
+		}
+	})
+
+	conn, err := dialNATS(mustParseURL(t, "nats://alice:secret@"+addr), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.publish("crtmon.discovery", []byte(`{"type":"discovery"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-done
+	if got.connect["user"] != "alice" || got.connect["pass"] != "secret" || got.connect["verbose"] != false {
+		t.Errorf("CONNECT = %v", got.connect)
+	}
+	if !got.pong {
+		t.Error("server PING was not answered")
+	}
+	if got.pub != "PUB crtmon.discovery 20" {
+		t.Errorf("PUB line = %q", got.pub)
+	}
+	if got.payload != "{\"type\":\"discovery\"}\r\n" {
+		t.Errorf("payload = %q", got.payload)
+	}
+}
+
+func TestNATSToken(t *testing.T) {
+	connect := make(chan map[string]interface{}, 1)
+	addr := fakeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {}\r\n")
+		line, _ := r.ReadString('\n')
+		var got map[string]interface{}
+		json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &got)
+		connect <- got
+		r.ReadString('\n')
+		fmt.Fprint(conn, "PONG\r\n")
+	})
+
+	conn, err := dialNATS(mustParseURL(t, "nats://s3cr3t@"+addr), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	got := <-connect
+	if got["auth_token"] != "s3cr3t" || got["user"] != nil {
+		t.Errorf("CONNECT = %v", got)
+	}
+}
+
+func TestNATSConnectRejected(t *testing.T) {
+	addr := fakeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {}\r\n")
+		r.ReadString('\n')
+		r.ReadString('\n')
+		fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+	})
+
+	_, err := dialNATS(mustParseURL(t, "nats://alice:wrong@"+addr), nil, false)
+	if err == nil || err.Error() != "nats: Authorization Violation" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestNATSNotAServer(t *testing.T) {
+	addr := fakeServer(t, func(conn net.Conn) {
+		fmt.Fprint(conn, "+OK\r\n")
+	})
+	if _, err := dialNATS(mustParseURL(t, "nats://"+addr), nil, false); err == nil || !strings.Contains(err.Error(), "not a nats server") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// readRESP reads one RESP array of bulk strings, as clients send commands
+func readRESP(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("not an array: %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("not a bulk string: %q", line)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if string(data[size:]) != "\r\n" {
+			return nil, fmt.Errorf("bulk string of %d bytes not terminated", size)
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+// fakeRedis records the commands of one connection, answering each with a reply
+func fakeRedis(t *testing.T, reply func(args []string) string) (string, chan [][]string) {
+	commands := make(chan [][]string, 1)
+	addr := fakeServer(t, func(conn net.Conn) {
+		var got [][]string
+		defer func() { commands <- got }()
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readRESP(r)
+			if err != nil {
+				return
+			}
+			got = append(got, args)
+			fmt.Fprint(conn, reply(args))
+		}
+	})
+	return addr, commands
+}
+
+func TestRedisPublish(t *testing.T) {
+	addr, commands := fakeRedis(t, func(args []string) string {
+		if args[0] == "PUBLISH" {
+			return ":1\r\n"
+		}
+		return "+OK\r\n"
+	})
+
+	conn, err := dialRedis(mustParseURL(t, "redis://crtmon:secret@"+addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Payloads are binary safe, line breaks included
+	if err := conn.publish("crtmon.scan_finished", []byte("{\"a\":\r\n1}")); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	got := <-commands
+	want := [][]string{
+		{"AUTH", "crtmon", "secret"},
+		{"PUBLISH", "crtmon.scan_finished", "{\"a\":\r\n1}"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestRedisDefaultUser(t *testing.T) {
+	addr, commands := fakeRedis(t, func(args []string) string { return "+OK\r\n" })
+
+	conn, err := dialRedis(mustParseURL(t, "redis://:secret@"+addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := <-commands; len(got) != 1 || fmt.Sprint(got[0]) != "[AUTH secret]" {
+		t.Errorf("commands = %q", got)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	addr, _ := fakeRedis(t, func(args []string) string {
+		return "-WRONGPASS invalid username-password pair\r\n"
+	})
+	_, err := dialRedis(mustParseURL(t, "redis://crtmon:wrong@"+addr), nil)
+	if err == nil || err.Error() != "redis: WRONGPASS invalid username-password pair" {
+		t.Fatalf("err = %v", err)
+	}
+
+	addr, _ = fakeRedis(t, func(args []string) string {
+		return "-ERR unknown command\r\n"
+	})
+	conn, err := dialRedis(mustParseURL(t, "redis://"+addr), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.publish("crtmon.discovery", []byte("{}")); err == nil {
+		t.Fatal("publish succeeded despite an error reply")
+	}
+}
+
+func TestDialBusScheme(t *testing.T) {
+	for _, raw := range []string{"amqp://localhost", "nats://", "://bad"} {
+		if _, err := dialBus(&BusConfig{URL: raw}); err == nil {
+			t.Errorf("dialBus(%q) succeeded", raw)
+		}
+	}
+}
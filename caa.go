@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CAAConfig holds settings for checking new certificates against their domains' CAA records
+type CAAConfig struct {
+	Enabled bool                `yaml:"enabled"`
+	Issuers map[string][]string `yaml:"issuers"` // Issuer name -> CAA identifiers it issues under, added to caaIssuerDomains
+	Webhook string              `yaml:"webhook"` // Falls back to the main webhook when empty
+}
+
+// caaIssuerDomains maps names in certificate issuers to the identifiers their CA
+// recognizes in CAA issue properties
+var caaIssuerDomains = map[string][]string{
+	"Let's Encrypt":         {"letsencrypt.org"},
+	"DigiCert":              {"digicert.com", "www.digicert.com", "symantec.com", "geotrust.com", "thawte.com", "rapidssl.com"},
+	"GeoTrust":              {"digicert.com", "geotrust.com"},
+	"Thawte":                {"digicert.com", "thawte.com"},
+	"RapidSSL":              {"digicert.com", "rapidssl.com"},
+	"Cloudflare":            {"digicert.com"}, // Cloudflare Inc ECC CA-3 is operated by DigiCert
+	"Sectigo":               {"sectigo.com", "comodoca.com", "comodo.com", "usertrust.com", "trust-provider.com"},
+	"COMODO":                {"sectigo.com", "comodoca.com", "comodo.com"},
+	"USERTrust":             {"sectigo.com", "usertrust.com"},
+	"ZeroSSL":               {"sectigo.com", "zerossl.com"},
+	"Google Trust Services": {"pki.goog"},
+	"Amazon":                {"amazon.com", "amazontrust.com", "awstrust.com", "amazonaws.com"},
+	"GlobalSign":            {"globalsign.com"},
+	"Microsoft":             {"microsoft.com"},
+	"Entrust":               {"entrust.net"},
+	"GoDaddy":               {"godaddy.com", "starfieldtech.com"},
+	"Starfield":             {"starfieldtech.com", "godaddy.com"},
+	"Buypass":               {"buypass.com", "buypass.no"},
+	"SSL.com":               {"ssl.com"},
+	"Certum":                {"certum.pl", "certum.eu"},
+	"HARICA":                {"harica.gr"},
+	"Apple":                 {"apple.com"},
+}
+
+// caaKnownTags are the CAA properties a critical flag doesn't forbid issuance for
+var caaKnownTags = map[string]bool{
+	"issue": true, "issuewild": true, "iodef": true, "issuemail": true, "issuevmc": true,
+	"contactemail": true, "contactphone": true,
+}
+
+var caaConfig *CAAConfig
+var caaMutex sync.Mutex
+
+// caaSeen holds the certificates already checked
+var caaSeen certSeen
+
+// SetCAAConfig sets the CAA cross-check configuration
+func SetCAAConfig(cfg *CAAConfig) {
+	caaMutex.Lock()
+	defer caaMutex.Unlock()
+	caaConfig = cfg
+}
+
+// GetCAAConfig returns the CAA cross-check configuration
+func GetCAAConfig() *CAAConfig {
+	caaMutex.Lock()
+	defer caaMutex.Unlock()
+	return caaConfig
+}
+
+// CheckCAA looks up the CAA records of a new certificate's matched domains and flags and
+// alerts on those that don't authorize its issuer
+func CheckCAA(entry CertEntry, decisions []EntryDecision) {
+	cfg := GetCAAConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	var domains []string
+	for _, d := range decisions {
+		if d.Matched && !d.Excluded {
+			domains = append(domains, d.Domain)
+		}
+	}
+	if len(domains) == 0 || !caaSeen.first(entry) {
+		return
+	}
+
+	issuer := describeIssuer(entry)
+	identifiers := caaIdentifiers(cfg, entry)
+	if len(identifiers) == 0 {
+		logger.Debug("no CAA identifiers known for issuer, skipping CAA check", "issuer", issuer)
+		return
+	}
+
+	dt := GetDomainTracker()
+	violations := make(map[string]string) // Domain -> reason
+	for _, domain := range domains {
+		records, err := LookupCAA(domain)
+		if err != nil {
+			logger.Debug("caa lookup failed", "domain", domain, "error", err)
+			continue
+		}
+		if reason := caaViolation(records, strings.HasPrefix(domain, "*."), identifiers); reason != "" {
+			violations[domain] = reason
+			dt.RecordCAAViolation(domain, issuer+": "+reason)
+			logger.Warn("certificate issuer not authorized by CAA", "domain", domain, "issuer", issuer, "reason", reason, "serial", entry.SerialNumber)
+		}
+	}
+	if len(violations) > 0 {
+		sendCAAAlert(cfg, entry, issuer, violations)
+	}
+}
+
+// caaIdentifiers returns the CAA identifiers of a certificate's issuer
+func caaIdentifiers(cfg *CAAConfig, entry CertEntry) []string {
+	seen := make(map[string]bool)
+	for _, mapping := range []map[string][]string{caaIssuerDomains, cfg.Issuers} {
+		for name, ids := range mapping {
+			if !issuerMatches(entry, []string{name}) {
+				continue
+			}
+			for _, id := range ids {
+				seen[strings.ToLower(strings.TrimSpace(id))] = true
+			}
+		}
+	}
+	identifiers := make([]string, 0, len(seen))
+	for id := range seen {
+		identifiers = append(identifiers, id)
+	}
+	sort.Strings(identifiers)
+	return identifiers
+}
+
+// caaViolation returns why a CAA record set doesn't let a CA with the given identifiers
+// issue for a domain, or "" if it does (RFC 8659)
+func caaViolation(records []CAARecord, wildcard bool, identifiers []string) string {
+	var issue, issuewild []string
+	hasIssue, hasIssuewild := false, false
+	for _, r := range records {
+		switch r.Tag {
+		case "issue":
+			hasIssue = true
+			issue = append(issue, caaIssuerDomain(r.Value))
+		case "issuewild":
+			hasIssuewild = true
+			issuewild = append(issuewild, caaIssuerDomain(r.Value))
+		default:
+			if r.Flags&caaCritical != 0 && !caaKnownTags[r.Tag] {
+				return "unknown critical CAA property " + r.Tag
+			}
+		}
+	}
+
+	authorized, present := issue, hasIssue
+	if wildcard && hasIssuewild {
+		authorized, present = issuewild, true
+	}
+	if !present {
+		return ""
+	}
+	var named []string
+	for _, a := range authorized {
+		if a == "" {
+			continue
+		}
+		for _, id := range identifiers {
+			if a == id {
+				return ""
+			}
+		}
+		named = append(named, a)
+	}
+	if len(named) == 0 {
+		return "CAA forbids all issuance"
+	}
+	return "CAA authorizes only " + strings.Join(named, ", ")
+}
+
+// caaIssuerDomain returns the issuer domain of an issue property value, without its parameters
+func caaIssuerDomain(value string) string {
+	domain, _, _ := strings.Cut(value, ";")
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
+
+// sendCAAAlert sends a dedicated high-priority alert for a certificate its domains'
+// CAA records don't authorize to Discord and ntfy
+func sendCAAAlert(cfg *CAAConfig, entry CertEntry, issuer string, violations map[string]string) {
+	domains := make([]string, 0, len(violations))
+	for domain := range violations {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	if isDryRun() {
+		logger.Info("dry run: would send CAA violation alert", "domains", domains, "issuer", issuer)
+		return
+	}
+
+	if alertConfigured(cfg.Webhook) {
+		if err := sendAlert(cfg.Webhook, buildCAAPayload(entry, issuer, domains, violations)); err != nil {
+			logger.Error("failed to send CAA violation alert", "domain", domains[0], "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("CAA violation alert for %s: %v", domains[0], err))
+		}
+	}
+	if isNtfyConfigured() {
+		body := fmt.Sprintf("Issuer: %s\n%s: %s", issuer, domains[0], violations[domains[0]])
+		if len(domains) > 1 {
+			body += fmt.Sprintf("\n+%d more domains", len(domains)-1)
+		}
+		if err := sendUrgentNtfy("CAA violation: "+domains[0], body, domains[0]); err != nil {
+			logger.Error("failed to send CAA violation ntfy alert", "domain", domains[0], "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("CAA violation ntfy alert for %s: %v", domains[0], err))
+		}
+	}
+}
+
+// buildCAAPayload builds a Discord embed for a certificate that violates CAA
+func buildCAAPayload(entry CertEntry, issuer string, domains []string, violations map[string]string) map[string]interface{} {
+	lines := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		lines = append(lines, domain+": "+violations[domain])
+	}
+	description := fmt.Sprintf("**Issuer**: %s\n**Serial**: `%s`\n**Log**: %s\n**Domains**:\n```\n%s\n```",
+		issuer, entry.SerialNumber, entry.LogURL, strings.Join(lines, "\n"))
+	embed := map[string]interface{}{
+		"title":       "CAA violation: " + domains[0],
+		"description": description,
+		"color":       15158332, // Red
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if link := dashboardDomainLink(domains[0]); link != "" {
+		embed["url"] = link
+	}
+	return map[string]interface{}{
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CensysConfig searches Censys for certificates of each target, catching those the
+// live CT feed missed or that were issued before monitoring started
+type CensysConfig struct {
+	APIID          string `yaml:"api_id"`
+	APISecret      string `yaml:"api_secret"`
+	IntervalHours  int    `yaml:"interval_hours"`  // Hours between searches of a target, default 24
+	MaxPages       int    `yaml:"max_pages"`       // Pages of 100 certificates per target and search, default 5
+	Query          string `yaml:"query"`           // {target} is replaced by the target, default "names: {target}"
+	IncludeExpired bool   `yaml:"include_expired"` // Also process certificates that have expired
+}
+
+// censysHit is the part of a certificate search hit crtmon reads
+type censysHit struct {
+	Names             []string `json:"names"`
+	FingerprintSHA256 string   `json:"fingerprint_sha256"`
+	Parsed            struct {
+		IssuerDN       string `json:"issuer_dn"`
+		SubjectDN      string `json:"subject_dn"`
+		SerialNumber   string `json:"serial_number"`
+		ValidityPeriod struct {
+			NotBefore time.Time `json:"not_before"`
+			NotAfter  time.Time `json:"not_after"`
+		} `json:"validity_period"`
+	} `json:"parsed"`
+}
+
+// censysSearchResponse is a page of certificate search results
+type censysSearchResponse struct {
+	Result struct {
+		Hits  []censysHit `json:"hits"`
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	} `json:"result"`
+	Error string `json:"error"`
+}
+
+// censysAPIBase is the Censys Search API
+const censysAPIBase = "https://search.censys.io/api"
+
+// censysInterval spaces requests under the free tier's limit
+const censysInterval = 3 * time.Second
+
+// censysSource marks tracked domains first found through Censys
+const censysSource = "censys"
+
+var censysConfig *CensysConfig
+var censysMutex sync.Mutex
+
+// censysPolled holds when each target was last searched, saved to censys_state.json
+var censysPolled = make(map[string]time.Time)
+var censysStatePath string
+
+// censysEntries carries found certificates to the main loop, which runs them
+// through the pipeline like certificates from the CT feed
+var censysEntries = make(chan CertEntry, 100)
+
+// SetCensysConfig sets the Censys certificate search configuration
+func SetCensysConfig(cfg *CensysConfig) {
+	censysMutex.Lock()
+	defer censysMutex.Unlock()
+	cfg.APIID = strings.TrimSpace(cfg.APIID)
+	cfg.APISecret = strings.TrimSpace(cfg.APISecret)
+	cfg.Query = strings.TrimSpace(cfg.Query)
+	if cfg.IntervalHours <= 0 {
+		cfg.IntervalHours = 24
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = 5
+	}
+	if cfg.Query == "" {
+		cfg.Query = "names: {target}"
+	}
+	censysConfig = cfg
+}
+
+// GetCensysConfig returns the Censys certificate search configuration
+func GetCensysConfig() *CensysConfig {
+	censysMutex.Lock()
+	defer censysMutex.Unlock()
+	return censysConfig
+}
+
+// isCensysEnabled reports whether Censys credentials are configured
+func isCensysEnabled() bool {
+	cfg := GetCensysConfig()
+	return cfg != nil && cfg.APIID != "" && cfg.APISecret != ""
+}
+
+// StartCensysPoller loads when each target was last searched and searches every
+// target that is due once an hour. Credentials added by a reload take effect then.
+func StartCensysPoller(configDir string) {
+	censysMutex.Lock()
+	censysStatePath = filepath.Join(configDir, "censys_state.json")
+	if data, err := os.ReadFile(censysStatePath); err == nil {
+		if err := json.Unmarshal(data, &censysPolled); err != nil {
+			logger.Warn("failed to read censys state, searching all targets again", "error", err)
+		}
+	}
+	censysMutex.Unlock()
+
+	go func() {
+		// Let the CT feed connect before the first search
+		time.Sleep(time.Minute)
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if isCensysEnabled() {
+				pollCensys()
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// pollCensys searches the targets whose interval has passed
+func pollCensys() {
+	cfg := GetCensysConfig()
+	interval := time.Duration(cfg.IntervalHours) * time.Hour
+
+	searched := false
+	for _, target := range targets {
+		// Certificates are searched by name, keyword targets have none
+		if isKeywordTarget(target) || isNetblockTarget(target) || isOrgTarget(target) {
+			continue
+		}
+		censysMutex.Lock()
+		last := censysPolled[target]
+		censysMutex.Unlock()
+		if time.Since(last) < interval {
+			continue
+		}
+		if searched {
+			time.Sleep(censysInterval)
+		}
+		searched = true
+
+		found, err := searchCensys(cfg, target, last)
+		if err != nil {
+			logger.Warn("censys search failed", "target", target, "error", err)
+			continue
+		}
+		logger.Info("censys search complete", "target", target, "certificates", found)
+
+		censysMutex.Lock()
+		censysPolled[target] = time.Now()
+		saveCensysState()
+		censysMutex.Unlock()
+	}
+}
+
+// searchCensys queues the certificates of a target with names not tracked yet and
+// returns how many were queued. Only certificates Censys added since the previous
+// search are requested, with a day of overlap.
+func searchCensys(cfg *CensysConfig, target string, since time.Time) (int, error) {
+	query := strings.ReplaceAll(cfg.Query, "{target}", target)
+	if !since.IsZero() {
+		query = fmt.Sprintf("(%s) and added_at: [%s TO *]", query, since.Add(-24*time.Hour).UTC().Format("2006-01-02"))
+	}
+
+	dt := GetDomainTracker()
+	queued := 0
+	cursor := ""
+	for page := 0; page < cfg.MaxPages; page++ {
+		if page > 0 {
+			time.Sleep(censysInterval)
+		}
+		resp, err := fetchCensysPage(cfg, query, cursor)
+		if err != nil {
+			return queued, err
+		}
+		for _, hit := range resp.Result.Hits {
+			entry, ok := censysCertEntry(hit, cfg.IncludeExpired)
+			if !ok {
+				continue
+			}
+			// Tracked names already went through the pipeline, and an older certificate
+			// would replace the details of their latest one
+			var untracked []string
+			for _, name := range entry.Domains {
+				if dt.GetDomainInfo(name) == nil {
+					untracked = append(untracked, name)
+				}
+			}
+			if len(untracked) == 0 {
+				continue
+			}
+			entry.Domains = untracked
+			censysEntries <- entry
+			queued++
+		}
+		cursor = resp.Result.Links.Next
+		if cursor == "" {
+			break
+		}
+	}
+	return queued, nil
+}
+
+// fetchCensysPage requests one page of certificate search results
+func fetchCensysPage(cfg *CensysConfig, query, cursor string) (*censysSearchResponse, error) {
+	params := url.Values{"q": {query}, "per_page": {"100"}}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	}
+	req, err := http.NewRequest(http.MethodGet, censysAPIBase+"/v2/certificates/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(cfg.APIID, cfg.APISecret)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result censysSearchResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("credentials rejected (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited")
+	case resp.StatusCode != http.StatusOK:
+		if result.Error != "" {
+			return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, result.Error)
+		}
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	case decodeErr != nil:
+		return nil, fmt.Errorf("invalid response: %w", decodeErr)
+	}
+	return &result, nil
+}
+
+// censysCertEntry converts a search hit to a certificate entry. ok is false for
+// hits without names, and for expired certificates unless they are included.
+func censysCertEntry(hit censysHit, includeExpired bool) (CertEntry, bool) {
+	validity := hit.Parsed.ValidityPeriod
+	if len(hit.Names) == 0 || (!includeExpired && !validity.NotAfter.IsZero() && validity.NotAfter.Before(time.Now())) {
+		return CertEntry{}, false
+	}
+
+	issuer := parseDN(hit.Parsed.IssuerDN)
+	subject := parseDN(hit.Parsed.SubjectDN)
+	var domains []string
+	seen := make(map[string]bool)
+	for _, name := range hit.Names {
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		domains = append(domains, name)
+	}
+	return CertEntry{
+		Domains:           domains,
+		NotBefore:         validity.NotBefore,
+		NotAfter:          validity.NotAfter,
+		Issuer:            issuer["CN"],
+		IssuerOrg:         issuer["O"],
+		LogURL:            "censys://",
+		SerialNumber:      hit.Parsed.SerialNumber,
+		SANs:              hit.Names,
+		FingerprintSHA256: hit.FingerprintSHA256,
+		SubjectOrg:        subject["O"],
+		SubjectCountry:    subject["C"],
+		Source:            censysSource,
+	}, true
+}
+
+// parseDN returns the attributes of a distinguished name like "C=US, O=Let's Encrypt, CN=R3"
+func parseDN(dn string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(dn, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			attrs[strings.ToUpper(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return attrs
+}
+
+// saveCensysState writes when each target was last searched. Caller must hold censysMutex.
+func saveCensysState() {
+	data, err := json.Marshal(censysPolled)
+	if err != nil {
+		return
+	}
+	tmp := censysStatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.Error("failed to save censys state", "error", err)
+		return
+	}
+	if err := os.Rename(tmp, censysStatePath); err != nil {
+		logger.Error("failed to save censys state", "error", err)
+	}
+}
+
+// checkCensysConfig reports a Censys section that cannot work as configured
+func checkCensysConfig(cfg CensysConfig) []doctorCheck {
+	var checks []doctorCheck
+	id, secret := strings.TrimSpace(cfg.APIID), strings.TrimSpace(cfg.APISecret)
+	if (id == "") != (secret == "") {
+		checks = append(checks, doctorCheck{"censys", doctorFail, "set both api_id and api_secret"})
+	}
+	if query := strings.TrimSpace(cfg.Query); query != "" && !strings.Contains(query, "{target}") {
+		checks = append(checks, doctorCheck{"censys", doctorWarn, "query has no {target}, every target gets the same results"})
+	}
+	return checks
+}
+
+// checkCensysAPI verifies the Censys credentials when they are configured
+func checkCensysAPI(cfg *Config) []doctorCheck {
+	id, secret := strings.TrimSpace(cfg.Censys.APIID), strings.TrimSpace(cfg.Censys.APISecret)
+	if id == "" || secret == "" {
+		return nil
+	}
+	return []doctorCheck{checkHTTP("censys api", censysAPIBase+"/v1/account", func(req *http.Request) {
+		req.SetBasicAuth(id, secret)
+	})}
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
@@ -19,11 +24,50 @@ import (
 )
 
 type CertEntry struct {
-	Domains   []string
-	NotBefore time.Time
-	NotAfter  time.Time
-	Issuer    string
-	LogURL    string
+	Domains           []string
+	NotBefore         time.Time
+	NotAfter          time.Time
+	Issuer            string
+	IssuerOrg         string
+	LogURL            string
+	SerialNumber      string
+	SANs              []string
+	FingerprintSHA256 string
+	KeyAlgorithm      string
+	IsPrecertificate  bool
+	SubjectOrg        string
+	SubjectCountry    string
+	Raw               []byte    // DER of the certificate or precertificate
+	LoggedAt          time.Time // Timestamp the CT log put on the entry
+	Source            string    // How the entry arrived, e.g. "certstream" or "censys"
+}
+
+// CertDetails holds the certificate metadata stored for a tracked domain
+type CertDetails struct {
+	SerialNumber      string    `json:"serial_number"`
+	Issuer            string    `json:"issuer"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	SANs              []string  `json:"sans"`
+	FingerprintSHA256 string    `json:"fingerprint_sha256"`
+	KeyAlgorithm      string    `json:"key_algorithm"`
+	IsPrecertificate  bool      `json:"is_precertificate"`
+	LogURL            string    `json:"log_url"`
+}
+
+// Details returns the certificate metadata of an entry
+func (e CertEntry) Details() *CertDetails {
+	return &CertDetails{
+		SerialNumber:      e.SerialNumber,
+		Issuer:            e.Issuer,
+		NotBefore:         e.NotBefore,
+		NotAfter:          e.NotAfter,
+		SANs:              e.SANs,
+		FingerprintSHA256: e.FingerprintSHA256,
+		KeyAlgorithm:      e.KeyAlgorithm,
+		IsPrecertificate:  e.IsPrecertificate,
+		LogURL:            e.LogURL,
+	}
 }
 
 type CTMonitor struct {
@@ -57,6 +101,7 @@ func (m *CTMonitor) run() {
 	logs, err := fetchLogList()
 	if err != nil {
 		logger.Error("failed to fetch CT log list", "error", err)
+		RecordError(errCategoryStream, fmt.Sprintf("fetch CT log list: %v", err))
 		return
 	}
 
@@ -73,7 +118,7 @@ func (m *CTMonitor) run() {
 }
 
 func fetchLogList() ([]*loglist3.Log, error) {
-	resp, err := http.Get(loglist3.LogListURL)
+	resp, err := downloadClient().Get(loglist3.LogListURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch log list: %w", err)
 	}
@@ -109,11 +154,10 @@ func (m *CTMonitor) monitorLog(logInfo *loglist3.Log) {
 		logURL = "https://" + logURL
 	}
 	logURL = strings.TrimSuffix(logURL, "/")
-	httpClient := &http.Client{Timeout: 30 * time.Second}
-
-	logClient, err := client.New(logURL, httpClient, jsonclient.Options{})
+	logClient, err := client.New(logURL, outboundClient(), jsonclient.Options{})
 	if err != nil {
 		logger.Warn("failed to create log client", "log", logInfo.Description, "error", err)
+		RecordError(errCategoryStream, fmt.Sprintf("%s: create client: %v", logInfo.Description, err))
 		// Update disconnected count
 		st := GetStatsTracker()
 		active, disconnected := st.GetCTLogHealth()
@@ -124,18 +168,16 @@ func (m *CTMonitor) monitorLog(logInfo *loglist3.Log) {
 	sth, err := logClient.GetSTH(m.ctx)
 	if err != nil {
 		logger.Warn("failed to get STH", "log", logInfo.Description, "error", err)
+		RecordError(errCategoryStream, fmt.Sprintf("%s: get STH: %v", logInfo.Description, err))
 		return
 	}
 
-	opts := scanner.FetcherOptions{
-		BatchSize:     256,
-		ParallelFetch: 2,
-		StartIndex:    int64(sth.TreeSize),
-		EndIndex:      0,
-		Continuous:    true,
-	}
-
-	fetcher := scanner.NewFetcher(logClient, &opts)
+	// Entries logged while offline are read first, without dropping any when the
+	// pipeline is busy
+	liveFrom := int64(sth.TreeSize)
+	cursor := newLogCursor(logURL, catchUpStart(logURL, logInfo.Description, liveFrom))
+	var caughtUp atomic.Bool
+	caughtUp.Store(cursor.position() >= liveFrom)
 
 	logger.Debug("monitoring CT log", "from", logInfo.Description)
 
@@ -146,9 +188,24 @@ func (m *CTMonitor) monitorLog(logInfo *loglist3.Log) {
 		default:
 		}
 
+		// Each connection resumes where the last one stopped
+		opts := scanner.FetcherOptions{
+			BatchSize:     256,
+			ParallelFetch: 2,
+			StartIndex:    cursor.position(),
+			EndIndex:      0,
+			Continuous:    true,
+		}
+		fetcher := scanner.NewFetcher(logClient, &opts)
+
 		err := fetcher.Run(m.ctx, func(batch scanner.EntryBatch) {
 			for i, entry := range batch.Entries {
-				m.processEntry(entry, batch.Start+int64(i), logURL)
+				index := batch.Start + int64(i)
+				m.processEntry(entry, index, logURL, index < liveFrom)
+			}
+			cursor.finish(batch.Start, batch.Start+int64(len(batch.Entries)))
+			if cursor.position() >= liveFrom && caughtUp.CompareAndSwap(false, true) {
+				logger.Info("caught up on CT log", "log", logInfo.Description)
 			}
 		})
 
@@ -156,12 +213,16 @@ func (m *CTMonitor) monitorLog(logInfo *loglist3.Log) {
 			if m.ctx.Err() != nil {
 				return
 			}
+			logger.Debug("CT log fetcher stopped, reconnecting", "log", logInfo.Description, "error", err)
+			RecordError(errCategoryStream, fmt.Sprintf("%s: reconnecting: %v", logInfo.Description, err))
 			time.Sleep(5 * time.Second)
 		}
 	}
 }
 
-func (m *CTMonitor) processEntry(entry ct.LeafEntry, index int64, logURL string) {
+// processEntry parses a log entry and queues its certificate. Live entries are dropped
+// when the queue is full; backlog entries wait for room.
+func (m *CTMonitor) processEntry(entry ct.LeafEntry, index int64, logURL string, backlog bool) {
 	rle, err := ct.RawLogEntryFromLeaf(index, &entry)
 	if err != nil {
 		return
@@ -187,15 +248,78 @@ func (m *CTMonitor) processEntry(entry ct.LeafEntry, index int64, logURL string)
 		return
 	}
 
+	serial := ""
+	if cert.SerialNumber != nil {
+		serial = cert.SerialNumber.Text(16)
+	}
+
+	// For precertificates this is the fingerprint of the TBSCertificate
+	fingerprint := sha256.Sum256(rle.Cert.Data)
+
+	certEntry := CertEntry{
+		Domains:           domains,
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		Issuer:            cert.Issuer.CommonName,
+		IssuerOrg:         firstOrEmpty(cert.Issuer.Organization),
+		LogURL:            logURL,
+		SerialNumber:      serial,
+		SANs:              extractSANs(cert),
+		FingerprintSHA256: hex.EncodeToString(fingerprint[:]),
+		KeyAlgorithm:      describePublicKey(cert.PublicKey),
+		IsPrecertificate:  rle.Leaf.TimestampedEntry.EntryType == ct.PrecertLogEntryType,
+		SubjectOrg:        firstOrEmpty(cert.Subject.Organization),
+		SubjectCountry:    firstOrEmpty(cert.Subject.Country),
+		Raw:               rle.Cert.Data,
+		LoggedAt:          time.UnixMilli(int64(rle.Leaf.TimestampedEntry.Timestamp)),
+		Source:            sourceCertstream,
+	}
+	if backlog {
+		certEntry.Source = sourceBackfill
+		select {
+		case m.entryChan <- certEntry:
+		case <-m.ctx.Done():
+		}
+		return
+	}
 	select {
-	case m.entryChan <- CertEntry{
-		Domains:   domains,
-		NotBefore: cert.NotBefore,
-		NotAfter:  cert.NotAfter,
-		Issuer:    cert.Issuer.CommonName,
-		LogURL:    logURL,
-	}:
+	case m.entryChan <- certEntry:
+	default:
+	}
+}
+
+// firstOrEmpty returns the first value of a subject attribute
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// extractSANs returns the DNS, IP and email subject alternative names of a certificate
+func extractSANs(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	return sans
+}
+
+// describePublicKey returns the key algorithm and size, e.g. "RSA-2048" or "ECDSA-P-256"
+func describePublicKey(key interface{}) string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	case nil:
+		return ""
 	default:
+		return "unknown"
 	}
 }
 
@@ -215,6 +339,16 @@ func extractDomains(cert *x509.Certificate) []string {
 		}
 	}
 
+	// IP SANs are only of interest to netblock targets
+	if hasNetblockTargets() {
+		for _, ip := range cert.IPAddresses {
+			if addr := ip.String(); !seen[addr] {
+				seen[addr] = true
+				domains = append(domains, addr)
+			}
+		}
+	}
+
 	return domains
 }
 
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CatchUpConfig controls reading the entries CT logs received while crtmon was offline
+type CatchUpConfig struct {
+	Enabled    bool  `yaml:"enabled"`
+	MaxEntries int64 `yaml:"max_entries"` // Entries read back per log; older missed entries are skipped, default 500000
+	MaxHours   int   `yaml:"max_hours"`   // No catch-up after longer downtime, default 48
+}
+
+// checkpointSaveInterval is how often log positions are written to disk
+const checkpointSaveInterval = 30 * time.Second
+
+// LogCheckpoint is how far a CT log has been read
+type LogCheckpoint struct {
+	Index     int64     `json:"index"`      // Next entry to read
+	UpdatedAt time.Time `json:"updated_at"` // When Index last moved
+}
+
+// CheckpointStore keeps the read position of every CT log across restarts
+type CheckpointStore struct {
+	mu    sync.Mutex
+	logs  map[string]*LogCheckpoint // Log URL -> position
+	dirty bool
+	path  string
+}
+
+// logCursor tracks the first entry of a log not yet read. Batches fetched in parallel
+// finish out of order, so the cursor only moves past contiguous finished ranges.
+type logCursor struct {
+	mu     sync.Mutex
+	logURL string
+	next   int64
+	done   map[int64]int64 // Start -> end of finished ranges past next
+}
+
+var catchUpConfig *CatchUpConfig
+var catchUpMutex sync.Mutex
+var checkpointStore *CheckpointStore
+
+// SetCatchUpConfig sets the CT log catch-up configuration
+func SetCatchUpConfig(cfg *CatchUpConfig) {
+	catchUpMutex.Lock()
+	defer catchUpMutex.Unlock()
+	catchUpConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 500000
+	}
+	if cfg.MaxHours <= 0 {
+		cfg.MaxHours = 48
+	}
+}
+
+// GetCatchUpConfig returns the CT log catch-up configuration
+func GetCatchUpConfig() *CatchUpConfig {
+	catchUpMutex.Lock()
+	defer catchUpMutex.Unlock()
+	return catchUpConfig
+}
+
+// InitCheckpoints loads saved log positions and saves them periodically
+func InitCheckpoints(configDir string) error {
+	cs := &CheckpointStore{
+		logs: make(map[string]*LogCheckpoint),
+		path: filepath.Join(configDir, "ct_checkpoints.json"),
+	}
+	data, err := os.ReadFile(cs.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cs.logs); err != nil {
+			return err
+		}
+	}
+	checkpointStore = cs
+
+	go func() {
+		ticker := time.NewTicker(checkpointSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := cs.Save(); err != nil {
+				logger.Error("failed to save CT log checkpoints", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Get returns the saved position of a log
+func (cs *CheckpointStore) Get(logURL string) (LogCheckpoint, bool) {
+	if cs == nil {
+		return LogCheckpoint{}, false
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cp, exists := cs.logs[logURL]
+	if !exists {
+		return LogCheckpoint{}, false
+	}
+	return *cp, true
+}
+
+// set records the position of a log
+func (cs *CheckpointStore) set(logURL string, index int64) {
+	if cs == nil {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.logs[logURL] = &LogCheckpoint{Index: index, UpdatedAt: time.Now()}
+	cs.dirty = true
+}
+
+// Save writes the log positions to disk if any moved
+func (cs *CheckpointStore) Save() error {
+	if cs == nil || isDryRun() {
+		return nil
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if !cs.dirty {
+		return nil
+	}
+	data, err := json.Marshal(cs.logs)
+	if err != nil {
+		return err
+	}
+	tmp := cs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cs.path); err != nil {
+		return err
+	}
+	cs.dirty = false
+	return nil
+}
+
+// catchUpStart returns the entry a log should be read from when its tree has treeSize
+// entries: the saved position when catch-up is enabled and the gap is within limits,
+// otherwise the end of the log
+func catchUpStart(logURL, description string, treeSize int64) int64 {
+	cfg := GetCatchUpConfig()
+	if cfg == nil || !cfg.Enabled {
+		return treeSize
+	}
+	cp, exists := checkpointStore.Get(logURL)
+	if !exists || cp.Index >= treeSize {
+		return treeSize
+	}
+
+	offline := time.Since(cp.UpdatedAt)
+	if offline > time.Duration(cfg.MaxHours)*time.Hour {
+		logger.Warn("CT log gap older than catch-up window, skipping it", "log", description, "missed", treeSize-cp.Index, "offline", offline.Round(time.Minute))
+		return treeSize
+	}
+	start := cp.Index
+	if missed := treeSize - start; missed > cfg.MaxEntries {
+		logger.Warn("CT log gap exceeds catch-up limit, skipping oldest entries", "log", description, "missed", missed, "skipped", missed-cfg.MaxEntries)
+		start = treeSize - cfg.MaxEntries
+	}
+	logger.Info("catching up on CT log", "log", description, "entries", treeSize-start, "offline", offline.Round(time.Minute))
+	return start
+}
+
+// newLogCursor starts a cursor at an entry and records it as the log's position
+func newLogCursor(logURL string, start int64) *logCursor {
+	checkpointStore.set(logURL, start)
+	return &logCursor{logURL: logURL, next: start, done: make(map[int64]int64)}
+}
+
+// position returns the first entry not yet read
+func (c *logCursor) position() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.next
+}
+
+// finish marks the entries from start up to end as read
+func (c *logCursor) finish(start, end int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if end <= c.next {
+		return
+	}
+	if end > c.done[start] {
+		c.done[start] = end
+	}
+
+	moved := false
+	for advanced := true; advanced; {
+		advanced = false
+		for s, e := range c.done {
+			if s > c.next {
+				continue
+			}
+			if e > c.next {
+				c.next = e
+				moved, advanced = true, true
+			}
+			delete(c.done, s)
+		}
+	}
+	if moved {
+		checkpointStore.set(c.logURL, c.next)
+	}
+}
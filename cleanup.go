@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CleanupConfig holds cleanup worker scheduling settings
+type CleanupConfig struct {
+	IntervalHours      int    `yaml:"interval_hours"`         // How often to run when run_at is empty
+	RunAt              string `yaml:"run_at"`                 // Daily off-peak time (HH:MM), overrides interval_hours
+	MaxAgeDays         int    `yaml:"max_age_days"`           // Drop tracked domains not seen for this long
+	ScanFileMaxAgeDays int    `yaml:"scan_file_max_age_days"` // Delete scan output files older than this
+}
+
+// CleanupReport counts what the cleanup worker reclaimed
+type CleanupReport struct {
+	Entries      int       `json:"entries"`
+	ScanFiles    int       `json:"scan_files"`
+	CacheEntries int       `json:"cache_entries"`
+	LastRun      time.Time `json:"last_run"`
+}
+
+// scanFilePatterns matches enumeration output written to the working directory
+var scanFilePatterns = []string{"*.ferox.txt", "*.puredns.txt", "*.nuclei.txt"}
+
+var cleanupConfig *CleanupConfig
+var cleanupMutex sync.Mutex
+var cleanupReport CleanupReport // Accumulated since the last daily summary
+
+// SetCleanupConfig sets the cleanup configuration
+func SetCleanupConfig(cfg *CleanupConfig) {
+	cleanupMutex.Lock()
+	defer cleanupMutex.Unlock()
+	cleanupConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.IntervalHours <= 0 {
+		cfg.IntervalHours = 24
+	}
+	if cfg.MaxAgeDays <= 0 {
+		cfg.MaxAgeDays = 30
+	}
+	if cfg.ScanFileMaxAgeDays <= 0 {
+		cfg.ScanFileMaxAgeDays = 7
+	}
+	if cfg.RunAt != "" {
+		if _, err := time.Parse("15:04", cfg.RunAt); err != nil {
+			logger.Warn("invalid cleanup run_at, using interval", "run_at", cfg.RunAt, "error", err)
+			cfg.RunAt = ""
+		}
+	}
+}
+
+// GetCleanupConfig returns the cleanup configuration, falling back to defaults
+func GetCleanupConfig() CleanupConfig {
+	cleanupMutex.Lock()
+	defer cleanupMutex.Unlock()
+	if cleanupConfig == nil {
+		return CleanupConfig{IntervalHours: 24, MaxAgeDays: 30, ScanFileMaxAgeDays: 7}
+	}
+	return *cleanupConfig
+}
+
+// StartCleanupScheduler runs the cleanup worker at run_at daily, or every interval_hours
+func StartCleanupScheduler() {
+	go func() {
+		for {
+			time.Sleep(nextCleanupDelay(GetCleanupConfig(), time.Now()))
+			RunCleanup()
+		}
+	}()
+}
+
+// nextCleanupDelay returns how long to wait until the next cleanup run
+func nextCleanupDelay(cfg CleanupConfig, now time.Time) time.Duration {
+	if cfg.RunAt == "" {
+		return time.Duration(cfg.IntervalHours) * time.Hour
+	}
+
+	at, _ := time.Parse("15:04", cfg.RunAt)
+	next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.Add(24 * time.Hour)
+	}
+	return next.Sub(now)
+}
+
+// RunCleanup reclaims old tracking entries, scan output files and cache entries
+func RunCleanup() CleanupReport {
+	cfg := GetCleanupConfig()
+
+	report := CleanupReport{LastRun: time.Now()}
+	report.Entries = GetDomainTracker().ClearOldEntries(time.Duration(cfg.MaxAgeDays) * 24 * time.Hour)
+	report.ScanFiles = removeOldScanFiles(time.Duration(cfg.ScanFileMaxAgeDays) * 24 * time.Hour)
+	report.CacheEntries = PruneResolveCache()
+
+	cleanupMutex.Lock()
+	cleanupReport.Entries += report.Entries
+	cleanupReport.ScanFiles += report.ScanFiles
+	cleanupReport.CacheEntries += report.CacheEntries
+	cleanupReport.LastRun = report.LastRun
+	cleanupMutex.Unlock()
+
+	logger.Info("cleanup complete", "entries", report.Entries, "scan_files", report.ScanFiles, "cache_entries", report.CacheEntries)
+	return report
+}
+
+// TakeCleanupReport returns what was reclaimed since the last call and resets the counters
+func TakeCleanupReport() CleanupReport {
+	cleanupMutex.Lock()
+	defer cleanupMutex.Unlock()
+	report := cleanupReport
+	cleanupReport = CleanupReport{LastRun: report.LastRun}
+	return report
+}
+
+// removeOldScanFiles deletes enumeration output files older than maxAge
+func removeOldScanFiles(maxAge time.Duration) int {
+	removed := 0
+	for _, pattern := range scanOutputPatterns() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, file := range matches {
+			info, err := os.Stat(file)
+			if err != nil || time.Since(info.ModTime()) <= maxAge {
+				continue
+			}
+			if err := os.Remove(file); err != nil {
+				logger.Warn("failed to remove scan file", "file", file, "error", err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// describeCleanupReport formats a cleanup report for the daily summary
+func describeCleanupReport(report CleanupReport) string {
+	return fmt.Sprintf("%d entries, %d scan files, %d cache entries", report.Entries, report.ScanFiles, report.CacheEntries)
+}
@@ -0,0 +1,253 @@
+// Package client calls the crtmon admin API and its gRPC API, to manage targets, pull
+// tracked domains and stream discoveries from other programs. The types in types.go
+// are generated from the same Go types crtmon's handlers encode; regenerate them with
+// go generate after changing those.
+package client
+
+//go:generate go run .. openapi -go client -o types.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls one crtmon admin panel with an API key
+type Client struct {
+	BaseURL    string // e.g. http://localhost:8080
+	APIKey     string // Created in the admin panel or with POST /api/keys
+	HTTPClient *http.Client
+}
+
+// Error is a response with a status other than 200 OK
+type Error struct {
+	StatusCode int
+	Message    string // The error text crtmon returned
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("crtmon: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// New returns a client for the admin panel at baseURL
+func New(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a request and decodes the JSON response into out, unless out is nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// setQuery adds a parameter unless its value is empty
+func setQuery(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+// Targets lists the targets, of one program when program isn't empty
+func (c *Client) Targets(ctx context.Context, program string) (*TargetList, error) {
+	q := url.Values{}
+	setQuery(q, "program", program)
+	var out TargetList
+	if err := c.do(ctx, http.MethodGet, "/api/targets", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddTarget adds a target: a domain, a contains:, regex: or org: target, or a netblock
+func (c *Client) AddTarget(ctx context.Context, target string) (*TargetChange, error) {
+	var out TargetChange
+	if err := c.do(ctx, http.MethodPost, "/api/targets", nil, TargetRequest{Target: target}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveTarget removes a target, written as crtmon lists it
+func (c *Client) RemoveTarget(ctx context.Context, target string) (*TargetChange, error) {
+	var out TargetChange
+	if err := c.do(ctx, http.MethodDelete, "/api/targets", url.Values{"target": {target}}, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddTargets adds several targets at once. Existing and invalid ones are reported, not errors.
+func (c *Client) AddTargets(ctx context.Context, targets []string) (*BulkTargetsResponse, error) {
+	var out BulkTargetsResponse
+	if err := c.do(ctx, http.MethodPost, "/api/targets/bulk", nil, BulkTargetsRequest{Targets: targets}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveTargets removes several targets at once
+func (c *Client) RemoveTargets(ctx context.Context, targets []string) (*BulkTargetsResponse, error) {
+	var out BulkTargetsResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/targets/bulk", nil, BulkTargetsRequest{Targets: targets}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DomainsQuery narrows GET /api/domains; empty fields match everything
+type DomainsQuery struct {
+	Provider string // aws, gcp, azure, digitalocean or other
+	ASN      string // e.g. AS13335
+	Source   string // e.g. certstream
+	Program  string
+}
+
+// Domains lists tracked domains
+func (c *Client) Domains(ctx context.Context, query DomainsQuery) (*DomainList, error) {
+	q := url.Values{}
+	setQuery(q, "provider", query.Provider)
+	setQuery(q, "asn", query.ASN)
+	setQuery(q, "source", query.Source)
+	setQuery(q, "program", query.Program)
+	var out DomainList
+	if err := c.do(ctx, http.MethodGet, "/api/domains", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Domain returns the full tracking entry of a domain
+func (c *Client) Domain(ctx context.Context, domain string) (*DomainDetail, error) {
+	var out DomainDetail
+	if err := c.do(ctx, http.MethodGet, "/api/domains/"+url.PathEscape(domain), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportQuery narrows an export; empty fields match everything
+type ExportQuery struct {
+	Target  string
+	Program string
+	Since   time.Time // First seen on or after
+	Until   time.Time // First seen on or before
+	MinRisk int
+}
+
+// ExportDomains returns tracked domains, one record per target they fall under
+func (c *Client) ExportDomains(ctx context.Context, query ExportQuery) ([]ExportRecord, error) {
+	q := url.Values{"format": {"json"}}
+	setQuery(q, "target", query.Target)
+	setQuery(q, "program", query.Program)
+	if !query.Since.IsZero() {
+		q.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if !query.Until.IsZero() {
+		q.Set("until", query.Until.Format(time.RFC3339))
+	}
+	if query.MinRisk > 0 {
+		q.Set("min_risk", strconv.Itoa(query.MinRisk))
+	}
+	var out []ExportRecord
+	if err := c.do(ctx, http.MethodGet, "/api/domains/export", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FindingsQuery narrows GET /api/findings; empty fields match everything
+type FindingsQuery struct {
+	Domain   string // The domain and domains under it
+	Tool     string
+	Kind     string // path, subdomain or vulnerability
+	Severity string
+	Status   int
+	Since    time.Time // Last seen at or after
+	Limit    int       // Default 500
+}
+
+// Findings lists scan findings, most recently seen first
+func (c *Client) Findings(ctx context.Context, query FindingsQuery) (*FindingList, error) {
+	q := url.Values{}
+	setQuery(q, "domain", query.Domain)
+	setQuery(q, "tool", query.Tool)
+	setQuery(q, "kind", query.Kind)
+	setQuery(q, "severity", query.Severity)
+	if query.Status != 0 {
+		q.Set("status", strconv.Itoa(query.Status))
+	}
+	if !query.Since.IsZero() {
+		q.Set("since", query.Since.Format(time.RFC3339))
+	}
+	if query.Limit > 0 {
+		q.Set("limit", strconv.Itoa(query.Limit))
+	}
+	var out FindingList
+	if err := c.do(ctx, http.MethodGet, "/api/findings", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Assets returns the asset inventory, narrowed to a target, a state and a program
+// when they aren't empty
+func (c *Client) Assets(ctx context.Context, target, state, program string) (*AssetInventory, error) {
+	q := url.Values{}
+	setQuery(q, "target", target)
+	setQuery(q, "state", state)
+	setQuery(q, "program", program)
+	var out AssetInventory
+	if err := c.do(ctx, http.MethodGet, "/api/assets", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
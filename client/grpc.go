@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+)
+
+// grpcService prefixes the methods of crtmon's gRPC service
+const grpcService = "/crtmon.v1.Crtmon/"
+
+// jsonCodec matches the codec crtmon serves its gRPC messages with
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// GRPCClient calls crtmon's gRPC API with an API key
+type GRPCClient struct {
+	conn   *grpc.ClientConn
+	apiKey string
+}
+
+// DialGRPC connects to the gRPC API at target, e.g. localhost:9090. A nil tlsConfig
+// connects without TLS.
+func DialGRPC(target, apiKey string, tlsConfig *tls.Config) (*GRPCClient, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodec{}.Name())),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{conn: conn, apiKey: apiKey}, nil
+}
+
+// Close closes the connection
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// authorize adds the API key to a call's metadata
+func (c *GRPCClient) authorize(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", c.apiKey)
+}
+
+// invoke calls a unary method
+func (c *GRPCClient) invoke(ctx context.Context, method string, req, reply interface{}) error {
+	return c.conn.Invoke(c.authorize(ctx), grpcService+method, req, reply)
+}
+
+// ListTargets lists the targets, of one program when program isn't empty
+func (c *GRPCClient) ListTargets(ctx context.Context, program string) (*TargetList, error) {
+	var out TargetList
+	if err := c.invoke(ctx, "ListTargets", &TargetsRequest{Program: program}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddTargets adds targets. Existing and invalid ones are reported, not errors.
+func (c *GRPCClient) AddTargets(ctx context.Context, targets []string) (*BulkTargetsResponse, error) {
+	var out BulkTargetsResponse
+	if err := c.invoke(ctx, "AddTargets", &BulkTargetsRequest{Targets: targets}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveTargets removes targets
+func (c *GRPCClient) RemoveTargets(ctx context.Context, targets []string) (*BulkTargetsResponse, error) {
+	var out BulkTargetsResponse
+	if err := c.invoke(ctx, "RemoveTargets", &BulkTargetsRequest{Targets: targets}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListDomains lists tracked domains
+func (c *GRPCClient) ListDomains(ctx context.Context, req DomainsRequest) (*DomainList, error) {
+	var out DomainList
+	if err := c.invoke(ctx, "ListDomains", &req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDomain returns the full tracking entry of a domain
+func (c *GRPCClient) GetDomain(ctx context.Context, domain string) (*DomainDetail, error) {
+	var out DomainDetail
+	if err := c.invoke(ctx, "GetDomain", &DomainRequest{Domain: domain}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DiscoveryStream receives discoveries from SubscribeDiscoveries
+type DiscoveryStream struct {
+	stream grpc.ClientStream
+}
+
+// Recv waits for the next discovery. Discoveries wait on the server while Recv isn't
+// called, up to the server's buffer; past that the stream fails with
+// RESOURCE_EXHAUSTED and can be resumed with After set to the last Seq received.
+func (s *DiscoveryStream) Recv() (*Discovery, error) {
+	var d Discovery
+	if err := s.stream.RecvMsg(&d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SubscribeDiscoveries streams new matched domains until ctx is done
+func (c *GRPCClient) SubscribeDiscoveries(ctx context.Context, req SubscribeRequest) (*DiscoveryStream, error) {
+	desc := &grpc.StreamDesc{StreamName: "SubscribeDiscoveries", ServerStreams: true}
+	stream, err := c.conn.NewStream(c.authorize(ctx), desc, grpcService+"SubscribeDiscoveries")
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &DiscoveryStream{stream: stream}, nil
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CodeSearchConfig searches GitHub and GitLab code for mentions of new domains, which
+// turns up leaked configs and API endpoints. It uses github_token and gitlab_token.
+type CodeSearchConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Webhook    string `yaml:"webhook"`     // Falls back to the main webhook when empty
+	GitLabURL  string `yaml:"gitlab_url"`  // Self-managed GitLab, default https://gitlab.com
+	MaxResults int    `yaml:"max_results"` // Files reported per domain and service, default 10
+	QueueSize  int    `yaml:"queue_size"`  // Domains waiting to be searched; more are dropped. Applies on restart.
+
+	githubToken string
+	gitlabToken string
+}
+
+// CodeHit is a file in a repository that mentions a domain
+type CodeHit struct {
+	Service string    `json:"service"` // "github" or "gitlab"
+	Repo    string    `json:"repo"`    // e.g. "owner/name"
+	Path    string    `json:"path"`
+	URL     string    `json:"url"`
+	Snippet string    `json:"snippet,omitempty"` // The line mentioning the domain
+	FoundAt time.Time `json:"found_at"`
+}
+
+// gitlabProject is the part of a GitLab project needed to link to its files
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+const githubAPIBase = "https://api.github.com"
+const defaultGitLabURL = "https://gitlab.com"
+
+// GitHub allows ten code searches a minute, GitLab is more lenient
+const (
+	githubSearchInterval = 7 * time.Second
+	gitlabSearchInterval = 3 * time.Second
+)
+
+// maxCodeSnippet caps the line shown for a hit
+const maxCodeSnippet = 160
+
+// codeSearched holds the domains already queued so a domain seen again isn't
+// searched again. It is reset once it reaches maxCodeSearched.
+const maxCodeSearched = 10000
+
+var codeSearchConfig *CodeSearchConfig
+var codeSearchMutex sync.Mutex
+var codeSearchQueue chan string
+var codeSearched = make(map[string]bool)
+var gitlabProjects = make(map[string]gitlabProject)
+
+// SetCodeSearchConfig sets the code search configuration and the tokens it searches with
+func SetCodeSearchConfig(cfg *CodeSearchConfig, githubToken, gitlabToken string) {
+	codeSearchMutex.Lock()
+	defer codeSearchMutex.Unlock()
+	cfg.Webhook = strings.TrimSpace(cfg.Webhook)
+	cfg.GitLabURL = strings.TrimSuffix(strings.TrimSpace(cfg.GitLabURL), "/")
+	if cfg.GitLabURL == "" {
+		cfg.GitLabURL = defaultGitLabURL
+	}
+	if cfg.MaxResults <= 0 {
+		cfg.MaxResults = 10
+	}
+	if cfg.MaxResults > 100 {
+		cfg.MaxResults = 100
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 500
+	}
+	cfg.githubToken = strings.TrimSpace(githubToken)
+	cfg.gitlabToken = strings.TrimSpace(gitlabToken)
+	codeSearchConfig = cfg
+}
+
+// GetCodeSearchConfig returns the code search configuration
+func GetCodeSearchConfig() *CodeSearchConfig {
+	codeSearchMutex.Lock()
+	defer codeSearchMutex.Unlock()
+	return codeSearchConfig
+}
+
+// isCodeSearchEnabled reports whether code search is on and has a token to search with
+func isCodeSearchEnabled() bool {
+	cfg := GetCodeSearchConfig()
+	return cfg != nil && cfg.Enabled && (cfg.githubToken != "" || cfg.gitlabToken != "")
+}
+
+// StartCodeSearchWorker starts the single worker that searches queued domains, spacing
+// requests to stay within each service's search limit. Enabling code search with a
+// reload takes effect without a restart.
+func StartCodeSearchWorker() {
+	size := 500
+	if cfg := GetCodeSearchConfig(); cfg != nil {
+		size = cfg.QueueSize
+	}
+	codeSearchQueue = make(chan string, size)
+	go func() {
+		var githubNext, gitlabNext time.Time
+		for domain := range codeSearchQueue {
+			searchCode(domain, &githubNext, &gitlabNext)
+		}
+	}()
+}
+
+// QueueCodeSearch schedules a search for a newly found domain without blocking
+func QueueCodeSearch(domain string) {
+	if codeSearchQueue == nil || !isCodeSearchEnabled() {
+		return
+	}
+	d := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+
+	codeSearchMutex.Lock()
+	if codeSearched[d] {
+		codeSearchMutex.Unlock()
+		return
+	}
+	if len(codeSearched) >= maxCodeSearched {
+		codeSearched = make(map[string]bool)
+	}
+	codeSearched[d] = true
+	codeSearchMutex.Unlock()
+
+	select {
+	case codeSearchQueue <- d:
+	default:
+		logger.Warn("code search queue full, skipping domain", "domain", d)
+	}
+}
+
+// waitCodeSearchTurn sleeps until a service may be searched again and reserves the next slot
+func waitCodeSearchTurn(next *time.Time, interval time.Duration) {
+	time.Sleep(time.Until(*next))
+	*next = time.Now().Add(interval)
+}
+
+// searchCode searches each service with a token for a domain and alerts on files not
+// reported before
+func searchCode(domain string, githubNext, gitlabNext *time.Time) {
+	cfg := GetCodeSearchConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	var hits []CodeHit
+	if cfg.githubToken != "" {
+		waitCodeSearchTurn(githubNext, githubSearchInterval)
+		found, err := searchGitHubCode(cfg.githubToken, domain, cfg.MaxResults)
+		if err != nil {
+			logger.Warn("github code search failed", "domain", domain, "error", err)
+		}
+		hits = append(hits, found...)
+	}
+	if cfg.gitlabToken != "" {
+		waitCodeSearchTurn(gitlabNext, gitlabSearchInterval)
+		found, err := searchGitLabCode(cfg.GitLabURL, cfg.gitlabToken, domain, cfg.MaxResults)
+		if err != nil {
+			logger.Warn("gitlab code search failed", "domain", domain, "error", err)
+		}
+		hits = append(hits, found...)
+	}
+	if len(hits) == 0 {
+		logger.Debug("no code mentions domain", "domain", domain)
+		return
+	}
+
+	added := GetDomainTracker().RecordCodeHits(domain, hits)
+	if len(added) == 0 {
+		return
+	}
+	logger.Info("code mentions domain", "domain", domain, "files", len(added))
+	sendCodeSearchAlert(cfg, domain, added)
+}
+
+// searchGitHubCode returns files on GitHub containing the domain
+func searchGitHubCode(token, domain string, limit int) ([]CodeHit, error) {
+	params := url.Values{"q": {`"` + domain + `"`}, "per_page": {strconv.Itoa(limit)}}
+	req, err := http.NewRequest(http.MethodGet, githubAPIBase+"/search/code?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	// text-match adds the fragments that matched
+	req.Header.Set("Accept", "application/vnd.github.text-match+json")
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("token rejected (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Items []struct {
+			Path       string `json:"path"`
+			HTMLURL    string `json:"html_url"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			TextMatches []struct {
+				Fragment string `json:"fragment"`
+			} `json:"text_matches"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	var hits []CodeHit
+	for _, item := range result.Items {
+		hit := CodeHit{Service: "github", Repo: item.Repository.FullName, Path: item.Path, URL: item.HTMLURL, FoundAt: time.Now()}
+		for _, match := range item.TextMatches {
+			if hit.Snippet = codeSnippet(match.Fragment, domain); hit.Snippet != "" {
+				break
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// searchGitLabCode returns files in the GitLab projects the token can read that
+// contain the domain
+func searchGitLabCode(baseURL, token, domain string, limit int) ([]CodeHit, error) {
+	params := url.Values{"scope": {"blobs"}, "search": {domain}, "per_page": {strconv.Itoa(limit)}}
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/v4/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", token)
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("token rejected (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited")
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var blobs []struct {
+		Path      string `json:"path"`
+		Ref       string `json:"ref"`
+		Startline int    `json:"startline"`
+		ProjectID int    `json:"project_id"`
+		Data      string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&blobs); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	var hits []CodeHit
+	for _, blob := range blobs {
+		project, err := fetchGitLabProject(baseURL, token, blob.ProjectID)
+		if err != nil {
+			logger.Debug("gitlab project lookup failed", "project_id", blob.ProjectID, "error", err)
+			continue
+		}
+		link := project.WebURL + "/-/blob/" + url.PathEscape(blob.Ref) + (&url.URL{Path: "/" + blob.Path}).EscapedPath()
+		if blob.Startline > 0 {
+			link += "#L" + strconv.Itoa(blob.Startline)
+		}
+		hits = append(hits, CodeHit{
+			Service: "gitlab",
+			Repo:    project.PathWithNamespace,
+			Path:    blob.Path,
+			URL:     link,
+			Snippet: codeSnippet(blob.Data, domain),
+			FoundAt: time.Now(),
+		})
+	}
+	return hits, nil
+}
+
+// fetchGitLabProject returns a project's name and web address, cached since every
+// blob of a project refers to it by ID
+func fetchGitLabProject(baseURL, token string, id int) (gitlabProject, error) {
+	key := baseURL + "/" + strconv.Itoa(id)
+	codeSearchMutex.Lock()
+	project, ok := gitlabProjects[key]
+	codeSearchMutex.Unlock()
+	if ok {
+		return project, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, baseURL+"/api/v4/projects/"+strconv.Itoa(id), nil)
+	if err != nil {
+		return project, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", token)
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return project, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return project, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&project); err != nil {
+		return project, fmt.Errorf("invalid response: %w", err)
+	}
+
+	codeSearchMutex.Lock()
+	gitlabProjects[key] = project
+	codeSearchMutex.Unlock()
+	return project, nil
+}
+
+// codeSnippet returns the first line of text mentioning the domain, shortened
+func codeSnippet(text, domain string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.Contains(strings.ToLower(line), domain) {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if len(line) > maxCodeSnippet {
+			line = line[:maxCodeSnippet] + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// sendCodeSearchAlert sends the files mentioning a domain to the code search webhook
+func sendCodeSearchAlert(cfg *CodeSearchConfig, domain string, hits []CodeHit) {
+	if isDryRun() {
+		logger.Info("dry run: would send code search alert", "domain", domain, "files", len(hits))
+		return
+	}
+	if !alertConfigured(cfg.Webhook) {
+		return
+	}
+	if err := sendAlert(cfg.Webhook, buildCodeSearchPayload(domain, hits)); err != nil {
+		logger.Error("failed to send code search alert", "domain", domain, "error", err)
+		RecordError(errCategoryWebhook, fmt.Sprintf("code search alert for %s: %v", domain, err))
+	}
+}
+
+// buildCodeSearchPayload builds a Discord embed linking each file that mentions a domain
+func buildCodeSearchPayload(domain string, hits []CodeHit) map[string]interface{} {
+	var b strings.Builder
+	for i, hit := range hits {
+		line := fmt.Sprintf("[%s/%s](%s) (%s)\n", hit.Repo, hit.Path, hit.URL, hit.Service)
+		if hit.Snippet != "" {
+			line += "`" + strings.ReplaceAll(hit.Snippet, "`", "'") + "`\n"
+		}
+		if b.Len()+len(line) > maxBatchChars {
+			fmt.Fprintf(&b, "… and %d more", len(hits)-i)
+			break
+		}
+		b.WriteString(line)
+	}
+
+	embed := map[string]interface{}{
+		"title":       fmt.Sprintf("Code mentioning %s (%d files)", domain, len(hits)),
+		"description": b.String(),
+		"color":       15105570, // Orange
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if link := dashboardDomainLink(domain); link != "" {
+		embed["url"] = link
+	}
+	return map[string]interface{}{
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
+	}
+}
+
+// checkCodeSearchConfig reports a code search section that cannot work as configured
+func checkCodeSearchConfig(cfg *Config) []doctorCheck {
+	if !cfg.CodeSearch.Enabled {
+		return nil
+	}
+	var checks []doctorCheck
+	if strings.TrimSpace(cfg.GitHubToken) == "" && strings.TrimSpace(cfg.GitLabToken) == "" {
+		checks = append(checks, doctorCheck{"code search", doctorFail, "enabled without github_token or gitlab_token"})
+	}
+	if raw := strings.TrimSpace(cfg.CodeSearch.GitLabURL); raw != "" {
+		if parsed, err := url.Parse(raw); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			checks = append(checks, doctorCheck{"code search", doctorFail, "gitlab_url must be an absolute http(s) URL"})
+		}
+	}
+	return checks
+}
+
+// checkCodeSearchAPIs verifies the GitHub and GitLab tokens when code search is enabled
+func checkCodeSearchAPIs(cfg *Config) []doctorCheck {
+	if !cfg.CodeSearch.Enabled {
+		return nil
+	}
+	var checks []doctorCheck
+	if token := strings.TrimSpace(cfg.GitHubToken); token != "" {
+		checks = append(checks, checkHTTP("github api", githubAPIBase+"/rate_limit", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}))
+	}
+	if token := strings.TrimSpace(cfg.GitLabToken); token != "" {
+		base := strings.TrimSuffix(strings.TrimSpace(cfg.CodeSearch.GitLabURL), "/")
+		if base == "" {
+			base = defaultGitLabURL
+		}
+		checks = append(checks, checkHTTP("gitlab api", base+"/api/v4/user", func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}))
+	}
+	return checks
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// command is a crtmon subcommand selected by the first argument. Running with only
+// flags (or "monitor") keeps the original monitoring behavior.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands besides monitor, in help order
+var commands = []command{
+	{"search", "look up a domain in the tracker and SNI data", runSearch},
+	{"export", "dump tracked domains or scan findings as csv or json", runExport},
+	{"scan", "queue enumeration for a domain and wait for the results", runScan},
+	{"config", "validate the configuration file", runConfigCommand},
+	{"targets", "import targets from a file or export them one per line", runTargets},
+	{"purge", "delete all data for a target, with a confirmation token", runPurge},
+	{"doctor", "check CT logs, DNS, webhooks, tools, wordlists, disk space and permissions", runDoctorCommand},
+	{"replay", "feed recorded certificate entries through matching, dedup and risk scoring without sending", runReplay},
+	{"openapi", "print the OpenAPI document of the admin API, or Go types for a client", runOpenAPI},
+	{"verify-log", "check the event or audit log hash chain for edited or removed lines", runVerifyLog},
+	{"service", "install, start, stop, status or uninstall the Windows service", runServiceCommand},
+}
+
+// findCommand returns the subcommand with the given name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// loadCommandConfig applies -config and loads the targets a subcommand works against
+func loadCommandConfig(path string) (*Config, error) {
+	if path != "" {
+		setConfigPath(path)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		targets = normalizeTargets(cfg.Targets)
+		SetHTTPConfig(&cfg.HTTP)
+	}
+	return cfg, nil
+}
+
+// runDoctorCommand implements crtmon doctor [-config path]
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	doctorConfig := fs.String("config", "", "path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: crtmon doctor [-config path]")
+		return 1
+	}
+	if *doctorConfig != "" {
+		setConfigPath(*doctorConfig)
+	}
+	return runDoctor()
+}
+
+// runSearch implements crtmon search <domain>, matching tracked domains and the SNI dataset
+func runSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	searchConfig := fs.String("config", "", "path to configuration file")
+	withSNI := fs.Bool("sni", true, "also search the downloaded SNI dataset")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: crtmon search [-config path] [-sni=false] <domain>")
+		return 1
+	}
+	query := strings.ToLower(strings.TrimSpace(fs.Arg(0)))
+
+	if _, err := loadCommandConfig(*searchConfig); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := InitDomainTracker(configDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var matches []*DomainEntry
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		if strings.Contains(entry.Domain, query) {
+			matches = append(matches, entry)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].FirstSeen.Before(matches[j].FirstSeen)
+	})
+
+	fmt.Printf("tracker: %d match(es)\n", len(matches))
+	for _, entry := range matches {
+		resolved := "unresolved"
+		if entry.Resolved {
+			resolved = "resolves"
+		}
+		fmt.Printf("  %-50s first seen %s  risk %3d  %s\n", entry.Domain, entry.FirstSeen.Format(time.DateOnly), entry.RiskScore, resolved)
+	}
+
+	if !*withSNI {
+		return 0
+	}
+	sniPath := filepath.Join(configDir, "sni.txt")
+	if _, err := os.Stat(sniPath); err != nil {
+		fmt.Println("sni: no dataset downloaded yet")
+		return 0
+	}
+	sm := &SNIManager{sniFilePath: sniPath}
+	found, err := sm.SearchSNIForDomain(query)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("sni: %d match(es)\n", len(found))
+	for _, domain := range found {
+		fmt.Printf("  %s\n", domain)
+	}
+	return 0
+}
+
+// runScan implements crtmon scan <domain>, running the same enumeration a new
+// discovery triggers and waiting for the queued jobs to finish
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	scanConfig := fs.String("config", "", "path to configuration file")
+	scanTarget := fs.String("target", "", "target the domain belongs to (defaults to the matching configured target)")
+	sendResults := fs.Bool("notify", false, "post results to the configured discord webhooks")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: crtmon scan [-config path] [-target domain] [-notify] <domain|*.domain>")
+		return 1
+	}
+	domain := strings.ToLower(strings.TrimSpace(fs.Arg(0)))
+
+	cfg, err := loadCommandConfig(*scanConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if cfg == nil || !cfg.Enumeration.EnableEnum {
+		fmt.Fprintln(os.Stderr, "enumeration is not enabled in the configuration file")
+		return 1
+	}
+	SetLowResourceConfig(&cfg.LowResource)
+	SetEnumConfig(&cfg.Enumeration)
+	SetWebhookConfig(&cfg.Webhooks)
+	webhookURL = strings.TrimSpace(cfg.Webhook)
+	SetDiscordBotConfig(&cfg.DiscordBot)
+	notifyDiscord = *sendResults && discordConfigured()
+
+	configDir, _ := getConfigDir()
+	if err := InitDomainTracker(configDir); err != nil {
+		logger.Warn("failed to initialize domain tracker", "error", err)
+	}
+
+	owner := *scanTarget
+	if owner == "" {
+		for _, t := range targets {
+			if matchesTarget(ExtractBaseDomain(domain), t) {
+				owner = t
+				break
+			}
+		}
+	}
+
+	triggerEnumeration(domain, owner)
+
+	jm := GetJobManager()
+	for {
+		running, queued := jm.Counts()
+		if running == 0 && queued == 0 {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+
+	failed := 0
+	for _, job := range jm.List() {
+		fmt.Printf("%-12s %-10s %s\n", job.Type, job.Status, job.OutputFile)
+		if job.Status != jobFinished {
+			failed++
+		}
+	}
+	if failed > 0 || len(jm.List()) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// runConfigCommand implements crtmon config validate [-config path] [-probe]
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: crtmon config validate [-config path] [-probe]")
+		return 1
+	}
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	validateConfigPath := fs.String("config", "", "path to configuration file")
+	probe := fs.Bool("probe", false, "also check that webhooks and bots are reachable")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+	if *validateConfigPath != "" {
+		setConfigPath(*validateConfigPath)
+	}
+	return printDoctorReport(validateConfigFile(*probe))
+}
+
+// validateConfigFile strictly checks the config file: unknown keys, targets, URL
+// syntax, notification providers and enumeration tool paths. Reachability of
+// webhooks and bots is only checked with probe.
+func validateConfigFile(probe bool) []doctorCheck {
+	path, err := getConfigPath()
+	if err != nil {
+		return []doctorCheck{{"config", doctorFail, err.Error()}}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []doctorCheck{{"config", doctorFail, err.Error()}}
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	err = dec.Decode(&cfg)
+	typeErr, schemaOnly := err.(*yaml.TypeError)
+	if err != nil && err != io.EOF && !schemaOnly {
+		return []doctorCheck{{"config", doctorFail, err.Error()}}
+	}
+	checks := []doctorCheck{{"config", doctorPass, path}}
+	if schemaOnly {
+		// Unknown keys and wrong types leave the rest of the file decoded
+		for _, msg := range typeErr.Errors {
+			checks = append(checks, doctorCheck{"schema", doctorFail, msg})
+		}
+	}
+	for _, key := range unknownSecretKeys(&cfg) {
+		checks = append(checks, doctorCheck{"schema", doctorFail, key + " is not a supported secret"})
+	}
+
+	// Secret files are read offline, secret commands only run with probe
+	for _, o := range configOverrides {
+		if cfg.SecretFiles[o.key] == "" && (!probe || cfg.SecretCommands[o.key] == "") {
+			continue
+		}
+		switch value, ok, err := loadSecret(&cfg, o.key); {
+		case err != nil:
+			checks = append(checks, doctorCheck{"secret", doctorFail, err.Error()})
+		case ok:
+			*o.field(&cfg) = value
+			checks = append(checks, doctorCheck{"secret", doctorPass, o.key})
+		}
+	}
+
+	invalid := 0
+	for _, t := range cfg.Targets {
+		if normalized, err := normalizeTarget(t); err != nil {
+			checks = append(checks, doctorCheck{"target", doctorFail, err.Error()})
+			invalid++
+		} else if normalized == "" {
+			checks = append(checks, doctorCheck{"target", doctorFail, "empty target"})
+			invalid++
+		} else if ca := orgTargetCA(normalized); ca != "" {
+			checks = append(checks, doctorCheck{"target", doctorWarn, normalized + " also matches every certificate " + ca + " issues"})
+		}
+	}
+	switch {
+	case len(cfg.Targets) == 0:
+		checks = append(checks, doctorCheck{"targets", doctorWarn, "none configured, pass -target or stdin"})
+	case invalid == 0:
+		checks = append(checks, doctorCheck{"targets", doctorPass, fmt.Sprintf("%d configured", len(normalizeTargets(cfg.Targets)))})
+	}
+
+	configuredTargets := make(map[string]bool)
+	for _, t := range normalizeTargets(cfg.Targets) {
+		configuredTargets[t] = true
+	}
+	for _, target := range sortedKeys(profileTargets(cfg.TargetProfiles)) {
+		profile := cfg.TargetProfiles[target]
+		normalized, err := normalizeTarget(target)
+		if err != nil {
+			checks = append(checks, doctorCheck{"target profile", doctorFail, err.Error()})
+			continue
+		}
+		if err := validateProfileNotify(profile.Notify); err != nil {
+			checks = append(checks, doctorCheck{"target profile", doctorFail, target + ": " + err.Error()})
+			continue
+		}
+		if len(cfg.Targets) > 0 && !configuredTargets[normalized] {
+			checks = append(checks, doctorCheck{"target profile", doctorWarn, target + " is not a configured target"})
+			continue
+		}
+		checks = append(checks, doctorCheck{"target profile", doctorPass, target})
+	}
+
+	checks = append(checks, checkSeverityConfig(cfg.Severity)...)
+	checks = append(checks, checkHTTPConfig(cfg.HTTP)...)
+	checks = append(checks, checkSNIConfig(cfg.SNI)...)
+	checks = append(checks, checkShodanConfig(cfg.Shodan)...)
+	checks = append(checks, checkCensysConfig(cfg.Censys)...)
+	checks = append(checks, checkReputationConfig(cfg.Reputation)...)
+	checks = append(checks, checkCodeSearchConfig(&cfg)...)
+	checks = append(checks, checkPortScanConfig(cfg.PortScan)...)
+	checks = append(checks, checkRescanConfig(&cfg)...)
+	checks = append(checks, checkGRPCConfig(&cfg)...)
+	checks = append(checks, checkBusConfig(cfg.MessageBus)...)
+	checks = append(checks, checkKafkaConfig(cfg.Kafka)...)
+	checks = append(checks, checkMQTTConfig(cfg.MQTT)...)
+	checks = append(checks, checkAssetConfig(cfg.Assets)...)
+	checks = append(checks, checkToolsConfig(cfg.Tools)...)
+
+	for _, name := range sortedKeys(programSet(cfg.Programs)) {
+		program := cfg.Programs[name]
+		var unknown []string
+		for _, t := range program.Targets {
+			normalized, err := normalizeTarget(t)
+			if err != nil {
+				checks = append(checks, doctorCheck{"program", doctorFail, name + ": " + err.Error()})
+				continue
+			}
+			if len(cfg.Targets) > 0 && !configuredTargets[normalized] {
+				unknown = append(unknown, t)
+			}
+		}
+		switch {
+		case len(program.Targets) == 0:
+			checks = append(checks, doctorCheck{"program", doctorWarn, name + " has no targets"})
+		case len(unknown) > 0:
+			checks = append(checks, doctorCheck{"program", doctorWarn, fmt.Sprintf("%s lists targets that are not monitored: %s", name, strings.Join(unknown, ", "))})
+		default:
+			checks = append(checks, doctorCheck{"program", doctorPass, fmt.Sprintf("%s, %d targets", name, len(program.Targets))})
+		}
+	}
+
+	loc, err := loadScheduleLocation(cfg.SummaryTimezone)
+	if err != nil {
+		checks = append(checks, doctorCheck{"summary_timezone", doctorFail, err.Error()})
+		loc = time.Local
+	}
+	if cfg.SummarySchedule != "" {
+		if _, err := parseCron(cfg.SummarySchedule, loc); err != nil {
+			checks = append(checks, doctorCheck{"summary_schedule", doctorFail, err.Error()})
+		}
+	}
+	for i, report := range cfg.ScheduledReports {
+		name := report.Name
+		if name == "" {
+			name = fmt.Sprintf("report-%d", i+1)
+		}
+		reportLoc := loc
+		if report.Timezone != "" {
+			if reportLoc, err = loadScheduleLocation(report.Timezone); err != nil {
+				checks = append(checks, doctorCheck{"scheduled report", doctorFail, name + ": " + err.Error()})
+				continue
+			}
+		}
+		if _, err := parseCron(report.Schedule, reportLoc); err != nil {
+			checks = append(checks, doctorCheck{"scheduled report", doctorFail, name + ": " + err.Error()})
+			continue
+		}
+		if _, exists := cfg.Programs[report.Program]; report.Program != "" && !exists {
+			checks = append(checks, doctorCheck{"scheduled report", doctorFail, name + ": unknown program " + report.Program})
+			continue
+		}
+		checks = append(checks, doctorCheck{"scheduled report", doctorPass, name + ", " + report.Schedule})
+	}
+
+	urls := []struct{ name, value string }{
+		{"discord webhook", cfg.Webhook},
+		{"new_domains_webhook", cfg.Webhooks.NewDomains},
+		{"subdomain_scans_webhook", cfg.Webhooks.SubdomainScans},
+		{"directory_scans_webhook", cfg.Webhooks.DirectoryScans},
+		{"daily_summary_webhook", cfg.Webhooks.DailySummary},
+		{"nuclei_findings_webhook", cfg.Webhooks.NucleiFindings},
+		{"ntfy topic_url", cfg.Ntfy.TopicURL},
+		{"admin public_url", cfg.AdminPanel.PublicURL},
+		{"opsgenie_api_url", cfg.Escalation.OpsgenieAPIURL},
+	}
+	for _, name := range sortedKeys(programSet(cfg.Programs)) {
+		urls = append(urls,
+			struct{ name, value string }{"programs." + name + ".webhook", cfg.Programs[name].Webhook},
+			struct{ name, value string }{"programs." + name + ".summary_webhook", cfg.Programs[name].SummaryWebhook})
+	}
+	for _, target := range sortedKeys(profileTargets(cfg.TargetProfiles)) {
+		urls = append(urls, struct{ name, value string }{"target_profiles." + target + ".webhook", cfg.TargetProfiles[target].Webhook})
+	}
+	for _, level := range severityLevels {
+		urls = append(urls, struct{ name, value string }{"severity.webhooks." + level, cfg.Severity.Webhooks[level]})
+	}
+	for _, u := range urls {
+		if u.value == "" || u.value == `""` {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			checks = append(checks, doctorCheck{u.name, doctorFail, "not a valid absolute URL"})
+		}
+	}
+
+	configured := func(key, value string) bool {
+		return value != "" || cfg.SecretCommands[key] != ""
+	}
+	if configured("telegram_bot_token", cfg.TelegramBotToken) != configured("telegram_chat_id", cfg.TelegramChatID) {
+		checks = append(checks, doctorCheck{"telegram", doctorFail, "telegram_bot_token and telegram_chat_id must be set together"})
+	}
+	if cfg.DiscordBot.Enabled {
+		var missing []string
+		for _, field := range []struct{ name, value string }{
+			{"token", cfg.DiscordBot.Token},
+			{"application_id", cfg.DiscordBot.ApplicationID},
+			{"public_key", cfg.DiscordBot.PublicKey},
+			{"channel_id", cfg.DiscordBot.ChannelID},
+		} {
+			if !configured("discord_bot."+field.name, field.value) {
+				missing = append(missing, field.name)
+			}
+		}
+		if key, err := hex.DecodeString(cfg.DiscordBot.PublicKey); cfg.DiscordBot.PublicKey != "" && (err != nil || len(key) != ed25519.PublicKeySize) {
+			checks = append(checks, doctorCheck{"discord bot", doctorFail, "public_key is not a hex Ed25519 key"})
+		}
+		switch {
+		case len(missing) > 0:
+			checks = append(checks, doctorCheck{"discord bot", doctorFail, "missing " + strings.Join(missing, ", ")})
+		case !cfg.AdminPanel.Enabled || cfg.AdminPanel.PublicURL == "":
+			checks = append(checks, doctorCheck{"discord bot", doctorWarn, "slash commands need the admin panel enabled with a public_url"})
+		default:
+			checks = append(checks, doctorCheck{"discord bot", doctorPass, "interactions endpoint " + strings.TrimRight(cfg.AdminPanel.PublicURL, "/") + "/api/discord/interactions"})
+		}
+	}
+	if !configured("webhook", cfg.Webhook) && !cfg.DiscordBot.Enabled && !configured("telegram_bot_token", cfg.TelegramBotToken) && !configured("ntfy.topic_url", cfg.Ntfy.TopicURL) {
+		checks = append(checks, doctorCheck{"notifications", doctorWarn, "no provider configured"})
+	}
+	if cfg.AdminPanel.Port < 0 || cfg.AdminPanel.Port > 65535 {
+		checks = append(checks, doctorCheck{"admin port", doctorFail, fmt.Sprintf("%d is out of range", cfg.AdminPanel.Port)})
+	}
+	if cfg.Enumeration.EnableEnum && cfg.Enumeration.FeroxbusterPath == "" && cfg.Enumeration.PurednsPath == "" {
+		checks = append(checks, doctorCheck{"enumeration", doctorWarn, "enabled but no tool paths set"})
+	}
+	checks = append(checks, checkToolBinaries(&cfg)...)
+
+	if probe {
+		registerSecrets(&cfg)
+		SetHTTPConfig(&cfg.HTTP)
+		checks = append(checks, checkNotificationProviders(&cfg)...)
+	}
+	return checks
+}
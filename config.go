@@ -9,24 +9,83 @@ import (
 )
 
 type Config struct {
-	Webhook          string        `yaml:"webhook"`
-	TelegramBotToken string        `yaml:"telegram_bot_token"`
-	TelegramChatID   string        `yaml:"telegram_chat_id"`
-	GitHubToken      string        `yaml:"github_token"`
-	GitLabToken      string        `yaml:"gitlab_token"`
-	Targets          []string      `yaml:"targets"`
-	Enumeration      EnumConfig    `yaml:"enumeration"`
-	Webhooks         WebhookConfig `yaml:"webhooks"`
-	AdminPanel       AdminConfig   `yaml:"admin_panel"`
+	Webhook          string                   `yaml:"webhook"`
+	TelegramBotToken string                   `yaml:"telegram_bot_token"`
+	TelegramChatID   string                   `yaml:"telegram_chat_id"`
+	Ntfy             NtfyConfig               `yaml:"ntfy"`
+	MessageBus       BusConfig                `yaml:"message_bus"`
+	Kafka            KafkaConfig              `yaml:"kafka"`
+	MQTT             MQTTConfig               `yaml:"mqtt"`
+	DiscordBot       DiscordBotConfig         `yaml:"discord_bot"`
+	Logging          LoggingConfig            `yaml:"logging"`
+	HTTP             HTTPConfig               `yaml:"http"`
+	GitHubToken      string                   `yaml:"github_token"`
+	GitLabToken      string                   `yaml:"gitlab_token"`
+	SecretFiles      map[string]string        `yaml:"secret_files"`    // Config key -> file holding its value, e.g. a docker secret
+	SecretCommands   map[string]string        `yaml:"secret_commands"` // Config key -> command printing its value
+	Targets          []string                 `yaml:"targets"`
+	TargetProfiles   map[string]TargetProfile `yaml:"target_profiles"` // Target -> settings replacing the global ones
+	Programs         map[string]ProgramConfig `yaml:"programs"`        // Name -> targets of one engagement scope
+	Exclusions       ExclusionConfig          `yaml:"exclusions"`
+	Dedup            DedupConfig              `yaml:"dedup"`
+	DNS              ResolveConfig            `yaml:"dns"`
+	SNI              SNIConfig                `yaml:"sni"`
+	Enumeration      EnumConfig               `yaml:"enumeration"`
+	Tools            []ToolConfig             `yaml:"tools"` // External tools run against new domains, like the built-in scanners
+	Webhooks         WebhookConfig            `yaml:"webhooks"`
+	AdminPanel       AdminConfig              `yaml:"admin_panel"`
+	GRPC             GRPCConfig               `yaml:"grpc"`
+	ExpiryAlerts     ExpiryConfig             `yaml:"expiry_alerts"`
+	Takeover         TakeoverConfig           `yaml:"takeover"`
+	Enrichment       EnrichConfig             `yaml:"enrichment"`
+	GeoIP            GeoIPConfig              `yaml:"geoip"`
+	Shodan           ShodanConfig             `yaml:"shodan"`
+	Censys           CensysConfig             `yaml:"censys"`
+	Reputation       ReputationConfig         `yaml:"reputation"`
+	CodeSearch       CodeSearchConfig         `yaml:"code_search"`
+	PortScan         PortScanConfig           `yaml:"port_scan"`
+	Rescan           RescanConfig             `yaml:"rescan"`
+	Assets           AssetConfig              `yaml:"assets"`
+	Netblocks        NetblockConfig           `yaml:"netblocks"`
+	Risk             RiskConfig               `yaml:"risk"`
+	Severity         SeverityConfig           `yaml:"severity"`
+	NotifyRetry      RetryConfig              `yaml:"notify_retry"`
+	IssuerPolicy     IssuerPolicyConfig       `yaml:"issuer_policy"`
+	CAA              CAAConfig                `yaml:"caa"`
+	CatchUp          CatchUpConfig            `yaml:"catch_up"`
+	Freshness        FreshnessConfig          `yaml:"freshness"`
+	HTTPProbe        ProbeConfig              `yaml:"http_probe"`
+	MultiVantage     VantageConfig            `yaml:"multi_vantage"`
+	Screenshots      ScreenshotConfig         `yaml:"screenshots"`
+	Cleanup          CleanupConfig            `yaml:"cleanup"`
+	EventLog         EventLogConfig           `yaml:"event_log"`
+	OrgExpansion     OrgExpansionConfig       `yaml:"org_expansion"`
+	LowResource      LowResourceConfig        `yaml:"low_resource"`
+	Escalation       EscalationConfig         `yaml:"escalation"`
+	IssuanceReport   IssuanceConfig           `yaml:"issuance_report"`
+	CertEvidence     EvidenceConfig           `yaml:"cert_evidence"`
+	Permutations     PermutationConfig        `yaml:"permutations"`
+	Storage          StorageConfig            `yaml:"storage"`
+	Graph            GraphConfig              `yaml:"graph"`
+	Reports          ReportConfig             `yaml:"reports"`
+	SummarySchedule  string                   `yaml:"summary_schedule"`  // Cron expression for the daily summary, default "1 0 * * *"
+	SummaryTimezone  string                   `yaml:"summary_timezone"`  // IANA zone schedules run in, default local time
+	ScheduledReports []ScheduledReport        `yaml:"scheduled_reports"` // Further summaries on their own schedules
 }
 
 var customConfigPath string
 
+// configDirOverride replaces the per-user config directory when running as a service
+var configDirOverride string
+
 func setConfigPath(path string) {
 	customConfigPath = path
 }
 
 func getConfigDir() (string, error) {
+	if configDirOverride != "" {
+		return configDirOverride, nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
@@ -66,20 +125,243 @@ webhook: ""
 telegram_bot_token: ""
 telegram_chat_id: ""
 
+# ntfy push notifications (optional) - priority follows the batch risk score
+ntfy:
+  topic_url: ""                  # e.g. https://ntfy.sh/my-crtmon-topic
+  token: ""                      # access token, or use username/password
+  username: ""
+  password: ""
+
+# log output (optional) - json writes one object per line for Loki/ELK
+logging:
+  format: text                   # text or json
+  level: debug                   # debug, info, warn or error
+
 # target wildcard to monitor
 targets:
 
+# domains that should never generate alerts (optional)
+# globs like "*.dev.example.com", or regexes wrapped in slashes like "/^ci-[0-9]+\\./"
+exclusions:
+  global: []
+  targets: {}
+
+# when a domain seen again is notified again, and when noisy domains are blacklisted
+# also adjustable from the admin panel
+dedup:
+  cooldown_hours: 168            # minimum time between notifications for a domain
+  max_per_day: 1                 # notifications per domain per day
+  blacklist_hits_per_day: 10     # days with more hits than this count towards blacklisting
+  blacklist_days: 3              # consecutive such days before a domain is blacklisted
+
+# resolvers and cache for dns lookups of new domains
+dns:
+  resolvers: []                  # e.g. ["1.1.1.1", "9.9.9.9:53", "cloudflare"]; empty uses the system resolver
+  positive_ttl_minutes: 60       # how long a resolving domain is trusted
+  negative_ttl_minutes: 60       # how long a failed lookup is remembered
+  cache_size: 100000             # least recently used results are dropped first
+  persist: false                 # keep the cache across restarts
+
 # Multiple webhook URLs for different message types (optional)
 webhooks:
   new_domains_webhook: ""        # New domain discoveries
   subdomain_scans_webhook: ""    # Subdomain enumeration results
   directory_scans_webhook: ""    # Directory enumeration results
   daily_summary_webhook: ""      # Daily summary
+  nuclei_findings_webhook: ""    # Nuclei vulnerability findings
+  edit_discovery_message: false  # Append scan results to the original discovery message
+
+# certificate expiry alerts (optional)
+expiry_alerts:
+  enabled: false
+  warn_days: 14
+  webhook: ""                    # defaults to the main webhook
+
+# flag domains whose CNAME points at an unclaimed S3 bucket, GitHub Pages site or Azure resource (optional)
+takeover:
+  enabled: false
+  webhook: ""                    # defaults to the main webhook
+
+# tag resolved domains with their ASN and cloud provider (aws, gcp, azure, digitalocean) (optional)
+enrichment:
+  enabled: false
+
+# record the country of resolved addresses from a MaxMind GeoLite2 database (optional)
+geoip:
+  database: ""                   # e.g. /usr/share/GeoIP/GeoLite2-Country.mmdb
+  expected_countries: []         # e.g. ["US", "DE"]; domains elsewhere are labelled unexpected-country
+  # risk_points: 50              # points of unexpected-country, same as risk.points
+
+# look up open ports, banners and TLS details of resolved addresses in Shodan (optional)
+shodan:
+  enabled: false
+  api_key: ""
+  cache_hours: 24
+  notable_ports: {}              # extra port -> service flagged in notifications, e.g. {8080: "Jenkins"}
+
+# search Censys for certificates the CT feed missed or that predate monitoring (optional)
+censys:
+  api_id: ""
+  api_secret: ""
+  interval_hours: 24
+  max_pages: 5                   # pages of 100 certificates per target and search
+
+# check lookalike and high-risk domains with VirusTotal and urlscan.io (optional)
+reputation:
+  virustotal_api_key: ""
+  urlscan_api_key: ""
+  min_risk: 70                   # also check domains of any target scoring at least this
+  labels: []                     # also check domains with these risk labels
+  urlscan_visibility: unlisted   # public, unlisted or private
+  urlscan_wait: 30               # seconds to wait for a verdict before notifying
+
+# search GitHub and GitLab code for new domains with github_token and gitlab_token (optional)
+code_search:
+  enabled: false
+  webhook: ""                    # defaults to the main webhook
+  gitlab_url: ""                 # defaults to https://gitlab.com
+  max_results: 10                # files reported per domain and service
+
+# scan new live hosts for open ports before they are notified (optional)
+port_scan:
+  enabled: false
+  scanner: native                # native, naabu or nmap
+  top_ports: 100                 # most common ports scanned, up to 100
+  ports: []                      # scanned as well, e.g. [9200, 6379, 27017]
+  timeout: 60                    # seconds per host
+
+# probe and enumerate known live domains again on a cadence (optional)
+rescan:
+  enabled: false
+  interval_days: 7               # days between rescans of a domain
+  per_hour: 20                   # domains rescanned per hour, spread evenly
+  probe: true                    # http probe and port scan again
+  enumerate: true                # queue feroxbuster and nuclei again
+  targets: []                    # only these targets, default all
+
+# asset lifecycle: new -> live -> offline -> retired (optional alerts)
+assets:
+  retire_days: 30                # days offline, or never live, before an asset is retired
+  notify: []                     # e.g. ["offline", "online", "retired"]
+  webhook: ""                    # defaults to the main webhook
+
+# netblock targets (CIDR ranges or addresses) match IP SANs and names resolving into them
+netblocks:
+  resolve_names: true            # resolve names no domain target matches
+  rate_limit: 50                 # lookups per second
+  queue_size: 5000               # names waiting beyond this are skipped
+
+# risk scoring - points per label, thresholds, and custom label rules (optional)
+risk:
+  points: {}                     # e.g. {wildcard: 10, takeover-candidate: 80}
+  high_frequency_hits: 50
+  high_frequency_days: 2
+  status_anomaly_codes: 3
+  response_min_bytes: 100
+  response_max_bytes: 1000000
+  rules: []
+
+# alert when a certificate for a target comes from an unexpected CA (optional)
+issuer_policy:
+  allowed: {}                    # e.g. {example.com: ["DigiCert", "Let's Encrypt"]}; "*" for targets without a list
+  denied: {}                     # e.g. {"*": ["Some CA"]}; "*" applies to every target
+  webhook: ""                    # defaults to the main webhook
+
+# check new certificates against their domains' CAA records (optional)
+caa:
+  enabled: false
+  issuers: {}                    # extra issuer -> CAA identifiers, e.g. {"Internal CA": ["ca.example.com"]}
+  webhook: ""                    # defaults to the main webhook
+
+# read the entries CT logs received while crtmon was offline (optional)
+catch_up:
+  enabled: false
+  max_entries: 500000            # per log; older missed entries are skipped
+  max_hours: 48                  # no catch-up after longer downtime
+
+# target freshness (optional) - alert when a target sees no certificates for stale_days
+freshness:
+  alert_enabled: false
+  stale_days: 7
+
+# resolve new domains from several vantages and flag differing answers (optional)
+multi_vantage:
+  enabled: false
+  resolvers: ["system", "1.1.1.1", "https://dns.google/resolve"]  # ip[:port], "system" or a DoH JSON endpoint
+  timeout: 5                     # seconds
+
+# record addresses and ASNs of new domains for the asset graph (optional)
+graph:
+  enabled: false
+  asn_lookup: false              # origin ASNs via Team Cymru's DNS service
+
+# built-in http probing of newly resolved domains (optional)
+http_probe:
+  enabled: false
+  concurrency: 10
+  timeout: 10                    # seconds
+
+# headless chrome screenshots of new live subdomains (optional)
+screenshots:
+  enabled: false
+  chrome_path: "chromium"
+  output_dir: ""                 # defaults to ~/.config/crtmon/screenshots
+  timeout: 30                    # seconds
+  no_sandbox: false              # only for containers where chrome can't start with its sandbox
+
+# append every matched certificate to a JSONL file, independent of notification dedup (optional)
+event_log:
+  enabled: false
+  path: ""                       # defaults to ~/.config/crtmon/events.jsonl
+  max_size_mb: 100               # rotate at this size
+  max_backups: 5                 # events.jsonl.1 ... events.jsonl.5
+
+# cleanup of old tracking entries, scan output and caches (optional)
+cleanup:
+  interval_hours: 24
+  run_at: ""                     # daily off-peak time (HH:MM), overrides interval_hours
+  max_age_days: 30
+  scan_file_max_age_days: 7
+
+# surface sibling apexes from certificates issued to a verified organization (optional)
+org_expansion:
+  enabled: false
+  organizations: []              # e.g. [{name: "Example Inc", country: "US"}]
+
+# page on-call for high-risk discoveries (optional), separate from chat notifications
+escalation:
+  enabled: false
+  risk_threshold: 70
+  critical_labels: []            # e.g. ["issuer-change"], pages regardless of score
+  critical_apexes: []            # limit critical labels to these apexes, e.g. ["example.com"]
+  pagerduty_routing_key: ""      # Events API v2 integration key
+  opsgenie_api_key: ""
+  opsgenie_api_url: ""           # defaults to https://api.opsgenie.com
+
+# count certificates per day by issuing CA for each target (optional)
+issuance_report:
+  enabled: false
+  window_days: 30                # history used for each CA's daily average
+  spike_factor: 3                # flag a CA issuing 3x its daily average...
+  min_certs: 5                   # ...but only at 5 or more certificates a day
+
+# low-resource mode for Raspberry Pi-class hosts (optional)
+# smaller caches and buffers, no SNI dataset, one scan at a time; state stays in the usual JSON files
+low_resource:
+  enabled: false
+  memory_limit_mb: 256
+  resolve_cache_size: 2000
+  max_pending_domains: 500
 
 # admin panel configuration (optional)
 admin_panel:
   enabled: true
   port: 8080
+  public_url: ""                 # e.g. https://crtmon.example.com, adds dashboard links to notifications
+  pprof: false                   # expose /debug/pprof/ behind admin auth for bug reports
+  login_max_failures: 5          # failed logins from one IP before it is locked out
+  login_lockout_minutes: 15
+  allowed_cidrs: []              # e.g. ["10.0.0.0/8", "203.0.113.7"], empty allows any address
 
 # enumeration settings (optional)
 enumeration:
@@ -93,6 +375,22 @@ enumeration:
   rate_limit_trusted: 300
   scan_timeout: 3600
   notify_on_complete: true
+  max_concurrent_scans: 3
+  max_concurrent_per_tool: {}    # e.g. {feroxbuster: 2, puredns: 1, nuclei: 1}
+  max_queued_scans: 100
+  nuclei_path: ""                # set to enable nuclei scans of live subdomains
+  nuclei_tags: []                # e.g. ["cve", "exposure"]
+  nuclei_severity: []            # e.g. ["medium", "high", "critical"]
+  notify_all_findings: false     # repeat scans only send new findings and changed status codes
+
+# external tools run against notified domains, with {{domain}}, {{url}}, {{output}} and {{target}} replaced (optional)
+# on: new-domain, wildcard and/or high-risk (risk score of at least min_risk)
+tools: []
+#  - name: katana
+#    command: "katana -u {{url}} -silent -o {{output}}"
+#    on: [new-domain]
+#    timeout: 600
+#    webhook: ""                  # defaults to the main webhook
 `
 
 	return os.WriteFile(configPath, []byte(template), 0644)
@@ -105,7 +403,15 @@ func loadConfig() (*Config, error) {
 	}
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, nil
+		// Containers may be configured from the environment alone
+		if !hasEnvConfig() {
+			return nil, nil
+		}
+		var config Config
+		if err := applyOverrides(&config); err != nil {
+			return nil, err
+		}
+		return &config, nil
 	}
 
 	data, err := os.ReadFile(configPath)
@@ -117,6 +423,9 @@ func loadConfig() (*Config, error) {
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
+	if err := applyOverrides(&config); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -175,6 +484,7 @@ func updateWebhooksConfig(webhooks struct {
 	SubdomainScans string `json:"subdomain_scans"`
 	DirectoryScans string `json:"directory_scans"`
 	DailySummary   string `json:"daily_summary"`
+	NucleiFindings string `json:"nuclei_findings"`
 }) error {
 	configPath, err := getConfigPath()
 	if err != nil {
@@ -198,6 +508,7 @@ func updateWebhooksConfig(webhooks struct {
 	config.Webhooks.SubdomainScans = webhooks.SubdomainScans
 	config.Webhooks.DirectoryScans = webhooks.DirectoryScans
 	config.Webhooks.DailySummary = webhooks.DailySummary
+	config.Webhooks.NucleiFindings = webhooks.NucleiFindings
 
 	newData, err := yaml.Marshal(&config)
 	if err != nil {
@@ -212,7 +523,8 @@ func getConfig() *Config {
 	return globalConfig
 }
 
-// SaveConfig saves the current global config to file
+// SaveConfig saves the current global config to file, keeping values set from the
+// environment or secret sources out of it
 func SaveConfig() error {
 	if globalConfig == nil {
 		return fmt.Errorf("no config loaded")
@@ -223,10 +535,14 @@ func SaveConfig() error {
 		return err
 	}
 
-	data, err := yaml.Marshal(globalConfig)
+	data, err := yaml.Marshal(withoutOverrides(globalConfig))
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		return err
+	}
+	savedConfigHash.Store(configHash(data))
+	return nil
 }
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, ny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name, expr, from, want string
+	}{
+		{"daily", "0 9 * * *", "2024-01-10 09:00", "2024-01-11 09:00"},
+		{"later today", "30 18 * * *", "2024-01-10 09:00", "2024-01-10 18:30"},
+		{"step", "*/15 * * * *", "2024-01-10 09:07", "2024-01-10 09:15"},
+		{"month rollover", "0 0 1 * *", "2024-01-31 12:00", "2024-02-01 00:00"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00", "2028-02-29 00:00"},
+		{"names", "0 8 * jun mon", "2024-01-10 00:00", "2024-06-03 08:00"},
+		{"sunday as 7", "0 12 * * 7", "2024-01-10 00:00", "2024-01-14 12:00"},
+		// Both day fields restricted: either one matches
+		{"dom or dow", "0 0 15 * fri", "2024-01-10 00:00", "2024-01-12 00:00"},
+		{"dom or dow, dom first", "0 0 11 * fri", "2024-01-10 00:00", "2024-01-11 00:00"},
+		// One field starting with *: only the other restricts
+		{"dow only", "0 0 * * fri", "2024-01-10 00:00", "2024-01-12 00:00"},
+		{"dom only", "0 0 15 * *", "2024-01-10 00:00", "2024-01-15 00:00"},
+		{"stepped dom counts as *", "0 0 */10 * mon", "2024-01-01 12:00", "2024-03-11 00:00"},
+		// Clocks go forward at 02:00 on March 10th 2024, 02:30 doesn't exist
+		{"skipped time", "30 2 * * *", "2024-03-10 00:00", "2024-03-10 03:00"},
+		{"after skipped day", "30 2 * * *", "2024-03-10 03:00", "2024-03-11 02:30"},
+		{"hourly across gap", "0 * * * *", "2024-03-10 01:30", "2024-03-10 03:00"},
+		{"daily across gap", "0 9 * * *", "2024-03-09 09:00", "2024-03-10 09:00"},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr, ny)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := s.Next(at(tt.from))
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.from, got, want)
+		}
+	}
+
+	// Clocks go back at 02:00 on November 3rd 2024, 01:30 happens twice
+	s, _ := parseCron("30 1 * * *", ny)
+	first := s.Next(at("2024-11-03 00:00"))
+	if first.Hour() != 1 || first.Minute() != 30 {
+		t.Fatalf("first run at %s", first)
+	}
+	if next := s.Next(first); !next.Equal(at("2024-11-04 01:30")) {
+		t.Errorf("repeated hour ran again at %s", next)
+	}
+	// Minutes of a repeated hour that have already passed aren't run again
+	s, _ = parseCron("*/20 * * * *", ny)
+	if next := s.Next(at("2024-11-03 01:40")); next.Sub(at("2024-11-03 01:40")) != 80*time.Minute {
+		t.Errorf("after 01:40 EDT got %s, want 02:00 EST", next)
+	}
+
+	// Chile moves its clocks forward at midnight, on September 8th 2024 the day starts at 01:00
+	santiago, err := time.LoadLocation("America/Santiago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ = parseCron("0 0 * * *", santiago)
+	from := time.Date(2024, 9, 7, 12, 0, 0, 0, santiago)
+	if next := s.Next(from); !next.Equal(time.Date(2024, 9, 8, 1, 0, 0, 0, santiago)) {
+		t.Errorf("skipped midnight: got %s, want 01:00", next)
+	}
+	s, _ = parseCron("0 0 * * sun", santiago)
+	if next := s.Next(from); !next.Equal(time.Date(2024, 9, 8, 1, 0, 0, 0, santiago)) {
+		t.Errorf("skipped midnight on a matching day: got %s, want 01:00", next)
+	}
+
+	if s, _ := parseCron("0 0 30 2 *", ny); !s.Next(at("2024-01-01 00:00")).IsZero() {
+		t.Error("February 30th fired")
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		if _, err := parseCron(expr, time.UTC); err == nil {
+			t.Errorf("parseCron(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// daemonEnv marks the re-executed background process so it doesn't detach again
+const daemonEnv = "CRTMON_DAEMON"
+
+const (
+	// shutdownTimeout bounds how long pending notifications are flushed on exit
+	shutdownTimeout = 30 * time.Second
+	// scanShutdownTimeout bounds how long cancelled scans get to exit
+	scanShutdownTimeout = 15 * time.Second
+)
+
+// isDaemonChild reports whether this process was started by -daemon
+func isDaemonChild() bool {
+	return os.Getenv(daemonEnv) == "1"
+}
+
+// sdNotify sends a state update such as READY=1 to systemd. It does nothing
+// unless crtmon was started by a Type=notify unit.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// Abstract namespace sockets are passed with a leading @
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		logger.Debug("sd_notify failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// watchdogInterval returns how often to ping the systemd watchdog, half of
+// WatchdogSec, or 0 when the watchdog is off
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// writePIDFile records this process ID, refusing to start when the file belongs
+// to another running crtmon
+func writePIDFile(path string) error {
+	if data, err := os.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			return fmt.Errorf("crtmon is already running with pid %d (%s)", pid, path)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// runningInstance returns the pid of a crtmon running from configDir, or 0
+func runningInstance(configDir string) int {
+	data, err := os.ReadFile(filepath.Join(configDir, "crtmon.pid"))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid == os.Getpid() || !processRunning(pid) {
+		return 0
+	}
+	return pid
+}
+
+// removePIDFile deletes the PID file if it still holds this process ID
+func removePIDFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	os.Remove(path)
+}
+
+// gracefulShutdown cancels scans, delivers or spills pending notifications and
+// persists state so nothing discovered before the stop is lost
+func gracefulShutdown() {
+	sdNotify("STOPPING=1")
+
+	// Scans go first so flushed batches can't queue new ones
+	GetJobManager().Shutdown(scanShutdownTimeout)
+
+	notifier.FlushAll(shutdownTimeout)
+
+	if err := GetStatsTracker().Save(); err != nil {
+		logger.Error("failed to save stats", "error", err)
+	}
+	if issuanceTracker != nil {
+		if err := issuanceTracker.Save(); err != nil {
+			logger.Error("failed to save issuance counts", "error", err)
+		}
+	}
+
+	if dt := GetDomainTracker(); dt != nil {
+		if err := dt.Persist(); err != nil {
+			logger.Error("failed to save domain tracker", "error", err)
+		}
+	}
+	if err := checkpointStore.Save(); err != nil {
+		logger.Error("failed to save CT log checkpoints", "error", err)
+	}
+	if err := SaveResolveCache(); err != nil {
+		logger.Error("failed to save dns cache", "error", err)
+	}
+	if discoveryLog != nil {
+		discoveryLog.Close()
+	}
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// daemonize is not supported on Windows, where crtmon runs in the background as a service
+func daemonize(logPath string) (int, error) {
+	return 0, fmt.Errorf("-daemon is not supported on Windows, use: crtmon service install")
+}
+
+// processRunning reports whether a process with the given PID exists
+func processRunning(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == 259 // STILL_ACTIVE
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DedupConfig decides when a domain seen again is notified again, and when a domain
+// seen too often is blacklisted as noise
+type DedupConfig struct {
+	CooldownHours       int `yaml:"cooldown_hours" json:"cooldown_hours"`                 // Minimum time between notifications for a domain, default 168 (7 days)
+	MaxPerDay           int `yaml:"max_per_day" json:"max_per_day"`                       // Notifications per domain per calendar day, default 1
+	BlacklistHitsPerDay int `yaml:"blacklist_hits_per_day" json:"blacklist_hits_per_day"` // A day with more hits than this counts towards blacklisting, default 10
+	BlacklistDays       int `yaml:"blacklist_days" json:"blacklist_days"`                 // Consecutive such days before the domain is blacklisted, default 3
+}
+
+var dedupConfig *DedupConfig
+var dedupMutex sync.Mutex
+
+// SetDedupConfig sets the notification dedup policy
+func SetDedupConfig(cfg *DedupConfig) {
+	dedupMutex.Lock()
+	defer dedupMutex.Unlock()
+	if cfg.CooldownHours <= 0 {
+		cfg.CooldownHours = 7 * 24
+	}
+	if cfg.MaxPerDay <= 0 {
+		cfg.MaxPerDay = 1
+	}
+	if cfg.BlacklistHitsPerDay <= 0 {
+		cfg.BlacklistHitsPerDay = 10
+	}
+	if cfg.BlacklistDays <= 0 {
+		cfg.BlacklistDays = 3
+	}
+	dedupConfig = cfg
+}
+
+// GetDedupConfig returns a copy of the dedup policy, with defaults if none was set
+func GetDedupConfig() DedupConfig {
+	dedupMutex.Lock()
+	defer dedupMutex.Unlock()
+	if dedupConfig == nil {
+		return DedupConfig{CooldownHours: 7 * 24, MaxPerDay: 1, BlacklistHitsPerDay: 10, BlacklistDays: 3}
+	}
+	return *dedupConfig
+}
+
+// validate rejects policies that can't be applied as given
+func (cfg DedupConfig) validate() error {
+	if cfg.CooldownHours < 1 || cfg.MaxPerDay < 1 || cfg.BlacklistHitsPerDay < 1 || cfg.BlacklistDays < 1 {
+		return fmt.Errorf("cooldown_hours, max_per_day, blacklist_hits_per_day and blacklist_days must all be at least 1")
+	}
+	return nil
+}
+
+// updateDedupConfig writes the dedup policy to the config file
+func updateDedupConfig(dedup DedupConfig) error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	config.Dedup = dedup
+
+	newData, err := yaml.Marshal(&config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, newData, 0644)
+}
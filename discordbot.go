@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// discordAPIBase is the Discord REST API the bot talks to
+const discordAPIBase = "https://discord.com/api/v10"
+
+// Discord interaction and response types
+const (
+	interactionPing            = 1
+	interactionCommand         = 2
+	interactionResponsePong    = 1
+	interactionResponseMessage = 4
+	discordThreadExists        = 160004 // A thread was already started from the message
+)
+
+// discordMaxSignatureAge bounds how far an interaction's timestamp may be from now,
+// so a captured request can't be replayed later
+const discordMaxSignatureAge = 5 * time.Minute
+
+// DiscordBotConfig runs crtmon as a Discord bot: notifications are posted to a channel
+// with the bot token, scan results go to a thread on the discovery message, and
+// /crtmon slash commands arrive at the admin panel's interactions endpoint.
+type DiscordBotConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Token         string   `yaml:"token"`
+	ApplicationID string   `yaml:"application_id"`
+	PublicKey     string   `yaml:"public_key"`    // Verifies interactions, from the developer portal
+	ChannelID     string   `yaml:"channel_id"`    // Where notifications are posted
+	GuildID       string   `yaml:"guild_id"`      // Registers commands in one server, where they appear at once
+	AllowedUsers  []string `yaml:"allowed_users"` // User IDs allowed to change targets, default anyone permitted by the server
+}
+
+// discordInteraction is the part of an interaction crtmon reads
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string          `json:"name"`
+		Options []discordOption `json:"options"`
+	} `json:"data"`
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+}
+
+// discordOption is a subcommand or argument of a slash command
+type discordOption struct {
+	Name    string          `json:"name"`
+	Value   interface{}     `json:"value"`
+	Options []discordOption `json:"options"`
+}
+
+// discordUser identifies who ran a command
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+var discordBotConfig *DiscordBotConfig
+var discordBotMutex sync.Mutex
+
+// SetDiscordBotConfig sets the Discord bot configuration
+func SetDiscordBotConfig(cfg *DiscordBotConfig) {
+	discordBotMutex.Lock()
+	defer discordBotMutex.Unlock()
+	discordBotConfig = cfg
+	if cfg == nil {
+		return
+	}
+	cfg.Token = strings.TrimSpace(cfg.Token)
+	cfg.ChannelID = strings.TrimSpace(cfg.ChannelID)
+	cfg.PublicKey = strings.TrimSpace(cfg.PublicKey)
+}
+
+// GetDiscordBotConfig returns the Discord bot configuration
+func GetDiscordBotConfig() *DiscordBotConfig {
+	discordBotMutex.Lock()
+	defer discordBotMutex.Unlock()
+	return discordBotConfig
+}
+
+// isDiscordBotEnabled reports whether notifications go through the bot
+func isDiscordBotEnabled() bool {
+	cfg := GetDiscordBotConfig()
+	return cfg != nil && cfg.Enabled && cfg.Token != "" && cfg.ChannelID != ""
+}
+
+// discordConfigured reports whether Discord notifications have somewhere to go
+func discordConfigured() bool {
+	return webhookURL != "" || isDiscordBotEnabled()
+}
+
+// discordBotRequest calls the Discord API with the bot token and returns the response body
+func discordBotRequest(method, path string, body []byte, contentType string) ([]byte, error) {
+	cfg := GetDiscordBotConfig()
+	if cfg == nil || cfg.Token == "" {
+		return nil, fmt.Errorf("discord bot not configured")
+	}
+	bucket := "bot:" + path
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		req, err := http.NewRequest(method, discordAPIBase+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bot "+cfg.Token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := discordDo(req, bucket)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			backoff(providerDiscord, bucket, resp)
+			continue
+		case resp.StatusCode >= 300:
+			var apiErr struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(data, &apiErr)
+			return data, &discordAPIError{Status: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("discord API rate limited after retries")
+}
+
+// discordAPIError is an error response from the Discord API
+type discordAPIError struct {
+	Status  int
+	Code    int
+	Message string
+}
+
+func (e *discordAPIError) Error() string {
+	return fmt.Sprintf("discord API status %d: %s (code %d)", e.Status, e.Message, e.Code)
+}
+
+// postDiscordBotMessage posts a JSON or multipart message body to a channel and returns
+// the message ID
+func postDiscordBotMessage(channelID string, body []byte, contentType string) (string, error) {
+	data, err := discordBotRequest(http.MethodPost, "/channels/"+channelID+"/messages", body, contentType)
+	if err != nil {
+		return "", err
+	}
+	var msg struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(data, &msg)
+	return msg.ID, nil
+}
+
+// sendDiscordBotPayload posts a payload to the bot's notification channel
+func sendDiscordBotPayload(payload map[string]interface{}) (string, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return postDiscordBotMessage(GetDiscordBotConfig().ChannelID, jsonData, "application/json")
+}
+
+// postDiscordThreadReply posts a JSON or multipart message body in the thread on a
+// discovery message, starting the thread first if needed
+func postDiscordThreadReply(messageID, domain string, body []byte, contentType string) error {
+	cfg := GetDiscordBotConfig()
+	name := domain
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	thread, _ := json.Marshal(map[string]interface{}{
+		"name":                  name,
+		"auto_archive_duration": 1440,
+	})
+	_, err := discordBotRequest(http.MethodPost, "/channels/"+cfg.ChannelID+"/messages/"+messageID+"/threads", thread, "application/json")
+	if apiErr, ok := err.(*discordAPIError); err != nil && !(ok && apiErr.Code == discordThreadExists) {
+		return fmt.Errorf("failed to start thread: %w", err)
+	}
+
+	// A thread started from a message has the message's ID
+	_, err = postDiscordBotMessage(messageID, body, contentType)
+	return err
+}
+
+// RegisterDiscordCommands registers the /crtmon slash command, in the configured guild
+// if any, otherwise globally
+func RegisterDiscordCommands() error {
+	cfg := GetDiscordBotConfig()
+	if cfg == nil || !cfg.Enabled || cfg.ApplicationID == "" {
+		return nil
+	}
+	targetOption := []map[string]interface{}{
+		{"type": 3, "name": "target", "description": "Domain or keyword", "required": true},
+	}
+	commands := []map[string]interface{}{{
+		"name":        "crtmon",
+		"description": "Certificate transparency monitor",
+		// Manage Server by default; server admins can grant it to other roles
+		"default_member_permissions": "32",
+		"options": []map[string]interface{}{
+			{"type": 1, "name": "status", "description": "Show monitor status"},
+			{"type": 1, "name": "add-target", "description": "Start monitoring a target", "options": targetOption},
+			{"type": 1, "name": "remove-target", "description": "Stop monitoring a target", "options": targetOption},
+		},
+	}}
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+
+	path := "/applications/" + cfg.ApplicationID + "/commands"
+	if cfg.GuildID != "" {
+		path = "/applications/" + cfg.ApplicationID + "/guilds/" + cfg.GuildID + "/commands"
+	}
+	if _, err := discordBotRequest(http.MethodPut, path, body, "application/json"); err != nil {
+		return err
+	}
+	logger.Info("discord slash commands registered", "guild", cfg.GuildID)
+	return nil
+}
+
+// handleDiscordInteraction answers Discord slash commands. Discord signs every request,
+// so the route needs no admin session.
+func (as *AdminServer) handleDiscordInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := GetDiscordBotConfig()
+	if cfg == nil || !cfg.Enabled || cfg.PublicKey == "" {
+		http.Error(w, "discord bot not enabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if !verifyDiscordSignature(cfg.PublicKey, r.Header.Get("X-Signature-Ed25519"), r.Header.Get("X-Signature-Timestamp"), body) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{"type": interactionResponsePong}
+	if interaction.Type == interactionCommand {
+		response = map[string]interface{}{
+			"type": interactionResponseMessage,
+			"data": map[string]interface{}{"content": runDiscordCommand(cfg, interaction)},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// verifyDiscordSignature checks the Ed25519 signature Discord puts on interactions and
+// that its timestamp is recent
+func verifyDiscordSignature(publicKey, signature, timestamp string, body []byte) bool {
+	key, err := hex.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize || timestamp == "" {
+		return false
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(sent, 0)); age > discordMaxSignatureAge || age < -discordMaxSignatureAge {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(key), append([]byte(timestamp), body...), sig)
+}
+
+// runDiscordCommand runs a /crtmon subcommand and returns the reply
+func runDiscordCommand(cfg *DiscordBotConfig, interaction discordInteraction) string {
+	if interaction.Data.Name != "crtmon" || len(interaction.Data.Options) == 0 {
+		return "Unknown command."
+	}
+	user := interaction.User
+	if interaction.Member != nil {
+		user = &interaction.Member.User
+	}
+	if user == nil {
+		return "Unknown user."
+	}
+	sub := interaction.Data.Options[0]
+	target := ""
+	for _, opt := range sub.Options {
+		if opt.Name == "target" {
+			target, _ = opt.Value.(string)
+		}
+	}
+
+	switch sub.Name {
+	case "status":
+		return discordStatus()
+	case "add-target", "remove-target":
+		if !discordUserAllowed(cfg, user) {
+			return "You are not allowed to change targets."
+		}
+		// A value that isn't a string was left empty above
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return "A target is required."
+		}
+		var result BulkTargetResult
+		if sub.Name == "add-target" {
+			result = addTargets([]string{target})
+		} else {
+			result = removeTargets([]string{target})
+		}
+		logger.Info("targets changed via discord", "command", sub.Name, "target", target, "user", user.ID, "changed", result.Changed)
+		switch {
+		case len(result.Invalid) > 0:
+			return fmt.Sprintf("`%s` is not a valid target.", target)
+		case len(result.Changed) == 0 && sub.Name == "add-target" && len(result.Unchanged) > 0:
+			return fmt.Sprintf("`%s` is already monitored.", result.Unchanged[0])
+		case len(result.Changed) == 0 && sub.Name == "add-target":
+			return fmt.Sprintf("`%s` was not added.", target)
+		case len(result.Changed) == 0:
+			return fmt.Sprintf("`%s` is not monitored.", target)
+		case sub.Name == "add-target":
+			if sm := GetSNIManager(); sm != nil {
+				go sm.SearchSNIOnDemand(result.Changed[0])
+			}
+			return fmt.Sprintf("Now monitoring `%s` (%d targets).", result.Changed[0], len(targets))
+		default:
+			return fmt.Sprintf("Stopped monitoring `%s` (%d targets).", result.Changed[0], len(targets))
+		}
+	}
+	return "Unknown command."
+}
+
+// discordUserAllowed reports whether a user may change targets
+func discordUserAllowed(cfg *DiscordBotConfig, user *discordUser) bool {
+	if len(cfg.AllowedUsers) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	for _, id := range cfg.AllowedUsers {
+		if strings.TrimSpace(id) == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// discordStatus summarizes the running monitor for /crtmon status
+func discordStatus() string {
+	allDomains := GetDomainTracker().GetAllDomains()
+	last24h := 0
+	for _, entry := range allDomains {
+		if time.Since(entry.FirstSeen) < 24*time.Hour {
+			last24h++
+		}
+	}
+	activeLogs, disconnectedLogs := GetStatsTracker().GetCTLogHealth()
+	pending, _, _, _ := notifier.Depth()
+	running, queued := GetJobManager().Counts()
+
+	return fmt.Sprintf("**crtmon %s** up %s\nTargets: %d\nTracked domains: %d (%d new in 24h)\nCT logs: %d active, %d disconnected\nPending notifications: %d\nScans: %d running, %d queued",
+		version, formatDuration(time.Since(startTime)), len(targets), len(allDomains), last24h, activeLogs, disconnectedLogs, pending, running, queued)
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Doctor check outcomes
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// minSNIDiskSpace is the free space needed to download the SNI datasets
+const minSNIDiskSpace = 1 << 30
+
+// doctorCheck is a single line of the doctor report
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// runDoctor checks the environment crtmon depends on and prints a report.
+// It returns the process exit code: 1 when any check failed.
+func runDoctor() int {
+	var checks []doctorCheck
+
+	cfg, err := loadConfig()
+	configPath, _ := getConfigPath()
+	switch {
+	case err != nil:
+		checks = append(checks, doctorCheck{"config", doctorFail, err.Error()})
+	case cfg == nil:
+		checks = append(checks, doctorCheck{"config", doctorWarn, "no config file at " + configPath})
+	default:
+		checks = append(checks, doctorCheck{"config", doctorPass, configPath})
+	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	registerSecrets(cfg)
+	SetHTTPConfig(&cfg.HTTP)
+
+	checks = append(checks, checkCTStream())
+	checks = append(checks, checkDNS(cfg)...)
+	checks = append(checks, checkNotificationProviders(cfg)...)
+	checks = append(checks, checkMessageBus(cfg)...)
+	checks = append(checks, checkKafka(cfg)...)
+	checks = append(checks, checkMQTT(cfg)...)
+	checks = append(checks, checkShodanAPI(cfg)...)
+	checks = append(checks, checkCensysAPI(cfg)...)
+	checks = append(checks, checkReputationAPIs(cfg)...)
+	checks = append(checks, checkCodeSearchAPIs(cfg)...)
+	checks = append(checks, checkToolBinaries(cfg)...)
+	checks = append(checks, checkWordlists(cfg)...)
+	checks = append(checks, checkDiskSpace(cfg))
+	checks = append(checks, checkPermissions(configPath)...)
+
+	return printDoctorReport(checks)
+}
+
+// printDoctorReport prints each check and returns 1 if any failed
+func printDoctorReport(checks []doctorCheck) int {
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	fmt.Println()
+	failed, warned := 0, 0
+	for _, c := range checks {
+		var mark string
+		switch c.Status {
+		case doctorPass:
+			mark = successStyle.Render("✓")
+		case doctorWarn:
+			mark = warnStyle.Render("!")
+			warned++
+		default:
+			mark = errorStyle.Render("✗")
+			failed++
+		}
+		fmt.Printf("%s %-22s %s\n", mark, c.Name, dimStyle.Render(redactSecrets(c.Detail)))
+	}
+	fmt.Println()
+
+	if failed > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("%d check(s) failed, %d warning(s)", failed, warned)))
+		fmt.Println()
+		return 1
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("all checks passed, %d warning(s)", warned)))
+	fmt.Println()
+	return 0
+}
+
+// checkCTStream verifies the CT log list can be fetched
+func checkCTStream() doctorCheck {
+	logs, err := fetchLogList()
+	if err != nil {
+		return doctorCheck{"ct log list", doctorFail, err.Error()}
+	}
+	if len(logs) == 0 {
+		return doctorCheck{"ct log list", doctorFail, "no usable logs"}
+	}
+	return doctorCheck{"ct log list", doctorPass, fmt.Sprintf("%d usable logs", len(logs))}
+}
+
+// checkDNS verifies the system resolver and the first few configured puredns resolvers
+func checkDNS(cfg *Config) []doctorCheck {
+	var checks []doctorCheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, "example.com"); err != nil {
+		checks = append(checks, doctorCheck{"dns (system)", doctorFail, err.Error()})
+	} else {
+		checks = append(checks, doctorCheck{"dns (system)", doctorPass, "example.com resolves"})
+	}
+
+	if !cfg.Enumeration.EnableEnum || cfg.Enumeration.ResolversFile == "" {
+		return checks
+	}
+
+	file, err := os.Open(cfg.Enumeration.ResolversFile)
+	if err != nil {
+		return checks // Reported by checkWordlists
+	}
+	defer file.Close()
+
+	tested := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && tested < 3 {
+		server := strings.TrimSpace(scanner.Text())
+		if server == "" || strings.HasPrefix(server, "#") {
+			continue
+		}
+		tested++
+
+		name := "dns (" + server + ")"
+		if err := lookupWithServer(server, "example.com"); err != nil {
+			checks = append(checks, doctorCheck{name, doctorWarn, err.Error()})
+		} else {
+			checks = append(checks, doctorCheck{name, doctorPass, "example.com resolves"})
+		}
+	}
+	return checks
+}
+
+// lookupWithServer resolves a host through a specific DNS server
+func lookupWithServer(server, host string) error {
+	resolver := resolverForServer(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := resolver.LookupHost(ctx, host)
+	return err
+}
+
+// checkNotificationProviders verifies configured webhooks and bots without posting messages
+func checkNotificationProviders(cfg *Config) []doctorCheck {
+	var checks []doctorCheck
+
+	webhooks := []struct{ name, url string }{
+		{"discord webhook", cfg.Webhook},
+		{"new domains webhook", cfg.Webhooks.NewDomains},
+		{"subdomain webhook", cfg.Webhooks.SubdomainScans},
+		{"directory webhook", cfg.Webhooks.DirectoryScans},
+		{"summary webhook", cfg.Webhooks.DailySummary},
+		{"nuclei webhook", cfg.Webhooks.NucleiFindings},
+	}
+	for _, wh := range webhooks {
+		webhook := strings.TrimSpace(wh.url)
+		if webhook == "" || webhook == `""` {
+			continue
+		}
+		if !isDiscordWebhook(webhook) {
+			checks = append(checks, doctorCheck{wh.name, doctorWarn, "not a Discord webhook, not verified"})
+			continue
+		}
+		// GET on a Discord webhook returns its metadata without posting
+		checks = append(checks, checkHTTP(wh.name, webhook, nil))
+	}
+
+	if token := strings.TrimSpace(cfg.TelegramBotToken); token != "" {
+		checks = append(checks, checkHTTP("telegram bot", fmt.Sprintf("https://api.telegram.org/bot%s/getMe", token), nil))
+		if strings.TrimSpace(cfg.TelegramChatID) == "" {
+			checks = append(checks, doctorCheck{"telegram chat", doctorFail, "telegram_chat_id is not set"})
+		}
+	}
+
+	if topic := strings.TrimSpace(cfg.Ntfy.TopicURL); topic != "" {
+		checks = append(checks, checkHTTP("ntfy topic", strings.TrimSuffix(topic, "/")+"/auth", func(req *http.Request) {
+			setNtfyAuth(req, &cfg.Ntfy)
+		}))
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, doctorCheck{"notifications", doctorWarn, "no notification provider configured"})
+	}
+	return checks
+}
+
+// checkHTTP passes when a GET request returns 200
+func checkHTTP(name, rawURL string, prepare func(*http.Request)) doctorCheck {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return doctorCheck{name, doctorFail, err.Error()}
+	}
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		// Drop the URL from the error, it may embed a token
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return doctorCheck{name, doctorFail, err.Error()}
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return doctorCheck{name, doctorFail, fmt.Sprintf("status %d", resp.StatusCode)}
+	}
+	return doctorCheck{name, doctorPass, "reachable"}
+}
+
+// checkToolBinaries verifies external tools for enabled features are installed
+func checkToolBinaries(cfg *Config) []doctorCheck {
+	var tools []struct{ name, path string }
+
+	if cfg.Enumeration.EnableEnum {
+		tools = append(tools,
+			struct{ name, path string }{"feroxbuster", orDefault(cfg.Enumeration.FeroxbusterPath, "feroxbuster")},
+			struct{ name, path string }{"puredns", orDefault(cfg.Enumeration.PurednsPath, "puredns")},
+			struct{ name, path string }{"massdns", "massdns"}, // Required by puredns
+		)
+		if cfg.Enumeration.NucleiPath != "" {
+			tools = append(tools, struct{ name, path string }{"nuclei", cfg.Enumeration.NucleiPath})
+		}
+	}
+	if cfg.Screenshots.Enabled {
+		tools = append(tools, struct{ name, path string }{"chrome", orDefault(cfg.Screenshots.ChromePath, "chromium")})
+	}
+	for _, tool := range cfg.Tools {
+		if args, err := splitCommand(tool.Command); err == nil && len(args) > 0 && (tool.Enabled == nil || *tool.Enabled) {
+			tools = append(tools, struct{ name, path string }{"tool " + tool.Name, args[0]})
+		}
+	}
+	if scanner := strings.ToLower(strings.TrimSpace(cfg.PortScan.Scanner)); cfg.PortScan.Enabled && (scanner == portScanNaabu || scanner == portScanNmap) {
+		tools = append(tools, struct{ name, path string }{scanner, orDefault(cfg.PortScan.Path, scanner)})
+	}
+
+	var checks []doctorCheck
+	for _, tool := range tools {
+		path, err := exec.LookPath(tool.path)
+		if err != nil {
+			checks = append(checks, doctorCheck{tool.name, doctorFail, tool.path + " not found"})
+			continue
+		}
+		checks = append(checks, doctorCheck{tool.name, doctorPass, path})
+	}
+	return checks
+}
+
+// checkWordlists verifies enumeration wordlists and the resolvers file exist and are not empty
+func checkWordlists(cfg *Config) []doctorCheck {
+	if !cfg.Enumeration.EnableEnum {
+		return nil
+	}
+
+	files := []struct{ name, path string }{
+		{"dir wordlist", cfg.Enumeration.DirWordlist},
+		{"dns wordlist", cfg.Enumeration.DNSWordlist},
+		{"resolvers file", cfg.Enumeration.ResolversFile},
+	}
+
+	var checks []doctorCheck
+	for _, f := range files {
+		if f.path == "" {
+			checks = append(checks, doctorCheck{f.name, doctorWarn, "not configured"})
+			continue
+		}
+		info, err := os.Stat(f.path)
+		switch {
+		case err != nil:
+			checks = append(checks, doctorCheck{f.name, doctorFail, err.Error()})
+		case info.Size() == 0:
+			checks = append(checks, doctorCheck{f.name, doctorFail, f.path + " is empty"})
+		default:
+			checks = append(checks, doctorCheck{f.name, doctorPass, f.path})
+		}
+	}
+	return checks
+}
+
+// checkDiskSpace verifies there is room for the SNI datasets in the config directory
+func checkDiskSpace(cfg *Config) doctorCheck {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return doctorCheck{"disk space", doctorFail, err.Error()}
+	}
+
+	free, err := freeDiskSpace(existingParent(configDir))
+	if err != nil {
+		return doctorCheck{"disk space", doctorWarn, err.Error()}
+	}
+
+	detail := fmt.Sprintf("%.1f GiB free", float64(free)/(1<<30))
+	if cfg.LowResource.Enabled || (cfg.SNI.Enabled != nil && !*cfg.SNI.Enabled) {
+		return doctorCheck{"disk space", doctorPass, detail + " (SNI dataset disabled)"}
+	}
+	if free < minSNIDiskSpace {
+		return doctorCheck{"disk space", doctorWarn, detail + ", SNI datasets need about 1 GiB"}
+	}
+	return doctorCheck{"disk space", doctorPass, detail}
+}
+
+// checkPermissions verifies the config directory is writable and the config file is private
+func checkPermissions(configPath string) []doctorCheck {
+	var checks []doctorCheck
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		return []doctorCheck{{"config dir", doctorFail, err.Error()}}
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		checks = append(checks, doctorCheck{"config dir", doctorFail, err.Error()})
+	} else if f, err := os.CreateTemp(configDir, ".doctor-*"); err != nil {
+		checks = append(checks, doctorCheck{"config dir", doctorFail, "not writable: " + err.Error()})
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		checks = append(checks, doctorCheck{"config dir", doctorPass, configDir + " is writable"})
+	}
+
+	// The config holds webhook URLs and tokens
+	if info, err := os.Stat(configPath); err == nil && runtime.GOOS != "windows" {
+		if info.Mode().Perm()&0077 != 0 {
+			checks = append(checks, doctorCheck{"config permissions", doctorWarn, fmt.Sprintf("%s is %v, consider chmod 600", configPath, info.Mode().Perm())})
+		} else {
+			checks = append(checks, doctorCheck{"config permissions", doctorPass, info.Mode().Perm().String()})
+		}
+	}
+
+	return checks
+}
+
+// existingParent returns the closest existing directory of a path
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnrichConfig tags resolved domains with the networks hosting them
+type EnrichConfig struct {
+	Enabled bool `yaml:"enabled"` // Look up origin ASNs with Team Cymru's DNS service and tag cloud providers
+}
+
+// Hosting provider tags, and the filter value for domains hosted elsewhere
+const (
+	providerAWS          = "aws"
+	providerGCP          = "gcp"
+	providerAzure        = "azure"
+	providerDigitalOcean = "digitalocean"
+	providerOther        = "other"
+)
+
+// cloudASNs maps the ASNs announcing cloud provider ranges to their provider
+var cloudASNs = map[string]string{
+	"AS16509":  providerAWS,
+	"AS14618":  providerAWS,
+	"AS8987":   providerAWS,
+	"AS15169":  providerGCP,
+	"AS396982": providerGCP,
+	"AS19527":  providerGCP,
+	"AS8075":   providerAzure,
+	"AS8068":   providerAzure,
+	"AS14061":  providerDigitalOcean,
+}
+
+var enrichConfig *EnrichConfig
+var enrichMutex sync.Mutex
+
+// SetEnrichConfig sets the network enrichment configuration
+func SetEnrichConfig(cfg *EnrichConfig) {
+	enrichMutex.Lock()
+	defer enrichMutex.Unlock()
+	enrichConfig = cfg
+}
+
+// GetEnrichConfig returns the network enrichment configuration
+func GetEnrichConfig() *EnrichConfig {
+	enrichMutex.Lock()
+	defer enrichMutex.Unlock()
+	return enrichConfig
+}
+
+// isEnrichEnabled reports whether resolved domains are tagged with their ASNs and providers
+func isEnrichEnabled() bool {
+	cfg := GetEnrichConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// hostingProviders returns the providers hosting a domain's addresses, "other" for
+// addresses announced by any other network
+func hostingProviders(asns map[string]string) []string {
+	seen := make(map[string]bool)
+	for _, asn := range asns {
+		number, _, _ := strings.Cut(asn, " ")
+		provider, ok := cloudASNs[number]
+		if !ok {
+			provider = providerOther
+		}
+		seen[provider] = true
+	}
+	providers := make([]string, 0, len(seen))
+	for p := range seen {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// asnLabels returns the distinct ASNs of a domain's addresses, e.g. "AS13335 CLOUDFLARENET, US"
+func asnLabels(asns map[string]string) []string {
+	seen := make(map[string]bool)
+	var labels []string
+	for _, asn := range asns {
+		if !seen[asn] {
+			seen[asn] = true
+			labels = append(labels, asn)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// matchesNetwork reports whether a domain is hosted by a provider and announced by an ASN,
+// either of which may be empty to match anything
+func matchesNetwork(entry *DomainEntry, provider, asn string) bool {
+	if provider != "" {
+		found := false
+		for _, p := range entry.Providers {
+			if strings.EqualFold(p, provider) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if asn != "" {
+		asn = strings.ToUpper(asn)
+		if !strings.HasPrefix(asn, "AS") {
+			asn = "AS" + asn
+		}
+		for _, label := range entry.ASNs {
+			if number, _, _ := strings.Cut(label, " "); number == asn {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
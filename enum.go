@@ -2,13 +2,11 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,16 +15,23 @@ import (
 
 // EnumConfig holds enumeration configuration
 type EnumConfig struct {
-	EnableEnum         bool   `yaml:"enable_enum"`
-	FeroxbusterPath    string `yaml:"feroxbuster_path"`
-	PurednsPath        string `yaml:"puredns_path"`
-	DirWordlist        string `yaml:"dir_wordlist"`
-	DNSWordlist        string `yaml:"dns_wordlist"`
-	ResolversFile      string `yaml:"resolvers_file"`
-	RateLimit          int    `yaml:"rate_limit"`
-	RateLimitTrusted   int    `yaml:"rate_limit_trusted"`
-	ScanTimeout        int    `yaml:"scan_timeout"`
-	NotifyOnComplete   bool   `yaml:"notify_on_complete"`
+	EnableEnum           bool           `yaml:"enable_enum"`
+	FeroxbusterPath      string         `yaml:"feroxbuster_path"`
+	PurednsPath          string         `yaml:"puredns_path"`
+	DirWordlist          string         `yaml:"dir_wordlist"`
+	DNSWordlist          string         `yaml:"dns_wordlist"`
+	ResolversFile        string         `yaml:"resolvers_file"`
+	RateLimit            int            `yaml:"rate_limit"`
+	RateLimitTrusted     int            `yaml:"rate_limit_trusted"`
+	ScanTimeout          int            `yaml:"scan_timeout"`
+	NotifyOnComplete     bool           `yaml:"notify_on_complete"`
+	NucleiPath           string         `yaml:"nuclei_path"`
+	NucleiTags           []string       `yaml:"nuclei_tags"`             // Template tags to run (empty = nuclei defaults)
+	NucleiSeverity       []string       `yaml:"nuclei_severity"`         // Severities to report (empty = all)
+	MaxConcurrentScans   int            `yaml:"max_concurrent_scans"`    // Across all tools
+	MaxConcurrentPerTool map[string]int `yaml:"max_concurrent_per_tool"` // e.g. feroxbuster: 2
+	MaxQueuedScans       int            `yaml:"max_queued_scans"`        // Further scans are rejected
+	NotifyAllFindings    bool           `yaml:"notify_all_findings"`     // Send every finding, not only new or changed ones
 }
 
 var enumConfig *EnumConfig
@@ -39,6 +44,20 @@ func SetEnumConfig(cfg *EnumConfig) {
 	enumConfig = cfg
 }
 
+// isNucleiEnabled reports whether nuclei scanning is configured
+func isNucleiEnabled() bool {
+	enumMutex.Lock()
+	defer enumMutex.Unlock()
+	return enumConfig != nil && enumConfig.EnableEnum && enumConfig.NucleiPath != ""
+}
+
+// isEnumEnabled reports whether enumeration is configured and enabled
+func isEnumEnabled() bool {
+	enumMutex.Lock()
+	defer enumMutex.Unlock()
+	return enumConfig != nil && enumConfig.EnableEnum
+}
+
 // IsWildcardDomain checks if domain matches wildcard pattern
 func IsWildcardDomain(domain string) bool {
 	return strings.HasPrefix(domain, "*.")
@@ -54,32 +73,22 @@ func ExtractBaseDomain(domain string) string {
 
 // RunFeroxbuster runs feroxbuster on a subdomain for directory enumeration
 func RunFeroxbuster(domain string, target string) (string, error) {
-	enumMutex.Lock()
-	if enumConfig == nil || !enumConfig.EnableEnum || enumConfig.FeroxbusterPath == "" {
-		enumMutex.Unlock()
+	cfg, enabled := targetEnumConfig(target)
+	if !enabled || cfg.FeroxbusterPath == "" {
 		return "", fmt.Errorf("feroxbuster not configured")
 	}
-	cfg := *enumConfig
-	enumMutex.Unlock()
 
 	// Construct the feroxbuster command
 	url := fmt.Sprintf("https://%s/", domain)
 	outputFile := fmt.Sprintf("%s.ferox.txt", strings.ReplaceAll(domain, ".", "_"))
 
-	// Track scan start
-	st := GetStatsTracker()
-	st.IncrementActiveFeroxScans()
-	defer func() {
-		// Track scan completion (success determined by error return)
-		st.DecrementActiveFeroxScans(true)
-	}()
-
 	args := []string{
 		"--url", url,
 		"--wordlist", cfg.DirWordlist,
 		"-A",
 		"--rate-limit", fmt.Sprintf("%d", cfg.RateLimit),
 		"-o", outputFile,
+		"--json", // One JSON object per line, parsed into findings
 		"-g",
 		"-x", "html,js,xml,json,config,env,txt",
 		"-E",
@@ -87,36 +96,20 @@ func RunFeroxbuster(domain string, target string) (string, error) {
 		"-s", "200,301,302,400",
 	}
 
-	// Start the scan in a screen session
-	screenName := fmt.Sprintf("enum_%s", strings.ReplaceAll(domain, ".", "_"))
-	screenCmd := exec.Command("screen", "-S", screenName, "-d", "-m", cfg.FeroxbusterPath)
-	screenCmd.Args = append(screenCmd.Args, args...)
-
-	if err := screenCmd.Run(); err != nil {
-		return "", fmt.Errorf("failed to start feroxbuster in screen: %w", err)
+	// Queue the scan; results are sent to Discord when it completes
+	if _, err := GetJobManager().Submit("feroxbuster", domain, target, cfg.FeroxbusterPath, args, outputFile, cfg.ScanTimeout); err != nil {
+		return "", err
 	}
 
-	logger.Info("started feroxbuster scan", "domain", domain, "screen", screenName, "output", outputFile)
-
-	// Read output file asynchronously and send to Discord when complete
-	go asyncReadAndSendScanResults(target, domain, outputFile, "feroxbuster", cfg.ScanTimeout)
-
 	return outputFile, nil
 }
 
 // RunPuredns runs puredns for DNS bruteforce on wildcard domains
 func RunPuredns(baseDomain string, target string) (string, error) {
-	enumMutex.Lock()
-	if enumConfig == nil || !enumConfig.EnableEnum || enumConfig.PurednsPath == "" {
-		enumMutex.Unlock()
+	cfg, enabled := targetEnumConfig(target)
+	if !enabled || cfg.PurednsPath == "" {
 		return "", fmt.Errorf("puredns not configured")
 	}
-	cfg := *enumConfig
-	enumMutex.Unlock()
-
-	// Track scan start
-	st := GetStatsTracker()
-	st.IncrementActivePurednsScans()
 
 	outputFile := fmt.Sprintf("%s.puredns.txt", strings.ReplaceAll(baseDomain, ".", "_"))
 
@@ -130,74 +123,45 @@ func RunPuredns(baseDomain string, target string) (string, error) {
 		"--write", outputFile,
 	}
 
-	// Start the scan in a screen session
-	screenName := fmt.Sprintf("dns_%s", strings.ReplaceAll(baseDomain, ".", "_"))
-	screenCmd := exec.Command("screen", "-S", screenName, "-d", "-m", cfg.PurednsPath)
-	screenCmd.Args = append(screenCmd.Args, args...)
-
-	if err := screenCmd.Run(); err != nil {
-		st.DecrementActivePurednsScans(false)
-		return "", fmt.Errorf("failed to start puredns in screen: %w", err)
+	// Queue the scan; results are sent to Discord when it completes
+	if _, err := GetJobManager().Submit("puredns", baseDomain, target, cfg.PurednsPath, args, outputFile, cfg.ScanTimeout); err != nil {
+		return "", err
 	}
 
-	logger.Info("started puredns scan", "domain", baseDomain, "screen", screenName, "output", outputFile)
-
-	// Read output file asynchronously and send to Discord when complete
-	go asyncReadAndSendScanResults(target, baseDomain, outputFile, "puredns", cfg.ScanTimeout)
-
 	return outputFile, nil
 }
 
-// asyncReadAndSendScanResults monitors a scan output file and sends results to Discord
-func asyncReadAndSendScanResults(target, domain, outputFile string, scanType string, timeoutSeconds int) {
-	timeout := time.Duration(timeoutSeconds) * time.Second
-	if timeout <= 0 {
-		timeout = 1 * time.Hour // Default 1 hour timeout
-	}
-
-	st := GetStatsTracker()
-	success := false
-	defer func() {
-		// Track scan completion based on type
-		if scanType == "puredns" {
-			st.DecrementActivePurednsScans(success)
-		} else if scanType == "feroxbuster" {
-			st.DecrementActiveFeroxScans(success)
-		}
-	}()
-
-	deadline := time.Now().Add(timeout)
-	var lastSize int64
+// RunNuclei runs a nuclei vulnerability scan against a live subdomain
+func RunNuclei(domain string, target string) (string, error) {
+	cfg, enabled := targetEnumConfig(target)
+	if !enabled || cfg.NucleiPath == "" {
+		return "", fmt.Errorf("nuclei not configured")
+	}
 
-	for {
-		if time.Now().After(deadline) {
-			logger.Warn("scan timeout reached", "domain", domain, "type", scanType, "file", outputFile)
-			sendScanResultsToDiscord(target, domain, outputFile, scanType, true)
-			success = false
-			return
-		}
+	url := fmt.Sprintf("https://%s", domain)
+	outputFile := fmt.Sprintf("%s.nuclei.txt", strings.ReplaceAll(domain, ".", "_"))
 
-		fileInfo, err := os.Stat(outputFile)
-		if err != nil {
-			time.Sleep(5 * time.Second)
-			continue
-		}
-
-		// Check if file size hasn't changed for 30 seconds (scan likely complete)
-		if fileInfo.Size() == lastSize && lastSize > 0 {
-			time.Sleep(30 * time.Second)
-			fileInfo2, _ := os.Stat(outputFile)
-			if fileInfo2.Size() == lastSize {
-				logger.Info("scan completed", "domain", domain, "type", scanType, "file", outputFile)
-				sendScanResultsToDiscord(target, domain, outputFile, scanType, false)
-				success = true
-				return
-			}
-		}
+	args := []string{
+		"-u", url,
+		"-o", outputFile,
+		"-jsonl", // One JSON object per line, parsed into findings
+		"-rate-limit", fmt.Sprintf("%d", cfg.RateLimit),
+		"-silent",
+		"-no-color",
+	}
+	if len(cfg.NucleiTags) > 0 {
+		args = append(args, "-tags", strings.Join(cfg.NucleiTags, ","))
+	}
+	if len(cfg.NucleiSeverity) > 0 {
+		args = append(args, "-severity", strings.Join(cfg.NucleiSeverity, ","))
+	}
 
-		lastSize = fileInfo.Size()
-		time.Sleep(10 * time.Second)
+	// Queue the scan; results are sent to Discord when it completes
+	if _, err := GetJobManager().Submit("nuclei", domain, target, cfg.NucleiPath, args, outputFile, cfg.ScanTimeout); err != nil {
+		return "", err
 	}
+
+	return outputFile, nil
 }
 
 // sendScanResultsToDiscord reads the scan output and sends it to Discord
@@ -218,6 +182,19 @@ func sendScanResultsToDiscord(target, domain, outputFile, scanType string, timed
 		   }
 	   }
 
+	   // Parsed results go to the findings store and are shown in place of raw JSON lines
+	   findings, results := parseScanFindings(scanType, domain, target, results)
+	   var changed []Finding
+	   if len(findings) > 0 {
+		   changed = GetFindingsStore().Record(findings)
+		   archiveKafkaFindings(findings)
+		   logger.Info("scan findings recorded", "domain", domain, "type", scanType, "findings", len(findings), "changed", len(changed))
+	   }
+	   // Subdomains from puredns and tools like subfinder are tracked, attributed to the scan
+	   if added := trackScanDomains(scanType, target, findings, results); len(added) > 0 {
+		   logger.Info("tracked subdomains found by scan", "domain", domain, "type", scanType, "added", len(added))
+	   }
+
 	   if len(results) == 0 && !timedOut {
 		   logger.Info("no results from scan", "domain", domain, "type", scanType)
 		   return
@@ -242,19 +219,36 @@ func sendScanResultsToDiscord(target, domain, outputFile, scanType string, timed
 		   }
 		   responseSize += len(line)
 	   }
-	   GetDomainTracker().RecordDomainMetadata(domain, statusCode, responseSize, lineCount, wordCount)
+	   // Nuclei findings and tool output are not HTTP responses, keep the probed metadata
+	   if scanType != "nuclei" && toolNamed(scanType) == nil {
+		   GetDomainTracker().RecordDomainMetadata(domain, statusCode, responseSize, lineCount, wordCount)
+	   }
 
 	   status := "Completed"
 	   if timedOut {
 		   status = "Timeout (results so far)"
 	   }
 
+	   // Repeat scans only report what is new or changed since the last one; all
+	   // findings stay in the findings store and the output file
+	   if cfg, _ := targetEnumConfig(target); len(findings) > 0 && !cfg.NotifyAllFindings {
+		   if len(changed) == 0 && !timedOut {
+		   	logger.Info("no new findings from scan", "domain", domain, "type", scanType, "findings", len(findings))
+		   	return
+		   }
+		   status += fmt.Sprintf(", %d new or changed of %d findings", len(changed), len(findings))
+		   results = make([]string, len(changed))
+		   for i, f := range changed {
+		   	results[i] = f.summary()
+		   }
+	   }
+
 	   sendScanResultsMessage(target, domain, scanType, status, results)
 }
 
 // sendScanResultsMessage sends the scan results as a Discord message
 func sendScanResultsMessage(target, domain, scanType, status string, results []string) {
-	if !notifyDiscord || webhookURL == "" {
+	if !notifyDiscord || !discordConfigured() {
 		return
 	}
 
@@ -263,7 +257,13 @@ func sendScanResultsMessage(target, domain, scanType, status string, results []s
 		results = []string{"No results found"}
 	}
 
-	chunks := chunkResults(results, 30) // Split into chunks of 30 results
+	// Upload long results as a file rather than many messages
+	if threshold := attachResultsThreshold(); threshold > 0 && len(strings.Join(results, "\n")) > threshold {
+		sendScanResultsFile(target, domain, scanType, status, results)
+		return
+	}
+
+	chunks := chunkByLength(results, maxBatchChars, resultLineLength)
 
 	for i, chunk := range chunks {
 		chunkInfo := ""
@@ -277,39 +277,142 @@ func sendScanResultsMessage(target, domain, scanType, status string, results []s
 				logger.Debug("failed to send directory scan to webhook", "domain", domain, "error", err)
 				// Fall back to main Discord webhook
 				payload := buildScanResultsPayload(target, domain, scanType, status+chunkInfo, chunk)
-				sendDiscordPayload(payload)
+				sendScanPayload(domain, payload)
+			}
+		} else if scanType == "nuclei" {
+			if err := SendNucleiFindings(domain, chunk); err != nil {
+				logger.Debug("failed to send nuclei findings to webhook", "domain", domain, "error", err)
+				// Fall back to main Discord webhook
+				payload := buildScanResultsPayload(target, domain, scanType, status+chunkInfo, chunk)
+				sendScanPayload(domain, payload)
 			}
 		} else if scanType == "puredns" {
 			if err := SendSubdomainScanResults(domain, chunk); err != nil {
 				logger.Debug("failed to send subdomain scan to webhook", "domain", domain, "error", err)
 				// Fall back to main Discord webhook
 				payload := buildScanResultsPayload(target, domain, scanType, status+chunkInfo, chunk)
-				sendDiscordPayload(payload)
+				sendScanPayload(domain, payload)
+			}
+		} else if webhook := scanResultsWebhook(scanType); webhook != "" {
+			payload := buildScanResultsPayload(target, domain, scanType, status+chunkInfo, chunk)
+			if err := SendToWebhook(webhook, payload); err != nil {
+				logger.Debug("failed to send tool results to webhook", "domain", domain, "type", scanType, "error", err)
+				// Fall back to main Discord webhook
+				sendScanPayload(domain, payload)
 			}
 		} else {
 			payload := buildScanResultsPayload(target, domain, scanType, status+chunkInfo, chunk)
-			sendDiscordPayload(payload)
+			sendScanPayload(domain, payload)
 		}
+	}
+}
+
+// sendScanResultsFile uploads scan results as a text file with a short summary embed,
+// to Discord and, when the target notifies there, Telegram
+func sendScanResultsFile(target, domain, scanType, status string, results []string) {
+	name := fmt.Sprintf("%s-%s.txt", scanType, strings.ReplaceAll(domain, "*", "wildcard"))
+	data := []byte(strings.Join(results, "\n") + "\n")
+	if len(data) > maxAttachmentBytes {
+		data = append(data[:maxAttachmentBytes], []byte("\n... (truncated)\n")...)
+	}
 
-		time.Sleep(500 * time.Millisecond) // Rate limit Discord sends
+	payload := buildScanFilePayload(domain, scanType, status, results)
+	if err := sendScanFile(domain, scanType, payload, name, data); err != nil {
+		logger.Error("failed to upload scan results", "domain", domain, "type", scanType, "error", err)
+		RecordError(errCategoryWebhook, fmt.Sprintf("%s results for %s: %v", scanType, domain, err))
+	}
+
+	if providersFor(target).telegram {
+		caption := fmt.Sprintf("%s scan: %s\n%d results (%s)", strings.ToUpper(scanType), domain, len(results), status)
+		if err := sendTelegramDocument(name, data, caption); err != nil {
+			logger.Error("failed to upload scan results to telegram", "domain", domain, "type", scanType, "error", err)
+		}
 	}
 }
 
-// chunkResults splits results into smaller chunks
-func chunkResults(results []string, chunkSize int) [][]string {
-	if len(results) == 0 {
-		return nil
+// sendScanFile posts a payload with a file to the scan type's webhook, falling back
+// to the discovery thread in bot mode or the main webhook
+func sendScanFile(domain, scanType string, payload map[string]interface{}, name string, data []byte) error {
+	body, contentType, err := attachDiscordFile(payload, name, data)
+	if err != nil {
+		return err
+	}
+
+	if webhook := scanResultsWebhook(scanType); webhook != "" {
+		_, err := sendWebhookBody(webhook, body, contentType)
+		if err == nil {
+			return nil
+		}
+		logger.Debug("failed to upload scan results to webhook", "domain", domain, "type", scanType, "error", err)
 	}
 
-	var chunks [][]string
-	for i := 0; i < len(results); i += chunkSize {
-		end := i + chunkSize
-		if end > len(results) {
-			end = len(results)
+	if isDiscordBotEnabled() {
+		if messageID := discoveryMessageID(domain); messageID != "" {
+			err := postDiscordThreadReply(messageID, domain, body, contentType)
+			if err == nil {
+				return nil
+			}
+			logger.Debug("failed to post scan results in discovery thread", "domain", domain, "error", err)
 		}
-		chunks = append(chunks, results[i:end])
+		_, err := postDiscordBotMessage(GetDiscordBotConfig().ChannelID, body, contentType)
+		return err
+	}
+
+	_, err = sendWebhookBody(webhookURL, body, contentType)
+	return err
+}
+
+// scanResultsWebhook returns the webhook configured for a scan type's results
+func scanResultsWebhook(scanType string) string {
+	cfg := GetWebhookConfig()
+	if cfg == nil {
+		return ""
+	}
+	switch scanType {
+	case "feroxbuster":
+		return cfg.DirectoryScans
+	case "nuclei":
+		return cfg.NucleiFindings
+	case "puredns":
+		return cfg.SubdomainScans
+	}
+	if tool := toolNamed(scanType); tool != nil {
+		return tool.Webhook
+	}
+	return ""
+}
+
+// buildScanFilePayload builds the Discord embed sent with an uploaded results file,
+// previewing the first lines
+func buildScanFilePayload(domain, scanType, status string, results []string) map[string]interface{} {
+	preview := results
+	if len(preview) > scanFilePreviewLines {
+		preview = preview[:scanFilePreviewLines]
+	}
+	previewText := strings.Join(preview, "\n")
+	if len(previewText) > 1000 {
+		previewText = previewText[:1000]
+	}
+
+	color := 3447003 // Blue
+	if status == "Timeout (results so far)" {
+		color = 16776960 // Yellow
+	}
+
+	return map[string]interface{}{
+		"tts": false,
+		"embeds": []map[string]interface{}{
+			{
+				"title":       fmt.Sprintf("%s Scan: %s", strings.ToUpper(scanType), domain),
+				"description": fmt.Sprintf("%d results, full output attached.\n```\n%s\n```", len(results), previewText),
+				"color":       color,
+				"footer": map[string]string{
+					"text": status,
+				},
+				"timestamp": time.Now().Format(time.RFC3339),
+			},
+		},
 	}
-	return chunks
 }
 
 // buildScanResultsPayload builds a Discord embed for scan results
@@ -343,8 +446,63 @@ func buildScanResultsPayload(target, domain, scanType, status string, results []
 	}
 }
 
-// sendDiscordPayload sends a payload to Discord webhook
+// sendScanPayload appends scan results to the original discovery message when
+// enabled, or replies in its thread in bot mode, falling back to posting a new
+// message on the main webhook
+func sendScanPayload(domain string, payload map[string]interface{}) error {
+	if isDiscordBotEnabled() {
+		if messageID := discoveryMessageID(domain); messageID != "" {
+			jsonData, _ := json.Marshal(payload)
+			err := postDiscordThreadReply(messageID, domain, jsonData, "application/json")
+			if err == nil {
+				return nil
+			}
+			logger.Debug("failed to post scan results in discovery thread", "domain", domain, "error", err)
+		}
+	}
+
+	if cfg := GetWebhookConfig(); cfg != nil && cfg.EditDiscoveryMessage {
+		embeds, _ := payload["embeds"].([]map[string]interface{})
+		if messageID := discoveryMessageID(domain); messageID != "" && len(embeds) > 0 {
+			err := AppendDiscordEmbed(webhookURL, messageID, embeds[0])
+			if err == nil {
+				return nil
+			}
+			logger.Debug("failed to append scan results to discovery message", "domain", domain, "error", err)
+		}
+	}
+
+	return sendDiscordPayload(payload)
+}
+
+// discoveryMessageID returns the Discord message a domain (or its wildcard) was notified in
+func discoveryMessageID(domain string) string {
+	dt := GetDomainTracker()
+	for _, d := range []string{domain, "*." + domain} {
+		if entry := dt.GetDomainInfo(d); entry != nil && entry.DiscordMessageID != "" {
+			return entry.DiscordMessageID
+		}
+	}
+	return ""
+}
+
+// sendDiscordPayload sends a payload to Discord webhook, or the bot's channel in bot
+// mode, queueing it for retry when that fails
 func sendDiscordPayload(payload map[string]interface{}) error {
+	err := postDiscordPayload(payload)
+	if err != nil {
+		GetRetryQueue().AddPayload("", payload, err)
+	}
+	return err
+}
+
+// postDiscordPayload sends a payload to Discord webhook, or the bot's channel in bot mode
+func postDiscordPayload(payload map[string]interface{}) error {
+	if isDiscordBotEnabled() {
+		_, err := sendDiscordBotPayload(payload)
+		return err
+	}
+
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("failed to marshal payload", "error", err)
@@ -352,7 +510,7 @@ func sendDiscordPayload(payload map[string]interface{}) error {
 	}
 
 	for attempt := 0; attempt < 3; attempt++ {
-		resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+		resp, err := discordPost(webhookURL, "application/json", jsonData)
 		if err != nil {
 			logger.Error("failed to send discord payload", "attempt", attempt+1, "error", err)
 			time.Sleep(time.Second * time.Duration(attempt+1))
@@ -364,15 +522,17 @@ func sendDiscordPayload(payload map[string]interface{}) error {
 			resp.Body.Close()
 			return nil
 		case http.StatusTooManyRequests:
+			backoff(providerDiscord, discordBucket(webhookURL), resp)
 			resp.Body.Close()
-			time.Sleep(2 * time.Second)
 		default:
 			bodyBytes, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 			logger.Error("discord returned error", "status", resp.StatusCode, "body", string(bodyBytes))
+			RecordError(errCategoryWebhook, fmt.Sprintf("discord returned status %d", resp.StatusCode))
 			return fmt.Errorf("discord returned status %d", resp.StatusCode)
 		}
 	}
 
+	RecordError(errCategoryWebhook, "failed to send discord payload after retries")
 	return fmt.Errorf("failed to send after retries")
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// envTargets lists targets separated by commas or whitespace
+const envTargets = "CRTMON_TARGETS"
+
+// configOverrides are the provider.yaml values that may come from outside the file:
+// an environment variable, or secret_files and secret_commands entries for the key
+var configOverrides = []struct {
+	key   string // Dotted yaml path
+	env   string
+	field func(*Config) *string
+}{
+	{"webhook", "CRTMON_WEBHOOK", func(c *Config) *string { return &c.Webhook }},
+	{"telegram_bot_token", "CRTMON_TELEGRAM_TOKEN", func(c *Config) *string { return &c.TelegramBotToken }},
+	{"telegram_chat_id", "CRTMON_TELEGRAM_CHAT_ID", func(c *Config) *string { return &c.TelegramChatID }},
+	{"ntfy.topic_url", "CRTMON_NTFY_TOPIC_URL", func(c *Config) *string { return &c.Ntfy.TopicURL }},
+	{"ntfy.token", "CRTMON_NTFY_TOKEN", func(c *Config) *string { return &c.Ntfy.Token }},
+	{"ntfy.username", "CRTMON_NTFY_USERNAME", func(c *Config) *string { return &c.Ntfy.Username }},
+	{"ntfy.password", "CRTMON_NTFY_PASSWORD", func(c *Config) *string { return &c.Ntfy.Password }},
+	{"message_bus.url", "CRTMON_MESSAGE_BUS_URL", func(c *Config) *string { return &c.MessageBus.URL }},
+	{"kafka.sasl.password", "CRTMON_KAFKA_SASL_PASSWORD", func(c *Config) *string { return &c.Kafka.SASL.Password }},
+	{"mqtt.url", "CRTMON_MQTT_URL", func(c *Config) *string { return &c.MQTT.URL }},
+	{"mqtt.username", "CRTMON_MQTT_USERNAME", func(c *Config) *string { return &c.MQTT.Username }},
+	{"mqtt.password", "CRTMON_MQTT_PASSWORD", func(c *Config) *string { return &c.MQTT.Password }},
+	{"discord_bot.token", "CRTMON_DISCORD_BOT_TOKEN", func(c *Config) *string { return &c.DiscordBot.Token }},
+	{"github_token", "CRTMON_GITHUB_TOKEN", func(c *Config) *string { return &c.GitHubToken }},
+	{"gitlab_token", "CRTMON_GITLAB_TOKEN", func(c *Config) *string { return &c.GitLabToken }},
+	{"webhooks.new_domains_webhook", "CRTMON_NEW_DOMAINS_WEBHOOK", func(c *Config) *string { return &c.Webhooks.NewDomains }},
+	{"webhooks.subdomain_scans_webhook", "CRTMON_SUBDOMAIN_SCANS_WEBHOOK", func(c *Config) *string { return &c.Webhooks.SubdomainScans }},
+	{"webhooks.directory_scans_webhook", "CRTMON_DIRECTORY_SCANS_WEBHOOK", func(c *Config) *string { return &c.Webhooks.DirectoryScans }},
+	{"webhooks.daily_summary_webhook", "CRTMON_DAILY_SUMMARY_WEBHOOK", func(c *Config) *string { return &c.Webhooks.DailySummary }},
+	{"webhooks.nuclei_findings_webhook", "CRTMON_NUCLEI_FINDINGS_WEBHOOK", func(c *Config) *string { return &c.Webhooks.NucleiFindings }},
+	{"escalation.pagerduty_routing_key", "CRTMON_PAGERDUTY_ROUTING_KEY", func(c *Config) *string { return &c.Escalation.PagerDutyRoutingKey }},
+	{"escalation.opsgenie_api_key", "CRTMON_OPSGENIE_API_KEY", func(c *Config) *string { return &c.Escalation.OpsgenieAPIKey }},
+	{"storage.access_key", "CRTMON_STORAGE_ACCESS_KEY", func(c *Config) *string { return &c.Storage.AccessKey }},
+	{"storage.secret_key", "CRTMON_STORAGE_SECRET_KEY", func(c *Config) *string { return &c.Storage.SecretKey }},
+	{"logging.format", "CRTMON_LOG_FORMAT", func(c *Config) *string { return &c.Logging.Format }},
+	{"logging.level", "CRTMON_LOG_LEVEL", func(c *Config) *string { return &c.Logging.Level }},
+	{"http.proxy", "CRTMON_HTTP_PROXY", func(c *Config) *string { return &c.HTTP.Proxy }},
+	{"shodan.api_key", "CRTMON_SHODAN_API_KEY", func(c *Config) *string { return &c.Shodan.APIKey }},
+	{"censys.api_id", "CRTMON_CENSYS_API_ID", func(c *Config) *string { return &c.Censys.APIID }},
+	{"censys.api_secret", "CRTMON_CENSYS_API_SECRET", func(c *Config) *string { return &c.Censys.APISecret }},
+	{"reputation.virustotal_api_key", "CRTMON_VIRUSTOTAL_API_KEY", func(c *Config) *string { return &c.Reputation.VirusTotalAPIKey }},
+	{"reputation.urlscan_api_key", "CRTMON_URLSCAN_API_KEY", func(c *Config) *string { return &c.Reputation.URLScanAPIKey }},
+}
+
+// fileConfig is provider.yaml as last read, before overrides. Saving the config
+// writes its values for overridden fields so secrets stay out of the file.
+var fileConfig *Config
+var overridden map[string]bool // Keys set from outside the file
+var envApplied []string
+var envMutex sync.Mutex
+
+// hasEnvConfig reports whether any configuration comes from the environment
+func hasEnvConfig() bool {
+	if os.Getenv(envTargets) != "" {
+		return true
+	}
+	for _, o := range configOverrides {
+		if os.Getenv(o.env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyOverrides loads the secrets configured in secret_files and secret_commands, then
+// replaces config values with the CRTMON_ environment variables that are set and not empty
+func applyOverrides(cfg *Config) error {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+
+	file := *cfg
+	fileConfig = &file
+	overridden = make(map[string]bool)
+	envApplied = nil
+
+	for _, o := range configOverrides {
+		value, ok, err := loadSecret(cfg, o.key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			*o.field(cfg) = value
+			overridden[o.key] = true
+		}
+	}
+	for _, o := range configOverrides {
+		if v := os.Getenv(o.env); v != "" {
+			*o.field(cfg) = v
+			overridden[o.key] = true
+			envApplied = append(envApplied, o.env)
+		}
+	}
+	if v := os.Getenv(envTargets); v != "" {
+		cfg.Targets = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n'
+		})
+		overridden["targets"] = true
+		envApplied = append(envApplied, envTargets)
+	}
+	return nil
+}
+
+// envOverrideNames returns the environment variables applied to the config
+func envOverrideNames() []string {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+	return append([]string(nil), envApplied...)
+}
+
+// withoutOverrides returns a copy of cfg with the values taken from the environment or
+// secret sources put back to those in provider.yaml
+func withoutOverrides(cfg *Config) *Config {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+	if fileConfig == nil || len(overridden) == 0 {
+		return cfg
+	}
+
+	out := *cfg
+	if overridden["targets"] {
+		out.Targets = fileConfig.Targets
+	}
+	for _, o := range configOverrides {
+		if overridden[o.key] {
+			*o.field(&out) = *o.field(fileConfig)
+		}
+	}
+	return &out
+}
+
+// restoreOverrides sets the values of cfg that come from the environment or secret
+// sources to those in src, undoing withoutOverrides
+func restoreOverrides(cfg, src *Config) {
+	envMutex.Lock()
+	defer envMutex.Unlock()
+	if overridden["targets"] {
+		cfg.Targets = src.Targets
+	}
+	for _, o := range configOverrides {
+		if overridden[o.key] {
+			*o.field(cfg) = *o.field(src)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Error categories surfaced in the admin panel
+const (
+	errCategoryWebhook = "webhook"
+	errCategoryScan    = "scan"
+	errCategorySNI     = "sni"
+	errCategoryStream  = "stream"
+	errCategoryStorage = "storage"
+	errCategoryBus     = "bus"
+	errCategoryKafka   = "kafka"
+	errCategoryMQTT    = "mqtt"
+)
+
+// maxRecentErrors bounds the error ring buffer
+const maxRecentErrors = 200
+
+// ErrorEvent is a single recorded error
+type ErrorEvent struct {
+	Category string    `json:"category"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+}
+
+// ErrorSummary aggregates errors per category
+type ErrorSummary struct {
+	Category    string    `json:"category"`
+	Count       int       `json:"count"`
+	LastSeen    time.Time `json:"last_seen"`
+	LastMessage string    `json:"last_message"`
+}
+
+type errorLog struct {
+	mu      sync.Mutex
+	events  []ErrorEvent // Ring buffer
+	next    int
+	summary map[string]*ErrorSummary // Counts survive ring buffer eviction
+}
+
+var recentErrors = &errorLog{
+	events:  make([]ErrorEvent, 0, maxRecentErrors),
+	summary: make(map[string]*ErrorSummary),
+}
+
+// RecordError adds an error to the ring buffer
+func RecordError(category, message string) {
+	recentErrors.mu.Lock()
+	defer recentErrors.mu.Unlock()
+
+	// Messages often wrap HTTP errors, which include the webhook or bot URL
+	message = redactSecrets(message)
+
+	event := ErrorEvent{Category: category, Message: message, Time: time.Now()}
+	if len(recentErrors.events) < maxRecentErrors {
+		recentErrors.events = append(recentErrors.events, event)
+	} else {
+		recentErrors.events[recentErrors.next] = event
+	}
+	recentErrors.next = (recentErrors.next + 1) % maxRecentErrors
+
+	s, exists := recentErrors.summary[category]
+	if !exists {
+		s = &ErrorSummary{Category: category}
+		recentErrors.summary[category] = s
+	}
+	s.Count++
+	s.LastSeen = event.Time
+	s.LastMessage = message
+}
+
+// GetRecentErrors returns buffered errors, newest first
+func GetRecentErrors() []ErrorEvent {
+	recentErrors.mu.Lock()
+	defer recentErrors.mu.Unlock()
+
+	result := make([]ErrorEvent, len(recentErrors.events))
+	copy(result, recentErrors.events)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result
+}
+
+// GetErrorSummary returns per-category counts, most recently seen first
+func GetErrorSummary() []ErrorSummary {
+	recentErrors.mu.Lock()
+	defer recentErrors.mu.Unlock()
+
+	var result []ErrorSummary
+	for _, s := range recentErrors.summary {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result
+}
+
+// ClearErrors empties the ring buffer and resets counts
+func ClearErrors() {
+	recentErrors.mu.Lock()
+	defer recentErrors.mu.Unlock()
+	recentErrors.events = recentErrors.events[:0]
+	recentErrors.next = 0
+	recentErrors.summary = make(map[string]*ErrorSummary)
+}
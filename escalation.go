@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// EscalationConfig holds on-call paging settings for high-risk discoveries
+type EscalationConfig struct {
+	Enabled             bool     `yaml:"enabled"`
+	RiskThreshold       int      `yaml:"risk_threshold"`        // Page when a domain's risk score reaches this
+	CriticalLabels      []string `yaml:"critical_labels"`       // Page on these risk labels regardless of score
+	CriticalApexes      []string `yaml:"critical_apexes"`       // Limit label matches to these apexes, empty matches all
+	PagerDutyRoutingKey string   `yaml:"pagerduty_routing_key"` // Events API v2 integration key
+	OpsgenieAPIKey      string   `yaml:"opsgenie_api_key"`
+	OpsgenieAPIURL      string   `yaml:"opsgenie_api_url"` // Defaults to https://api.opsgenie.com, EU accounts use https://api.eu.opsgenie.com
+}
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+var escalationConfig *EscalationConfig
+var escalationMutex sync.Mutex
+
+// SetEscalationConfig sets the escalation configuration
+func SetEscalationConfig(cfg *EscalationConfig) {
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+	escalationConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.RiskThreshold <= 0 {
+		cfg.RiskThreshold = 70
+	}
+	if cfg.OpsgenieAPIURL == "" {
+		cfg.OpsgenieAPIURL = "https://api.opsgenie.com"
+	}
+}
+
+// GetEscalationConfig returns the escalation configuration
+func GetEscalationConfig() *EscalationConfig {
+	escalationMutex.Lock()
+	defer escalationMutex.Unlock()
+	return escalationConfig
+}
+
+// shouldEscalate reports whether a domain matched under targets meets the paging criteria
+func shouldEscalate(domain string, targets []string, riskScore int, labels []string) bool {
+	cfg := GetEscalationConfig()
+	if cfg == nil || !cfg.Enabled {
+		return false
+	}
+	if riskScore >= escalationThresholdFor(targets, cfg.RiskThreshold) {
+		return true
+	}
+
+	if len(cfg.CriticalApexes) > 0 {
+		apex := extractRootDomain(domain)
+		matched := false
+		for _, a := range cfg.CriticalApexes {
+			if strings.EqualFold(strings.TrimSpace(a), apex) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, label := range labels {
+		for _, critical := range cfg.CriticalLabels {
+			if strings.EqualFold(label, critical) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Escalate pages the configured on-call providers about a high-risk domain
+func Escalate(domain string, riskScore int, labels []string) {
+	cfg := GetEscalationConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	if isDryRun() {
+		logger.Info("dry run: would escalate", "domain", domain, "risk_score", riskScore, "labels", labels)
+		return
+	}
+
+	logger.Warn("escalating high-risk domain", "domain", domain, "risk_score", riskScore, "labels", labels)
+
+	if cfg.PagerDutyRoutingKey != "" {
+		if err := sendPagerDutyEvent(cfg.PagerDutyRoutingKey, domain, riskScore, labels); err != nil {
+			logger.Error("failed to send pagerduty event", "domain", domain, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("pagerduty escalation for %s: %v", domain, err))
+		}
+	}
+
+	if cfg.OpsgenieAPIKey != "" {
+		if err := sendOpsgenieAlert(cfg.OpsgenieAPIURL, cfg.OpsgenieAPIKey, domain, riskScore, labels); err != nil {
+			logger.Error("failed to send opsgenie alert", "domain", domain, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("opsgenie escalation for %s: %v", domain, err))
+		}
+	}
+}
+
+// escalationSummary is the one-line incident title
+func escalationSummary(domain string, riskScore int, labels []string) string {
+	summary := fmt.Sprintf("crtmon: high-risk domain %s (risk %d)", domain, riskScore)
+	if len(labels) > 0 {
+		summary += " [" + strings.Join(labels, ", ") + "]"
+	}
+	return summary
+}
+
+// sendPagerDutyEvent triggers a PagerDuty Events API v2 incident, deduplicated per domain
+func sendPagerDutyEvent(routingKey, domain string, riskScore int, labels []string) error {
+	severity := "error"
+	if riskScore >= 90 {
+		severity = "critical"
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    "crtmon-" + domain,
+		"payload": map[string]interface{}{
+			"summary":  escalationSummary(domain, riskScore, labels),
+			"source":   domain,
+			"severity": severity,
+			"group":    extractRootDomain(domain),
+			"class":    "certificate-transparency",
+			"custom_details": map[string]interface{}{
+				"risk_score":  riskScore,
+				"risk_labels": labels,
+			},
+		},
+	}
+	if link := dashboardDomainLink(domain); link != "" {
+		event["links"] = []map[string]string{{"href": link, "text": "crtmon dashboard"}}
+	}
+
+	return postEscalation(pagerDutyEventsURL, "", event)
+}
+
+// sendOpsgenieAlert creates an Opsgenie alert, deduplicated per domain
+func sendOpsgenieAlert(apiURL, apiKey, domain string, riskScore int, labels []string) error {
+	priority := "P2"
+	if riskScore >= 90 {
+		priority = "P1"
+	}
+
+	description := fmt.Sprintf("Risk score: %d\nLabels: %s", riskScore, strings.Join(labels, ", "))
+	if link := dashboardDomainLink(domain); link != "" {
+		description += "\nDashboard: " + link
+	}
+
+	alert := map[string]interface{}{
+		"message":     escalationSummary(domain, riskScore, labels),
+		"alias":       "crtmon-" + domain,
+		"description": description,
+		"priority":    priority,
+		"source":      "crtmon",
+		"tags":        append([]string{"crtmon"}, labels...),
+		"details": map[string]string{
+			"domain": domain,
+			"apex":   extractRootDomain(domain),
+		},
+	}
+
+	return postEscalation(strings.TrimSuffix(apiURL, "/")+"/v2/alerts", "GenieKey "+apiKey, alert)
+}
+
+// postEscalation sends a JSON event and treats any 2xx response as accepted
+func postEscalation(url, authorization string, body map[string]interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
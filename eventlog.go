@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventLogConfig holds settings for the JSONL discovery event log
+type EventLogConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Path       string `yaml:"path"`        // Defaults to <config dir>/events.jsonl
+	MaxSizeMB  int    `yaml:"max_size_mb"` // Rotate when the file reaches this size
+	MaxBackups int    `yaml:"max_backups"` // Rotated files to keep (events.jsonl.1 is the newest)
+}
+
+// CertMatch is one matched domain of a certificate, including excluded and
+// duplicate ones
+type CertMatch struct {
+	Time        time.Time    `json:"time"`
+	Domain      string       `json:"domain"`
+	Target      string       `json:"target"`
+	Targets     []string     `json:"targets,omitempty"` // Every target credited when targets overlap
+	Excluded    bool         `json:"excluded"`
+	Duplicate   bool         `json:"duplicate"`
+	Resolves    bool         `json:"resolves"`
+	Notified    bool         `json:"notified"`
+	SubjectOrg  string       `json:"subject_org,omitempty"`
+	Source      string       `json:"source,omitempty"` // How the certificate arrived, e.g. "certstream"
+	Certificate *CertDetails `json:"certificate"`
+}
+
+// DiscoveryEvent is a CertMatch written as a JSON line
+type DiscoveryEvent struct {
+	CertMatch
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"` // Must stay last, see chainLine
+}
+
+// purgedEvent takes the place of an event of a purged target, so the chain still
+// shows a line was there
+type purgedEvent struct {
+	Time     time.Time `json:"time"`
+	Purged   bool      `json:"purged"`
+	PrevHash string    `json:"prev_hash"`
+	Hash     string    `json:"hash"`
+}
+
+// eventLog appends hash-chained events to a size-rotated file
+type eventLog struct {
+	mu       sync.Mutex
+	cfg      EventLogConfig
+	file     *os.File
+	size     int64
+	lastHash string // Hash of the last written event, carried across rotations
+}
+
+var discoveryLog *eventLog
+
+// SetEventLogConfig sets the event log configuration and opens the log file
+func SetEventLogConfig(cfg *EventLogConfig) {
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	if cfg.Path == "" {
+		configDir, _ := getConfigDir()
+		cfg.Path = filepath.Join(configDir, "events.jsonl")
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = 100
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = 5
+	}
+
+	l := &eventLog{cfg: *cfg}
+	if err := l.open(); err != nil {
+		logger.Error("failed to open event log", "path", cfg.Path, "error", err)
+		return
+	}
+	l.lastHash = lastChainHash(cfg.Path)
+	discoveryLog = l
+	logger.Info("discovery event log enabled", "path", cfg.Path, "max_size_mb", cfg.MaxSizeMB)
+}
+
+// LogDiscoveryEvents writes one line per matched domain, regardless of notification dedup
+func LogDiscoveryEvents(entry CertEntry, decisions []EntryDecision) {
+	if discoveryLog == nil || isDryRun() {
+		return
+	}
+	for _, match := range certMatches(entry, decisions) {
+		discoveryLog.write(DiscoveryEvent{CertMatch: match})
+	}
+}
+
+// certMatches returns the matched domains of a certificate
+func certMatches(entry CertEntry, decisions []EntryDecision) []CertMatch {
+	now := time.Now()
+	var details *CertDetails
+	var matches []CertMatch
+	for _, decision := range decisions {
+		if !decision.Matched {
+			continue
+		}
+		if details == nil {
+			details = entry.Details()
+		}
+		matches = append(matches, CertMatch{
+			Time:        now,
+			Domain:      decision.Domain,
+			Target:      decision.Target,
+			Targets:     decision.Targets,
+			Excluded:    decision.Excluded,
+			Duplicate:   decision.Duplicate,
+			Resolves:    decision.Resolves,
+			Notified:    decision.Notify,
+			SubjectOrg:  entry.SubjectOrg,
+			Source:      entry.Source,
+			Certificate: details,
+		})
+	}
+	return matches
+}
+
+// open opens the log file for appending and records its current size
+func (l *eventLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.cfg.Path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// write chains an event to the previous one and appends it, rotating first if
+// it would exceed the size limit
+func (l *eventLog) write(event DiscoveryEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+
+	event.PrevHash = l.lastHash
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	line, hash, err := chainLine(body)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	if l.size > 0 && l.size+int64(len(line)) > int64(l.cfg.MaxSizeMB)<<20 {
+		if err := l.rotate(); err != nil {
+			logger.Error("failed to rotate event log", "error", err)
+			RecordError(errCategoryStorage, "event log rotation: "+err.Error())
+			if l.file == nil {
+				return
+			}
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		logger.Error("failed to write event log", "error", err)
+		return
+	}
+	l.lastHash = hash
+}
+
+// Close flushes and closes the log file
+func (l *eventLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Sync()
+	l.file.Close()
+	l.file = nil
+	return err
+}
+
+// rotate shifts events.jsonl.N up by one, dropping the oldest, and starts a new file.
+// If the current file can't be moved aside it is reopened, so events keep being
+// written past the size limit instead of being dropped. Caller must hold l.mu.
+func (l *eventLog) rotate() error {
+	l.file.Close()
+	l.file = nil
+
+	oldest := fmt.Sprintf("%s.%d", l.cfg.Path, l.cfg.MaxBackups)
+	if line := lastFileLine(oldest); len(line) > 0 {
+		// Pruned events leave their last hash behind, so the chain still starts from a
+		// known value
+		if _, stored, ok := lineHash(line); ok {
+			if err := os.WriteFile(chainAnchorPath(l.cfg.Path), []byte(stored+"\n"), 0644); err != nil {
+				logger.Error("failed to save event log anchor", "error", err)
+				RecordError(errCategoryStorage, "event log anchor: "+err.Error())
+			}
+		}
+	}
+	os.Remove(oldest)
+	for i := l.cfg.MaxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.cfg.Path, i), fmt.Sprintf("%s.%d", l.cfg.Path, i+1))
+	}
+	if err := os.Rename(l.cfg.Path, l.cfg.Path+".1"); err != nil {
+		if openErr := l.open(); openErr != nil {
+			return fmt.Errorf("%w; reopening: %v", err, openErr)
+		}
+		return err
+	}
+	return l.open()
+}
+
+// hashSuffix is how every chained line ends before the hash is filled in
+const hashSuffix = `"hash":""}`
+
+// chainLine takes a JSON object whose last field is an empty "hash", hashes those
+// bytes and writes the hash into the field. Verification hashes the raw line the
+// same way, so it never depends on re-encoding the record.
+func chainLine(body []byte) ([]byte, string, error) {
+	if !bytes.HasSuffix(body, []byte(hashSuffix)) {
+		return nil, "", fmt.Errorf("hash is not the last field")
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	line := append(body[:len(body)-2], hash...)
+	return append(line, '"', '}'), hash, nil
+}
+
+// lineHash recomputes the hash of a chained line and returns it with the stored one
+func lineHash(line []byte) (computed, stored string, ok bool) {
+	const hashed = len(`"hash":"`) + sha256.Size*2 + len(`"}`)
+	if len(line) < hashed || !bytes.HasPrefix(line[len(line)-hashed:], []byte(`"hash":"`)) {
+		return "", "", false
+	}
+	stored = string(line[len(line)-hashed+len(`"hash":"`) : len(line)-2])
+	body := append(append([]byte{}, line[:len(line)-hashed]...), hashSuffix...)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), stored, true
+}
+
+// lastChainHash returns the hash of the newest event so a restart continues the chain,
+// falling back to the anchor when every file is empty
+func lastChainHash(path string) string {
+	for _, p := range []string{path, path + ".1"} {
+		if line := lastFileLine(p); len(line) > 0 {
+			_, stored, _ := lineHash(line)
+			return stored
+		}
+	}
+	return readChainAnchor(path)
+}
+
+// chainAnchorPath is where the hash of the last pruned event is kept, outside the log
+// files themselves
+func chainAnchorPath(path string) string {
+	return path + ".anchor"
+}
+
+// readChainAnchor returns the hash the oldest remaining event must link to: the last
+// pruned event's, or "" (the genesis value) when nothing was pruned
+func readChainAnchor(path string) string {
+	data, err := os.ReadFile(chainAnchorPath(path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// lastFileLine returns the final line of a file, reading only its tail
+func lastFileLine(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	const tail = 256 << 10 // Well above the size of one event
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	offset := info.Size() - tail
+	if offset < 0 {
+		offset = 0
+	}
+	data := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil
+	}
+	data = bytes.TrimRight(data, "\n")
+	return data[bytes.LastIndexByte(data, '\n')+1:]
+}
+
+// eventLogPath returns the path of the event log, configured or default
+func eventLogPath() string {
+	if discoveryLog != nil {
+		return discoveryLog.cfg.Path
+	}
+	if cfg := getConfig(); cfg != nil && cfg.EventLog.Path != "" {
+		return cfg.EventLog.Path
+	}
+	configDir, _ := getConfigDir()
+	return filepath.Join(configDir, "events.jsonl")
+}
+
+// eventTarget is what an event line says about the targets it credits
+type eventTarget struct {
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target"`
+	Targets []string  `json:"targets"`
+}
+
+// credits reports whether an event line credits a target
+func (e eventTarget) credits(target string) bool {
+	return e.Target == target || slices.Contains(e.Targets, target)
+}
+
+// countTargetEvents counts the lines crediting a target in the event log and its backups
+func countTargetEvents(target string) int {
+	count := 0
+	for _, path := range chainFiles(eventLogPath()) {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			var event eventTarget
+			if json.Unmarshal(line, &event) == nil && event.credits(target) {
+				count++
+			}
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+	}
+	return count
+}
+
+// purgeTargetEvents rewrites the event log and its backups with the lines crediting a
+// target replaced by purgedEvent tombstones, and chains every line again. The new
+// chain starts from an anchor derived from the old head hash and the target, which
+// ties it to the history it replaces. Returns the lines replaced.
+func purgeTargetEvents(target string) (int, error) {
+	if countTargetEvents(target) == 0 {
+		return 0, nil
+	}
+	path := eventLogPath()
+	if l := discoveryLog; l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.file != nil {
+			l.file.Close()
+			l.file = nil
+		}
+		defer func() {
+			if err := l.open(); err != nil {
+				logger.Error("failed to reopen event log after purge", "error", err)
+				RecordError(errCategoryStorage, "event log reopen: "+err.Error())
+			}
+			l.lastHash = lastChainHash(path)
+		}()
+	}
+
+	oldHead := lastChainHash(path)
+	sum := sha256.Sum256([]byte(oldHead + "\npurge " + target))
+	anchor := hex.EncodeToString(sum[:])
+	if err := os.WriteFile(chainAnchorPath(path), []byte(anchor+"\n"), 0644); err != nil {
+		return 0, err
+	}
+
+	prev, replaced := anchor, 0
+	for _, file := range chainFiles(path) {
+		n, err := rechainEventFile(file, target, &prev)
+		replaced += n
+		if err != nil {
+			return replaced, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	logger.Warn("purged target from event log", "target", target, "lines", replaced, "previous_head", oldHead, "anchor", anchor)
+	return replaced, nil
+}
+
+// rechainEventFile rewrites one log file for purgeTargetEvents, linking each line to
+// prev. Unchained lines from before chaining was added are chained as well.
+func rechainEventFile(file, target string, prev *string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+
+	var out bytes.Buffer
+	replaced := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var event eventTarget
+		if err := json.Unmarshal(line, &event); err != nil {
+			return replaced, fmt.Errorf("unparsable line: %w", err)
+		}
+
+		var body []byte
+		if event.credits(target) {
+			body, err = json.Marshal(purgedEvent{Time: event.Time, Purged: true, PrevHash: *prev})
+			if err != nil {
+				return replaced, err
+			}
+			replaced++
+		} else {
+			// Everything before the link fields is kept as it was written
+			fields := line[:len(line)-1]
+			if i := bytes.LastIndex(line, []byte(`,"prev_hash":"`)); i >= 0 {
+				fields = line[:i]
+			}
+			link, _ := json.Marshal(*prev)
+			body = append(append(append(append([]byte{}, fields...), `,"prev_hash":`...), link...), `,`+hashSuffix...)
+		}
+		chained, hash, err := chainLine(body)
+		if err != nil {
+			return replaced, err
+		}
+		out.Write(chained)
+		out.WriteByte('\n')
+		*prev = hash
+	}
+
+	if err := os.WriteFile(file+".tmp", out.Bytes(), 0644); err != nil {
+		return replaced, err
+	}
+	return replaced, os.Rename(file+".tmp", file)
+}
+
+// chainFiles returns the log and its rotated backups, oldest first
+func chainFiles(path string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		backup := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		files = append([]string{backup}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// verifyChain walks the log oldest first and returns the number of chained events and the
+// newest hash,
+// or an error naming the first line whose hash or link does not match. The first event
+// must link to the anchor, so removing lines from the head is caught too. Lines written
+// before chaining was added are counted as legacy and only allowed at the very start of
+// an unpruned log.
+func verifyChain(path string) (verified, legacy int, head string, err error) {
+	files := chainFiles(path)
+	if len(files) == 0 {
+		return 0, 0, "", fmt.Errorf("no event log at %s", path)
+	}
+
+	prev := readChainAnchor(path)
+	started := prev != ""
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return verified, legacy, prev, err
+		}
+		reader := bufio.NewReader(f)
+		for lineNo := 1; ; lineNo++ {
+			line, readErr := reader.ReadBytes('\n')
+			line = bytes.TrimRight(line, "\n")
+			if len(line) > 0 {
+				switch err := verifyLine(line, &prev, &started); {
+				case err == errLegacyLine:
+					legacy++
+				case err != nil:
+					f.Close()
+					return verified, legacy, prev, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+				default:
+					verified++
+				}
+			}
+			if readErr == io.EOF {
+				break
+			}
+			if readErr != nil {
+				f.Close()
+				return verified, legacy, prev, readErr
+			}
+		}
+		f.Close()
+	}
+	return verified, legacy, prev, nil
+}
+
+var errLegacyLine = errors.New("unchained line")
+
+// verifyLine checks one line against the previous hash and advances the chain
+func verifyLine(line []byte, prev *string, started *bool) error {
+	var link struct {
+		PrevHash string `json:"prev_hash"`
+		Hash     string `json:"hash"`
+	}
+	if err := json.Unmarshal(line, &link); err != nil {
+		return fmt.Errorf("unparsable line: %w", err)
+	}
+	computed, stored, ok := lineHash(line)
+	if !ok || link.Hash == "" {
+		if *started {
+			return fmt.Errorf("unchained line after the chain started")
+		}
+		return errLegacyLine
+	}
+	if computed != stored {
+		return fmt.Errorf("hash mismatch, line was modified")
+	}
+	if link.PrevHash != *prev {
+		if !*started {
+			return fmt.Errorf("first event doesn't link to the start of the chain, lines before it were removed")
+		}
+		return fmt.Errorf("broken link, a line before this one was removed or reordered")
+	}
+	*started = true
+	*prev = stored
+	return nil
+}
+
+// runVerifyLog implements crtmon verify-log, checking the event or audit log hash chain
+func runVerifyLog(args []string) int {
+	fs := flag.NewFlagSet("verify-log", flag.ContinueOnError)
+	logPath := fs.String("path", "", "event log to verify (defaults to event_log.path)")
+	verifyAudit := fs.Bool("audit", false, "verify the audit log instead of the event log")
+	verifyConfig := fs.String("config", "", "path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	if *logPath == "" && *verifyAudit {
+		if *verifyConfig != "" {
+			setConfigPath(*verifyConfig)
+		}
+		configDir, _ := getConfigDir()
+		*logPath = filepath.Join(configDir, auditLogFile)
+	}
+	if *logPath == "" {
+		if *verifyConfig != "" {
+			setConfigPath(*verifyConfig)
+		}
+		if cfg, err := loadConfig(); err == nil && cfg != nil && cfg.EventLog.Path != "" {
+			*logPath = cfg.EventLog.Path
+		} else {
+			configDir, _ := getConfigDir()
+			*logPath = filepath.Join(configDir, "events.jsonl")
+		}
+	}
+
+	verified, legacy, head, err := verifyChain(*logPath)
+	if legacy > 0 {
+		fmt.Printf("%d lines predate hash chaining and were not verified\n", legacy)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "verification failed after %d events: %v\n", verified, err)
+		return 1
+	}
+	fmt.Printf("ok: %d events verified in %s\n", verified, *logPath)
+	fmt.Printf("head: %s\n", head)
+	return 0
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestEventLog opens an event log in a temporary directory as the discovery log
+func newTestEventLog(t *testing.T, maxBackups int) *eventLog {
+	t.Helper()
+	l := &eventLog{cfg: EventLogConfig{
+		Enabled:    true,
+		Path:       filepath.Join(t.TempDir(), "events.jsonl"),
+		MaxSizeMB:  1,
+		MaxBackups: maxBackups,
+	}}
+	if err := l.open(); err != nil {
+		t.Fatal(err)
+	}
+	discoveryLog = l
+	t.Cleanup(func() {
+		l.Close()
+		discoveryLog = nil
+	})
+	return l
+}
+
+// writeTestEvents writes n events alternating between the targets, padded so a few
+// thousand of them fill a 1 MB file
+func writeTestEvents(l *eventLog, n int, targets ...string) {
+	for i := 0; i < n; i++ {
+		target := targets[i%len(targets)]
+		l.write(DiscoveryEvent{CertMatch: CertMatch{
+			Time:       time.Unix(int64(i), 0).UTC(),
+			Domain:     fmt.Sprintf("host%d.%s", i, target),
+			Target:     target,
+			SubjectOrg: strings.Repeat("x", 200),
+		}})
+	}
+}
+
+func TestPurgeTargetEvents(t *testing.T) {
+	l := newTestEventLog(t, 2)
+	// Enough to rotate past both backups, so the oldest lines are pruned
+	writeTestEvents(l, 12000, "a.example", "b.example")
+	if _, err := os.Stat(l.cfg.Path + ".2"); err != nil {
+		t.Fatal("log did not rotate twice")
+	}
+	if readChainAnchor(l.cfg.Path) == "" {
+		t.Fatal("pruning left no anchor")
+	}
+	verified, _, oldHead, err := verifyChain(l.cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := countTargetEvents("a.example")
+	replaced, err := purgeTargetEvents("a.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced != want || replaced == 0 {
+		t.Errorf("replaced %d lines, want %d", replaced, want)
+	}
+	if n := countTargetEvents("a.example"); n != 0 {
+		t.Errorf("%d lines of the purged target left", n)
+	}
+	if n := countTargetEvents("b.example"); n != verified-want {
+		t.Errorf("%d lines of the other target, want %d", n, verified-want)
+	}
+	for _, file := range chainFiles(l.cfg.Path) {
+		data, _ := os.ReadFile(file)
+		if strings.Contains(string(data), ".a.example") {
+			t.Fatalf("%s still names a purged domain", file)
+		}
+	}
+
+	after, _, head, err := verifyChain(l.cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != verified || head == oldHead {
+		t.Errorf("verified %d lines with head %s, want %d with a new head", after, head, verified)
+	}
+
+	// The log keeps appending to the new chain
+	writeTestEvents(l, 10, "b.example")
+	if _, _, _, err := verifyChain(l.cfg.Path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyChainAcrossRotation(t *testing.T) {
+	l := newTestEventLog(t, 2)
+	writeTestEvents(l, 12000, "a.example")
+	if _, err := os.Stat(l.cfg.Path + ".2"); err != nil {
+		t.Fatal("log did not rotate twice")
+	}
+	verified, legacy, head, err := verifyChain(l.cfg.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified == 0 || verified >= 12000 || legacy != 0 {
+		t.Fatalf("verified %d and %d legacy lines, want some pruned", verified, legacy)
+	}
+	if head != lastChainHash(l.cfg.Path) {
+		t.Errorf("head %s is not the last line's hash", head)
+	}
+
+	// Each change is undone before the next
+	intact := make(map[string][]byte)
+	for _, file := range append(chainFiles(l.cfg.Path), chainAnchorPath(l.cfg.Path)) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		intact[file] = data
+	}
+	restore := func() {
+		for file, data := range intact {
+			if err := os.WriteFile(file, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	editLines := func(file string, edit func(lines []string) []string) {
+		lines := strings.Split(strings.TrimSuffix(string(intact[file]), "\n"), "\n")
+		if err := os.WriteFile(file, []byte(strings.Join(edit(lines), "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+	}{
+		{"line modified in the oldest backup", func() {
+			editLines(l.cfg.Path+".2", func(lines []string) []string {
+				lines[5] = strings.Replace(lines[5], "host", "h0st", 1)
+				return lines
+			})
+		}},
+		{"first line after the anchor removed", func() {
+			editLines(l.cfg.Path+".2", func(lines []string) []string { return lines[1:] })
+		}},
+		{"line removed across a rotation", func() {
+			editLines(l.cfg.Path+".1", func(lines []string) []string { return lines[1:] })
+		}},
+		{"last line of a backup removed", func() {
+			editLines(l.cfg.Path+".1", func(lines []string) []string { return lines[:len(lines)-1] })
+		}},
+		{"lines reordered", func() {
+			editLines(l.cfg.Path, func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			})
+		}},
+		{"anchor replaced", func() {
+			os.WriteFile(chainAnchorPath(l.cfg.Path), []byte(strings.Repeat("0", 64)+"\n"), 0644)
+		}},
+		{"anchor and oldest backup removed", func() {
+			os.Remove(chainAnchorPath(l.cfg.Path))
+			os.Remove(l.cfg.Path + ".2")
+		}},
+	}
+	for _, tt := range tests {
+		tt.change()
+		if _, _, _, err := verifyChain(l.cfg.Path); err == nil {
+			t.Errorf("%s: chain verified", tt.name)
+		}
+		restore()
+	}
+	if _, _, _, err := verifyChain(l.cfg.Path); err != nil {
+		t.Fatalf("restored log: %v", err)
+	}
+}
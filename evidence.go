@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// EvidenceConfig holds settings for keeping raw certificates as evidence
+type EvidenceConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"` // Defaults to <config dir>/evidence
+}
+
+var evidenceConfig *EvidenceConfig
+var evidenceMutex sync.Mutex
+
+// SetEvidenceConfig sets the certificate evidence configuration
+func SetEvidenceConfig(cfg *EvidenceConfig) {
+	evidenceMutex.Lock()
+	defer evidenceMutex.Unlock()
+	evidenceConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.Dir == "" {
+		if configDir, err := getConfigDir(); err == nil {
+			cfg.Dir = filepath.Join(configDir, "evidence")
+		}
+	}
+}
+
+// GetEvidenceConfig returns the certificate evidence configuration
+func GetEvidenceConfig() *EvidenceConfig {
+	evidenceMutex.Lock()
+	defer evidenceMutex.Unlock()
+	return evidenceConfig
+}
+
+// StoreCertEvidence keeps the PEM of the first certificate seen for a domain and of
+// the most recently issued one. The first file is never overwritten, and an older
+// certificate showing up late in another log doesn't replace the latest.
+func StoreCertEvidence(domain string, entry CertEntry) {
+	cfg := GetEvidenceConfig()
+	if cfg == nil || !cfg.Enabled || len(entry.Raw) == 0 || isDryRun() {
+		return
+	}
+
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	// The name comes from the certificate, so it must not be able to leave cfg.Dir
+	if !isSafeHost(ExtractBaseDomain(d)) {
+		logger.Warn("not storing evidence for unusual domain name", "domain", d)
+		return
+	}
+	dir := filepath.Join(cfg.Dir, d)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		logger.Warn("failed to create evidence directory", "domain", d, "error", err)
+		return
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: entry.Raw})
+
+	first := filepath.Join(dir, "first.pem")
+	latest := filepath.Join(dir, "latest.pem")
+	f, err := os.OpenFile(first, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err == nil {
+		_, err = f.Write(data)
+		f.Close()
+	}
+	if err != nil && !os.IsExist(err) {
+		logger.Warn("failed to store certificate evidence", "domain", d, "error", err)
+		return
+	}
+
+	if newerThanLatest(latest, data, entry.NotBefore) {
+		err := os.WriteFile(latest+".tmp", data, 0600)
+		if err == nil {
+			err = os.Rename(latest+".tmp", latest)
+		}
+		if err != nil {
+			logger.Warn("failed to store certificate evidence", "domain", d, "error", err)
+			return
+		}
+	}
+
+	// Recorded every time so a domain pruned and later rediscovered gets its evidence back
+	GetDomainTracker().RecordDomainEvidence(d, first, latest)
+}
+
+// newerThanLatest reports whether a certificate should replace the stored latest PEM
+func newerThanLatest(latest string, data []byte, notBefore time.Time) bool {
+	existing, err := os.ReadFile(latest)
+	if err != nil {
+		return true
+	}
+	if bytes.Equal(existing, data) {
+		return false
+	}
+	if block, _ := pem.Decode(existing); block != nil {
+		// Parsing can return the certificate together with non-fatal errors
+		if cert, _ := x509.ParseCertificate(block.Bytes); cert != nil && notBefore.Before(cert.NotBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+// evidencePath returns the stored PEM for a domain, which is "first" or "latest"
+func evidencePath(entry *DomainEntry, which string) (string, error) {
+	switch which {
+	case "first":
+		if entry.EvidenceFirst != "" {
+			return entry.EvidenceFirst, nil
+		}
+	case "latest", "":
+		if entry.EvidenceLatest != "" {
+			return entry.EvidenceLatest, nil
+		}
+	default:
+		return "", fmt.Errorf("which must be first or latest")
+	}
+	return "", os.ErrNotExist
+}
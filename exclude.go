@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ExclusionConfig holds domain patterns that should never generate alerts.
+// Patterns are globs (e.g. "*.dev.example.com") unless wrapped in slashes,
+// in which case they are treated as regular expressions (e.g. "/^ci-[0-9]+\./").
+type ExclusionConfig struct {
+	Global  []string            `yaml:"global"`
+	Targets map[string][]string `yaml:"targets"`
+}
+
+var exclusionConfig *ExclusionConfig
+var exclusionMutex sync.RWMutex
+var exclusionRegexCache = make(map[string]*regexp.Regexp)
+
+// SetExclusionConfig sets the exclusion configuration
+func SetExclusionConfig(cfg *ExclusionConfig) {
+	exclusionMutex.Lock()
+	defer exclusionMutex.Unlock()
+	exclusionConfig = cfg
+	exclusionRegexCache = make(map[string]*regexp.Regexp)
+}
+
+// GetExclusionConfig returns a copy of the exclusion configuration
+func GetExclusionConfig() ExclusionConfig {
+	exclusionMutex.RLock()
+	defer exclusionMutex.RUnlock()
+
+	result := ExclusionConfig{Targets: make(map[string][]string)}
+	if exclusionConfig == nil {
+		return result
+	}
+	result.Global = append(result.Global, exclusionConfig.Global...)
+	for t, patterns := range exclusionConfig.Targets {
+		result.Targets[t] = append([]string(nil), patterns...)
+	}
+	return result
+}
+
+// IsExcluded checks whether a domain matches a global or per-target exclusion pattern
+func IsExcluded(domain, target string) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	t := strings.ToLower(strings.TrimSuffix(target, "."))
+
+	exclusionMutex.Lock()
+	defer exclusionMutex.Unlock()
+
+	if exclusionConfig == nil {
+		return false
+	}
+
+	for _, pattern := range exclusionConfig.Global {
+		if matchExclusion(pattern, d) {
+			return true
+		}
+	}
+
+	for key, patterns := range exclusionConfig.Targets {
+		if strings.ToLower(strings.TrimSuffix(key, ".")) != t {
+			continue
+		}
+		for _, pattern := range patterns {
+			if matchExclusion(pattern, d) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddExclusion adds a pattern globally, or for a target when target is non-empty
+func AddExclusion(pattern, target string) error {
+	pattern = strings.TrimSpace(pattern)
+	if err := validateExclusionPattern(pattern); err != nil {
+		return err
+	}
+
+	exclusionMutex.Lock()
+	defer exclusionMutex.Unlock()
+
+	if exclusionConfig == nil {
+		exclusionConfig = &ExclusionConfig{}
+	}
+
+	if target == "" {
+		for _, p := range exclusionConfig.Global {
+			if p == pattern {
+				return fmt.Errorf("exclusion already exists")
+			}
+		}
+		exclusionConfig.Global = append(exclusionConfig.Global, pattern)
+		return nil
+	}
+
+	if exclusionConfig.Targets == nil {
+		exclusionConfig.Targets = make(map[string][]string)
+	}
+	for _, p := range exclusionConfig.Targets[target] {
+		if p == pattern {
+			return fmt.Errorf("exclusion already exists")
+		}
+	}
+	exclusionConfig.Targets[target] = append(exclusionConfig.Targets[target], pattern)
+	return nil
+}
+
+// RemoveExclusion removes a pattern globally, or for a target when target is non-empty
+func RemoveExclusion(pattern, target string) bool {
+	exclusionMutex.Lock()
+	defer exclusionMutex.Unlock()
+
+	if exclusionConfig == nil {
+		return false
+	}
+
+	if target == "" {
+		for i, p := range exclusionConfig.Global {
+			if p == pattern {
+				exclusionConfig.Global = append(exclusionConfig.Global[:i], exclusionConfig.Global[i+1:]...)
+				return true
+			}
+		}
+		return false
+	}
+
+	patterns := exclusionConfig.Targets[target]
+	for i, p := range patterns {
+		if p == pattern {
+			patterns = append(patterns[:i], patterns[i+1:]...)
+			if len(patterns) == 0 {
+				delete(exclusionConfig.Targets, target)
+			} else {
+				exclusionConfig.Targets[target] = patterns
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// validateExclusionPattern checks that a glob or regex pattern is well-formed
+func validateExclusionPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("pattern cannot be empty")
+	}
+	if isRegexPattern(pattern) {
+		if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		return nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid glob: %w", err)
+	}
+	return nil
+}
+
+// isRegexPattern reports whether a pattern is wrapped in slashes
+func isRegexPattern(pattern string) bool {
+	return len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// matchExclusion matches a single pattern against a normalized domain.
+// Caller must hold exclusionMutex.
+func matchExclusion(pattern, domain string) bool {
+	if isRegexPattern(pattern) {
+		re, exists := exclusionRegexCache[pattern]
+		if !exists {
+			compiled, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				logger.Warn("invalid exclusion regex", "pattern", pattern, "error", err)
+			}
+			// Cache nil for invalid patterns so we only warn once
+			exclusionRegexCache[pattern] = compiled
+			re = compiled
+		}
+		return re != nil && re.MatchString(domain)
+	}
+
+	matched, err := path.Match(strings.ToLower(pattern), domain)
+	return err == nil && matched
+}
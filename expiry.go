@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExpiryConfig holds certificate expiry alert settings
+type ExpiryConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	WarnDays int    `yaml:"warn_days"`
+	Webhook  string `yaml:"webhook"` // Falls back to the main webhook when empty
+}
+
+var expiryConfig *ExpiryConfig
+var expiryMutex sync.Mutex
+
+// SetExpiryConfig sets the expiry alert configuration
+func SetExpiryConfig(cfg *ExpiryConfig) {
+	expiryMutex.Lock()
+	defer expiryMutex.Unlock()
+	expiryConfig = cfg
+	if cfg != nil && cfg.WarnDays <= 0 {
+		cfg.WarnDays = 14
+	}
+}
+
+// GetExpiryConfig returns the expiry alert configuration
+func GetExpiryConfig() *ExpiryConfig {
+	expiryMutex.Lock()
+	defer expiryMutex.Unlock()
+	return expiryConfig
+}
+
+// ExpiringCert describes a tracked domain with a certificate close to expiry
+type ExpiringCert struct {
+	Domain   string    `json:"domain"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+	Issuer   string    `json:"issuer"`
+	Notified bool      `json:"notified"`
+}
+
+// GetExpiringCerts returns the expiry calendar for the next N days, soonest first
+func GetExpiringCerts(days int) []ExpiringCert {
+	dt := GetDomainTracker()
+	entries := dt.GetExpiringDomains(time.Duration(days) * 24 * time.Hour)
+
+	var result []ExpiringCert
+	for _, entry := range entries {
+		result = append(result, ExpiringCert{
+			Domain:   entry.Domain,
+			NotAfter: entry.CertExpiry,
+			DaysLeft: int(time.Until(entry.CertExpiry).Hours() / 24),
+			Issuer:   entry.CertIssuer,
+			Notified: entry.ExpiryNotified.Equal(entry.CertExpiry),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NotAfter.Before(result[j].NotAfter)
+	})
+	return result
+}
+
+// StartExpiryScheduler starts the daily certificate expiry check
+func StartExpiryScheduler() {
+	cfg := GetExpiryConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	logger.Info("certificate expiry alerts enabled", "warn_days", cfg.WarnDays)
+
+	go func() {
+		// Give the tracker a moment to load before the first check
+		time.Sleep(1 * time.Minute)
+		CheckExpiringCertificates()
+
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			CheckExpiringCertificates()
+		}
+	}()
+}
+
+// CheckExpiringCertificates alerts on certificates expiring within the warning
+// window, once per certificate
+func CheckExpiringCertificates() {
+	cfg := GetExpiryConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	var pending []ExpiringCert
+	for _, cert := range GetExpiringCerts(cfg.WarnDays) {
+		if !cert.Notified {
+			pending = append(pending, cert)
+		}
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	if !alertConfigured(cfg.Webhook) {
+		logger.Warn("certificates expiring soon but no webhook configured", "count", len(pending))
+		return
+	}
+
+	// Keep each message inside the embed description limit
+	lines := make([]string, 0, len(pending))
+	for _, cert := range pending {
+		lines = append(lines, fmt.Sprintf("%s  %s  (%dd)", cert.NotAfter.Format("2006-01-02"), cert.Domain, cert.DaysLeft))
+	}
+
+	// Each chunk is marked once sent, so a later failure doesn't repeat it tomorrow
+	sent := 0
+	for _, chunk := range chunkByLength(lines, maxBatchChars, resultLineLength) {
+		payload := buildExpiryPayload(cfg.WarnDays, chunk, len(pending))
+		if err := sendAlert(cfg.Webhook, payload); err != nil {
+			logger.Error("failed to send expiry alert", "error", err, "sent", sent, "count", len(pending))
+			return
+		}
+
+		var domains []string
+		for _, cert := range pending[sent : sent+len(chunk)] {
+			domains = append(domains, cert.Domain)
+		}
+		GetDomainTracker().MarkExpiryNotified(domains)
+		sent += len(chunk)
+	}
+
+	logger.Info("certificate expiry alert sent", "count", len(pending))
+}
+
+// buildExpiryPayload builds a Discord embed for certificates expiring soon
+func buildExpiryPayload(days int, lines []string, total int) map[string]interface{} {
+	return map[string]interface{}{
+		"tts": false,
+		"embeds": []map[string]interface{}{
+			{
+				"title":       fmt.Sprintf("Certificates expiring in %d days  [%d]", days, total),
+				"description": fmt.Sprintf("```\n%s\n```", strings.Join(lines, "\n")),
+				"color":       16753920, // Orange
+				"timestamp":   time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+}
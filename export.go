@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFilter selects which tracked domains are exported
+type ExportFilter struct {
+	Target  string          // Only domains under this target
+	Since   time.Time       // First seen at or after
+	Until   time.Time       // First seen before
+	MinRisk int             // Risk score at least
+	Program map[string]bool // Only domains under these targets, when set
+}
+
+// ExportRecord is a flattened tracked domain for CSV/JSON export
+type ExportRecord struct {
+	Domain     string    `json:"domain"`
+	Target     string    `json:"target"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	HitCount   int       `json:"hit_count"`
+	Resolved   bool      `json:"resolved"`
+	RiskScore  int       `json:"risk_score"`
+	RiskLabels []string  `json:"risk_labels"`
+	StatusCode int       `json:"status_code"`
+	Title      string    `json:"title"`
+	Issuer     string    `json:"issuer"`
+	CertExpiry time.Time `json:"cert_expiry"`
+	Source     string    `json:"source"`
+}
+
+// exportCSVHeader is the column order of CSV exports
+var exportCSVHeader = []string{"domain", "target", "first_seen", "last_seen", "hit_count", "resolved", "risk_score", "risk_labels", "status_code", "title", "issuer", "cert_expiry", "source"}
+
+// parseExportFilter builds a filter from target, since, until and min_risk strings
+func parseExportFilter(target, since, until, minRisk string) (ExportFilter, error) {
+	filter := ExportFilter{Target: strings.ToLower(strings.TrimSpace(target))}
+
+	var err error
+	if since != "" {
+		if filter.Since, err = parseExportDate(since, false); err != nil {
+			return filter, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if until != "" {
+		if filter.Until, err = parseExportDate(until, true); err != nil {
+			return filter, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if minRisk != "" {
+		if filter.MinRisk, err = strconv.Atoi(minRisk); err != nil {
+			return filter, fmt.Errorf("invalid min_risk: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// parseExportDate accepts YYYY-MM-DD or RFC 3339. A bare end date includes the whole day.
+func parseExportDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
+
+// ExportDomains returns tracked domains matching the filter, oldest first
+func ExportDomains(filter ExportFilter) []ExportRecord {
+	var records []ExportRecord
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		target := ""
+		for _, t := range targets {
+			if entryMatchesTarget(entry, t) {
+				target = t
+				break
+			}
+		}
+
+		if filter.Target != "" && !entryMatchesTarget(entry, filter.Target) {
+			continue
+		}
+		if !inProgram(entry, filter.Program) {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.FirstSeen.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !entry.FirstSeen.Before(filter.Until) {
+			continue
+		}
+		if entry.RiskScore < filter.MinRisk {
+			continue
+		}
+
+		record := ExportRecord{
+			Domain:     entry.Domain,
+			Target:     target,
+			FirstSeen:  entry.FirstSeen,
+			LastSeen:   entry.LastSeen,
+			HitCount:   entry.HitCount,
+			Resolved:   entry.Resolved,
+			RiskScore:  entry.RiskScore,
+			RiskLabels: entry.RiskLabels,
+			StatusCode: entry.HttpStatusCode,
+			Issuer:     entry.CertIssuer,
+			CertExpiry: entry.CertExpiry,
+			Source:     domainSource(entry),
+		}
+		if entry.Probe != nil {
+			record.Title = entry.Probe.Title
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].FirstSeen.Before(records[j].FirstSeen)
+	})
+	return records
+}
+
+// writeExport encodes records as csv or json
+func writeExport(w io.Writer, format string, records []ExportRecord) error {
+	switch format {
+	case "json":
+		if records == nil {
+			records = []ExportRecord{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(exportCSVHeader)
+		for _, r := range records {
+			cw.Write(csvSafe([]string{
+				r.Domain,
+				r.Target,
+				r.FirstSeen.Format(time.RFC3339),
+				r.LastSeen.Format(time.RFC3339),
+				strconv.Itoa(r.HitCount),
+				strconv.FormatBool(r.Resolved),
+				strconv.Itoa(r.RiskScore),
+				strings.Join(r.RiskLabels, ";"),
+				strconv.Itoa(r.StatusCode),
+				r.Title,
+				r.Issuer,
+				formatExportTime(r.CertExpiry),
+				r.Source,
+			}))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+}
+
+// exportFindingsCSVHeader is the column order of CSV finding exports
+var exportFindingsCSVHeader = []string{"tool", "domain", "target", "kind", "url", "method", "status", "previous_status", "length", "severity", "name", "template_id", "first_seen", "last_seen", "scans"}
+
+// ExportFindings returns stored scan findings matching the filter, oldest first. The
+// risk score filter applies to domains only.
+func ExportFindings(filter ExportFilter) []Finding {
+	var findings []Finding
+	for _, f := range GetFindingsStore().List(FindingFilter{}) {
+		if filter.Target != "" && !matchesTarget(f.Domain, filter.Target) {
+			continue
+		}
+		if filter.Program != nil && !filter.Program[f.Target] {
+			continue
+		}
+		if !filter.Since.IsZero() && f.FirstSeen.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !f.FirstSeen.Before(filter.Until) {
+			continue
+		}
+		findings = append(findings, f)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].FirstSeen.Before(findings[j].FirstSeen)
+	})
+	return findings
+}
+
+// writeFindingsExport encodes findings as csv or json
+func writeFindingsExport(w io.Writer, format string, findings []Finding) error {
+	switch format {
+	case "json":
+		if findings == nil {
+			findings = []Finding{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(exportFindingsCSVHeader)
+		for _, f := range findings {
+			cw.Write(csvSafe([]string{
+				f.Tool,
+				f.Domain,
+				f.Target,
+				f.Kind,
+				f.URL,
+				f.Method,
+				strconv.Itoa(f.Status),
+				strconv.Itoa(f.PreviousStatus),
+				strconv.Itoa(f.Length),
+				f.Severity,
+				f.Name,
+				f.TemplateID,
+				f.FirstSeen.Format(time.RFC3339),
+				f.LastSeen.Format(time.RFC3339),
+				strconv.Itoa(f.Scans),
+			}))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported format %q, use csv or json", format)
+	}
+}
+
+// csvSafe prefixes cells that spreadsheets would run as formulas with a quote. Titles,
+// issuers and scan results come from the sites and certificates being monitored.
+func csvSafe(row []string) []string {
+	for i, cell := range row {
+		if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			continue
+		}
+		// Negative numbers are left alone
+		if _, err := strconv.ParseFloat(cell, 64); err == nil {
+			continue
+		}
+		row[i] = "'" + cell
+	}
+	return row
+}
+
+// formatExportTime formats a timestamp, leaving unset times empty
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// runExport implements crtmon export, dumping tracked domains or scan findings to stdout or a file
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "output format: csv or json")
+	exportTarget := fs.String("target", "", "only domains under this target")
+	exportProgram := fs.String("program", "", "only domains under this program's targets")
+	since := fs.String("since", "", "first seen on or after (YYYY-MM-DD or RFC 3339)")
+	until := fs.String("until", "", "first seen on or before (YYYY-MM-DD or RFC 3339)")
+	minRisk := fs.String("min-risk", "", "minimum risk score")
+	output := fs.String("o", "", "write to file instead of stdout")
+	exportFindings := fs.Bool("findings", false, "export scan findings instead of domains")
+	exportConfig := fs.String("config", "", "path to configuration file")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	// Checked before -o creates the file
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported format %q, use csv or json\n", *format)
+		return 1
+	}
+
+	filter, err := parseExportFilter(*exportTarget, *since, *until, *minRisk)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *exportConfig != "" {
+		setConfigPath(*exportConfig)
+	}
+	if cfg, err := loadConfig(); err == nil && cfg != nil {
+		targets = normalizeTargets(cfg.Targets)
+		SetProgramConfig(cfg.Programs)
+	}
+	if *exportProgram != "" {
+		members, ok := programMembers(*exportProgram)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown program %q\n", *exportProgram)
+			return 1
+		}
+		filter.Program = members
+	}
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := InitDomainTracker(configDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *exportFindings {
+		InitFindingsStore(configDir)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *exportFindings {
+		findings := ExportFindings(filter)
+		if err := writeFindingsExport(w, *format, findings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if *output != "" {
+			fmt.Fprintf(os.Stderr, "exported %d findings to %s\n", len(findings), *output)
+		}
+		return 0
+	}
+
+	records := ExportDomains(filter)
+	if err := writeExport(w, *format, records); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "exported %d domains to %s\n", len(records), *output)
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		cell, want string
+	}{
+		{"example.com", "example.com"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\",\"x\")", "'=HYPERLINK(\"http://evil\",\"x\")"},
+		{"+cmd|' /C calc'!A0", "'+cmd|' /C calc'!A0"},
+		{"-2+3+cmd|' /C calc'!A0", "'-2+3+cmd|' /C calc'!A0"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"a=b", "a=b"},
+		// Numbers stay numbers
+		{"-5", "-5"},
+		{"+5", "+5"},
+		{"-0.25", "-0.25"},
+		{"-1e3", "-1e3"},
+	}
+	for _, tt := range tests {
+		if got := csvSafe([]string{tt.cell})[0]; got != tt.want {
+			t.Errorf("csvSafe(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+
+	row := csvSafe([]string{"=1", "ok", "@x"})
+	if row[0] != "'=1" || row[1] != "ok" || row[2] != "'@x" {
+		t.Errorf("row = %q", row)
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Finding kinds
+const (
+	findingPath          = "path"
+	findingSubdomain     = "subdomain"
+	findingVulnerability = "vulnerability"
+)
+
+// Finding is one result of a scan, kept across scans. A finding reported again
+// by a later scan updates the stored one instead of being added twice.
+type Finding struct {
+	ID             string    `json:"id"`
+	Tool           string    `json:"tool"`
+	Domain         string    `json:"domain"` // Domain the scan ran against
+	Target         string    `json:"target,omitempty"`
+	Kind           string    `json:"kind"` // path, subdomain or vulnerability
+	URL            string    `json:"url,omitempty"`
+	Path           string    `json:"path,omitempty"`
+	Method         string    `json:"method,omitempty"`
+	Status         int       `json:"status,omitempty"`
+	Length         int       `json:"length,omitempty"`
+	Severity       string    `json:"severity,omitempty"`
+	Name           string    `json:"name,omitempty"` // Nuclei template name, or the subdomain found
+	TemplateID     string    `json:"template_id,omitempty"`
+	PreviousStatus int       `json:"previous_status,omitempty"` // Status before it last changed
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+	Scans          int       `json:"scans"` // Scans that reported it
+}
+
+// FindingsStore persists findings parsed from scan output
+type FindingsStore struct {
+	mu       sync.Mutex
+	findings map[string]*Finding
+	filePath string
+}
+
+// maxFindings bounds the store; the findings seen longest ago are dropped first
+const maxFindings = 50000
+
+var findingsStore *FindingsStore
+
+// nucleiTextPattern matches nuclei's text output, e.g.
+// "[git-config] [http] [medium] https://example.com/.git/config"
+var nucleiTextPattern = regexp.MustCompile(`^\[([^\]]+)\] \[([^\]]+)\] \[([^\]]+)\] (\S+)`)
+
+// InitFindingsStore loads the stored findings
+func InitFindingsStore(configDir string) error {
+	s := &FindingsStore{
+		findings: make(map[string]*Finding),
+		filePath: filepath.Join(configDir, "findings.json"),
+	}
+
+	if data, err := os.ReadFile(s.filePath); err == nil {
+		if err := json.Unmarshal(data, &s.findings); err != nil {
+			logger.Error("failed to load findings", "error", err)
+		}
+	} else if !os.IsNotExist(err) {
+		logger.Error("failed to read findings", "error", err)
+	}
+
+	findingsStore = s
+	return nil
+}
+
+// GetFindingsStore returns the global findings store
+func GetFindingsStore() *FindingsStore {
+	if findingsStore == nil {
+		configDir, _ := getConfigDir()
+		InitFindingsStore(configDir)
+	}
+	return findingsStore
+}
+
+// findingID identifies a finding across scans by its tool, domain and what was found
+func findingID(f Finding) string {
+	key := f.Tool + "|" + f.Domain + "|" + f.Kind + "|"
+	switch f.Kind {
+	case findingPath:
+		key += f.Method + " " + f.URL
+	case findingSubdomain:
+		key += f.Name
+	default:
+		key += f.TemplateID + " " + f.URL
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Record stores the findings of one scan, filling in their IDs and sightings, and
+// returns those not seen before and those whose status code changed since the
+// previous scan
+func (s *FindingsStore) Record(findings []Finding) []Finding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var changed []Finding
+	for i := range findings {
+		f := findings[i]
+		f.ID = findingID(f)
+		if existing, ok := s.findings[f.ID]; ok {
+			// Keep when it was first seen, take everything else from the latest scan
+			f.FirstSeen = existing.FirstSeen
+			f.Scans = existing.Scans + 1
+			f.LastSeen = now
+			f.PreviousStatus = existing.PreviousStatus
+			if existing.Status != f.Status && f.Status != 0 {
+				f.PreviousStatus = existing.Status
+				changed = append(changed, f)
+			}
+			*existing = f
+			findings[i] = f
+			continue
+		}
+		f.FirstSeen, f.LastSeen, f.Scans = now, now, 1
+		findings[i] = f
+		s.findings[f.ID] = &f
+		changed = append(changed, f)
+	}
+	s.evict()
+
+	if err := s.save(); err != nil {
+		logger.Error("failed to save findings", "error", err)
+	}
+	return changed
+}
+
+// evict drops the findings seen longest ago beyond maxFindings. Caller must hold s.mu.
+func (s *FindingsStore) evict() {
+	if len(s.findings) <= maxFindings {
+		return
+	}
+	all := make([]*Finding, 0, len(s.findings))
+	for _, f := range s.findings {
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].LastSeen.Before(all[j].LastSeen) })
+	for _, f := range all[:len(all)-maxFindings] {
+		delete(s.findings, f.ID)
+	}
+}
+
+// FindingFilter selects findings; zero fields match everything
+type FindingFilter struct {
+	Domain   string // The domain and domains under it
+	Tool     string
+	Kind     string
+	Severity string
+	Status   int
+	Since    time.Time // Last seen at or after
+}
+
+// matches reports whether a finding passes the filter
+func (filter FindingFilter) matches(f *Finding) bool {
+	switch {
+	case filter.Domain != "" && f.Domain != filter.Domain && !strings.HasSuffix(f.Domain, "."+filter.Domain):
+		return false
+	case filter.Tool != "" && f.Tool != filter.Tool:
+		return false
+	case filter.Kind != "" && f.Kind != filter.Kind:
+		return false
+	case filter.Severity != "" && !strings.EqualFold(f.Severity, filter.Severity):
+		return false
+	case filter.Status != 0 && f.Status != filter.Status:
+		return false
+	case !filter.Since.IsZero() && f.LastSeen.Before(filter.Since):
+		return false
+	}
+	return true
+}
+
+// List returns the findings passing the filter, most recently seen first
+func (s *FindingsStore) List(filter FindingFilter) []Finding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []Finding
+	for _, f := range s.findings {
+		if filter.matches(f) {
+			result = append(result, *f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastSeen.Equal(result[j].LastSeen) {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// CountTarget returns how many findings are stored for a target's domains
+func (s *FindingsStore) CountTarget(target string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, f := range s.findings {
+		if matchesTarget(f.Domain, target) {
+			count++
+		}
+	}
+	return count
+}
+
+// CountDomains returns how many findings are stored for each domain scanned
+func (s *FindingsStore) CountDomains() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, f := range s.findings {
+		counts[f.Domain]++
+	}
+	return counts
+}
+
+// RemoveTarget deletes the findings of a target's domains and returns how many there were
+func (s *FindingsStore) RemoveTarget(target string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, f := range s.findings {
+		if matchesTarget(f.Domain, target) {
+			delete(s.findings, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.save()
+}
+
+// save writes the findings to disk. Caller must hold s.mu.
+func (s *FindingsStore) save() error {
+	if isDryRun() {
+		return nil
+	}
+	data, err := json.Marshal(s.findings)
+	if err != nil {
+		return err
+	}
+
+	tempPath := s.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, s.filePath)
+}
+
+// parseScanFindings turns the output lines of a scan into findings, and returns the
+// lines to show in its results message. Output of tools without a parser is shown as
+// it is; lines a parser doesn't recognise, like feroxbuster's statistics, are dropped.
+func parseScanFindings(scanType, domain, target string, lines []string) ([]Finding, []string) {
+	var parse func(string) (Finding, bool)
+	switch scanType {
+	case "feroxbuster":
+		parse = parseFeroxLine
+	case "puredns":
+		parse = parsePurednsLine
+	case "nuclei":
+		parse = parseNucleiLine
+	default:
+		return nil, lines
+	}
+
+	var findings []Finding
+	var display []string
+	for _, line := range lines {
+		f, ok := parse(line)
+		if !ok {
+			continue
+		}
+		f.Tool, f.Domain, f.Target = scanType, domain, target
+		findings = append(findings, f)
+		display = append(display, f.summary())
+	}
+	return findings, display
+}
+
+// parseFeroxLine reads a response from feroxbuster's --json output, or a line of its
+// text output like "200      GET       10l       20w      300c https://example.com/admin"
+func parseFeroxLine(line string) (Finding, bool) {
+	if strings.HasPrefix(line, "{") {
+		var response struct {
+			Type          string `json:"type"`
+			URL           string `json:"url"`
+			Path          string `json:"path"`
+			Method        string `json:"method"`
+			Status        int    `json:"status"`
+			ContentLength int    `json:"content_length"`
+		}
+		if err := json.Unmarshal([]byte(line), &response); err != nil || response.Type != "response" || response.URL == "" {
+			return Finding{}, false
+		}
+		return Finding{
+			Kind:   findingPath,
+			URL:    response.URL,
+			Path:   orDefault(response.Path, urlPath(response.URL)),
+			Method: response.Method,
+			Status: response.Status,
+			Length: response.ContentLength,
+		}, true
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return Finding{}, false
+	}
+	status, err := strconv.Atoi(fields[0])
+	if err != nil || status < 100 || status >= 600 {
+		return Finding{}, false
+	}
+	f := Finding{Kind: findingPath, Method: fields[1], Status: status}
+	for _, field := range fields[2:] {
+		switch {
+		case strings.Contains(field, "://"):
+			// Redirects are followed by "=> location"
+			f.URL, f.Path = field, urlPath(field)
+		case strings.HasSuffix(field, "c"):
+			if n, err := strconv.Atoi(strings.TrimSuffix(field, "c")); err == nil {
+				f.Length = n
+			}
+		}
+		if f.URL != "" {
+			break
+		}
+	}
+	return f, f.URL != ""
+}
+
+// parsePurednsLine reads a subdomain puredns found
+func parsePurednsLine(line string) (Finding, bool) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(line), "."))
+	if name == "" || strings.ContainsAny(name, " \t/:") {
+		return Finding{}, false
+	}
+	return Finding{Kind: findingSubdomain, Name: name}, true
+}
+
+// parseNucleiLine reads a result from nuclei's -jsonl output, or a line of its text output
+func parseNucleiLine(line string) (Finding, bool) {
+	if strings.HasPrefix(line, "{") {
+		var result struct {
+			TemplateID string `json:"template-id"`
+			Info       struct {
+				Name     string `json:"name"`
+				Severity string `json:"severity"`
+			} `json:"info"`
+			MatchedAt string `json:"matched-at"`
+			Host      string `json:"host"`
+		}
+		if err := json.Unmarshal([]byte(line), &result); err != nil || result.TemplateID == "" {
+			return Finding{}, false
+		}
+		matched := orDefault(result.MatchedAt, result.Host)
+		return Finding{
+			Kind:       findingVulnerability,
+			URL:        matched,
+			Path:       urlPath(matched),
+			Severity:   strings.ToLower(result.Info.Severity),
+			Name:       result.Info.Name,
+			TemplateID: result.TemplateID,
+		}, true
+	}
+
+	m := nucleiTextPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Finding{}, false
+	}
+	return Finding{
+		Kind:       findingVulnerability,
+		URL:        m[4],
+		Path:       urlPath(m[4]),
+		Severity:   strings.ToLower(m[3]),
+		TemplateID: m[1],
+	}, true
+}
+
+// urlPath returns the path of a URL, or "" if it has none
+func urlPath(raw string) string {
+	if u, err := url.Parse(raw); err == nil {
+		return u.Path
+	}
+	return ""
+}
+
+// summary is the line shown for a finding in scan results messages, in the shape of
+// the tool's own text output
+func (f Finding) summary() string {
+	switch f.Kind {
+	case findingPath:
+		if f.PreviousStatus != 0 {
+			return fmt.Sprintf("%d (was %d) %s %dc %s", f.Status, f.PreviousStatus, orDefault(f.Method, "GET"), f.Length, f.URL)
+		}
+		return fmt.Sprintf("%d %s %dc %s", f.Status, orDefault(f.Method, "GET"), f.Length, f.URL)
+	case findingSubdomain:
+		return f.Name
+	}
+	return fmt.Sprintf("[%s] [%s] %s", f.TemplateID, orDefault(f.Severity, "unknown"), f.URL)
+}
+
+// handleFindings lists stored findings, filtered by domain (including subdomains),
+// tool, kind, severity, status and since (RFC 3339 or a duration like 24h)
+func (as *AdminServer) handleFindings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	filter := FindingFilter{
+		Domain:   strings.ToLower(strings.TrimSpace(q.Get("domain"))),
+		Tool:     q.Get("tool"),
+		Kind:     q.Get("kind"),
+		Severity: q.Get("severity"),
+	}
+	if status := q.Get("status"); status != "" {
+		n, err := strconv.Atoi(status)
+		if err != nil {
+			http.Error(w, "status must be a number", http.StatusBadRequest)
+			return
+		}
+		filter.Status = n
+	}
+	if since := q.Get("since"); since != "" {
+		if d, err := time.ParseDuration(since); err == nil {
+			filter.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = t
+		} else {
+			http.Error(w, "since must be RFC 3339 or a duration like 24h", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 500
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	findings := GetFindingsStore().List(filter)
+	total := len(findings)
+	if len(findings) > limit {
+		findings = findings[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FindingList{Findings: findings, Count: len(findings), Total: total})
+}
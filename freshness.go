@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FreshnessConfig holds target activity alert settings
+type FreshnessConfig struct {
+	AlertEnabled bool `yaml:"alert_enabled"`
+	StaleDays    int  `yaml:"stale_days"`
+}
+
+var freshnessConfig *FreshnessConfig
+var freshnessMutex sync.Mutex
+
+// freshnessAlerted holds targets already alerted as inactive, until they see activity again
+var freshnessAlerted = make(map[string]bool)
+
+// TargetFreshness describes how recently a target has seen CT activity
+type TargetFreshness struct {
+	Target        string    `json:"target"`
+	LastDiscovery time.Time `json:"last_discovery"` // Newest first-seen domain
+	LastCertSeen  time.Time `json:"last_cert_seen"` // Newest certificate for any domain
+	Domains       int       `json:"domains"`
+	IdleDays      int       `json:"idle_days"`
+	Status        string    `json:"status"` // fresh, stale, dead or pending
+}
+
+// SetFreshnessConfig sets the freshness configuration
+func SetFreshnessConfig(cfg *FreshnessConfig) {
+	freshnessMutex.Lock()
+	defer freshnessMutex.Unlock()
+	freshnessConfig = cfg
+	if cfg != nil && cfg.StaleDays <= 0 {
+		cfg.StaleDays = 7
+	}
+}
+
+// getStaleDays returns the configured inactivity threshold
+func getStaleDays() int {
+	freshnessMutex.Lock()
+	defer freshnessMutex.Unlock()
+	if freshnessConfig == nil || freshnessConfig.StaleDays <= 0 {
+		return 7
+	}
+	return freshnessConfig.StaleDays
+}
+
+// GetTargetFreshness computes the freshness of each target from tracked domains
+func GetTargetFreshness(targetList []string) []TargetFreshness {
+	allDomains := GetDomainTracker().GetAllDomains()
+	staleAfter := time.Duration(getStaleDays()) * 24 * time.Hour
+	now := time.Now()
+
+	result := make([]TargetFreshness, 0, len(targetList))
+	for _, target := range targetList {
+		f := TargetFreshness{Target: target}
+
+		for _, entry := range allDomains {
+			if !entryMatchesTarget(entry, target) {
+				continue
+			}
+			f.Domains++
+			if entry.FirstSeen.After(f.LastDiscovery) {
+				f.LastDiscovery = entry.FirstSeen
+			}
+			if entry.LastSeen.After(f.LastCertSeen) {
+				f.LastCertSeen = entry.LastSeen
+			}
+		}
+
+		switch {
+		case f.LastCertSeen.IsZero():
+			// Nothing seen yet, only a problem once we've been watching long enough
+			f.IdleDays = int(now.Sub(startTime).Hours() / 24)
+			if now.Sub(startTime) >= staleAfter {
+				f.Status = "dead"
+			} else {
+				f.Status = "pending"
+			}
+		default:
+			idle := now.Sub(f.LastCertSeen)
+			f.IdleDays = int(idle.Hours() / 24)
+			if idle < 24*time.Hour {
+				f.Status = "fresh"
+			} else if idle < staleAfter {
+				f.Status = "stale"
+			} else {
+				f.Status = "dead"
+			}
+		}
+
+		result = append(result, f)
+	}
+	return result
+}
+
+// StartFreshnessScheduler starts the daily inactive target check
+func StartFreshnessScheduler() {
+	freshnessMutex.Lock()
+	enabled := freshnessConfig != nil && freshnessConfig.AlertEnabled
+	freshnessMutex.Unlock()
+
+	if !enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			CheckInactiveTargets()
+		}
+	}()
+}
+
+// CheckInactiveTargets alerts once for each target with no activity in stale_days
+func CheckInactiveTargets() {
+	var inactive []TargetFreshness
+
+	freshness := GetTargetFreshness(targets)
+
+	freshnessMutex.Lock()
+	for _, f := range freshness {
+		if f.Status != "dead" {
+			delete(freshnessAlerted, f.Target)
+			continue
+		}
+		if !freshnessAlerted[f.Target] {
+			freshnessAlerted[f.Target] = true
+			inactive = append(inactive, f)
+		}
+	}
+	freshnessMutex.Unlock()
+
+	if len(inactive) == 0 {
+		return
+	}
+
+	if !alertConfigured("") {
+		logger.Warn("targets inactive but no webhook configured", "count", len(inactive))
+		return
+	}
+
+	var lines []string
+	for _, f := range inactive {
+		last := "never"
+		if !f.LastCertSeen.IsZero() {
+			last = f.LastCertSeen.Format("2006-01-02")
+		}
+		lines = append(lines, fmt.Sprintf("%s  last seen: %s  (%dd)", f.Target, last, f.IdleDays))
+	}
+
+	payload := map[string]interface{}{
+		"tts": false,
+		"embeds": []map[string]interface{}{
+			{
+				"title":       fmt.Sprintf("No activity in %d days  [%d]", getStaleDays(), len(inactive)),
+				"description": fmt.Sprintf("```\n%s\n```\nCheck these targets for typos or retired scope.", strings.Join(lines, "\n")),
+				"color":       8421504, // Grey
+				"timestamp":   time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+
+	if err := sendAlert("", payload); err != nil {
+		logger.Error("failed to send inactive target alert", "error", err)
+		return
+	}
+	logger.Info("inactive target alert sent", "count", len(inactive))
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GeoIPConfig records the country of resolved addresses from a MaxMind GeoLite2 database
+type GeoIPConfig struct {
+	Database          string   `yaml:"database"`           // Path to GeoLite2-Country.mmdb or GeoLite2-City.mmdb
+	ExpectedCountries []string `yaml:"expected_countries"` // ISO codes, e.g. ["US", "DE"]; others are labelled "unexpected-country"
+	RiskPoints        *int     `yaml:"risk_points"`        // Points of "unexpected-country", unless risk.points sets them
+}
+
+// mmdbMetadataMarker precedes the metadata map at the end of an MMDB file
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// mmdbReader looks up addresses in a MaxMind DB file held in memory
+type mmdbReader struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	treeSize   uint
+	ipv4Start  uint // Node reached after the 96 zero bits of an IPv4-mapped address
+}
+
+var geoipConfig *GeoIPConfig
+var geoipDB *mmdbReader
+var geoipMutex sync.Mutex
+
+// SetGeoIPConfig sets the GeoIP configuration and opens its database
+func SetGeoIPConfig(cfg *GeoIPConfig) {
+	geoipMutex.Lock()
+	defer geoipMutex.Unlock()
+	geoipConfig = cfg
+	geoipDB = nil
+	if cfg == nil || cfg.Database == "" {
+		return
+	}
+	for i, c := range cfg.ExpectedCountries {
+		cfg.ExpectedCountries[i] = strings.ToUpper(strings.TrimSpace(c))
+	}
+	db, err := openMMDB(cfg.Database)
+	if err != nil {
+		logger.Error("failed to open geoip database, countries will not be recorded", "path", cfg.Database, "error", err)
+		return
+	}
+	geoipDB = db
+	logger.Info("geoip database loaded", "path", cfg.Database)
+}
+
+// GetGeoIPConfig returns the GeoIP configuration
+func GetGeoIPConfig() *GeoIPConfig {
+	geoipMutex.Lock()
+	defer geoipMutex.Unlock()
+	return geoipConfig
+}
+
+// isGeoIPEnabled reports whether a GeoIP database is loaded
+func isGeoIPEnabled() bool {
+	geoipMutex.Lock()
+	defer geoipMutex.Unlock()
+	return geoipDB != nil
+}
+
+// LookupCountries returns the distinct ISO country codes of addresses
+func LookupCountries(addrs []string) []string {
+	geoipMutex.Lock()
+	db := geoipDB
+	geoipMutex.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		record, err := db.lookup(ip)
+		if err != nil {
+			logger.Debug("geoip lookup failed", "addr", addr, "error", err)
+			continue
+		}
+		if code := recordCountry(record); code != "" {
+			seen[code] = true
+		}
+	}
+	countries := make([]string, 0, len(seen))
+	for c := range seen {
+		countries = append(countries, c)
+	}
+	sort.Strings(countries)
+	return countries
+}
+
+// unexpectedCountries returns the countries not in the expected list, none when no list is set
+func unexpectedCountries(countries []string) []string {
+	cfg := GetGeoIPConfig()
+	if cfg == nil || len(cfg.ExpectedCountries) == 0 {
+		return nil
+	}
+	var unexpected []string
+	for _, c := range countries {
+		expected := false
+		for _, e := range cfg.ExpectedCountries {
+			if c == e {
+				expected = true
+				break
+			}
+		}
+		if !expected {
+			unexpected = append(unexpected, c)
+		}
+	}
+	return unexpected
+}
+
+// recordCountry returns the country ISO code of a GeoLite2 record, falling back to the
+// country the network is registered in
+func recordCountry(record interface{}) string {
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := m[key].(map[string]interface{}); ok {
+			if code, ok := country["iso_code"].(string); ok && code != "" {
+				return code
+			}
+		}
+	}
+	return ""
+}
+
+// openMMDB reads a MaxMind DB file and its metadata
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("not a MaxMind DB file")
+	}
+
+	r := &mmdbReader{buf: buf}
+	meta, _, err := r.decode(buf[start+len(mmdbMetadataMarker):], 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid metadata")
+	}
+	nodeCount, _ := m["node_count"].(uint64)
+	recordSize, _ := m["record_size"].(uint64)
+	ipVersion, _ := m["ip_version"].(uint64)
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("unsupported record size %d", recordSize)
+	}
+	r.nodeCount = uint(nodeCount)
+	r.recordSize = uint(recordSize)
+	r.ipVersion = uint(ipVersion)
+	r.treeSize = r.nodeCount * r.recordSize / 4
+	if r.treeSize+16 > uint(start) {
+		return nil, fmt.Errorf("search tree larger than file")
+	}
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// lookup returns the record for an address, nil if the database has none
+func (r *mmdbReader) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	bits := ip.To16()
+	if v4 := ip.To4(); v4 != nil {
+		bits = v4
+		node = r.ipv4Start
+	} else if r.ipVersion == 4 {
+		return nil, fmt.Errorf("IPv6 address in an IPv4 database")
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("invalid search tree")
+	}
+
+	data := r.buf[r.treeSize+16:]
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(data)) {
+		return nil, fmt.Errorf("invalid data pointer")
+	}
+	value, _, err := r.decode(data, offset)
+	return value, err
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a search tree node
+func (r *mmdbReader) readNode(node, bit uint) uint {
+	b := r.buf[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// MMDB data section types
+const (
+	mmdbExtended = 0
+	mmdbPointer  = 1
+	mmdbString   = 2
+	mmdbDouble   = 3
+	mmdbBytes    = 4
+	mmdbUint16   = 5
+	mmdbUint32   = 6
+	mmdbMap      = 7
+	mmdbInt32    = 8
+	mmdbUint64   = 9
+	mmdbUint128  = 10
+	mmdbArray    = 11
+	mmdbBool     = 14
+	mmdbFloat    = 15
+)
+
+// decode reads the value at offset in a data section and returns the offset after it.
+// Unsigned integers decode to uint64; 128-bit integers are kept as raw bytes.
+func (r *mmdbReader) decode(data []byte, offset uint) (interface{}, uint, error) {
+	if offset >= uint(len(data)) {
+		return nil, 0, fmt.Errorf("unexpected end of data")
+	}
+	ctrl := data[offset]
+	offset++
+	kind := uint(ctrl >> 5)
+
+	if kind == mmdbPointer {
+		ss := uint(ctrl>>3) & 3
+		if offset+ss+1 > uint(len(data)) {
+			return nil, 0, fmt.Errorf("unexpected end of data")
+		}
+		var target uint
+		switch ss {
+		case 0:
+			target = uint(ctrl&7)<<8 | uint(data[offset])
+		case 1:
+			target = (uint(ctrl&7)<<16 | uint(data[offset])<<8 | uint(data[offset+1])) + 2048
+		case 2:
+			target = (uint(ctrl&7)<<24 | uint(data[offset])<<16 | uint(data[offset+1])<<8 | uint(data[offset+2])) + 526336
+		default:
+			target = uint(binary.BigEndian.Uint32(data[offset:]))
+		}
+		value, _, err := r.decode(data, target)
+		return value, offset + ss + 1, err
+	}
+
+	if kind == mmdbExtended {
+		if offset >= uint(len(data)) {
+			return nil, 0, fmt.Errorf("unexpected end of data")
+		}
+		kind = 7 + uint(data[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(data)) {
+			return nil, 0, fmt.Errorf("unexpected end of data")
+		}
+		extra := uint(0)
+		for i := uint(0); i < n; i++ {
+			extra = extra<<8 | uint(data[offset+i])
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch kind {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := r.decode(data, offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			value, after, err := r.decode(data, next)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string")
+			}
+			m[k] = value
+			offset = after
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := r.decode(data, offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(data)) {
+		return nil, 0, fmt.Errorf("unexpected end of data")
+	}
+	b := data[offset : offset+size]
+	offset += size
+	switch kind {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case mmdbInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(n)), offset, nil
+		}
+		return int64(n), offset, nil
+	case mmdbBytes, mmdbUint128:
+		return append([]byte(nil), b...), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+	}
+}
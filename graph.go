@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GraphConfig records what the asset graph needs beyond tracking data: the addresses
+// each notified domain resolves to and, optionally, the ASNs announcing them
+type GraphConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	ASNLookup bool `yaml:"asn_lookup"` // Look up origin ASNs with Team Cymru's DNS service
+}
+
+// maxGraphDomains bounds the graph to the most recently seen domains so the page stays usable
+const maxGraphDomains = 500
+
+// maxASNCache bounds cached ASN lookups
+const maxASNCache = 10000
+
+var graphConfig *GraphConfig
+var graphMutex sync.Mutex
+
+var asnCache = make(map[string]string) // Address or "AS<n>" -> ASN label or name
+var asnMutex sync.Mutex
+
+// SetGraphConfig sets the asset graph configuration
+func SetGraphConfig(cfg *GraphConfig) {
+	graphMutex.Lock()
+	defer graphMutex.Unlock()
+	graphConfig = cfg
+}
+
+// GetGraphConfig returns the asset graph configuration
+func GetGraphConfig() *GraphConfig {
+	graphMutex.Lock()
+	defer graphMutex.Unlock()
+	return graphConfig
+}
+
+// isGraphEnabled reports whether addresses are recorded for the asset graph
+func isGraphEnabled() bool {
+	cfg := GetGraphConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// GraphNode is an asset in the graph
+type GraphNode struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"` // target, domain, ip, asn or cert
+	Label string `json:"label"`
+}
+
+// GraphEdge links two assets, pointing from target towards infrastructure
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AssetGraph links targets to their subdomains, and subdomains to addresses, ASNs and certificates
+type AssetGraph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"` // More domains matched than maxGraphDomains
+}
+
+// RecordAssetLinks stores the addresses a domain resolved to and, for the asset graph's
+// asn_lookup or network enrichment, their ASNs
+func RecordAssetLinks(domain string) {
+	addrs := ResolvedAddrs(domain)
+	if len(addrs) == 0 {
+		return
+	}
+	sort.Strings(addrs)
+
+	var asns map[string]string
+	if cfg := GetGraphConfig(); (cfg != nil && cfg.ASNLookup) || isEnrichEnabled() {
+		asns = make(map[string]string)
+		for _, addr := range addrs {
+			if asn := lookupASN(addr); asn != "" {
+				asns[addr] = asn
+			}
+		}
+	}
+	GetDomainTracker().RecordDomainAddrs(domain, addrs, asns)
+}
+
+// BuildAssetGraph returns the graph of tracked domains, limited to one target and to a
+// program's targets if given
+func BuildAssetGraph(target string, members map[string]bool) AssetGraph {
+	var entries []*DomainEntry
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		if entry.Blacklisted {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastSeen.After(entries[j].LastSeen)
+	})
+
+	g := AssetGraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	seen := make(map[string]bool)
+	addNode := func(id, kind, label string) {
+		if !seen[id] {
+			seen[id] = true
+			g.Nodes = append(g.Nodes, GraphNode{ID: id, Type: kind, Label: label})
+		}
+	}
+	linked := make(map[GraphEdge]bool)
+	addEdge := func(from, to string) {
+		e := GraphEdge{From: from, To: to}
+		if !linked[e] {
+			linked[e] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+
+	count := 0
+	for _, entry := range entries {
+		domainTargets := matchingTargets(entry)
+		if target != "" {
+			domainTargets = filterTarget(domainTargets, target)
+		}
+		if members != nil {
+			var scoped []string
+			for _, t := range domainTargets {
+				if members[t] {
+					scoped = append(scoped, t)
+				}
+			}
+			domainTargets = scoped
+		}
+		if len(domainTargets) == 0 {
+			continue
+		}
+		if count == maxGraphDomains {
+			g.Truncated = true
+			break
+		}
+		count++
+
+		domainID := "domain:" + entry.Domain
+		addNode(domainID, "domain", entry.Domain)
+		for _, t := range domainTargets {
+			addNode("target:"+t, "target", displayTarget(t))
+			addEdge("target:"+t, domainID)
+		}
+		for _, addr := range entry.Addrs {
+			addNode("ip:"+addr, "ip", addr)
+			addEdge(domainID, "ip:"+addr)
+			if asn := entry.ASNs[addr]; asn != "" {
+				number, _, _ := strings.Cut(asn, " ")
+				addNode("asn:"+number, "asn", asn)
+				addEdge("ip:"+addr, "asn:"+number)
+			}
+		}
+		if c := entry.Certificate; c != nil && c.FingerprintSHA256 != "" {
+			fp := c.FingerprintSHA256
+			if len(fp) > 12 {
+				fp = fp[:12]
+			}
+			addNode("cert:"+c.FingerprintSHA256, "cert", c.Issuer+" "+fp)
+			addEdge(domainID, "cert:"+c.FingerprintSHA256)
+		}
+	}
+	return g
+}
+
+// filterTarget keeps only the given target
+func filterTarget(targets []string, target string) []string {
+	for _, t := range targets {
+		if strings.EqualFold(t, target) {
+			return []string{t}
+		}
+	}
+	return nil
+}
+
+// lookupASN returns the origin ASN announcing an address, e.g. "AS13335 CLOUDFLARENET, US",
+// using the TXT records served under origin.asn.cymru.com
+func lookupASN(addr string) string {
+	asnMutex.Lock()
+	if asn, exists := asnCache[addr]; exists {
+		asnMutex.Unlock()
+		return asn
+	}
+	asnMutex.Unlock()
+
+	query, ok := asnQuery(addr)
+	if !ok {
+		return ""
+	}
+	// Answers look like "13335 | 1.1.1.0/24 | AU | apnic | 2011-08-11"
+	fields := cymruTXT(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	// Multi-origin prefixes list several ASNs; the first is enough for the graph
+	number := "AS" + strings.Fields(fields[0])[0]
+	asn := number
+	if name := lookupASName(number); name != "" {
+		asn += " " + name
+	}
+
+	cacheASN(addr, asn)
+	return asn
+}
+
+// lookupASName returns the registered name of an ASN, e.g. "CLOUDFLARENET, US"
+func lookupASName(number string) string {
+	asnMutex.Lock()
+	if name, exists := asnCache[number]; exists {
+		asnMutex.Unlock()
+		return name
+	}
+	asnMutex.Unlock()
+
+	// Answers look like "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+	fields := cymruTXT(number + ".asn.cymru.com")
+	if len(fields) < 5 {
+		return ""
+	}
+	name := fields[4]
+	cacheASN(number, name)
+	return name
+}
+
+// cacheASN stores a lookup result, starting over when the cache is full
+func cacheASN(key, value string) {
+	asnMutex.Lock()
+	defer asnMutex.Unlock()
+	if len(asnCache) >= maxASNCache {
+		asnCache = make(map[string]string)
+	}
+	asnCache[key] = value
+}
+
+// asnQuery returns the origin lookup name for an address: reversed octets for IPv4,
+// reversed nibbles for IPv6
+func asnQuery(addr string) (string, bool) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", v4[3], v4[2], v4[1], v4[0]), true
+	}
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip[i]&0xf, ip[i]>>4)
+	}
+	return b.String() + "origin6.asn.cymru.com", true
+}
+
+// cymruTXT returns the pipe-separated fields of the first TXT answer for a name
+func cymruTXT(name string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	if err != nil || len(records) == 0 {
+		logger.Debug("asn lookup failed", "name", name, "error", err)
+		return nil
+	}
+	parts := strings.Split(records[0], "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	if parts[0] == "" {
+		return nil
+	}
+	return parts
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServiceName is the full name of the gRPC service. Methods are called as
+// /crtmon.v1.Crtmon/<Method>.
+const grpcServiceName = "crtmon.v1.Crtmon"
+
+// GRPCConfig holds settings for the gRPC API, served on its own port
+type GRPCConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Port         int      `yaml:"port"`          // Default 9090
+	TLSCert      string   `yaml:"tls_cert"`      // Serve TLS when both files are set
+	TLSKey       string   `yaml:"tls_key"`       // Private key of tls_cert
+	Buffer       int      `yaml:"buffer"`        // Discoveries kept for resuming, and how far a subscriber may fall behind, default 1000
+	AllowedCIDRs []string `yaml:"allowed_cidrs"` // Networks allowed to connect; empty allows all
+}
+
+var grpcConfig *GRPCConfig
+var grpcMutex sync.Mutex
+
+// SetGRPCConfig sets the gRPC API configuration
+func SetGRPCConfig(cfg *GRPCConfig) {
+	grpcMutex.Lock()
+	defer grpcMutex.Unlock()
+	if cfg.Port == 0 {
+		cfg.Port = 9090
+	}
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 1000
+	}
+	grpcConfig = cfg
+}
+
+// GetGRPCConfig returns the gRPC API configuration
+func GetGRPCConfig() *GRPCConfig {
+	grpcMutex.Lock()
+	defer grpcMutex.Unlock()
+	return grpcConfig
+}
+
+// Discovery is a new matched domain on the SubscribeDiscoveries stream
+type Discovery struct {
+	Seq         uint64       `json:"seq,omitempty"` // Increases by one per discovery on the gRPC stream, for resuming with after
+	Time        time.Time    `json:"time"`
+	Domain      string       `json:"domain"`
+	Target      string       `json:"target"`
+	Targets     []string     `json:"targets,omitempty"` // Every target credited when targets overlap
+	Resolves    bool         `json:"resolves"`
+	Notified    bool         `json:"notified"`
+	Source      string       `json:"source,omitempty"`
+	SubjectOrg  string       `json:"subject_org,omitempty"`
+	Certificate *CertDetails `json:"certificate,omitempty"`
+}
+
+// SubscribeRequest starts a SubscribeDiscoveries stream
+type SubscribeRequest struct {
+	After   uint64   `json:"after,omitempty"`   // Replay kept discoveries after this seq first; 0 streams new ones only
+	Targets []string `json:"targets,omitempty"` // Only discoveries credited to these targets
+	Program string   `json:"program,omitempty"` // Only discoveries under this program's targets
+}
+
+// TargetsRequest is the request of ListTargets
+type TargetsRequest struct {
+	Program string `json:"program,omitempty"`
+}
+
+// DomainsRequest is the request of ListDomains; empty fields match everything
+type DomainsRequest struct {
+	Provider string `json:"provider,omitempty"` // aws, gcp, azure, digitalocean or other
+	ASN      string `json:"asn,omitempty"`      // e.g. AS13335
+	Source   string `json:"source,omitempty"`   // e.g. certstream
+	Program  string `json:"program,omitempty"`
+}
+
+// DomainRequest is the request of GetDomain
+type DomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// grpcMessages are the gRPC messages not already used by the HTTP API, generated
+// into the client package with the rest
+var grpcMessages = []interface{}{Discovery{}, SubscribeRequest{}, TargetsRequest{}, DomainsRequest{}, DomainRequest{}}
+
+// jsonCodec carries gRPC messages as JSON, so the same Go types serve the HTTP API
+// and the gRPC API. Clients call with the content subtype "json".
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// discoverySub is one SubscribeDiscoveries stream
+type discoverySub struct {
+	ch     chan Discovery
+	lagged chan struct{} // Closed when ch was full, and the subscriber dropped
+}
+
+// discoveryHub numbers discoveries, keeps the latest for resuming and fans them out
+type discoveryHub struct {
+	mu     sync.Mutex
+	seq    uint64
+	recent []Discovery
+	subs   map[*discoverySub]struct{}
+}
+
+var discoveries = &discoveryHub{subs: make(map[*discoverySub]struct{})}
+
+// newDiscoveries returns the matched domains of a certificate that weren't excluded
+// or already seen, as streamed and published
+func newDiscoveries(entry CertEntry, decisions []EntryDecision) []Discovery {
+	var found []Discovery
+	var details *CertDetails
+	now := time.Now()
+	for _, d := range decisions {
+		if !d.Matched || d.Excluded || d.Duplicate {
+			continue
+		}
+		if details == nil {
+			details = entry.Details()
+		}
+		found = append(found, Discovery{
+			Time:        now,
+			Domain:      d.Domain,
+			Target:      d.Target,
+			Targets:     d.Targets,
+			Resolves:    d.Resolves,
+			Notified:    d.Notify,
+			Source:      entry.Source,
+			SubjectOrg:  entry.SubjectOrg,
+			Certificate: details,
+		})
+	}
+	return found
+}
+
+// publishGRPCDiscoveries streams new discoveries to subscribers. Publishing never
+// waits for a subscriber.
+func publishGRPCDiscoveries(entry CertEntry, decisions []EntryDecision) {
+	cfg := GetGRPCConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+
+	h := discoveries
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range newDiscoveries(entry, decisions) {
+		h.seq++
+		event.Seq = h.seq
+		h.recent = append(h.recent, event)
+		if len(h.recent) > cfg.Buffer {
+			h.recent = h.recent[len(h.recent)-cfg.Buffer:]
+		}
+		for sub := range h.subs {
+			select {
+			case sub.ch <- event:
+			default:
+				close(sub.lagged)
+				delete(h.subs, sub)
+			}
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the kept discoveries after a seq
+func (h *discoveryHub) subscribe(after uint64, buffer int) (*discoverySub, []Discovery) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub := &discoverySub{ch: make(chan Discovery, buffer), lagged: make(chan struct{})}
+	h.subs[sub] = struct{}{}
+
+	var backlog []Discovery
+	if after > 0 {
+		for _, d := range h.recent {
+			if d.Seq > after {
+				backlog = append(backlog, d)
+			}
+		}
+	}
+	return sub, backlog
+}
+
+// unsubscribe removes a subscriber
+func (h *discoveryHub) unsubscribe(sub *discoverySub) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, sub)
+}
+
+// discoveryFilter returns the targets a subscription is limited to, or nil for all
+func discoveryFilter(req *SubscribeRequest) (map[string]bool, error) {
+	var wanted map[string]bool
+	if req.Program != "" {
+		members, ok := programMembers(req.Program)
+		if !ok {
+			return nil, status.Error(codes.NotFound, "unknown program")
+		}
+		wanted = members
+	}
+	if len(req.Targets) > 0 {
+		listed := make(map[string]bool)
+		for _, raw := range req.Targets {
+			t, err := normalizeTarget(raw)
+			if err != nil || t == "" {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid target %q", raw)
+			}
+			if wanted == nil || wanted[t] {
+				listed[t] = true
+			}
+		}
+		wanted = listed
+	}
+	return wanted, nil
+}
+
+// wants reports whether a discovery is credited to one of the wanted targets
+func wants(wanted map[string]bool, d *Discovery) bool {
+	if wanted == nil {
+		return true
+	}
+	for _, t := range d.Targets {
+		if wanted[t] {
+			return true
+		}
+	}
+	return wanted[d.Target]
+}
+
+// subscribeDiscoveries streams discoveries until the client goes away. Sends wait for
+// the client under HTTP/2 flow control, while discoveries queue for it up to
+// grpc.buffer. A client falling further behind gets RESOURCE_EXHAUSTED and resumes
+// with after set to the last seq it received.
+func subscribeDiscoveries(srv interface{}, stream grpc.ServerStream) error {
+	var req SubscribeRequest
+	if err := stream.RecvMsg(&req); err != nil {
+		return err
+	}
+	wanted, err := discoveryFilter(&req)
+	if err != nil {
+		return err
+	}
+
+	buffer := 1000
+	if cfg := GetGRPCConfig(); cfg != nil {
+		buffer = cfg.Buffer
+	}
+	sub, backlog := discoveries.subscribe(req.After, buffer)
+	defer discoveries.unsubscribe(sub)
+
+	last := req.After
+	send := func(d Discovery) error {
+		last = d.Seq
+		if !wants(wanted, &d) {
+			return nil
+		}
+		return stream.SendMsg(&d)
+	}
+	for _, d := range backlog {
+		if err := send(d); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case d := <-sub.ch:
+			if err := send(d); err != nil {
+				return err
+			}
+		case <-sub.lagged:
+			// Deliver what was queued before the subscriber was dropped
+			for drained := false; !drained; {
+				select {
+				case d := <-sub.ch:
+					if err := send(d); err != nil {
+						return err
+					}
+				default:
+					drained = true
+				}
+			}
+			logger.Warn("grpc subscriber fell behind, stream closed", "buffer", buffer, "last_seq", last)
+			return status.Errorf(codes.ResourceExhausted, "fell %d discoveries behind, resubscribe with after=%d", buffer, last)
+		}
+	}
+}
+
+// grpcUnary describes a unary method, decoding its request into newRequest()
+func grpcUnary(name string, newRequest func() interface{}, call grpc.UnaryHandler) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newRequest()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + grpcServiceName + "/" + name}
+			return interceptor(ctx, req, info, call)
+		},
+	}
+}
+
+// grpcService describes the service. Its messages are the Go types below and in
+// openapi.go, carried by jsonCodec.
+var grpcService = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		grpcUnary("ListTargets", func() interface{} { return &TargetsRequest{} }, grpcListTargets),
+		grpcUnary("AddTargets", func() interface{} { return &BulkTargetsRequest{} }, grpcAddTargets),
+		grpcUnary("RemoveTargets", func() interface{} { return &BulkTargetsRequest{} }, grpcRemoveTargets),
+		grpcUnary("ListDomains", func() interface{} { return &DomainsRequest{} }, grpcListDomains),
+		grpcUnary("GetDomain", func() interface{} { return &DomainRequest{} }, grpcGetDomain),
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "SubscribeDiscoveries", Handler: subscribeDiscoveries, ServerStreams: true},
+	},
+}
+
+// grpcProgram returns the targets of a program, or nil when none is named
+func grpcProgram(name string) (map[string]bool, error) {
+	if name == "" {
+		return nil, nil
+	}
+	members, ok := programMembers(name)
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown program")
+	}
+	return members, nil
+}
+
+func grpcListTargets(ctx context.Context, req interface{}) (interface{}, error) {
+	members, err := grpcProgram(req.(*TargetsRequest).Program)
+	if err != nil {
+		return nil, err
+	}
+	list := listTargets(members)
+	return &list, nil
+}
+
+func grpcAddTargets(ctx context.Context, req interface{}) (interface{}, error) {
+	list := req.(*BulkTargetsRequest).Targets
+	if len(list) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "targets cannot be empty")
+	}
+	result := addTargets(list)
+	logger.Info("targets added via grpc", "added", len(result.Changed), "existing", len(result.Unchanged), "invalid", len(result.Invalid))
+
+	if sm := GetSNIManager(); sm != nil {
+		for _, t := range result.Changed {
+			sm.SearchSNIOnDemand(t)
+		}
+	}
+	return &BulkTargetsResponse{result, targets}, nil
+}
+
+func grpcRemoveTargets(ctx context.Context, req interface{}) (interface{}, error) {
+	list := req.(*BulkTargetsRequest).Targets
+	if len(list) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "targets cannot be empty")
+	}
+	result := removeTargets(list)
+	logger.Info("targets removed via grpc", "removed", len(result.Changed), "not_found", len(result.Unchanged), "invalid", len(result.Invalid))
+	return &BulkTargetsResponse{result, targets}, nil
+}
+
+func grpcListDomains(ctx context.Context, req interface{}) (interface{}, error) {
+	r := req.(*DomainsRequest)
+	members, err := grpcProgram(r.Program)
+	if err != nil {
+		return nil, err
+	}
+	list := listDomains(r.Provider, r.ASN, r.Source, members)
+	return &list, nil
+}
+
+func grpcGetDomain(ctx context.Context, req interface{}) (interface{}, error) {
+	detail := domainDetail(strings.ToLower(strings.TrimSpace(req.(*DomainRequest).Domain)))
+	if detail == nil {
+		return nil, status.Error(codes.NotFound, "domain not found")
+	}
+	return detail, nil
+}
+
+// grpcAuthorize checks the caller's address against allowed_cidrs and its x-api-key
+// metadata against the admin panel's API keys
+func grpcAuthorize(ctx context.Context, allowed []*net.IPNet) error {
+	if len(allowed) > 0 {
+		var ip net.IP
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			host, _, err := net.SplitHostPort(p.Addr.String())
+			if err == nil {
+				ip = net.ParseIP(host)
+			}
+		}
+		permitted := false
+		for _, ipnet := range allowed {
+			if ip != nil && ipnet.Contains(ip) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return status.Error(codes.PermissionDenied, "address not allowed")
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get("x-api-key")
+	if len(keys) == 0 || !apiKeys.Verify(keys[0]) {
+		return status.Error(codes.Unauthenticated, "valid x-api-key required")
+	}
+	return nil
+}
+
+// StartGRPCServer serves the gRPC API. Callers authenticate with the admin panel's
+// API keys, which are loaded here when the panel is off.
+func StartGRPCServer(configDir string) error {
+	cfg := GetGRPCConfig()
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	if apiKeys == nil {
+		if err := loadAPIKeys(filepath.Join(configDir, ".admin_api_keys.json")); err != nil {
+			return fmt.Errorf("failed to load api keys: %w", err)
+		}
+	}
+	allowed, err := parseAllowlist(cfg.AllowedCIDRs)
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcAuthorize(ctx, allowed); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcAuthorize(ss.Context(), allowed); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+	secure := cfg.TLSCert != "" && cfg.TLSKey != ""
+	if secure {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return fmt.Errorf("failed to load grpc tls certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Port))
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&grpcService, nil)
+
+	go func() {
+		logger.Info("grpc api starting", "port", cfg.Port, "tls", secure, "allowed_networks", len(allowed))
+		if err := server.Serve(lis); err != nil {
+			logger.Error("grpc server error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// checkGRPCConfig reports a TLS setting missing its other half
+func checkGRPCConfig(cfg *Config) []doctorCheck {
+	if !cfg.GRPC.Enabled || (cfg.GRPC.TLSCert == "") == (cfg.GRPC.TLSKey == "") {
+		return nil
+	}
+	return []doctorCheck{{"grpc", doctorWarn, "only one of tls_cert and tls_key is set, serving without TLS"}}
+}
@@ -28,6 +28,7 @@ func displayHelp() {
 	fmt.Printf("    %s domains.txt | %s -target -\n", cmdStyle.Render("cat"), cmdStyle.Render("crtmon"))
 	fmt.Printf("    %s -target %s -config %s -notify=%s\n", cmdStyle.Render("crtmon"), argStyle.Render("example.com"), argStyle.Render("custom.yaml"), argStyle.Render("discord"))
 	fmt.Printf("    %s -target %s\n", cmdStyle.Render("crtmon"), argStyle.Render("domains.txt"))
+	fmt.Printf("    %s doctor -config %s\n", cmdStyle.Render("crtmon"), argStyle.Render("custom.yaml"))
 	fmt.Printf("    %s \"@reboot %s %s -target %s > /tmp/crtmon.log 2>&1 &\" | %s -\n\n", cmdStyle.Render("echo"), cmdStyle.Render("nohup"), cmdStyle.Render("crtmon"), argStyle.Render("example.com"), cmdStyle.Render("crontab"))
 
 	fmt.Println(successStyle.Render(" options:"))
@@ -37,10 +38,22 @@ func displayHelp() {
 	fmt.Printf("                   file with domains: %s\n", argStyle.Render("-target targets.txt"))
 	fmt.Printf("                   stdin: %s\n", argStyle.Render("-target -"))
 	fmt.Printf("    %s      path to configuration file (default: ~/.config/crtmon/provider.yaml)\n", flagStyle.Render("-config"))
-	fmt.Printf("    %s      notification provider: discord, telegram, both\n", flagStyle.Render("-notify"))
+	fmt.Printf("    %s      notification provider: discord, telegram, ntfy, both (comma-separated for several)\n", flagStyle.Render("-notify"))
 	fmt.Printf("    %s     show version\n", flagStyle.Render("-version"))
 	fmt.Printf("    %s      update to latest version\n", flagStyle.Render("-update"))
+	fmt.Printf("    %s      run in the background, logging to crtmon.log in the config directory\n", flagStyle.Render("-daemon"))
+	fmt.Printf("    %s     write the process id to a file (default with -daemon: crtmon.pid in the config directory)\n", flagStyle.Render("-pidfile"))
+	fmt.Printf("    %s     log would-be notifications instead of sending them, and save nothing\n", flagStyle.Render("-dry-run"))
+	fmt.Printf("    %s  log format: text or json\n", flagStyle.Render("-log-format"))
+	fmt.Printf("    %s   log level: debug, info, warn or error\n", flagStyle.Render("-log-level"))
+	fmt.Printf("    %s    validate the configuration file and exit non-zero on errors\n", flagStyle.Render("-validate"))
 	fmt.Printf("    %s    show this help message\n\n", flagStyle.Render("-h, -help"))
+	fmt.Println(successStyle.Render(" commands:"))
+	fmt.Printf("    %s run the monitor with the options above (default when no command is given)\n", flagStyle.Render(fmt.Sprintf("%-12s", "monitor")))
+	for _, c := range commands {
+		fmt.Printf("    %s %s\n", flagStyle.Render(fmt.Sprintf("%-12s", c.name)), c.summary)
+	}
+	fmt.Printf("                 e.g. %s\n\n", argStyle.Render("crtmon search api.example.com, crtmon scan *.example.com, crtmon config validate"))
 
 	fmt.Println(successStyle.Render(" configuration:"))
 	fmt.Printf("    %s config file location: ~/.config/crtmon/provider.yaml\n", argStyle.Render("•"))
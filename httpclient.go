@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HTTPConfig sets how outbound requests are made: to CT logs, SNI sources,
+// webhooks, Telegram, ntfy and the other APIs crtmon calls
+type HTTPConfig struct {
+	Proxy              string `yaml:"proxy"`                // http://, https:// or socks5:// URL, default HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	Timeout            int    `yaml:"timeout"`              // Seconds per request, default 30
+	DownloadTimeout    int    `yaml:"download_timeout"`     // Seconds for large transfers (SNI ranges, log list, storage), default 600
+	CAFile             string `yaml:"ca_file"`              // PEM bundle trusted in addition to the system roots
+	MinTLSVersion      string `yaml:"min_tls_version"`      // "1.2" (default) or "1.3"
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // Skip certificate checks, e.g. behind an intercepting proxy
+}
+
+var httpConfig *HTTPConfig
+var httpMutex sync.Mutex
+
+// The shared clients, rebuilt by SetHTTPConfig
+var httpAPIClient, httpDownloadClient *http.Client
+
+// tlsVersions maps min_tls_version values to their TLS versions
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// SetHTTPConfig sets the outbound HTTP configuration and rebuilds the shared clients.
+// A proxy or CA file that cannot be used is logged and left out.
+func SetHTTPConfig(cfg *HTTPConfig) {
+	cfg.Proxy = strings.TrimSpace(cfg.Proxy)
+	cfg.CAFile = strings.TrimSpace(cfg.CAFile)
+	cfg.MinTLSVersion = strings.TrimSpace(cfg.MinTLSVersion)
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30
+	}
+	if cfg.DownloadTimeout <= 0 {
+		cfg.DownloadTimeout = 600
+	}
+	if cfg.MinTLSVersion == "" {
+		cfg.MinTLSVersion = "1.2"
+	}
+
+	transport := newOutboundTransport(cfg)
+
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
+	httpConfig = cfg
+	httpAPIClient = &http.Client{Transport: transport, Timeout: time.Duration(cfg.Timeout) * time.Second}
+	httpDownloadClient = &http.Client{Transport: transport, Timeout: time.Duration(cfg.DownloadTimeout) * time.Second}
+}
+
+// GetHTTPConfig returns the outbound HTTP configuration
+func GetHTTPConfig() *HTTPConfig {
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
+	return httpConfig
+}
+
+// outboundClient returns the shared client for API and webhook requests
+func outboundClient() *http.Client {
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
+	if httpAPIClient == nil {
+		return &http.Client{Transport: http.DefaultTransport, Timeout: 30 * time.Second}
+	}
+	return httpAPIClient
+}
+
+// downloadClient returns the shared client for large transfers
+func downloadClient() *http.Client {
+	httpMutex.Lock()
+	defer httpMutex.Unlock()
+	if httpDownloadClient == nil {
+		return &http.Client{Transport: http.DefaultTransport, Timeout: 600 * time.Second}
+	}
+	return httpDownloadClient
+}
+
+// errNonPublicAddress is returned for requests a certificate could point at internal
+// services: private, loopback, link-local (cloud metadata) and similar addresses
+var errNonPublicAddress = errors.New("not a public address")
+
+// isPublicIP reports whether an address is routable on the internet
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		// 0.0.0.0/8, and 100.64.0.0/10 of carrier-grade NAT, where some clouds serve metadata
+		if ip4[0] == 0 || (ip4[0] == 100 && ip4[1]&0xc0 == 64) {
+			return false
+		}
+		ip = ip4
+	}
+	return !(ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// checkPublicHost resolves a host and fails unless all of its addresses are public
+func checkPublicHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("%s: %w", host, errNonPublicAddress)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr.IP, errNonPublicAddress)
+		}
+	}
+	return nil
+}
+
+// publicDialer connects only to public addresses. The check runs on the resolved
+// address, so a DNS answer can't change between a check and the connection.
+var publicDialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+			return fmt.Errorf("%s: %w", host, errNonPublicAddress)
+		}
+		return nil
+	},
+}
+
+// proxyAddrs returns the host:port of the configured and environment proxies, which
+// are reached even on private addresses
+func proxyAddrs() map[string]bool {
+	addrs := map[string]bool{}
+	for _, raw := range []string{"http://example.com/", "https://example.com/"} {
+		req, _ := http.NewRequest(http.MethodGet, raw, nil)
+		if proxy, err := outboundProxy(req); err == nil && proxy != nil {
+			port := proxy.Port()
+			if port == "" {
+				port = map[string]string{"http": "80", "https": "443"}[proxy.Scheme]
+				if port == "" {
+					port = "1080"
+				}
+			}
+			addrs[net.JoinHostPort(proxy.Hostname(), port)] = true
+		}
+	}
+	return addrs
+}
+
+// publicClient returns a copy of the outbound client, with its proxy and TLS settings,
+// that refuses non-public addresses at every hop. It is for URLs taken from
+// certificates, which anyone can point at internal services. After maxRedirects
+// redirects the last response is returned; onRedirect, if set, sees each one followed.
+func publicClient(timeout time.Duration, maxRedirects int, onRedirect func(req *http.Request)) *http.Client {
+	client := *outboundClient()
+	client.Timeout = timeout
+	if base, ok := client.Transport.(*http.Transport); ok {
+		transport := base.Clone()
+		proxies := proxyAddrs()
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if proxies[addr] {
+				return (&net.Dialer{Timeout: 30 * time.Second}).DialContext(ctx, network, addr)
+			}
+			return publicDialer.DialContext(ctx, network, addr)
+		}
+		transport.DisableKeepAlives = true
+		client.Transport = transport
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		// Through a proxy the dialer never sees the target, so each hop is resolved here
+		if err := checkPublicHost(req.Context(), req.URL.Hostname()); err != nil {
+			return err
+		}
+		if onRedirect != nil {
+			onRedirect(req)
+		}
+		return nil
+	}
+	return &client
+}
+
+// outboundProxy returns the proxy of a request, for transports built elsewhere
+func outboundProxy(req *http.Request) (*url.URL, error) {
+	if cfg := GetHTTPConfig(); cfg != nil && cfg.Proxy != "" {
+		if proxy, err := parseProxyURL(cfg.Proxy); err == nil {
+			return proxy, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// newOutboundTransport builds the transport shared by the outbound clients
+func newOutboundTransport(cfg *HTTPConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.Proxy != "" {
+		proxy, err := parseProxyURL(cfg.Proxy)
+		if err != nil {
+			logger.Error("invalid http proxy, using the environment", "error", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if version, ok := tlsVersions[cfg.MinTLSVersion]; ok {
+		tlsConfig.MinVersion = version
+	} else {
+		logger.Error("invalid min_tls_version, using 1.2", "min_tls_version", cfg.MinTLSVersion)
+	}
+	if cfg.CAFile != "" {
+		pool, err := loadCAFile(cfg.CAFile)
+		if err != nil {
+			logger.Error("failed to load http ca_file, using system roots", "file", cfg.CAFile, "error", err)
+		} else {
+			tlsConfig.RootCAs = pool
+		}
+	}
+	if cfg.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification disabled for outbound requests")
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// parseProxyURL parses a proxy URL with an http, https, socks5 or socks5h scheme
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("proxy has no host")
+	}
+	return proxy, nil
+}
+
+// loadCAFile returns the system roots plus the certificates of a PEM file
+func loadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return pool, nil
+}
+
+// checkHTTPConfig reports problems with the outbound HTTP section
+func checkHTTPConfig(cfg HTTPConfig) []doctorCheck {
+	var checks []doctorCheck
+	if proxy := strings.TrimSpace(cfg.Proxy); proxy != "" {
+		if _, err := parseProxyURL(proxy); err != nil {
+			checks = append(checks, doctorCheck{"http", doctorFail, "proxy: " + err.Error()})
+		}
+	}
+	if version := strings.TrimSpace(cfg.MinTLSVersion); version != "" {
+		if _, ok := tlsVersions[version]; !ok {
+			checks = append(checks, doctorCheck{"http", doctorFail, "min_tls_version: must be 1.2 or 1.3"})
+		}
+	}
+	if file := strings.TrimSpace(cfg.CAFile); file != "" {
+		if _, err := loadCAFile(file); err != nil {
+			checks = append(checks, doctorCheck{"http", doctorFail, "ca_file: " + err.Error()})
+		}
+	}
+	if cfg.InsecureSkipVerify {
+		checks = append(checks, doctorCheck{"http", doctorWarn, "insecure_skip_verify disables certificate checks"})
+	}
+	return checks
+}
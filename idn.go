@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeTarget lowercases a target and converts Unicode labels to punycode (xn--).
+// Keyword targets (contains:, regex:) are checked by normalizeKeywordTarget, org:
+// targets by normalizeOrgTarget and netblocks (CIDR ranges, addresses) by
+// normalizeNetblockTarget instead.
+func normalizeTarget(target string) (string, error) {
+	if isOrgTarget(target) {
+		return normalizeOrgTarget(target)
+	}
+	if isKeywordTarget(target) {
+		return normalizeKeywordTarget(target)
+	}
+	if isNetblockTarget(target) || strings.Contains(target, "/") {
+		return normalizeNetblockTarget(target)
+	}
+	t := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(target), "."))
+
+	prefix := ""
+	if strings.HasPrefix(t, "*.") {
+		prefix = "*."
+		t = strings.TrimPrefix(t, "*.")
+	}
+
+	ascii, err := idna.Lookup.ToASCII(t)
+	if err != nil {
+		return "", fmt.Errorf("invalid target %q: %w", target, err)
+	}
+	return prefix + ascii, nil
+}
+
+// normalizeTargets normalizes a target list, dropping invalid entries and duplicates
+func normalizeTargets(list []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+
+	for _, target := range list {
+		t, err := normalizeTarget(target)
+		if err != nil {
+			logger.Warn("skipping target", "error", err)
+			continue
+		}
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		if t != strings.ToLower(strings.TrimSpace(target)) {
+			logger.Info("normalized target", "input", target, "target", t)
+		}
+		result = append(result, t)
+	}
+
+	return result
+}
+
+// displayTarget returns the Unicode form of a punycode target, or the target itself
+func displayTarget(target string) string {
+	unicode, err := idna.Display.ToUnicode(target)
+	if err != nil || unicode == target {
+		return target
+	}
+	return unicode
+}
+
+// describeTarget shows both forms of an internationalized target, e.g. "bücher.de (xn--bcher-kva.de)"
+func describeTarget(target string) string {
+	if unicode := displayTarget(target); unicode != target {
+		return fmt.Sprintf("%s (%s)", unicode, target)
+	}
+	return target
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IssuanceConfig holds settings for the per-target, per-CA issuance rate report
+type IssuanceConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	WindowDays  int     `yaml:"window_days"`  // Days of history kept for averages
+	SpikeFactor float64 `yaml:"spike_factor"` // Flag a CA whose daily count reaches this multiple of its average
+	MinCerts    int     `yaml:"min_certs"`    // Ignore spikes below this many certificates a day
+}
+
+const (
+	issuanceDayFormat = "2006-01-02"
+	// minBaselineDays is how much history a target needs before a CA counts as new
+	minBaselineDays = 7
+	// issuanceSeenTTL bounds how long a serial is remembered to skip copies in other logs
+	issuanceSeenTTL = 48 * time.Hour
+)
+
+// certSeen remembers certificates by issuer|serial, so precerts and copies in other
+// logs are handled once
+type certSeen struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// first reports whether a certificate hasn't been seen in the last issuanceSeenTTL,
+// and remembers it. Certificates without a serial are always first.
+func (s *certSeen) first(entry CertEntry) bool {
+	if entry.SerialNumber == "" {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, seenAt := range s.seen {
+		if now.Sub(seenAt) > issuanceSeenTTL {
+			delete(s.seen, key)
+		}
+	}
+	key := entry.Issuer + "|" + entry.SerialNumber
+	if _, exists := s.seen[key]; exists {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]time.Time)
+	}
+	s.seen[key] = now
+	return true
+}
+
+// IssuanceTracker counts certificates per target, per day, per issuing CA
+type IssuanceTracker struct {
+	mu     sync.Mutex
+	counts map[string]map[string]map[string]int // target -> day -> issuer -> certificates
+	seen   map[string]time.Time                 // issuer|serial, so precerts and copies in other logs count once
+	dirty  bool
+	path   string
+}
+
+// IssuanceRow is one target and CA in the issuance report
+type IssuanceRow struct {
+	Target  string         `json:"target"`
+	Issuer  string         `json:"issuer"`
+	Daily   map[string]int `json:"daily"`  // Day -> certificates
+	Total   int            `json:"total"`  // Certificates across the window
+	Latest  int            `json:"latest"` // Certificates on the report day
+	Average float64        `json:"average"`
+	Flag    string         `json:"flag,omitempty"` // new-ca or spike
+}
+
+var issuanceConfig *IssuanceConfig
+var issuanceMutex sync.Mutex
+var issuanceTracker *IssuanceTracker
+
+// SetIssuanceConfig sets the issuance report configuration
+func SetIssuanceConfig(cfg *IssuanceConfig) {
+	issuanceMutex.Lock()
+	defer issuanceMutex.Unlock()
+	issuanceConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.WindowDays <= 0 {
+		cfg.WindowDays = 30
+	}
+	if cfg.SpikeFactor <= 0 {
+		cfg.SpikeFactor = 3
+	}
+	if cfg.MinCerts <= 0 {
+		cfg.MinCerts = 5
+	}
+}
+
+// GetIssuanceConfig returns the issuance report configuration
+func GetIssuanceConfig() *IssuanceConfig {
+	issuanceMutex.Lock()
+	defer issuanceMutex.Unlock()
+	return issuanceConfig
+}
+
+// InitIssuanceTracker loads saved counts and starts periodic saving when the report is enabled
+func InitIssuanceTracker(configDir string) error {
+	cfg := GetIssuanceConfig()
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	it, err := loadIssuanceTracker(configDir)
+	if err != nil {
+		return err
+	}
+	issuanceTracker = it
+
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := it.Save(); err != nil {
+				logger.Error("failed to save issuance counts", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// loadIssuanceTracker reads the saved counts
+func loadIssuanceTracker(configDir string) (*IssuanceTracker, error) {
+	it := &IssuanceTracker{
+		counts: make(map[string]map[string]map[string]int),
+		seen:   make(map[string]time.Time),
+		path:   filepath.Join(configDir, "issuance.json"),
+	}
+	if data, err := os.ReadFile(it.path); err == nil {
+		if err := json.Unmarshal(data, &it.counts); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return it, nil
+}
+
+// RemoveIssuanceTarget deletes a target's counts, from the running tracker or, when
+// the report is off or this is a CLI run, from the saved file
+func RemoveIssuanceTarget(target string) (bool, error) {
+	it := issuanceTracker
+	if it == nil {
+		configDir, err := getConfigDir()
+		if err != nil {
+			return false, err
+		}
+		if it, err = loadIssuanceTracker(configDir); err != nil {
+			return false, err
+		}
+	}
+
+	it.mu.Lock()
+	_, found := it.counts[target]
+	if found {
+		delete(it.counts, target)
+		it.dirty = true
+	}
+	it.mu.Unlock()
+	if !found {
+		return false, nil
+	}
+	return true, it.Save()
+}
+
+// RecordIssuance counts a certificate once for each target it matched
+func RecordIssuance(entry CertEntry, decisions []EntryDecision) {
+	it := issuanceTracker
+	if it == nil {
+		return
+	}
+
+	matched := make(map[string]bool)
+	for _, d := range decisions {
+		if d.Matched && !d.Excluded {
+			for _, target := range d.Targets {
+				matched[target] = true
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	issuer := entry.Issuer
+	if issuer == "" {
+		issuer = "unknown"
+	}
+	now := time.Now()
+	day := now.Format(issuanceDayFormat)
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if entry.SerialNumber != "" {
+		key := issuer + "|" + entry.SerialNumber
+		if _, exists := it.seen[key]; exists {
+			return
+		}
+		it.seen[key] = now
+	}
+
+	for target := range matched {
+		days := it.counts[target]
+		if days == nil {
+			days = make(map[string]map[string]int)
+			it.counts[target] = days
+		}
+		if days[day] == nil {
+			days[day] = make(map[string]int)
+		}
+		days[day][issuer]++
+	}
+	it.dirty = true
+}
+
+// Save prunes counts outside the window and writes them to disk if anything changed
+func (it *IssuanceTracker) Save() error {
+	if isDryRun() {
+		return nil
+	}
+	cfg := GetIssuanceConfig()
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	now := time.Now()
+	for key, seenAt := range it.seen {
+		if now.Sub(seenAt) > issuanceSeenTTL {
+			delete(it.seen, key)
+		}
+	}
+	if !it.dirty {
+		return nil
+	}
+
+	if cfg != nil {
+		cutoff := now.AddDate(0, 0, -cfg.WindowDays).Format(issuanceDayFormat)
+		for target, days := range it.counts {
+			for day := range days {
+				if day < cutoff {
+					delete(days, day)
+				}
+			}
+			if len(days) == 0 {
+				delete(it.counts, target)
+			}
+		}
+	}
+
+	data, err := json.Marshal(it.counts)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(it.path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	it.dirty = false
+	return os.Rename(it.path+".tmp", it.path)
+}
+
+// BuildIssuanceReport compares each CA's certificates on day with its average over
+// the earlier days of the window, flagging CAs new to a target and sudden spikes
+func BuildIssuanceReport(day time.Time) []IssuanceRow {
+	it := issuanceTracker
+	cfg := GetIssuanceConfig()
+	if it == nil || cfg == nil {
+		return nil
+	}
+	reportDay := day.Format(issuanceDayFormat)
+	windowStart := day.AddDate(0, 0, -(cfg.WindowDays - 1)).Format(issuanceDayFormat)
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	var rows []IssuanceRow
+	for target, days := range it.counts {
+		// Days of baseline before the report day, from the first day anything was seen
+		first := reportDay
+		for d := range days {
+			if d >= windowStart && d < first {
+				first = d
+			}
+		}
+		firstDay, _ := time.ParseInLocation(issuanceDayFormat, first, day.Location())
+		baseline := int(day.Sub(firstDay).Hours() / 24)
+
+		byIssuer := make(map[string]*IssuanceRow)
+		for d, issuers := range days {
+			if d < windowStart || d > reportDay {
+				continue
+			}
+			for issuer, count := range issuers {
+				row := byIssuer[issuer]
+				if row == nil {
+					row = &IssuanceRow{Target: target, Issuer: issuer, Daily: make(map[string]int)}
+					byIssuer[issuer] = row
+				}
+				row.Daily[d] = count
+				row.Total += count
+				if d == reportDay {
+					row.Latest = count
+				}
+			}
+		}
+
+		for _, row := range byIssuer {
+			prior := row.Total - row.Latest
+			if baseline > 0 {
+				row.Average = float64(prior) / float64(baseline)
+			}
+			switch {
+			case row.Latest > 0 && prior == 0 && baseline >= minBaselineDays:
+				row.Flag = "new-ca"
+			case baseline > 0 && row.Latest >= cfg.MinCerts && float64(row.Latest) >= cfg.SpikeFactor*max(row.Average, 1):
+				row.Flag = "spike"
+			}
+			rows = append(rows, *row)
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Target != rows[j].Target {
+			return rows[i].Target < rows[j].Target
+		}
+		return rows[i].Latest > rows[j].Latest
+	})
+	return rows
+}
+
+// describeIssuance formats the report day for the daily summary: each target's
+// busiest CAs plus every flagged one
+func describeIssuance(rows []IssuanceRow) string {
+	const maxLines = 15
+	var lines []string
+	shown := make(map[string]int)
+	for _, row := range rows {
+		if row.Latest == 0 || (row.Flag == "" && shown[row.Target] >= 3) {
+			continue
+		}
+		shown[row.Target]++
+
+		line := fmt.Sprintf("   %s: %s %d (avg %.1f/day)", row.Target, row.Issuer, row.Latest, row.Average)
+		switch row.Flag {
+		case "new-ca":
+			line += " ⚠️ new CA"
+		case "spike":
+			line += " ⚠️ spike"
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines], fmt.Sprintf("   ... %d more", len(lines)-maxLines))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IssuerPolicyConfig declares which certificate authorities may issue for each target.
+// Issuers match case-insensitively against the issuer organization or common name.
+type IssuerPolicyConfig struct {
+	Allowed map[string][]string `yaml:"allowed"` // Target -> expected issuers; "*" covers targets without their own list
+	Denied  map[string][]string `yaml:"denied"`  // Target -> forbidden issuers; "*" covers every target
+	Webhook string              `yaml:"webhook"` // Falls back to the main webhook when empty
+}
+
+// issuerViolation is a target whose policy a certificate breaks, and the domains it covers there
+type issuerViolation struct {
+	target  string
+	reason  string
+	domains []string
+}
+
+var issuerPolicyConfig *IssuerPolicyConfig
+var issuerPolicyMutex sync.Mutex
+
+// issuerPolicySeen holds the certificates already alerted on
+var issuerPolicySeen certSeen
+
+// SetIssuerPolicyConfig sets the issuer policy configuration
+func SetIssuerPolicyConfig(cfg *IssuerPolicyConfig) {
+	issuerPolicyMutex.Lock()
+	defer issuerPolicyMutex.Unlock()
+	issuerPolicyConfig = cfg
+}
+
+// GetIssuerPolicyConfig returns the issuer policy configuration
+func GetIssuerPolicyConfig() *IssuerPolicyConfig {
+	issuerPolicyMutex.Lock()
+	defer issuerPolicyMutex.Unlock()
+	return issuerPolicyConfig
+}
+
+// CheckIssuerPolicy flags the domains of a certificate issued outside their targets'
+// policy and sends a high-priority alert
+func CheckIssuerPolicy(entry CertEntry, decisions []EntryDecision) {
+	cfg := GetIssuerPolicyConfig()
+	if cfg == nil || (len(cfg.Allowed) == 0 && len(cfg.Denied) == 0) {
+		return
+	}
+
+	byTarget := make(map[string]*issuerViolation)
+	var violations []*issuerViolation
+	for _, d := range decisions {
+		if !d.Matched || d.Excluded {
+			continue
+		}
+		for _, target := range d.Targets {
+			v, checked := byTarget[target]
+			if !checked {
+				if reason := cfg.violation(target, entry); reason != "" {
+					v = &issuerViolation{target: target, reason: reason}
+					violations = append(violations, v)
+				}
+				byTarget[target] = v
+			}
+			if v != nil {
+				v.domains = append(v.domains, d.Domain)
+			}
+		}
+	}
+	if len(violations) == 0 || !issuerPolicySeen.first(entry) {
+		return
+	}
+
+	issuer := describeIssuer(entry)
+	dt := GetDomainTracker()
+	for _, v := range violations {
+		for _, domain := range v.domains {
+			dt.RecordUnexpectedIssuer(domain, issuer)
+		}
+		logger.Warn("certificate from unexpected issuer", "target", v.target, "issuer", issuer, "reason", v.reason, "domains", v.domains, "serial", entry.SerialNumber)
+	}
+	sendIssuerPolicyAlert(cfg, entry, issuer, violations)
+}
+
+// violation returns why a certificate breaks a target's policy, or ""
+func (cfg *IssuerPolicyConfig) violation(target string, entry CertEntry) string {
+	for _, key := range []string{target, "*"} {
+		if issuerMatches(entry, cfg.Denied[key]) {
+			return "denied issuer"
+		}
+	}
+	allowed, ok := cfg.Allowed[target]
+	if !ok {
+		allowed = cfg.Allowed["*"]
+	}
+	if len(allowed) > 0 && !issuerMatches(entry, allowed) {
+		return "issuer not in allowlist"
+	}
+	return ""
+}
+
+// issuerMatches reports whether a certificate's issuer organization or common name
+// contains any of the names
+func issuerMatches(entry CertEntry, names []string) bool {
+	return containsAnyFold(entry.IssuerOrg, names) || containsAnyFold(entry.Issuer, names)
+}
+
+// describeIssuer returns a certificate's issuer as "Org (CN)"
+func describeIssuer(entry CertEntry) string {
+	switch {
+	case entry.IssuerOrg != "" && entry.Issuer != "":
+		return entry.IssuerOrg + " (" + entry.Issuer + ")"
+	case entry.IssuerOrg != "":
+		return entry.IssuerOrg
+	case entry.Issuer != "":
+		return entry.Issuer
+	}
+	return "unknown"
+}
+
+// sendIssuerPolicyAlert sends a dedicated high-priority alert for a certificate from an
+// unexpected issuer to Discord and ntfy
+func sendIssuerPolicyAlert(cfg *IssuerPolicyConfig, entry CertEntry, issuer string, violations []*issuerViolation) {
+	var targets, domains []string
+	for _, v := range violations {
+		targets = append(targets, v.target)
+		domains = append(domains, v.domains...)
+	}
+	sort.Strings(domains)
+	domains = slices.Compact(domains)
+	if isDryRun() {
+		logger.Info("dry run: would send unexpected issuer alert", "domains", domains, "issuer", issuer)
+		return
+	}
+
+	if alertConfigured(cfg.Webhook) {
+		if err := sendAlert(cfg.Webhook, buildIssuerPolicyPayload(entry, issuer, violations, domains)); err != nil {
+			logger.Error("failed to send unexpected issuer alert", "issuer", issuer, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("unexpected issuer alert for %s: %v", strings.Join(targets, ", "), err))
+		}
+	}
+	if isNtfyConfigured() {
+		body := fmt.Sprintf("Issuer: %s\nTargets: %s\nDomains: %s", issuer, strings.Join(targets, ", "), strings.Join(domains, ", "))
+		if err := sendUrgentNtfy("Unexpected certificate issuer: "+domains[0], body, domains[0]); err != nil {
+			logger.Error("failed to send unexpected issuer ntfy alert", "issuer", issuer, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("unexpected issuer ntfy alert for %s: %v", strings.Join(targets, ", "), err))
+		}
+	}
+}
+
+// buildIssuerPolicyPayload builds a Discord embed for a certificate from an unexpected issuer
+func buildIssuerPolicyPayload(entry CertEntry, issuer string, violations []*issuerViolation, domains []string) map[string]interface{} {
+	var reasons []string
+	for _, v := range violations {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", v.target, v.reason))
+	}
+	description := fmt.Sprintf("**Issuer**: %s\n**Targets**: %s\n**Serial**: `%s`\n**Log**: %s\n**Domains**:\n```\n%s\n```",
+		issuer, strings.Join(reasons, ", "), entry.SerialNumber, entry.LogURL, strings.Join(domains, "\n"))
+	embed := map[string]interface{}{
+		"title":       "Unexpected certificate issuer: " + domains[0],
+		"description": description,
+		"color":       15158332, // Red
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if link := dashboardDomainLink(domains[0]); link != "" {
+		embed["url"] = link
+	}
+	return map[string]interface{}{
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
+	}
+}
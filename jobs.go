@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobFinished  = "finished"
+	jobFailed    = "failed"
+	jobTimeout   = "timeout"
+	jobCancelled = "cancelled"
+)
+
+const (
+	// maxFinishedJobs bounds how many completed jobs are kept for /api/jobs
+	maxFinishedJobs = 100
+	// jobWaitDelay bounds how long to wait for output after a job is killed
+	jobWaitDelay = 10 * time.Second
+	// jobStderrLimit is how much of a job's stderr is kept, enough for its last line
+	jobStderrLimit = 4096
+)
+
+// Job is a single enumeration tool invocation
+type Job struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"` // feroxbuster, puredns, nuclei, or a configured tool
+	Domain     string    `json:"domain"`
+	Target     string    `json:"target"`
+	Status     string    `json:"status"`
+	OutputFile string    `json:"output_file"`
+	Error      string    `json:"error,omitempty"`
+	QueuedAt   time.Time `json:"queued_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+
+	path    string
+	args    []string
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+// JobManager runs enumeration jobs in-process with global and per-tool concurrency limits
+type JobManager struct {
+	mu            sync.Mutex
+	jobs          map[string]*Job
+	queue         []*Job
+	running       int
+	runningByType map[string]int
+	finished      []string // Job IDs in completion order
+	nextID        int
+	closed        bool // Shutting down, no new jobs are accepted
+}
+
+var jobManager = &JobManager{
+	jobs:          make(map[string]*Job),
+	runningByType: make(map[string]int),
+}
+
+// GetJobManager returns the global job manager
+func GetJobManager() *JobManager {
+	return jobManager
+}
+
+// jobLimits returns the global concurrency limit, per-tool limits and queue capacity
+func jobLimits() (global int, perTool map[string]int, maxQueued int) {
+	enumMutex.Lock()
+	defer enumMutex.Unlock()
+
+	global, maxQueued = 3, 100
+	if isLowResourceMode() {
+		global, maxQueued = 1, 20
+	}
+	if enumConfig == nil {
+		return global, nil, maxQueued
+	}
+	if enumConfig.MaxConcurrentScans > 0 {
+		global = enumConfig.MaxConcurrentScans
+	}
+	if enumConfig.MaxQueuedScans > 0 {
+		maxQueued = enumConfig.MaxQueuedScans
+	}
+	return global, enumConfig.MaxConcurrentPerTool, maxQueued
+}
+
+// Submit queues a job and starts it when a slot is free. It fails when the queue is full.
+func (jm *JobManager) Submit(scanType, domain, target, path string, args []string, outputFile string, timeoutSeconds int) (*Job, error) {
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 1 * time.Hour // Default 1 hour timeout
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if jm.closed {
+		return nil, fmt.Errorf("shutting down, scan not queued")
+	}
+
+	// A domain notified under several targets is only scanned once; output files are named by domain
+	for _, existing := range jm.jobs {
+		if existing.Type == scanType && existing.Domain == domain && (existing.Status == jobQueued || existing.Status == jobRunning) {
+			logger.Debug("scan already queued", "id", existing.ID, "domain", domain)
+			return existing, nil
+		}
+	}
+
+	// Apply backpressure instead of growing without bound
+	if _, _, maxQueued := jobLimits(); len(jm.queue) >= maxQueued {
+		return nil, fmt.Errorf("scan queue full (%d queued)", len(jm.queue))
+	}
+
+	jm.nextID++
+	job := &Job{
+		ID:         fmt.Sprintf("%s-%d", scanType, jm.nextID),
+		Type:       scanType,
+		Domain:     domain,
+		Target:     target,
+		Status:     jobQueued,
+		OutputFile: outputFile,
+		QueuedAt:   time.Now(),
+		path:       path,
+		args:       args,
+		timeout:    timeout,
+	}
+	jm.jobs[job.ID] = job
+	jm.queue = append(jm.queue, job)
+
+	logger.Info("scan queued", "id", job.ID, "domain", domain, "queued", len(jm.queue))
+	jm.dispatch()
+	return job, nil
+}
+
+// dispatch starts queued jobs, oldest first, while global and per-tool slots are free.
+// Caller must hold jm.mu.
+func (jm *JobManager) dispatch() {
+	global, perTool, _ := jobLimits()
+
+	var waiting []*Job
+	for _, job := range jm.queue {
+		if jm.running >= global {
+			waiting = append(waiting, job)
+			continue
+		}
+		if limit, ok := perTool[job.Type]; ok && limit > 0 && jm.runningByType[job.Type] >= limit {
+			waiting = append(waiting, job)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), job.timeout)
+		job.cancel = cancel
+		job.Status = jobRunning
+		job.StartedAt = time.Now()
+		jm.running++
+		jm.runningByType[job.Type]++
+
+		go jm.run(ctx, job)
+	}
+	jm.queue = waiting
+}
+
+// run executes a job, then reports results and frees its slot
+func (jm *JobManager) run(ctx context.Context, job *Job) {
+	st := GetStatsTracker()
+	switch job.Type {
+	case "feroxbuster":
+		st.IncrementActiveFeroxScans()
+	case "puredns":
+		st.IncrementActivePurednsScans()
+	}
+
+	logger.Info("started scan", "id", job.ID, "type", job.Type, "domain", job.Domain, "output", job.OutputFile)
+	publishLive(liveScanStarted, map[string]interface{}{"id": job.ID, "type": job.Type, "domain": job.Domain, "target": job.Target})
+
+	// Stdout goes to disk, since tools can print far more than is worth holding in memory
+	stdoutPath := job.OutputFile + ".stdout"
+	stdout, err := os.Create(stdoutPath)
+	if err != nil {
+		logger.Warn("failed to capture scan stdout", "id", job.ID, "error", err)
+	}
+	stderr := &tailBuffer{limit: jobStderrLimit}
+	cmd := exec.CommandContext(ctx, job.path, job.args...)
+	if stdout != nil {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = stderr
+	cmd.WaitDelay = jobWaitDelay
+	configureJobProcess(cmd)
+	err = cmd.Run()
+
+	// Tools that only print to stdout still get their results delivered
+	if stdout != nil {
+		info, statErr := stdout.Stat()
+		stdout.Close()
+		if _, outErr := os.Stat(job.OutputFile); os.IsNotExist(outErr) && statErr == nil && info.Size() > 0 {
+			os.Rename(stdoutPath, job.OutputFile)
+		} else {
+			os.Remove(stdoutPath)
+		}
+	}
+
+	status := jobFinished
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		status = jobTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		status = jobCancelled
+	case err != nil:
+		status = jobFailed
+	}
+
+	jm.mu.Lock()
+	job.Status = status
+	job.FinishedAt = time.Now()
+	if err != nil && status == jobFailed {
+		job.Error = strings.TrimSpace(fmt.Sprintf("%v: %s", err, lastLine(stderr.String())))
+	}
+	job.cancel()
+	jm.running--
+	jm.runningByType[job.Type]--
+	jm.recordFinished(job.ID)
+	jm.dispatch()
+	jm.mu.Unlock()
+
+	publishLive(liveScanFinished, map[string]interface{}{"id": job.ID, "type": job.Type, "domain": job.Domain, "target": job.Target, "status": status})
+
+	success := status == jobFinished
+	switch job.Type {
+	case "feroxbuster":
+		st.DecrementActiveFeroxScans(success)
+	case "puredns":
+		st.DecrementActivePurednsScans(success)
+	}
+
+	switch status {
+	case jobFinished:
+		logger.Info("scan completed", "id", job.ID, "domain", job.Domain, "type", job.Type)
+		sendScanResultsToDiscord(job.Target, job.Domain, job.OutputFile, job.Type, false)
+	case jobTimeout:
+		logger.Warn("scan timeout reached", "id", job.ID, "domain", job.Domain, "type", job.Type)
+		sendScanResultsToDiscord(job.Target, job.Domain, job.OutputFile, job.Type, true)
+	case jobFailed:
+		logger.Error("scan failed", "id", job.ID, "domain", job.Domain, "type", job.Type, "error", job.Error)
+		RecordError(errCategoryScan, fmt.Sprintf("%s %s: %s", job.Type, job.Domain, job.Error))
+	case jobCancelled:
+		logger.Info("scan cancelled", "id", job.ID, "domain", job.Domain, "type", job.Type)
+	}
+	publishBusScan(*job)
+
+	// Results have been read, move the output to object storage if configured
+	if ref := storeArtifact(artifactScans, job.OutputFile, "text/plain"); ref != job.OutputFile {
+		jm.mu.Lock()
+		job.OutputFile = ref
+		jm.mu.Unlock()
+	}
+}
+
+// recordFinished remembers a completed job, evicting the oldest. Caller must hold jm.mu.
+func (jm *JobManager) recordFinished(id string) {
+	jm.finished = append(jm.finished, id)
+	if len(jm.finished) > maxFinishedJobs {
+		delete(jm.jobs, jm.finished[0])
+		jm.finished = jm.finished[1:]
+	}
+}
+
+// Cancel stops a running job or removes a queued one
+func (jm *JobManager) Cancel(id string) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job, exists := jm.jobs[id]
+	if !exists {
+		return fmt.Errorf("job not found")
+	}
+
+	switch job.Status {
+	case jobRunning:
+		job.cancel()
+	case jobQueued:
+		for i, j := range jm.queue {
+			if j.ID == id {
+				jm.queue = append(jm.queue[:i], jm.queue[i+1:]...)
+				break
+			}
+		}
+		job.Status = jobCancelled
+		job.FinishedAt = time.Now()
+		jm.recordFinished(id)
+	default:
+		return fmt.Errorf("job already %s", job.Status)
+	}
+	return nil
+}
+
+// Shutdown stops accepting jobs, drops the queue and cancels running scans, waiting
+// up to timeout for them to exit. Partial output stays on disk.
+func (jm *JobManager) Shutdown(timeout time.Duration) {
+	jm.mu.Lock()
+	jm.closed = true
+	for _, job := range jm.queue {
+		job.Status = jobCancelled
+		job.FinishedAt = time.Now()
+		jm.recordFinished(job.ID)
+	}
+	jm.queue = nil
+	running := jm.running
+	for _, job := range jm.jobs {
+		if job.Status == jobRunning {
+			job.cancel()
+		}
+	}
+	jm.mu.Unlock()
+
+	if running == 0 {
+		return
+	}
+	logger.Info("cancelling running scans", "count", running)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if running, _ := jm.Counts(); running == 0 {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	logger.Warn("scans still running at shutdown", "timeout", timeout)
+}
+
+// List returns all known jobs, newest first
+func (jm *JobManager) List() []Job {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	result := make([]Job, 0, len(jm.jobs))
+	for _, job := range jm.jobs {
+		result = append(result, *job)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].QueuedAt.After(result[j].QueuedAt)
+	})
+	return result
+}
+
+// Counts returns the number of running and queued jobs
+func (jm *JobManager) Counts() (running, queued int) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	return jm.running, len(jm.queue)
+}
+
+// QueueDepthByType returns queued job counts per tool
+func (jm *JobManager) QueueDepthByType() map[string]int {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	depth := make(map[string]int)
+	for _, job := range jm.queue {
+		depth[job.Type]++
+	}
+	return depth
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	b     []byte
+	limit int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if len(t.b) > t.limit {
+		t.b = append(t.b[:0:0], t.b[len(t.b)-t.limit:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.b)
+}
+
+// lastLine returns the last non-empty line of tool output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// configureJobProcess relies on exec.CommandContext killing the process;
+// Windows has no process groups to signal
+func configureJobProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Record types written to Kafka
+const (
+	kafkaMatch   = "match"
+	kafkaFinding = "finding"
+)
+
+// Produce requests carry what is queued, up to these limits. The byte limit stays
+// under the broker's default message.max.bytes of 1MB.
+const (
+	kafkaMaxBatch      = 500
+	kafkaMaxBatchBytes = 768 << 10
+)
+
+// KafkaConfig holds settings for archiving matches and findings to Kafka
+type KafkaConfig struct {
+	Brokers       []string        `yaml:"brokers"`        // Bootstrap brokers, host:port
+	Topic         string          `yaml:"topic"`          // Default "crtmon"
+	FindingsTopic string          `yaml:"findings_topic"` // Default the same as topic
+	Acks          string          `yaml:"acks"`           // 1 waits for the leader (default), all for every in-sync replica
+	TLS           bool            `yaml:"tls"`
+	CAFile        string          `yaml:"ca_file"` // CA bundle for TLS, default system roots
+	SASL          KafkaSASLConfig `yaml:"sasl"`
+	QueueSize     int             `yaml:"queue_size"` // Records held while Kafka is unreachable, default 10000
+}
+
+// KafkaSASLConfig authenticates to the brokers
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// KafkaRecord is the JSON value of each record, keyed by domain
+type KafkaRecord struct {
+	Type    string     `json:"type"` // match or finding
+	Time    time.Time  `json:"time"`
+	Match   *CertMatch `json:"match,omitempty"`
+	Finding *Finding   `json:"finding,omitempty"`
+}
+
+// kafkaItem is an encoded record waiting to be produced
+type kafkaItem struct {
+	topic string
+	key   []byte
+	value []byte
+	time  time.Time
+}
+
+var kafkaConfig *KafkaConfig
+var kafkaMutex sync.Mutex
+var kafkaQueue chan kafkaItem
+var kafkaStart sync.Once
+var kafkaDropped atomic.Int64 // Records dropped since Kafka was last reachable
+
+// kafkaTopicPattern is what Kafka accepts as a topic name
+var kafkaTopicPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// SetKafkaConfig sets the Kafka configuration, starting the producer the first time
+// brokers are set
+func SetKafkaConfig(cfg *KafkaConfig) {
+	kafkaMutex.Lock()
+	defer kafkaMutex.Unlock()
+	kafkaDefaults(cfg)
+	kafkaConfig = cfg
+
+	if len(cfg.Brokers) > 0 {
+		kafkaStart.Do(func() {
+			kafkaQueue = make(chan kafkaItem, cfg.QueueSize)
+			go runKafkaProducer()
+		})
+	}
+}
+
+// kafkaDefaults trims the brokers and fills in unset values
+func kafkaDefaults(cfg *KafkaConfig) {
+	var brokers []string
+	for _, b := range cfg.Brokers {
+		if b = strings.TrimSpace(b); b != "" {
+			brokers = append(brokers, b)
+		}
+	}
+	cfg.Brokers = brokers
+	if cfg.Topic == "" {
+		cfg.Topic = "crtmon"
+	}
+	if cfg.FindingsTopic == "" {
+		cfg.FindingsTopic = cfg.Topic
+	}
+	if cfg.Acks == "" {
+		cfg.Acks = "1"
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 10000
+	}
+}
+
+// GetKafkaConfig returns the Kafka configuration
+func GetKafkaConfig() *KafkaConfig {
+	kafkaMutex.Lock()
+	defer kafkaMutex.Unlock()
+	return kafkaConfig
+}
+
+// kafkaEnabled reports whether records are archived, and the configuration to use
+func kafkaEnabled() (*KafkaConfig, bool) {
+	cfg := GetKafkaConfig()
+	if cfg == nil || len(cfg.Brokers) == 0 || kafkaQueue == nil || isDryRun() {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// archiveKafka queues a record without waiting for Kafka. When the queue is full the
+// record is dropped.
+func archiveKafka(topic, key string, record KafkaRecord) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		logger.Error("failed to encode kafka record", "type", record.Type, "error", err)
+		return
+	}
+	item := kafkaItem{topic: topic, key: []byte(key), value: value, time: record.Time}
+	select {
+	case kafkaQueue <- item:
+	default:
+		if kafkaDropped.Add(1) == 1 {
+			logger.Warn("kafka queue full, dropping records until it is reachable", "queue_size", cap(kafkaQueue))
+		}
+	}
+}
+
+// archiveKafkaMatches archives every matched domain of a certificate, excluded and
+// duplicate ones included
+func archiveKafkaMatches(entry CertEntry, decisions []EntryDecision) {
+	cfg, ok := kafkaEnabled()
+	if !ok {
+		return
+	}
+	for _, m := range certMatches(entry, decisions) {
+		archiveKafka(cfg.Topic, m.Domain, KafkaRecord{Type: kafkaMatch, Time: m.Time, Match: &m})
+	}
+}
+
+// archiveKafkaFindings archives every finding of a scan, as recorded in the findings store
+func archiveKafkaFindings(findings []Finding) {
+	cfg, ok := kafkaEnabled()
+	if !ok {
+		return
+	}
+	for _, f := range findings {
+		archiveKafka(cfg.FindingsTopic, f.Domain, KafkaRecord{Type: kafkaFinding, Time: f.LastSeen, Finding: &f})
+	}
+}
+
+// runKafkaProducer produces queued records in batches, reconnecting with backoff. A
+// batch is retried until it is produced, until the brokers are removed or until the
+// brokers reject it as invalid.
+func runKafkaProducer() {
+	var producer *kafkaProducer
+	wait := time.Second
+
+	for item := range kafkaQueue {
+		batch := []kafkaItem{item}
+		size := len(item.value)
+	fill:
+		for len(batch) < kafkaMaxBatch && size < kafkaMaxBatchBytes {
+			select {
+			case next := <-kafkaQueue:
+				batch = append(batch, next)
+				size += len(next.value)
+			default:
+				break fill
+			}
+		}
+
+		for {
+			cfg := GetKafkaConfig()
+			if cfg == nil || len(cfg.Brokers) == 0 {
+				break
+			}
+			if producer == nil || !reflect.DeepEqual(producer.cfg, *cfg) {
+				if producer != nil {
+					producer.Close()
+				}
+				var err error
+				producer, err = dialKafka(*cfg)
+				if err != nil {
+					producer = nil
+					logger.Warn("failed to connect to kafka", "retry_in", wait, "error", redactSecrets(err.Error()))
+					RecordError(errCategoryKafka, redactSecrets(err.Error()))
+					time.Sleep(wait)
+					wait = min(wait*2, time.Minute)
+					continue
+				}
+				wait = time.Second
+				logger.Info("connected to kafka", "brokers", len(cfg.Brokers), "topic", cfg.Topic)
+			}
+
+			err := producer.produce(batch)
+			var kerr kafkaError
+			if errors.As(err, &kerr) && kerr.rejected() {
+				logger.Error("kafka rejected records, dropping them", "records", len(batch), "error", err)
+				RecordError(errCategoryKafka, err.Error())
+				break
+			}
+			if err != nil {
+				logger.Warn("failed to produce to kafka, reconnecting", "records", len(batch), "error", err)
+				RecordError(errCategoryKafka, err.Error())
+				producer.Close()
+				producer = nil
+				time.Sleep(wait)
+				wait = min(wait*2, time.Minute)
+				continue
+			}
+			if dropped := kafkaDropped.Swap(0); dropped > 0 {
+				logger.Warn("kafka reachable again", "dropped", dropped)
+			}
+			break
+		}
+	}
+}
+
+// checkKafkaConfig reports Kafka settings that can't work
+func checkKafkaConfig(cfg KafkaConfig) []doctorCheck {
+	if len(cfg.Brokers) == 0 {
+		return nil
+	}
+	var checks []doctorCheck
+	for _, b := range cfg.Brokers {
+		if _, _, err := net.SplitHostPort(strings.TrimSpace(b)); err != nil {
+			checks = append(checks, doctorCheck{"kafka", doctorFail, fmt.Sprintf("broker %q is not host:port", b)})
+		}
+	}
+	for _, topic := range []string{cfg.Topic, cfg.FindingsTopic} {
+		if topic != "" && !kafkaTopicPattern.MatchString(topic) {
+			checks = append(checks, doctorCheck{"kafka", doctorFail, fmt.Sprintf("invalid topic %q", topic)})
+		}
+	}
+	switch strings.ToLower(cfg.Acks) {
+	case "", "1", "all", "-1":
+	default:
+		checks = append(checks, doctorCheck{"kafka", doctorFail, fmt.Sprintf("unsupported acks %q, use 1 or all", cfg.Acks)})
+	}
+	if cfg.SASL.Mechanism != "" {
+		if _, ok := kafkaSASLMechanisms[strings.ToUpper(cfg.SASL.Mechanism)]; !ok {
+			checks = append(checks, doctorCheck{"kafka", doctorFail, fmt.Sprintf("unsupported sasl mechanism %q, use PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", cfg.SASL.Mechanism)})
+		} else if cfg.SASL.Username == "" {
+			checks = append(checks, doctorCheck{"kafka", doctorFail, "sasl needs a username"})
+		}
+		if !cfg.TLS && strings.EqualFold(cfg.SASL.Mechanism, "PLAIN") {
+			checks = append(checks, doctorCheck{"kafka", doctorWarn, "sasl PLAIN without tls sends the password in clear text"})
+		}
+	}
+	if cfg.CAFile != "" && !cfg.TLS {
+		checks = append(checks, doctorCheck{"kafka", doctorWarn, "ca_file is set but tls is off"})
+	}
+	return checks
+}
+
+// checkKafka connects to the configured brokers and looks up the topics
+func checkKafka(cfg *Config) []doctorCheck {
+	if len(cfg.Kafka.Brokers) == 0 {
+		return nil
+	}
+	kcfg := cfg.Kafka
+	kafkaDefaults(&kcfg)
+	producer, err := dialKafka(kcfg)
+	if err != nil {
+		return []doctorCheck{{"kafka", doctorFail, redactSecrets(err.Error())}}
+	}
+	producer.Close()
+	return []doctorCheck{{"kafka", doctorPass, fmt.Sprintf("connected, %d partitions of %s", len(producer.partitions[kcfg.Topic]), kcfg.Topic)}}
+}
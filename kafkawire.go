@@ -0,0 +1,643 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kafkaTimeout bounds connecting to a broker and each request
+const kafkaTimeout = 10 * time.Second
+
+// Kafka API keys and the versions crtmon speaks. These are supported from Kafka 1.0
+// on and are still accepted by 4.x.
+const (
+	kafkaProduce          = 0
+	kafkaMetadata         = 3
+	kafkaSASLHandshake    = 17
+	kafkaSASLAuthenticate = 36
+
+	kafkaProduceVersion  = 3
+	kafkaMetadataVersion = 4
+)
+
+// kafkaSASLMechanisms maps supported mechanisms to the SCRAM hash, nil for PLAIN
+var kafkaSASLMechanisms = map[string]func() hash.Hash{
+	"PLAIN":         nil,
+	"SCRAM-SHA-256": sha256.New,
+	"SCRAM-SHA-512": sha512.New,
+}
+
+// castagnoli is the CRC-32C table record batches are checksummed with
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaError is an error code returned by a broker
+type kafkaError int16
+
+// kafkaErrorNames names the codes crtmon is likely to see
+var kafkaErrorNames = map[kafkaError]string{
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	18: "RECORD_LIST_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+func (e kafkaError) Error() string {
+	if name, ok := kafkaErrorNames[e]; ok {
+		return fmt.Sprintf("kafka: %s (%d)", name, int16(e))
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// rejected reports whether the records themselves were refused, so sending them
+// again can't succeed
+func (e kafkaError) rejected() bool {
+	switch e {
+	case 2, 10, 17, 18, 87:
+		return true
+	}
+	return false
+}
+
+// kafkaEncoder appends big-endian protocol fields
+type kafkaEncoder struct {
+	b []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+func (e *kafkaEncoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// kafkaDecoder reads protocol fields, keeping the first error
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = errors.New("kafka: short response")
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a string, nullable ones as ""
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) bytes() []byte {
+	n := d.int32()
+	if n < 0 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// array reads an array length, treating a null array as empty
+func (d *kafkaDecoder) array() int {
+	n := d.int32()
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	if int(n) > len(d.b) {
+		d.err = errors.New("kafka: short response")
+		return 0
+	}
+	return int(n)
+}
+
+// kafkaRecordBatch encodes records as one uncompressed v2 record batch
+func kafkaRecordBatch(items []kafkaItem) []byte {
+	base := items[0].time.UnixMilli()
+	maxTime := base
+	var records []byte
+	for i, item := range items {
+		ts := item.time.UnixMilli()
+		maxTime = max(maxTime, ts)
+		r := []byte{0} // attributes
+		r = binary.AppendVarint(r, ts-base)
+		r = binary.AppendVarint(r, int64(i))
+		r = binary.AppendVarint(r, int64(len(item.key)))
+		r = append(r, item.key...)
+		r = binary.AppendVarint(r, int64(len(item.value)))
+		r = append(r, item.value...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// Everything after the CRC field is checksummed
+	var body kafkaEncoder
+	body.int16(0) // attributes: no compression, create time
+	body.int32(int32(len(items) - 1))
+	body.int64(base)
+	body.int64(maxTime)
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(int32(len(items)))
+	body.b = append(body.b, records...)
+
+	var e kafkaEncoder
+	e.int64(0) // base offset, assigned by the broker
+	e.int32(int32(4 + 1 + 4 + len(body.b)))
+	e.int32(-1) // partition leader epoch
+	e.int8(2)   // magic
+	e.int32(int32(crc32.Checksum(body.b, castagnoli)))
+	e.b = append(e.b, body.b...)
+	return e.b
+}
+
+// kafkaBroker is a connection to one broker
+type kafkaBroker struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	correlation int32
+}
+
+// dialKafkaBroker connects to a broker and authenticates
+func dialKafkaBroker(cfg KafkaConfig, addr string, tlsConfig *tls.Config) (*kafkaBroker, error) {
+	conn, err := net.DialTimeout("tcp", addr, kafkaTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		host, _, _ := net.SplitHostPort(addr)
+		c := tlsConfig.Clone()
+		c.ServerName = host
+		tc := tls.Client(conn, c)
+		tc.SetDeadline(time.Now().Add(kafkaTimeout))
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+	}
+	b := &kafkaBroker{conn: conn, r: bufio.NewReader(conn)}
+	if cfg.SASL.Mechanism != "" {
+		if err := b.authenticate(cfg.SASL); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: sasl: %w", addr, err)
+		}
+	}
+	return b, nil
+}
+
+// request sends a request and returns the response body after the correlation ID
+func (b *kafkaBroker) request(apiKey, version int16, body []byte) ([]byte, error) {
+	b.correlation++
+	var e kafkaEncoder
+	e.int32(0) // size, filled in below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(b.correlation)
+	e.string("crtmon")
+	e.b = append(e.b, body...)
+	binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+
+	b.conn.SetDeadline(time.Now().Add(kafkaTimeout))
+	if _, err := b.conn.Write(e.b); err != nil {
+		return nil, err
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(b.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > 64<<20 {
+		return nil, fmt.Errorf("kafka: invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(b.r, resp); err != nil {
+		return nil, err
+	}
+	if id := int32(binary.BigEndian.Uint32(resp)); id != b.correlation {
+		return nil, fmt.Errorf("kafka: response %d to request %d", id, b.correlation)
+	}
+	return resp[4:], nil
+}
+
+// authenticate runs a SASL handshake and exchange
+func (b *kafkaBroker) authenticate(cfg KafkaSASLConfig) error {
+	mechanism := strings.ToUpper(cfg.Mechanism)
+	newHash, ok := kafkaSASLMechanisms[mechanism]
+	if !ok {
+		return fmt.Errorf("unsupported mechanism %q", cfg.Mechanism)
+	}
+
+	var e kafkaEncoder
+	e.string(mechanism)
+	resp, err := b.request(kafkaSASLHandshake, 1, e.b)
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{b: resp}
+	if code := kafkaError(d.int16()); code != 0 {
+		var enabled []string
+		for i, n := 0, d.array(); i < n; i++ {
+			enabled = append(enabled, d.string())
+		}
+		return fmt.Errorf("%w, broker enables %s", code, strings.Join(enabled, ", "))
+	}
+
+	if newHash == nil {
+		_, err := b.saslExchange([]byte("\x00" + cfg.Username + "\x00" + cfg.Password))
+		return err
+	}
+	return b.scram(newHash, cfg.Username, cfg.Password)
+}
+
+// saslExchange sends one SASL message and returns the broker's reply
+func (b *kafkaBroker) saslExchange(msg []byte) ([]byte, error) {
+	var e kafkaEncoder
+	e.bytes(msg)
+	resp, err := b.request(kafkaSASLAuthenticate, 0, e.b)
+	if err != nil {
+		return nil, err
+	}
+	d := kafkaDecoder{b: resp}
+	code := kafkaError(d.int16())
+	message := d.string()
+	reply := d.bytes()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if code != 0 {
+		if message != "" {
+			return nil, fmt.Errorf("%w: %s", code, message)
+		}
+		return nil, code
+	}
+	return reply, nil
+}
+
+// scramNonce returns a random client nonce
+var scramNonce = func() string {
+	nonce := make([]byte, 24)
+	rand.Read(nonce)
+	return base64.RawStdEncoding.EncodeToString(nonce)
+}
+
+// scram authenticates with SCRAM (RFC 5802) and verifies the broker's signature
+func (b *kafkaBroker) scram(newHash func() hash.Hash, username, password string) error {
+	clientNonce := scramNonce()
+	user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(username)
+	clientFirst := "n=" + user + ",r=" + clientNonce
+
+	reply, err := b.saslExchange([]byte("n,," + clientFirst))
+	if err != nil {
+		return err
+	}
+	serverFirst := string(reply)
+	attrs := map[string]string{}
+	for _, part := range strings.Split(serverFirst, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			attrs[k] = v
+		}
+	}
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil {
+		return fmt.Errorf("invalid scram salt: %w", err)
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 {
+		return fmt.Errorf("invalid scram iteration count %q", attrs["i"])
+	}
+	if !strings.HasPrefix(attrs["r"], clientNonce) {
+		return errors.New("scram nonce doesn't extend the client's")
+	}
+
+	salted, err := pbkdf2.Key(newHash, password, salt, iterations, newHash().Size())
+	if err != nil {
+		return err
+	}
+	mac := func(key []byte, msg string) []byte {
+		h := hmac.New(newHash, key)
+		h.Write([]byte(msg))
+		return h.Sum(nil)
+	}
+	clientKey := mac(salted, "Client Key")
+	h := newHash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	clientFinal := "c=biws,r=" + attrs["r"]
+	authMessage := clientFirst + "," + serverFirst + "," + clientFinal
+	proof := mac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	reply, err = b.saslExchange([]byte(clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof)))
+	if err != nil {
+		return err
+	}
+
+	serverSignature := mac(mac(salted, "Server Key"), authMessage)
+	verifier, ok := strings.CutPrefix(string(reply), "v=")
+	if !ok || verifier != base64.StdEncoding.EncodeToString(serverSignature) {
+		return errors.New("broker's scram signature doesn't match")
+	}
+	return nil
+}
+
+// kafkaProducer produces to the leaders of each topic's partitions
+type kafkaProducer struct {
+	cfg        KafkaConfig
+	tlsConfig  *tls.Config
+	acks       int16
+	addrs      map[int32]string       // Broker node ID to host:port
+	partitions map[string][]int32     // Topic to the leader of each partition
+	conns      map[int32]*kafkaBroker // Open connections by node ID
+}
+
+// dialKafka connects to a bootstrap broker and looks up the configured topics
+func dialKafka(cfg KafkaConfig) (*kafkaProducer, error) {
+	p := &kafkaProducer{
+		cfg:   cfg,
+		acks:  1,
+		conns: map[int32]*kafkaBroker{},
+	}
+	if strings.EqualFold(cfg.Acks, "all") || cfg.Acks == "-1" {
+		p.acks = -1
+	}
+	if cfg.TLS {
+		p.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pool, err := loadCAFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("kafka ca_file: %w", err)
+			}
+			p.tlsConfig.RootCAs = pool
+		}
+	}
+
+	lastErr := errors.New("kafka: no brokers")
+	for _, addr := range cfg.Brokers {
+		b, err := dialKafkaBroker(cfg, addr, p.tlsConfig)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		err = p.metadata(b)
+		b.conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", addr, err)
+			continue
+		}
+		return p, nil
+	}
+	return nil, lastErr
+}
+
+// metadata fetches the brokers and the partition leaders of the topics
+func (p *kafkaProducer) metadata(b *kafkaBroker) error {
+	topics := []string{p.cfg.Topic}
+	if p.cfg.FindingsTopic != p.cfg.Topic {
+		topics = append(topics, p.cfg.FindingsTopic)
+	}
+	var e kafkaEncoder
+	e.int32(int32(len(topics)))
+	for _, t := range topics {
+		e.string(t)
+	}
+	e.bool(true) // allow auto topic creation
+	resp, err := b.request(kafkaMetadata, kafkaMetadataVersion, e.b)
+	if err != nil {
+		return err
+	}
+
+	d := kafkaDecoder{b: resp}
+	d.int32() // throttle time
+	p.addrs = map[int32]string{}
+	for i, n := 0, d.array(); i < n; i++ {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		p.addrs[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.string() // cluster id
+	d.int32()  // controller id
+
+	p.partitions = map[string][]int32{}
+	for i, n := 0, d.array(); i < n; i++ {
+		code := kafkaError(d.int16())
+		name := d.string()
+		d.int8() // is internal
+		leaders := make([]int32, d.array())
+		for range leaders {
+			d.int16() // partition error
+			index := d.int32()
+			leader := d.int32()
+			for k, m := 0, d.array(); k < m; k++ {
+				d.int32() // replicas
+			}
+			for k, m := 0, d.array(); k < m; k++ {
+				d.int32() // in-sync replicas
+			}
+			if index >= 0 && int(index) < len(leaders) {
+				leaders[index] = leader
+			}
+		}
+		if d.err != nil {
+			return d.err
+		}
+		if code != 0 {
+			return fmt.Errorf("topic %s: %w", name, code)
+		}
+		if len(leaders) == 0 {
+			return fmt.Errorf("topic %s has no partitions", name)
+		}
+		p.partitions[name] = leaders
+	}
+	for _, t := range topics {
+		if _, ok := p.partitions[t]; !ok {
+			return fmt.Errorf("topic %s: %w", t, kafkaError(3))
+		}
+	}
+	return d.err
+}
+
+// broker returns an open connection to a node, dialing it when needed
+func (p *kafkaProducer) broker(node int32) (*kafkaBroker, error) {
+	if b, ok := p.conns[node]; ok {
+		return b, nil
+	}
+	addr, ok := p.addrs[node]
+	if !ok {
+		return nil, fmt.Errorf("kafka: no address for broker %d", node)
+	}
+	b, err := dialKafkaBroker(p.cfg, addr, p.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[node] = b
+	return b, nil
+}
+
+// kafkaPartition picks a partition for a key, so records of one domain stay in order
+func kafkaPartition(key []byte, partitions int) int32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(partitions))
+}
+
+// produce sends records to their partition leaders, one request per leader
+func (p *kafkaProducer) produce(items []kafkaItem) error {
+	// Leader -> topic -> partition -> records, keeping queue order within a partition
+	byLeader := map[int32]map[string]map[int32][]kafkaItem{}
+	for _, item := range items {
+		leaders, ok := p.partitions[item.topic]
+		if !ok {
+			return fmt.Errorf("topic %s: %w", item.topic, kafkaError(3))
+		}
+		partition := kafkaPartition(item.key, len(leaders))
+		leader := leaders[partition]
+		if leader < 0 {
+			return fmt.Errorf("topic %s partition %d: %w", item.topic, partition, kafkaError(5))
+		}
+		if byLeader[leader] == nil {
+			byLeader[leader] = map[string]map[int32][]kafkaItem{}
+		}
+		if byLeader[leader][item.topic] == nil {
+			byLeader[leader][item.topic] = map[int32][]kafkaItem{}
+		}
+		byLeader[leader][item.topic][partition] = append(byLeader[leader][item.topic][partition], item)
+	}
+
+	for leader, topics := range byLeader {
+		var e kafkaEncoder
+		e.int16(-1) // transactional id
+		e.int16(p.acks)
+		e.int32(int32(kafkaTimeout / time.Millisecond))
+		e.int32(int32(len(topics)))
+		for topic, partitions := range topics {
+			e.string(topic)
+			e.int32(int32(len(partitions)))
+			for partition, records := range partitions {
+				e.int32(partition)
+				e.bytes(kafkaRecordBatch(records))
+			}
+		}
+
+		b, err := p.broker(leader)
+		if err != nil {
+			return err
+		}
+		resp, err := b.request(kafkaProduce, kafkaProduceVersion, e.b)
+		if err != nil {
+			return err
+		}
+		d := kafkaDecoder{b: resp}
+		for i, n := 0, d.array(); i < n; i++ {
+			topic := d.string()
+			for j, m := 0, d.array(); j < m; j++ {
+				partition := d.int32()
+				code := kafkaError(d.int16())
+				d.int64() // base offset
+				d.int64() // log append time
+				if d.err == nil && code != 0 {
+					return fmt.Errorf("topic %s partition %d: %w", topic, partition, code)
+				}
+			}
+		}
+		if d.err != nil {
+			return d.err
+		}
+	}
+	return nil
+}
+
+// Close closes every broker connection
+func (p *kafkaProducer) Close() error {
+	for node, b := range p.conns {
+		b.conn.Close()
+		delete(p.conns, node)
+	}
+	return nil
+}
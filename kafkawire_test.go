@@ -0,0 +1,504 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kafkaHandler answers one request with the response body after the correlation ID
+type kafkaHandler func(apiKey, version int16, body []byte) []byte
+
+// fakeKafkaBroker serves the Kafka framing on a loopback port, for any number of
+// connections, and returns its host and port
+func fakeKafkaBroker(t *testing.T, handle kafkaHandler) (string, int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveKafka(conn, handle)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), int32(addr.Port)
+}
+
+func serveKafka(conn net.Conn, handle kafkaHandler) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := kafkaDecoder{b: req}
+		apiKey := d.int16()
+		version := d.int16()
+		correlation := d.int32()
+		d.string() // client id
+		if d.err != nil {
+			return
+		}
+
+		var e kafkaEncoder
+		e.int32(0)
+		e.int32(correlation)
+		e.b = append(e.b, handle(apiKey, version, d.b)...)
+		binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+		if _, err := conn.Write(e.b); err != nil {
+			return
+		}
+	}
+}
+
+// metadataResponse is a v4 metadata response naming one broker as the leader of
+// every partition of the topics
+func metadataResponse(host string, port int32, partitions int, topics ...string) []byte {
+	var e kafkaEncoder
+	e.int32(0) // throttle time
+	e.int32(1)
+	e.int32(1) // node id
+	e.string(host)
+	e.int32(port)
+	e.int16(-1) // rack
+	e.string("cluster")
+	e.int32(1) // controller
+	e.int32(int32(len(topics)))
+	for _, topic := range topics {
+		e.int16(0)
+		e.string(topic)
+		e.bool(false)
+		e.int32(int32(partitions))
+		for i := 0; i < partitions; i++ {
+			e.int16(0)
+			e.int32(int32(i))
+			e.int32(1) // leader
+			e.int32(1)
+			e.int32(1) // replicas
+			e.int32(1)
+			e.int32(1) // in-sync replicas
+		}
+	}
+	return e.b
+}
+
+// decodedRecord is a record read back from a batch
+type decodedRecord struct {
+	key, value string
+	time       int64
+}
+
+// decodeRecordBatch checks a v2 record batch field by field and returns its records
+func decodeRecordBatch(t *testing.T, b []byte) []decodedRecord {
+	t.Helper()
+	d := kafkaDecoder{b: b}
+	if offset := d.int64(); offset != 0 {
+		t.Errorf("base offset = %d", offset)
+	}
+	if length := d.int32(); int(length) != len(b)-12 {
+		t.Errorf("batch length = %d, want %d", length, len(b)-12)
+	}
+	if epoch := d.int32(); epoch != -1 {
+		t.Errorf("partition leader epoch = %d", epoch)
+	}
+	if magic := d.int8(); magic != 2 {
+		t.Errorf("magic = %d", magic)
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.b, castagnoli) {
+		t.Errorf("crc = %#x, want %#x", crc, crc32.Checksum(d.b, castagnoli))
+	}
+	if attributes := d.int16(); attributes != 0 {
+		t.Errorf("attributes = %d", attributes)
+	}
+	lastOffsetDelta := d.int32()
+	baseTime := d.int64()
+	maxTime := d.int64()
+	if producer, epoch, sequence := d.int64(), d.int16(), d.int32(); producer != -1 || epoch != -1 || sequence != -1 {
+		t.Errorf("producer = %d/%d/%d, want no idempotence", producer, epoch, sequence)
+	}
+	count := d.int32()
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if lastOffsetDelta != count-1 {
+		t.Errorf("last offset delta = %d with %d records", lastOffsetDelta, count)
+	}
+
+	varint := func() int64 {
+		v, n := binary.Varint(d.b)
+		if n <= 0 {
+			t.Fatal("invalid varint")
+		}
+		d.b = d.b[n:]
+		return v
+	}
+	var records []decodedRecord
+	latest := baseTime
+	for i := int32(0); i < count; i++ {
+		length := varint()
+		rest := len(d.b) - int(length)
+		d.int8() // attributes
+		ts := baseTime + varint()
+		if delta := varint(); delta != int64(i) {
+			t.Errorf("record %d has offset delta %d", i, delta)
+		}
+		key := string(d.next(int(varint())))
+		value := string(d.next(int(varint())))
+		if headers := varint(); headers != 0 {
+			t.Errorf("record %d has %d headers", i, headers)
+		}
+		if len(d.b) != rest {
+			t.Errorf("record %d length = %d, off by %d", i, length, len(d.b)-rest)
+		}
+		latest = max(latest, ts)
+		records = append(records, decodedRecord{key: key, value: value, time: ts})
+	}
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if len(d.b) != 0 {
+		t.Errorf("%d bytes after the records", len(d.b))
+	}
+	if maxTime != latest {
+		t.Errorf("max timestamp = %d, want %d", maxTime, latest)
+	}
+	return records
+}
+
+func TestCastagnoli(t *testing.T) {
+	// The CRC-32C check value; IEEE CRC-32 gives 0xCBF43926
+	if got := crc32.Checksum([]byte("123456789"), castagnoli); got != 0xE3069283 {
+		t.Fatalf("crc32c = %#x", got)
+	}
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	base := time.UnixMilli(1700000000000)
+	items := []kafkaItem{
+		{key: []byte("a.example.com"), value: []byte(`{"type":"match"}`), time: base.Add(2 * time.Second)},
+		{key: []byte("b.example.com"), value: []byte(strings.Repeat("x", 300)), time: base},
+		{key: nil, value: []byte(`{}`), time: base.Add(time.Second)},
+	}
+	records := decodeRecordBatch(t, kafkaRecordBatch(items))
+	if len(records) != len(items) {
+		t.Fatalf("%d records, want %d", len(records), len(items))
+	}
+	for i, r := range records {
+		if r.key != string(items[i].key) || r.value != string(items[i].value) || r.time != items[i].time.UnixMilli() {
+			t.Errorf("record %d = %q %d bytes at %d", i, r.key, len(r.value), r.time)
+		}
+	}
+}
+
+func TestKafkaPartition(t *testing.T) {
+	seen := map[int32]bool{}
+	for i := 0; i < 100; i++ {
+		key := []byte("host" + strconv.Itoa(i) + ".example.com")
+		p := kafkaPartition(key, 6)
+		if p < 0 || p >= 6 {
+			t.Fatalf("partition %d out of range", p)
+		}
+		if kafkaPartition(key, 6) != p {
+			t.Fatal("partition isn't stable for a key")
+		}
+		seen[p] = true
+	}
+	if len(seen) < 4 {
+		t.Errorf("100 keys landed in only %d of 6 partitions", len(seen))
+	}
+}
+
+func TestKafkaProduce(t *testing.T) {
+	var mu sync.Mutex
+	produced := map[string][]decodedRecord{}
+	var acks int16
+	var host string
+	var port int32
+	host, port = fakeKafkaBroker(t, func(apiKey, version int16, body []byte) []byte {
+		switch apiKey {
+		case kafkaMetadata:
+			return metadataResponse(host, port, 3, "crtmon", "crtmon-findings")
+		case kafkaProduce:
+			d := kafkaDecoder{b: body}
+			d.string() // transactional id
+			mu.Lock()
+			acks = d.int16()
+			mu.Unlock()
+			d.int32() // timeout
+			var resp kafkaEncoder
+			topics := d.array()
+			resp.int32(int32(topics))
+			for i := 0; i < topics; i++ {
+				topic := d.string()
+				resp.string(topic)
+				partitions := d.array()
+				resp.int32(int32(partitions))
+				for j := 0; j < partitions; j++ {
+					partition := d.int32()
+					records := decodeRecordBatch(t, d.bytes())
+					mu.Lock()
+					produced[topic] = append(produced[topic], records...)
+					mu.Unlock()
+					resp.int32(partition)
+					resp.int16(0)
+					resp.int64(0)  // base offset
+					resp.int64(-1) // log append time
+				}
+			}
+			resp.int32(0) // throttle time
+			return resp.b
+		}
+		t.Errorf("unexpected request %d v%d", apiKey, version)
+		return nil
+	})
+
+	cfg := KafkaConfig{Brokers: []string{net.JoinHostPort(host, strconv.Itoa(int(port)))}, FindingsTopic: "crtmon-findings", Acks: "all"}
+	kafkaDefaults(&cfg)
+	producer, err := dialKafka(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	if n := len(producer.partitions["crtmon"]); n != 3 {
+		t.Fatalf("%d partitions of crtmon, want 3", n)
+	}
+
+	now := time.Now()
+	err = producer.produce([]kafkaItem{
+		{topic: "crtmon", key: []byte("a.example.com"), value: []byte("1"), time: now},
+		{topic: "crtmon", key: []byte("b.example.com"), value: []byte("2"), time: now},
+		{topic: "crtmon", key: []byte("a.example.com"), value: []byte("3"), time: now},
+		{topic: "crtmon-findings", key: []byte("a.example.com"), value: []byte("4"), time: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if acks != -1 {
+		t.Errorf("acks = %d, want -1", acks)
+	}
+	if len(produced["crtmon"]) != 3 || len(produced["crtmon-findings"]) != 1 {
+		t.Fatalf("produced %v", produced)
+	}
+	// Records of one key share a partition, so they keep their order
+	var order []string
+	for _, r := range produced["crtmon"] {
+		if r.key == "a.example.com" {
+			order = append(order, r.value)
+		}
+	}
+	if strings.Join(order, ",") != "1,3" {
+		t.Errorf("a.example.com records in order %v", order)
+	}
+}
+
+func TestKafkaProduceRejected(t *testing.T) {
+	var host string
+	var port int32
+	host, port = fakeKafkaBroker(t, func(apiKey, version int16, body []byte) []byte {
+		if apiKey == kafkaMetadata {
+			return metadataResponse(host, port, 1, "crtmon")
+		}
+		var resp kafkaEncoder
+		resp.int32(1)
+		resp.string("crtmon")
+		resp.int32(1)
+		resp.int32(0)
+		resp.int16(10) // MESSAGE_TOO_LARGE
+		resp.int64(-1)
+		resp.int64(-1)
+		resp.int32(0)
+		return resp.b
+	})
+
+	cfg := KafkaConfig{Brokers: []string{net.JoinHostPort(host, strconv.Itoa(int(port)))}}
+	kafkaDefaults(&cfg)
+	producer, err := dialKafka(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+
+	err = producer.produce([]kafkaItem{{topic: "crtmon", key: []byte("a"), value: []byte("x"), time: time.Now()}})
+	var kerr kafkaError
+	if !errors.As(err, &kerr) || !kerr.rejected() {
+		t.Fatalf("err = %v, want a rejection", err)
+	}
+}
+
+func TestKafkaUnknownTopic(t *testing.T) {
+	var host string
+	var port int32
+	host, port = fakeKafkaBroker(t, func(apiKey, version int16, body []byte) []byte {
+		return metadataResponse(host, port, 1, "other")
+	})
+	cfg := KafkaConfig{Brokers: []string{net.JoinHostPort(host, strconv.Itoa(int(port)))}}
+	kafkaDefaults(&cfg)
+	if _, err := dialKafka(cfg); err == nil || !strings.Contains(err.Error(), "UNKNOWN_TOPIC_OR_PARTITION") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// saslBroker answers the SASL handshake for mechanism and passes each authenticate
+// message to exchange, which returns the reply or an error code
+func saslBroker(t *testing.T, mechanism string, exchange func(msg []byte) ([]byte, int16)) string {
+	var host string
+	var port int32
+	host, port = fakeKafkaBroker(t, func(apiKey, version int16, body []byte) []byte {
+		d := kafkaDecoder{b: body}
+		var resp kafkaEncoder
+		switch apiKey {
+		case kafkaSASLHandshake:
+			if got := d.string(); got != mechanism {
+				resp.int16(33) // UNSUPPORTED_SASL_MECHANISM
+			} else {
+				resp.int16(0)
+			}
+			resp.int32(1)
+			resp.string(mechanism)
+		case kafkaSASLAuthenticate:
+			reply, code := exchange(d.bytes())
+			resp.int16(code)
+			if code != 0 {
+				resp.string("authentication failed")
+			} else {
+				resp.int16(-1)
+			}
+			resp.bytes(reply)
+		case kafkaMetadata:
+			return metadataResponse(host, port, 1, "crtmon")
+		}
+		return resp.b
+	})
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+func TestKafkaSASLPlain(t *testing.T) {
+	var got []string
+	addr := saslBroker(t, "PLAIN", func(msg []byte) ([]byte, int16) {
+		got = append(got, string(msg))
+		return nil, 0
+	})
+	cfg := KafkaConfig{Brokers: []string{addr}, SASL: KafkaSASLConfig{Mechanism: "plain", Username: "crtmon", Password: "secret"}}
+	kafkaDefaults(&cfg)
+	b, err := dialKafkaBroker(cfg, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.conn.Close()
+	if len(got) != 1 || got[0] != "\x00crtmon\x00secret" {
+		t.Errorf("sasl messages = %q", got)
+	}
+}
+
+func TestKafkaSASLUnsupported(t *testing.T) {
+	addr := saslBroker(t, "SCRAM-SHA-512", func(msg []byte) ([]byte, int16) { return nil, 0 })
+	cfg := KafkaConfig{SASL: KafkaSASLConfig{Mechanism: "PLAIN", Username: "crtmon"}}
+	_, err := dialKafkaBroker(cfg, addr, nil)
+	if err == nil || !strings.Contains(err.Error(), "UNSUPPORTED_SASL_MECHANISM") || !strings.Contains(err.Error(), "SCRAM-SHA-512") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// The SCRAM-SHA-256 exchange of RFC 7677, section 3
+const (
+	rfc7677ClientNonce = "rOprNGfwEbeRWgbNEkqO"
+	rfc7677ClientFirst = "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"
+	rfc7677ServerFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	rfc7677ClientFinal = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	rfc7677ServerFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+// rfc7677Broker plays the server side of the RFC 7677 exchange, answering the final
+// message with serverFinal
+func rfc7677Broker(t *testing.T, serverFinal string) (string, *[]string) {
+	var got []string
+	addr := saslBroker(t, "SCRAM-SHA-256", func(msg []byte) ([]byte, int16) {
+		got = append(got, string(msg))
+		switch string(msg) {
+		case rfc7677ClientFirst:
+			return []byte(rfc7677ServerFirst), 0
+		case rfc7677ClientFinal:
+			return []byte(serverFinal), 0
+		}
+		return nil, 58 // SASL_AUTHENTICATION_FAILED
+	})
+	return addr, &got
+}
+
+func withSCRAMNonce(t *testing.T, nonce string) {
+	saved := scramNonce
+	scramNonce = func() string { return nonce }
+	t.Cleanup(func() { scramNonce = saved })
+}
+
+func TestKafkaSCRAM(t *testing.T) {
+	withSCRAMNonce(t, rfc7677ClientNonce)
+	addr, got := rfc7677Broker(t, rfc7677ServerFinal)
+
+	cfg := KafkaConfig{SASL: KafkaSASLConfig{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pencil"}}
+	b, err := dialKafkaBroker(cfg, addr, nil)
+	if err != nil {
+		t.Fatalf("err = %v, messages %q", err, *got)
+	}
+	b.conn.Close()
+	if len(*got) != 2 {
+		t.Errorf("sasl messages = %q", *got)
+	}
+}
+
+func TestKafkaSCRAMWrongPassword(t *testing.T) {
+	withSCRAMNonce(t, rfc7677ClientNonce)
+	addr, _ := rfc7677Broker(t, rfc7677ServerFinal)
+
+	cfg := KafkaConfig{SASL: KafkaSASLConfig{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pencil2"}}
+	_, err := dialKafkaBroker(cfg, addr, nil)
+	if err == nil || !strings.Contains(err.Error(), "SASL_AUTHENTICATION_FAILED") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestKafkaSCRAMServerSignature(t *testing.T) {
+	withSCRAMNonce(t, rfc7677ClientNonce)
+	// A broker that doesn't know the password can't sign the exchange
+	addr, _ := rfc7677Broker(t, "v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+
+	cfg := KafkaConfig{SASL: KafkaSASLConfig{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pencil"}}
+	_, err := dialKafkaBroker(cfg, addr, nil)
+	if err == nil || !strings.Contains(err.Error(), "signature doesn't match") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestKafkaSCRAMNonce(t *testing.T) {
+	withSCRAMNonce(t, "othernonce")
+	addr := saslBroker(t, "SCRAM-SHA-256", func(msg []byte) ([]byte, int16) {
+		// Doesn't extend the client's nonce
+		return []byte(rfc7677ServerFirst), 0
+	})
+	cfg := KafkaConfig{SASL: KafkaSASLConfig{Mechanism: "SCRAM-SHA-256", Username: "user", Password: "pencil"}}
+	_, err := dialKafkaBroker(cfg, addr, nil)
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("err = %v", err)
+	}
+}
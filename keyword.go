@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mode markers for keyword targets. These match brand keywords anywhere in a domain
+// instead of an apex and its subdomains, e.g. "contains:acme" or "regex:^vpn\..*\.acme\.com$".
+const (
+	targetContains = "contains:"
+	targetRegex    = "regex:"
+)
+
+var targetRegexps = make(map[string]*regexp.Regexp) // Pattern -> compiled
+var targetRegexpsMutex sync.Mutex
+
+// keywordTarget splits a keyword target into its mode marker and value
+func keywordTarget(target string) (mode, value string, ok bool) {
+	t := strings.TrimSpace(target)
+	for _, m := range []string{targetContains, targetRegex} {
+		if len(t) >= len(m) && strings.EqualFold(t[:len(m)], m) {
+			return m, t[len(m):], true
+		}
+	}
+	return "", "", false
+}
+
+// isKeywordTarget reports whether a target matches by keyword rather than as a domain
+func isKeywordTarget(target string) bool {
+	_, _, ok := keywordTarget(target)
+	return ok
+}
+
+// normalizeKeywordTarget lowercases contains: keywords and checks that regex: patterns compile.
+// Patterns keep their case, since lowercasing would change escapes like \D.
+func normalizeKeywordTarget(target string) (string, error) {
+	mode, value, _ := keywordTarget(target)
+	switch mode {
+	case targetContains:
+		keyword := strings.ToLower(strings.TrimSpace(value))
+		if keyword == "" || strings.ContainsAny(keyword, " \t/") {
+			return "", fmt.Errorf("invalid keyword target %q", target)
+		}
+		return targetContains + keyword, nil
+	default:
+		if strings.TrimSpace(value) == "" {
+			return "", fmt.Errorf("invalid regex target %q: empty pattern", target)
+		}
+		if _, err := compileTargetRegex(value); err != nil {
+			return "", fmt.Errorf("invalid regex target %q: %w", target, err)
+		}
+		return targetRegex + value, nil
+	}
+}
+
+// matchesKeyword reports whether a lowercase domain matches a keyword target
+func matchesKeyword(domain, target string) bool {
+	mode, value, _ := keywordTarget(target)
+	if mode == targetContains {
+		return strings.Contains(domain, strings.ToLower(value))
+	}
+	re, err := compileTargetRegex(value)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(domain)
+}
+
+// compileTargetRegex compiles a regex target once and caches it
+func compileTargetRegex(pattern string) (*regexp.Regexp, error) {
+	targetRegexpsMutex.Lock()
+	defer targetRegexpsMutex.Unlock()
+	if re, ok := targetRegexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	targetRegexps[pattern] = re
+	return re, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Time-to-notify is measured from the timestamp the CT log put on a certificate entry
+// to the moment a notification for the domain was delivered by any provider.
+
+// maxNotifyLatencySamples is how many recent deliveries the percentiles are computed over
+const maxNotifyLatencySamples = 1000
+
+var loggedAtMutex sync.Mutex
+var loggedAt = make(map[string]time.Time) // Domain -> CT log timestamp of the entry being notified
+
+// markLoggedAt remembers when the entry that triggered a notification was logged.
+// The earliest timestamp wins if a domain is queued again before delivery.
+func markLoggedAt(domain string, at time.Time) {
+	if at.IsZero() || at.Unix() <= 0 {
+		return
+	}
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	loggedAtMutex.Lock()
+	defer loggedAtMutex.Unlock()
+	if _, exists := loggedAt[d]; exists {
+		return
+	}
+	// Domains spilled to disk and never delivered would otherwise accumulate here
+	if len(loggedAt) >= 2*maxPendingDomains {
+		loggedAt = make(map[string]time.Time)
+	}
+	loggedAt[d] = at
+}
+
+// forgetLoggedAt drops the log timestamps of the given domains
+func forgetLoggedAt(domains map[string]bool) {
+	loggedAtMutex.Lock()
+	defer loggedAtMutex.Unlock()
+	for d := range domains {
+		delete(loggedAt, d)
+	}
+}
+
+// recordNotifyLatency records time-to-notify for a delivered batch
+func recordNotifyLatency(domains []string) {
+	now := time.Now()
+	dt := GetDomainTracker()
+	st := GetStatsTracker()
+
+	for _, domain := range domains {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		loggedAtMutex.Lock()
+		at, exists := loggedAt[d]
+		delete(loggedAt, d)
+		loggedAtMutex.Unlock()
+		if !exists {
+			continue
+		}
+
+		latency := now.Sub(at)
+		if latency < 0 {
+			latency = 0 // Log clocks can run slightly ahead of ours
+		}
+		st.RecordNotifyLatency(latency)
+		dt.RecordDomainNotifyLatency(d, latency)
+	}
+}
+
+// RecordNotifyLatency adds a time-to-notify sample, keeping the most recent ones
+func (st *StatsTracker) RecordNotifyLatency(latency time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.notifyLatencies = append(st.notifyLatencies, latency.Milliseconds())
+	if len(st.notifyLatencies) > maxNotifyLatencySamples {
+		st.notifyLatencies = st.notifyLatencies[len(st.notifyLatencies)-maxNotifyLatencySamples:]
+	}
+}
+
+// GetNotifyLatency returns the median and 95th percentile time-to-notify over recent deliveries
+func (st *StatsTracker) GetNotifyLatency() (p50, p95 time.Duration, samples int) {
+	st.mu.RLock()
+	sorted := append([]int64(nil), st.notifyLatencies...)
+	st.mu.RUnlock()
+
+	if len(sorted) == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return percentile(sorted, 50), percentile(sorted, 95), len(sorted)
+}
+
+// percentile returns the nearest-rank percentile of sorted millisecond samples
+func percentile(sorted []int64, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return time.Duration(sorted[rank-1]) * time.Millisecond
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Live event types pushed to the admin panel
+const (
+	liveDiscovery    = "discovery"
+	liveScanStarted  = "scan_started"
+	liveScanFinished = "scan_finished"
+	liveCTHealth     = "ct_health"
+)
+
+const (
+	// liveBacklog is how many recent events a new subscriber receives first
+	liveBacklog = 50
+	// liveClientBuffer is how many events a slow client can fall behind before
+	// events are dropped for it
+	liveClientBuffer = 64
+)
+
+// LiveEvent is one update on the /api/events stream
+type LiveEvent struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// liveHub fans events out to connected admin panel clients
+type liveHub struct {
+	mu      sync.Mutex
+	clients map[chan LiveEvent]struct{}
+	recent  []LiveEvent
+}
+
+var live = &liveHub{clients: make(map[chan LiveEvent]struct{})}
+
+// Subscribe registers a client and returns its channel plus the recent backlog
+func (h *liveHub) Subscribe() (chan LiveEvent, []LiveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ch := make(chan LiveEvent, liveClientBuffer)
+	h.clients[ch] = struct{}{}
+	return ch, append([]LiveEvent(nil), h.recent...)
+}
+
+// Unsubscribe removes a client
+func (h *liveHub) Unsubscribe(ch chan LiveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, ch)
+}
+
+// publishLive sends an event to every client without blocking the caller
+func publishLive(kind string, data interface{}) {
+	event := LiveEvent{Type: kind, Time: time.Now(), Data: data}
+
+	live.mu.Lock()
+	defer live.mu.Unlock()
+	live.recent = append(live.recent, event)
+	if len(live.recent) > liveBacklog {
+		live.recent = live.recent[len(live.recent)-liveBacklog:]
+	}
+	for ch := range live.clients {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// publishDiscoveries pushes matched domains that weren't excluded or already seen
+func publishDiscoveries(entry CertEntry, decisions []EntryDecision) {
+	for _, d := range decisions {
+		if !d.Matched || d.Excluded || d.Duplicate {
+			continue
+		}
+		publishLive(liveDiscovery, map[string]interface{}{
+			"domain":   d.Domain,
+			"target":   d.Target,
+			"targets":  d.Targets,
+			"issuer":   entry.Issuer,
+			"resolves": d.Resolves,
+			"notified": d.Notify,
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// LoggingConfig selects how log lines are written
+type LoggingConfig struct {
+	Format string `yaml:"format"` // "text" (default) or "json", one object per line for Loki/ELK
+	Level  string `yaml:"level"`  // debug (default), info, warn or error
+}
+
+// jsonLogging is set while logs are written as JSON
+var jsonLogging bool
+
+// configureLogger switches the log format and level; empty values keep the current ones
+func configureLogger(format, level string) error {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "":
+	case "text":
+		logger.SetFormatter(log.TextFormatter)
+		logger.SetTimeFormat("15:04:05")
+		jsonLogging = false
+	case "json":
+		logger.SetFormatter(log.JSONFormatter)
+		logger.SetTimeFormat(time.RFC3339)
+		jsonLogging = true
+	default:
+		return fmt.Errorf("unknown log format %q, use text or json", format)
+	}
+
+	if level = strings.ToLower(strings.TrimSpace(level)); level != "" {
+		if level == "warning" {
+			level = "warn"
+		}
+		l, err := log.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("unknown log level %q, use debug, info, warn or error", level)
+		}
+		logger.SetLevel(l)
+	}
+	return nil
+}
+
+// applyLoggingConfig applies the logging section of the config. The -log-format and
+// -log-level flags take precedence and were applied at startup.
+func applyLoggingConfig(cfg *LoggingConfig) {
+	format, level := cfg.Format, cfg.Level
+	if *logFormat != "" {
+		format = ""
+	}
+	if *logLevel != "" {
+		level = ""
+	}
+	if err := configureLogger(format, level); err != nil {
+		logger.Warn("ignoring logging config", "error", err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// LowResourceConfig tunes crtmon for Raspberry Pi-class hosts. Only caches, buffers and
+// concurrency shrink; state stays in the usual JSON files.
+type LowResourceConfig struct {
+	Enabled           bool `yaml:"enabled"`
+	MemoryLimitMB     int  `yaml:"memory_limit_mb"`     // Soft heap ceiling enforced by the Go runtime
+	ResolveCacheSize  int  `yaml:"resolve_cache_size"`  // Max cached DNS results
+	MaxPendingDomains int  `yaml:"max_pending_domains"` // Notification buffer size before spilling to disk
+}
+
+// memoryCheckInterval is how often the heap is compared against the ceiling
+const memoryCheckInterval = 30 * time.Second
+
+var lowResourceConfig *LowResourceConfig
+var lowResourceMutex sync.Mutex
+
+// SetLowResourceConfig sets the low-resource configuration and applies the memory ceiling
+func SetLowResourceConfig(cfg *LowResourceConfig) {
+	lowResourceMutex.Lock()
+	defer lowResourceMutex.Unlock()
+	lowResourceConfig = cfg
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	if cfg.MemoryLimitMB <= 0 {
+		cfg.MemoryLimitMB = 256
+	}
+	if cfg.ResolveCacheSize <= 0 {
+		cfg.ResolveCacheSize = 2000
+	}
+	if cfg.MaxPendingDomains <= 0 {
+		cfg.MaxPendingDomains = 500
+	}
+
+	debug.SetMemoryLimit(int64(cfg.MemoryLimitMB) << 20)
+	logger.Info("low-resource mode enabled", "memory_limit_mb", cfg.MemoryLimitMB, "resolve_cache_size", cfg.ResolveCacheSize, "max_pending_domains", cfg.MaxPendingDomains)
+}
+
+// GetLowResourceConfig returns the low-resource configuration
+func GetLowResourceConfig() *LowResourceConfig {
+	lowResourceMutex.Lock()
+	defer lowResourceMutex.Unlock()
+	return lowResourceConfig
+}
+
+// isLowResourceMode reports whether low-resource mode is enabled
+func isLowResourceMode() bool {
+	cfg := GetLowResourceConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// resolveCacheLimit returns the max number of cached DNS results, the smaller limit in low-resource mode
+func resolveCacheLimit() int {
+	limit := GetResolveConfig().CacheSize
+	if cfg := GetLowResourceConfig(); cfg != nil && cfg.Enabled && cfg.ResolveCacheSize < limit {
+		return cfg.ResolveCacheSize
+	}
+	return limit
+}
+
+// pendingDomainLimit returns how many domains the notification buffer holds in memory
+func pendingDomainLimit() int {
+	if cfg := GetLowResourceConfig(); cfg != nil && cfg.Enabled {
+		return cfg.MaxPendingDomains
+	}
+	return maxPendingDomains
+}
+
+// StartMemoryWatchdog sheds caches when the heap grows past the configured ceiling
+func StartMemoryWatchdog() {
+	cfg := GetLowResourceConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	limit := uint64(cfg.MemoryLimitMB) << 20
+
+	go func() {
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			if m.HeapAlloc < limit {
+				continue
+			}
+
+			logger.Warn("memory ceiling reached, shedding caches", "heap_mb", m.HeapAlloc>>20, "limit_mb", cfg.MemoryLimitMB)
+			ClearResolveCache()
+			debug.FreeOSMemory()
+		}
+	}()
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -19,12 +20,18 @@ const version = "1.1.0"
 var (
 	target      = flag.String("target", "", "target domain to monitor")
 	configPath  = flag.String("config", "", "path to configuration file")
-	notify      = flag.String("notify", "", "notification provider: discord, telegram, both")
+	notify      = flag.String("notify", "", "notification provider: discord, telegram, ntfy, both")
 	showVersion = flag.Bool("version", false, "show version")
 	update      = flag.Bool("update", false, "update to latest version")
+	daemon      = flag.Bool("daemon", false, "run in the background, logging to crtmon.log in the config directory")
+	pidFile     = flag.String("pidfile", "", "write the process ID to this file (default with -daemon: crtmon.pid in the config directory)")
+	dryRunMode  = flag.Bool("dry-run", false, "match, dedup and score entries but log would-be notifications instead of sending, and save nothing")
+	logFormat   = flag.String("log-format", "", "log format: text or json (overrides logging.format)")
+	logLevel    = flag.String("log-level", "", "log level: debug, info, warn or error (overrides logging.level)")
+	validate    = flag.Bool("validate", false, "validate the configuration file and exit, same as crtmon config validate")
 	showHelp    = flag.Bool("h", false, "show help")
 	showHelp2   = flag.Bool("help", false, "show help")
-	logger      = log.NewWithOptions(os.Stderr, log.Options{
+	logger      = log.NewWithOptions(redactingWriter{os.Stderr}, log.Options{
 		ReportTimestamp: true,
 		TimeFormat:      "15:04:05",
 		Level:           log.DebugLevel,
@@ -37,9 +44,23 @@ var (
 	globalConfig   *Config      // Store config globally for admin panel
 	notifyDiscord  bool
 	notifyTelegram bool
+	notifyNtfy     bool
 )
 
 func main() {
+	// crtmon <command> runs a subcommand; bare flags keep running the monitor
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+		if os.Args[1] != "monitor" {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+			displayHelp()
+			os.Exit(2)
+		}
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	flag.Parse()
 
 	if len(os.Args) > 1 {
@@ -49,6 +70,12 @@ func main() {
 			"-notify": true,
 			"-version": true,
 			"-update": true,
+			"-daemon": true,
+			"-pidfile": true,
+			"-dry-run": true,
+			"-log-format": true,
+			"-log-level": true,
+			"-validate": true,
 			"-h": true, "-help": true,
 		}
 
@@ -72,6 +99,11 @@ func main() {
 		}
 	}
 
+	if err := configureLogger(*logFormat, *logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	if *showVersion {
 		displayVersion()
 		return
@@ -87,46 +119,72 @@ func main() {
 		return
 	}
 
-	printBanner()
-
 	if *configPath != "" {
 		setConfigPath(*configPath)
 	}
 
-	cfg, err := loadConfig()
-	if err != nil {
-		logger.Fatal("failed to load config", "error", err)
+	if *validate {
+		os.Exit(printDoctorReport(validateConfigFile(false)))
 	}
 
-	if cfg != nil {
-		if cfg.Webhook == `""` {
-			cfg.Webhook = ""
+	// -daemon starts a detached copy of crtmon and exits; the copy continues below
+	if *daemon && !isDaemonChild() {
+		if *target == "-" {
+			logger.Fatal("-target - reads stdin, which is not available with -daemon; use a targets file")
 		}
-
-		webhookURL = strings.TrimSpace(cfg.Webhook)
-		if webhookURL == "" {
-			logger.Warn("no discord webhook configured in configuration file; discord notifications disabled")
+		configDir, err := getConfigDir()
+		if err == nil {
+			err = os.MkdirAll(configDir, 0755)
+		}
+		if err != nil {
+			logger.Fatal("failed to prepare config directory", "error", err)
+		}
+		logPath := filepath.Join(configDir, "crtmon.log")
+		pid, err := daemonize(logPath)
+		if err != nil {
+			logger.Fatal("failed to start daemon", "error", err)
 		}
+		fmt.Printf("crtmon started in background (pid %d), logs: %s\n", pid, logPath)
+		return
+	}
 
-		telegramToken = strings.TrimSpace(cfg.TelegramBotToken)
-		telegramChatID = strings.TrimSpace(cfg.TelegramChatID)
+	if !jsonLogging {
+		printBanner()
+	}
 
-		// Initialize enumeration configuration
-		if cfg.Enumeration.EnableEnum {
-			SetEnumConfig(&cfg.Enumeration)
-			logger.Info("enumeration enabled", "feroxbuster", cfg.Enumeration.FeroxbusterPath, "puredns", cfg.Enumeration.PurednsPath)
+	pidPath := *pidFile
+	if pidPath == "" && isDaemonChild() {
+		configDir, _ := getConfigDir()
+		pidPath = filepath.Join(configDir, "crtmon.pid")
+	}
+	if pidPath != "" {
+		if err := writePIDFile(pidPath); err != nil {
+			logger.Fatal("failed to write pid file", "error", err)
 		}
+	}
 
-		// Initialize webhook configuration
-		if cfg.Webhooks.NewDomains != "" || cfg.Webhooks.SubdomainScans != "" || cfg.Webhooks.DirectoryScans != "" || cfg.Webhooks.DailySummary != "" {
-			SetWebhookConfig(&cfg.Webhooks)
-			logger.Info("webhooks configured",
-				"new_domains", cfg.Webhooks.NewDomains != "",
-				"subdomain_scans", cfg.Webhooks.SubdomainScans != "",
-				"directory_scans", cfg.Webhooks.DirectoryScans != "",
-				"daily_summary", cfg.Webhooks.DailySummary != "",
-			)
-		}
+	// Started by the Windows service manager: no console, no user profile
+	if isWindowsService() {
+		startServiceHandler()
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Fatal("failed to load config", "error", err)
+	}
+	registerSecrets(cfg)
+	// Applying the config fills in defaults, the reloader compares edits with the
+	// file as it was loaded
+	var loaded *Config
+	if cfg != nil {
+		loaded, _ = cloneConfig(cfg)
+	}
+	if names := envOverrideNames(); len(names) > 0 {
+		logger.Info("configuration overridden from environment", "variables", names)
+	}
+
+	if cfg != nil {
+		applyConfig(cfg)
 
 		// Initialize admin panel
 		if cfg.AdminPanel.Enabled {
@@ -137,6 +195,24 @@ func main() {
 			}
 		}
 
+		// Initialize the gRPC API, after the panel so they share API keys
+		if cfg.GRPC.Enabled {
+			SetGRPCConfig(&cfg.GRPC)
+			configDir, _ := getConfigDir()
+			if err := StartGRPCServer(configDir); err != nil {
+				logger.Error("failed to start grpc api", "error", err)
+			}
+		}
+
+		// Slash commands are answered through the admin panel
+		if isDiscordBotEnabled() {
+			go func() {
+				if err := RegisterDiscordCommands(); err != nil {
+					logger.Error("failed to register discord slash commands", "error", err)
+				}
+			}()
+		}
+
 		// Store config globally for admin panel
 		globalConfig = cfg
 	} else {
@@ -151,24 +227,78 @@ func main() {
 	if err := InitDomainTracker(configDir); err != nil {
 		logger.Warn("failed to initialize domain tracker", "error", err)
 	}
+	if err := InitResolveCache(configDir); err != nil {
+		logger.Warn("failed to restore dns cache", "error", err)
+	}
+	if err := InitRetryQueue(configDir); err != nil {
+		logger.Warn("failed to restore notification retry queue", "error", err)
+	}
+	if err := InitCandidateQueue(configDir); err != nil {
+		logger.Warn("failed to initialize org candidate queue", "error", err)
+	}
+	if err := InitNoiseFeedback(configDir); err != nil {
+		logger.Warn("failed to initialize noise feedback", "error", err)
+	}
+	if err := InitIssuanceTracker(configDir); err != nil {
+		logger.Warn("failed to initialize issuance tracker", "error", err)
+	}
+	if err := InitFindingsStore(configDir); err != nil {
+		logger.Warn("failed to initialize findings store", "error", err)
+	}
+	if err := InitCheckpoints(configDir); err != nil {
+		logger.Warn("failed to restore CT log checkpoints", "error", err)
+	}
+	if err := GetStatsTracker().Load(); err != nil {
+		logger.Warn("failed to restore stats", "error", err)
+	}
+	if isDryRun() {
+		logger.Warn("dry run: notifications and alerts are logged instead of sent, and nothing is saved")
+	} else {
+		StartPermutationWorker()
+		StartCodeSearchWorker()
+		StartRetryWorker()
+	}
+
+	// Resolve names no domain target matches, for netblock targets
+	StartNetblockResolver()
+
+	// Initialize SNI manager; the dataset is too large for low-resource hosts
+	if isLowResourceMode() {
+		logger.Info("low-resource mode: SNI dataset disabled")
+	} else if !isSNIEnabled() {
+		logger.Info("SNI dataset disabled in configuration")
+	} else {
+		sniPath := fmt.Sprintf("%s/sni.txt", configDir)
+		InitSNIManager(sniPath)
+		logger.Info("SNI manager initialized", "path", sniPath)
+	}
 
-	// Initialize SNI manager
-	sniPath := fmt.Sprintf("%s/sni.txt", configDir)
-	InitSNIManager(sniPath)
-	logger.Info("SNI manager initialized", "path", sniPath)
+	// Shed caches when the heap passes the low-resource ceiling
+	StartMemoryWatchdog()
 
-	// Start daily summary scheduler
-	StartDailySummaryScheduler()
+	// Scheduled reports and cleanup would send or delete things
+	if !isDryRun() {
+		// Start daily summary scheduler
+		StartDailySummaryScheduler()
 
-	// Periodically clear old tracking entries (once per day)
-	go func() {
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			dt := GetDomainTracker()
-			dt.ClearOldEntries(30 * 24 * time.Hour)
-		}
-	}()
+		// Start certificate expiry scheduler
+		StartExpiryScheduler()
+
+		// Start inactive target scheduler
+		StartFreshnessScheduler()
+
+		// Periodically clear old tracking entries, scan files and cache entries
+		StartCleanupScheduler()
+
+		// Search Censys for certificates the CT feed missed
+		StartCensysPoller(configDir)
+
+		// Probe and enumerate known live domains again
+		StartRescanScheduler()
+
+		// Retire stale assets and alert lifecycle changes
+		StartAssetScheduler()
+	}
 
 	stdinAvailable := false
 	if fi, err := os.Stdin.Stat(); err == nil && (fi.Mode()&os.ModeCharDevice) == 0 {
@@ -201,6 +331,7 @@ func main() {
 			logger.Fatal("no targets configured. please add target domains to ~/.config/crtmon/provider.yaml or use -target flag or stdin")
 		}
 		targets = cfg.Targets
+		targetsFromConfig = true
 		logger.Info("loaded configuration", "targets", len(targets))
 	default:
 		if err := createConfigTemplate(); err != nil {
@@ -211,37 +342,58 @@ func main() {
 		logger.Fatal("please edit the configuration file or provide targets via -target or stdin and run again")
 	}
 
-	discordConfigured := webhookURL != ""
+	// Unicode targets are monitored in their punycode form
+	targets = normalizeTargets(targets)
+	if len(targets) == 0 {
+		logger.Fatal("no valid targets after normalization")
+	}
+
+	discordConfigured := webhookURL != "" || isDiscordBotEnabled()
 	telegramConfigured := telegramToken != "" && telegramChatID != ""
 
+	ntfyConfigured := isNtfyConfigured()
+
+	// -notify takes a single provider or a comma-separated list, e.g. discord,ntfy
 	notifyValue := strings.ToLower(strings.TrimSpace(*notify))
-	switch notifyValue {
-	case "":
-		// No notify flag - notifications off
-	case "discord":
-		if !discordConfigured {
-			logger.Fatal("notify=discord selected but discord webhook is not configured. please configure it in your configuration file (use -config for a custom path)")
-		}
-		notifyDiscord = true
-	case "telegram":
-		if !telegramConfigured {
-			logger.Fatal("notify=telegram selected but telegram bot token/chat id are not configured. please configure them in your configuration file (use -config for a custom path)")
-		}
-		notifyTelegram = true
-	case "both":
-		if !discordConfigured && !telegramConfigured {
-			logger.Fatal("notify=both selected but neither discord nor telegram is configured")
-		}
-		if !discordConfigured {
-			logger.Warn("notify=both selected but discord webhook is not configured; falling back to telegram only")
-		}
-		if !telegramConfigured {
-			logger.Warn("notify=both selected but telegram bot token/chat id are not configured; falling back to discord only")
+	for _, provider := range strings.Split(notifyValue, ",") {
+		switch strings.TrimSpace(provider) {
+		case "":
+			// No notify flag - notifications off
+		case "discord":
+			if !discordConfigured {
+				logger.Fatal("notify=discord selected but discord webhook is not configured. please configure it in your configuration file (use -config for a custom path)")
+			}
+			notifyDiscord = true
+		case "telegram":
+			if !telegramConfigured {
+				logger.Fatal("notify=telegram selected but telegram bot token/chat id are not configured. please configure them in your configuration file (use -config for a custom path)")
+			}
+			notifyTelegram = true
+		case "ntfy":
+			if !ntfyConfigured {
+				logger.Fatal("notify=ntfy selected but ntfy topic_url is not configured. please configure it in your configuration file (use -config for a custom path)")
+			}
+			notifyNtfy = true
+		case "both":
+			if !discordConfigured && !telegramConfigured {
+				logger.Fatal("notify=both selected but neither discord nor telegram is configured")
+			}
+			if !discordConfigured {
+				logger.Warn("notify=both selected but discord webhook is not configured; falling back to telegram only")
+			}
+			if !telegramConfigured {
+				logger.Warn("notify=both selected but telegram bot token/chat id are not configured; falling back to discord only")
+			}
+			notifyDiscord = notifyDiscord || discordConfigured
+			notifyTelegram = notifyTelegram || telegramConfigured
+		default:
+			logger.Fatal("invalid value for -notify. valid options are: discord, telegram, ntfy, both (comma-separated for several)")
 		}
-		notifyDiscord = discordConfigured
-		notifyTelegram = telegramConfigured
-	default:
-		logger.Fatal("invalid value for -notify. valid options are: discord, telegram, both")
+	}
+
+	// Pick up edits to provider.yaml without a restart
+	if loaded != nil && configExists() {
+		StartConfigReloader(loaded)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -249,25 +401,41 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	go func() {
-		<-sigChan
+		select {
+		case <-sigChan:
+		case <-serviceStop:
+		}
 		logger.Info("shutting down...")
 		cancel()
+
+		// A second signal skips the flush
+		<-sigChan
+		logger.Warn("forced exit, pending notifications not flushed")
+		os.Exit(1)
 	}()
 
-	logger.Info("starting crtmon")
-	for i, t := range targets {
-		fmt.Printf("         %d. %s\n", (i + 1), t)
+	if jsonLogging {
+		logger.Info("starting crtmon", "targets", targets)
+	} else {
+		logger.Info("starting crtmon")
+		for i, t := range targets {
+			fmt.Printf("         %d. %s\n", (i + 1), t)
+		}
 	}
 
-	var notifyStatus string
-	if notifyDiscord && notifyTelegram {
-		notifyStatus = "discord, telegram"
-	} else if notifyDiscord {
-		notifyStatus = "discord"
-	} else if notifyTelegram {
-		notifyStatus = "telegram"
-	} else {
-		notifyStatus = "off"
+	var providers []string
+	if notifyDiscord {
+		providers = append(providers, "discord")
+	}
+	if notifyTelegram {
+		providers = append(providers, "telegram")
+	}
+	if notifyNtfy {
+		providers = append(providers, "ntfy")
+	}
+	notifyStatus := "off"
+	if len(providers) > 0 {
+		notifyStatus = strings.Join(providers, ", ")
 	}
 	logger.Debug("configuration", "targets", len(targets), "notification", notifyStatus)
 
@@ -275,17 +443,201 @@ func main() {
 
 	stream := CertStreamEventStream()
 
+	// Ping the systemd watchdog from the main loop so a stalled loop gets restarted
+	var watchdog <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		watchdog = time.NewTicker(interval).C
+	}
+	sdNotify("READY=1")
+
 	for {
 		select {
 		case <-ctx.Done():
+			gracefulShutdown()
+			if pidPath != "" {
+				removePIDFile(pidPath)
+			}
 			logger.Info("goodbye")
+			finishService()
 			return
+		case <-watchdog:
+			sdNotify("WATCHDOG=1")
 		case entry := <-stream:
 			processEntry(entry)
+		case entry := <-censysEntries:
+			processEntry(entry)
 		}
 	}
 }
 
+// applyConfig hands each section of the configuration to its subsystem
+func applyConfig(cfg *Config) {
+	applyLoggingConfig(&cfg.Logging)
+	SetHTTPConfig(&cfg.HTTP)
+
+	if cfg.Webhook == `""` {
+		cfg.Webhook = ""
+	}
+
+	webhookURL = strings.TrimSpace(cfg.Webhook)
+	SetDiscordBotConfig(&cfg.DiscordBot)
+	if !discordConfigured() {
+		logger.Warn("no discord webhook configured in configuration file; discord notifications disabled")
+	}
+
+	telegramToken = strings.TrimSpace(cfg.TelegramBotToken)
+	telegramChatID = strings.TrimSpace(cfg.TelegramChatID)
+
+	SetNtfyConfig(&cfg.Ntfy)
+
+	// Publish discoveries and scan results to NATS or Redis
+	SetBusConfig(&cfg.MessageBus)
+
+	// Archive every certificate match and scan finding to Kafka
+	SetKafkaConfig(&cfg.Kafka)
+
+	// Publish discoveries to an MQTT broker for home automation
+	SetMQTTConfig(&cfg.MQTT)
+
+	// Apply low-resource tuning before other subsystems pick their defaults
+	SetLowResourceConfig(&cfg.LowResource)
+
+	// Initialize enumeration configuration; target profiles may enable it per target
+	SetEnumConfig(&cfg.Enumeration)
+	if cfg.Enumeration.EnableEnum {
+		logger.Info("enumeration enabled", "feroxbuster", cfg.Enumeration.FeroxbusterPath, "puredns", cfg.Enumeration.PurednsPath)
+	}
+
+	// Initialize external tools run against new domains
+	SetToolsConfig(cfg.Tools)
+
+	// Initialize exclusion patterns
+	SetExclusionConfig(&cfg.Exclusions)
+
+	// Initialize per-target settings
+	SetTargetProfiles(cfg.TargetProfiles)
+	if len(cfg.TargetProfiles) > 0 {
+		logger.Info("target profiles configured", "count", len(cfg.TargetProfiles))
+	}
+	SetProgramConfig(cfg.Programs)
+	if len(cfg.Programs) > 0 {
+		logger.Info("programs configured", "programs", programNames())
+	}
+
+	// Initialize webhook configuration
+	SetWebhookConfig(&cfg.Webhooks)
+	if cfg.Webhooks.NewDomains != "" || cfg.Webhooks.SubdomainScans != "" || cfg.Webhooks.DirectoryScans != "" || cfg.Webhooks.DailySummary != "" {
+		logger.Info("webhooks configured",
+			"new_domains", cfg.Webhooks.NewDomains != "",
+			"subdomain_scans", cfg.Webhooks.SubdomainScans != "",
+			"directory_scans", cfg.Webhooks.DirectoryScans != "",
+			"daily_summary", cfg.Webhooks.DailySummary != "",
+		)
+	}
+
+	// Initialize the notification dedup policy
+	SetDedupConfig(&cfg.Dedup)
+
+	// Initialize DNS resolvers and the resolution cache
+	SetResolveConfig(&cfg.DNS)
+	SetSNIConfig(&cfg.SNI)
+
+	// Initialize multi-vantage resolution
+	SetVantageConfig(&cfg.MultiVantage)
+
+	// Initialize built-in HTTP probing
+	SetProbeConfig(&cfg.HTTPProbe)
+
+	// Initialize screenshot capture
+	SetScreenshotConfig(&cfg.Screenshots)
+
+	// Initialize certificate evidence storage
+	SetEvidenceConfig(&cfg.CertEvidence)
+
+	// Initialize subdomain permutations
+	SetPermutationConfig(&cfg.Permutations)
+
+	// Initialize object storage for scan outputs and screenshots
+	SetStorageConfig(&cfg.Storage)
+
+	// Initialize address recording for the asset graph
+	SetGraphConfig(&cfg.Graph)
+
+	// Initialize daily report files
+	SetReportConfig(&cfg.Reports)
+
+	// Initialize summary schedules
+	SetSummarySchedules(cfg.SummarySchedule, cfg.SummaryTimezone, cfg.ScheduledReports)
+
+	// Initialize the JSONL discovery event log
+	SetEventLogConfig(&cfg.EventLog)
+
+	// Initialize cleanup scheduling
+	SetCleanupConfig(&cfg.Cleanup)
+
+	// Initialize organization-based apex discovery
+	SetOrgExpansionConfig(&cfg.OrgExpansion)
+
+	// Initialize on-call escalation
+	SetEscalationConfig(&cfg.Escalation)
+
+	// Initialize per-CA issuance tracking
+	SetIssuanceConfig(&cfg.IssuanceReport)
+
+	// Initialize target freshness alerts
+	SetFreshnessConfig(&cfg.Freshness)
+
+	// Initialize certificate expiry alerts
+	SetExpiryConfig(&cfg.ExpiryAlerts)
+
+	// Initialize dangling CNAME detection
+	SetTakeoverConfig(&cfg.Takeover)
+
+	// Initialize ASN and cloud provider enrichment
+	SetEnrichConfig(&cfg.Enrichment)
+
+	// Initialize GeoIP country lookups
+	SetGeoIPConfig(&cfg.GeoIP)
+
+	// Initialize Shodan enrichment
+	SetShodanConfig(&cfg.Shodan)
+
+	// Initialize Censys certificate search
+	SetCensysConfig(&cfg.Censys)
+
+	// Initialize VirusTotal and urlscan.io reputation checks
+	SetReputationConfig(&cfg.Reputation)
+
+	// Initialize GitHub and GitLab code search
+	SetCodeSearchConfig(&cfg.CodeSearch, cfg.GitHubToken, cfg.GitLabToken)
+
+	// Initialize port scanning of new live hosts
+	SetPortScanConfig(&cfg.PortScan)
+
+	// Initialize scheduled rescans of known live domains
+	SetRescanConfig(&cfg.Rescan)
+
+	// Initialize asset lifecycle alerts
+	SetAssetConfig(&cfg.Assets)
+
+	// Initialize netblock target lookups
+	SetNetblockConfig(&cfg.Netblocks)
+
+	// Initialize per-target issuer policy
+	SetIssuerPolicyConfig(&cfg.IssuerPolicy)
+
+	// Initialize CT log catch-up after downtime
+	SetCatchUpConfig(&cfg.CatchUp)
+
+	// Initialize CAA cross-checks
+	SetCAAConfig(&cfg.CAA)
+
+	// Initialize risk scoring rules
+	SetRiskConfig(&cfg.Risk)
+	SetSeverityConfig(&cfg.Severity)
+	SetRetryConfig(&cfg.NotifyRetry)
+}
+
 func resolveTargetFlag(value string) ([]string, error) {
 	if value == "-" {
 		return loadTargetsFromStdin()
@@ -334,52 +686,236 @@ func loadTargetsFromStdin() ([]string, error) {
 	return targets, nil
 }
 
-func processEntry(entry CertEntry) {
+// EntryDecision records what happened to a single domain of a certificate entry
+type EntryDecision struct {
+	Domain    string   `json:"domain"`
+	Target    string   `json:"target,omitempty"`  // First target credited with the domain
+	Targets   []string `json:"targets,omitempty"` // Every matching target that doesn't exclude the domain
+	Matched   bool     `json:"matched"`
+	Excluded  bool     `json:"excluded"` // Excluded by every matching target
+	Duplicate bool     `json:"duplicate"`
+	Resolves  bool     `json:"resolves"`
+	Notify    bool     `json:"notify"`
+	Enumerate bool     `json:"enumerate"`
+}
+
+// processEntry runs a certificate through the pipeline and returns what was decided for each domain
+func processEntry(entry CertEntry) []EntryDecision {
+	decisions := evaluateEntry(entry, false)
+	fanOutDecisions(entry, decisions)
+	return decisions
+}
+
+// fanOutDecisions hands the decisions made for an entry to everything that records,
+// publishes or checks them
+func fanOutDecisions(entry CertEntry, decisions []EntryDecision) {
+	LogDiscoveryEvents(entry, decisions)
+	publishDiscoveries(entry, decisions)
+	publishGRPCDiscoveries(entry, decisions)
+	publishBusDiscoveries(entry, decisions)
+	archiveKafkaMatches(entry, decisions)
+	publishMQTTDiscoveries(entry, decisions)
+	RecordIssuance(entry, decisions)
+	go CheckIssuerPolicy(entry, decisions)
+	go CheckCAA(entry, decisions)
+	CheckOrgCandidates(entry, decisions)
+}
+
+// isDryRun reports whether notifications are logged instead of sent
+func isDryRun() bool {
+	return *dryRunMode
+}
+
+// evaluateEntry runs an entry through matching, dedup, resolution and notification.
+// In dry-run mode nothing is recorded or sent; the decisions are only reported.
+func evaluateEntry(entry CertEntry, dryRun bool) []EntryDecision {
+	var decisions []EntryDecision
+
 	for _, domain := range entry.Domains {
+		decision := EntryDecision{Domain: domain}
+
 		// Normalize domain and target to lowercase without trailing dots
 		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+		// Overlapping targets (example.com and dev.example.com) all get credit for a domain
 		for _, target := range targets {
-			t := strings.ToLower(strings.TrimSuffix(target, "."))
-
-			// Match only exact target or real subdomains
-			if d == t || strings.HasSuffix(d, "."+t) {
-				logger.Info("new subdomain", "domain", domain, "target", target)
-
-				// Track discovery for this target
-				st := GetStatsTracker()
-				st.RecordDiscovery(target)
-
-				// Check if domain should be notified (deduplication)
-				dt := GetDomainTracker()
-				if !dt.ShouldNotifyDomain(domain) {
-					hitCount := dt.GetDomainHitCount(domain)
-					logger.Debug("domain already notified in last 24h", "domain", domain, "hits", hitCount)
-					// Record issuer even if not notifying
-					if entry.Issuer != "" {
-						dt.RecordDomainIssuer(domain, entry.Issuer)
-					}
-					break
-				}
+			if !matchesTarget(d, target) && !matchesOrgTarget(entry, d, target) {
+				continue
+			}
+			if !decision.Matched {
+				decision.Matched = true
+				decision.Target = target
+			}
 
-				// Record certificate issuer for risk tracking
-				if entry.Issuer != "" {
-					dt.RecordDomainIssuer(domain, entry.Issuer)
-				}
+			// Skip known-infrastructure domains before doing any work
+			if IsExcluded(d, target) {
+				logger.Debug("domain excluded", "domain", domain, "target", target)
+				continue
+			}
+			decision.Targets = append(decision.Targets, target)
+		}
 
-				// Check DNS resolution before notifying
-				if !ResolveDomain(domain) {
-					logger.Debug("domain does not resolve", "domain", domain)
-					dt.RecordDomainResolution(domain, false)
-					break
-				}
+		if decision.Matched {
+			if len(decision.Targets) == 0 {
+				decision.Excluded = true
+			} else {
+				decision.Target = decision.Targets[0]
+				evaluateDomain(entry, &decision, dryRun)
+			}
+		} else if !dryRun {
+			// Names may still resolve into a netblock target
+			QueueNetblockLookup(entry, d)
+		}
 
-				dt.RecordDomainResolution(domain, true)
+		decisions = append(decisions, decision)
+	}
 
-				if notifyDiscord || notifyTelegram {
-					go sendToDiscord(domain, target)
-				}
-				break
+	return decisions
+}
+
+// evaluateDomain records a matched domain once and notifies each of its targets
+func evaluateDomain(entry CertEntry, decision *EntryDecision, dryRun bool) {
+	domain := decision.Domain
+	dt := GetDomainTracker()
+
+	if dryRun {
+		decision.Duplicate = !dt.WouldNotifyDomain(domain)
+		if !decision.Duplicate {
+			decision.Resolves = ResolveDomain(domain)
+			decision.Notify = decision.Resolves && notifiesAny(decision.Targets)
+			decision.Enumerate = decision.Notify && enumeratesAny(decision.Targets)
+		}
+		return
+	}
+
+	logger.Info("new subdomain", "domain", domain, "targets", decision.Targets)
+
+	// Track discovery for each target
+	st := GetStatsTracker()
+	for _, target := range decision.Targets {
+		st.RecordDiscovery(target)
+	}
+
+	// Check if domain should be notified (deduplication)
+	isNew := dt.GetDomainInfo(domain) == nil
+	notify := dt.ShouldNotifyDomain(domain)
+	if isNew {
+		// The first certificate a domain is seen on tells how it was found
+		dt.MarkDomainSource(domain, entry.Source)
+	}
+	if !notify {
+		hitCount := dt.GetDomainHitCount(domain)
+		logger.Debug("domain already notified in last 24h", "domain", domain, "hits", hitCount)
+		dt.RecordDomainTargets(domain, decision.Targets)
+		// Record issuer even if not notifying
+		if entry.Issuer != "" {
+			dt.RecordDomainIssuer(domain, entry.Issuer)
+		}
+		dt.RecordDomainCertificate(domain, entry.Details())
+		StoreCertEvidence(domain, entry)
+		decision.Duplicate = true
+		return
+	}
+	dt.RecordDomainTargets(domain, decision.Targets)
+
+	// Record certificate issuer for risk tracking
+	if entry.Issuer != "" {
+		dt.RecordDomainIssuer(domain, entry.Issuer)
+	}
+	dt.RecordDomainCertificate(domain, entry.Details())
+	StoreCertEvidence(domain, entry)
+
+	// Check DNS resolution before notifying
+	resolves := ResolveDomain(domain)
+	addrs := ResolvedAddrs(domain)
+	dt.RecordDomainResolution(domain, addrs)
+	if isGeoIPEnabled() && len(addrs) > 0 {
+		// Recorded before notifying, since unexpected countries raise the priority
+		dt.RecordDomainCountries(domain, LookupCountries(addrs))
+	}
+	// Dangling CNAMEs usually don't resolve, so check for takeovers either way
+	go CheckTakeover(domain, resolves)
+	// Leaked configs often name internal hosts that don't resolve publicly
+	QueueCodeSearch(domain)
+	if !resolves {
+		logger.Debug("domain does not resolve", "domain", domain)
+		return
+	}
+
+	decision.Resolves = true
+	if isGraphEnabled() || isEnrichEnabled() {
+		// ASN lookups can be slow, keep them off the notification path
+		go RecordAssetLinks(domain)
+	}
+	QueuePermutations(domain, decision.Target)
+
+	if notifiesAny(decision.Targets) || isDryRun() {
+		decision.Notify = true
+		decision.Enumerate = enumeratesAny(decision.Targets)
+		if isDryRun() {
+			if info := dt.GetDomainInfo(domain); info != nil {
+				logger.Info("dry run: would notify", "domain", domain, "targets", decision.Targets, "risk_score", info.RiskScore, "labels", info.RiskLabels)
 			}
+			return
 		}
+		markLoggedAt(domain, entry.LoggedAt)
+		go func(domain string, targets []string) {
+			// Compare answers across vantages before the notification goes out
+			if isVantageEnabled() {
+				CheckVantages(domain)
+			}
+			// Capture HTTP metadata before the notification goes out
+			if isProbeEnabled() {
+				ProbeDomain(domain)
+			}
+			// Risk rules can match open ports, so scan before scoring is final
+			if isPortScanEnabled() {
+				RunPortScan(domain)
+			}
+			// Exposed services raise the risk score, so look them up first too
+			if isShodanEnabled() {
+				LookupShodan(domain)
+			}
+			// Lookalike and high-risk domains get reputation verdicts, once their risk is known
+			if isReputationEnabled() {
+				CheckReputation(domain, targets)
+			}
+			if isScreenshotEnabled() {
+				if _, err := CaptureScreenshot(domain); err != nil {
+					logger.Debug("screenshot failed", "domain", domain, "error", err)
+				}
+			}
+			// Each target batches and sends its own notifications
+			riskScore := 0
+			if info := GetDomainTracker().GetDomainInfo(domain); info != nil {
+				riskScore = info.RiskScore
+			}
+			for _, target := range targets {
+				if belowMinRisk(target, riskScore) {
+					logger.Debug("risk below target minimum, not notifying", "domain", domain, "target", target, "risk_score", riskScore)
+					continue
+				}
+				sendToDiscord(domain, target)
+			}
+		}(domain, decision.Targets)
+	}
+}
+
+// matchesTarget reports whether a domain is the target itself or a real subdomain of it,
+// for keyword targets whether it contains the keyword or matches the pattern, and for
+// netblocks whether it is an address in the range. Org targets match by certificate
+// instead, see matchesOrgTarget.
+func matchesTarget(domain, target string) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if isOrgTarget(target) {
+		return false
+	}
+	if isKeywordTarget(target) {
+		return matchesKeyword(d, target)
+	}
+	if isNetblockTarget(target) {
+		return isIPLiteral(d) && netblockContains(target, d)
 	}
+	t := strings.ToLower(strings.TrimSuffix(target, "."))
+	return d == t || strings.HasSuffix(d, "."+t)
 }
@@ -6,6 +6,55 @@ import (
 	"time"
 )
 
+const (
+	// maxBatchChars keeps a batch inside Discord's 4096 character embed
+	// description and Telegram's 4096 character message limit, leaving room
+	// for code fences and the title line
+	maxBatchChars = 3800
+	// hitSuffixReserve is the room reserved per domain for "  [hit: N]"
+	hitSuffixReserve = 16
+	// telegramMaxLength is Telegram's per-message character limit
+	telegramMaxLength = 4096
+	// maxAttachmentBytes keeps uploaded result files under Discord's 10 MB limit
+	maxAttachmentBytes = 8 << 20
+	// scanFilePreviewLines is how many result lines the embed of an uploaded file shows
+	scanFilePreviewLines = 10
+)
+
+// batchLineLength estimates how many characters a domain occupies in a notification
+func batchLineLength(domain string) int {
+	return len(domain) + hitSuffixReserve
+}
+
+// resultLineLength returns how many characters a scan result line occupies
+func resultLineLength(line string) int {
+	return len(line) + 1
+}
+
+// chunkByLength splits lines into chunks whose total cost stays within limit.
+// A single line larger than limit gets a chunk of its own.
+func chunkByLength(lines []string, limit int, lineCost func(string) int) [][]string {
+	var chunks [][]string
+	var current []string
+	size := 0
+
+	for _, line := range lines {
+		cost := lineCost(line)
+		if len(current) > 0 && size+cost > limit {
+			chunks = append(chunks, current)
+			current = nil
+			size = 0
+		}
+		current = append(current, line)
+		size += cost
+	}
+
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
 func buildDiscordPayload(target string, domains []string) map[string]interface{} {
 	domainList := strings.Builder{}
 	dt := GetDomainTracker()
@@ -19,21 +68,56 @@ func buildDiscordPayload(target string, domains []string) map[string]interface{}
 		}
 	}
 
-	return map[string]interface{}{
-		"tts": false,
-		"embeds": []map[string]interface{}{
-			{
-				"title":       fmt.Sprintf("%s  [%d]", target, len(domains)),
-				"description": fmt.Sprintf("```\n%s\n```", strings.TrimSuffix(domainList.String(), "\n")),
-				"color":       2829617,
-				// "author": map[string]string{
-				// 	"name": "1hehaq/ceye",
-				// 	"url":  "https://github.com/1hehaq/ceye",
-				// },
-				"timestamp": time.Now().Format(time.RFC3339),
-			},
-		},
+	level := batchSeverity(domains)
+	embed := map[string]interface{}{
+		"title":       severityTitle(level, fmt.Sprintf("%s  [%d]", describeTarget(target), len(domains))),
+		"description": fmt.Sprintf("```\n%s\n```", strings.TrimSuffix(domainList.String(), "\n")),
+		"color":       severityColors[level],
+		// "author": map[string]string{
+		// 	"name": "1hehaq/ceye",
+		// 	"url":  "https://github.com/1hehaq/ceye",
+		// },
+		"timestamp": time.Now().Format(time.RFC3339),
 	}
+
+	footer := "Source: " + batchSources(domains)
+	if issuers := batchIssuers(domains); issuers != "" {
+		footer = "Issuer: " + issuers + " | " + footer
+	}
+	embed["footer"] = map[string]string{
+		"text": footer,
+	}
+
+	var fields []map[string]interface{}
+	// Ports the port scan found open
+	if ports := batchOpenPorts(domains, 1000); ports != "" {
+		fields = append(fields, map[string]interface{}{"name": "Open ports", "value": fmt.Sprintf("```\n%s\n```", ports)})
+	}
+	// Services Shodan saw exposed, e.g. RDP or Elasticsearch
+	if exposures := batchExposures(domains, 1000); exposures != "" {
+		fields = append(fields, map[string]interface{}{"name": "Exposed services", "value": fmt.Sprintf("```\n%s\n```", exposures)})
+	}
+	// VirusTotal and urlscan.io verdicts on lookalike and high-risk domains
+	if verdicts := batchReputation(domains, 1024); verdicts != "" {
+		fields = append(fields, map[string]interface{}{"name": "Reputation", "value": verdicts})
+	}
+	// Link each domain back to the dashboard for triage
+	if links := dashboardLinks(domains, 1024); links != "" {
+		fields = append(fields, map[string]interface{}{"name": "Dashboard", "value": links})
+	}
+	if len(fields) > 0 {
+		embed["fields"] = fields
+	}
+
+	payload := map[string]interface{}{
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
+	}
+	// Ping the configured role or users for severe findings
+	if mention := severityMention(level); mention != "" {
+		payload["content"] = mention
+	}
+	return payload
 }
 
 func buildTelegramMessage(target string, domains []string) string {
@@ -49,7 +133,87 @@ func buildTelegramMessage(target string, domains []string) string {
 		}
 	}
 
-	return fmt.Sprintf("*%s* [%d]\n```%s```", target, len(domains), strings.TrimSuffix(domainList.String(), "\n"))
+	message := fmt.Sprintf("*%s* [%d]\n```%s```", describeTarget(target), len(domains), strings.TrimSuffix(domainList.String(), "\n"))
+	if level := batchSeverity(domains); level != "info" {
+		message = fmt.Sprintf("%s *%s*\n%s", severityEmoji[level], strings.ToUpper(level), message)
+	}
+	if issuers := batchIssuers(domains); issuers != "" {
+		message += "\nIssuer: " + issuers
+	}
+	message += "\nSource: " + batchSources(domains)
+	if ports := batchOpenPorts(domains, telegramMaxLength-len(message)-32); ports != "" {
+		message += "\nOpen ports:\n" + ports
+	}
+	if exposures := batchExposures(domains, telegramMaxLength-len(message)-32); exposures != "" {
+		message += "\nExposed services:\n" + exposures
+	}
+	if verdicts := batchReputation(domains, telegramMaxLength-len(message)-32); verdicts != "" {
+		message += "\nReputation:\n" + verdicts
+	}
+	if links := dashboardLinks(domains, telegramMaxLength-len(message)-1); links != "" {
+		message += "\n" + links
+	}
+	return message
+}
+
+// dashboardLinks returns markdown links to the admin panel for a batch, within a character budget
+func dashboardLinks(domains []string, budget int) string {
+	if budget <= 0 {
+		return ""
+	}
+
+	var links strings.Builder
+	for i, domain := range domains {
+		link := dashboardDomainLink(domain)
+		if link == "" {
+			return ""
+		}
+		line := fmt.Sprintf("[%s](%s)\n", domain, link)
+		more := fmt.Sprintf("… and %d more", len(domains)-i)
+		if links.Len()+len(line)+len(more) > budget {
+			links.WriteString(more)
+			break
+		}
+		links.WriteString(line)
+	}
+	return strings.TrimSuffix(links.String(), "\n")
+}
+
+// batchIssuers returns the distinct certificate issuers seen for a batch of domains
+func batchIssuers(domains []string) string {
+	dt := GetDomainTracker()
+	seen := make(map[string]bool)
+	var issuers []string
+
+	for _, domain := range domains {
+		entry := dt.GetDomainInfo(domain)
+		if entry == nil || entry.CertIssuer == "" || seen[entry.CertIssuer] {
+			continue
+		}
+		seen[entry.CertIssuer] = true
+		issuers = append(issuers, entry.CertIssuer)
+	}
+
+	return strings.Join(issuers, ", ")
+}
+
+// describeCertificate formats certificate metadata for a notification
+func describeCertificate(cert *CertDetails) string {
+	certType := "Certificate"
+	if cert.IsPrecertificate {
+		certType = "Precertificate"
+	}
+
+	return fmt.Sprintf("Issuer: %s\nValid: %s to %s\nSerial: %s\nKey: %s\nType: %s\nSANs: %d\nSHA256: %s",
+		cert.Issuer,
+		cert.NotBefore.Format("2006-01-02"),
+		cert.NotAfter.Format("2006-01-02"),
+		cert.SerialNumber,
+		cert.KeyAlgorithm,
+		certType,
+		len(cert.SANs),
+		cert.FingerprintSHA256,
+	)
 }
 
 // sendNewDomainToWebhook sends a new domain to the new domains webhook
@@ -69,8 +233,13 @@ func sendNewDomainToWebhook(domain string, rootDomain string) {
 		wordCount = entry.ResponseWordCount
 	}
 
+	var cert *CertDetails
+	if entry != nil {
+		cert = entry.Certificate
+	}
+
 	// Try to send to new domains webhook
-	if err := SendNewDomainNotification(domain, rootDomain, statusCode, responseSize, lineCount, wordCount); err != nil {
+	if err := SendNewDomainNotification(domain, rootDomain, statusCode, responseSize, lineCount, wordCount, cert); err != nil {
 		logger.Debug("failed to send to new domains webhook", "domain", domain, "error", err)
 	}
 }
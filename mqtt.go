@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// mqttKeepAlive is the keep alive sent in CONNECT; crtmon pings at half of it
+const mqttKeepAlive = 60 * time.Second
+
+// mqttTimeout bounds connecting to the broker and waiting for each acknowledgement
+const mqttTimeout = 10 * time.Second
+
+// MQTT control packet types, shifted into the high nibble of the first byte
+const (
+	mqttConnect    = 1 << 4
+	mqttConnack    = 2 << 4
+	mqttPublish    = 3 << 4
+	mqttPuback     = 4 << 4
+	mqttPubrec     = 5 << 4
+	mqttPubrel     = 6<<4 | 0x02 // PUBREL has reserved flag bits set
+	mqttPubcomp    = 7 << 4
+	mqttPingreq    = 12 << 4
+	mqttPingresp   = 13 << 4
+	mqttDisconnect = 14 << 4
+)
+
+// MQTTConfig holds settings for publishing discoveries to an MQTT broker
+type MQTTConfig struct {
+	URL       string `yaml:"url"`        // mqtt://host:1883, or mqtts://host:8883 for TLS
+	Topic     string `yaml:"topic"`      // Default "crtmon/discovery"
+	QoS       int    `yaml:"qos"`        // 0 (default), 1 or 2
+	Retain    bool   `yaml:"retain"`     // Keep the last discovery on the broker for new subscribers
+	ClientID  string `yaml:"client_id"`  // Default crtmon-<random>
+	Username  string `yaml:"username"`   // Also read from the URL
+	Password  string `yaml:"password"`   // Also read from the URL
+	CAFile    string `yaml:"ca_file"`    // CA bundle for mqtts://, default system roots
+	QueueSize int    `yaml:"queue_size"` // Discoveries held while the broker is unreachable, default 1000
+}
+
+// mqttItem is an encoded discovery waiting to be published
+type mqttItem struct {
+	topic   string
+	payload []byte
+}
+
+var mqttConfig *MQTTConfig
+var mqttMutex sync.Mutex
+var mqttQueue chan mqttItem
+var mqttStart sync.Once
+var mqttDropped atomic.Int64 // Discoveries dropped since the broker was last reachable
+
+// SetMQTTConfig sets the MQTT configuration, starting the publisher the first time a
+// URL is set
+func SetMQTTConfig(cfg *MQTTConfig) {
+	mqttMutex.Lock()
+	defer mqttMutex.Unlock()
+	mqttDefaults(cfg)
+	mqttConfig = cfg
+
+	if cfg.URL != "" {
+		mqttStart.Do(func() {
+			mqttQueue = make(chan mqttItem, cfg.QueueSize)
+			go runMQTTPublisher()
+		})
+	}
+}
+
+// mqttDefaults fills in unset values
+func mqttDefaults(cfg *MQTTConfig) {
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	if cfg.Topic == "" {
+		cfg.Topic = "crtmon/discovery"
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+}
+
+// GetMQTTConfig returns the MQTT configuration
+func GetMQTTConfig() *MQTTConfig {
+	mqttMutex.Lock()
+	defer mqttMutex.Unlock()
+	return mqttConfig
+}
+
+// publishMQTTDiscoveries queues each new discovery of a certificate without waiting for
+// the broker. When the queue is full the discovery is dropped.
+func publishMQTTDiscoveries(entry CertEntry, decisions []EntryDecision) {
+	cfg := GetMQTTConfig()
+	if cfg == nil || cfg.URL == "" || mqttQueue == nil || isDryRun() {
+		return
+	}
+	for _, d := range newDiscoveries(entry, decisions) {
+		payload, err := json.Marshal(d)
+		if err != nil {
+			logger.Error("failed to encode mqtt discovery", "domain", d.Domain, "error", err)
+			continue
+		}
+		select {
+		case mqttQueue <- mqttItem{topic: cfg.Topic, payload: payload}:
+		default:
+			if mqttDropped.Add(1) == 1 {
+				logger.Warn("mqtt queue full, dropping discoveries until the broker is reachable", "queue_size", cap(mqttQueue))
+			}
+		}
+	}
+}
+
+// runMQTTPublisher publishes queued discoveries in order, reconnecting with backoff,
+// and pings the broker while idle. A discovery is retried until it is published, or
+// until the URL is removed.
+func runMQTTPublisher() {
+	var client *mqttClient
+	wait := time.Second
+	ping := time.NewTicker(mqttKeepAlive / 2)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ping.C:
+			if client != nil {
+				if err := client.ping(); err != nil {
+					logger.Warn("mqtt broker stopped answering pings, reconnecting", "error", err)
+					client.Close()
+					client = nil
+				}
+			}
+		case item := <-mqttQueue:
+			for {
+				cfg := GetMQTTConfig()
+				if cfg == nil || cfg.URL == "" {
+					break
+				}
+				if client == nil || !reflect.DeepEqual(client.cfg, *cfg) {
+					if client != nil {
+						client.Close()
+					}
+					var err error
+					client, err = dialMQTT(*cfg)
+					if err != nil {
+						client = nil
+						logger.Warn("failed to connect to mqtt broker", "retry_in", wait, "error", redactSecrets(err.Error()))
+						RecordError(errCategoryMQTT, redactSecrets(err.Error()))
+						time.Sleep(wait)
+						wait = min(wait*2, time.Minute)
+						continue
+					}
+					wait = time.Second
+					logger.Info("connected to mqtt broker", "topic", cfg.Topic, "qos", cfg.QoS)
+				}
+
+				if err := client.publish(item.topic, item.payload); err != nil {
+					logger.Warn("failed to publish to mqtt broker, reconnecting", "topic", item.topic, "error", err)
+					RecordError(errCategoryMQTT, err.Error())
+					client.Close()
+					client = nil
+					continue
+				}
+				if dropped := mqttDropped.Swap(0); dropped > 0 {
+					logger.Warn("mqtt broker reachable again", "dropped", dropped)
+				}
+				break
+			}
+		}
+	}
+}
+
+// mqttClient publishes with MQTT 3.1.1, reading only the acknowledgements it waits for
+type mqttClient struct {
+	cfg      MQTTConfig
+	conn     net.Conn
+	r        *bufio.Reader
+	packetID uint16
+}
+
+// mqttConnackErrors explains the CONNACK return codes
+var mqttConnackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client id rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+// dialMQTT connects and sends CONNECT with a clean session
+func dialMQTT(cfg MQTTConfig) (*mqttClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt url: %w", err)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("mqtt url has no host")
+	}
+
+	var conn net.Conn
+	switch strings.ToLower(u.Scheme) {
+	case "mqtt":
+		conn, err = net.DialTimeout("tcp", busAddress(u, "1883"), mqttTimeout)
+	case "mqtts":
+		tlsConfig := &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pool, err := loadCAFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("mqtt ca_file: %w", err)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: mqttTimeout}, "tcp", busAddress(u, "8883"), tlsConfig)
+	default:
+		return nil, fmt.Errorf("unsupported mqtt scheme %q, use mqtt or mqtts", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	username, password := cfg.Username, cfg.Password
+	if u.User != nil && username == "" {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		suffix := make([]byte, 4)
+		rand.Read(suffix)
+		clientID = "crtmon-" + hex.EncodeToString(suffix)
+	}
+
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+	}
+	if username != "" && password != "" {
+		flags |= 0x40
+	}
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = mqttString(body, clientID)
+	if username != "" {
+		body = mqttString(body, username)
+	}
+	if username != "" && password != "" {
+		body = mqttString(body, password)
+	}
+
+	c := &mqttClient{cfg: cfg, conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(mqttTimeout))
+	if _, err := conn.Write(mqttPacket(mqttConnect, body)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	kind, ack, err := c.read()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if kind != mqttConnack || len(ack) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt: expected CONNACK, got packet type %d", kind>>4)
+	}
+	if code := ack[1]; code != 0 {
+		conn.Close()
+		if msg, ok := mqttConnackErrors[code]; ok {
+			return nil, fmt.Errorf("mqtt: connection refused: %s", msg)
+		}
+		return nil, fmt.Errorf("mqtt: connection refused with code %d", code)
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// mqttPacket frames a control packet with its remaining length
+func mqttPacket(header byte, body []byte) []byte {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(pkt, body...)
+}
+
+// mqttString appends a length-prefixed UTF-8 string
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// read reads one packet and returns its type, with flags cleared, and its body
+func (c *mqttClient) read() (byte, []byte, error) {
+	header, err := c.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, shift := 0, 0
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+		if shift > 21 {
+			return 0, nil, errors.New("mqtt: invalid remaining length")
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xf0, body, nil
+}
+
+// await reads packets until one of the given type for the packet ID arrives
+func (c *mqttClient) await(kind byte, id uint16) error {
+	for {
+		got, body, err := c.read()
+		if err != nil {
+			return err
+		}
+		if got == kind && len(body) >= 2 && binary.BigEndian.Uint16(body) == id {
+			return nil
+		}
+	}
+}
+
+// publish sends a message and, for QoS 1 and 2, waits until the broker has it
+func (c *mqttClient) publish(topic string, payload []byte) error {
+	qos := byte(c.cfg.QoS)
+	header := byte(mqttPublish) | qos<<1
+	if c.cfg.Retain {
+		header |= 0x01
+	}
+	body := mqttString(make([]byte, 0, len(topic)+len(payload)+4), topic)
+	if qos > 0 {
+		c.packetID++
+		if c.packetID == 0 {
+			c.packetID = 1
+		}
+		body = binary.BigEndian.AppendUint16(body, c.packetID)
+	}
+	body = append(body, payload...)
+
+	c.conn.SetDeadline(time.Now().Add(mqttTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := c.conn.Write(mqttPacket(header, body)); err != nil {
+		return err
+	}
+	switch qos {
+	case 1:
+		return c.await(mqttPuback, c.packetID)
+	case 2:
+		if err := c.await(mqttPubrec, c.packetID); err != nil {
+			return err
+		}
+		if _, err := c.conn.Write(mqttPacket(mqttPubrel, binary.BigEndian.AppendUint16(nil, c.packetID))); err != nil {
+			return err
+		}
+		return c.await(mqttPubcomp, c.packetID)
+	}
+	return nil
+}
+
+// ping sends PINGREQ and waits for PINGRESP, keeping the connection alive while idle
+func (c *mqttClient) ping() error {
+	c.conn.SetDeadline(time.Now().Add(mqttTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := c.conn.Write(mqttPacket(mqttPingreq, nil)); err != nil {
+		return err
+	}
+	for {
+		kind, _, err := c.read()
+		if err != nil {
+			return err
+		}
+		if kind == mqttPingresp {
+			return nil
+		}
+	}
+}
+
+// Close sends DISCONNECT and closes the connection
+func (c *mqttClient) Close() error {
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	c.conn.Write(mqttPacket(mqttDisconnect, nil))
+	return c.conn.Close()
+}
+
+// checkMQTTConfig reports MQTT settings that can't work
+func checkMQTTConfig(cfg MQTTConfig) []doctorCheck {
+	raw := strings.TrimSpace(cfg.URL)
+	if raw == "" {
+		return nil
+	}
+	var checks []doctorCheck
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		checks = append(checks, doctorCheck{"mqtt", doctorFail, "url: " + redactSecrets(err.Error())})
+	case u.Hostname() == "":
+		checks = append(checks, doctorCheck{"mqtt", doctorFail, "url has no host"})
+	case !strings.EqualFold(u.Scheme, "mqtt") && !strings.EqualFold(u.Scheme, "mqtts"):
+		checks = append(checks, doctorCheck{"mqtt", doctorFail, fmt.Sprintf("unsupported scheme %q, use mqtt or mqtts", u.Scheme)})
+	}
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		checks = append(checks, doctorCheck{"mqtt", doctorFail, fmt.Sprintf("qos %d, use 0, 1 or 2", cfg.QoS)})
+	}
+	if strings.ContainsAny(cfg.Topic, "+#") || strings.HasPrefix(cfg.Topic, "$") {
+		checks = append(checks, doctorCheck{"mqtt", doctorFail, "topic can't contain + or # or start with $"})
+	}
+	if err == nil && cfg.CAFile != "" && strings.EqualFold(u.Scheme, "mqtt") {
+		checks = append(checks, doctorCheck{"mqtt", doctorWarn, "ca_file is only used with mqtts://"})
+	}
+	return checks
+}
+
+// checkMQTT connects to the configured broker
+func checkMQTT(cfg *Config) []doctorCheck {
+	if strings.TrimSpace(cfg.MQTT.URL) == "" {
+		return nil
+	}
+	mcfg := cfg.MQTT
+	mqttDefaults(&mcfg)
+	client, err := dialMQTT(mcfg)
+	if err != nil {
+		return []doctorCheck{{"mqtt", doctorFail, redactSecrets(err.Error())}}
+	}
+	client.Close()
+	return []doctorCheck{{"mqtt", doctorPass, "connected, publishing to " + mcfg.Topic}}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMQTTRemainingLength(t *testing.T) {
+	// The examples of the MQTT 3.1.1 spec, section 2.2.3
+	tests := []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{'x'}, tt.length)
+		pkt := mqttPacket(mqttPublish, body)
+		if !bytes.Equal(pkt[1:1+len(tt.encoded)], tt.encoded) {
+			t.Errorf("length %d encoded as % x, want % x", tt.length, pkt[1:1+len(tt.encoded)], tt.encoded)
+		}
+
+		c := &mqttClient{r: bufio.NewReader(bytes.NewReader(pkt))}
+		kind, got, err := c.read()
+		if err != nil || kind != mqttPublish || len(got) != tt.length {
+			t.Errorf("length %d read back as type %#x, %d bytes, %v", tt.length, kind, len(got), err)
+		}
+	}
+
+	c := &mqttClient{r: bufio.NewReader(bytes.NewReader([]byte{mqttPublish, 0xff, 0xff, 0xff, 0xff, 0x01}))}
+	if _, _, err := c.read(); err == nil {
+		t.Error("read a remaining length of five bytes")
+	}
+}
+
+// readMQTTPacket reads one packet as a broker, returning its first byte and body
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	c := &mqttClient{r: r}
+	header, err := r.Peek(1)
+	if err != nil {
+		return 0, nil, err
+	}
+	first := header[0]
+	_, body, err := c.read()
+	return first, body, err
+}
+
+// mqttConnectPacket is what a broker reads from CONNECT
+type mqttConnectPacket struct {
+	protocol  string
+	level     byte
+	flags     byte
+	keepAlive uint16
+	clientID  string
+	username  string
+	password  string
+}
+
+func parseMQTTConnect(body []byte) (mqttConnectPacket, error) {
+	var p mqttConnectPacket
+	str := func() string {
+		if len(body) < 2 {
+			return ""
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+n {
+			body = nil
+			return ""
+		}
+		s := string(body[2 : 2+n])
+		body = body[2+n:]
+		return s
+	}
+	p.protocol = str()
+	if len(body) < 4 {
+		return p, fmt.Errorf("short CONNECT")
+	}
+	p.level, p.flags = body[0], body[1]
+	p.keepAlive = binary.BigEndian.Uint16(body[2:])
+	body = body[4:]
+	p.clientID = str()
+	if p.flags&0x80 != 0 {
+		p.username = str()
+	}
+	if p.flags&0x40 != 0 {
+		p.password = str()
+	}
+	if len(body) != 0 {
+		return p, fmt.Errorf("%d bytes after CONNECT payload", len(body))
+	}
+	return p, nil
+}
+
+// fakeMQTTBroker accepts CONNECT with returnCode, then hands the connection to serve
+func fakeMQTTBroker(t *testing.T, returnCode byte, serve func(r *bufio.Reader, conn net.Conn)) (string, chan mqttConnectPacket) {
+	connects := make(chan mqttConnectPacket, 1)
+	addr := fakeServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		header, body, err := readMQTTPacket(r)
+		if err != nil || header != mqttConnect {
+			close(connects)
+			return
+		}
+		connect, err := parseMQTTConnect(body)
+		if err != nil {
+			t.Error(err)
+		}
+		connects <- connect
+		conn.Write(mqttPacket(mqttConnack, []byte{0, returnCode}))
+		if serve != nil && returnCode == 0 {
+			serve(r, conn)
+		}
+	})
+	return addr, connects
+}
+
+func TestMQTTConnect(t *testing.T) {
+	addr, connects := fakeMQTTBroker(t, 0, nil)
+	c, err := dialMQTT(MQTTConfig{URL: "mqtt://alice:secret@" + addr, ClientID: "crtmon-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	got := <-connects
+	want := mqttConnectPacket{protocol: "MQTT", level: 4, flags: 0xc2, keepAlive: 60, clientID: "crtmon-test", username: "alice", password: "secret"}
+	if got != want {
+		t.Errorf("CONNECT = %+v, want %+v", got, want)
+	}
+}
+
+func TestMQTTConnectWithoutUsername(t *testing.T) {
+	addr, connects := fakeMQTTBroker(t, 0, nil)
+	// A password needs a username in MQTT 3.1.1, so it isn't sent alone
+	c, err := dialMQTT(MQTTConfig{URL: "mqtt://" + addr, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	got := <-connects
+	if got.flags != 0x02 || got.password != "" || !strings.HasPrefix(got.clientID, "crtmon-") {
+		t.Errorf("CONNECT = %+v", got)
+	}
+}
+
+func TestMQTTConnectRefused(t *testing.T) {
+	addr, _ := fakeMQTTBroker(t, 4, nil)
+	_, err := dialMQTT(MQTTConfig{URL: "mqtt://alice:wrong@" + addr})
+	if err == nil || !strings.Contains(err.Error(), "bad username or password") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// mqttPublished is a PUBLISH as the broker read it
+type mqttPublished struct {
+	header   byte
+	topic    string
+	packetID uint16
+	payload  string
+}
+
+func TestMQTTPublish(t *testing.T) {
+	for _, qos := range []int{0, 1, 2} {
+		t.Run(fmt.Sprintf("qos%d", qos), func(t *testing.T) {
+			published := make(chan mqttPublished, 1)
+			released := make(chan bool, 1)
+			addr, _ := fakeMQTTBroker(t, 0, func(r *bufio.Reader, conn net.Conn) {
+				header, body, err := readMQTTPacket(r)
+				if err != nil || header&0xf0 != mqttPublish {
+					close(published)
+					return
+				}
+				p := mqttPublished{header: header}
+				n := int(binary.BigEndian.Uint16(body))
+				p.topic = string(body[2 : 2+n])
+				body = body[2+n:]
+				if qos > 0 {
+					p.packetID = binary.BigEndian.Uint16(body)
+					body = body[2:]
+				}
+				p.payload = string(body)
+				published <- p
+
+				id := binary.BigEndian.AppendUint16(nil, p.packetID)
+				switch qos {
+				case 1:
+					// An acknowledgement of another packet is skipped
+					conn.Write(mqttPacket(mqttPuback, binary.BigEndian.AppendUint16(nil, p.packetID+1)))
+					conn.Write(mqttPacket(mqttPuback, id))
+				case 2:
+					conn.Write(mqttPacket(mqttPubrec, id))
+					header, body, err := readMQTTPacket(r)
+					released <- err == nil && header == mqttPubrel && bytes.Equal(body, id)
+					conn.Write(mqttPacket(mqttPubcomp, id))
+				}
+				readMQTTPacket(r) // DISCONNECT
+			})
+
+			c, err := dialMQTT(MQTTConfig{URL: "mqtt://" + addr, QoS: qos, Retain: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.publish("crtmon/discovery", []byte(`{"domain":"a.example.com"}`)); err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+
+			got := <-published
+			if want := byte(mqttPublish) | byte(qos)<<1 | 0x01; got.header != want {
+				t.Errorf("header = %#x, want %#x", got.header, want)
+			}
+			if got.topic != "crtmon/discovery" || got.payload != `{"domain":"a.example.com"}` {
+				t.Errorf("published %q to %q", got.payload, got.topic)
+			}
+			if (qos == 0) != (got.packetID == 0) {
+				t.Errorf("packet id %d at qos %d", got.packetID, qos)
+			}
+			if qos == 2 && !<-released {
+				t.Error("PUBREL missing or malformed")
+			}
+		})
+	}
+}
+
+func TestMQTTPublishUnacknowledged(t *testing.T) {
+	addr, _ := fakeMQTTBroker(t, 0, func(r *bufio.Reader, conn net.Conn) {
+		readMQTTPacket(r)
+		// Closing without PUBACK
+	})
+	c, err := dialMQTT(MQTTConfig{URL: "mqtt://" + addr, QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.publish("crtmon/discovery", []byte("{}")); err == nil {
+		t.Fatal("publish succeeded without PUBACK")
+	}
+}
+
+func TestMQTTPing(t *testing.T) {
+	addr, _ := fakeMQTTBroker(t, 0, func(r *bufio.Reader, conn net.Conn) {
+		if header, body, err := readMQTTPacket(r); err == nil && header == mqttPingreq && len(body) == 0 {
+			conn.Write(mqttPacket(mqttPingresp, nil))
+		}
+		readMQTTPacket(r)
+	})
+	c, err := dialMQTT(MQTTConfig{URL: "mqtt://" + addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ping(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMQTTScheme(t *testing.T) {
+	if _, err := dialMQTT(MQTTConfig{URL: "ws://localhost"}); err == nil || !strings.Contains(err.Error(), "unsupported mqtt scheme") {
+		t.Fatalf("err = %v", err)
+	}
+}
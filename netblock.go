@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NetblockConfig holds settings for matching names by the addresses they resolve to
+type NetblockConfig struct {
+	ResolveNames *bool `yaml:"resolve_names"` // Resolve names no other target matches, default true
+	RateLimit    int   `yaml:"rate_limit"`    // Lookups per second, default 50
+	QueueSize    int   `yaml:"queue_size"`    // Names waiting beyond this are skipped, default 5000
+}
+
+// netblockWorkers resolve queued names at once, within the rate limit
+const netblockWorkers = 10
+
+// maxNetblockChecked bounds the names remembered as resolved; the set is reset when full
+const maxNetblockChecked = 200000
+
+type netblockJob struct {
+	entry  CertEntry
+	domain string
+}
+
+var netblockConfig *NetblockConfig
+var netblockMutex sync.Mutex
+var netblockQueue chan netblockJob
+var netblockLimiter *time.Ticker
+var netblockChecked = make(map[string]bool)
+
+// SetNetblockConfig sets the netblock configuration
+func SetNetblockConfig(cfg *NetblockConfig) {
+	netblockMutex.Lock()
+	defer netblockMutex.Unlock()
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = 50
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 5000
+	}
+	netblockConfig = cfg
+	if netblockLimiter != nil {
+		netblockLimiter.Reset(time.Second / time.Duration(cfg.RateLimit))
+	}
+}
+
+// netblockPrefix parses a netblock target, a CIDR range or a single address
+func netblockPrefix(target string) (netip.Prefix, bool) {
+	t := strings.TrimSpace(target)
+	if prefix, err := netip.ParsePrefix(t); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(t); err == nil {
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+// isNetblockTarget reports whether a target is an address range rather than a domain
+func isNetblockTarget(target string) bool {
+	_, ok := netblockPrefix(target)
+	return ok
+}
+
+// normalizeNetblockTarget writes a range with its host bits cleared, and a single
+// address without a prefix length
+func normalizeNetblockTarget(target string) (string, error) {
+	prefix, ok := netblockPrefix(target)
+	if !ok {
+		return "", fmt.Errorf("invalid netblock target %q", target)
+	}
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String(), nil
+	}
+	return prefix.String(), nil
+}
+
+// isIPLiteral reports whether a name is an address, as found in IP SANs
+func isIPLiteral(name string) bool {
+	_, err := netip.ParseAddr(name)
+	return err == nil
+}
+
+// netblockContains reports whether an address falls in a netblock target
+func netblockContains(target, addr string) bool {
+	prefix, ok := netblockPrefix(target)
+	if !ok {
+		return false
+	}
+	a, err := netip.ParseAddr(addr)
+	return err == nil && prefix.Contains(a.Unmap())
+}
+
+// hasNetblockTargets reports whether any configured target is a netblock
+func hasNetblockTargets() bool {
+	for _, target := range targets {
+		if isNetblockTarget(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// netblockTargetsFor returns the netblock targets any of the addresses fall in
+func netblockTargetsFor(addrs []string) []string {
+	var matched []string
+	for _, target := range targets {
+		if !isNetblockTarget(target) {
+			continue
+		}
+		for _, addr := range addrs {
+			if netblockContains(target, addr) {
+				matched = append(matched, target)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// entryMatchesTarget reports whether a tracked domain belongs to a target. Names
+// matched by resolving into a netblock, or by the organization on their certificate,
+// keep that target among their targets.
+func entryMatchesTarget(entry *DomainEntry, target string) bool {
+	if matchesTarget(entry.Domain, target) {
+		return true
+	}
+	if !isNetblockTarget(target) && !isOrgTarget(target) {
+		return false
+	}
+	for _, t := range entry.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// StartNetblockResolver starts the workers resolving names for netblock targets
+func StartNetblockResolver() {
+	netblockMutex.Lock()
+	defer netblockMutex.Unlock()
+	cfg := netblockConfig
+	if cfg == nil {
+		cfg = &NetblockConfig{RateLimit: 50, QueueSize: 5000}
+	}
+	netblockQueue = make(chan netblockJob, cfg.QueueSize)
+	netblockLimiter = time.NewTicker(time.Second / time.Duration(cfg.RateLimit))
+	limiter := netblockLimiter
+
+	for i := 0; i < netblockWorkers; i++ {
+		go func() {
+			for job := range netblockQueue {
+				<-limiter.C
+				resolveForNetblocks(job)
+			}
+		}()
+	}
+}
+
+// QueueNetblockLookup queues a name no other target matched, to be resolved and
+// matched against the netblock targets. Names already tracked are matched by the
+// addresses recorded for them.
+func QueueNetblockLookup(entry CertEntry, domain string) {
+	if netblockQueue == nil || IsWildcardDomain(domain) || isIPLiteral(domain) || !hasNetblockTargets() {
+		return
+	}
+	netblockMutex.Lock()
+	cfg := netblockConfig
+	netblockMutex.Unlock()
+	if cfg != nil && cfg.ResolveNames != nil && !*cfg.ResolveNames {
+		return
+	}
+
+	if info := GetDomainTracker().GetDomainInfo(domain); info != nil {
+		if matched := netblockTargetsFor(info.Addrs); len(matched) > 0 {
+			evaluateNetblockMatch(entry, domain, matched)
+		}
+		return
+	}
+
+	netblockMutex.Lock()
+	if netblockChecked[domain] {
+		netblockMutex.Unlock()
+		return
+	}
+	if len(netblockChecked) >= maxNetblockChecked {
+		netblockChecked = make(map[string]bool)
+	}
+	netblockChecked[domain] = true
+	netblockMutex.Unlock()
+
+	select {
+	case netblockQueue <- netblockJob{entry: entry, domain: domain}:
+	default:
+		logger.Debug("netblock queue full, skipping name", "domain", domain)
+	}
+}
+
+// resolveForNetblocks resolves a queued name and evaluates it for the netblocks it
+// resolves into. The lookup skips the resolve cache, so the stream of unmatched
+// names doesn't push out the entries of tracked domains.
+func resolveForNetblocks(job netblockJob) {
+	addrs, err := lookupUpstream(GetResolveConfig().Resolvers, job.domain)
+	if err != nil {
+		logger.Debug("netblock lookup failed", "domain", job.domain, "error", err)
+		return
+	}
+	if matched := netblockTargetsFor(addrs); len(matched) > 0 {
+		evaluateNetblockMatch(job.entry, job.domain, matched)
+	}
+}
+
+// evaluateNetblockMatch runs a name that resolves into netblock targets through
+// the rest of the pipeline, like a name matched by its domain
+func evaluateNetblockMatch(entry CertEntry, domain string, matched []string) {
+	decision := EntryDecision{Domain: domain, Matched: true, Target: matched[0]}
+	for _, target := range matched {
+		if IsExcluded(domain, target) {
+			logger.Debug("domain excluded", "domain", domain, "target", target)
+			continue
+		}
+		decision.Targets = append(decision.Targets, target)
+	}
+	if len(decision.Targets) == 0 {
+		decision.Excluded = true
+	} else {
+		decision.Target = decision.Targets[0]
+		evaluateDomain(entry, &decision, false)
+	}
+
+	fanOutDecisions(entry, []EntryDecision{decision})
+}
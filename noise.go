@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Noise proposal review states
+const (
+	noisePending  = "pending"
+	noiseApproved = "approved"
+	noiseRejected = "rejected"
+)
+
+const (
+	// minNoiseSupport is how many domains marked as noise must share a pattern before it is proposed
+	minNoiseSupport = 2
+	// minNoisePrefix keeps prefix patterns from being so short they match everything
+	minNoisePrefix   = 2
+	maxNoiseExamples = 5
+)
+
+// NoiseMark is a domain an analyst marked as noise
+type NoiseMark struct {
+	Domain   string    `json:"domain"`
+	Target   string    `json:"target"`
+	MarkedAt time.Time `json:"marked_at"`
+}
+
+// NoiseProposal is an exclusion pattern learned from domains marked as noise
+type NoiseProposal struct {
+	Pattern  string   `json:"pattern"`
+	Target   string   `json:"target"`
+	Kind     string   `json:"kind"`    // prefix or suffix
+	Support  int      `json:"support"` // Marked domains the pattern covers
+	Matches  int      `json:"matches"` // Tracked domains it would suppress that are not yet blacklisted
+	Examples []string `json:"examples"`
+	Status   string   `json:"status"`
+}
+
+// NoiseFeedback persists noise marks and the review state of learned patterns
+type NoiseFeedback struct {
+	mu       sync.Mutex
+	Marks    []NoiseMark       `json:"marks"`
+	Reviewed map[string]string `json:"reviewed"` // target|pattern -> approved or rejected
+	filePath string
+}
+
+var noiseFeedback *NoiseFeedback
+
+// InitNoiseFeedback loads noise marks and reviewed proposals
+func InitNoiseFeedback(configDir string) error {
+	nf := &NoiseFeedback{
+		Reviewed: make(map[string]string),
+		filePath: filepath.Join(configDir, "noise_feedback.json"),
+	}
+
+	if data, err := os.ReadFile(nf.filePath); err == nil {
+		if err := json.Unmarshal(data, nf); err != nil {
+			logger.Error("failed to load noise feedback", "error", err)
+		}
+		if nf.Reviewed == nil {
+			nf.Reviewed = make(map[string]string)
+		}
+	} else if !os.IsNotExist(err) {
+		logger.Error("failed to read noise feedback", "error", err)
+	}
+
+	noiseFeedback = nf
+	return nil
+}
+
+// GetNoiseFeedback returns the global noise feedback store
+func GetNoiseFeedback() *NoiseFeedback {
+	if noiseFeedback == nil {
+		configDir, _ := getConfigDir()
+		InitNoiseFeedback(configDir)
+	}
+	return noiseFeedback
+}
+
+// MarkNoise blacklists a domain and, with learn set, keeps it as an example for
+// proposing exclusion patterns
+func (nf *NoiseFeedback) MarkNoise(domain string, learn bool) error {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if !GetDomainTracker().SetBlacklisted(d, true) {
+		return fmt.Errorf("domain not found")
+	}
+	if !learn {
+		return nil
+	}
+
+	target := ""
+	for _, t := range targets {
+		if matchesTarget(d, strings.TrimPrefix(t, "*.")) {
+			target = t
+			break
+		}
+	}
+
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+	for _, m := range nf.Marks {
+		if m.Domain == d {
+			return nil
+		}
+	}
+	nf.Marks = append(nf.Marks, NoiseMark{Domain: d, Target: target, MarkedAt: time.Now()})
+	return nf.save()
+}
+
+// Proposals returns learned patterns with the given status, or all when status is empty
+func (nf *NoiseFeedback) Proposals(status string) []NoiseProposal {
+	nf.mu.Lock()
+	marks := append([]NoiseMark(nil), nf.Marks...)
+	reviewed := make(map[string]string, len(nf.Reviewed))
+	for k, v := range nf.Reviewed {
+		reviewed[k] = v
+	}
+	nf.mu.Unlock()
+
+	byTarget := make(map[string][]string)
+	for _, m := range marks {
+		if m.Target != "" {
+			byTarget[m.Target] = append(byTarget[m.Target], m.Domain)
+		}
+	}
+
+	domains := GetDomainTracker().GetAllDomains()
+	var result []NoiseProposal
+	for target, marked := range byTarget {
+		for _, p := range learnNoisePatterns(marked, strings.TrimPrefix(target, "*.")) {
+			p.Target = target
+			p.Status = noisePending
+			if s, ok := reviewed[target+"|"+p.Pattern]; ok {
+				p.Status = s
+			}
+			if status != "" && p.Status != status {
+				continue
+			}
+			if IsExcluded(p.Examples[0], target) && p.Status == noisePending {
+				continue // Already covered by an existing rule
+			}
+			for _, entry := range domains {
+				if !entry.Blacklisted && matchesTarget(entry.Domain, strings.TrimPrefix(target, "*.")) && matchExclusion(p.Pattern, entry.Domain) {
+					p.Matches++
+				}
+			}
+			result = append(result, p)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Support != result[j].Support {
+			return result[i].Support > result[j].Support
+		}
+		return result[i].Pattern < result[j].Pattern
+	})
+	return result
+}
+
+// Review approves a proposal, adding it as an exclusion for its target, or rejects it
+func (nf *NoiseFeedback) Review(pattern, target, status string) error {
+	if status == noiseApproved {
+		if err := AddExclusion(pattern, target); err != nil {
+			return err
+		}
+		if getConfig() != nil {
+			if err := SaveConfig(); err != nil {
+				logger.Error("failed to save config after approving noise pattern", "error", err)
+			}
+		}
+	}
+
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+	nf.Reviewed[target+"|"+pattern] = status
+	return nf.save()
+}
+
+// RemoveTarget deletes the marks of a target or of the given domains, and the review
+// state of the target's proposals, returning how many were removed
+func (nf *NoiseFeedback) RemoveTarget(target string, domains map[string]bool) (int, error) {
+	nf.mu.Lock()
+	defer nf.mu.Unlock()
+
+	removed := 0
+	marks := nf.Marks[:0]
+	for _, m := range nf.Marks {
+		if m.Target == target || domains[m.Domain] {
+			removed++
+			continue
+		}
+		marks = append(marks, m)
+	}
+	nf.Marks = marks
+	for key := range nf.Reviewed {
+		if strings.HasPrefix(key, target+"|") {
+			delete(nf.Reviewed, key)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, nf.save()
+}
+
+// save writes noise feedback to disk. Caller must hold nf.mu.
+func (nf *NoiseFeedback) save() error {
+	data, err := json.MarshalIndent(nf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(nf.filePath, data, 0644)
+}
+
+// learnNoisePatterns proposes glob patterns shared by at least minNoiseSupport marked
+// domains: a common parent ("*.preview.example.com") or a common leading label prefix
+// ("ci-*.example.com"). A shorter parent with no more support than a longer one is dropped.
+func learnNoisePatterns(marked []string, base string) []NoiseProposal {
+	suffixes := make(map[string][]string)
+	prefixes := make(map[string][]string)
+
+	for _, d := range marked {
+		if !strings.HasSuffix(d, "."+base) {
+			continue
+		}
+		labels := strings.Split(strings.TrimSuffix(d, "."+base), ".")
+
+		// Every parent zone strictly between the domain and the target
+		for i := 1; i < len(labels); i++ {
+			parent := strings.Join(labels[i:], ".") + "." + base
+			suffixes[parent] = append(suffixes[parent], d)
+		}
+
+		if prefix := labelPrefix(labels[0]); prefix != "" {
+			rest := strings.Join(append(labels[1:], base), ".")
+			key := prefix + "*." + rest
+			prefixes[key] = append(prefixes[key], d)
+		}
+	}
+
+	var result []NoiseProposal
+	for parent, ds := range suffixes {
+		if len(ds) < minNoiseSupport {
+			continue
+		}
+		covered := false
+		for other, ods := range suffixes {
+			if other != parent && strings.HasSuffix(other, "."+parent) && len(ods) >= len(ds) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, newNoiseProposal("*."+parent, "suffix", ds))
+		}
+	}
+	for pattern, ds := range prefixes {
+		if len(ds) >= minNoiseSupport {
+			result = append(result, newNoiseProposal(pattern, "prefix", ds))
+		}
+	}
+	return result
+}
+
+// labelPrefix returns the part of a label before its first digit or separator, such as
+// "ci-" for ci-4711 or "build" for build42. Labels without such a split give "".
+func labelPrefix(label string) string {
+	i := strings.IndexAny(label, "0123456789-_")
+	if i < minNoisePrefix {
+		return ""
+	}
+	if label[i] == '-' || label[i] == '_' {
+		i++
+	}
+	if i >= len(label) {
+		return ""
+	}
+	return label[:i]
+}
+
+// newNoiseProposal builds a pending proposal from the domains supporting it
+func newNoiseProposal(pattern, kind string, domains []string) NoiseProposal {
+	sort.Strings(domains)
+	examples := domains
+	if len(examples) > maxNoiseExamples {
+		examples = examples[:maxNoiseExamples]
+	}
+	return NoiseProposal{
+		Pattern:  pattern,
+		Kind:     kind,
+		Support:  len(domains),
+		Examples: append([]string(nil), examples...),
+	}
+}
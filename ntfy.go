@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NtfyConfig holds ntfy push notification settings
+type NtfyConfig struct {
+	TopicURL string `yaml:"topic_url"` // e.g. https://ntfy.sh/my-crtmon-topic
+	Token    string `yaml:"token"`     // Access token, takes precedence over username/password
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ntfy message priorities
+const (
+	ntfyPriorityLow     = 2
+	ntfyPriorityDefault = 3
+	ntfyPriorityHigh    = 4
+	ntfyPriorityUrgent  = 5
+)
+
+var ntfyConfig *NtfyConfig
+var ntfyMutex sync.Mutex
+
+// SetNtfyConfig sets the ntfy configuration
+func SetNtfyConfig(cfg *NtfyConfig) {
+	ntfyMutex.Lock()
+	defer ntfyMutex.Unlock()
+	ntfyConfig = cfg
+}
+
+// GetNtfyConfig returns the ntfy configuration
+func GetNtfyConfig() *NtfyConfig {
+	ntfyMutex.Lock()
+	defer ntfyMutex.Unlock()
+	return ntfyConfig
+}
+
+// isNtfyConfigured reports whether an ntfy topic is configured
+func isNtfyConfigured() bool {
+	cfg := GetNtfyConfig()
+	return cfg != nil && strings.TrimSpace(cfg.TopicURL) != ""
+}
+
+// ntfyPriority maps a severity level to an ntfy priority
+func ntfyPriority(level string) int {
+	switch level {
+	case "critical":
+		return ntfyPriorityUrgent
+	case "high":
+		return ntfyPriorityHigh
+	case "low", "medium":
+		return ntfyPriorityDefault
+	default:
+		return ntfyPriorityLow
+	}
+}
+
+// setNtfyAuth adds the configured token or basic auth credentials to a request
+func setNtfyAuth(req *http.Request, cfg *NtfyConfig) {
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	} else if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+}
+
+// sendToNtfy publishes a batch of new domains to the configured ntfy topic
+func sendToNtfy(target string, domains []string) bool {
+	cfg := GetNtfyConfig()
+	if cfg == nil || strings.TrimSpace(cfg.TopicURL) == "" {
+		return false
+	}
+
+	body := strings.Join(domains, "\n")
+	if issuers := batchIssuers(domains); issuers != "" {
+		body += "\nIssuer: " + issuers
+	}
+
+	level := batchSeverity(domains)
+	priority := ntfyPriority(level)
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, strings.TrimSpace(cfg.TopicURL), strings.NewReader(body))
+		if err != nil {
+			logger.Error("failed to build ntfy request", "error", err)
+			return false
+		}
+		req.Header.Set("Title", fmt.Sprintf("%s [%d]", describeTarget(target), len(domains)))
+		req.Header.Set("Priority", strconv.Itoa(priority))
+		req.Header.Set("Tags", "lock,"+level)
+		if link := dashboardDomainLink(domains[0]); link != "" {
+			req.Header.Set("Click", link)
+		}
+		setNtfyAuth(req, cfg)
+
+		resp, err := outboundClient().Do(req)
+		if err != nil {
+			logger.Error("failed to send ntfy notification", "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("ntfy notification for %s: %v", target, err))
+			return false
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			return true
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			logger.Warn("ntfy rate limited, waiting", "attempt", attempt+1)
+			time.Sleep(rateLimitWait * time.Duration(attempt+1))
+			continue
+		}
+
+		logger.Warn("ntfy send error", "status", resp.StatusCode)
+		RecordError(errCategoryWebhook, fmt.Sprintf("ntfy notification for %s: status %d", target, resp.StatusCode))
+		return false
+	}
+
+	logger.Error("failed to send ntfy after retries", "target", target)
+	RecordError(errCategoryWebhook, fmt.Sprintf("ntfy notification for %s: rate limited after retries", target))
+	return false
+}
+
+// sendUrgentNtfy publishes a single alert to the ntfy topic at urgent priority,
+// linking to the domain's detail view
+func sendUrgentNtfy(title, body, domain string) error {
+	cfg := GetNtfyConfig()
+	if cfg == nil || strings.TrimSpace(cfg.TopicURL) == "" {
+		return fmt.Errorf("ntfy not configured")
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSpace(cfg.TopicURL), strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", title)
+	req.Header.Set("Priority", strconv.Itoa(ntfyPriorityUrgent))
+	req.Header.Set("Tags", "warning")
+	if link := dashboardDomainLink(domain); link != "" {
+		req.Header.Set("Click", link)
+	}
+	setNtfyAuth(req, cfg)
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Request and response bodies of the admin API. The OpenAPI document and the Go
+// client types are built from these, so handlers encoding them can't drift from either.
+
+// TargetList is the response of GET /api/targets
+type TargetList struct {
+	Targets   []string          `json:"targets"`
+	Count     int               `json:"count"`
+	Freshness []TargetFreshness `json:"freshness"`
+	Display   map[string]string `json:"display"`  // Target -> Unicode form of internationalized targets
+	Programs  map[string]string `json:"programs"` // Target -> program
+}
+
+// TargetRequest is the body of POST /api/targets
+type TargetRequest struct {
+	Target string `json:"target"`
+}
+
+// TargetChange is the response of adding or removing one target
+type TargetChange struct {
+	Message string   `json:"message"`
+	Target  string   `json:"target"`
+	Targets []string `json:"targets"` // All targets after the change
+}
+
+// BulkTargetsRequest is the body of POST and DELETE /api/targets/bulk
+type BulkTargetsRequest struct {
+	Targets []string `json:"targets"`
+}
+
+// BulkTargetsResponse is the response of POST and DELETE /api/targets/bulk
+type BulkTargetsResponse struct {
+	BulkTargetResult
+	Targets []string `json:"targets"` // All targets after the change
+}
+
+// DomainSummary is a tracked domain as listed by GET /api/domains
+type DomainSummary struct {
+	Domain        string         `json:"domain"`
+	HitCount      int            `json:"hit_count"`
+	FirstSeen     time.Time      `json:"first_seen"`
+	LastSeen      time.Time      `json:"last_seen"`
+	Blacklisted   bool           `json:"blacklisted"`
+	DailyHits     map[string]int `json:"daily_hits"`
+	RiskScore     int            `json:"risk_score"`
+	RiskLabels    []string       `json:"risk_labels"`
+	IsDuplicate   bool           `json:"is_duplicate"`
+	CertIssuer    string         `json:"cert_issuer"`
+	StatusCode    int            `json:"status_code"`
+	Certificate   *CertDetails   `json:"certificate,omitempty"`
+	Probe         *ProbeResult   `json:"probe,omitempty"`
+	HasScreenshot bool           `json:"has_screenshot"`
+	Snoozed       bool           `json:"snoozed"`
+	Providers     []string       `json:"providers,omitempty"`
+	ASNs          []string       `json:"asns,omitempty"`
+	Countries     []string       `json:"countries,omitempty"`
+	Source        string         `json:"source"`
+}
+
+// DomainList is the response of GET /api/domains
+type DomainList struct {
+	Total   int             `json:"total"`
+	Domains []DomainSummary `json:"domains"`
+}
+
+// DomainDetail is the full tracking entry of a domain with its scans
+type DomainDetail struct {
+	*DomainEntry
+	Scans     []Job    `json:"scans"`
+	ScanFiles []string `json:"scan_files"`
+}
+
+// FindingList is the response of GET /api/findings
+type FindingList struct {
+	Findings []Finding `json:"findings"`
+	Count    int       `json:"count"`
+	Total    int       `json:"total"` // Matching findings before the limit
+}
+
+// AssetInventory is the response of GET /api/assets
+type AssetInventory struct {
+	Targets []TargetAssets `json:"targets"`
+	Count   int            `json:"count"`
+}
+
+// apiParam is a query or path parameter of an operation
+type apiParam struct {
+	name, in, description string
+}
+
+// apiOperation describes one method on one path of the admin API
+type apiOperation struct {
+	method, path, summary string
+	params                []apiParam
+	request               interface{} // Zero value of the body type, or nil
+	response              interface{} // Zero value of the JSON response type, or nil for free-form
+	csv                   bool        // Also answers text/csv
+	public                bool        // No authentication
+}
+
+// programParam scopes a response to one program's targets
+var programParam = apiParam{"program", "query", "Only the targets of this program"}
+
+// apiOperations are the operations described by the OpenAPI document
+var apiOperations = []apiOperation{
+	{method: "get", path: "/health", summary: "Health check", public: true},
+	{method: "get", path: "/api/openapi.json", summary: "This OpenAPI document", public: true},
+	{method: "get", path: "/api/stats", summary: "Runtime and discovery statistics", params: []apiParam{programParam}},
+	{method: "get", path: "/api/targets", summary: "List targets", params: []apiParam{programParam}, response: TargetList{}},
+	{method: "post", path: "/api/targets", summary: "Add a target", request: TargetRequest{}, response: TargetChange{}},
+	{method: "delete", path: "/api/targets", summary: "Remove a target", params: []apiParam{{"target", "query", "Target to remove, as listed"}}, response: TargetChange{}},
+	{method: "post", path: "/api/targets/bulk", summary: "Add targets", request: BulkTargetsRequest{}, response: BulkTargetsResponse{}},
+	{method: "delete", path: "/api/targets/bulk", summary: "Remove targets", request: BulkTargetsRequest{}, response: BulkTargetsResponse{}},
+	{method: "get", path: "/api/domains", summary: "List tracked domains", params: []apiParam{
+		{"provider", "query", "Hosting provider: aws, gcp, azure, digitalocean or other"},
+		{"asn", "query", "Origin ASN, e.g. AS13335"},
+		{"source", "query", "Source that first found the domain, e.g. certstream"},
+		programParam,
+	}, response: DomainList{}},
+	{method: "get", path: "/api/domains/{domain}", summary: "Get a tracked domain", params: []apiParam{{"domain", "path", "Tracked domain"}}, response: DomainDetail{}},
+	{method: "get", path: "/api/domains/export", summary: "Export tracked domains", params: []apiParam{
+		{"format", "query", "csv (default) or json"},
+		{"target", "query", "Only domains under this target"},
+		{"since", "query", "First seen on or after, YYYY-MM-DD or RFC 3339"},
+		{"until", "query", "First seen on or before, YYYY-MM-DD or RFC 3339"},
+		{"min_risk", "query", "Minimum risk score"},
+		programParam,
+	}, response: []ExportRecord{}, csv: true},
+	{method: "get", path: "/api/findings", summary: "List scan findings", params: []apiParam{
+		{"domain", "query", "The domain and domains under it"},
+		{"tool", "query", "Tool that reported the finding, e.g. nuclei"},
+		{"kind", "query", "path, subdomain or vulnerability"},
+		{"severity", "query", "Nuclei severity"},
+		{"status", "query", "HTTP status code"},
+		{"since", "query", "Last seen since, RFC 3339 or a duration like 24h"},
+		{"limit", "query", "Most findings returned, default 500"},
+	}, response: FindingList{}},
+	{method: "get", path: "/api/assets", summary: "Asset inventory by target", params: []apiParam{
+		{"target", "query", "Only this target"},
+		{"state", "query", "new, live, offline or retired"},
+		programParam,
+	}, response: AssetInventory{}},
+}
+
+// apiSchemas collects the component schemas of named types while building the document
+type apiSchemas map[string]interface{}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// apiField is a field as encoding/json sees it
+type apiField struct {
+	name    string // Go field name
+	json    string // Key in the JSON object
+	tag     string // The json struct tag, if any
+	omitted bool   // omitempty
+	typ     reflect.Type
+}
+
+// apiFields returns the fields encoding/json writes for a struct, with those of
+// embedded structs promoted
+func apiFields(t reflect.Type) []apiField {
+	var fields []apiField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, apiFields(embedded)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, apiField{f.Name, name, tag, strings.Contains(opts, "omitempty"), f.Type})
+	}
+	return fields
+}
+
+// schema returns the JSON schema of a Go type, adding named structs to the components
+func (s apiSchemas) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		return s.schema(t.Elem())
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Implements(textMarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = nil // Placeholder, for types that refer to themselves
+			s[t.Name()] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+// object returns the schema of a struct's JSON object
+func (s apiSchemas) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for _, f := range apiFields(t) {
+		properties[f.json] = s.schema(f.typ)
+		if !f.omitted {
+			required = append(required, f.json)
+		}
+	}
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// buildOpenAPISpec returns the OpenAPI 3 document of the admin API
+func buildOpenAPISpec() map[string]interface{} {
+	schemas := make(apiSchemas)
+	paths := make(map[string]map[string]interface{})
+
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{
+			"summary":     op.summary,
+			"operationId": op.method + strings.NewReplacer("/api/", "", "/", "_", "{", "", "}", "", ".", "_").Replace(op.path),
+		}
+		if op.public {
+			operation["security"] = []interface{}{}
+		}
+
+		var params []interface{}
+		for _, p := range op.params {
+			params = append(params, map[string]interface{}{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.in == "path",
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if op.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemas.schema(reflect.TypeOf(op.request))},
+				},
+			}
+		}
+
+		body := map[string]interface{}{"type": "object"}
+		if op.response != nil {
+			body = schemas.schema(reflect.TypeOf(op.response))
+		}
+		content := map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": body},
+		}
+		if op.csv {
+			content["text/csv"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		responses := map[string]interface{}{
+			"200":     map[string]interface{}{"description": "OK", "content": content},
+			"default": map[string]interface{}{"$ref": "#/components/responses/Error"},
+		}
+		operation["responses"] = responses
+
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]interface{})
+		}
+		paths[op.path][op.method] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "crtmon admin API",
+			"version":     version,
+			"description": "Manage targets and read what crtmon tracks. Authenticate with an API key in X-API-Key, or a session token from /api/auth/login in Authorization.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey":  map[string]interface{}{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"session": map[string]interface{}{"type": "apiKey", "in": "header", "name": "Authorization"},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "The error as plain text",
+					"content": map[string]interface{}{
+						"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"apiKey": []string{}},
+			map[string]interface{}{"session": []string{}},
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document of the admin API (no auth required)
+func (as *AdminServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildOpenAPISpec())
+}
+
+// writeClientTypes writes Go declarations of the API's named types for a client
+// package, the same types the document's schemas are built from
+func writeClientTypes(w io.Writer, pkg string) error {
+	// Collect the named structs reachable from the operations
+	var names []string
+	types := make(map[string]reflect.Type)
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			visit(t.Elem())
+		case reflect.Map:
+			visit(t.Elem())
+		case reflect.Struct:
+			if t == timeType || t.Implements(textMarshalerType) {
+				return
+			}
+			if t.Name() != "" {
+				if _, seen := types[t.Name()]; seen {
+					return
+				}
+				types[t.Name()] = t
+				names = append(names, t.Name())
+			}
+			for _, f := range apiFields(t) {
+				visit(f.typ)
+			}
+		}
+	}
+	for _, op := range apiOperations {
+		for _, v := range []interface{}{op.request, op.response} {
+			if v != nil {
+				visit(reflect.TypeOf(v))
+			}
+		}
+	}
+	for _, v := range grpcMessages {
+		visit(reflect.TypeOf(v))
+	}
+	sort.Strings(names)
+
+	var decls strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&decls, "\n// %s is the %s schema of the admin API\n", name, name)
+		fmt.Fprintf(&decls, "type %s %s\n", name, goStructType(types[name]))
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by \"crtmon openapi -go %s\"; DO NOT EDIT.\n\n", pkg)
+	fmt.Fprintf(&src, "package %s\n", pkg)
+	if strings.Contains(decls.String(), "time.Time") {
+		src.WriteString("\nimport \"time\"\n")
+	}
+	src.WriteString(decls.String())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+// goType returns the Go type a client decodes a field of type t into
+func goType(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "time.Time"
+	case t.Kind() != reflect.Ptr && t.Implements(textMarshalerType):
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + goType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "[]byte"
+		}
+		return "[]" + goType(t.Elem())
+	case reflect.Map:
+		return "map[" + goType(t.Key()) + "]" + goType(t.Elem())
+	case reflect.Struct:
+		if t.Name() == "" {
+			return goStructType(t)
+		}
+		return t.Name()
+	case reflect.Interface:
+		return "interface{}"
+	}
+	// Named basic types decode as their underlying type
+	return t.Kind().String()
+}
+
+// goStructType returns a struct type literal with the JSON fields of t
+func goStructType(t reflect.Type) string {
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, f := range apiFields(t) {
+		b.WriteString(f.name + " " + goType(f.typ))
+		if f.tag != "" {
+			b.WriteString(" `json:\"" + f.tag + "\"`")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// runOpenAPI implements crtmon openapi [-go package] [-o file], printing the OpenAPI
+// document of the admin API, or the Go types of a client package
+func runOpenAPI(args []string) int {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	goPackage := fs.String("go", "", "write Go types for this client package instead of the document")
+	output := fs.String("o", "", "write to file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var buf bytes.Buffer
+	if *goPackage != "" {
+		if err := writeClientTypes(&buf, *goPackage); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(buildOpenAPISpec()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if *output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrgExpansionConfig holds verified organizations used to discover sibling apexes
+type OrgExpansionConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	Organizations []VerifiedOrg `yaml:"organizations"`
+}
+
+// VerifiedOrg is a certificate subject organization known to belong to a target
+type VerifiedOrg struct {
+	Name    string `yaml:"name"`    // Subject O=
+	Country string `yaml:"country"` // Subject C=, empty matches any country
+}
+
+// Candidate review states
+const (
+	candidatePending  = "pending"
+	candidateApproved = "approved"
+	candidateRejected = "rejected"
+)
+
+// OrgCandidate is a sibling apex surfaced from CT data for review
+type OrgCandidate struct {
+	Apex         string    `json:"apex"`
+	Organization string    `json:"organization"`
+	Country      string    `json:"country"`
+	Examples     []string  `json:"examples"` // Sample domains seen under this apex
+	Hits         int       `json:"hits"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Status       string    `json:"status"`
+}
+
+// CandidateQueue persists org-matched apex candidates
+type CandidateQueue struct {
+	mu         sync.Mutex
+	candidates map[string]*OrgCandidate
+	filePath   string
+}
+
+const maxCandidateExamples = 5
+
+var orgExpansionConfig *OrgExpansionConfig
+var orgExpansionMutex sync.Mutex
+var candidateQueue *CandidateQueue
+
+// SetOrgExpansionConfig sets the organization expansion configuration
+func SetOrgExpansionConfig(cfg *OrgExpansionConfig) {
+	orgExpansionMutex.Lock()
+	defer orgExpansionMutex.Unlock()
+	orgExpansionConfig = cfg
+}
+
+// GetOrgExpansionConfig returns the organization expansion configuration
+func GetOrgExpansionConfig() *OrgExpansionConfig {
+	orgExpansionMutex.Lock()
+	defer orgExpansionMutex.Unlock()
+	return orgExpansionConfig
+}
+
+// InitCandidateQueue initializes the candidate review queue
+func InitCandidateQueue(configDir string) error {
+	q := &CandidateQueue{
+		candidates: make(map[string]*OrgCandidate),
+		filePath:   filepath.Join(configDir, "org_candidates.json"),
+	}
+
+	if data, err := os.ReadFile(q.filePath); err == nil {
+		if err := json.Unmarshal(data, &q.candidates); err != nil {
+			logger.Error("failed to load org candidates", "error", err)
+		}
+	} else if !os.IsNotExist(err) {
+		logger.Error("failed to read org candidates", "error", err)
+	}
+
+	candidateQueue = q
+	return nil
+}
+
+// GetCandidateQueue returns the global candidate queue
+func GetCandidateQueue() *CandidateQueue {
+	if candidateQueue == nil {
+		configDir, _ := getConfigDir()
+		InitCandidateQueue(configDir)
+	}
+	return candidateQueue
+}
+
+// matchVerifiedOrg reports whether a certificate subject belongs to a verified organization
+func matchVerifiedOrg(org, country string) bool {
+	cfg := GetOrgExpansionConfig()
+	if cfg == nil || !cfg.Enabled || org == "" {
+		return false
+	}
+
+	for _, v := range cfg.Organizations {
+		if !strings.EqualFold(strings.TrimSpace(v.Name), strings.TrimSpace(org)) {
+			continue
+		}
+		if v.Country == "" || strings.EqualFold(v.Country, country) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOrgCandidates queues apexes of the decided domains of a verified-org certificate
+// that are not yet targets
+func CheckOrgCandidates(entry CertEntry, decisions []EntryDecision) {
+	if !matchVerifiedOrg(entry.SubjectOrg, entry.SubjectCountry) {
+		return
+	}
+
+	q := GetCandidateQueue()
+	for _, decision := range decisions {
+		// Netblock matches come from a later lookup, the entry was sighted already
+		if decision.Matched && isNetblockTarget(decision.Target) {
+			continue
+		}
+		d := strings.ToLower(strings.TrimPrefix(strings.TrimSuffix(decision.Domain, "."), "*."))
+		apex := extractRootDomain(d)
+		if isKnownApex(apex) {
+			continue
+		}
+		q.Record(apex, d, entry.SubjectOrg, entry.SubjectCountry)
+	}
+}
+
+// isKnownApex reports whether an apex is already covered by a target
+func isKnownApex(apex string) bool {
+	for _, target := range targets {
+		if matchesTarget(apex, target) || strings.EqualFold(extractRootDomain(target), apex) {
+			return true
+		}
+	}
+	return false
+}
+
+// Record adds a sighting of a candidate apex
+func (q *CandidateQueue) Record(apex, domain, org, country string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	c, exists := q.candidates[apex]
+	if !exists {
+		c = &OrgCandidate{
+			Apex:         apex,
+			Organization: org,
+			Country:      country,
+			FirstSeen:    now,
+			Status:       candidatePending,
+		}
+		q.candidates[apex] = c
+		logger.Info("new org candidate apex", "apex", apex, "organization", org, "domain", domain)
+	}
+
+	c.Hits++
+	c.LastSeen = now
+	if len(c.Examples) < maxCandidateExamples {
+		known := false
+		for _, e := range c.Examples {
+			if e == domain {
+				known = true
+				break
+			}
+		}
+		if !known {
+			c.Examples = append(c.Examples, domain)
+		}
+	}
+
+	q.save()
+}
+
+// List returns candidates with the given status (all when empty), most hits first
+func (q *CandidateQueue) List(status string) []OrgCandidate {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var result []OrgCandidate
+	for _, c := range q.candidates {
+		if status == "" || c.Status == status {
+			result = append(result, *c)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Hits > result[j].Hits
+	})
+	return result
+}
+
+// SetStatus marks a candidate approved or rejected
+func (q *CandidateQueue) SetStatus(apex, status string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	c, exists := q.candidates[strings.ToLower(apex)]
+	if !exists {
+		return fmt.Errorf("candidate not found")
+	}
+	c.Status = status
+	return q.save()
+}
+
+// save writes the queue to disk
+func (q *CandidateQueue) save() error {
+	if isDryRun() {
+		return nil
+	}
+	data, err := json.Marshal(q.candidates)
+	if err != nil {
+		return err
+	}
+
+	tempPath := q.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, q.filePath)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// targetOrg marks a target matching certificates by organization rather than by name,
+// e.g. "org:Acme Corp". Every name on a certificate whose subject or issuer O= is the
+// organization matches, which finds domains of the company not yet known as targets.
+const targetOrg = "org:"
+
+// orgTarget returns the organization of an org: target
+func orgTarget(target string) (string, bool) {
+	t := strings.TrimSpace(target)
+	if len(t) < len(targetOrg) || !strings.EqualFold(t[:len(targetOrg)], targetOrg) {
+		return "", false
+	}
+	return t[len(targetOrg):], true
+}
+
+// isOrgTarget reports whether a target matches by certificate organization
+func isOrgTarget(target string) bool {
+	_, ok := orgTarget(target)
+	return ok
+}
+
+// normalizeOrgTarget collapses the whitespace in an organization. Its case is kept
+// for display, matching ignores it.
+func normalizeOrgTarget(target string) (string, error) {
+	org, _ := orgTarget(target)
+	name := strings.Join(strings.Fields(org), " ")
+	if orgKey(name) == "" {
+		return "", fmt.Errorf("invalid organization target %q", target)
+	}
+	return targetOrg + name, nil
+}
+
+// orgKey reduces an organization to lowercase words, so "ACME Corp." and "Acme Corp"
+// compare equal
+func orgKey(org string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(org), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// orgMatches reports whether a certificate organization is the target organization,
+// or starts with it followed by more words, like "Acme Corp" in "Acme Corp, Inc."
+func orgMatches(org, name string) bool {
+	o, n := orgKey(org), orgKey(name)
+	if o == "" || n == "" {
+		return false
+	}
+	return o == n || strings.HasPrefix(o, n+" ")
+}
+
+// matchesOrgTarget reports whether a name on a certificate matches an org: target by
+// the certificate's subject or issuer organization. A common name that isn't a host
+// name, like "Acme Root CA", doesn't.
+func matchesOrgTarget(entry CertEntry, domain, target string) bool {
+	name, ok := orgTarget(target)
+	if !ok || !strings.Contains(domain, ".") || strings.ContainsAny(domain, " \t") {
+		return false
+	}
+	return orgMatches(entry.SubjectOrg, name) || orgMatches(entry.IssuerOrg, name)
+}
+
+// orgTargetCA returns the public CA an org: target names, whose issuer organization
+// is on every certificate it issues, or ""
+func orgTargetCA(target string) string {
+	name, ok := orgTarget(target)
+	if !ok {
+		return ""
+	}
+	for ca := range caaIssuerDomains {
+		if orgMatches(ca, name) || orgMatches(name, ca) {
+			return ca
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification providers with hard send limits
+const (
+	providerDiscord  = "discord"
+	providerTelegram = "telegram"
+)
+
+// providerLimits holds each provider's hard limit in requests per minute per destination
+// (per webhook for Discord, per chat for Telegram)
+var providerLimits = map[string]int{
+	providerDiscord:  30,
+	providerTelegram: 20,
+}
+
+// providerGlobalLimits caps each provider's requests across all destinations, as
+// requests per second with a burst allowance, so parallel senders stay under the
+// provider-wide limit
+var providerGlobalLimits = map[string]struct{ rate, burst float64 }{
+	providerDiscord: {rate: 5, burst: 10},
+}
+
+// tokenBucket is a provider-wide limiter. Tokens may go negative: each reservation
+// waits until the tokens taken before it have refilled.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// sendPacer spaces requests to each destination evenly under its provider limit
+type sendPacer struct {
+	mu      sync.Mutex
+	next    map[string]time.Time // provider:destination -> earliest next send; provider -> global hold
+	buckets map[string]*tokenBucket
+}
+
+var pacer = &sendPacer{next: make(map[string]time.Time), buckets: make(map[string]*tokenBucket)}
+
+// reserveGlobal takes a token from a provider's bucket and returns when it may be
+// used. Caller must hold pacer.mu.
+func (p *sendPacer) reserveGlobal(provider string, now time.Time) time.Time {
+	limit, ok := providerGlobalLimits[provider]
+	if !ok {
+		return now
+	}
+	bucket := p.buckets[provider]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: limit.burst, last: now}
+		p.buckets[provider] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * limit.rate
+	if bucket.tokens > limit.burst {
+		bucket.tokens = limit.burst
+	}
+	bucket.last = now
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return now
+	}
+	return now.Add(time.Duration(-bucket.tokens / limit.rate * float64(time.Second)))
+}
+
+// pace blocks until a destination may be sent to again and reserves that slot
+func pace(provider, destination string) {
+	limit := providerLimits[provider]
+	if limit <= 0 {
+		return
+	}
+	interval := time.Minute / time.Duration(limit)
+	key := provider + ":" + destination
+
+	pacer.mu.Lock()
+	now := time.Now()
+	slot := pacer.next[key]
+	if slot.Before(now) {
+		slot = now
+	}
+	// A global rate limit holds every destination
+	if hold := pacer.next[provider]; hold.After(slot) {
+		slot = hold
+	}
+	if global := pacer.reserveGlobal(provider, now); global.After(slot) {
+		slot = global
+	}
+	pacer.next[key] = slot.Add(interval)
+	pacer.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		logger.Debug("pacing notification", "provider", provider, "wait", wait.Round(time.Millisecond))
+		time.Sleep(wait)
+	}
+}
+
+// hold delays the next send to a key until a time. Caller must hold pacer.mu.
+func (p *sendPacer) hold(key string, until time.Time) {
+	if until.After(p.next[key]) {
+		p.next[key] = until
+	}
+}
+
+// headerSeconds parses a header holding a number of seconds
+func headerSeconds(resp *http.Response, name string) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(resp.Header.Get(name), 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// backoff holds all sends to a destination after a 429, honoring Retry-After. A
+// Discord global limit holds every destination.
+func backoff(provider, destination string, resp *http.Response) {
+	delay := rateLimitWait
+	if d, ok := headerSeconds(resp, "Retry-After"); ok {
+		delay = d
+	}
+	if d, ok := headerSeconds(resp, "X-RateLimit-Reset-After"); ok && d > delay {
+		delay = d
+	}
+	key := provider + ":" + destination
+	global := resp.Header.Get("X-RateLimit-Global") == "true" || resp.Header.Get("X-RateLimit-Scope") == "global"
+	if global {
+		key = provider
+	}
+
+	pacer.mu.Lock()
+	defer pacer.mu.Unlock()
+	pacer.hold(key, time.Now().Add(delay))
+	logger.Warn("rate limited, backing off", "provider", provider, "delay", delay, "global", global)
+}
+
+// observeRateLimit holds a destination until its bucket resets when a response
+// says no requests remain, so the next send doesn't hit a 429
+func observeRateLimit(provider, destination string, resp *http.Response) {
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	delay, ok := headerSeconds(resp, "X-RateLimit-Reset-After")
+	if !ok {
+		return
+	}
+
+	pacer.mu.Lock()
+	defer pacer.mu.Unlock()
+	pacer.hold(provider+":"+destination, time.Now().Add(delay))
+}
+
+// discordDo sends a request to Discord through the shared limiter and records the
+// rate limit headers of the response
+func discordDo(req *http.Request, bucket string) (*http.Response, error) {
+	pace(providerDiscord, bucket)
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	observeRateLimit(providerDiscord, bucket, resp)
+	return resp, nil
+}
+
+// discordPost posts a body to a Discord URL through the shared limiter
+func discordPost(url, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return discordDo(req, discordBucket(url))
+}
+
+// discordBucket returns the rate limit bucket of a Discord webhook or message URL
+func discordBucket(webhookURL string) string {
+	bucket := strings.SplitN(webhookURL, "?", 2)[0]
+	if idx := strings.Index(bucket, "/messages/"); idx != -1 {
+		bucket = bucket[:idx]
+	}
+	return bucket
+}
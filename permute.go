@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PermutationConfig holds settings for generating and resolving variations of new subdomains
+type PermutationConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	Words        []string `yaml:"words"`          // Replaces the built-in word list when set
+	MaxPerDomain int      `yaml:"max_per_domain"` // Cap on candidates generated for one discovery
+	ResolveRate  int      `yaml:"resolve_rate"`   // DNS lookups per second across all permutation jobs
+	QueueSize    int      `yaml:"queue_size"`     // Discoveries waiting to be permuted; more are dropped
+}
+
+// defaultPermutationWords are common environment and service labels, in the spirit of dnsgen
+var defaultPermutationWords = []string{
+	"dev", "development", "staging", "stage", "stg", "test", "qa", "uat", "prod", "preprod",
+	"api", "admin", "internal", "beta", "old", "new", "v1", "v2", "app", "portal",
+	"auth", "sso", "vpn", "mail", "backup", "demo", "sandbox", "int", "ext", "cdn",
+}
+
+// sourcePermutation marks tracked domains found by resolving permutations rather than in CT logs
+const sourcePermutation = "permutation"
+
+// permutationJob is one discovered domain whose permutations should be resolved
+type permutationJob struct {
+	domain string
+	target string
+}
+
+var permutationConfig *PermutationConfig
+var permutationMutex sync.Mutex
+var permutationQueue chan permutationJob
+
+// permuted remembers domains already permuted this run so cooldown re-notifications
+// don't repeat the work. It is reset once it reaches maxPermuted.
+var permuted = make(map[string]bool)
+
+const maxPermuted = 10000
+
+// SetPermutationConfig sets the permutation configuration
+func SetPermutationConfig(cfg *PermutationConfig) {
+	permutationMutex.Lock()
+	defer permutationMutex.Unlock()
+	permutationConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if len(cfg.Words) == 0 {
+		cfg.Words = defaultPermutationWords
+	}
+	if cfg.MaxPerDomain <= 0 {
+		cfg.MaxPerDomain = 200
+	}
+	if cfg.ResolveRate <= 0 {
+		cfg.ResolveRate = 10
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 100
+	}
+}
+
+// GetPermutationConfig returns the permutation configuration
+func GetPermutationConfig() *PermutationConfig {
+	permutationMutex.Lock()
+	defer permutationMutex.Unlock()
+	return permutationConfig
+}
+
+// StartPermutationWorker starts the single rate-limited worker that resolves permutations
+func StartPermutationWorker() {
+	cfg := GetPermutationConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	permutationQueue = make(chan permutationJob, cfg.QueueSize)
+	go func() {
+		limiter := time.NewTicker(time.Second / time.Duration(cfg.ResolveRate))
+		defer limiter.Stop()
+		for job := range permutationQueue {
+			resolvePermutations(cfg, job, limiter.C)
+		}
+	}()
+	logger.Info("subdomain permutations enabled", "words", len(cfg.Words), "max_per_domain", cfg.MaxPerDomain, "resolve_rate", cfg.ResolveRate)
+}
+
+// QueuePermutations schedules permutations of a newly found domain without blocking
+func QueuePermutations(domain, target string) {
+	// Permutations are of names under an apex, netblocks have none
+	if permutationQueue == nil || isNetblockTarget(target) {
+		return
+	}
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	permutationMutex.Lock()
+	if permuted[d] {
+		permutationMutex.Unlock()
+		return
+	}
+	if len(permuted) >= maxPermuted {
+		permuted = make(map[string]bool)
+	}
+	permuted[d] = true
+	permutationMutex.Unlock()
+
+	select {
+	case permutationQueue <- permutationJob{domain: d, target: target}:
+	default:
+		logger.Warn("permutation queue full, skipping domain", "domain", d)
+	}
+}
+
+// resolvePermutations resolves each candidate at the configured rate and tracks the
+// ones that resolve. Parents with wildcard DNS are skipped since every name resolves.
+func resolvePermutations(cfg *PermutationConfig, job permutationJob, tick <-chan time.Time) {
+	dt := GetDomainTracker()
+	candidates := generatePermutations(job.domain, job.target, cfg.Words, cfg.MaxPerDomain)
+
+	wildcard := make(map[string]bool)
+	found := 0
+	for _, candidate := range candidates {
+		if dt.GetDomainInfo(candidate) != nil || IsExcluded(candidate, job.target) {
+			continue
+		}
+		parent := candidate[strings.Index(candidate, ".")+1:]
+		if _, checked := wildcard[parent]; !checked {
+			<-tick
+			wildcard[parent] = hasWildcardDNS(parent)
+		}
+		if wildcard[parent] {
+			continue
+		}
+
+		<-tick
+		if !ResolveDomain(candidate) {
+			continue
+		}
+		if !dt.AddDomain(candidate, sourcePermutation) {
+			continue
+		}
+		found++
+		logger.Info("permutation resolves", "domain", candidate, "from", job.domain, "target", job.target)
+		publishLive(liveDiscovery, map[string]interface{}{
+			"domain":   candidate,
+			"target":   job.target,
+			"source":   sourcePermutation,
+			"resolves": true,
+		})
+	}
+	logger.Debug("permutations resolved", "domain", job.domain, "candidates", len(candidates), "found", found)
+}
+
+// hasWildcardDNS reports whether a random label under parent resolves
+func hasWildcardDNS(parent string) bool {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return ResolveDomain("crtmon-" + hex.EncodeToString(b) + "." + parent)
+}
+
+// generatePermutations derives candidate names from the labels of domain below its
+// target: inserting words as new labels, joining them with dashes, swapping known
+// words for others and incrementing numbers. At most limit candidates are returned.
+func generatePermutations(domain, target string, words []string, limit int) []string {
+	base := strings.TrimPrefix(target, "*.")
+	if !strings.HasSuffix(domain, "."+base) {
+		return nil
+	}
+	labels := strings.Split(strings.TrimSuffix(domain, "."+base), ".")
+
+	isWord := make(map[string]bool, len(words))
+	for _, w := range words {
+		isWord[w] = true
+	}
+
+	seen := map[string]bool{domain: true}
+	var result []string
+	add := func(l []string) bool {
+		name := strings.Join(l, ".") + "." + base
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+		return len(result) < limit
+	}
+	with := func(i int, label string) []string {
+		l := append([]string(nil), labels...)
+		l[i] = label
+		return l
+	}
+
+	// Cheapest and most productive first: swap words and bump numbers
+	for i, label := range labels {
+		if isWord[label] {
+			for _, w := range words {
+				if w != label && !add(with(i, w)) {
+					return result
+				}
+			}
+		}
+		for _, n := range bumpNumbers(label) {
+			if !add(with(i, n)) {
+				return result
+			}
+		}
+	}
+
+	for _, w := range words {
+		for i, label := range labels {
+			if label == w {
+				continue
+			}
+			if !add(with(i, w+"-"+label)) || !add(with(i, label+"-"+w)) {
+				return result
+			}
+		}
+		for i := 0; i <= len(labels); i++ {
+			l := append(append(append([]string(nil), labels[:i]...), w), labels[i:]...)
+			if !add(l) {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// bumpNumbers returns a label with its last number incremented and decremented,
+// so api2 gives api1 and api3
+func bumpNumbers(label string) []string {
+	end := strings.LastIndexAny(label, "0123456789")
+	if end < 0 {
+		return nil
+	}
+	start := end
+	for start > 0 && label[start-1] >= '0' && label[start-1] <= '9' {
+		start--
+	}
+	n, err := strconv.Atoi(label[start : end+1])
+	if err != nil {
+		return nil
+	}
+
+	var out []string
+	for _, v := range []int{n - 1, n + 1} {
+		if v >= 0 {
+			out = append(out, label[:start]+strconv.Itoa(v)+label[end+1:])
+		}
+	}
+	return out
+}
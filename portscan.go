@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PortScanConfig holds settings for scanning new live hosts for open ports
+type PortScanConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	Scanner     string   `yaml:"scanner"`     // native (default), naabu or nmap
+	Path        string   `yaml:"path"`        // naabu or nmap binary, defaults to its name
+	Args        []string `yaml:"args"`        // Extra scanner arguments, e.g. ["-sV"] for nmap
+	TopPorts    int      `yaml:"top_ports"`   // Most common ports scanned, up to 100, default 100
+	Ports       []int    `yaml:"ports"`       // Scanned as well, e.g. [9200, 6379, 27017]
+	Timeout     int      `yaml:"timeout"`     // Seconds per host, default 60
+	Concurrency int      `yaml:"concurrency"` // Hosts scanned at once, default 2
+}
+
+// PortScanResult holds the open ports found on a domain
+type PortScanResult struct {
+	Open      []int          `json:"open"`
+	Services  map[int]string `json:"services,omitempty"` // Port -> service nmap named, e.g. "http"
+	Scanner   string         `json:"scanner"`
+	ScannedAt time.Time      `json:"scanned_at"`
+}
+
+const (
+	portScanNative = "native"
+	portScanNaabu  = "naabu"
+	portScanNmap   = "nmap"
+)
+
+// topTCPPorts are nmap's 100 most common TCP ports, the 20 most common first
+var topTCPPorts = []int{
+	80, 23, 443, 21, 22, 25, 3389, 110, 445, 139, 143, 53, 135, 3306, 8080, 1723, 111, 995, 993, 5900,
+	7, 9, 13, 26, 37, 79, 81, 88, 106, 113, 119, 144, 179, 199, 389, 427, 444, 465, 513, 514,
+	515, 543, 544, 548, 554, 587, 631, 646, 873, 990, 1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1755, 1900,
+	2000, 2001, 2049, 2121, 2717, 3000, 3128, 3986, 4899, 5000, 5009, 5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800,
+	6000, 6001, 6646, 7070, 8000, 8008, 8009, 8081, 8443, 8888, 9100, 9999, 10000, 32768, 49152, 49153, 49154, 49155, 49156, 49157,
+}
+
+// The native scanner tries this many ports of a host at once, fewer in low-resource
+// mode, and gives each this long to connect
+const (
+	nativeScanWorkers    = 50
+	nativeScanLowWorkers = 10
+	nativeDialTimeout    = 2 * time.Second
+)
+
+var portScanConfig *PortScanConfig
+var portScanMutex sync.Mutex
+var portScanSlots chan struct{}
+
+// SetPortScanConfig sets the port scan configuration
+func SetPortScanConfig(cfg *PortScanConfig) {
+	portScanMutex.Lock()
+	defer portScanMutex.Unlock()
+	cfg.Scanner = strings.ToLower(strings.TrimSpace(cfg.Scanner))
+	if cfg.Scanner == "" {
+		cfg.Scanner = portScanNative
+	}
+	cfg.Path = strings.TrimSpace(cfg.Path)
+	if cfg.Path == "" && cfg.Scanner != portScanNative {
+		cfg.Path = cfg.Scanner
+	}
+	if cfg.TopPorts <= 0 || cfg.TopPorts > len(topTCPPorts) {
+		cfg.TopPorts = len(topTCPPorts)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 2
+		if isLowResourceMode() {
+			cfg.Concurrency = 1
+		}
+	}
+	portScanConfig = cfg
+	portScanSlots = make(chan struct{}, cfg.Concurrency)
+}
+
+// GetPortScanConfig returns the port scan configuration
+func GetPortScanConfig() *PortScanConfig {
+	portScanMutex.Lock()
+	defer portScanMutex.Unlock()
+	return portScanConfig
+}
+
+// isPortScanEnabled reports whether port scanning is configured and enabled
+func isPortScanEnabled() bool {
+	cfg := GetPortScanConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// RunPortScan scans a resolved domain for open ports and records them
+func RunPortScan(domain string) *PortScanResult {
+	portScanMutex.Lock()
+	cfg := portScanConfig
+	slots := portScanSlots
+	portScanMutex.Unlock()
+
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	// The name comes from a certificate and goes on the scanner's command line
+	if !isSafeHost(domain) {
+		logger.Warn("not port scanning unusual domain name", "domain", domain)
+		return nil
+	}
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+
+	ports := portScanList(cfg)
+	result := &PortScanResult{Scanner: cfg.Scanner}
+	var err error
+	switch cfg.Scanner {
+	case portScanNaabu:
+		result.Open, err = runNaabu(ctx, cfg, domain, ports)
+	case portScanNmap:
+		result.Open, result.Services, err = runNmap(ctx, cfg, domain, ports)
+	default:
+		addrs := ResolvedAddrs(domain)
+		if len(addrs) == 0 {
+			return nil
+		}
+		// The first address is the one clients connect to
+		result.Open = scanTCPPorts(ctx, addrs[0], ports)
+	}
+	if err != nil {
+		logger.Warn("port scan failed", "domain", domain, "scanner", cfg.Scanner, "error", err)
+		return nil
+	}
+	sort.Ints(result.Open)
+	result.ScannedAt = time.Now()
+
+	GetDomainTracker().RecordDomainPortScan(domain, result)
+	logger.Debug("port scan complete", "domain", domain, "scanner", cfg.Scanner, "ports", len(ports), "open", result.Open)
+	return result
+}
+
+// portScanList returns the top ports followed by the configured ones, without repeats
+func portScanList(cfg *PortScanConfig) []int {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, port := range append(append([]int(nil), topTCPPorts[:cfg.TopPorts]...), cfg.Ports...) {
+		if port > 0 && port <= 65535 && !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// joinPorts returns ports as a comma-separated list
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ",")
+}
+
+// scanTCPPorts returns the ports of addr accepting a TCP connection. Connections are
+// made directly, the outbound proxy is only for HTTP.
+func scanTCPPorts(ctx context.Context, addr string, ports []int) []int {
+	workers := nativeScanWorkers
+	if isLowResourceMode() {
+		workers = nativeScanLowWorkers
+	}
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var open []int
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialer := net.Dialer{Timeout: nativeDialTimeout}
+			for port := range jobs {
+				conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+				if err != nil {
+					continue
+				}
+				conn.Close()
+				mu.Lock()
+				open = append(open, port)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, port := range ports {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- port
+	}
+	close(jobs)
+	wg.Wait()
+	return open
+}
+
+// runNaabu scans a domain with naabu, which prints one host:port per open port
+func runNaabu(ctx context.Context, cfg *PortScanConfig, domain string, ports []int) ([]int, error) {
+	if !isSafeHost(domain) {
+		return nil, fmt.Errorf("unsafe host name %q", domain)
+	}
+	args := append([]string{"-host", domain, "-p", joinPorts(ports), "-silent"}, cfg.Args...)
+	output, err := runPortScanner(ctx, cfg.Path, args)
+	if err != nil {
+		return nil, err
+	}
+	var open []int
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			continue
+		}
+		if port, err := strconv.Atoi(line[i+1:]); err == nil && !seen[port] {
+			seen[port] = true
+			open = append(open, port)
+		}
+	}
+	return open, nil
+}
+
+// runNmap scans a domain with nmap and reads its grepable output, lines like
+// "Host: 1.2.3.4 (example.com)	Ports: 22/open/tcp//ssh///, 80/open/tcp//http///"
+func runNmap(ctx context.Context, cfg *PortScanConfig, domain string, ports []int) ([]int, map[int]string, error) {
+	if !isSafeHost(domain) {
+		return nil, nil, fmt.Errorf("unsafe host name %q", domain)
+	}
+	args := append([]string{"-Pn", "--open", "-p", joinPorts(ports), "-oG", "-"}, cfg.Args...)
+	// -- ends the options, so the target is never read as one
+	output, err := runPortScanner(ctx, cfg.Path, append(args, "--", domain))
+	if err != nil {
+		return nil, nil, err
+	}
+	open, services := parseNmapGrepable(string(output))
+	return open, services, nil
+}
+
+// parseNmapGrepable returns the open ports in nmap's grepable output and the
+// services it named
+func parseNmapGrepable(output string) ([]int, map[int]string) {
+	var open []int
+	services := make(map[int]string)
+	seen := make(map[int]bool)
+	for _, line := range strings.Split(output, "\n") {
+		_, list, ok := strings.Cut(line, "Ports: ")
+		if !ok {
+			continue
+		}
+		// Further fields follow the port list after a tab
+		list, _, _ = strings.Cut(list, "\t")
+		for _, item := range strings.Split(list, ",") {
+			fields := strings.Split(strings.TrimSpace(item), "/")
+			if len(fields) < 5 || fields[1] != "open" {
+				continue
+			}
+			port, err := strconv.Atoi(fields[0])
+			if err != nil || seen[port] {
+				continue
+			}
+			seen[port] = true
+			open = append(open, port)
+			if fields[4] != "" {
+				services[port] = fields[4]
+			}
+		}
+	}
+	if len(services) == 0 {
+		services = nil
+	}
+	return open, services
+}
+
+// runPortScanner runs a scanner binary and returns what it printed
+func runPortScanner(ctx context.Context, path string, args []string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("timed out")
+		}
+		return nil, fmt.Errorf("%w: %s", err, lastLine(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// batchOpenPorts returns one line per domain in the batch listing its open ports, up
+// to budget characters
+func batchOpenPorts(domains []string, budget int) string {
+	dt := GetDomainTracker()
+	var lines strings.Builder
+	for _, domain := range domains {
+		entry := dt.GetDomainInfo(domain)
+		if entry == nil || entry.PortScan == nil || len(entry.PortScan.Open) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s\n", domain, strings.ReplaceAll(joinPorts(entry.PortScan.Open), ",", ", "))
+		if lines.Len()+len(line) > budget {
+			break
+		}
+		lines.WriteString(line)
+	}
+	return strings.TrimSuffix(lines.String(), "\n")
+}
+
+// checkPortScanConfig reports a port scan section that cannot work as configured
+func checkPortScanConfig(cfg PortScanConfig) []doctorCheck {
+	var checks []doctorCheck
+	switch strings.ToLower(strings.TrimSpace(cfg.Scanner)) {
+	case "", portScanNative, portScanNaabu, portScanNmap:
+	default:
+		checks = append(checks, doctorCheck{"port scan", doctorFail, "scanner must be native, naabu or nmap"})
+	}
+	if cfg.TopPorts > len(topTCPPorts) {
+		checks = append(checks, doctorCheck{"port scan", doctorWarn, fmt.Sprintf("top_ports above %d scans the %d most common ports, list others in ports", len(topTCPPorts), len(topTCPPorts))})
+	}
+	for _, port := range cfg.Ports {
+		if port <= 0 || port > 65535 {
+			checks = append(checks, doctorCheck{"port scan", doctorFail, fmt.Sprintf("ports: %d is not a port", port)})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProbeConfig holds built-in HTTP probing settings
+type ProbeConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	Concurrency int  `yaml:"concurrency"` // Max simultaneous probes
+	Timeout     int  `yaml:"timeout"`     // Per-request timeout in seconds
+}
+
+// ProbeResult holds HTTP metadata captured for a domain
+type ProbeResult struct {
+	URL           string    `json:"url"`
+	StatusCode    int       `json:"status_code"`
+	Title         string    `json:"title"`
+	ContentLength int       `json:"content_length"`
+	Server        string    `json:"server"`
+	RedirectChain []string  `json:"redirect_chain,omitempty"`
+	ProbedAt      time.Time `json:"probed_at"`
+}
+
+const (
+	maxProbeBody      = 512 * 1024
+	maxProbeRedirects = 10
+)
+
+var probeConfig *ProbeConfig
+var probeMutex sync.Mutex
+var probeSlots chan struct{}
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// SetProbeConfig sets the HTTP probe configuration
+func SetProbeConfig(cfg *ProbeConfig) {
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+	probeConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 10
+		if isLowResourceMode() {
+			cfg.Concurrency = 2
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	probeSlots = make(chan struct{}, cfg.Concurrency)
+}
+
+// GetProbeConfig returns the HTTP probe configuration
+func GetProbeConfig() *ProbeConfig {
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+	return probeConfig
+}
+
+// isProbeEnabled reports whether built-in probing is configured and enabled
+func isProbeEnabled() bool {
+	cfg := GetProbeConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// ProbeDomain fetches https (then http) for a domain and records the result
+func ProbeDomain(domain string) *ProbeResult {
+	probeMutex.Lock()
+	cfg := probeConfig
+	slots := probeSlots
+	probeMutex.Unlock()
+
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	for _, scheme := range []string{"https", "http"} {
+		result, err := probeURL(fmt.Sprintf("%s://%s", scheme, domain), timeout)
+		if err != nil {
+			logger.Debug("probe failed", "domain", domain, "scheme", scheme, "error", err)
+			continue
+		}
+		GetDomainTracker().RecordDomainProbe(domain, result)
+		logger.Debug("probe complete", "domain", domain, "status", result.StatusCode, "title", result.Title)
+		return result
+	}
+
+	return nil
+}
+
+// probeURL performs a single GET request following redirects
+func probeURL(url string, timeout time.Duration) (*ProbeResult, error) {
+	result := &ProbeResult{URL: url, ProbedAt: time.Now()}
+
+	client := publicClient(timeout, maxProbeRedirects, func(req *http.Request) {
+		result.RedirectChain = append(result.RedirectChain, req.URL.String())
+	})
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPublicHost(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("crtmon/%s", version))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+
+	result.StatusCode = resp.StatusCode
+	result.Server = resp.Header.Get("Server")
+	result.ContentLength = int(resp.ContentLength)
+	if result.ContentLength < 0 {
+		result.ContentLength = len(body)
+	}
+	if match := titlePattern.FindSubmatch(body); match != nil {
+		result.Title = strings.Join(strings.Fields(html.UnescapeString(string(match[1]))), " ")
+	}
+
+	return result, nil
+}
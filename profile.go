@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TargetProfile overrides the global settings for one target. Unset fields keep the
+// global value.
+type TargetProfile struct {
+	Notify        []string `yaml:"notify"`         // Providers for this target: discord, telegram, ntfy, or none; replaces -notify
+	Webhook       string   `yaml:"webhook"`        // Discord webhook for this target instead of the main one
+	Enumeration   *bool    `yaml:"enumeration"`    // Turn enumeration on or off; tool paths still come from enumeration
+	DirWordlist   string   `yaml:"dir_wordlist"`   // Replaces enumeration.dir_wordlist
+	DNSWordlist   string   `yaml:"dns_wordlist"`   // Replaces enumeration.dns_wordlist
+	CooldownHours int      `yaml:"cooldown_hours"` // Replaces dedup.cooldown_hours
+	MinRisk       int      `yaml:"min_risk"`       // Only notify domains scoring at least this
+	RiskThreshold int      `yaml:"risk_threshold"` // Replaces escalation.risk_threshold
+}
+
+// targetProviders are the notification providers a target sends to
+type targetProviders struct {
+	discord, telegram, ntfy bool
+}
+
+var targetProfiles map[string]*TargetProfile
+var targetProfilesMutex sync.Mutex
+
+// SetTargetProfiles sets the per-target profiles, keyed by normalized target
+func SetTargetProfiles(profiles map[string]TargetProfile) {
+	normalized := make(map[string]*TargetProfile, len(profiles))
+	for target, profile := range profiles {
+		t, err := normalizeTarget(target)
+		if err != nil {
+			logger.Warn("ignoring profile of invalid target", "error", err)
+			continue
+		}
+		p := profile
+		p.Notify = nil
+		for _, provider := range profile.Notify {
+			p.Notify = append(p.Notify, strings.ToLower(strings.TrimSpace(provider)))
+		}
+		p.Webhook = strings.TrimSpace(p.Webhook)
+		normalized[t] = &p
+	}
+
+	targetProfilesMutex.Lock()
+	defer targetProfilesMutex.Unlock()
+	targetProfiles = normalized
+}
+
+// GetTargetProfile returns the profile of a target, or nil
+func GetTargetProfile(target string) *TargetProfile {
+	targetProfilesMutex.Lock()
+	defer targetProfilesMutex.Unlock()
+	return targetProfiles[target]
+}
+
+// profileTargets returns the targets that have a profile
+func profileTargets(profiles map[string]TargetProfile) map[string]bool {
+	set := make(map[string]bool, len(profiles))
+	for target := range profiles {
+		set[target] = true
+	}
+	return set
+}
+
+// validateProfileNotify returns an error for notify values that name no provider
+func validateProfileNotify(notify []string) error {
+	for _, provider := range notify {
+		switch strings.ToLower(strings.TrimSpace(provider)) {
+		case "discord", "telegram", "ntfy", "none":
+		default:
+			return fmt.Errorf("unknown notify provider %q", provider)
+		}
+	}
+	return nil
+}
+
+// providersFor returns the configured providers a target's notifications go to
+func providersFor(target string) targetProviders {
+	p := targetProviders{discord: notifyDiscord, telegram: notifyTelegram, ntfy: notifyNtfy}
+	profile := GetTargetProfile(target)
+	if profile != nil && len(profile.Notify) > 0 {
+		p = parseProviders(profile.Notify)
+	}
+	return p.available(target)
+}
+
+// parseProviders returns the providers named in a notify list
+func parseProviders(notify []string) targetProviders {
+	var p targetProviders
+	for _, provider := range notify {
+		switch provider {
+		case "discord":
+			p.discord = true
+		case "telegram":
+			p.telegram = true
+		case "ntfy":
+			p.ntfy = true
+		}
+	}
+	return p
+}
+
+// available drops the providers that are not configured for a target
+func (p targetProviders) available(target string) targetProviders {
+	p.discord = p.discord && (discordWebhookFor(target) != "" || isDiscordBotEnabled())
+	p.telegram = p.telegram && telegramToken != "" && telegramChatID != ""
+	p.ntfy = p.ntfy && isNtfyConfigured()
+	return p
+}
+
+// any reports whether a target sends to at least one provider
+func (p targetProviders) any() bool {
+	return p.discord || p.telegram || p.ntfy
+}
+
+// notifiesAny reports whether any of the targets sends notifications
+func notifiesAny(targets []string) bool {
+	for _, target := range targets {
+		if providersFor(target).any() {
+			return true
+		}
+	}
+	return false
+}
+
+// discordWebhookFor returns the Discord webhook of a target: its profile's, its
+// program's, or the main one
+func discordWebhookFor(target string) string {
+	if profile := GetTargetProfile(target); profile != nil && profile.Webhook != "" {
+		return profile.Webhook
+	}
+	if program := GetProgram(programOf(target)); program != nil && program.Webhook != "" {
+		return program.Webhook
+	}
+	return webhookURL
+}
+
+// targetEnumConfig returns the enumeration settings for a target with its profile
+// applied, and whether enumeration is enabled for it
+func targetEnumConfig(target string) (EnumConfig, bool) {
+	enumMutex.Lock()
+	if enumConfig == nil {
+		enumMutex.Unlock()
+		return EnumConfig{}, false
+	}
+	cfg := *enumConfig
+	enumMutex.Unlock()
+
+	enabled := cfg.EnableEnum
+	if profile := GetTargetProfile(target); profile != nil {
+		if profile.Enumeration != nil {
+			enabled = *profile.Enumeration
+		}
+		if profile.DirWordlist != "" {
+			cfg.DirWordlist = profile.DirWordlist
+		}
+		if profile.DNSWordlist != "" {
+			cfg.DNSWordlist = profile.DNSWordlist
+		}
+	}
+	return cfg, enabled
+}
+
+// enumEnabledFor reports whether new domains of a target are enumerated
+func enumEnabledFor(target string) bool {
+	_, enabled := targetEnumConfig(target)
+	return enabled
+}
+
+// enumeratesAny reports whether any of the targets enumerates new domains
+func enumeratesAny(targets []string) bool {
+	for _, target := range targets {
+		if enumEnabledFor(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// cooldownHoursFor returns the notification cooldown of a domain matched under targets:
+// the shortest profile cooldown, or the global one
+func cooldownHoursFor(targets []string, global int) int {
+	hours := 0
+	for _, target := range targets {
+		if profile := GetTargetProfile(target); profile != nil && profile.CooldownHours > 0 {
+			if hours == 0 || profile.CooldownHours < hours {
+				hours = profile.CooldownHours
+			}
+		}
+	}
+	if hours == 0 {
+		return global
+	}
+	return hours
+}
+
+// belowMinRisk reports whether a domain's risk score is under a target's min_risk
+func belowMinRisk(target string, riskScore int) bool {
+	profile := GetTargetProfile(target)
+	return profile != nil && riskScore < profile.MinRisk
+}
+
+// escalationThresholdFor returns the risk score that pages on-call for a domain matched
+// under targets: the lowest profile threshold, or the global one
+func escalationThresholdFor(targets []string, global int) int {
+	threshold := global
+	for _, target := range targets {
+		if profile := GetTargetProfile(target); profile != nil && profile.RiskThreshold > 0 && profile.RiskThreshold < threshold {
+			threshold = profile.RiskThreshold
+		}
+	}
+	return threshold
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ProgramConfig groups the targets of one engagement scope, e.g. a bug bounty program
+type ProgramConfig struct {
+	Targets        []string `yaml:"targets"`         // Targets in the program; they must also be monitored
+	Webhook        string   `yaml:"webhook"`         // Discord webhook for the program's new domains
+	SummaryWebhook string   `yaml:"summary_webhook"` // Daily summary of the program's domains
+}
+
+var programConfig map[string]*ProgramConfig
+var programByTarget map[string]string
+var programMutex sync.Mutex
+
+// SetProgramConfig sets the target programs. A target listed in several programs
+// belongs to the first by name.
+func SetProgramConfig(programs map[string]ProgramConfig) {
+	normalized := make(map[string]*ProgramConfig, len(programs))
+	byTarget := make(map[string]string)
+	names := make([]string, 0, len(programs))
+	for name := range programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		program := programs[name]
+		p := &ProgramConfig{
+			Targets:        normalizeTargets(program.Targets),
+			Webhook:        strings.TrimSpace(program.Webhook),
+			SummaryWebhook: strings.TrimSpace(program.SummaryWebhook),
+		}
+		for _, t := range p.Targets {
+			if other, exists := byTarget[t]; exists {
+				logger.Warn("target listed in several programs", "target", t, "program", other, "ignored", name)
+				continue
+			}
+			byTarget[t] = name
+		}
+		normalized[name] = p
+	}
+
+	programMutex.Lock()
+	defer programMutex.Unlock()
+	programConfig = normalized
+	programByTarget = byTarget
+}
+
+// programSet returns the names of the programs in a config
+func programSet(programs map[string]ProgramConfig) map[string]bool {
+	set := make(map[string]bool, len(programs))
+	for name := range programs {
+		set[name] = true
+	}
+	return set
+}
+
+// GetProgram returns a program by name, or nil
+func GetProgram(name string) *ProgramConfig {
+	programMutex.Lock()
+	defer programMutex.Unlock()
+	return programConfig[name]
+}
+
+// programNames returns the configured programs in order
+func programNames() []string {
+	programMutex.Lock()
+	defer programMutex.Unlock()
+	names := make([]string, 0, len(programConfig))
+	for name := range programConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// programOf returns the program a target belongs to, or ""
+func programOf(target string) string {
+	programMutex.Lock()
+	defer programMutex.Unlock()
+	return programByTarget[target]
+}
+
+// programMembers returns the targets of a program as a set
+func programMembers(name string) (map[string]bool, bool) {
+	program := GetProgram(name)
+	if program == nil {
+		return nil, false
+	}
+	members := make(map[string]bool, len(program.Targets))
+	for _, t := range program.Targets {
+		members[t] = true
+	}
+	return members, true
+}
+
+// inProgram reports whether a tracked domain falls under one of the members. A nil
+// set means no program filter.
+func inProgram(entry *DomainEntry, members map[string]bool) bool {
+	if members == nil {
+		return true
+	}
+	for _, t := range matchingTargets(entry) {
+		if members[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// requestProgram returns the targets of a request's ?program=, or nil when it has none.
+// It answers 404 and returns false for an unknown program.
+func requestProgram(w http.ResponseWriter, r *http.Request) (map[string]bool, bool) {
+	name := r.URL.Query().Get("program")
+	if name == "" {
+		return nil, true
+	}
+	members, ok := programMembers(name)
+	if !ok {
+		http.Error(w, "unknown program", http.StatusNotFound)
+		return nil, false
+	}
+	return members, true
+}
+
+// handlePrograms lists the programs with their targets and tracked domain counts
+func (as *AdminServer) handlePrograms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type programInfo struct {
+		Name           string   `json:"name"`
+		Targets        []string `json:"targets"`
+		Domains        int      `json:"domains"`
+		Webhook        bool     `json:"webhook"`
+		SummaryWebhook bool     `json:"summary_webhook"`
+	}
+
+	allDomains := GetDomainTracker().GetAllDomains()
+	programs := []programInfo{}
+	for _, name := range programNames() {
+		program := GetProgram(name)
+		members, _ := programMembers(name)
+		count := 0
+		for _, entry := range allDomains {
+			if inProgram(entry, members) {
+				count++
+			}
+		}
+		programs = append(programs, programInfo{
+			Name:           name,
+			Targets:        program.Targets,
+			Domains:        count,
+			Webhook:        program.Webhook != "",
+			SummaryWebhook: program.SummaryWebhook != "",
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"programs": programs,
+	})
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// errPurgeToken is returned when a purge is confirmed with a stale or wrong token
+var errPurgeToken = errors.New("confirmation token does not match, request a new preview")
+
+// PurgePlan is everything stored for a target. The token confirms a purge of
+// exactly this data and changes whenever the data does.
+type PurgePlan struct {
+	Target        string   `json:"target"`
+	Configured    bool     `json:"configured"` // Still in the targets list
+	Domains       int      `json:"domains"`
+	ScanFiles     []string `json:"scan_files"`
+	Screenshots   []string `json:"screenshots"`
+	Evidence      []string `json:"evidence"`      // Stored certificate PEMs
+	Notifications int      `json:"notifications"` // Pending and overflowed
+	Findings      int      `json:"findings"`      // Parsed scan results
+	SNIResults    bool     `json:"sni_results"`   // Some domains came from the SNI dataset
+	Events        int      `json:"events"`        // Event log lines, replaced by tombstones
+	Token         string   `json:"token"`
+}
+
+// PlanPurge previews what purging a target would delete
+func PlanPurge(target string) PurgePlan {
+	plan := PurgePlan{Target: target}
+
+	for _, t := range targets {
+		if t == target {
+			plan.Configured = true
+		}
+	}
+
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		if !entryMatchesTarget(entry, target) {
+			continue
+		}
+		plan.Domains++
+		if !entry.SNIFirstSeen.IsZero() {
+			plan.SNIResults = true
+		}
+		if entry.Screenshot != "" && artifactExists(entry.Screenshot) {
+			plan.Screenshots = append(plan.Screenshots, entry.Screenshot)
+		}
+		for _, pem := range []string{entry.EvidenceFirst, entry.EvidenceLatest} {
+			if pem != "" {
+				plan.Evidence = append(plan.Evidence, pem)
+			}
+		}
+	}
+
+	plan.ScanFiles = targetScanFiles(target)
+	plan.Findings = GetFindingsStore().CountTarget(target)
+
+	notifier.mu.Lock()
+	plan.Notifications = len(notifier.pending[target])
+	notifier.mu.Unlock()
+	if path, err := notificationOverflowPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			plan.Notifications += strings.Count(string(data), `"target":"`+target+`"`)
+		}
+	}
+
+	plan.Events = countTargetEvents(target)
+
+	plan.Token = purgeToken(plan)
+	return plan
+}
+
+// purgeToken derives a short confirmation token from the plan contents
+func purgeToken(plan PurgePlan) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%t|%d|%v|%v|%v|%d|%t|%d|%d",
+		plan.Target, plan.Configured, plan.Domains, plan.ScanFiles, plan.Screenshots, plan.Evidence, plan.Notifications, plan.SNIResults, plan.Findings, plan.Events)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// PurgeTarget deletes all data for a target after checking the confirmation token,
+// then records what was removed in the audit log. Event log lines are replaced by
+// tombstones and the log is chained again, see purgeTargetEvents.
+func PurgeTarget(target, token, actor string) (PurgePlan, error) {
+	plan := PlanPurge(target)
+	if token != plan.Token {
+		return plan, errPurgeToken
+	}
+
+	var errs []error
+	if plan.Configured {
+		removeConfiguredTarget(target)
+	}
+
+	removed, err := GetDomainTracker().RemoveTargetDomains(target)
+	errs = append(errs, err)
+	domains := make(map[string]bool, len(removed))
+	for _, entry := range removed {
+		domains[entry.Domain] = true
+		ClearResolveCacheEntry(entry.Domain)
+	}
+	errs = append(errs, SaveResolveCache())
+	dropAssetTransitions(domains)
+	forgetLoggedAt(domains)
+
+	files := append(append(plan.ScanFiles, plan.Screenshots...), plan.Evidence...)
+	for _, file := range files {
+		if err := removeArtifact(file); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	// Per-domain evidence directories are empty once their PEMs are gone
+	for _, file := range plan.Evidence {
+		os.Remove(filepath.Dir(file))
+	}
+
+	_, err = GetFindingsStore().RemoveTarget(target)
+	errs = append(errs, err)
+
+	dropped, err := notifier.DropTarget(target)
+	errs = append(errs, err)
+	retries, err := GetRetryQueue().DropTarget(target)
+	dropped += retries
+	errs = append(errs, err)
+
+	events, err := purgeTargetEvents(target)
+	errs = append(errs, err)
+	_, err = RemoveIssuanceTarget(target)
+	errs = append(errs, err)
+	if GetStatsTracker().RemoveTarget(target) {
+		errs = append(errs, GetStatsTracker().Save())
+	}
+	noise, err := GetNoiseFeedback().RemoveTarget(target, domains)
+	errs = append(errs, err)
+
+	errs = append(errs, recordAudit(AuditEntry{
+		Action: "purge_target",
+		Target: target,
+		Actor:  actor,
+		Details: map[string]int{
+			"domains":       len(removed),
+			"scan_files":    len(plan.ScanFiles),
+			"screenshots":   len(plan.Screenshots),
+			"evidence":      len(plan.Evidence),
+			"notifications": dropped,
+			"findings":      plan.Findings,
+			"events":        events,
+			"noise_marks":   noise,
+		},
+	}))
+
+	logger.Warn("purged target data", "target", target, "actor", actor, "domains", len(removed), "scan_files", len(plan.ScanFiles), "screenshots", len(plan.Screenshots))
+	return plan, errors.Join(errs...)
+}
+
+// removeConfiguredTarget drops a target from the monitored list and the config file
+func removeConfiguredTarget(target string) {
+	for i, t := range targets {
+		if t == target {
+			targets = append(targets[:i], targets[i+1:]...)
+			break
+		}
+	}
+	if cfg := getConfig(); cfg != nil {
+		cfg.Targets = targets
+		if err := SaveConfig(); err != nil {
+			logger.Error("failed to save config after purging target", "error", err)
+		}
+	}
+}
+
+// targetScanFiles returns enumeration output files for domains under a target, on
+// local disk and in object storage
+func targetScanFiles(target string) []string {
+	return scanFiles(target, true)
+}
+
+// domainScanFiles returns enumeration output files for a single domain
+func domainScanFiles(domain string) []string {
+	return scanFiles(domain, false)
+}
+
+// scanFiles returns output files named after a domain, and with subdomains those of
+// domains under it. Output names replace dots with underscores, see RunFeroxbuster.
+func scanFiles(domain string, subdomains bool) []string {
+	base := strings.ReplaceAll(strings.TrimPrefix(domain, "*."), ".", "_")
+
+	stored, err := listArtifacts(artifactScans)
+	if err != nil {
+		logger.Warn("failed to list scan files in object storage", "error", err)
+	}
+
+	var files []string
+	for _, pattern := range scanOutputPatterns() {
+		matches, _ := filepath.Glob(pattern)
+		suffix := strings.TrimPrefix(pattern, "*")
+		for _, file := range append(matches, stored...) {
+			if !strings.HasSuffix(file, suffix) {
+				continue
+			}
+			name := strings.TrimSuffix(path.Base(file), suffix)
+			if name == base || (subdomains && strings.HasSuffix(name, "_"+base)) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// runPurge implements crtmon purge <target>: without -confirm it prints what would
+// be deleted and the token to confirm with
+func runPurge(args []string) int {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	purgeConfig := fs.String("config", "", "path to configuration file")
+	confirm := fs.String("confirm", "", "confirmation token from the preview")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: crtmon purge [-config path] [-confirm token] <target>")
+		return 1
+	}
+
+	cfg, err := loadCommandConfig(*purgeConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	globalConfig = cfg
+
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// A running instance would write its in-memory copy of the data back
+	if pid := runningInstance(configDir); pid != 0 {
+		fmt.Fprintf(os.Stderr, "crtmon is running (pid %d), stop it or purge from the admin panel API\n", pid)
+		return 1
+	}
+	if err := InitDomainTracker(configDir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Loaded so saving them after the purge keeps the other targets
+	if err := GetStatsTracker().Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	target, err := normalizeTarget(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *confirm == "" {
+		plan := PlanPurge(target)
+		fmt.Printf("purging %s would delete:\n", target)
+		fmt.Printf("  configured target:      %t\n", plan.Configured)
+		fmt.Printf("  tracked domains:        %d\n", plan.Domains)
+		fmt.Printf("  scan output files:      %d\n", len(plan.ScanFiles))
+		fmt.Printf("  screenshots:            %d\n", len(plan.Screenshots))
+		fmt.Printf("  certificate evidence:   %d\n", len(plan.Evidence))
+		fmt.Printf("  queued notifications:   %d\n", plan.Notifications)
+		fmt.Printf("  scan findings:          %d\n", plan.Findings)
+		fmt.Printf("  sni results:            %t\n", plan.SNIResults)
+		fmt.Printf("  event log lines:        %d\n", plan.Events)
+		fmt.Println("and its issuance counts, discovery stats and noise marks")
+		fmt.Printf("to confirm, run: crtmon purge -confirm %s %s\n", plan.Token, target)
+		return 0
+	}
+
+	plan, err := PurgeTarget(target, *confirm, "cli")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("purged %s: %d domains, %d scan files, %d screenshots, %d event log lines\n", target, plan.Domains, len(plan.ScanFiles), len(plan.Screenshots), plan.Events)
+	return 0
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in log output and error messages
+const redacted = "[REDACTED]"
+
+// minSecretLength skips short configured values that would match unrelated text
+const minSecretLength = 6
+
+// secretPatterns match credentials by shape, for values that were never configured
+// (e.g. a webhook pasted into the admin panel before a restart)
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(discord(?:app)?\.com/api/webhooks/\d+/)[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`(/bot)\d+:[A-Za-z0-9_-]+`),
+	regexp.MustCompile(`(?i)((?:token|key|password|secret|apikey|access_token)=)[^&\s"]+`),
+}
+
+var secretValues []string
+var secretMutex sync.RWMutex
+
+// registerSecrets records configured credentials so they are redacted verbatim
+func registerSecrets(cfg *Config) {
+	if cfg == nil {
+		return
+	}
+	addSecrets(
+		cfg.Webhook,
+		cfg.TelegramBotToken,
+		cfg.TelegramChatID,
+		cfg.Ntfy.TopicURL,
+		cfg.Ntfy.Token,
+		cfg.Ntfy.Password,
+		cfg.MessageBus.URL,
+		cfg.Kafka.SASL.Password,
+		cfg.MQTT.URL,
+		cfg.MQTT.Password,
+		cfg.DiscordBot.Token,
+		cfg.GitHubToken,
+		cfg.GitLabToken,
+		cfg.Webhooks.NewDomains,
+		cfg.Webhooks.SubdomainScans,
+		cfg.Webhooks.DirectoryScans,
+		cfg.Webhooks.DailySummary,
+		cfg.Webhooks.NucleiFindings,
+		cfg.Escalation.PagerDutyRoutingKey,
+		cfg.Escalation.OpsgenieAPIKey,
+		cfg.Storage.AccessKey,
+		cfg.Storage.SecretKey,
+		cfg.HTTP.Proxy,
+		cfg.Shodan.APIKey,
+		cfg.Censys.APISecret,
+		cfg.Reputation.VirusTotalAPIKey,
+		cfg.Reputation.URLScanAPIKey,
+		cfg.Assets.Webhook,
+		cfg.ExpiryAlerts.Webhook,
+		cfg.Takeover.Webhook,
+		cfg.IssuerPolicy.Webhook,
+		cfg.CAA.Webhook,
+		cfg.CodeSearch.Webhook,
+	)
+	for _, report := range cfg.ScheduledReports {
+		addSecrets(report.Webhook)
+	}
+	for _, program := range cfg.Programs {
+		addSecrets(program.Webhook, program.SummaryWebhook)
+	}
+	for _, profile := range cfg.TargetProfiles {
+		addSecrets(profile.Webhook)
+	}
+	for _, webhook := range cfg.Severity.Webhooks {
+		addSecrets(webhook)
+	}
+	for _, tool := range cfg.Tools {
+		addSecrets(tool.Webhook)
+	}
+	for _, source := range cfg.SNI.Sources {
+		addSecrets(source.Password)
+		for _, value := range source.Headers {
+			addSecrets(value)
+		}
+	}
+}
+
+// addSecrets registers values to redact, longest first so a URL is replaced before its token
+func addSecrets(values ...string) {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength {
+			continue
+		}
+		known := false
+		for _, s := range secretValues {
+			if s == v {
+				known = true
+				break
+			}
+		}
+		if !known {
+			secretValues = append(secretValues, v)
+		}
+	}
+	sort.Slice(secretValues, func(i, j int) bool {
+		return len(secretValues[i]) > len(secretValues[j])
+	})
+}
+
+// redactSecrets replaces configured credentials and credential-shaped text in s
+func redactSecrets(s string) string {
+	secretMutex.RLock()
+	for _, secret := range secretValues {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secretMutex.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+// redactingWriter redacts every log line before it reaches the underlying writer
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, redactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// maskValue shows only that a secret is set, plus its last 4 characters when long
+// enough that they reveal nothing useful
+func maskValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) < 16 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
+
+// unmaskValue keeps the current secret when a form submits its masked form unchanged
+func unmaskValue(submitted, current string) string {
+	if submitted != "" && submitted == maskValue(current) {
+		return current
+	}
+	return submitted
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// configPollInterval is how often provider.yaml is checked for changes when it can't
+// be watched
+const configPollInterval = 5 * time.Second
+
+// configSettleDelay is how long the config file must go without changes before it
+// is read
+const configSettleDelay = 500 * time.Millisecond
+
+// reloadSections re-initializes the subsystem behind each top-level config key.
+// Keys missing here and from restartSections are only read when used.
+var reloadSections = map[string]func(cfg *Config){
+	"webhook": func(cfg *Config) {
+		if cfg.Webhook == `""` {
+			cfg.Webhook = ""
+		}
+		webhookURL = strings.TrimSpace(cfg.Webhook)
+	},
+	"telegram_bot_token": func(cfg *Config) { telegramToken = strings.TrimSpace(cfg.TelegramBotToken) },
+	"telegram_chat_id":   func(cfg *Config) { telegramChatID = strings.TrimSpace(cfg.TelegramChatID) },
+	"ntfy":               func(cfg *Config) { SetNtfyConfig(&cfg.Ntfy) },
+	"message_bus":        func(cfg *Config) { SetBusConfig(&cfg.MessageBus) },
+	"kafka":              func(cfg *Config) { SetKafkaConfig(&cfg.Kafka) },
+	"mqtt":               func(cfg *Config) { SetMQTTConfig(&cfg.MQTT) },
+	"discord_bot":        func(cfg *Config) { SetDiscordBotConfig(&cfg.DiscordBot) },
+	"logging":            func(cfg *Config) { applyLoggingConfig(&cfg.Logging) },
+	"http":               func(cfg *Config) { SetHTTPConfig(&cfg.HTTP) },
+	"targets":            reloadTargets,
+	"target_profiles":    func(cfg *Config) { SetTargetProfiles(cfg.TargetProfiles) },
+	"programs":           func(cfg *Config) { SetProgramConfig(cfg.Programs) },
+	"exclusions":         func(cfg *Config) { SetExclusionConfig(&cfg.Exclusions) },
+	"dedup":              func(cfg *Config) { SetDedupConfig(&cfg.Dedup) },
+	"dns":                func(cfg *Config) { SetResolveConfig(&cfg.DNS) },
+	"sni":                func(cfg *Config) { SetSNIConfig(&cfg.SNI) },
+	"enumeration":        func(cfg *Config) { SetEnumConfig(&cfg.Enumeration) },
+	"tools":              func(cfg *Config) { SetToolsConfig(cfg.Tools) },
+	"webhooks":           func(cfg *Config) { SetWebhookConfig(&cfg.Webhooks) },
+	"expiry_alerts":      func(cfg *Config) { SetExpiryConfig(&cfg.ExpiryAlerts) },
+	"takeover":           func(cfg *Config) { SetTakeoverConfig(&cfg.Takeover) },
+	"enrichment":         func(cfg *Config) { SetEnrichConfig(&cfg.Enrichment) },
+	"geoip":              func(cfg *Config) { SetGeoIPConfig(&cfg.GeoIP) },
+	"shodan":             func(cfg *Config) { SetShodanConfig(&cfg.Shodan) },
+	"censys":             func(cfg *Config) { SetCensysConfig(&cfg.Censys) },
+	"reputation":         func(cfg *Config) { SetReputationConfig(&cfg.Reputation) },
+	"code_search":        reloadCodeSearch,
+	"port_scan":          func(cfg *Config) { SetPortScanConfig(&cfg.PortScan) },
+	"rescan":             func(cfg *Config) { SetRescanConfig(&cfg.Rescan) },
+	"assets":             func(cfg *Config) { SetAssetConfig(&cfg.Assets) },
+	"netblocks":          func(cfg *Config) { SetNetblockConfig(&cfg.Netblocks) },
+	"github_token":       reloadCodeSearch,
+	"gitlab_token":       reloadCodeSearch,
+	"risk":               func(cfg *Config) { SetRiskConfig(&cfg.Risk) },
+	"severity":           func(cfg *Config) { SetSeverityConfig(&cfg.Severity) },
+	"notify_retry":       func(cfg *Config) { SetRetryConfig(&cfg.NotifyRetry) },
+	"issuer_policy":      func(cfg *Config) { SetIssuerPolicyConfig(&cfg.IssuerPolicy) },
+	"caa":                func(cfg *Config) { SetCAAConfig(&cfg.CAA) },
+	"catch_up":           func(cfg *Config) { SetCatchUpConfig(&cfg.CatchUp) },
+	"freshness":          func(cfg *Config) { SetFreshnessConfig(&cfg.Freshness) },
+	"http_probe":         func(cfg *Config) { SetProbeConfig(&cfg.HTTPProbe) },
+	"multi_vantage":      func(cfg *Config) { SetVantageConfig(&cfg.MultiVantage) },
+	"screenshots":        func(cfg *Config) { SetScreenshotConfig(&cfg.Screenshots) },
+	"cleanup":            func(cfg *Config) { SetCleanupConfig(&cfg.Cleanup) },
+	"org_expansion":      func(cfg *Config) { SetOrgExpansionConfig(&cfg.OrgExpansion) },
+	"escalation":         func(cfg *Config) { SetEscalationConfig(&cfg.Escalation) },
+	"issuance_report":    func(cfg *Config) { SetIssuanceConfig(&cfg.IssuanceReport) },
+	"cert_evidence":      func(cfg *Config) { SetEvidenceConfig(&cfg.CertEvidence) },
+	"permutations":       func(cfg *Config) { SetPermutationConfig(&cfg.Permutations) },
+	"storage":            func(cfg *Config) { SetStorageConfig(&cfg.Storage) },
+	"graph":              func(cfg *Config) { SetGraphConfig(&cfg.Graph) },
+	"reports":            func(cfg *Config) { SetReportConfig(&cfg.Reports) },
+	"summary_schedule":   reloadSummarySchedules,
+	"summary_timezone":   reloadSummarySchedules,
+	"scheduled_reports":  reloadSummarySchedules,
+}
+
+// restartSections are only read at startup
+var restartSections = map[string]bool{
+	"admin_panel":  true,
+	"grpc":         true,
+	"low_resource": true,
+	"event_log":    true,
+}
+
+// targetsFromConfig is set when the monitored targets come from provider.yaml rather
+// than -target or stdin, so reloads may replace them
+var targetsFromConfig bool
+
+// savedConfigHash is the SHA-256 of what crtmon itself last wrote to provider.yaml.
+// Only that content is skipped, so an edit made around the same time still reloads.
+var savedConfigHash atomic.Value // string
+
+var reloadMutex sync.Mutex
+
+// configHash returns the SHA-256 of config file content
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cloneConfig returns a deep copy of a config, made through YAML like the comparisons
+func cloneConfig(cfg *Config) (*Config, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var out Config
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartConfigReloader reloads provider.yaml on SIGHUP and whenever the file changes.
+// loaded is the config as read at startup, before defaults were filled in, which
+// changes are compared against.
+func StartConfigReloader(loaded *Config) {
+	path, err := getConfigPath()
+	if err != nil {
+		return
+	}
+	last := loaded
+	var seen string
+	if data, err := os.ReadFile(path); err == nil {
+		seen = configHash(data)
+	}
+
+	changed := watchConfigFile(path)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				logger.Info("reloading configuration", "reason", "SIGHUP")
+			case <-changed:
+				data, err := os.ReadFile(path)
+				if err != nil || configHash(data) == seen {
+					continue
+				}
+				seen = configHash(data)
+				if saved, _ := savedConfigHash.Load().(string); saved == seen {
+					// Written from the running config, nothing to apply. The file holds
+					// the file values of overridden keys, the comparisons need the others.
+					var cfg Config
+					if err := yaml.Unmarshal(data, &cfg); err == nil {
+						restoreOverrides(&cfg, last)
+						last = &cfg
+					}
+					continue
+				}
+				logger.Info("reloading configuration", "reason", "file changed")
+			}
+			if data, err := os.ReadFile(path); err == nil {
+				seen = configHash(data)
+			}
+			if cfg := reloadConfig(last); cfg != nil {
+				last = cfg
+			}
+		}
+	}()
+}
+
+// watchConfigFile signals when provider.yaml may have changed. Its directory is
+// watched, since editors often save by renaming a new file over the old one. A
+// symlinked file, or one that can't be watched, is polled instead.
+func watchConfigFile(path string) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if info, lerr := os.Lstat(path); lerr == nil && info.Mode()&os.ModeSymlink != 0 {
+			err = fmt.Errorf("%s is a symlink", path)
+		} else {
+			err = watcher.Add(filepath.Dir(path))
+		}
+		if err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		logger.Debug("polling configuration for changes", "path", path, "interval", configPollInterval, "reason", err)
+		go func() {
+			ticker := time.NewTicker(configPollInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				notify()
+			}
+		}()
+		return changed
+	}
+
+	logger.Debug("watching configuration for changes", "path", path)
+	go func() {
+		defer watcher.Close()
+		var settle *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) || event.Op == fsnotify.Chmod {
+					continue
+				}
+				// Editors write in several steps, read the file once they're done
+				if settle == nil {
+					settle = time.AfterFunc(configSettleDelay, notify)
+				} else {
+					settle.Reset(configSettleDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("configuration watcher failed", "error", err)
+			}
+		}
+	}()
+	return changed
+}
+
+// reloadConfig reads provider.yaml, logs how it differs from the last loaded config and
+// re-initializes the changed sections. It returns the freshly loaded config, or nil
+// when the file couldn't be read and the running config was kept.
+func reloadConfig(last *Config) *Config {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("failed to reload configuration, keeping the running one", "error", err)
+		return nil
+	}
+	if cfg == nil {
+		logger.Warn("configuration file removed, keeping the running configuration")
+		return nil
+	}
+	registerSecrets(cfg)
+	// Applying sections fills in defaults, keep an untouched copy
+	fresh, err := cloneConfig(cfg)
+	if err != nil {
+		fresh = last
+	}
+
+	changes := diffConfig(last, cfg)
+	if len(changes) == 0 {
+		logger.Debug("configuration unchanged")
+		return fresh
+	}
+
+	sections := make(map[string]bool)
+	for _, c := range changes {
+		// Only the key, values can be tokens, passwords or webhook URLs
+		logger.Info("configuration changed", "key", c.key)
+		section, _, _ := strings.Cut(c.key, ".")
+		sections[section] = true
+	}
+
+	running := getConfig()
+	if running == nil {
+		return fresh
+	}
+	var applied, restart []string
+	for _, section := range sortedKeys(sections) {
+		copyConfigSection(running, cfg, section)
+		if restartSections[section] {
+			restart = append(restart, section)
+			continue
+		}
+		if apply := reloadSections[section]; apply != nil {
+			apply(running)
+		}
+		applied = append(applied, section)
+	}
+	if len(applied) > 0 {
+		logger.Info("configuration reloaded", "sections", applied)
+	}
+	if len(restart) > 0 {
+		logger.Warn("configuration changes take effect after a restart", "sections", restart)
+	}
+	return fresh
+}
+
+// reloadCodeSearch applies code search settings and the tokens it searches with
+func reloadCodeSearch(cfg *Config) {
+	SetCodeSearchConfig(&cfg.CodeSearch, cfg.GitHubToken, cfg.GitLabToken)
+}
+
+// reloadSummarySchedules restarts the summary scheduler with the new schedules
+func reloadSummarySchedules(cfg *Config) {
+	SetSummarySchedules(cfg.SummarySchedule, cfg.SummaryTimezone, cfg.ScheduledReports)
+}
+
+// reloadTargets replaces the monitored targets with those in the config, unless they
+// were given with -target or stdin
+func reloadTargets(cfg *Config) {
+	if !targetsFromConfig {
+		logger.Warn("targets come from the command line, not reloading them")
+		return
+	}
+	updated := normalizeTargets(cfg.Targets)
+	if len(updated) == 0 {
+		logger.Error("reloaded configuration has no valid targets, keeping the current ones")
+		cfg.Targets = targets
+		return
+	}
+
+	current := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		current[t] = true
+	}
+	var added []string
+	for _, t := range updated {
+		if !current[t] {
+			added = append(added, t)
+		}
+		delete(current, t)
+	}
+	removed := sortedKeys(current)
+
+	targets = updated
+	cfg.Targets = updated
+	logger.Info("targets reloaded", "count", len(targets), "added", added, "removed", removed)
+
+	if sm := GetSNIManager(); sm != nil {
+		for _, t := range added {
+			go sm.SearchSNIOnDemand(t)
+		}
+	}
+}
+
+// configChange is a config value that differs between two loads
+type configChange struct {
+	key      string // Dotted yaml path, e.g. enumeration.rate_limit
+	old, new string
+}
+
+// diffConfig returns the values that differ between two configs, sorted by key
+func diffConfig(a, b *Config) []configChange {
+	before, after := flattenConfig(a), flattenConfig(b)
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	var changes []configChange
+	for _, k := range sortedKeys(keys) {
+		if before[k] != after[k] {
+			changes = append(changes, configChange{k, before[k], after[k]})
+		}
+	}
+	return changes
+}
+
+// flattenConfig returns every value of a config keyed by its dotted yaml path
+func flattenConfig(cfg *Config) map[string]string {
+	flat := make(map[string]string)
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return flat
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return flat
+	}
+	flattenValue(flat, "", tree)
+	return flat
+}
+
+// flattenValue adds the leaves of a value to flat, descending into maps. Empty maps
+// add nothing, so they compare equal to absent ones.
+func flattenValue(flat map[string]string, path string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			key := k
+			if path != "" {
+				key = path + "." + k
+			}
+			flattenValue(flat, key, child)
+		}
+		return
+	case nil:
+		flat[path] = ""
+		return
+	}
+	flat[path] = fmt.Sprint(v)
+}
+
+// copyConfigSection sets the field of dst with the given yaml key to its value in src
+func copyConfigSection(dst, src *Config, key string) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		name, _, _ := strings.Cut(dv.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == key {
+			dv.Field(i).Set(sv.Field(i))
+			return
+		}
+	}
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// replayRecord is one line of a replay file: a certificate entry, or a discovery event
+// copied from the event log
+type replayRecord struct {
+	Domains      []string     `json:"domains"`
+	Domain       string       `json:"domain"` // Event log lines carry one domain each
+	Issuer       string       `json:"issuer"`
+	IssuerOrg    string       `json:"issuer_org"`
+	SerialNumber string       `json:"serial_number"`
+	NotBefore    time.Time    `json:"not_before"`
+	NotAfter     time.Time    `json:"not_after"`
+	SANs         []string     `json:"sans"`
+	SubjectOrg   string       `json:"subject_org"`
+	LogURL       string       `json:"log_url"`
+	LoggedAt     time.Time    `json:"logged_at"`
+	Certificate  *CertDetails `json:"certificate"` // Event log lines
+	Purged       bool         `json:"purged"`      // Tombstone of a purged target's event
+}
+
+// certEntry returns the certificate entry a replay line describes
+func (r replayRecord) certEntry() CertEntry {
+	entry := CertEntry{
+		Domains:      r.Domains,
+		NotBefore:    r.NotBefore,
+		NotAfter:     r.NotAfter,
+		Issuer:       r.Issuer,
+		IssuerOrg:    r.IssuerOrg,
+		LogURL:       r.LogURL,
+		SerialNumber: r.SerialNumber,
+		SANs:         r.SANs,
+		SubjectOrg:   r.SubjectOrg,
+		LoggedAt:     r.LoggedAt,
+	}
+	if r.Domain != "" {
+		entry.Domains = append(entry.Domains, r.Domain)
+	}
+	if c := r.Certificate; c != nil {
+		entry.Issuer = c.Issuer
+		entry.SerialNumber = c.SerialNumber
+		entry.NotBefore = c.NotBefore
+		entry.NotAfter = c.NotAfter
+		entry.SANs = c.SANs
+		entry.FingerprintSHA256 = c.FingerprintSHA256
+		entry.KeyAlgorithm = c.KeyAlgorithm
+		entry.IsPrecertificate = c.IsPrecertificate
+		entry.LogURL = c.LogURL
+	}
+	if len(entry.SANs) == 0 {
+		entry.SANs = entry.Domains
+	}
+	if entry.LogURL == "" {
+		entry.LogURL = "replay://"
+	}
+	return entry
+}
+
+// runReplay implements crtmon replay <file.jsonl>, running recorded certificate entries
+// through the pipeline in dry-run mode and printing what would be notified
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	replayConfig := fs.String("config", "", "path to configuration file")
+	replayTarget := fs.String("target", "", "match against this target or targets file instead of the configured targets")
+	fresh := fs.Bool("fresh", false, "start from an empty tracker, so domains already seen aren't duplicates")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: crtmon replay [-config path] [-target domain|file] [-fresh] <file.jsonl>")
+		return 1
+	}
+
+	*dryRunMode = true
+	cfg, err := loadCommandConfig(*replayConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if cfg != nil {
+		applyConfig(cfg)
+	}
+	if *replayTarget != "" {
+		resolved, err := resolveTargetFlag(*replayTarget)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		targets = normalizeTargets(resolved)
+	}
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "no targets configured, use -config or -target")
+		return 1
+	}
+
+	if *fresh {
+		tracker = &DomainTracker{domains: make(map[string]*DomainEntry)}
+	} else {
+		configDir, _ := getConfigDir()
+		if err := InitDomainTracker(configDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	entries, matched, notified, invalid := 0, 0, 0, 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var record replayRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+			invalid++
+			continue
+		}
+		if record.Purged {
+			continue
+		}
+		entry := record.certEntry()
+		if len(entry.Domains) == 0 {
+			fmt.Fprintf(os.Stderr, "line %d: no domains\n", line)
+			invalid++
+			continue
+		}
+
+		entries++
+		for _, d := range processEntry(entry) {
+			if !d.Matched {
+				continue
+			}
+			matched++
+			if d.Notify {
+				notified++
+			}
+			fmt.Println(describeReplayDecision(d))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("%d entries, %d matched domains, %d would notify\n", entries, matched, notified)
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "%d invalid lines\n", invalid)
+		return 1
+	}
+	return 0
+}
+
+// describeReplayDecision returns one line describing what happened to a matched domain
+func describeReplayDecision(d EntryDecision) string {
+	outcome := "unresolved"
+	switch {
+	case d.Excluded:
+		return fmt.Sprintf("%-50s excluded", d.Domain)
+	case d.Duplicate:
+		outcome = "duplicate"
+	case d.Notify:
+		outcome = "notify"
+	}
+	line := fmt.Sprintf("%-50s %-10s targets=%s", d.Domain, outcome, strings.Join(d.Targets, ","))
+	if info := GetDomainTracker().GetDomainInfo(d.Domain); info != nil {
+		line += fmt.Sprintf(" risk=%d", info.RiskScore)
+		if len(info.RiskLabels) > 0 {
+			line += " labels=" + strings.Join(info.RiskLabels, ",")
+		}
+	}
+	return line
+}
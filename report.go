@@ -0,0 +1,404 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportConfig controls writing the daily summary as a file, which unlike the
+// Discord embed has room for every domain
+type ReportConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	OutputDir string `yaml:"output_dir"` // Defaults to <config dir>/reports
+	Format    string `yaml:"format"`     // html, markdown or both, default html
+	KeepDays  int    `yaml:"keep_days"`  // Older reports are deleted, default 90
+}
+
+// dailyReport is the data rendered into a report file
+type dailyReport struct {
+	Title       string
+	Generated   time.Time
+	Domains     []*DomainEntry // Discovered today, highest risk first
+	Blacklisted []string
+	Issuance    []IssuanceRow
+	Cleanup     *CleanupReport
+	PerTarget   []reportBar
+	RiskBands   []reportBar
+}
+
+// reportBar is one bar of a report chart
+type reportBar struct {
+	Label string
+	Count int
+	Width int // Percent of the largest bar
+}
+
+var reportConfig *ReportConfig
+var reportMutex sync.Mutex
+
+// reportNameUnsafe matches characters kept out of report file names
+var reportNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// SetReportConfig sets the report file configuration
+func SetReportConfig(cfg *ReportConfig) {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	reportConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.OutputDir == "" {
+		if configDir, err := getConfigDir(); err == nil {
+			cfg.OutputDir = filepath.Join(configDir, "reports")
+		}
+	}
+	cfg.Format = strings.ToLower(strings.TrimSpace(cfg.Format))
+	if cfg.Format != "markdown" && cfg.Format != "both" {
+		cfg.Format = "html"
+	}
+	if cfg.KeepDays <= 0 {
+		cfg.KeepDays = 90
+	}
+}
+
+// GetReportConfig returns the report file configuration
+func GetReportConfig() *ReportConfig {
+	reportMutex.Lock()
+	defer reportMutex.Unlock()
+	return reportConfig
+}
+
+// isReportEnabled reports whether daily summaries are written to files
+func isReportEnabled() bool {
+	cfg := GetReportConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// WriteDailyReport writes a daily summary to the reports directory and returns the
+// name of the file to link to
+func WriteDailyReport(summary map[string]interface{}) (string, error) {
+	cfg := GetReportConfig()
+	if cfg == nil || !cfg.Enabled {
+		return "", fmt.Errorf("reports not enabled")
+	}
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create reports directory: %w", err)
+	}
+
+	report := newDailyReport(summary)
+	base := "daily-" + report.Generated.Format("2006-01-02")
+	for _, key := range []string{"name", "program"} {
+		if v, ok := summary[key].(string); ok && v != "" {
+			base += "-" + reportNameUnsafe.ReplaceAllString(v, "_")
+		}
+	}
+
+	var name string
+	if cfg.Format == "markdown" || cfg.Format == "both" {
+		name = base + ".md"
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, name), []byte(renderMarkdownReport(report)), 0644); err != nil {
+			return "", err
+		}
+	}
+	if cfg.Format == "html" || cfg.Format == "both" {
+		var b strings.Builder
+		if err := reportTemplate.Execute(&b, report); err != nil {
+			return "", err
+		}
+		name = base + ".html"
+		if err := os.WriteFile(filepath.Join(cfg.OutputDir, name), []byte(b.String()), 0644); err != nil {
+			return "", err
+		}
+	}
+
+	pruneReports(cfg)
+	return name, nil
+}
+
+// pruneReports deletes reports older than keep_days
+func pruneReports(cfg *ReportConfig) {
+	files, err := os.ReadDir(cfg.OutputDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -cfg.KeepDays)
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || f.IsDir() || !isReportFile(f.Name()) || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(cfg.OutputDir, f.Name())); err != nil {
+			logger.Warn("failed to remove old report", "file", f.Name(), "error", err)
+		}
+	}
+}
+
+// handleReports lists the report files, or serves the one named by ?name=
+func (as *AdminServer) handleReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := GetReportConfig()
+	if cfg == nil || !cfg.Enabled {
+		http.Error(w, "reports not enabled", http.StatusNotFound)
+		return
+	}
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		if name != filepath.Base(name) || !isReportFile(name) {
+			http.Error(w, "invalid report name", http.StatusBadRequest)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.OutputDir, name))
+		if err != nil {
+			http.Error(w, "report not found", http.StatusNotFound)
+			return
+		}
+		contentType := "text/html; charset=utf-8"
+		if strings.HasSuffix(name, ".md") {
+			contentType = "text/markdown; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+		return
+	}
+
+	type reportFile struct {
+		Name     string    `json:"name"`
+		Size     int64     `json:"size"`
+		Modified time.Time `json:"modified"`
+	}
+	reports := []reportFile{}
+	files, _ := os.ReadDir(cfg.OutputDir)
+	for _, f := range files {
+		info, err := f.Info()
+		if err != nil || f.IsDir() || !isReportFile(f.Name()) {
+			continue
+		}
+		reports = append(reports, reportFile{Name: f.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Modified.After(reports[j].Modified)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports": reports,
+	})
+}
+
+// isReportFile reports whether a file name is one crtmon writes to the reports directory
+func isReportFile(name string) bool {
+	return strings.HasPrefix(name, "daily-") && (strings.HasSuffix(name, ".html") || strings.HasSuffix(name, ".md"))
+}
+
+// dashboardReportLink returns the admin panel link that opens a report file, or "" without
+// a public URL. The report API needs the token, which the dashboard adds.
+func dashboardReportLink(name string) string {
+	cfg := GetAdminConfig()
+	if cfg == nil || !cfg.Enabled || strings.TrimSpace(cfg.PublicURL) == "" {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimSpace(cfg.PublicURL), "/") + "/#report=" + url.QueryEscape(name)
+}
+
+// newDailyReport collects the report data from a daily summary
+func newDailyReport(summary map[string]interface{}) dailyReport {
+	report := dailyReport{Title: summaryTitle(summary), Generated: time.Now()}
+	if program, ok := summary["program"].(string); ok && program != "" {
+		report.Title += ": " + program
+	}
+	report.Domains, _ = summary["domains"].([]*DomainEntry)
+	report.Blacklisted, _ = summary["blacklisted_domains"].([]string)
+	report.Issuance, _ = summary["issuance"].([]IssuanceRow)
+	if cleanup, ok := summary["cleanup"].(CleanupReport); ok && !cleanup.LastRun.IsZero() {
+		report.Cleanup = &cleanup
+	}
+
+	report.Domains = append([]*DomainEntry(nil), report.Domains...)
+	sort.Slice(report.Domains, func(i, j int) bool {
+		if report.Domains[i].RiskScore != report.Domains[j].RiskScore {
+			return report.Domains[i].RiskScore > report.Domains[j].RiskScore
+		}
+		return report.Domains[i].Domain < report.Domains[j].Domain
+	})
+
+	perTarget := make(map[string]int)
+	bands := []reportBar{{Label: "75-100"}, {Label: "50-74"}, {Label: "25-49"}, {Label: "0-24"}}
+	for _, entry := range report.Domains {
+		for _, t := range matchingTargets(entry) {
+			perTarget[t]++
+		}
+		switch {
+		case entry.RiskScore >= 75:
+			bands[0].Count++
+		case entry.RiskScore >= 50:
+			bands[1].Count++
+		case entry.RiskScore >= 25:
+			bands[2].Count++
+		default:
+			bands[3].Count++
+		}
+	}
+	for target, count := range perTarget {
+		report.PerTarget = append(report.PerTarget, reportBar{Label: target, Count: count})
+	}
+	sort.Slice(report.PerTarget, func(i, j int) bool {
+		if report.PerTarget[i].Count != report.PerTarget[j].Count {
+			return report.PerTarget[i].Count > report.PerTarget[j].Count
+		}
+		return report.PerTarget[i].Label < report.PerTarget[j].Label
+	})
+	report.PerTarget = scaleBars(report.PerTarget)
+	report.RiskBands = scaleBars(bands)
+	return report
+}
+
+// scaleBars sets each bar's width relative to the largest
+func scaleBars(bars []reportBar) []reportBar {
+	largest := 0
+	for _, b := range bars {
+		if b.Count > largest {
+			largest = b.Count
+		}
+	}
+	for i := range bars {
+		if largest > 0 {
+			bars[i].Width = bars[i].Count * 100 / largest
+		}
+	}
+	return bars
+}
+
+// renderMarkdownReport renders a report as Markdown tables
+func renderMarkdownReport(report dailyReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s - %s\n\n", report.Title, report.Generated.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Generated %s. %d domains discovered, %d blacklisted.\n", report.Generated.Format(time.RFC1123), len(report.Domains), len(report.Blacklisted))
+
+	if len(report.PerTarget) > 0 {
+		b.WriteString("\n## Discoveries by Target\n\n| Target | Domains |\n|---|---|\n")
+		for _, bar := range report.PerTarget {
+			fmt.Fprintf(&b, "| %s | %d |\n", markdownCell(bar.Label), bar.Count)
+		}
+	}
+
+	if len(report.Domains) > 0 {
+		b.WriteString("\n## Risk\n\n| Score | Domains |\n|---|---|\n")
+		for _, bar := range report.RiskBands {
+			fmt.Fprintf(&b, "| %s | %d |\n", bar.Label, bar.Count)
+		}
+
+		b.WriteString("\n## Domains\n\n| Domain | Risk | Labels | Status | Resolved | Issuer | First Seen | Hits |\n|---|---|---|---|---|---|---|---|\n")
+		for _, e := range report.Domains {
+			fmt.Fprintf(&b, "| %s | %d | %s | %s | %t | %s | %s | %d |\n",
+				markdownCell(e.Domain), e.RiskScore, markdownCell(strings.Join(e.RiskLabels, ", ")), reportStatus(e.HttpStatusCode),
+				e.Resolved, markdownCell(e.CertIssuer), e.FirstSeen.Format("15:04"), e.HitCount)
+		}
+	}
+
+	if len(report.Blacklisted) > 0 {
+		b.WriteString("\n## Blacklisted\n\n")
+		for _, domain := range report.Blacklisted {
+			fmt.Fprintf(&b, "- %s\n", markdownCell(domain))
+		}
+	}
+
+	if len(report.Issuance) > 0 {
+		b.WriteString("\n## Certificates by CA\n\n| Target | Issuer | Report Day | Window Total | Daily Average | Flag |\n|---|---|---|---|---|---|\n")
+		for _, row := range report.Issuance {
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %.1f | %s |\n",
+				markdownCell(row.Target), markdownCell(row.Issuer), row.Latest, row.Total, row.Average, row.Flag)
+		}
+	}
+
+	if report.Cleanup != nil {
+		fmt.Fprintf(&b, "\n## Cleanup\n\nReclaimed %s.\n", describeCleanupReport(*report.Cleanup))
+	}
+	return b.String()
+}
+
+// markdownCell escapes a value for a Markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// reportStatus formats an HTTP status code, blank when unknown
+func reportStatus(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return fmt.Sprint(code)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"status":  reportStatus,
+	"join":    strings.Join,
+	"day":     func(t time.Time) string { return t.Format("2006-01-02") },
+	"clock":   func(t time.Time) string { return t.Format("15:04") },
+	"full":    func(t time.Time) string { return t.Format(time.RFC1123) },
+	"cleanup": describeCleanupReport,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - {{day .Generated}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 32px; color: #1f2933; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 24px; font-size: 14px; }
+th, td { border-bottom: 1px solid #e4e7eb; padding: 6px 8px; text-align: left; }
+th { background: #f5f7fa; }
+.chart { margin-bottom: 24px; }
+.bar { display: flex; align-items: center; margin: 4px 0; font-size: 14px; }
+.bar span { width: 220px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.bar div { background: #7c3aed; height: 16px; margin-right: 8px; }
+.high { color: #c81e1e; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} - {{day .Generated}}</h1>
+<p>Generated {{full .Generated}}. {{len .Domains}} domains discovered, {{len .Blacklisted}} blacklisted.</p>
+{{if .PerTarget}}
+<h2>Discoveries by Target</h2>
+<div class="chart">{{range .PerTarget}}
+<div class="bar"><span>{{.Label}}</span><div style="width: {{.Width}}%; max-width: 60%;"></div>{{.Count}}</div>{{end}}
+</div>
+{{end}}{{if .Domains}}
+<h2>Risk</h2>
+<div class="chart">{{range .RiskBands}}
+<div class="bar"><span>{{.Label}}</span><div style="width: {{.Width}}%; max-width: 60%;"></div>{{.Count}}</div>{{end}}
+</div>
+<h2>Domains</h2>
+<table>
+<tr><th>Domain</th><th>Risk</th><th>Labels</th><th>Status</th><th>Resolved</th><th>Issuer</th><th>First Seen</th><th>Hits</th></tr>{{range .Domains}}
+<tr><td>{{.Domain}}</td><td{{if ge .RiskScore 75}} class="high"{{end}}>{{.RiskScore}}</td><td>{{join .RiskLabels ", "}}</td><td>{{status .HttpStatusCode}}</td><td>{{if .Resolved}}yes{{else}}no{{end}}</td><td>{{.CertIssuer}}</td><td>{{clock .FirstSeen}}</td><td>{{.HitCount}}</td></tr>{{end}}
+</table>
+{{end}}{{if .Blacklisted}}
+<h2>Blacklisted</h2>
+<ul>{{range .Blacklisted}}
+<li>{{.}}</li>{{end}}
+</ul>
+{{end}}{{if .Issuance}}
+<h2>Certificates by CA</h2>
+<table>
+<tr><th>Target</th><th>Issuer</th><th>Report Day</th><th>Window Total</th><th>Daily Average</th><th>Flag</th></tr>{{range .Issuance}}
+<tr><td>{{.Target}}</td><td>{{.Issuer}}</td><td>{{.Latest}}</td><td>{{.Total}}</td><td>{{printf "%.1f" .Average}}</td><td>{{.Flag}}</td></tr>{{end}}
+</table>
+{{end}}{{with .Cleanup}}
+<h2>Cleanup</h2>
+<p>Reclaimed {{cleanup .}}.</p>
+{{end}}
+</body>
+</html>
+`))
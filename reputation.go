@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReputationConfig checks lookalike and high-risk domains against VirusTotal and
+// urlscan.io before they are notified
+type ReputationConfig struct {
+	VirusTotalAPIKey  string   `yaml:"virustotal_api_key"`
+	URLScanAPIKey     string   `yaml:"urlscan_api_key"`
+	MinRisk           int      `yaml:"min_risk"`           // Also check domains of any target scoring at least this, default 70
+	Labels            []string `yaml:"labels"`             // Also check domains with any of these risk labels
+	URLScanVisibility string   `yaml:"urlscan_visibility"` // public, unlisted (default) or private
+	URLScanWait       int      `yaml:"urlscan_wait"`       // Seconds to wait for a scan's verdict before notifying, default 30
+	CacheHours        int      `yaml:"cache_hours"`        // Reuse a domain's verdicts for this long, default 24
+}
+
+// Reputation holds the verdicts of a domain's last reputation check
+type Reputation struct {
+	VirusTotal *VirusTotalVerdict `json:"virustotal,omitempty"`
+	URLScan    *URLScanVerdict    `json:"urlscan,omitempty"`
+	CheckedAt  time.Time          `json:"checked_at"`
+}
+
+// VirusTotalVerdict is VirusTotal's view of a domain
+type VirusTotalVerdict struct {
+	Known      bool   `json:"known"`     // VirusTotal had a report on the domain
+	Malicious  int    `json:"malicious"` // Engines flagging it malicious
+	Suspicious int    `json:"suspicious"`
+	Harmless   int    `json:"harmless"`
+	Reputation int    `json:"reputation"`            // Community score
+	AnalysisID string `json:"analysis_id,omitempty"` // URL analysis submitted for a domain VirusTotal didn't know
+}
+
+// URLScanVerdict is the result of an urlscan.io scan of a domain
+type URLScanVerdict struct {
+	ScanID     string   `json:"scan_id"`
+	Done       bool     `json:"done"` // The verdict was ready when the domain was notified
+	Malicious  bool     `json:"malicious"`
+	Score      int      `json:"score"`
+	Categories []string `json:"categories,omitempty"` // e.g. "phishing"
+}
+
+// Reputation service endpoints
+const (
+	virusTotalAPIBase = "https://www.virustotal.com/api/v3"
+	urlscanAPIBase    = "https://urlscan.io/api/v1"
+)
+
+// Request spacing under the free tiers: VirusTotal allows 4 lookups a minute
+const (
+	virusTotalInterval  = 15 * time.Second
+	urlscanInterval     = 2 * time.Second
+	urlscanPollInterval = 5 * time.Second
+)
+
+// reputationMaxQueueWait is the longest a lookup waits for its turn. Lookups run before
+// notifying, so a domain is notified without a verdict rather than held up behind a
+// long queue.
+const reputationMaxQueueWait = 30 * time.Second
+
+// errReputationBusy is returned when a service's queue is longer than reputationMaxQueueWait
+var errReputationBusy = errors.New("too many lookups queued, skipped")
+
+var reputationConfig *ReputationConfig
+var reputationMutex sync.Mutex
+var virusTotalNext, urlscanNext time.Time
+
+// SetReputationConfig sets the reputation check configuration
+func SetReputationConfig(cfg *ReputationConfig) {
+	reputationMutex.Lock()
+	defer reputationMutex.Unlock()
+	cfg.VirusTotalAPIKey = strings.TrimSpace(cfg.VirusTotalAPIKey)
+	cfg.URLScanAPIKey = strings.TrimSpace(cfg.URLScanAPIKey)
+	cfg.URLScanVisibility = strings.ToLower(strings.TrimSpace(cfg.URLScanVisibility))
+	if cfg.MinRisk <= 0 {
+		cfg.MinRisk = 70
+	}
+	if cfg.URLScanVisibility == "" {
+		cfg.URLScanVisibility = "unlisted"
+	}
+	if cfg.URLScanWait <= 0 {
+		cfg.URLScanWait = 30
+	}
+	if cfg.CacheHours <= 0 {
+		cfg.CacheHours = 24
+	}
+	reputationConfig = cfg
+}
+
+// GetReputationConfig returns the reputation check configuration
+func GetReputationConfig() *ReputationConfig {
+	reputationMutex.Lock()
+	defer reputationMutex.Unlock()
+	return reputationConfig
+}
+
+// isReputationEnabled reports whether a VirusTotal or urlscan.io key is configured
+func isReputationEnabled() bool {
+	cfg := GetReputationConfig()
+	return cfg != nil && (cfg.VirusTotalAPIKey != "" || cfg.URLScanAPIKey != "")
+}
+
+// needsReputation reports whether a domain is checked: a lookalike matched by a
+// keyword target or found by permutation, or one scoring or labelled high-risk
+func needsReputation(cfg *ReputationConfig, entry *DomainEntry, targets []string) bool {
+	for _, target := range targets {
+		if isKeywordTarget(target) {
+			return true
+		}
+	}
+	if entry.Source == sourcePermutation || entry.RiskScore >= cfg.MinRisk {
+		return true
+	}
+	for _, label := range entry.RiskLabels {
+		for _, l := range cfg.Labels {
+			if strings.EqualFold(label, l) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckReputation looks a domain up in VirusTotal and scans it with urlscan.io when it
+// needs a check, and records the verdicts
+func CheckReputation(domain string, targets []string) *Reputation {
+	cfg := GetReputationConfig()
+	if cfg == nil || (cfg.VirusTotalAPIKey == "" && cfg.URLScanAPIKey == "") {
+		return nil
+	}
+	dt := GetDomainTracker()
+	entry := dt.GetDomainInfo(domain)
+	if entry == nil || !needsReputation(cfg, entry, targets) {
+		return nil
+	}
+	if entry.Reputation != nil && time.Since(entry.Reputation.CheckedAt) < time.Duration(cfg.CacheHours)*time.Hour {
+		return entry.Reputation
+	}
+
+	result := &Reputation{CheckedAt: time.Now()}
+	if cfg.VirusTotalAPIKey != "" {
+		verdict, err := checkVirusTotal(cfg.VirusTotalAPIKey, domain)
+		if errors.Is(err, errReputationBusy) {
+			logger.Debug("virustotal lookup skipped", "domain", domain, "error", err)
+		} else if err != nil {
+			logger.Warn("virustotal lookup failed", "domain", domain, "error", err)
+		}
+		result.VirusTotal = verdict
+	}
+	if cfg.URLScanAPIKey != "" {
+		verdict, err := scanURLScan(cfg, domain)
+		if errors.Is(err, errReputationBusy) {
+			logger.Debug("urlscan scan skipped", "domain", domain, "error", err)
+		} else if err != nil {
+			logger.Warn("urlscan scan failed", "domain", domain, "error", err)
+		}
+		result.URLScan = verdict
+	}
+	if result.VirusTotal == nil && result.URLScan == nil {
+		return nil
+	}
+
+	dt.RecordDomainReputation(domain, result)
+	logger.Debug("reputation check complete", "domain", domain, "malicious", result.malicious())
+	return result
+}
+
+// malicious reports whether either service flagged the domain
+func (r *Reputation) malicious() bool {
+	return r != nil && ((r.VirusTotal != nil && r.VirusTotal.Malicious > 0) || (r.URLScan != nil && r.URLScan.Malicious))
+}
+
+// waitReputationTurn blocks until a service may be called again and reserves the slot
+// after. When the turn is further off than reputationMaxQueueWait no slot is reserved
+// and errReputationBusy is returned.
+func waitReputationTurn(next *time.Time, interval time.Duration) error {
+	reputationMutex.Lock()
+	now := time.Now()
+	wait := next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > reputationMaxQueueWait {
+		reputationMutex.Unlock()
+		return errReputationBusy
+	}
+	*next = now.Add(wait + interval)
+	reputationMutex.Unlock()
+	time.Sleep(wait)
+	return nil
+}
+
+// checkVirusTotal returns VirusTotal's report on a domain. A domain it doesn't know
+// is submitted for analysis, and the analysis ID kept for reference.
+func checkVirusTotal(apiKey, domain string) (*VirusTotalVerdict, error) {
+	if err := waitReputationTurn(&virusTotalNext, virusTotalInterval); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, virusTotalAPIBase+"/domains/"+url.PathEscape(domain), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-apikey", apiKey)
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		verdict := &VirusTotalVerdict{}
+		verdict.AnalysisID, err = submitVirusTotalURL(apiKey, "https://"+domain)
+		return verdict, err
+	default:
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var report struct {
+		Data struct {
+			Attributes struct {
+				LastAnalysisStats struct {
+					Malicious  int `json:"malicious"`
+					Suspicious int `json:"suspicious"`
+					Harmless   int `json:"harmless"`
+				} `json:"last_analysis_stats"`
+				Reputation int `json:"reputation"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	attrs := report.Data.Attributes
+	return &VirusTotalVerdict{
+		Known:      true,
+		Malicious:  attrs.LastAnalysisStats.Malicious,
+		Suspicious: attrs.LastAnalysisStats.Suspicious,
+		Harmless:   attrs.LastAnalysisStats.Harmless,
+		Reputation: attrs.Reputation,
+	}, nil
+}
+
+// submitVirusTotalURL submits a URL for analysis and returns the analysis ID
+func submitVirusTotalURL(apiKey, rawURL string) (string, error) {
+	if err := waitReputationTurn(&virusTotalNext, virusTotalInterval); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, virusTotalAPIBase+"/urls", strings.NewReader(url.Values{"url": {rawURL}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("x-apikey", apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("submit: HTTP %d", resp.StatusCode)
+	}
+
+	var submitted struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&submitted); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return submitted.Data.ID, nil
+}
+
+// scanURLScan submits a domain to urlscan.io and waits up to urlscan_wait for the
+// verdict. The scan ID is returned even when the verdict isn't ready yet.
+func scanURLScan(cfg *ReputationConfig, domain string) (*URLScanVerdict, error) {
+	if err := waitReputationTurn(&urlscanNext, urlscanInterval); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]string{"url": "https://" + domain, "visibility": cfg.URLScanVisibility})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, urlscanAPIBase+"/scan/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("API-Key", cfg.URLScanAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	var submitted struct {
+		UUID    string `json:"uuid"`
+		Message string `json:"message"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&submitted)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if submitted.Message != "" {
+			return nil, fmt.Errorf("submit: HTTP %d: %s", resp.StatusCode, submitted.Message)
+		}
+		return nil, fmt.Errorf("submit: HTTP %d", resp.StatusCode)
+	}
+	if decodeErr != nil || submitted.UUID == "" {
+		return nil, fmt.Errorf("submit: invalid response")
+	}
+
+	verdict := &URLScanVerdict{ScanID: submitted.UUID}
+	deadline := time.Now().Add(time.Duration(cfg.URLScanWait) * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(urlscanPollInterval)
+		done, err := fetchURLScanResult(verdict)
+		if err != nil {
+			return verdict, err
+		}
+		if done {
+			break
+		}
+	}
+	return verdict, nil
+}
+
+// fetchURLScanResult fills in the verdict of a finished scan, reporting false while
+// the scan is still running
+func fetchURLScanResult(verdict *URLScanVerdict) (bool, error) {
+	resp, err := outboundClient().Get(urlscanAPIBase + "/result/" + url.PathEscape(verdict.ScanID) + "/")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("result: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Verdicts struct {
+			Overall struct {
+				Score      int      `json:"score"`
+				Malicious  bool     `json:"malicious"`
+				Categories []string `json:"categories"`
+			} `json:"overall"`
+		} `json:"verdicts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("result: invalid response: %w", err)
+	}
+	overall := result.Verdicts.Overall
+	verdict.Done = true
+	verdict.Malicious = overall.Malicious
+	verdict.Score = overall.Score
+	verdict.Categories = overall.Categories
+	return true, nil
+}
+
+// urlscanResultURL returns the page of a scan
+func urlscanResultURL(scanID string) string {
+	return "https://urlscan.io/result/" + scanID + "/"
+}
+
+// urlscanScreenshotURL returns the screenshot a scan took
+func urlscanScreenshotURL(scanID string) string {
+	return "https://urlscan.io/screenshots/" + scanID + ".png"
+}
+
+// describeReputation summarizes a domain's verdicts for a notification, with links
+func describeReputation(domain string, r *Reputation) string {
+	var parts []string
+	if vt := r.VirusTotal; vt != nil {
+		link := "https://www.virustotal.com/gui/domain/" + url.PathEscape(domain)
+		switch {
+		case !vt.Known:
+			parts = append(parts, fmt.Sprintf("[VirusTotal](%s): not seen before, submitted", link))
+		case vt.Malicious > 0 || vt.Suspicious > 0:
+			parts = append(parts, fmt.Sprintf("[VirusTotal](%s): %d malicious, %d suspicious", link, vt.Malicious, vt.Suspicious))
+		default:
+			parts = append(parts, fmt.Sprintf("[VirusTotal](%s): clean", link))
+		}
+	}
+	if us := r.URLScan; us != nil {
+		verdict := "pending"
+		switch {
+		case us.Done && us.Malicious:
+			verdict = fmt.Sprintf("malicious, score %d", us.Score)
+			if len(us.Categories) > 0 {
+				verdict += " (" + strings.Join(us.Categories, ", ") + ")"
+			}
+		case us.Done:
+			verdict = "no verdict"
+		}
+		parts = append(parts, fmt.Sprintf("[urlscan](%s): %s, [screenshot](%s)", urlscanResultURL(us.ScanID), verdict, urlscanScreenshotURL(us.ScanID)))
+	}
+	return fmt.Sprintf("%s: %s", domain, strings.Join(parts, " · "))
+}
+
+// batchReputation lists the reputation verdicts of a batch of domains, within a character budget
+func batchReputation(domains []string, budget int) string {
+	dt := GetDomainTracker()
+	var lines strings.Builder
+	for _, domain := range domains {
+		entry := dt.GetDomainInfo(domain)
+		if entry == nil || entry.Reputation == nil {
+			continue
+		}
+		line := describeReputation(domain, entry.Reputation) + "\n"
+		if lines.Len()+len(line) > budget {
+			break
+		}
+		lines.WriteString(line)
+	}
+	return strings.TrimSuffix(lines.String(), "\n")
+}
+
+// checkReputationConfig reports a reputation section that cannot work as configured
+func checkReputationConfig(cfg ReputationConfig) []doctorCheck {
+	var checks []doctorCheck
+	switch strings.ToLower(strings.TrimSpace(cfg.URLScanVisibility)) {
+	case "", "public", "unlisted", "private":
+	default:
+		checks = append(checks, doctorCheck{"reputation", doctorFail, "urlscan_visibility: must be public, unlisted or private"})
+	}
+	if strings.EqualFold(strings.TrimSpace(cfg.URLScanVisibility), "public") && strings.TrimSpace(cfg.URLScanAPIKey) != "" {
+		checks = append(checks, doctorCheck{"reputation", doctorWarn, "public urlscan scans are visible to anyone, including the domain owner"})
+	}
+	return checks
+}
+
+// checkReputationAPIs verifies the configured VirusTotal and urlscan.io keys
+func checkReputationAPIs(cfg *Config) []doctorCheck {
+	var checks []doctorCheck
+	if key := strings.TrimSpace(cfg.Reputation.VirusTotalAPIKey); key != "" {
+		checks = append(checks, checkHTTP("virustotal api", virusTotalAPIBase+"/domains/example.com", func(req *http.Request) {
+			req.Header.Set("x-apikey", key)
+		}))
+	}
+	if key := strings.TrimSpace(cfg.Reputation.URLScanAPIKey); key != "" {
+		checks = append(checks, checkHTTP("urlscan api", "https://urlscan.io/user/quotas/", func(req *http.Request) {
+			req.Header.Set("API-Key", key)
+		}))
+	}
+	return checks
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// RescanConfig holds settings for rescanning known live domains on a cadence
+type RescanConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	IntervalDays int      `yaml:"interval_days"` // Days between rescans of a domain, default 7
+	PerHour      int      `yaml:"per_hour"`      // Domains rescanned per hour, spread evenly, default 20
+	Probe        *bool    `yaml:"probe"`         // Probe and port scan again, default true
+	Enumerate    *bool    `yaml:"enumerate"`     // Queue the discovery scans again, default true
+	Targets      []string `yaml:"targets"`       // Only domains of these targets, default all
+}
+
+var rescanConfig *RescanConfig
+var rescanMutex sync.Mutex
+
+// SetRescanConfig sets the rescan configuration
+func SetRescanConfig(cfg *RescanConfig) {
+	rescanMutex.Lock()
+	defer rescanMutex.Unlock()
+	if cfg.IntervalDays <= 0 {
+		cfg.IntervalDays = 7
+	}
+	if cfg.PerHour <= 0 {
+		cfg.PerHour = 20
+	}
+	rescanConfig = cfg
+}
+
+// GetRescanConfig returns the rescan configuration
+func GetRescanConfig() *RescanConfig {
+	rescanMutex.Lock()
+	defer rescanMutex.Unlock()
+	return rescanConfig
+}
+
+// rescanSpacing is the time between two rescans, which keeps them under per_hour
+func rescanSpacing(cfg *RescanConfig) time.Duration {
+	if cfg == nil {
+		return time.Hour
+	}
+	return time.Hour / time.Duration(cfg.PerHour)
+}
+
+// StartRescanScheduler rescans the domain due longest ago every so often. A reload
+// enabling rescans or changing per_hour takes effect after the current wait.
+func StartRescanScheduler() {
+	go func() {
+		// Let the CT feed and the first notifications go before rescanning
+		time.Sleep(5 * time.Minute)
+		for {
+			cfg := GetRescanConfig()
+			if cfg != nil && cfg.Enabled {
+				if domain, target := nextRescan(cfg); domain != "" {
+					rescanDomain(cfg, domain, target)
+				}
+			}
+			time.Sleep(rescanSpacing(cfg))
+		}
+	}()
+}
+
+// nextRescan returns the live domain whose last scan is oldest, once that is
+// interval_days ago, and the target it falls under
+func nextRescan(cfg *RescanConfig) (string, string) {
+	due := time.Now().Add(-time.Duration(cfg.IntervalDays) * 24 * time.Hour)
+	var next, nextTarget string
+	var oldest time.Time
+	for _, entry := range GetDomainTracker().GetAllDomains() {
+		// Known live means it resolved or answered a probe
+		if IsWildcardDomain(entry.Domain) || entry.Blacklisted || isSnoozed(entry) || (!entry.Resolved && entry.Probe == nil) {
+			continue
+		}
+		last := entry.LastRescan
+		if last.IsZero() {
+			last = entry.FirstSeen
+		}
+		if last.After(due) || (next != "" && !last.Before(oldest)) {
+			continue
+		}
+		target := rescanTarget(cfg, entry)
+		if target == "" {
+			continue
+		}
+		next, nextTarget, oldest = entry.Domain, target, last
+	}
+	return next, nextTarget
+}
+
+// rescanTarget returns the configured target a tracked domain falls under, or "" when it
+// falls under none or only under targets left out of rescans
+func rescanTarget(cfg *RescanConfig, entry *DomainEntry) string {
+	for _, target := range targets {
+		if !entryMatchesTarget(entry, target) {
+			continue
+		}
+		if len(cfg.Targets) == 0 {
+			return target
+		}
+		for _, t := range cfg.Targets {
+			if strings.EqualFold(strings.TrimSpace(t), target) {
+				return target
+			}
+		}
+	}
+	return ""
+}
+
+// rescanDomain probes a domain again and queues its discovery scans. Repeat scans only
+// report what changed, see enumeration.notify_all_findings.
+func rescanDomain(cfg *RescanConfig, domain, target string) {
+	if cfg.Enumerate == nil || *cfg.Enumerate {
+		if enumEnabledFor(target) {
+			// Leave room in the queue for newly discovered domains; the probes below
+			// still run
+			_, _, maxQueued := jobLimits()
+			if _, queued := GetJobManager().Counts(); maxQueued > 0 && queued >= maxQueued/2 {
+				logger.Debug("scan queue busy, rescan enumeration skipped", "domain", domain, "queued", queued)
+			} else if len(pendingJobs(domain)) == 0 {
+				triggerEnumeration(domain, target)
+			}
+		}
+	}
+
+	// Resolving again moves the asset between live and offline
+	ResolveDomain(domain)
+	GetDomainTracker().RecordDomainResolution(domain, ResolvedAddrs(domain))
+
+	if cfg.Probe == nil || *cfg.Probe {
+		ProbeDomain(domain)
+		RunPortScan(domain)
+	}
+
+	GetDomainTracker().MarkDomainRescanned(domain)
+	logger.Info("domain rescanned", "domain", domain, "target", target)
+}
+
+// checkRescanConfig reports a rescan section that has nothing to do
+func checkRescanConfig(cfg *Config) []doctorCheck {
+	if !cfg.Rescan.Enabled {
+		return nil
+	}
+	probe := (cfg.Rescan.Probe == nil || *cfg.Rescan.Probe) && (cfg.HTTPProbe.Enabled || cfg.PortScan.Enabled)
+	enumerate := (cfg.Rescan.Enumerate == nil || *cfg.Rescan.Enumerate) && cfg.Enumeration.EnableEnum
+	if !probe && !enumerate {
+		return []doctorCheck{{"rescan", doctorWarn, "enabled, but http_probe, port_scan and enumeration are all off"}}
+	}
+	return nil
+}
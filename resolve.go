@@ -1,69 +1,702 @@
 package main
 
 import (
+	"container/list"
 	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
+// ResolveConfig selects the resolvers new domains are checked with and tunes the cache of results
+type ResolveConfig struct {
+	Resolvers          []string `yaml:"resolvers"`            // "system", ip[:port], a DoH JSON endpoint, or "cloudflare"/"google" for their DoH; empty uses the system resolver
+	PositiveTTLMinutes int      `yaml:"positive_ttl_minutes"` // How long a resolving domain is trusted, default 60
+	NegativeTTLMinutes int      `yaml:"negative_ttl_minutes"` // How long a failed lookup is remembered, default 60
+	CacheSize          int      `yaml:"cache_size"`           // Max cached results, least recently used go first, default 100000
+	Persist            bool     `yaml:"persist"`              // Keep the cache across restarts in resolve_cache.json
+}
+
+// dohShorthands name public DoH JSON endpoints. They are addressed by IP so they work
+// when local DNS is broken.
+var dohShorthands = map[string]string{
+	"cloudflare": "https://1.1.1.1/dns-query",
+	"google":     "https://8.8.8.8/resolve",
+}
+
+// resolveRotation spreads lookups across the configured resolvers
+var resolveRotation atomic.Uint32
+
+// systemResolver is used without configured resolvers, and for "system"
+var systemResolver = &net.Resolver{
+	PreferGo: true,
+	Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		d := net.Dialer{Timeout: 5 * time.Second}
+		return d.DialContext(ctx, network, address)
+	},
+}
+
+// maxCNAMEHops bounds how far a CNAME chain is followed
+const maxCNAMEHops = 8
+
+// resolveCacheSaveInterval is how often a persisted cache is written to disk
+const resolveCacheSaveInterval = 10 * time.Minute
+
 var (
-	resolveCache = make(map[string]cacheEntry)
-	resolveMutex sync.RWMutex
+	resolveCache = make(map[string]*list.Element) // Domain -> element of resolveLRU holding a *cacheEntry
+	resolveLRU   = list.New()                     // Most recently used at the front
+	resolveMutex sync.Mutex
 )
 
+// resolveInflight lets concurrent lookups of one domain, e.g. from a burst of
+// certificates, share a single query
+var resolveInflight = make(map[string]*resolveCall)
+
+type resolveCall struct {
+	done     chan struct{}
+	resolves bool
+}
+
 type cacheEntry struct {
-	resolves  bool
-	timestamp time.Time
+	Domain    string    `json:"domain"`
+	Resolves  bool      `json:"resolves"`
+	Addrs     []string  `json:"addrs,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+var resolveConfig *ResolveConfig
+var resolveConfigMutex sync.Mutex
+var resolveCachePath string
+
+// SetResolveConfig sets the DNS cache configuration
+func SetResolveConfig(cfg *ResolveConfig) {
+	resolveConfigMutex.Lock()
+	defer resolveConfigMutex.Unlock()
+	if cfg.PositiveTTLMinutes <= 0 {
+		cfg.PositiveTTLMinutes = 60
+	}
+	if cfg.NegativeTTLMinutes <= 0 {
+		cfg.NegativeTTLMinutes = 60
+	}
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 100000
+	}
+	for i, r := range cfg.Resolvers {
+		if endpoint, ok := dohShorthands[strings.ToLower(r)]; ok {
+			cfg.Resolvers[i] = endpoint
+		}
+	}
+	if len(cfg.Resolvers) > 0 {
+		logger.Info("using upstream resolvers", "resolvers", cfg.Resolvers)
+	}
+	resolveConfig = cfg
+}
+
+// GetResolveConfig returns the DNS cache configuration, with defaults if none was set
+func GetResolveConfig() ResolveConfig {
+	resolveConfigMutex.Lock()
+	defer resolveConfigMutex.Unlock()
+	if resolveConfig == nil {
+		return ResolveConfig{PositiveTTLMinutes: 60, NegativeTTLMinutes: 60, CacheSize: 100000}
+	}
+	return *resolveConfig
+}
+
+// expired reports whether a cached result is older than its TTL
+func (e *cacheEntry) expired(cfg ResolveConfig) bool {
+	ttl := cfg.NegativeTTLMinutes
+	if e.Resolves {
+		ttl = cfg.PositiveTTLMinutes
+	}
+	return time.Since(e.Timestamp) >= time.Duration(ttl)*time.Minute
+}
+
+// InitResolveCache restores a persisted cache and saves it periodically
+func InitResolveCache(configDir string) error {
+	if !GetResolveConfig().Persist {
+		return nil
+	}
+	resolveCachePath = filepath.Join(configDir, "resolve_cache.json")
+
+	data, err := os.ReadFile(resolveCachePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var entries []*cacheEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		// Oldest first, so the most recent end up at the front
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		})
+		cfg := GetResolveConfig()
+		resolveMutex.Lock()
+		for _, entry := range entries {
+			if !entry.expired(cfg) {
+				storeResolveEntry(entry)
+			}
+		}
+		logger.Info("dns cache restored", "entries", len(resolveCache))
+		resolveMutex.Unlock()
+	}
+
+	go func() {
+		ticker := time.NewTicker(resolveCacheSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := SaveResolveCache(); err != nil {
+				logger.Error("failed to save dns cache", "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// SaveResolveCache writes unexpired results to disk when persistence is enabled
+func SaveResolveCache() error {
+	if resolveCachePath == "" {
+		return nil
+	}
+	cfg := GetResolveConfig()
+
+	resolveMutex.Lock()
+	entries := make([]*cacheEntry, 0, len(resolveCache))
+	for e := resolveLRU.Front(); e != nil; e = e.Next() {
+		if entry := e.Value.(*cacheEntry); !entry.expired(cfg) {
+			entries = append(entries, entry)
+		}
+	}
+	data, err := json.Marshal(entries)
+	resolveMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := resolveCachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, resolveCachePath)
 }
 
 // ResolveDomain checks if a domain resolves via DNS
 func ResolveDomain(domain string) bool {
 	// Normalize domain
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	cfg := GetResolveConfig()
+
+	// Check cache first, then join a lookup already in flight
+	resolveMutex.Lock()
+	if e, exists := resolveCache[d]; exists {
+		if entry := e.Value.(*cacheEntry); !entry.expired(cfg) {
+			resolveLRU.MoveToFront(e)
+			resolveMutex.Unlock()
+			return entry.Resolves
+		}
+	}
+	if call, exists := resolveInflight[d]; exists {
+		resolveMutex.Unlock()
+		<-call.done
+		return call.resolves
+	}
+	call := &resolveCall{done: make(chan struct{})}
+	resolveInflight[d] = call
+	resolveMutex.Unlock()
+
+	addrs, err := lookupUpstream(cfg.Resolvers, d)
+	if err != nil {
+		logger.Debug("dns lookup failed", "domain", d, "error", err)
+	}
+	resolves := len(addrs) > 0
+
+	resolveMutex.Lock()
+	storeResolveEntry(&cacheEntry{
+		Domain:    d,
+		Resolves:  resolves,
+		Addrs:     addrs,
+		Timestamp: time.Now(),
+	})
+	delete(resolveInflight, d)
+	resolveMutex.Unlock()
+
+	call.resolves = resolves
+	close(call.done)
+	return resolves
+}
+
+// lookupUpstream resolves a domain with the configured resolvers. A name that doesn't
+// exist is an answer, not a failure.
+func lookupUpstream(resolvers []string, domain string) ([]string, error) {
+	// An address from an IP SAN resolves to itself
+	if addr, err := netip.ParseAddr(domain); err == nil {
+		return []string{addr.Unmap().String()}, nil
+	}
+	var addrs []string
+	err := rotateResolvers(resolvers, domain, func(resolver string) error {
+		var err error
+		addrs, err = lookupWith(resolver, domain)
+		return err
+	})
+	return addrs, err
+}
 
-	// Check cache first (valid for 1 hour)
-	resolveMutex.RLock()
-	if entry, exists := resolveCache[d]; exists {
-		if time.Since(entry.timestamp) < time.Hour {
-			resolveMutex.RUnlock()
-			return entry.resolves
+// rotateResolvers calls lookup with each resolver in turn, starting at the next one in
+// rotation, until one succeeds. Without resolvers the system resolver is used.
+func rotateResolvers(resolvers []string, domain string, lookup func(resolver string) error) error {
+	if len(resolvers) == 0 {
+		return lookup("system")
+	}
+	start := int(resolveRotation.Add(1))
+	var lastErr error
+	for i := range resolvers {
+		resolver := resolvers[(start+i)%len(resolvers)]
+		err := lookup(resolver)
+		if err == nil {
+			return nil
 		}
+		logger.Debug("resolver failed, trying next", "resolver", resolver, "domain", domain, "error", err)
+		lastErr = err
 	}
-	resolveMutex.RUnlock()
+	return lastErr
+}
 
-	// Perform DNS lookup with timeout
+// lookupWith returns a domain's addresses from one resolver, empty if the name doesn't exist
+func lookupWith(resolver, domain string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{Timeout: 5 * time.Second}
-			return d.DialContext(ctx, network, address)
-		},
+	if strings.HasPrefix(resolver, "https://") {
+		addrs, err := lookupDoH(ctx, resolver, domain, "A")
+		if err == nil && len(addrs) == 0 {
+			addrs, err = lookupDoH(ctx, resolver, domain, "AAAA")
+		}
+		return addrs, err
 	}
 
-	_, err := resolver.LookupHost(ctx, d)
-	resolves := err == nil
+	addrs, err := netResolver(resolver).LookupHost(ctx, domain)
+	if err != nil {
+		if isNotFound(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return addrs, nil
+}
 
-	// Cache result
-	resolveMutex.Lock()
-	resolveCache[d] = cacheEntry{
-		resolves:  resolves,
-		timestamp: time.Now(),
+// LookupCNAMEChain returns the names a domain is an alias for, in order, using the
+// configured resolvers. Resolvers that follow the chain themselves may only report the
+// canonical name for domains that resolve. Dangling chains are followed hop by hop.
+func LookupCNAMEChain(domain string) []string {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	var chain []string
+	err := rotateResolvers(GetResolveConfig().Resolvers, d, func(resolver string) error {
+		var err error
+		chain, err = cnameChainWith(resolver, d)
+		return err
+	})
+	if err != nil {
+		logger.Debug("cname lookup failed", "domain", d, "error", err)
 	}
-	resolveMutex.Unlock()
+	return chain
+}
 
-	return resolves
+// cnameChainWith follows a domain's CNAMEs with one resolver
+func cnameChainWith(resolver, domain string) ([]string, error) {
+	chain := []string{}
+	name := domain
+	for len(chain) < maxCNAMEHops {
+		next, err := lookupCNAMEWith(resolver, name)
+		if err != nil {
+			if len(chain) > 0 {
+				break
+			}
+			return nil, err
+		}
+		if next == "" || next == name || slices.Contains(chain, next) {
+			break
+		}
+		chain = append(chain, next)
+		name = next
+	}
+	return chain, nil
+}
+
+// lookupCNAMEWith returns the name a domain is an alias for from one resolver, empty if it isn't one
+func lookupCNAMEWith(resolver, domain string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cname string
+	if strings.HasPrefix(resolver, "https://") {
+		records, err := lookupDoH(ctx, resolver, domain, "CNAME")
+		if err != nil || len(records) == 0 {
+			return "", err
+		}
+		cname = records[0]
+	} else {
+		var err error
+		cname, err = netResolver(resolver).LookupCNAME(ctx, domain)
+		if err != nil {
+			if isNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+	}
+	return strings.ToLower(strings.TrimSuffix(cname, ".")), nil
+}
+
+// CAARecord is one property of a domain's CAA record set
+type CAARecord struct {
+	Flags uint8  `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// caaCritical is the issuer critical flag; a CA must not issue if it doesn't understand the tag
+const caaCritical = 128
+
+// typeCAA is the CAA record type, which dnsmessage has no constant for
+const typeCAA = dnsmessage.Type(257)
+
+// LookupCAA returns the CAA records that govern issuance for a domain: the first
+// non-empty set at the domain or its parents, using the configured resolvers. None
+// means any CA may issue.
+func LookupCAA(domain string) ([]CAARecord, error) {
+	name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(domain, "*."), "."))
+	resolvers := GetResolveConfig().Resolvers
+	for name != "" {
+		var records []CAARecord
+		err := rotateResolvers(resolvers, name, func(resolver string) error {
+			var err error
+			records, err = lookupCAAWith(resolver, name)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			return records, nil
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return nil, nil
+}
+
+// lookupCAAWith returns the CAA records at a name from one resolver, empty if it has none
+func lookupCAAWith(resolver, name string) ([]CAARecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if strings.HasPrefix(resolver, "https://") {
+		answers, err := lookupDoH(ctx, resolver, name, "CAA")
+		if err != nil {
+			return nil, err
+		}
+		records := make([]CAARecord, 0, len(answers))
+		for _, answer := range answers {
+			record, err := parseCAAText(answer)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	servers := []string{resolver}
+	if resolver == "system" {
+		var err error
+		if servers, err = systemNameservers(); err != nil {
+			return nil, err
+		}
+	}
+	var lastErr error
+	for _, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		records, err := queryCAA(ctx, server, name)
+		if err == nil {
+			return records, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// queryCAA asks a DNS server for the CAA records at a name, over UDP and then TCP if
+// the answer was truncated
+func queryCAA(ctx context.Context, server, name string) ([]CAARecord, error) {
+	qname, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Uint32())
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: typeCAA, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeDNS(ctx, "udp", server, query)
+	if err != nil {
+		return nil, err
+	}
+	records, truncated, err := parseCAAResponse(resp, id)
+	if err == nil && truncated {
+		if resp, err = exchangeDNS(ctx, "tcp", server, query); err != nil {
+			return nil, err
+		}
+		records, _, err = parseCAAResponse(resp, id)
+	}
+	return records, err
+}
+
+// exchangeDNS sends a DNS query and returns the raw response
+func exchangeDNS(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	d := net.Dialer{Timeout: 3 * time.Second}
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+
+	// Over TCP messages are prefixed with their length
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// parseCAAResponse returns the CAA records in a DNS response, and whether it was truncated
+func parseCAAResponse(resp []byte, id uint16) ([]CAARecord, bool, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return nil, false, err
+	}
+	if header.ID != id {
+		return nil, false, fmt.Errorf("mismatched response id")
+	}
+	if header.Truncated {
+		return nil, true, nil
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return []CAARecord{}, false, nil
+	default:
+		return nil, false, fmt.Errorf("rcode %s", header.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, false, err
+	}
+
+	records := []CAARecord{}
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if h.Type != typeCAA { // CNAMEs leading to the records are skipped
+			if err := p.SkipAnswer(); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		r, err := p.UnknownResource()
+		if err != nil {
+			return nil, false, err
+		}
+		record, err := parseCAAData(r.Data)
+		if err != nil {
+			return nil, false, err
+		}
+		records = append(records, record)
+	}
+	return records, false, nil
+}
+
+// parseCAAData decodes the wire format of a CAA record: flags, tag length, tag, value
+func parseCAAData(data []byte) (CAARecord, error) {
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return CAARecord{}, fmt.Errorf("malformed CAA record")
+	}
+	tagEnd := 2 + int(data[1])
+	return CAARecord{
+		Flags: data[0],
+		Tag:   strings.ToLower(string(data[2:tagEnd])),
+		Value: string(data[tagEnd:]),
+	}, nil
+}
+
+// parseCAAText decodes a CAA record from a DoH JSON answer, either in presentation
+// format (0 issue "letsencrypt.org") or as RFC 3597 hex (\# 22 00 05 ...)
+func parseCAAText(text string) (CAARecord, error) {
+	fields := strings.Fields(text)
+	if len(fields) >= 2 && fields[0] == `\#` {
+		data, err := hex.DecodeString(strings.Join(fields[2:], ""))
+		if err != nil {
+			return CAARecord{}, fmt.Errorf("malformed CAA record: %w", err)
+		}
+		return parseCAAData(data)
+	}
+	if len(fields) < 3 {
+		return CAARecord{}, fmt.Errorf("malformed CAA record %q", text)
+	}
+	flags, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return CAARecord{}, fmt.Errorf("malformed CAA record %q", text)
+	}
+	_, rest, _ := strings.Cut(strings.TrimSpace(text), fields[1])
+	return CAARecord{
+		Flags: uint8(flags),
+		Tag:   strings.ToLower(fields[1]),
+		Value: strings.Trim(strings.TrimSpace(rest), `"`),
+	}, nil
+}
+
+// systemNameservers returns the nameservers in /etc/resolv.conf
+func systemNameservers() ([]string, error) {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("no system nameservers, set dns.resolvers for CAA lookups: %w", err)
+	}
+	var servers []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no nameservers in /etc/resolv.conf, set dns.resolvers for CAA lookups")
+	}
+	return servers, nil
+}
+
+// netResolver returns the resolver for "system" or an ip[:port] entry
+func netResolver(resolver string) *net.Resolver {
+	if resolver == "system" {
+		return systemResolver
+	}
+	return resolverForServer(resolver)
+}
+
+// isNotFound reports whether a lookup failed because the name doesn't exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// storeResolveEntry caches a result, evicting the least recently used ones past the
+// size limit. Caller must hold resolveMutex.
+func storeResolveEntry(entry *cacheEntry) {
+	if e, exists := resolveCache[entry.Domain]; exists {
+		e.Value = entry
+		resolveLRU.MoveToFront(e)
+	} else {
+		resolveCache[entry.Domain] = resolveLRU.PushFront(entry)
+	}
+	for limit := resolveCacheLimit(); len(resolveCache) > limit; {
+		oldest := resolveLRU.Back()
+		resolveLRU.Remove(oldest)
+		delete(resolveCache, oldest.Value.(*cacheEntry).Domain)
+	}
+}
+
+// ResolvedAddrs returns the addresses a domain resolved to, if the cached result is still fresh
+func ResolvedAddrs(domain string) []string {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+	cfg := GetResolveConfig()
+	resolveMutex.Lock()
+	defer resolveMutex.Unlock()
+	if e, exists := resolveCache[d]; exists {
+		if entry := e.Value.(*cacheEntry); !entry.expired(cfg) {
+			return append([]string(nil), entry.Addrs...)
+		}
+	}
+	return nil
 }
 
 // ClearResolveCache clears the DNS resolution cache
 func ClearResolveCache() {
 	resolveMutex.Lock()
 	defer resolveMutex.Unlock()
-	resolveCache = make(map[string]cacheEntry)
+	resolveCache = make(map[string]*list.Element)
+	resolveLRU.Init()
+}
+
+// PruneResolveCache removes expired entries and returns how many were removed
+func PruneResolveCache() int {
+	cfg := GetResolveConfig()
+	resolveMutex.Lock()
+	defer resolveMutex.Unlock()
+
+	removed := 0
+	for e := resolveLRU.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*cacheEntry); entry.expired(cfg) {
+			resolveLRU.Remove(e)
+			delete(resolveCache, entry.Domain)
+			removed++
+		}
+		e = next
+	}
+	return removed
 }
 
 // ClearResolveCacheEntry clears a single entry from the cache
@@ -71,12 +704,15 @@ func ClearResolveCacheEntry(domain string) {
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
 	resolveMutex.Lock()
 	defer resolveMutex.Unlock()
-	delete(resolveCache, d)
+	if e, exists := resolveCache[d]; exists {
+		resolveLRU.Remove(e)
+		delete(resolveCache, d)
+	}
 }
 
 // GetResolveCacheSize returns the current cache size
 func GetResolveCacheSize() int {
-	resolveMutex.RLock()
-	defer resolveMutex.RUnlock()
+	resolveMutex.Lock()
+	defer resolveMutex.Unlock()
 	return len(resolveCache)
 }
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RetryConfig sets how failed notifications are retried before they move to the
+// dead-letter log
+type RetryConfig struct {
+	MaxAttempts  int `yaml:"max_attempts"`  // Failed sends before giving up, default 8
+	InitialDelay int `yaml:"initial_delay"` // Seconds before the first retry, doubling after each, default 30
+	MaxDelay     int `yaml:"max_delay"`     // Longest wait between retries in seconds, default 3600
+}
+
+// Kinds of failed notification
+const (
+	retryBatch   = "batch"   // New domains of a target, sent to its providers
+	retryDiscord = "discord" // Payload for the main Discord webhook or bot channel
+	retryWebhook = "webhook" // Payload for a specific webhook
+)
+
+// FailedNotification is a notification waiting for a retry, or given up on
+type FailedNotification struct {
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Target      string          `json:"target,omitempty"`
+	Domains     []string        `json:"domains,omitempty"`
+	URL         string          `json:"url,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	FirstFailed time.Time       `json:"first_failed"`
+	NextRetry   time.Time       `json:"next_retry"`
+	DeadAt      time.Time       `json:"dead_at"`
+}
+
+// RetryQueue persists failed notifications and retries them with exponential backoff
+type RetryQueue struct {
+	mu       sync.Mutex
+	items    map[string]*FailedNotification
+	filePath string // Pending retries
+	deadPath string // Dead-letter log, one notification per line
+}
+
+var retryConfig *RetryConfig
+var retryConfigMutex sync.Mutex
+var retryQueue *RetryQueue
+
+// SetRetryConfig sets the retry configuration
+func SetRetryConfig(cfg *RetryConfig) {
+	retryConfigMutex.Lock()
+	defer retryConfigMutex.Unlock()
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.InitialDelay <= 0 {
+		cfg.InitialDelay = 30
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = 3600
+	}
+	retryConfig = cfg
+}
+
+// GetRetryConfig returns the retry configuration, with defaults if none was set
+func GetRetryConfig() *RetryConfig {
+	retryConfigMutex.Lock()
+	defer retryConfigMutex.Unlock()
+	if retryConfig == nil {
+		return &RetryConfig{MaxAttempts: 8, InitialDelay: 30, MaxDelay: 3600}
+	}
+	return retryConfig
+}
+
+// retryDelayAfter returns the wait before the next retry of a notification that
+// failed the given number of times
+func retryDelayAfter(attempts int) time.Duration {
+	cfg := GetRetryConfig()
+	delay := time.Duration(cfg.InitialDelay) * time.Second
+	limit := time.Duration(cfg.MaxDelay) * time.Second
+	for i := 1; i < attempts && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// InitRetryQueue loads the pending retries from the config directory
+func InitRetryQueue(configDir string) error {
+	q := &RetryQueue{
+		items:    make(map[string]*FailedNotification),
+		filePath: filepath.Join(configDir, "notify_retry.json"),
+		deadPath: filepath.Join(configDir, "notify_deadletter.jsonl"),
+	}
+
+	if data, err := os.ReadFile(q.filePath); err == nil {
+		if err := json.Unmarshal(data, &q.items); err != nil {
+			logger.Error("failed to load notification retry queue", "error", err)
+		}
+	} else if !os.IsNotExist(err) {
+		logger.Error("failed to read notification retry queue", "error", err)
+	}
+
+	retryQueue = q
+	return nil
+}
+
+// GetRetryQueue returns the global retry queue
+func GetRetryQueue() *RetryQueue {
+	if retryQueue == nil {
+		configDir, _ := getConfigDir()
+		InitRetryQueue(configDir)
+	}
+	return retryQueue
+}
+
+// AddBatch queues a batch of new domains that no provider accepted
+func (q *RetryQueue) AddBatch(target string, domains []string) {
+	q.add(&FailedNotification{Kind: retryBatch, Target: target, Domains: domains}, fmt.Errorf("every provider failed"))
+}
+
+// AddPayload queues a Discord payload; an empty URL means the main webhook or bot channel
+func (q *RetryQueue) AddPayload(url string, payload map[string]interface{}, sendErr error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	item := &FailedNotification{Kind: retryDiscord, Payload: data}
+	if url != "" {
+		item.Kind = retryWebhook
+		item.URL = url
+	}
+	q.add(item, sendErr)
+}
+
+// add queues a notification after its first failure
+func (q *RetryQueue) add(item *FailedNotification, sendErr error) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	item.ID = hex.EncodeToString(b)
+	item.Attempts = 1
+	item.LastError = redactSecrets(sendErr.Error())
+	item.FirstFailed = time.Now()
+	item.NextRetry = time.Now().Add(retryDelayAfter(1))
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items[item.ID] = item
+	if err := q.save(); err != nil {
+		logger.Error("failed to save notification retry queue", "error", err)
+	}
+	logger.Warn("notification failed, queued for retry", "kind", item.Kind, "target", item.Target, "retry_at", item.NextRetry.Format(time.RFC3339))
+}
+
+// StartRetryWorker retries due notifications in the background
+func StartRetryWorker() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			GetRetryQueue().retryDue()
+		}
+	}()
+}
+
+// retryDue retries every notification whose backoff has passed, oldest first
+func (q *RetryQueue) retryDue() {
+	q.mu.Lock()
+	var due []*FailedNotification
+	now := time.Now()
+	for _, item := range q.items {
+		if !item.NextRetry.After(now) {
+			copied := *item
+			due = append(due, &copied)
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].FirstFailed.Before(due[j].FirstFailed)
+	})
+	for _, item := range due {
+		err := deliverFailed(item)
+
+		q.mu.Lock()
+		current, exists := q.items[item.ID]
+		if !exists {
+			// Discarded while being retried
+			q.mu.Unlock()
+			continue
+		}
+		if err == nil {
+			delete(q.items, item.ID)
+			logger.Info("queued notification delivered", "kind", item.Kind, "target", item.Target, "attempts", current.Attempts+1)
+		} else {
+			current.Attempts++
+			current.LastError = redactSecrets(err.Error())
+			if current.Attempts >= GetRetryConfig().MaxAttempts {
+				delete(q.items, item.ID)
+				q.deadLetter(current)
+			} else {
+				current.NextRetry = time.Now().Add(retryDelayAfter(current.Attempts))
+			}
+		}
+		if err := q.save(); err != nil {
+			logger.Error("failed to save notification retry queue", "error", err)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// deliverFailed sends a queued notification again
+func deliverFailed(item *FailedNotification) error {
+	switch item.Kind {
+	case retryBatch:
+		if !notifier.deliver(item.Target, item.Domains) {
+			return fmt.Errorf("every provider failed")
+		}
+		return nil
+	case retryDiscord, retryWebhook:
+		var payload map[string]interface{}
+		if err := json.Unmarshal(item.Payload, &payload); err != nil {
+			return err
+		}
+		if item.Kind == retryWebhook {
+			return SendToWebhook(item.URL, payload)
+		}
+		return postDiscordPayload(payload)
+	}
+	return fmt.Errorf("unknown notification kind %q", item.Kind)
+}
+
+// deadLetter appends a notification that ran out of retries to the dead-letter log.
+// Caller must hold q.mu.
+func (q *RetryQueue) deadLetter(item *FailedNotification) {
+	item.DeadAt = time.Now()
+	logger.Error("notification failed after retries, moved to dead-letter log", "kind", item.Kind, "target", item.Target, "attempts", item.Attempts, "error", item.LastError)
+	RecordError(errCategoryWebhook, fmt.Sprintf("%s notification for %s gave up after %d attempts: %s", item.Kind, item.Target, item.Attempts, item.LastError))
+
+	file, err := os.OpenFile(q.deadPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Error("failed to open dead-letter log", "error", err)
+		return
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(item); err != nil {
+		logger.Error("failed to write dead-letter log", "error", err)
+	}
+}
+
+// deadLetters reads the dead-letter log
+func (q *RetryQueue) deadLetters() ([]*FailedNotification, error) {
+	file, err := os.Open(q.deadPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var items []*FailedNotification
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var item FailedNotification
+		if json.Unmarshal(scanner.Bytes(), &item) == nil && item.ID != "" {
+			items = append(items, &item)
+		}
+	}
+	return items, scanner.Err()
+}
+
+// writeDeadLetters replaces the dead-letter log. Caller must hold q.mu.
+func (q *RetryQueue) writeDeadLetters(items []*FailedNotification) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(q.deadPath, buf.Bytes(), 0600)
+}
+
+// Pending returns the notifications waiting for a retry, soonest first
+func (q *RetryQueue) Pending() []*FailedNotification {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := make([]*FailedNotification, 0, len(q.items))
+	for _, item := range q.items {
+		copied := *item
+		items = append(items, &copied)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].NextRetry.Before(items[j].NextRetry)
+	})
+	return items
+}
+
+// Replay retries a notification at the next pass: a pending one immediately, a dead
+// one with a fresh set of attempts. An empty id replays every dead letter.
+func (q *RetryQueue) Replay(id string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if item, exists := q.items[id]; exists {
+		item.NextRetry = time.Now()
+		return 1, q.save()
+	}
+
+	dead, err := q.deadLetters()
+	if err != nil {
+		return 0, err
+	}
+	var kept []*FailedNotification
+	replayed := 0
+	for _, item := range dead {
+		if id != "" && item.ID != id {
+			kept = append(kept, item)
+			continue
+		}
+		item.Attempts = 0
+		item.DeadAt = time.Time{}
+		item.NextRetry = time.Now()
+		q.items[item.ID] = item
+		replayed++
+	}
+	if replayed == 0 {
+		return 0, fmt.Errorf("notification not found")
+	}
+	if err := q.save(); err != nil {
+		return replayed, err
+	}
+	return replayed, q.writeDeadLetters(kept)
+}
+
+// Discard removes a pending or dead notification. An empty id clears the dead-letter log.
+func (q *RetryQueue) Discard(id string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, exists := q.items[id]; exists {
+		delete(q.items, id)
+		return 1, q.save()
+	}
+
+	dead, err := q.deadLetters()
+	if err != nil {
+		return 0, err
+	}
+	var kept []*FailedNotification
+	for _, item := range dead {
+		if id != "" && item.ID != id {
+			kept = append(kept, item)
+		}
+	}
+	removed := len(dead) - len(kept)
+	if removed == 0 && id != "" {
+		return 0, fmt.Errorf("notification not found")
+	}
+	return removed, q.writeDeadLetters(kept)
+}
+
+// DropTarget discards the pending retries of a target's batches and returns how
+// many domains they held
+func (q *RetryQueue) DropTarget(target string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	dropped := 0
+	for id, item := range q.items {
+		if item.Kind == retryBatch && item.Target == target {
+			dropped += len(item.Domains)
+			delete(q.items, id)
+		}
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+	return dropped, q.save()
+}
+
+// save writes the pending retries to disk. Caller must hold q.mu.
+func (q *RetryQueue) save() error {
+	if isDryRun() {
+		return nil
+	}
+	data, err := json.Marshal(q.items)
+	if err != nil {
+		return err
+	}
+
+	tempPath := q.filePath + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, q.filePath)
+}
+
+// handleFailedNotifications lists pending retries and dead letters, and replays or
+// discards them
+func (as *AdminServer) handleFailedNotifications(w http.ResponseWriter, r *http.Request) {
+	q := GetRetryQueue()
+
+	switch r.Method {
+	case http.MethodGet:
+		dead, err := q.deadLetters()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pending := q.Pending()
+		// Webhook URLs are credentials
+		for _, item := range append(append([]*FailedNotification(nil), pending...), dead...) {
+			item.URL = maskValue(item.URL)
+		}
+		if pending == nil {
+			pending = []*FailedNotification{}
+		}
+		if dead == nil {
+			dead = []*FailedNotification{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pending":      pending,
+			"dead_letters": dead,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			ID     string `json:"id"`     // Empty applies to every dead letter
+			Action string `json:"action"` // replay or discard
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		var count int
+		var err error
+		switch req.Action {
+		case "replay":
+			count, err = q.Replay(req.ID)
+		case "discard":
+			count, err = q.Discard(req.ID)
+		default:
+			http.Error(w, "action must be replay or discard", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		logger.Info("failed notifications updated via admin panel", "action", req.Action, "id", req.ID, "count", count)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"count":   count,
+		})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
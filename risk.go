@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RiskConfig sets the points behind each risk label, the thresholds of the built-in
+// labels, and custom label rules
+type RiskConfig struct {
+	Points             map[string]int `yaml:"points"`               // Label -> points, overriding defaultRiskPoints
+	HighFrequencyHits  int            `yaml:"high_frequency_hits"`  // More hits than this is high-frequency, default 50
+	HighFrequencyDays  int            `yaml:"high_frequency_days"`  // As are this many consecutive high-hit days, default 2
+	StatusAnomalyCodes int            `yaml:"status_anomaly_codes"` // Distinct recent status codes for status-anomaly, default 3
+	ResponseMinBytes   int            `yaml:"response_min_bytes"`   // Smaller responses are response-anomaly, default 100
+	ResponseMaxBytes   int            `yaml:"response_max_bytes"`   // As are larger ones, default 1000000
+	Rules              []RiskRule     `yaml:"rules"`
+}
+
+// RiskRule adds a label and points to domains matching every condition it sets.
+// Each condition matches when any of its values does.
+type RiskRule struct {
+	Label       string   `yaml:"label"`
+	Points      int      `yaml:"points"`
+	Issuers     []string `yaml:"issuers"`      // Substrings of the certificate issuer, case-insensitive
+	Keywords    []string `yaml:"keywords"`     // Substrings of the domain
+	StatusCodes []int    `yaml:"status_codes"` // Last HTTP status code
+	Ports       []int    `yaml:"ports"`        // Ports of the probed URL or its redirects, or found open by the port scan
+}
+
+// defaultRiskPoints are the points of the built-in labels
+var defaultRiskPoints = map[string]int{
+	"wildcard":             30,
+	"status-anomaly":       20,
+	"high-frequency":       25,
+	"takeover-candidate":   60,
+	"unexpected-issuer":    70,
+	"caa-violation":        80,
+	"unexpected-country":   50,
+	"exposed-service":      40,
+	"malicious-reputation": 80,
+	"issuer-change":        15,
+	"split-horizon":        25,
+	"geo-variance":         10,
+	"response-anomaly":     10,
+}
+
+var riskConfig *RiskConfig
+var riskMutex sync.Mutex
+
+// SetRiskConfig sets the risk scoring configuration
+func SetRiskConfig(cfg *RiskConfig) {
+	riskMutex.Lock()
+	defer riskMutex.Unlock()
+	if cfg.HighFrequencyHits <= 0 {
+		cfg.HighFrequencyHits = 50
+	}
+	if cfg.HighFrequencyDays <= 0 {
+		cfg.HighFrequencyDays = 2
+	}
+	if cfg.StatusAnomalyCodes <= 0 {
+		cfg.StatusAnomalyCodes = 3
+	}
+	if cfg.ResponseMinBytes <= 0 {
+		cfg.ResponseMinBytes = 100
+	}
+	if cfg.ResponseMaxBytes <= 0 {
+		cfg.ResponseMaxBytes = 1000000
+	}
+
+	rules := cfg.Rules[:0]
+	for _, rule := range cfg.Rules {
+		rule.Label = strings.TrimSpace(rule.Label)
+		if rule.Label == "" || !rule.hasConditions() {
+			logger.Warn("ignoring risk rule without a label or conditions", "label", rule.Label)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	cfg.Rules = rules
+	riskConfig = cfg
+}
+
+// GetRiskConfig returns the risk scoring configuration, with defaults if none was set
+func GetRiskConfig() *RiskConfig {
+	riskMutex.Lock()
+	defer riskMutex.Unlock()
+	if riskConfig == nil {
+		return &RiskConfig{HighFrequencyHits: 50, HighFrequencyDays: 2, StatusAnomalyCodes: 3, ResponseMinBytes: 100, ResponseMaxBytes: 1000000}
+	}
+	return riskConfig
+}
+
+// points returns the points of a label
+func (cfg *RiskConfig) points(label string) int {
+	if p, ok := cfg.Points[label]; ok {
+		return p
+	}
+	if label == "unexpected-country" {
+		if geo := GetGeoIPConfig(); geo != nil && geo.RiskPoints != nil {
+			return *geo.RiskPoints
+		}
+	}
+	return defaultRiskPoints[label]
+}
+
+// hasConditions reports whether a rule sets any condition
+func (rule RiskRule) hasConditions() bool {
+	return len(rule.Issuers) > 0 || len(rule.Keywords) > 0 || len(rule.StatusCodes) > 0 || len(rule.Ports) > 0
+}
+
+// matches reports whether a domain meets every condition of a rule
+func (rule RiskRule) matches(entry *DomainEntry) bool {
+	if len(rule.Issuers) > 0 && !containsAnyFold(entry.CertIssuer, rule.Issuers) {
+		return false
+	}
+	if len(rule.Keywords) > 0 && !containsAnyFold(entry.Domain, rule.Keywords) {
+		return false
+	}
+	if len(rule.StatusCodes) > 0 {
+		found := false
+		for _, code := range rule.StatusCodes {
+			if entry.HttpStatusCode == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.Ports) > 0 {
+		found := false
+		ports := probedPorts(entry.Probe)
+		if entry.PortScan != nil {
+			ports = append(ports, entry.PortScan.Open...)
+		}
+		for _, port := range ports {
+			for _, p := range rule.Ports {
+				if port == p {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsAnyFold reports whether s contains any of the values, ignoring case
+func containsAnyFold(s string, values []string) bool {
+	s = strings.ToLower(s)
+	for _, v := range values {
+		if v != "" && strings.Contains(s, strings.ToLower(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// probedPorts returns the ports of a probed URL and its redirects
+func probedPorts(probe *ProbeResult) []int {
+	if probe == nil {
+		return nil
+	}
+	var ports []int
+	for _, raw := range append([]string{probe.URL}, probe.RedirectChain...) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		port := u.Port()
+		switch {
+		case port != "":
+		case u.Scheme == "https":
+			port = "443"
+		case u.Scheme == "http":
+			port = "80"
+		default:
+			continue
+		}
+		if n, err := strconv.Atoi(port); err == nil {
+			ports = append(ports, n)
+		}
+	}
+	return ports
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScreenshotConfig holds headless Chrome screenshot settings
+type ScreenshotConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	ChromePath string `yaml:"chrome_path"` // Chrome/Chromium binary
+	OutputDir  string `yaml:"output_dir"`  // Defaults to <config dir>/screenshots
+	Timeout    int    `yaml:"timeout"`     // Seconds per capture
+	NoSandbox  bool   `yaml:"no_sandbox"`  // Run Chrome without its sandbox, only for containers where it can't start otherwise
+}
+
+// maxScreenshotAttachments caps images attached to one Discord message (10 embeds minus the batch embed)
+const maxScreenshotAttachments = 9
+
+var screenshotConfig *ScreenshotConfig
+var screenshotMutex sync.Mutex
+
+// SetScreenshotConfig sets the screenshot configuration
+func SetScreenshotConfig(cfg *ScreenshotConfig) {
+	screenshotMutex.Lock()
+	defer screenshotMutex.Unlock()
+	screenshotConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.ChromePath == "" {
+		cfg.ChromePath = "chromium"
+	}
+	if cfg.OutputDir == "" {
+		if configDir, err := getConfigDir(); err == nil {
+			cfg.OutputDir = filepath.Join(configDir, "screenshots")
+		}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30
+	}
+}
+
+// GetScreenshotConfig returns the screenshot configuration
+func GetScreenshotConfig() *ScreenshotConfig {
+	screenshotMutex.Lock()
+	defer screenshotMutex.Unlock()
+	return screenshotConfig
+}
+
+// isScreenshotEnabled reports whether screenshots are configured and enabled
+func isScreenshotEnabled() bool {
+	cfg := GetScreenshotConfig()
+	return cfg != nil && cfg.Enabled
+}
+
+// CaptureScreenshot renders a domain in headless Chrome and records the image path
+func CaptureScreenshot(domain string) (string, error) {
+	cfg := GetScreenshotConfig()
+	if cfg == nil || !cfg.Enabled {
+		return "", fmt.Errorf("screenshots not enabled")
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create screenshot directory: %w", err)
+	}
+
+	// Prefer the URL the probe landed on, it already knows which scheme answers
+	url := "https://" + domain
+	if entry := GetDomainTracker().GetDomainInfo(domain); entry != nil && entry.Probe != nil {
+		url = entry.Probe.URL
+	}
+
+	name := fmt.Sprintf("%s_%d.png", strings.ReplaceAll(domain, ".", "_"), time.Now().Unix())
+	outputFile := filepath.Join(cfg.OutputDir, name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout)*time.Second)
+	defer cancel()
+
+	if err := checkPublicHost(ctx, domain); err != nil {
+		return "", err
+	}
+	proxy, err := startScreenshotProxy()
+	if err != nil {
+		return "", fmt.Errorf("failed to start screenshot proxy: %w", err)
+	}
+	defer proxy.Close()
+
+	args := []string{
+		"--headless",
+		"--disable-gpu",
+		"--hide-scrollbars",
+		"--ignore-certificate-errors",
+		"--window-size=1280,800",
+		// Every request, loopback included, goes through the proxy, which only
+		// connects to public addresses
+		"--proxy-server=http://" + proxy.Addr().String(),
+		"--proxy-bypass-list=<-loopback>",
+		"--disable-quic",
+		"--force-webrtc-ip-handling-policy=disable_non_proxied_udp",
+		"--screenshot=" + outputFile,
+	}
+	if cfg.NoSandbox {
+		args = append(args, "--no-sandbox")
+	}
+	cmd := exec.CommandContext(ctx, cfg.ChromePath, append(args, url)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("chrome failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	if _, err := os.Stat(outputFile); err != nil {
+		return "", fmt.Errorf("screenshot not written: %w", err)
+	}
+
+	ref := storeArtifact(artifactScreenshots, outputFile, "image/png")
+	GetDomainTracker().RecordDomainScreenshot(domain, ref)
+	logger.Debug("screenshot captured", "domain", domain, "path", ref)
+	return ref, nil
+}
+
+// startScreenshotProxy serves an HTTP proxy on a loopback port for one capture, so
+// a page or its redirects can't make Chrome load internal addresses
+func startScreenshotProxy() (net.Listener, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(ln, http.HandlerFunc(serveScreenshotProxy))
+	return ln, nil
+}
+
+// screenshotTransport forwards plain HTTP requests of the screenshot proxy
+var screenshotTransport = &http.Transport{
+	DialContext:       publicDialer.DialContext,
+	DisableKeepAlives: true,
+}
+
+// serveScreenshotProxy tunnels CONNECT and forwards plain HTTP requests, dialing
+// only public addresses
+func serveScreenshotProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		if !r.URL.IsAbs() {
+			http.Error(w, "not a proxy request", http.StatusBadRequest)
+			return
+		}
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Connection")
+		r.Header.Del("Proxy-Authorization")
+		resp, err := screenshotTransport.RoundTrip(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for key, values := range resp.Header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	target, err := publicDialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		target.Close()
+		http.Error(w, "tunnel not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		target.Close()
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	go func() {
+		io.Copy(target, buf)
+		target.Close()
+		conn.Close()
+	}()
+	io.Copy(conn, target)
+	target.Close()
+	conn.Close()
+}
+
+// batchScreenshots returns the recorded screenshot paths for a batch, keyed by domain
+func batchScreenshots(domains []string) map[string]string {
+	dt := GetDomainTracker()
+	screenshots := make(map[string]string)
+	for _, domain := range domains {
+		if len(screenshots) >= maxScreenshotAttachments {
+			break
+		}
+		entry := dt.GetDomainInfo(domain)
+		if entry == nil || entry.Screenshot == "" {
+			continue
+		}
+		if artifactExists(entry.Screenshot) {
+			screenshots[domain] = entry.Screenshot
+		}
+	}
+	return screenshots
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// secretCommandTimeout bounds a command printing a secret, e.g. one waiting on a vault login
+const secretCommandTimeout = 30 * time.Second
+
+// loadSecret returns the value of a config key from its secret_files or
+// secret_commands entry, and whether it has one
+func loadSecret(cfg *Config, key string) (string, bool, error) {
+	if path := strings.TrimSpace(cfg.SecretFiles[key]); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("secret_files.%s: %w", key, err)
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	if command := strings.TrimSpace(cfg.SecretCommands[key]); command != "" {
+		value, err := runSecretCommand(command)
+		if err != nil {
+			return "", false, fmt.Errorf("secret_commands.%s: %w", key, err)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+// runSecretCommand runs a command through the shell and returns its trimmed output
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	value := strings.TrimSpace(stdout.String())
+	if value == "" {
+		return "", fmt.Errorf("command printed nothing")
+	}
+	return value, nil
+}
+
+// unknownSecretKeys returns the secret_files and secret_commands keys that name no
+// supported config value
+func unknownSecretKeys(cfg *Config) []string {
+	known := make(map[string]bool, len(configOverrides))
+	for _, o := range configOverrides {
+		known[o.key] = true
+	}
+	var unknown []string
+	for _, m := range []struct {
+		name    string
+		sources map[string]string
+	}{{"secret_files", cfg.SecretFiles}, {"secret_commands", cfg.SecretCommands}} {
+		for key := range m.sources {
+			if !known[key] {
+				unknown = append(unknown, m.name+"."+key)
+			}
+		}
+	}
+	return unknown
+}
@@ -1,30 +1,47 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 )
 
 const (
-	batchDelay    = 5 * time.Second
-	maxBatchSize  = 25
-	rateLimitWait = 2 * time.Second
-	maxRetries    = 3
+	batchDelay        = 5 * time.Second
+	rateLimitWait     = 2 * time.Second
+	maxRetries        = 3
+	maxPendingDomains = 5000
 )
 
 type notificationBuffer struct {
-	mu      sync.Mutex
-	pending map[string][]string
-	timers  map[string]*time.Timer
+	mu         sync.Mutex
+	pending    map[string][]string
+	chars      map[string]int // Estimated message length of each pending batch
+	timers     map[string]*time.Timer
+	depth      int  // Total domains across all pending batches
+	overflowed int  // Domains spilled to disk since startup
+	requeued   int  // Domains queued for retry after failed sends
+	draining   bool // Overflow file is being restored
+	closing    bool // Shutting down, new domains go straight to the overflow file
+}
+
+// overflowRecord is a single notification spilled to the overflow file
+type overflowRecord struct {
+	Target string `json:"target"`
+	Domain string `json:"domain"`
 }
 
 var notifier = &notificationBuffer{
 	pending: make(map[string][]string),
+	chars:   make(map[string]int),
 	timers:  make(map[string]*time.Timer),
 }
 
@@ -36,117 +53,423 @@ func (n *notificationBuffer) add(target, domain string) {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
+	// Buffer is full or shutting down, spill to disk rather than growing without bound
+	if n.depth >= pendingDomainLimit() || n.closing {
+		n.overflow(target, []string{domain})
+		return
+	}
+
 	n.pending[target] = append(n.pending[target], domain)
+	n.chars[target] += batchLineLength(domain)
+	n.depth++
 
-	if len(n.pending[target]) >= maxBatchSize {
-		domains := n.pending[target]
-		delete(n.pending, target)
+	// Send as soon as the batch fills a message
+	if n.chars[target] >= maxBatchChars {
 		if timer, exists := n.timers[target]; exists {
 			timer.Stop()
 			delete(n.timers, target)
 		}
+		domains := n.takeBatch(target)
 		go n.send(target, domains)
 		return
 	}
 
+	n.schedule(target, batchDelay)
+}
+
+func (n *notificationBuffer) flush(target string) {
+	n.mu.Lock()
+	delete(n.timers, target)
+	if len(n.pending[target]) == 0 {
+		n.mu.Unlock()
+		return
+	}
+	domains := n.takeBatch(target)
+	n.mu.Unlock()
+
+	n.send(target, domains)
+}
+
+// schedule arms a flush timer for a target if one isn't already running.
+// Caller must hold n.mu.
+func (n *notificationBuffer) schedule(target string, delay time.Duration) {
 	if _, exists := n.timers[target]; !exists {
-		n.timers[target] = time.AfterFunc(batchDelay, func() {
+		n.timers[target] = time.AfterFunc(delay, func() {
 			n.flush(target)
 		})
 	}
 }
 
-func (n *notificationBuffer) flush(target string) {
+// takeBatch removes as many domains as fit in one message from a target's
+// pending list, scheduling another flush if any remain. Caller must hold n.mu.
+func (n *notificationBuffer) takeBatch(target string) []string {
+	domains := n.pending[target]
+	batch := chunkByLength(domains, maxBatchChars, batchLineLength)[0]
+
+	if len(batch) < len(domains) {
+		batch = append([]string(nil), batch...)
+		n.pending[target] = domains[len(batch):]
+		for _, domain := range batch {
+			n.chars[target] -= batchLineLength(domain)
+		}
+		n.depth -= len(batch)
+		n.schedule(target, batchDelay)
+		return batch
+	}
+
+	delete(n.pending, target)
+	delete(n.chars, target)
+	n.depth -= len(domains)
+	return domains
+}
+
+// overflow appends domains to the on-disk overflow file. Caller must hold n.mu.
+func (n *notificationBuffer) overflow(target string, domains []string) {
+	path, err := notificationOverflowPath()
+	if err != nil {
+		logger.Error("notification buffer full, dropping domains", "target", target, "count", len(domains), "error", err)
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Error("notification buffer full, dropping domains", "target", target, "count", len(domains), "error", err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, domain := range domains {
+		if err := encoder.Encode(overflowRecord{Target: target, Domain: domain}); err != nil {
+			logger.Error("failed to write notification overflow", "error", err)
+			return
+		}
+		n.overflowed++
+	}
+
+	logger.Warn("notification buffer full, spilled to disk", "target", target, "count", len(domains), "path", path)
+}
+
+// drainOverflow restores spilled notifications once delivery is working again
+func (n *notificationBuffer) drainOverflow() {
 	n.mu.Lock()
-	domains, exists := n.pending[target]
-	if !exists || len(domains) == 0 {
+	if n.draining || n.closing {
 		n.mu.Unlock()
 		return
 	}
-	delete(n.pending, target)
-	delete(n.timers, target)
+	n.draining = true
 	n.mu.Unlock()
 
-	n.send(target, domains)
+	defer func() {
+		n.mu.Lock()
+		n.draining = false
+		n.mu.Unlock()
+	}()
+
+	path, err := notificationOverflowPath()
+	if err != nil {
+		return
+	}
+
+	// Move the file aside so new overflow during the drain starts a fresh file
+	drainPath := path + ".draining"
+	if err := os.Rename(path, drainPath); err != nil {
+		return
+	}
+
+	file, err := os.Open(drainPath)
+	if err != nil {
+		logger.Error("failed to open notification overflow", "error", err)
+		return
+	}
+
+	restored := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record overflowRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Domain == "" {
+			continue
+		}
+		n.add(record.Target, record.Domain)
+		restored++
+	}
+	file.Close()
+	os.Remove(drainPath)
+
+	if restored > 0 {
+		logger.Info("restored overflowed notifications", "count", restored)
+	}
+}
+
+// FlushAll sends every pending batch once before shutdown. Batches that fail or are
+// still unsent at the deadline are spilled to the overflow file for the next start.
+func (n *notificationBuffer) FlushAll(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+
+	n.mu.Lock()
+	n.closing = true
+	type batch struct {
+		target  string
+		domains []string
+	}
+	var batches []batch
+	for target := range n.pending {
+		for len(n.pending[target]) > 0 {
+			batches = append(batches, batch{target, n.takeBatch(target)})
+		}
+	}
+	n.stopTimers()
+	n.mu.Unlock()
+
+	if len(batches) > 0 {
+		logger.Info("flushing pending notifications", "batches", len(batches))
+	}
+	for i, b := range batches {
+		if time.Now().After(deadline) {
+			n.mu.Lock()
+			for _, rest := range batches[i:] {
+				n.overflow(rest.target, rest.domains)
+			}
+			n.mu.Unlock()
+			break
+		}
+		n.send(b.target, b.domains)
+	}
+
+	// Anything still pending is kept on disk for the next start
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.stopTimers()
+	for target, domains := range n.pending {
+		n.overflow(target, domains)
+	}
+	n.pending = make(map[string][]string)
+	n.chars = make(map[string]int)
+	n.depth = 0
+}
+
+// DropTarget discards pending and overflowed notifications for a target and
+// returns how many were removed
+func (n *notificationBuffer) DropTarget(target string) (int, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	dropped := len(n.pending[target])
+	n.depth -= dropped
+	delete(n.pending, target)
+	delete(n.chars, target)
+	if timer, exists := n.timers[target]; exists {
+		timer.Stop()
+		delete(n.timers, target)
+	}
+
+	path, err := notificationOverflowPath()
+	if err != nil {
+		return dropped, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return dropped, nil
+	}
+	if err != nil {
+		return dropped, err
+	}
+
+	var kept bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var record overflowRecord
+		if len(line) == 0 {
+			continue
+		}
+		if json.Unmarshal(line, &record) == nil && record.Target == target {
+			dropped++
+			continue
+		}
+		kept.Write(line)
+		kept.WriteByte('\n')
+	}
+	return dropped, os.WriteFile(path, kept.Bytes(), 0644)
+}
+
+// stopTimers cancels every scheduled flush. Caller must hold n.mu.
+func (n *notificationBuffer) stopTimers() {
+	for target, timer := range n.timers {
+		timer.Stop()
+		delete(n.timers, target)
+	}
+}
+
+// Depth returns buffer metrics for the admin panel
+func (n *notificationBuffer) Depth() (pending, targets, overflowed, requeued int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.depth, len(n.pending), n.overflowed, n.requeued
+}
+
+// notificationOverflowPath returns the path of the on-disk overflow file
+func notificationOverflowPath() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "notify_overflow.jsonl"), nil
 }
 
 func (n *notificationBuffer) send(target string, domains []string) {
-	if notifyDiscord && webhookURL != "" {
-		n.sendDiscord(target, domains)
+	// Every provider failed, keep the batch for a later retry
+	if !n.deliver(target, domains) {
+		n.mu.Lock()
+		n.requeued += len(domains)
+		n.mu.Unlock()
+		GetRetryQueue().AddBatch(target, domains)
 	}
+}
 
-	if notifyTelegram {
-		sendToTelegram(target, domains)
+// deliver sends a batch to the target's providers and reports whether any accepted
+// it, or none was asked
+func (n *notificationBuffer) deliver(target string, domains []string) bool {
+	attempted := false
+	delivered := false
+
+	providers := providersForBatch(target, domains)
+
+	if providers.discord {
+		attempted = true
+		if n.sendDiscord(target, domains) {
+			delivered = true
+		}
 	}
 
-	// Trigger enumeration if enabled
-	enumMutex.Lock()
-	enumEnabled := enumConfig != nil && enumConfig.EnableEnum
-	enumMutex.Unlock()
+	if providers.telegram {
+		attempted = true
+		if sendToTelegram(target, domains) {
+			delivered = true
+		}
+	}
+
+	if providers.ntfy {
+		attempted = true
+		if sendToNtfy(target, domains) {
+			delivered = true
+		}
+	}
 
-	if enumEnabled {
+	if attempted && !delivered {
+		return false
+	}
+
+	if delivered {
+		recordNotifyLatency(domains)
+		go n.drainOverflow()
+	}
+
+	// Trigger enumeration if enabled
+	if enumEnabledFor(target) {
 		for _, domain := range domains {
 			go triggerEnumeration(domain, target)
 		}
 	}
+	// Configured tools have their own triggers
+	for _, domain := range domains {
+		go RunTools(domain, target)
+	}
+	return true
 }
 
-func (n *notificationBuffer) sendDiscord(target string, domains []string) {
+func (n *notificationBuffer) sendDiscord(target string, domains []string) bool {
 	payload := buildDiscordPayload(target, domains)
+	webhook := discordWebhookForBatch(target, domains)
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("failed to marshal discord payload", "error", err)
-		return
+		return false
+	}
+	contentType := "application/json"
+
+	// Upload captured screenshots alongside the batch embed
+	if screenshots := batchScreenshots(domains); len(screenshots) > 0 {
+		body, multipartType, err := attachDiscordScreenshots(payload, screenshots)
+		if err != nil {
+			logger.Warn("failed to attach screenshots", "error", err)
+		} else {
+			jsonData = body
+			contentType = multipartType
+		}
+	}
+
+	// The bot posts to its channel unless the target has its own webhook
+	if isDiscordBotEnabled() && (webhook == "" || webhook == webhookURL) {
+		messageID, err := postDiscordBotMessage(GetDiscordBotConfig().ChannelID, jsonData, contentType)
+		if err != nil {
+			logger.Error("failed to send discord bot notification", "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("discord notification for %s: %v", target, err))
+			return false
+		}
+		discordDelivered(target, domains, messageID)
+		return true
 	}
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+		resp, err := discordPost(withWait(webhook), contentType, jsonData)
 		if err != nil {
 			logger.Error("failed to send discord notification", "error", err)
-			return
+			RecordError(errCategoryWebhook, fmt.Sprintf("discord notification for %s: %v", target, err))
+			return false
 		}
 
 		   switch resp.StatusCode {
 		   case http.StatusOK, http.StatusNoContent:
+			   messageID := decodeMessageID(resp.Body)
 			   resp.Body.Close()
-
-			   // Send to new domains webhook if configured
-			   if GetWebhookConfig() != nil && GetWebhookConfig().NewDomains != "" {
-				   for _, domain := range domains {
-					   sendNewDomainToWebhook(domain, extractRootDomain(domain))
-				   }
-			   }
-
-			   // Trigger enumeration after successful send (if enabled)
-			   enumMutex.Lock()
-			   enumEnabled := enumConfig != nil && enumConfig.EnableEnum
-			   enumMutex.Unlock()
-
-			   if enumEnabled {
-				   for _, domain := range domains {
-					   go triggerEnumeration(domain, target)
-				   }
-			   }
-			   return
+			   discordDelivered(target, domains, messageID)
+			   return true
 		case http.StatusTooManyRequests:
+			backoff(providerDiscord, discordBucket(webhook), resp)
 			resp.Body.Close()
-			logger.Warn("discord rate limited, waiting", "attempt", attempt+1)
-			time.Sleep(rateLimitWait * time.Duration(attempt+1))
 			continue
 		default:
 			resp.Body.Close()
 			logger.Warn("discord webhook error", "status", resp.StatusCode)
-			return
+			RecordError(errCategoryWebhook, fmt.Sprintf("discord notification for %s: status %d", target, resp.StatusCode))
+			return false
 		}
 	}
 
 	logger.Error("failed to send discord after retries", "target", target)
+	RecordError(errCategoryWebhook, fmt.Sprintf("discord notification for %s: rate limited after retries", target))
+	return false
+}
+
+// discordDelivered records a delivered Discord notification and starts its follow-ups
+func discordDelivered(target string, domains []string, messageID string) {
+	// Keep the delivery receipt so the message can be edited later
+	if messageID != "" {
+		GetDomainTracker().RecordDomainMessageID(domains, messageID)
+	}
+
+	// Send to new domains webhook if configured
+	if GetWebhookConfig() != nil && GetWebhookConfig().NewDomains != "" {
+		for _, domain := range domains {
+			sendNewDomainToWebhook(domain, extractRootDomain(domain))
+		}
+	}
+
+	// Trigger enumeration after successful send (if enabled)
+	if enumEnabledFor(target) {
+		for _, domain := range domains {
+			go triggerEnumeration(domain, target)
+		}
+	}
+	// Configured tools have their own triggers
+	for _, domain := range domains {
+		go RunTools(domain, target)
+	}
 }
 
-func sendToTelegram(target string, domains []string) {
+func sendToTelegram(target string, domains []string) bool {
 	if telegramToken == "" || telegramChatID == "" {
-		return
+		return false
 	}
 
 	text := buildTelegramMessage(target, domains)
@@ -161,36 +484,86 @@ func sendToTelegram(target string, domains []string) {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("failed to marshal telegram payload", "error", err)
-		return
+		return false
 	}
 
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", telegramToken)
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+		pace(providerTelegram, telegramChatID)
+		resp, err := outboundClient().Post(url, "application/json", bytes.NewBuffer(jsonData))
 		if err != nil {
 			logger.Error("failed to send telegram notification", "error", err)
-			return
+			RecordError(errCategoryWebhook, fmt.Sprintf("telegram notification for %s: %v", target, err))
+			return false
 		}
 
 		if resp.StatusCode == http.StatusOK {
 			resp.Body.Close()
-			return
+			return true
 		}
 
 		if resp.StatusCode == http.StatusTooManyRequests {
+			backoff(providerTelegram, telegramChatID, resp)
 			resp.Body.Close()
-			logger.Warn("telegram rate limited, waiting", "attempt", attempt+1)
-			time.Sleep(rateLimitWait * time.Duration(attempt+1))
 			continue
 		}
 
 		resp.Body.Close()
 		logger.Warn("telegram send error", "status", resp.StatusCode)
-		return
+		RecordError(errCategoryWebhook, fmt.Sprintf("telegram notification for %s: status %d", target, resp.StatusCode))
+		return false
 	}
 
 	logger.Error("failed to send telegram after retries", "target", target)
+	RecordError(errCategoryWebhook, fmt.Sprintf("telegram notification for %s: rate limited after retries", target))
+	return false
+}
+
+// sendTelegramDocument uploads a file to the Telegram chat with a caption
+func sendTelegramDocument(name string, data []byte, caption string) error {
+	if telegramToken == "" || telegramChatID == "" {
+		return fmt.Errorf("telegram not configured")
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("chat_id", telegramChatID)
+	writer.WriteField("caption", caption)
+	part, err := writer.CreateFormFile("document", name)
+	if err != nil {
+		return err
+	}
+	part.Write(data)
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendDocument", telegramToken)
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		pace(providerTelegram, telegramChatID)
+		resp, err := outboundClient().Post(url, writer.FormDataContentType(), bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			return nil
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			backoff(providerTelegram, telegramChatID, resp)
+			resp.Body.Close()
+			continue
+		}
+
+		resp.Body.Close()
+		return fmt.Errorf("telegram returned status %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("telegram rate limited after retries")
 }
 
 // triggerEnumeration starts enumeration based on domain type
@@ -202,6 +575,7 @@ func triggerEnumeration(domain, target string) {
 		_, err := RunPuredns(baseDomain, target)
 		if err != nil {
 			logger.Error("failed to start puredns", "domain", baseDomain, "error", err)
+			RecordError(errCategoryScan, fmt.Sprintf("puredns %s: %v", baseDomain, err))
 		}
 	} else {
 		// Regular subdomain - use feroxbuster
@@ -209,20 +583,59 @@ func triggerEnumeration(domain, target string) {
 		_, err := RunFeroxbuster(domain, target)
 		if err != nil {
 			logger.Error("failed to start feroxbuster", "domain", domain, "error", err)
+			RecordError(errCategoryScan, fmt.Sprintf("feroxbuster %s: %v", domain, err))
+		}
+
+		// Live subdomain - also run a vulnerability scan when nuclei is configured
+		if cfg, enabled := targetEnumConfig(target); enabled && cfg.NucleiPath != "" {
+			logger.Info("starting nuclei", "domain", domain)
+			if _, err := RunNuclei(domain, target); err != nil {
+				logger.Error("failed to start nuclei", "domain", domain, "error", err)
+				RecordError(errCategoryScan, fmt.Sprintf("nuclei %s: %v", domain, err))
+			}
 		}
 	}
 }
 // sendSNIDiscoveryNotification sends a Discord notification for SNI discoveries
 func sendSNIDiscoveryNotification(target string, newDomains []string) {
-	if !notifyDiscord || webhookURL == "" {
+	sendSNINotification(target, "🔍 SNI File Updated - New Domains Discovered", "new domains found", 9764863, newDomains) // Purple
+}
+
+// sendSNIRemovalNotification sends a Discord notification for domains that dropped out
+// of the SNI dataset, often decommissioned assets
+func sendSNIRemovalNotification(target string, removed []string) {
+	sendSNINotification(target, "📉 SNI File Updated - Domains No Longer Served", "domains dropped out, possibly decommissioned", 9807270, removed) // Grey
+}
+
+// sendSNINotification sends SNI domains to Discord, split into as many embeds as needed
+func sendSNINotification(target, title, noun string, color int, domains []string) {
+	if !notifyDiscord || !discordConfigured() {
 		return
 	}
 
+	chunks := chunkByLength(domains, maxBatchChars, resultLineLength)
+
+	for i, chunk := range chunks {
+		chunkTitle := title
+		if len(chunks) > 1 {
+			chunkTitle += fmt.Sprintf(" (Part %d/%d)", i+1, len(chunks))
+		}
+
+		payload := buildSNIPayload(target, chunkTitle, noun, color, chunk, len(domains))
+		if err := sendDiscordPayload(payload); err != nil {
+			logger.Error("failed to send SNI notification", "error", err)
+			return
+		}
+	}
+}
+
+// buildSNIPayload builds a Discord embed for one chunk of SNI domains
+func buildSNIPayload(target, title, noun string, color int, domains []string, total int) map[string]interface{} {
 	// Group by wildcard vs regular domains
 	wildcards := []string{}
 	regular := []string{}
 
-	for _, domain := range newDomains {
+	for _, domain := range domains {
 		if strings.HasPrefix(domain, "*.") {
 			wildcards = append(wildcards, domain)
 		} else {
@@ -233,7 +646,7 @@ func sendSNIDiscoveryNotification(target string, newDomains []string) {
 	description := fmt.Sprintf("**Target**: %s\n\n", target)
 
 	if len(wildcards) > 0 {
-		description += fmt.Sprintf("**Wildcards** (%d):\n```\n%s\n```\n\n", 
+		description += fmt.Sprintf("**Wildcards** (%d):\n```\n%s\n```\n\n",
 			len(wildcards), strings.Join(wildcards, "\n"))
 	}
 
@@ -242,34 +655,17 @@ func sendSNIDiscoveryNotification(target string, newDomains []string) {
 			len(regular), strings.Join(regular, "\n"))
 	}
 
-	description += fmt.Sprintf("*Total: %d new domains found*", len(newDomains))
+	description += fmt.Sprintf("*Total: %d %s*", total, noun)
 
-	payload := map[string]interface{}{
+	return map[string]interface{}{
 		"tts": false,
 		"embeds": []map[string]interface{}{
 			{
-				"title":       "🔍 SNI File Updated - New Domains Discovered",
+				"title":       title,
 				"description": description,
-				"color":       9764863, // Purple
+				"color":       color,
 				"timestamp":   time.Now().Format(time.RFC3339),
 			},
 		},
 	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		logger.Error("failed to marshal SNI notification", "error", err)
-		return
-	}
-
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		logger.Error("failed to send SNI notification", "error", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		logger.Error("discord returned error for SNI notification", "status", resp.StatusCode)
-	}
-}
\ No newline at end of file
+}
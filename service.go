@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// serviceName is the name crtmon is registered under with the service manager
+const serviceName = "crtmon"
+
+// serviceStop is closed when the service manager asks crtmon to shut down
+var serviceStop = make(chan struct{})
+
+// serviceDone is closed once shutdown has finished, so the service manager is only
+// told crtmon stopped after notifications are flushed and state is saved
+var serviceDone = make(chan struct{})
+
+// printServiceUsage prints the service subcommands
+func printServiceUsage() {
+	cmdStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+	argStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+
+	fmt.Println()
+	fmt.Printf("    %s service install %s\n", cmdStyle.Render("crtmon"), argStyle.Render("[-config provider.yaml] [-target example.com] [-notify discord]"))
+	fmt.Printf("    %s service start|stop|status|uninstall\n", cmdStyle.Render("crtmon"))
+	fmt.Println()
+}
+
+// serviceSuccess prints a success message and returns exit code 0
+func serviceSuccess(message string) int {
+	successStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	fmt.Printf("%s %s\n", successStyle.Render("✓"), dimStyle.Render(message))
+	return 0
+}
+
+// serviceError prints an error message and returns exit code 1
+func serviceError(message string) int {
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true)
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	fmt.Printf("%s %s\n", errorStyle.Render("✗"), dimStyle.Render(message))
+	return 1
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+// isWindowsService is always false outside Windows
+func isWindowsService() bool {
+	return false
+}
+
+// startServiceHandler is a no-op outside Windows
+func startServiceHandler() {}
+
+// finishService marks shutdown as complete; there is no service manager to wait for
+func finishService() {
+	close(serviceDone)
+}
+
+// runServiceCommand points non-Windows users at their native service manager
+func runServiceCommand(args []string) int {
+	return serviceError("crtmon service is only supported on Windows; on Linux use make install-vps (systemd)")
+}
@@ -0,0 +1,270 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// crtmonService reports to the Windows service manager and relays stop requests
+type crtmonService struct{}
+
+// isWindowsService reports whether crtmon was started by the service manager
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// startServiceHandler prepares the process for running without a console or user
+// profile, then hands control requests to the service manager in the background.
+func startServiceHandler() {
+	// Services run as LocalSystem, keep data next to the config file chosen at install time
+	if customConfigPath != "" {
+		configDirOverride = filepath.Dir(customConfigPath)
+	}
+
+	if configDir, err := getConfigDir(); err == nil {
+		os.MkdirAll(configDir, 0755)
+		os.Chdir(configDir) // Scan output is written to the working directory
+		if f, err := os.OpenFile(filepath.Join(configDir, "crtmon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			logger.SetOutput(redactingWriter{f})
+		}
+	}
+
+	go func() {
+		if err := svc.Run(serviceName, &crtmonService{}); err != nil {
+			logger.Error("windows service failed", "error", err)
+		}
+		close(serviceExited)
+	}()
+}
+
+// serviceExited is closed once the service manager has been told crtmon stopped
+var serviceExited = make(chan struct{})
+
+// finishService tells the service handler that shutdown is complete and waits for it to
+// report the service stopped before the process exits
+func finishService() {
+	close(serviceDone)
+	if !isWindowsService() {
+		return
+	}
+	select {
+	case <-serviceExited:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// Execute runs until the service manager asks crtmon to stop and shutdown has finished
+func (s *crtmonService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-serviceDone:
+			// Shut down by a signal rather than the service manager
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				close(serviceStop)
+				waitForShutdown(requests, status)
+				return false, 0
+			}
+		}
+	}
+}
+
+// waitForShutdown reports StopPending, with a new checkpoint every few seconds, until
+// shutdown has finished, so the service manager doesn't kill crtmon while it flushes
+func waitForShutdown(requests <-chan svc.ChangeRequest, status chan<- svc.Status) {
+	pending := svc.Status{State: svc.StopPending, WaitHint: 10000}
+	status <- pending
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-serviceDone:
+			return
+		case req := <-requests:
+			if req.Cmd == svc.Interrogate {
+				status <- pending
+			}
+		case <-ticker.C:
+			pending.CheckPoint++
+			status <- pending
+		}
+	}
+}
+
+// runServiceCommand handles crtmon service install|uninstall|start|stop|status
+func runServiceCommand(args []string) int {
+	if len(args) == 0 {
+		printServiceUsage()
+		return 1
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return serviceError("could not connect to the service manager (run as administrator): " + err.Error())
+	}
+	defer m.Disconnect()
+
+	switch args[0] {
+	case "install":
+		return installService(m, args[1:])
+	case "uninstall":
+		return withService(m, func(s *mgr.Service) error {
+			return s.Delete()
+		}, "service removed")
+	case "start":
+		return withService(m, func(s *mgr.Service) error {
+			return s.Start()
+		}, "service started, logs are written to crtmon.log next to the config file")
+	case "stop":
+		return withService(m, stopService, "service stopped")
+	case "status":
+		return withService(m, func(s *mgr.Service) error {
+			st, err := s.Query()
+			if err != nil {
+				return err
+			}
+			fmt.Println(describeServiceState(st.State))
+			return nil
+		}, "")
+	default:
+		printServiceUsage()
+		return 1
+	}
+}
+
+// installService registers crtmon with the given run flags, always with an absolute -config
+func installService(m *mgr.Mgr, runArgs []string) int {
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return serviceError("service already installed")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return serviceError("could not locate executable: " + err.Error())
+	}
+
+	runArgs, err = withAbsoluteConfig(runArgs)
+	if err != nil {
+		return serviceError(err.Error())
+	}
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "crtmon",
+		Description: "Certificate transparency monitor with admin panel",
+		StartType:   mgr.StartAutomatic,
+	}, runArgs...)
+	if err != nil {
+		return serviceError("could not install service: " + err.Error())
+	}
+	defer s.Close()
+
+	// Restart after crashes, backing off up to a minute
+	s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+
+	return serviceSuccess(fmt.Sprintf("service installed: %s %v", exe, runArgs))
+}
+
+// withAbsoluteConfig ensures the service is started with an absolute -config path,
+// since the service account has a different home directory than the installing user
+func withAbsoluteConfig(args []string) ([]string, error) {
+	for i, arg := range args {
+		switch {
+		case arg == "-config" && i+1 < len(args):
+			abs, err := filepath.Abs(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			args[i+1] = abs
+			return args, nil
+		case strings.HasPrefix(arg, "-config="):
+			abs, err := filepath.Abs(strings.TrimPrefix(arg, "-config="))
+			if err != nil {
+				return nil, err
+			}
+			args[i] = "-config=" + abs
+			return args, nil
+		}
+	}
+
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return append(args, "-config", configPath), nil
+}
+
+// withService opens the installed service, runs fn and prints message on success
+func withService(m *mgr.Mgr, fn func(*mgr.Service) error, message string) int {
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return serviceError("service not installed, run: crtmon service install")
+	}
+	defer s.Close()
+
+	if err := fn(s); err != nil {
+		return serviceError(err.Error())
+	}
+	if message == "" {
+		return 0
+	}
+	return serviceSuccess(message)
+}
+
+// stopService asks the service to stop and waits for it to exit
+func stopService(s *mgr.Service) error {
+	st, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for st.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for service to stop")
+		}
+		time.Sleep(500 * time.Millisecond)
+		if st, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// describeServiceState returns a readable service state
+func describeServiceState(state svc.State) string {
+	switch state {
+	case svc.Running:
+		return "running"
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "starting"
+	case svc.StopPending:
+		return "stopping"
+	case svc.Paused:
+		return "paused"
+	default:
+		return fmt.Sprintf("state %d", state)
+	}
+}
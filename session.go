@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Admin panel sessions are HS256 JWTs signed with a key kept in the config directory.
+// Logging out revokes the session ID server-side until the token would have expired.
+
+var (
+	errSessionInvalid = errors.New("invalid session token")
+	errSessionExpired = errors.New("session expired")
+	errSessionRevoked = errors.New("session revoked")
+)
+
+// jwtHeader is the fixed header of every session token
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// sessionClaims is the JWT payload of an admin session
+type sessionClaims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// sessionStore holds the signing key and revoked session IDs, persisted so a
+// restart neither logs everyone out nor brings revoked tokens back
+type sessionStore struct {
+	mu      sync.Mutex
+	path    string
+	Key     []byte           `json:"key"`
+	Revoked map[string]int64 `json:"revoked"` // Session ID -> expiry (unix)
+}
+
+var sessions *sessionStore
+
+// loadOrCreateSessions loads the session store, generating a signing key on first use
+func loadOrCreateSessions(path string) error {
+	s := &sessionStore{path: path, Revoked: make(map[string]int64)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return err
+		}
+		if s.Revoked == nil {
+			s.Revoked = make(map[string]int64)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(s.Key) < 32 {
+		s.Key = make([]byte, 32)
+		if _, err := rand.Read(s.Key); err != nil {
+			return err
+		}
+		if err := s.save(); err != nil {
+			return err
+		}
+		logger.Info("generated admin session signing key", "path", path)
+	}
+	sessions = s
+	return nil
+}
+
+// save writes the store, dropping revocations of tokens that have expired anyway.
+// Caller must hold s.mu or own s exclusively.
+func (s *sessionStore) save() error {
+	now := time.Now().Unix()
+	for id, exp := range s.Revoked {
+		if exp < now {
+			delete(s.Revoked, id)
+		}
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// sessionTTL returns how long a session token is valid
+func sessionTTL() time.Duration {
+	if cfg := GetAdminConfig(); cfg != nil && cfg.SessionHours > 0 {
+		return time.Duration(cfg.SessionHours) * time.Hour
+	}
+	return 12 * time.Hour
+}
+
+// issueSession signs a new session token and returns it with its expiry
+func issueSession() (string, time.Time, error) {
+	if sessions == nil {
+		return "", time.Time{}, errSessionInvalid
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expires := now.Add(sessionTTL())
+	payload, err := json.Marshal(sessionClaims{
+		ID:        hex.EncodeToString(id),
+		Subject:   "admin",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sessions.sign(unsigned), expires, nil
+}
+
+// sign returns the base64url HMAC-SHA256 of a token's header and payload
+func (s *sessionStore) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySession checks a token's signature, expiry and revocation
+func verifySession(token string) (*sessionClaims, error) {
+	if sessions == nil {
+		return nil, errSessionInvalid
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, errSessionInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(sessions.sign(parts[0]+"."+parts[1]))) {
+		return nil, errSessionInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errSessionInvalid
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ID == "" {
+		return nil, errSessionInvalid
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, errSessionExpired
+	}
+
+	sessions.mu.Lock()
+	_, revoked := sessions.Revoked[claims.ID]
+	sessions.mu.Unlock()
+	if revoked {
+		return nil, errSessionRevoked
+	}
+	return &claims, nil
+}
+
+// revokeSession invalidates a session until its expiry
+func revokeSession(claims *sessionClaims) error {
+	sessions.mu.Lock()
+	defer sessions.mu.Unlock()
+	sessions.Revoked[claims.ID] = claims.ExpiresAt
+	return sessions.save()
+}
+
+// requestToken returns the session token from the Authorization header. Tokens in the
+// query string would end up in proxy logs and browser history, so they aren't accepted.
+func requestToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
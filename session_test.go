@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSessions loads a session store in a temporary directory
+func newTestSessions(t *testing.T) {
+	t.Helper()
+	if err := loadOrCreateSessions(filepath.Join(t.TempDir(), "sessions.json")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sessions = nil })
+}
+
+// signedToken signs arbitrary claims with the store's key
+func signedToken(t *testing.T, claims sessionClaims) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + sessions.sign(unsigned)
+}
+
+func TestVerifySession(t *testing.T) {
+	newTestSessions(t)
+	token, _, err := issueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	now := time.Now().Unix()
+
+	forged, _ := json.Marshal(sessionClaims{ID: "forged", Subject: "admin", ExpiresAt: now + 3600})
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"valid", token, nil},
+		{"empty", "", errSessionInvalid},
+		{"two segments", parts[0] + "." + parts[1], errSessionInvalid},
+		{"payload swapped", parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2], errSessionInvalid},
+		{"signature altered", parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])), errSessionInvalid},
+		{"signature dropped", parts[0] + "." + parts[1] + ".", errSessionInvalid},
+		{"alg none", noneHeader + "." + parts[1] + ".", errSessionInvalid},
+		{"old hex token", strings.Repeat("ab", 32), errSessionInvalid},
+		{"expired", signedToken(t, sessionClaims{ID: "old", Subject: "admin", ExpiresAt: now - 1}), errSessionExpired},
+		{"expires now", signedToken(t, sessionClaims{ID: "edge", Subject: "admin", ExpiresAt: now}), errSessionExpired},
+		{"no session ID", signedToken(t, sessionClaims{Subject: "admin", ExpiresAt: now + 3600}), errSessionInvalid},
+	}
+	for _, tt := range tests {
+		_, err := verifySession(tt.token)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestSessionSignedByOtherKey(t *testing.T) {
+	newTestSessions(t)
+	token, _, err := issueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A new store generates a new key, as after deleting sessions.json
+	newTestSessions(t)
+	if _, err := verifySession(token); !errors.Is(err, errSessionInvalid) {
+		t.Errorf("got %v, want %v", err, errSessionInvalid)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	newTestSessions(t)
+	path := sessions.path
+	token, _, err := issueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := issueSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := verifySession(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := revokeSession(claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := verifySession(token); !errors.Is(err, errSessionRevoked) {
+		t.Errorf("revoked token: got %v, want %v", err, errSessionRevoked)
+	}
+	if _, err := verifySession(other); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+
+	// Revocations survive a restart
+	if err := loadOrCreateSessions(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifySession(token); !errors.Is(err, errSessionRevoked) {
+		t.Errorf("after reload: got %v, want %v", err, errSessionRevoked)
+	}
+}
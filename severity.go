@@ -0,0 +1,221 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Notification severity levels, lowest first
+var severityLevels = []string{"info", "low", "medium", "high", "critical"}
+
+// defaultSeverityThresholds are the lowest risk scores of each level above info
+var defaultSeverityThresholds = map[string]int{
+	"low":      20,
+	"medium":   40,
+	"high":     60,
+	"critical": 80,
+}
+
+// severityColors are the Discord embed colors of each level
+var severityColors = map[string]int{
+	"info":     2829617,  // Dark grey
+	"low":      3447003,  // Blue
+	"medium":   16776960, // Yellow
+	"high":     15105570, // Orange
+	"critical": 15158332, // Red
+}
+
+// severityEmoji marks each level in Discord titles and Telegram messages
+var severityEmoji = map[string]string{
+	"low":      "🔵",
+	"medium":   "🟡",
+	"high":     "🟠",
+	"critical": "🔴",
+}
+
+// SeverityConfig maps risk scores and labels to notification severity levels, and
+// what each level changes about a notification
+type SeverityConfig struct {
+	Thresholds   map[string]int      `yaml:"thresholds"`    // Level -> lowest risk score, default low 20, medium 40, high 60, critical 80
+	Labels       map[string]string   `yaml:"labels"`        // Risk label -> lowest level of domains carrying it
+	Mention      string              `yaml:"mention"`       // Discord mention for batches at mention_level or above, e.g. <@&role-id> or @here
+	MentionLevel string              `yaml:"mention_level"` // Default critical
+	Providers    map[string][]string `yaml:"providers"`     // Level -> providers, replacing the target's
+	Webhooks     map[string]string   `yaml:"webhooks"`      // Level -> Discord webhook, replacing the target's
+}
+
+var severityConfig *SeverityConfig
+var severityMutex sync.Mutex
+
+// SetSeverityConfig sets the severity configuration
+func SetSeverityConfig(cfg *SeverityConfig) {
+	severityMutex.Lock()
+	defer severityMutex.Unlock()
+
+	thresholds := make(map[string]int, len(defaultSeverityThresholds))
+	for level, score := range defaultSeverityThresholds {
+		thresholds[level] = score
+	}
+	for level, score := range cfg.Thresholds {
+		thresholds[strings.ToLower(level)] = score
+	}
+	cfg.Thresholds = thresholds
+
+	labels := make(map[string]string, len(cfg.Labels))
+	for label, level := range cfg.Labels {
+		labels[label] = strings.ToLower(strings.TrimSpace(level))
+	}
+	cfg.Labels = labels
+
+	providers := make(map[string][]string, len(cfg.Providers))
+	for level, list := range cfg.Providers {
+		var normalized []string
+		for _, provider := range list {
+			normalized = append(normalized, strings.ToLower(strings.TrimSpace(provider)))
+		}
+		providers[strings.ToLower(level)] = normalized
+	}
+	cfg.Providers = providers
+
+	webhooks := make(map[string]string, len(cfg.Webhooks))
+	for level, webhook := range cfg.Webhooks {
+		webhooks[strings.ToLower(level)] = strings.TrimSpace(webhook)
+	}
+	cfg.Webhooks = webhooks
+
+	cfg.Mention = strings.TrimSpace(cfg.Mention)
+	cfg.MentionLevel = strings.ToLower(strings.TrimSpace(cfg.MentionLevel))
+	if cfg.MentionLevel == "" {
+		cfg.MentionLevel = "critical"
+	}
+	severityConfig = cfg
+}
+
+// GetSeverityConfig returns the severity configuration, with defaults if none was set
+func GetSeverityConfig() *SeverityConfig {
+	severityMutex.Lock()
+	defer severityMutex.Unlock()
+	if severityConfig == nil {
+		return &SeverityConfig{Thresholds: defaultSeverityThresholds, MentionLevel: "critical"}
+	}
+	return severityConfig
+}
+
+// severityRank returns the position of a level, or -1 for an unknown level
+func severityRank(level string) int {
+	for i, l := range severityLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+// domainSeverity returns the level of a tracked domain: the highest whose threshold
+// its risk score reaches, raised by the levels of its labels
+func domainSeverity(entry *DomainEntry) string {
+	cfg := GetSeverityConfig()
+	level := "info"
+	for _, l := range severityLevels[1:] {
+		if threshold, ok := cfg.Thresholds[l]; ok && entry.RiskScore >= threshold && severityRank(l) > severityRank(level) {
+			level = l
+		}
+	}
+	for _, label := range entry.RiskLabels {
+		if l := cfg.Labels[label]; severityRank(l) > severityRank(level) {
+			level = l
+		}
+	}
+	return level
+}
+
+// batchSeverity returns the highest level among a batch of domains
+func batchSeverity(domains []string) string {
+	dt := GetDomainTracker()
+	level := "info"
+	for _, domain := range domains {
+		if entry := dt.GetDomainInfo(domain); entry != nil {
+			if l := domainSeverity(entry); severityRank(l) > severityRank(level) {
+				level = l
+			}
+		}
+	}
+	return level
+}
+
+// severityMention returns the Discord mention for a level, or ""
+func severityMention(level string) string {
+	cfg := GetSeverityConfig()
+	if cfg.Mention == "" || severityRank(level) < severityRank(cfg.MentionLevel) {
+		return ""
+	}
+	return cfg.Mention
+}
+
+// severityTitle prefixes a notification title with the level, except for info
+func severityTitle(level, title string) string {
+	if level == "info" {
+		return title
+	}
+	return severityEmoji[level] + " " + strings.ToUpper(level) + " · " + title
+}
+
+// providersForBatch returns the providers a batch goes to: those of its level when
+// severity.providers lists it, otherwise the target's
+func providersForBatch(target string, domains []string) targetProviders {
+	routed, ok := GetSeverityConfig().Providers[batchSeverity(domains)]
+	if !ok {
+		return providersFor(target)
+	}
+	return parseProviders(routed).available(target)
+}
+
+// discordWebhookForBatch returns the Discord webhook of a batch: its level's, or the
+// target's
+func discordWebhookForBatch(target string, domains []string) string {
+	if webhook := GetSeverityConfig().Webhooks[batchSeverity(domains)]; webhook != "" {
+		return webhook
+	}
+	return discordWebhookFor(target)
+}
+
+// checkSeverityConfig reports unknown levels and providers in the severity section
+func checkSeverityConfig(cfg SeverityConfig) []doctorCheck {
+	known := func(level string) bool {
+		return severityRank(strings.ToLower(strings.TrimSpace(level))) >= 0
+	}
+	var problems []string
+	for level := range cfg.Thresholds {
+		if !known(level) || strings.EqualFold(level, "info") {
+			problems = append(problems, "thresholds: unknown level "+level)
+		}
+	}
+	for label, level := range cfg.Labels {
+		if !known(level) {
+			problems = append(problems, "labels."+label+": unknown level "+level)
+		}
+	}
+	if cfg.MentionLevel != "" && !known(cfg.MentionLevel) {
+		problems = append(problems, "mention_level: unknown level "+cfg.MentionLevel)
+	}
+	for level, providers := range cfg.Providers {
+		if !known(level) {
+			problems = append(problems, "providers: unknown level "+level)
+		} else if err := validateProfileNotify(providers); err != nil {
+			problems = append(problems, "providers."+level+": "+err.Error())
+		}
+	}
+	for level := range cfg.Webhooks {
+		if !known(level) {
+			problems = append(problems, "webhooks: unknown level "+level)
+		}
+	}
+	sort.Strings(problems)
+
+	var checks []doctorCheck
+	for _, problem := range problems {
+		checks = append(checks, doctorCheck{"severity", doctorFail, problem})
+	}
+	return checks
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShodanConfig enriches resolved domains with what Shodan has seen on their addresses
+type ShodanConfig struct {
+	Enabled      bool           `yaml:"enabled"`
+	APIKey       string         `yaml:"api_key"`
+	CacheHours   int            `yaml:"cache_hours"`   // Reuse an address's data for this long, default 24
+	NotablePorts map[int]string `yaml:"notable_ports"` // Port -> service flagged in notifications, added to the built-in ones
+}
+
+// ShodanSummary is what Shodan knows about a domain's addresses
+type ShodanSummary struct {
+	Ports     []int           `json:"ports,omitempty"`
+	Services  []ShodanService `json:"services,omitempty"`
+	Vulns     []string        `json:"vulns,omitempty"`
+	Notable   []string        `json:"notable,omitempty"` // Exposed services, e.g. "RDP on 203.0.113.7:3389"
+	CheckedAt time.Time       `json:"checked_at"`
+}
+
+// ShodanService is one service Shodan found listening on an address
+type ShodanService struct {
+	Addr        string   `json:"addr"`
+	Port        int      `json:"port"`
+	Transport   string   `json:"transport,omitempty"`
+	Product     string   `json:"product,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Banner      string   `json:"banner,omitempty"`       // First line of the banner
+	TLSSubject  string   `json:"tls_subject,omitempty"`  // Common name of the certificate served
+	TLSIssuer   string   `json:"tls_issuer,omitempty"`   // Common name of its issuer
+	TLSExpires  string   `json:"tls_expires,omitempty"`  // YYYY-MM-DD
+	TLSVersions []string `json:"tls_versions,omitempty"` // Protocol versions offered
+}
+
+// shodanHost is the part of a /shodan/host response crtmon reads
+type shodanHost struct {
+	Ports []int    `json:"ports"`
+	Vulns []string `json:"vulns"`
+	Data  []struct {
+		Port      int    `json:"port"`
+		Transport string `json:"transport"`
+		Product   string `json:"product"`
+		Version   string `json:"version"`
+		Data      string `json:"data"`
+		SSL       *struct {
+			Cert struct {
+				Subject map[string]string `json:"subject"`
+				Issuer  map[string]string `json:"issuer"`
+				Expires string            `json:"expires"`
+			} `json:"cert"`
+			Versions []string `json:"versions"`
+		} `json:"ssl"`
+	} `json:"data"`
+}
+
+// shodanCacheEntry is a host response, nil when Shodan has no data on the address
+type shodanCacheEntry struct {
+	host    *shodanHost
+	fetched time.Time
+}
+
+// defaultNotablePorts are services that should rarely face the internet
+var defaultNotablePorts = map[int]string{
+	23:    "Telnet",
+	445:   "SMB",
+	1433:  "MSSQL",
+	2375:  "Docker API",
+	2379:  "etcd",
+	3306:  "MySQL",
+	3389:  "RDP",
+	5432:  "PostgreSQL",
+	5601:  "Kibana",
+	5900:  "VNC",
+	5984:  "CouchDB",
+	6379:  "Redis",
+	9200:  "Elasticsearch",
+	10250: "Kubelet",
+	11211: "Memcached",
+	27017: "MongoDB",
+}
+
+// shodanAPIBase is the Shodan REST API
+const shodanAPIBase = "https://api.shodan.io"
+
+// shodanInterval spaces requests under Shodan's limit of one per second
+const shodanInterval = time.Second
+
+// maxShodanBanner caps the banner line kept per service
+const maxShodanBanner = 120
+
+var shodanConfig *ShodanConfig
+var shodanMutex sync.Mutex
+var shodanCache = make(map[string]shodanCacheEntry)
+var shodanNext time.Time
+
+// SetShodanConfig sets the Shodan enrichment configuration
+func SetShodanConfig(cfg *ShodanConfig) {
+	shodanMutex.Lock()
+	defer shodanMutex.Unlock()
+	cfg.APIKey = strings.TrimSpace(cfg.APIKey)
+	if cfg.CacheHours <= 0 {
+		cfg.CacheHours = 24
+	}
+	shodanConfig = cfg
+}
+
+// GetShodanConfig returns the Shodan enrichment configuration
+func GetShodanConfig() *ShodanConfig {
+	shodanMutex.Lock()
+	defer shodanMutex.Unlock()
+	return shodanConfig
+}
+
+// isShodanEnabled reports whether resolved domains are looked up in Shodan
+func isShodanEnabled() bool {
+	cfg := GetShodanConfig()
+	return cfg != nil && cfg.Enabled && cfg.APIKey != ""
+}
+
+// LookupShodan looks up the addresses a domain resolved to and records the summary
+func LookupShodan(domain string) *ShodanSummary {
+	cfg := GetShodanConfig()
+	if cfg == nil || !cfg.Enabled || cfg.APIKey == "" {
+		return nil
+	}
+	dt := GetDomainTracker()
+	entry := dt.GetDomainInfo(domain)
+	if entry == nil || len(entry.Addrs) == 0 {
+		return nil
+	}
+
+	hosts := make(map[string]*shodanHost)
+	for _, addr := range entry.Addrs {
+		host, err := shodanHostInfo(cfg, addr)
+		if err != nil {
+			logger.Warn("shodan lookup failed", "domain", domain, "addr", addr, "error", err)
+			continue
+		}
+		if host != nil {
+			hosts[addr] = host
+		}
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	summary := summarizeShodan(hosts, notablePorts(cfg))
+	dt.RecordDomainShodan(domain, summary)
+	logger.Debug("shodan lookup complete", "domain", domain, "ports", summary.Ports, "notable", summary.Notable)
+	return summary
+}
+
+// notablePorts returns the built-in notable ports with the configured ones added
+func notablePorts(cfg *ShodanConfig) map[int]string {
+	ports := make(map[int]string, len(defaultNotablePorts)+len(cfg.NotablePorts))
+	for port, name := range defaultNotablePorts {
+		ports[port] = name
+	}
+	for port, name := range cfg.NotablePorts {
+		ports[port] = name
+	}
+	return ports
+}
+
+// shodanHostInfo returns the cached or fetched data on an address
+func shodanHostInfo(cfg *ShodanConfig, addr string) (*shodanHost, error) {
+	ttl := time.Duration(cfg.CacheHours) * time.Hour
+
+	shodanMutex.Lock()
+	if cached, ok := shodanCache[addr]; ok && time.Since(cached.fetched) < ttl {
+		shodanMutex.Unlock()
+		return cached.host, nil
+	}
+	// Take the next request slot while holding the lock
+	now := time.Now()
+	wait := shodanNext.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	shodanNext = now.Add(wait + shodanInterval)
+	shodanMutex.Unlock()
+	time.Sleep(wait)
+
+	host, err := fetchShodanHost(cfg.APIKey, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	shodanMutex.Lock()
+	defer shodanMutex.Unlock()
+	for a, cached := range shodanCache {
+		if time.Since(cached.fetched) >= ttl {
+			delete(shodanCache, a)
+		}
+	}
+	shodanCache[addr] = shodanCacheEntry{host: host, fetched: time.Now()}
+	return host, nil
+}
+
+// fetchShodanHost requests Shodan's data on an address, nil when it has none
+func fetchShodanHost(apiKey, addr string) (*shodanHost, error) {
+	endpoint := fmt.Sprintf("%s/shodan/host/%s?key=%s", shodanAPIBase, url.PathEscape(addr), url.QueryEscape(apiKey))
+	resp, err := outboundClient().Get(endpoint)
+	if err != nil {
+		// Drop the URL from the error, it holds the key
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("API key rejected (HTTP %d)", resp.StatusCode)
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited")
+	default:
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var host shodanHost
+	if err := json.NewDecoder(resp.Body).Decode(&host); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &host, nil
+}
+
+// summarizeShodan merges the data on a domain's addresses into one summary
+func summarizeShodan(hosts map[string]*shodanHost, notable map[int]string) *ShodanSummary {
+	summary := &ShodanSummary{CheckedAt: time.Now()}
+	ports := make(map[int]bool)
+	vulns := make(map[string]bool)
+
+	addrs := make([]string, 0, len(hosts))
+	for addr := range hosts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	for _, addr := range addrs {
+		host := hosts[addr]
+		for _, port := range host.Ports {
+			ports[port] = true
+		}
+		for _, vuln := range host.Vulns {
+			vulns[vuln] = true
+		}
+		for _, data := range host.Data {
+			service := ShodanService{
+				Addr:      addr,
+				Port:      data.Port,
+				Transport: data.Transport,
+				Product:   data.Product,
+				Version:   data.Version,
+				Banner:    firstBannerLine(data.Data),
+			}
+			if data.SSL != nil {
+				service.TLSSubject = data.SSL.Cert.Subject["CN"]
+				service.TLSIssuer = data.SSL.Cert.Issuer["CN"]
+				if expires, err := time.Parse("20060102150405Z", data.SSL.Cert.Expires); err == nil {
+					service.TLSExpires = expires.Format("2006-01-02")
+				}
+				// Shodan lists versions it found disabled with a leading "-"
+				for _, v := range data.SSL.Versions {
+					if !strings.HasPrefix(v, "-") {
+						service.TLSVersions = append(service.TLSVersions, v)
+					}
+				}
+			}
+			ports[data.Port] = true
+			summary.Services = append(summary.Services, service)
+
+			if name := notableService(data.Port, data.Product, notable); name != "" {
+				summary.Notable = append(summary.Notable, fmt.Sprintf("%s on %s", name, net.JoinHostPort(addr, strconv.Itoa(data.Port))))
+			}
+		}
+	}
+
+	for port := range ports {
+		summary.Ports = append(summary.Ports, port)
+	}
+	sort.Ints(summary.Ports)
+	for vuln := range vulns {
+		summary.Vulns = append(summary.Vulns, vuln)
+	}
+	sort.Strings(summary.Vulns)
+	return summary
+}
+
+// notableService names the exposed service on a port, matched by port or by a
+// product naming a notable service, e.g. Elasticsearch moved to port 80
+func notableService(port int, product string, notable map[int]string) string {
+	if name, ok := notable[port]; ok {
+		return name
+	}
+	for _, name := range notable {
+		if product != "" && strings.Contains(strings.ToLower(product), strings.ToLower(name)) {
+			return name
+		}
+	}
+	return ""
+}
+
+// firstBannerLine returns the first line of a banner, shortened for display
+func firstBannerLine(banner string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(banner), "\n")
+	line = strings.TrimSpace(line)
+	if len(line) > maxShodanBanner {
+		line = line[:maxShodanBanner] + "…"
+	}
+	return line
+}
+
+// batchExposures lists the notable services of a batch of domains, within a character budget
+func batchExposures(domains []string, budget int) string {
+	dt := GetDomainTracker()
+	var lines strings.Builder
+	for _, domain := range domains {
+		entry := dt.GetDomainInfo(domain)
+		if entry == nil || entry.Shodan == nil || len(entry.Shodan.Notable) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("%s: %s\n", domain, strings.Join(entry.Shodan.Notable, ", "))
+		if lines.Len()+len(line) > budget {
+			break
+		}
+		lines.WriteString(line)
+	}
+	return strings.TrimSuffix(lines.String(), "\n")
+}
+
+// checkShodanConfig reports a Shodan section that cannot work as configured
+func checkShodanConfig(cfg ShodanConfig) []doctorCheck {
+	var checks []doctorCheck
+	if cfg.Enabled && strings.TrimSpace(cfg.APIKey) == "" {
+		checks = append(checks, doctorCheck{"shodan", doctorFail, "api_key is required when enabled"})
+	}
+	for port := range cfg.NotablePorts {
+		if port <= 0 || port > 65535 {
+			checks = append(checks, doctorCheck{"shodan", doctorFail, fmt.Sprintf("notable_ports: %d is not a port", port)})
+		}
+	}
+	return checks
+}
+
+// checkShodanAPI verifies the API key when Shodan enrichment is enabled
+func checkShodanAPI(cfg *Config) []doctorCheck {
+	key := strings.TrimSpace(cfg.Shodan.APIKey)
+	if !cfg.Shodan.Enabled || key == "" {
+		return nil
+	}
+	return []doctorCheck{checkHTTP("shodan api", shodanAPIBase+"/api-info?key="+url.QueryEscape(key), nil)}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,6 +11,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,10 +19,14 @@ import (
 type SNIManager struct {
 	sniFilePath      string
 	lastUpdateFile   string
-	previousResultsFile string
 	mu               sync.RWMutex
+	refreshing       atomic.Bool // A refresh or the recheck after it is running
+	demand           chan string // Targets waiting for an on-demand search
 }
 
+// errSNIRefreshRunning is returned when a refresh is requested while one runs
+var errSNIRefreshRunning = errors.New("an SNI refresh is already running")
+
 var sniManager *SNIManager
 
 // InitSNIManager initializes the SNI manager
@@ -27,8 +34,9 @@ func InitSNIManager(sniPath string) {
 	sniManager = &SNIManager{
 		sniFilePath:      sniPath,
 		lastUpdateFile:   sniPath + ".lastupdate",
-		previousResultsFile: sniPath + ".previous",
+		demand:           make(chan string, sniDemandQueueSize),
 	}
+	go sniManager.runDemandSearches()
 	
 	// Start monthly refresh scheduler
 	go sniManager.startMonthlyScheduler()
@@ -40,15 +48,6 @@ func GetSNIManager() *SNIManager {
 	return sniManager
 }
 
-// SNI source URLs
-var sniSources = []string{
-	"https://kaeferjaeger.gay/sni-ip-ranges/amazon/ipv4_merged_sni.txt",
-	"https://kaeferjaeger.gay/sni-ip-ranges/google/ipv4_merged_sni.txt",
-	"https://kaeferjaeger.gay/sni-ip-ranges/digitalocean/ipv4_merged_sni.txt",
-	"https://kaeferjaeger.gay/sni-ip-ranges/microsoft/ipv4_merged_sni.txt",
-	"https://kaeferjaeger.gay/sni-ip-ranges/oracle/ipv4_merged_sni.txt",
-}
-
 // startMonthlyScheduler starts the monthly SNI file refresh scheduler
 func (sm *SNIManager) startMonthlyScheduler() {
 	// Check if we should update on startup
@@ -56,6 +55,8 @@ func (sm *SNIManager) startMonthlyScheduler() {
 	if shouldUpdate {
 		logger.Info("SNI file update due - refreshing on startup")
 		sm.RefreshSNIFiles()
+	} else {
+		sm.ensureIndex()
 	}
 	
 	// Schedule monthly updates
@@ -81,36 +82,150 @@ func (sm *SNIManager) shouldUpdateSNIFile() bool {
 		return true
 	}
 	
-	// Check if last update was more than 30 days ago
-	return time.Since(lastUpdate.ModTime()) > 30*24*time.Hour
+	// Check if the last update is older than the refresh interval
+	return time.Since(lastUpdate.ModTime()) > time.Duration(GetSNIConfig().CheckIntervalDays)*24*time.Hour
 }
 
-// RefreshSNIFiles downloads all SNI files and refreshes sni.txt
+// RefreshSNIFiles fetches the enabled SNI sources and, when the data changed,
+// notifies the domains that appeared in or dropped out of each target's results
 func (sm *SNIManager) RefreshSNIFiles() error {
+	if !sm.refreshing.CompareAndSwap(false, true) {
+		return errSNIRefreshRunning
+	}
+	defer sm.refreshing.Store(false)
+	return sm.refreshAndRecheck()
+}
+
+// StartRefresh refreshes the SNI file in the background, returning false if a
+// refresh is already running
+func (sm *SNIManager) StartRefresh() bool {
+	if !sm.refreshing.CompareAndSwap(false, true) {
+		return false
+	}
+	go func() {
+		defer sm.refreshing.Store(false)
+		if err := sm.refreshAndRecheck(); err != nil {
+			logger.Error("SNI refresh failed", "error", err)
+		}
+	}()
+	return true
+}
+
+// refreshAndRecheck refreshes the SNI file and rechecks the targets if it changed
+func (sm *SNIManager) refreshAndRecheck() error {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	changed, err := sm.refreshSNIFile()
+	sm.mu.Unlock()
 	
+	// Searches take the read lock, so targets are rechecked once the refresh is done
+	if changed {
+		sm.recheckAllTargets()
+	}
+	return err
+}
+
+// refreshSNIFile rebuilds sni.txt from the enabled sources, caller holds sm.mu. A
+// source that is unchanged, fails or is rejected keeps its data from the previous
+// sni.txt, and the file is left alone when no source changed.
+func (sm *SNIManager) refreshSNIFile() (bool, error) {
 	logger.Info("starting SNI file refresh")
 	
+	sources := enabledSNISources()
+	if len(sources) == 0 {
+		logger.Warn("no SNI sources enabled, skipping refresh")
+		return false, nil
+	}
+	previous := readSNISections(sm.sniFilePath)
+	var oldSize int64
+	old, err := os.Open(sm.sniFilePath)
+	if err != nil {
+		old = nil
+	} else {
+		defer old.Close()
+		if info, err := old.Stat(); err == nil {
+			oldSize = info.Size()
+		}
+	}
+	
 	// Create temp file for new data
 	tmpFile := sm.sniFilePath + ".tmp"
 	out, err := os.Create(tmpFile)
 	if err != nil {
 		logger.Error("failed to create temp SNI file", "error", err)
-		return err
+		return false, err
 	}
 	defer out.Close()
-	
-	// Download each source and append to file
-	for _, source := range sniSources {
-		logger.Info("downloading SNI file", "source", source)
-		if err := sm.downloadAndAppend(source, out); err != nil {
-			logger.Error("failed to download SNI file", "source", source, "error", err)
-			continue // Continue with other sources
+	partFile := sm.sniFilePath + ".part"
+	defer os.Remove(partFile)
+	
+	var sections []sniSection
+	var offset int64
+	fetched, unchanged, failed := 0, 0, 0
+	for _, source := range sources {
+		name := source.key()
+		var prev *sniSection
+		if p, ok := previous[name]; ok && old != nil && p.Offset+p.Size <= oldSize {
+			prev = &p
+		}
+		
+		logger.Info("fetching SNI source", "source", name)
+		section, err := appendSNISource(source, prev, partFile, out)
+		if err != nil && section.Size > 0 {
+			// A partial copy can't be taken back out of the file
+			logger.Error("failed to write SNI source", "source", name, "error", err)
+			RecordError(errCategorySNI, fmt.Sprintf("write %s: %v", name, err))
+			os.Remove(tmpFile)
+			return false, err
+		}
+		switch {
+		case err == nil:
+			fetched++
+		case errors.Is(err, errSNINotModified):
+			unchanged++
+			logger.Info("SNI source not modified", "source", name)
+		default:
+			failed++
+			logger.Error("failed to fetch SNI source", "source", name, "error", err)
+			RecordError(errCategorySNI, fmt.Sprintf("fetch %s: %v", name, err))
+		}
+		
+		if err != nil {
+			if prev == nil {
+				continue
+			}
+			if !errors.Is(err, errSNINotModified) {
+				logger.Warn("keeping previous SNI data for source", "source", name, "fetched_at", prev.FetchedAt)
+			}
+			section = *prev
+			var copyErr error
+			if section.Size, copyErr = io.Copy(out, io.NewSectionReader(old, prev.Offset, prev.Size)); copyErr != nil {
+				logger.Error("failed to copy previous SNI data", "source", name, "error", copyErr)
+				os.Remove(tmpFile)
+				return false, copyErr
+			}
 		}
+		section.Name, section.Offset = name, offset
+		offset += section.Size
+		sections = append(sections, section)
 	}
 	
 	out.Close()
+	if old != nil {
+		old.Close()
+	}
+	
+	if fetched == 0 && failed == 0 && len(sections) == len(previous) {
+		os.Remove(tmpFile)
+		sm.touchLastUpdate()
+		logger.Info("SNI data unchanged", "sources", len(sections))
+		return false, nil
+	}
+	if fetched == 0 && unchanged == 0 {
+		os.Remove(tmpFile)
+		err := fmt.Errorf("no SNI source could be fetched")
+		logger.Error("SNI refresh failed, keeping the current file", "error", err)
+		return false, err
+	}
 	
 	// Backup old file and replace with new
 	if _, err := os.Stat(sm.sniFilePath); err == nil {
@@ -121,50 +236,38 @@ func (sm *SNIManager) RefreshSNIFiles() error {
 	
 	if err := os.Rename(tmpFile, sm.sniFilePath); err != nil {
 		logger.Error("failed to replace SNI file", "error", err)
-		return err
+		RecordError(errCategorySNI, fmt.Sprintf("replace SNI file: %v", err))
+		return false, err
 	}
-	
-	// Update last update timestamp
-	if err := os.WriteFile(sm.lastUpdateFile, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
-		logger.Error("failed to write last update file", "error", err)
+	if data, err := json.MarshalIndent(sections, "", "  "); err == nil {
+		if err := os.WriteFile(sniSectionsPath(sm.sniFilePath), data, 0644); err != nil {
+			logger.Error("failed to write SNI sources file", "error", err)
+		}
 	}
+	sm.touchLastUpdate()
 	
-	logger.Info("SNI file refresh completed", "path", sm.sniFilePath)
-	
-	// Re-check all targets for new domains
-	sm.recheckAllTargets()
-	
-	return nil
+	logger.Info("SNI file refresh completed", "path", sm.sniFilePath, "fetched", fetched, "unchanged", unchanged, "failed", failed)
+	sm.buildIndex()
+	return true, nil
 }
 
-// downloadAndAppend downloads a SNI file and appends to output
-func (sm *SNIManager) downloadAndAppend(source string, out *os.File) error {
-	resp, err := http.Get(source)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
-	}
-	
-	// Copy response to output file
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("failed to write: %w", err)
+// touchLastUpdate records that the SNI file was refreshed now
+func (sm *SNIManager) touchLastUpdate() {
+	if err := os.WriteFile(sm.lastUpdateFile, []byte(time.Now().Format(time.RFC3339)), 0644); err != nil {
+		logger.Error("failed to write last update file", "error", err)
 	}
-	
-	// Ensure newline between sources
-	out.WriteString("\n")
-	
-	return nil
 }
 
 // SearchSNIForDomain searches sni.txt for a domain and extracts related domains
 func (sm *SNIManager) SearchSNIForDomain(domain string) ([]string, error) {
-	sm.mu.RLock()
+	// SNI results are looked up by apex, keyword targets have none
+	if isKeywordTarget(domain) || isNetblockTarget(domain) || isOrgTarget(domain) {
+		return []string{}, nil
+	}
+
+	// The path never changes and a refresh replaces the file by renaming, so searches
+	// don't wait for a running refresh
 	sniPath := sm.sniFilePath
-	sm.mu.RUnlock()
 	
 	// Check if SNI file exists
 	if _, err := os.Stat(sniPath); err != nil {
@@ -172,9 +275,6 @@ func (sm *SNIManager) SearchSNIForDomain(domain string) ([]string, error) {
 		return []string{}, nil
 	}
 	
-	// Run the grep/awk/sed chain to extract domains
-	// Command: cat sni.txt | grep -F ".{domain}" | awk -F' -- ''{print $2}' | tr '\n' | tr '[' | sed 's/ //' | sed 's/\]//' | grep -F ".{domain}" | sort -u
-	
 	results := make(map[string]bool) // Use map for dedup
 	
 	file, err := os.Open(sniPath)
@@ -184,39 +284,27 @@ func (sm *SNIManager) SearchSNIForDomain(domain string) ([]string, error) {
 	}
 	defer file.Close()
 	
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		
-		// Check if line contains domain
-		if !strings.Contains(line, "."+domain) && !strings.Contains(line, domain) {
-			continue
-		}
-		
-		// Parse line format: IP -- domain1, domain2, domain3, etc.
-		parts := strings.Split(line, " -- ")
-		if len(parts) < 2 {
-			continue
-		}
-		
-		// Extract domains from second part
-		domainsStr := parts[1]
-		
-		// Clean up brackets and whitespace
-		domainsStr = strings.ReplaceAll(domainsStr, "[", "")
-		domainsStr = strings.ReplaceAll(domainsStr, "]", "")
-		domainsStr = strings.ReplaceAll(domainsStr, " ", "")
-		
-		// Split by comma
-		domains := strings.Split(domainsStr, ",")
-		
-		for _, d := range domains {
-			d = strings.TrimSpace(d)
-			
-			// Only include if it contains our target domain
-			if strings.Contains(d, "."+domain) || d == domain {
-				results[d] = true
+	// Read only the lines the index lists for the domain's apex, or scan the whole
+	// file while the index is missing or being rebuilt
+	apex := extractRootDomain(strings.ToLower(ExtractBaseDomain(domain)))
+	offsets, indexed, err := lookupSNIIndex(sniPath, apex)
+	if err != nil {
+		logger.Warn("failed to read SNI index, scanning the file", "error", err)
+		indexed = false
+	}
+	if indexed {
+		for _, offset := range offsets {
+			line, err := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62)).ReadString('\n')
+			if err != nil && err != io.EOF {
+				logger.Error("failed to read SNI file", "error", err)
+				return []string{}, err
 			}
+			collectSNIMatches(line, domain, results)
+		}
+	} else {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			collectSNIMatches(scanner.Text(), domain, results)
 		}
 	}
 	
@@ -227,11 +315,47 @@ func (sm *SNIManager) SearchSNIForDomain(domain string) ([]string, error) {
 	}
 	sort.Strings(resultSlice)
 	
-	logger.Info("SNI search completed", "target_domain", domain, "found_count", len(resultSlice))
+	logger.Info("SNI search completed", "target_domain", domain, "found_count", len(resultSlice), "indexed", indexed)
 	return resultSlice, nil
 }
 
-// recheckAllTargets re-searches all current targets in SNI file after update
+// collectSNIMatches adds the names on an SNI line that are domain or its subdomains
+func collectSNIMatches(line, domain string, results map[string]bool) {
+	if !strings.Contains(line, domain) {
+		return
+	}
+	for _, d := range sniLineNames(line) {
+		if strings.Contains(d, "."+domain) || d == domain {
+			results[d] = true
+		}
+	}
+}
+
+// ensureIndex builds the index of the SNI file when it is missing or out of date
+func (sm *SNIManager) ensureIndex() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	
+	if _, err := os.Stat(sm.sniFilePath); err != nil || sniIndexCurrent(sm.sniFilePath) {
+		return
+	}
+	sm.buildIndex()
+}
+
+// buildIndex indexes the SNI file, caller holds sm.mu
+func (sm *SNIManager) buildIndex() {
+	logger.Info("building SNI index", "path", sniIndexPath(sm.sniFilePath))
+	start := time.Now()
+	if err := buildSNIIndex(sm.sniFilePath, sniIndexPath(sm.sniFilePath)); err != nil {
+		logger.Error("failed to build SNI index, searches scan the whole file", "error", err)
+		RecordError(errCategorySNI, fmt.Sprintf("build SNI index: %v", err))
+		return
+	}
+	logger.Info("SNI index built", "duration", time.Since(start).Round(time.Millisecond))
+}
+
+// recheckAllTargets re-searches all current targets in SNI file after update and
+// records the results in the domain tracker
 func (sm *SNIManager) recheckAllTargets() {
 	dt := GetDomainTracker()
 	if dt == nil {
@@ -246,152 +370,45 @@ func (sm *SNIManager) recheckAllTargets() {
 	
 	logger.Info("rechecking all targets after SNI update")
 	
-	newDiscoveries := make(map[string][]string)
-	
 	for _, target := range cfg.Targets {
+		if isKeywordTarget(target) || isNetblockTarget(target) || isOrgTarget(target) {
+			continue
+		}
 		logger.Info("rechecking SNI for target", "target", target)
 		
-		// Search SNI for this target
+		// A failed search must not look like every domain dropped out
 		domains, err := sm.SearchSNIForDomain(target)
-		if err != nil || len(domains) == 0 {
+		if err != nil {
 			continue
 		}
 		
-		// Track which domains are new (not previously seen)
-		previousDomains := sm.getPreviousResults(target)
-		newDomains := []string{}
-		
-		for _, domain := range domains {
-			isNew := true
-			for _, prev := range previousDomains {
-				if prev == domain {
-					isNew = false
-					break
-				}
-			}
-			
-			if isNew {
-				newDomains = append(newDomains, domain)
-				logger.Info("found new domain in SNI", "target", target, "domain", domain)
-			}
+		added, removed := dt.RecordSNIResults(target, domains)
+		for _, domain := range added {
+			logger.Info("found new domain in SNI", "target", target, "domain", domain)
 		}
-		
-		if len(newDomains) > 0 {
-			newDiscoveries[target] = newDomains
+		for _, domain := range removed {
+			logger.Info("domain dropped out of SNI", "target", target, "domain", domain)
 		}
-	}
-	
-	// Save current results as previous for next update
-	sm.savePreviousResults(newDiscoveries)
-	
-	// Send Discord notification and trigger enumeration
-	if len(newDiscoveries) > 0 {
-		sm.notifyAndEnumerateNewDomains(newDiscoveries)
-	}
-}
-
-// getPreviousResults retrieves previously found domains for a target
-func (sm *SNIManager) getPreviousResults(target string) []string {
-	sm.mu.RLock()
-	previousFile := sm.previousResultsFile
-	sm.mu.RUnlock()
-	
-	data, err := os.ReadFile(previousFile)
-	if err != nil {
-		return []string{}
-	}
-	
-	// Simple format: target|domain1,domain2,domain3\n
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		parts := strings.Split(line, "|")
-		if len(parts) == 2 && parts[0] == target {
-			domains := strings.Split(parts[1], ",")
-			var result []string
-			for _, d := range domains {
-				if d = strings.TrimSpace(d); d != "" {
-					result = append(result, d)
-				}
-			}
-			return result
-		}
-	}
-	
-	return []string{}
-}
-
-// savePreviousResults saves the current found domains as previous for next update
-func (sm *SNIManager) savePreviousResults(newDiscoveries map[string][]string) {
-	sm.mu.Lock()
-	previousFile := sm.previousResultsFile
-	sm.mu.Unlock()
-	
-	// Also load all existing domains (from before this update)
-	existingResults := make(map[string][]string)
-	data, err := os.ReadFile(previousFile)
-	if err == nil {
-		lines := strings.Split(string(data), "\n")
-		for _, line := range lines {
-			parts := strings.Split(line, "|")
-			if len(parts) == 2 {
-				target := parts[0]
-				domains := strings.Split(parts[1], ",")
-				var result []string
-				for _, d := range domains {
-					if d = strings.TrimSpace(d); d != "" {
-						result = append(result, d)
-					}
-				}
-				existingResults[target] = result
-			}
-		}
-	}
-	
-	// Merge new discoveries with existing
-	for target, newDomains := range newDiscoveries {
-		existingResults[target] = append(existingResults[target], newDomains...)
-		
-		// Deduplicate
-		seen := make(map[string]bool)
-		var unique []string
-		for _, d := range existingResults[target] {
-			if !seen[d] {
-				unique = append(unique, d)
-				seen[d] = true
-			}
-		}
-		sort.Strings(unique)
-		existingResults[target] = unique
-	}
-	
-	// Write to file
-	var lines []string
-	for target, domains := range existingResults {
-		if len(domains) > 0 {
-			lines = append(lines, fmt.Sprintf("%s|%s", target, strings.Join(domains, ",")))
-		}
-	}
-	sort.Strings(lines)
-	
-	content := strings.Join(lines, "\n")
-	if err := os.WriteFile(previousFile, []byte(content), 0644); err != nil {
-		logger.Error("failed to save previous results", "error", err)
+		sm.notifySNIChanges(target, added, removed)
 	}
 }
 
-// notifyAndEnumerateNewDomains sends Discord notification and triggers enumeration
-func (sm *SNIManager) notifyAndEnumerateNewDomains(newDiscoveries map[string][]string) {
-	for target, newDomains := range newDiscoveries {
-		logger.Info("SNI discovery notification", "target", target, "count", len(newDomains))
-		
-		// Send Discord notification
-		sendSNIDiscoveryNotification(target, newDomains)
+// notifySNIChanges notifies a target's domains that appeared in and dropped out of
+// the SNI dataset, and enumerates the new ones
+func (sm *SNIManager) notifySNIChanges(target string, added, removed []string) {
+	if len(added) > 0 {
+		logger.Info("SNI discovery notification", "target", target, "count", len(added))
+		sendSNIDiscoveryNotification(target, added)
 		
 		// Trigger enumeration for each domain
-		for _, domain := range newDomains {
+		for _, domain := range added {
 			go sm.enumerateSNIDomain(target, domain)
 		}
 	}
+	if len(removed) > 0 {
+		logger.Info("SNI removal notification", "target", target, "count", len(removed))
+		sendSNIRemovalNotification(target, removed)
+	}
 }
 
 // enumerateSNIDomain determines if domain should go to puredns (wildcard) or feroxbuster
@@ -405,34 +422,144 @@ func (sm *SNIManager) enumerateSNIDomain(target, domain string) {
 		baseDomain := ExtractBaseDomain(domain)
 		if _, err := RunPuredns(baseDomain, target); err != nil {
 			logger.Error("failed to start puredns for SNI domain", "domain", baseDomain, "error", err)
+			RecordError(errCategoryScan, fmt.Sprintf("puredns %s: %v", baseDomain, err))
 		}
 	} else {
 		// Non-wildcard - use feroxbuster
 		if _, err := RunFeroxbuster(domain, target); err != nil {
 			logger.Error("failed to start feroxbuster for SNI domain", "domain", domain, "error", err)
+			RecordError(errCategoryScan, fmt.Sprintf("feroxbuster %s: %v", domain, err))
 		}
 	}
 }
 
-// SearchSNIOnDemand performs an immediate SNI search for a domain (for when target is added)
+// sniDemandQueueSize bounds the targets waiting for an on-demand SNI search
+const sniDemandQueueSize = 1000
+
+// SearchSNIOnDemand queues an SNI search for a newly added target. Searches run one at
+// a time, so a bulk add doesn't scan the SNI file once per target in parallel.
 func (sm *SNIManager) SearchSNIOnDemand(target string) {
-	go func() {
+	select {
+	case sm.demand <- target:
+	default:
+		logger.Warn("SNI search queue full, target will be searched at the next refresh", "target", target)
+	}
+}
+
+// runDemandSearches searches queued targets in order and enumerates what they find
+func (sm *SNIManager) runDemandSearches() {
+	for target := range sm.demand {
 		time.Sleep(1 * time.Second) // Brief delay for SNI file to be available
 		
 		domains, err := sm.SearchSNIForDomain(target)
 		if err != nil || len(domains) == 0 {
 			logger.Info("no SNI results for new target", "target", target)
-			return
+			continue
 		}
 		
 		logger.Info("found SNI domains for new target", "target", target, "count", len(domains))
 		
-		// Save these as previous results since these are the initial findings
-		sm.savePreviousResults(map[string][]string{target: domains})
+		// Record these so the next refresh only reports changes
+		GetDomainTracker().RecordSNIResults(target, domains)
 		
 		// Trigger enumeration
 		for _, domain := range domains {
 			go sm.enumerateSNIDomain(target, domain)
 		}
-	}()
+	}
+}
+
+// LastRefresh returns when the SNI file was last refreshed, or the zero time
+func (sm *SNIManager) LastRefresh() time.Time {
+	info, err := os.Stat(sm.lastUpdateFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// sniStatus describes the SNI dataset for the admin API
+func (sm *SNIManager) sniStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"refreshing": sm.refreshing.Load(),
+		"indexed":    sniIndexCurrent(sm.sniFilePath),
+		"sources":    []sniSection{},
+	}
+	if last := sm.LastRefresh(); !last.IsZero() {
+		status["last_refresh"] = last
+		status["next_refresh"] = last.Add(time.Duration(GetSNIConfig().CheckIntervalDays) * 24 * time.Hour)
+	}
+	if sections := readSNISections(sm.sniFilePath); sections != nil {
+		var list []sniSection
+		for _, section := range sections {
+			list = append(list, section)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Offset < list[j].Offset })
+		status["sources"] = list
+	}
+	return status
+}
+
+// handleSNIRefresh starts an SNI refresh in the background (POST), or reports whether
+// one is running and when the data was last refreshed (GET)
+func (as *AdminServer) handleSNIRefresh(w http.ResponseWriter, r *http.Request) {
+	sm := GetSNIManager()
+	if sm == nil {
+		http.Error(w, "SNI dataset is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sm.sniStatus())
+	case http.MethodPost:
+		if !sm.StartRefresh() {
+			http.Error(w, errSNIRefreshRunning.Error(), http.StatusConflict)
+			return
+		}
+		logger.Info("SNI refresh requested via admin panel")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(sm.sniStatus())
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSNISearch looks a domain up in the SNI dataset
+func (as *AdminServer) handleSNISearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	if domain == "" {
+		http.Error(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+	sm := GetSNIManager()
+	if sm == nil {
+		http.Error(w, "SNI dataset is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	start := time.Now()
+	matches, err := sm.SearchSNIForDomain(domain)
+	if err != nil {
+		http.Error(w, "SNI search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if matches == nil {
+		matches = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"domain":  domain,
+		"matches": matches,
+		"count":   len(matches),
+		"indexed": sniIndexCurrent(sm.sniFilePath),
+		"took_ms": time.Since(start).Milliseconds(),
+	})
 }
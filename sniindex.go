@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The SNI index maps the apex of every name in sni.txt to the offsets of the lines
+// naming it, so a lookup reads a few lines instead of the whole file. It holds
+// (FNV-64a hash of the apex, line offset) records sorted by hash and split into
+// partitions by the top byte of the hash, after a header naming the size and
+// modification time of the indexed file.
+const (
+	sniIndexMagic      = "CRTSNIX1"
+	sniIndexPartitions = 256
+	sniIndexRecordSize = 16
+	sniIndexHeaderSize = 24 + (sniIndexPartitions+1)*8
+)
+
+// sniIndexRecord is one apex occurrence in the SNI file
+type sniIndexRecord struct {
+	hash   uint64
+	offset uint64
+}
+
+// sniIndexHeader describes the file an index was built from and where each
+// partition's records start
+type sniIndexHeader struct {
+	size    int64
+	modTime int64
+	starts  [sniIndexPartitions + 1]uint64
+}
+
+// sniIndexPath returns the index file of an SNI file
+func sniIndexPath(sniPath string) string {
+	return sniPath + ".idx"
+}
+
+// sniApexHash returns the index key of an apex
+func sniApexHash(apex string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(apex))
+	return h.Sum64()
+}
+
+// sniLineNames returns the names of an SNI line: IP -- [name1, name2, ...]
+func sniLineNames(line string) []string {
+	parts := strings.SplitN(line, " -- ", 2)
+	if len(parts) < 2 {
+		return nil
+	}
+	return strings.FieldsFunc(parts[1], func(r rune) bool {
+		return r == ',' || r == '[' || r == ']' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+// sniLineApexes returns the distinct apexes named on an SNI line
+func sniLineApexes(line string) []string {
+	var apexes []string
+	for _, name := range sniLineNames(line) {
+		apex := extractRootDomain(strings.ToLower(ExtractBaseDomain(name)))
+		known := false
+		for _, a := range apexes {
+			if a == apex {
+				known = true
+				break
+			}
+		}
+		if !known {
+			apexes = append(apexes, apex)
+		}
+	}
+	return apexes
+}
+
+// buildSNIIndex indexes an SNI file. Records are spread over one temporary file per
+// partition first, so only one partition is sorted in memory at a time.
+func buildSNIIndex(sniPath, indexPath string) error {
+	in, err := os.Open(sniPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(indexPath), ".sni-index-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	var partFiles [sniIndexPartitions]*os.File
+	var partWriters [sniIndexPartitions]*bufio.Writer
+	defer func() {
+		for _, f := range partFiles {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i := range partFiles {
+		if partFiles[i], err = os.Create(filepath.Join(tmpDir, fmt.Sprintf("%03d", i))); err != nil {
+			return err
+		}
+		partWriters[i] = bufio.NewWriterSize(partFiles[i], 64*1024)
+	}
+
+	reader := bufio.NewReaderSize(in, 1<<20)
+	var record [sniIndexRecordSize]byte
+	var offset int64
+	for {
+		line, readErr := reader.ReadString('\n')
+		for _, apex := range sniLineApexes(line) {
+			hash := sniApexHash(apex)
+			binary.LittleEndian.PutUint64(record[:8], hash)
+			binary.LittleEndian.PutUint64(record[8:], uint64(offset))
+			if _, err := partWriters[hash>>56].Write(record[:]); err != nil {
+				return err
+			}
+		}
+		offset += int64(len(line))
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	for i, w := range partWriters {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		partFiles[i].Close()
+		partFiles[i] = nil
+	}
+
+	tmpIndex := indexPath + ".tmp"
+	out, err := os.Create(tmpIndex)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpIndex)
+	defer out.Close()
+
+	header := sniIndexHeader{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	writer := bufio.NewWriterSize(out, 1<<20)
+	if _, err := writer.Write(make([]byte, sniIndexHeaderSize)); err != nil {
+		return err
+	}
+	var count uint64
+	for i := 0; i < sniIndexPartitions; i++ {
+		header.starts[i] = count
+		data, err := os.ReadFile(filepath.Join(tmpDir, fmt.Sprintf("%03d", i)))
+		if err != nil {
+			return err
+		}
+		records := make([]sniIndexRecord, len(data)/sniIndexRecordSize)
+		for j := range records {
+			records[j].hash = binary.LittleEndian.Uint64(data[j*sniIndexRecordSize:])
+			records[j].offset = binary.LittleEndian.Uint64(data[j*sniIndexRecordSize+8:])
+		}
+		sort.Slice(records, func(a, b int) bool {
+			if records[a].hash != records[b].hash {
+				return records[a].hash < records[b].hash
+			}
+			return records[a].offset < records[b].offset
+		})
+		for _, r := range records {
+			binary.LittleEndian.PutUint64(record[:8], r.hash)
+			binary.LittleEndian.PutUint64(record[8:], r.offset)
+			if _, err := writer.Write(record[:]); err != nil {
+				return err
+			}
+		}
+		count += uint64(len(records))
+	}
+	header.starts[sniIndexPartitions] = count
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := out.WriteAt(header.encode(), 0); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpIndex, indexPath)
+}
+
+// encode returns the header as written at the start of the index
+func (h *sniIndexHeader) encode() []byte {
+	buf := make([]byte, sniIndexHeaderSize)
+	copy(buf, sniIndexMagic)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(h.size))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(h.modTime))
+	for i, start := range h.starts {
+		binary.LittleEndian.PutUint64(buf[24+i*8:], start)
+	}
+	return buf
+}
+
+// readSNIIndexHeader reads the header of an open index
+func readSNIIndexHeader(index *os.File) (*sniIndexHeader, error) {
+	buf := make([]byte, sniIndexHeaderSize)
+	if _, err := index.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+	if string(buf[:8]) != sniIndexMagic {
+		return nil, fmt.Errorf("not an SNI index")
+	}
+	h := &sniIndexHeader{
+		size:    int64(binary.LittleEndian.Uint64(buf[8:])),
+		modTime: int64(binary.LittleEndian.Uint64(buf[16:])),
+	}
+	for i := range h.starts {
+		h.starts[i] = binary.LittleEndian.Uint64(buf[24+i*8:])
+	}
+	return h, nil
+}
+
+// matches reports whether the header describes the SNI file as it is now
+func (h *sniIndexHeader) matches(sniPath string) bool {
+	info, err := os.Stat(sniPath)
+	return err == nil && info.Size() == h.size && info.ModTime().UnixNano() == h.modTime
+}
+
+// sniIndexCurrent reports whether the index of an SNI file is up to date
+func sniIndexCurrent(sniPath string) bool {
+	index, err := os.Open(sniIndexPath(sniPath))
+	if err != nil {
+		return false
+	}
+	defer index.Close()
+	header, err := readSNIIndexHeader(index)
+	return err == nil && header.matches(sniPath)
+}
+
+// lookupSNIIndex returns the offsets of the lines naming an apex in ascending order.
+// ok is false when the index is missing or was built from another version of the file.
+func lookupSNIIndex(sniPath, apex string) (offsets []int64, ok bool, err error) {
+	index, err := os.Open(sniIndexPath(sniPath))
+	if err != nil {
+		return nil, false, nil
+	}
+	defer index.Close()
+	header, err := readSNIIndexHeader(index)
+	if err != nil || !header.matches(sniPath) {
+		return nil, false, nil
+	}
+
+	hash := sniApexHash(apex)
+	start, end := header.starts[hash>>56], header.starts[hash>>56+1]
+	var record [sniIndexRecordSize]byte
+	readRecord := func(i uint64) (sniIndexRecord, error) {
+		if _, err := index.ReadAt(record[:], int64(sniIndexHeaderSize+i*sniIndexRecordSize)); err != nil {
+			return sniIndexRecord{}, err
+		}
+		return sniIndexRecord{binary.LittleEndian.Uint64(record[:8]), binary.LittleEndian.Uint64(record[8:])}, nil
+	}
+
+	var readErr error
+	first := start + uint64(sort.Search(int(end-start), func(i int) bool {
+		r, err := readRecord(start + uint64(i))
+		if err != nil {
+			readErr = err
+			return true
+		}
+		return r.hash >= hash
+	}))
+	if readErr != nil {
+		return nil, true, readErr
+	}
+	for i := first; i < end; i++ {
+		r, err := readRecord(i)
+		if err != nil {
+			return nil, true, err
+		}
+		if r.hash != hash {
+			break
+		}
+		offsets = append(offsets, int64(r.offset))
+	}
+	return offsets, true, nil
+}
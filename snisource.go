@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSNISources are the kaeferjaeger.gay cloud SNI ranges, used unless disabled
+var defaultSNISources = []SNISource{
+	{Name: "amazon", URL: "https://kaeferjaeger.gay/sni-ip-ranges/amazon/ipv4_merged_sni.txt"},
+	{Name: "google", URL: "https://kaeferjaeger.gay/sni-ip-ranges/google/ipv4_merged_sni.txt"},
+	{Name: "digitalocean", URL: "https://kaeferjaeger.gay/sni-ip-ranges/digitalocean/ipv4_merged_sni.txt"},
+	{Name: "microsoft", URL: "https://kaeferjaeger.gay/sni-ip-ranges/microsoft/ipv4_merged_sni.txt"},
+	{Name: "oracle", URL: "https://kaeferjaeger.gay/sni-ip-ranges/oracle/ipv4_merged_sni.txt"},
+}
+
+// defaultSNIMinBytes is the smallest accepted download of a built-in source
+const defaultSNIMinBytes = 1 << 20
+
+// sniSampleLines are checked for the "IP -- [names]" format before a source is accepted
+const sniSampleLines = 1000
+
+// SNIConfig sets where the SNI dataset comes from and how often it is refreshed
+type SNIConfig struct {
+	Enabled           *bool       `yaml:"enabled"`             // Default true; changes apply on restart
+	CheckIntervalDays int         `yaml:"check_interval_days"` // Days between refreshes, default 30
+	Sources           []SNISource `yaml:"sources"`             // Added to the built-in sources, or replacing those of the same name
+}
+
+// SNISource is one file of SNI ranges, downloaded or read from disk
+type SNISource struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	Path     string            `yaml:"path"`    // Local file, instead of a URL
+	Enabled  *bool             `yaml:"enabled"` // Default true
+	Headers  map[string]string `yaml:"headers"` // e.g. Authorization: Bearer ...
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	MinBytes int64             `yaml:"min_bytes"` // Smaller files are rejected, default 1 MiB for built-in sources
+}
+
+// sniSection is where a source's data sits in sni.txt, and the validators its server
+// sent for asking whether it changed
+type sniSection struct {
+	Name         string    `json:"name"`
+	Offset       int64     `json:"offset"`
+	Size         int64     `json:"size"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+}
+
+// errSNINotModified reports a URL source unchanged since its previous fetch
+var errSNINotModified = errors.New("not modified")
+
+var sniConfig *SNIConfig
+var sniConfigMutex sync.Mutex
+
+// SetSNIConfig sets the SNI dataset configuration
+func SetSNIConfig(cfg *SNIConfig) {
+	sniConfigMutex.Lock()
+	defer sniConfigMutex.Unlock()
+	if cfg.CheckIntervalDays <= 0 {
+		cfg.CheckIntervalDays = 30
+	}
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		source.Name = strings.TrimSpace(source.Name)
+		source.URL = strings.TrimSpace(source.URL)
+		source.Path = strings.TrimSpace(source.Path)
+	}
+	sniConfig = cfg
+}
+
+// GetSNIConfig returns the SNI dataset configuration, with defaults if none was set
+func GetSNIConfig() *SNIConfig {
+	sniConfigMutex.Lock()
+	defer sniConfigMutex.Unlock()
+	if sniConfig == nil {
+		return &SNIConfig{CheckIntervalDays: 30}
+	}
+	return sniConfig
+}
+
+// isSNIEnabled reports whether the SNI dataset is downloaded and searched
+func isSNIEnabled() bool {
+	cfg := GetSNIConfig()
+	return cfg.Enabled == nil || *cfg.Enabled
+}
+
+// key names a source in logs and in the sections file
+func (s SNISource) key() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.URL != "":
+		return s.URL
+	}
+	return s.Path
+}
+
+// isEnabled reports whether a source is fetched
+func (s SNISource) isEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// sniSourcesOf returns the built-in sources merged with the configured ones. A
+// configured source named like a built-in one replaces its fields that are set.
+func sniSourcesOf(cfg *SNIConfig) []SNISource {
+	sources := append([]SNISource(nil), defaultSNISources...)
+	for i := range sources {
+		sources[i].MinBytes = defaultSNIMinBytes
+	}
+	for _, configured := range cfg.Sources {
+		merged := false
+		for i := range sources {
+			if configured.Name == "" || sources[i].Name != configured.Name {
+				continue
+			}
+			builtin := &sources[i]
+			if configured.URL != "" || configured.Path != "" {
+				builtin.URL, builtin.Path = configured.URL, configured.Path
+			}
+			if configured.Enabled != nil {
+				builtin.Enabled = configured.Enabled
+			}
+			if configured.Headers != nil {
+				builtin.Headers = configured.Headers
+			}
+			if configured.Username != "" {
+				builtin.Username, builtin.Password = configured.Username, configured.Password
+			}
+			if configured.MinBytes > 0 {
+				builtin.MinBytes = configured.MinBytes
+			}
+			merged = true
+		}
+		if !merged {
+			sources = append(sources, configured)
+		}
+	}
+	return sources
+}
+
+// enabledSNISources returns the sources fetched on refresh
+func enabledSNISources() []SNISource {
+	var enabled []SNISource
+	for _, source := range sniSourcesOf(GetSNIConfig()) {
+		if source.isEnabled() {
+			enabled = append(enabled, source)
+		}
+	}
+	return enabled
+}
+
+// fetchSNISource writes a source's data to out and returns the validators of a URL
+// source. A URL source is asked for changes since prev, if given, and
+// errSNINotModified reports it has none.
+func fetchSNISource(source SNISource, prev *sniSection, out io.Writer) (etag, lastModified string, err error) {
+	if source.Path != "" {
+		in, err := os.Open(source.Path)
+		if err != nil {
+			return "", "", err
+		}
+		defer in.Close()
+		if _, err := io.Copy(out, in); err != nil {
+			return "", "", fmt.Errorf("failed to copy: %w", err)
+		}
+		return "", "", nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, source.URL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	for name, value := range source.Headers {
+		req.Header.Set(name, value)
+	}
+	if source.Username != "" {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	resp, err := downloadClient().Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		return "", "", errSNINotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return "", "", fmt.Errorf("failed to write: %w", err)
+	}
+	return resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), nil
+}
+
+// validateSNIData checks a fetched source before it replaces the live data: it must
+// reach the minimum size and its first lines must be in the "IP -- [names]" format
+func validateSNIData(source SNISource, f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	minBytes := source.MinBytes
+	if minBytes <= 0 {
+		minBytes = 1
+	}
+	if info.Size() < minBytes {
+		return fmt.Errorf("only %d bytes, expected at least %d", info.Size(), minBytes)
+	}
+
+	scanner := bufio.NewScanner(io.NewSectionReader(f, 0, info.Size()))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	var lines, valid int
+	for lines < sniSampleLines && scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines++
+		if len(sniLineNames(line)) > 0 {
+			valid++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unreadable: %w", err)
+	}
+	if valid == 0 || valid*10 < lines*9 {
+		return fmt.Errorf("%d of the first %d lines are not in the \"IP -- [names]\" format", lines-valid, lines)
+	}
+	return nil
+}
+
+// sniSectionsPath returns the file recording each source's place in an SNI file
+func sniSectionsPath(sniPath string) string {
+	return sniPath + ".sources"
+}
+
+// readSNISections returns the sections of an SNI file by source, or nil
+func readSNISections(sniPath string) map[string]sniSection {
+	data, err := os.ReadFile(sniSectionsPath(sniPath))
+	if err != nil {
+		return nil
+	}
+	var list []sniSection
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil
+	}
+	sections := make(map[string]sniSection, len(list))
+	for _, s := range list {
+		sections[s.Name] = s
+	}
+	return sections
+}
+
+// checkSNIConfig reports sources that cannot be fetched as configured
+func checkSNIConfig(cfg SNIConfig) []doctorCheck {
+	var checks []doctorCheck
+	seen := make(map[string]bool)
+	for i, source := range cfg.Sources {
+		name := strings.TrimSpace(source.key())
+		if name == "" {
+			checks = append(checks, doctorCheck{"sni source", doctorFail, fmt.Sprintf("sources[%d]: needs a name, url or path", i)})
+			continue
+		}
+		if seen[name] {
+			checks = append(checks, doctorCheck{"sni source", doctorFail, name + ": listed twice"})
+		}
+		seen[name] = true
+
+		builtin := false
+		for _, s := range defaultSNISources {
+			builtin = builtin || s.Name == source.Name
+		}
+		rawURL, path := strings.TrimSpace(source.URL), strings.TrimSpace(source.Path)
+		switch {
+		case rawURL != "" && path != "":
+			checks = append(checks, doctorCheck{"sni source", doctorFail, name + ": set url or path, not both"})
+		case rawURL != "":
+			if parsed, err := url.Parse(rawURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				checks = append(checks, doctorCheck{"sni source", doctorFail, name + ": url must be an absolute http(s) URL"})
+			}
+		case path != "":
+			if _, err := os.Stat(path); err != nil {
+				checks = append(checks, doctorCheck{"sni source", doctorWarn, name + ": " + err.Error()})
+			}
+		case !builtin:
+			checks = append(checks, doctorCheck{"sni source", doctorFail, name + ": needs a url or path"})
+		}
+	}
+	return checks
+}
+
+// appendSNISource fetches a source into partPath, validates it and appends it to out
+// with a trailing newline. The returned section holds the bytes appended, which are
+// only non-zero on error when out was left with part of the source.
+func appendSNISource(source SNISource, prev *sniSection, partPath string, out io.Writer) (sniSection, error) {
+	part, err := os.Create(partPath)
+	if err != nil {
+		return sniSection{}, err
+	}
+	defer part.Close()
+	etag, lastModified, err := fetchSNISource(source, prev, part)
+	if err != nil {
+		return sniSection{}, err
+	}
+	if err := validateSNIData(source, part); err != nil {
+		return sniSection{}, fmt.Errorf("rejected: %w", err)
+	}
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return sniSection{}, err
+	}
+	section := sniSection{FetchedAt: time.Now(), ETag: etag, LastModified: lastModified}
+	if section.Size, err = io.Copy(out, part); err != nil {
+		return section, fmt.Errorf("failed to write: %w", err)
+	}
+	// Ensure newline between sources
+	if _, err := io.WriteString(out, "\n"); err != nil {
+		return section, fmt.Errorf("failed to write: %w", err)
+	}
+	section.Size++
+	return section, nil
+}
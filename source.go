@@ -0,0 +1,140 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Discovery sources, how a tracked domain was first found. Domains found by a
+// configured tool, like subfinder, have the tool's name as their source.
+const (
+	sourceCertstream = "certstream" // Live certificates from CT logs
+	sourceBackfill   = "backfill"   // CT log entries caught up on after downtime
+	sourceSNI        = "sni"        // The SNI dataset
+	sourcePuredns    = "puredns"    // DNS bruteforce of a wildcard
+	sourceManual     = "manual"     // Injected through the admin panel
+)
+
+// scanHostPattern is a lowercase host name in scan output. Labels may hold underscores,
+// like _dmarc, so lines that are anything else aren't tracked as domains.
+var scanHostPattern = regexp.MustCompile(`^[a-z0-9_-]+(\.[a-z0-9_-]+)*$`)
+
+// SourceStats counts the tracked domains first found by one source, and how many
+// of them turned out to be worth something
+type SourceStats struct {
+	Source   string `json:"source"`
+	Domains  int    `json:"domains"`
+	Last7d   int    `json:"last_7d"`   // First seen in the last 7 days
+	Live     int    `json:"live"`      // Asset state live
+	HighRisk int    `json:"high_risk"` // Risk score 50 or more
+	Findings int    `json:"findings"`  // Domains with scan findings
+}
+
+// initSources attributes domains tracked before sources were recorded. Only CT
+// discoveries went without one.
+func (dt *DomainTracker) initSources() {
+	for _, entry := range dt.domains {
+		if entry.Source == "" {
+			entry.Source = sourceCertstream
+		}
+	}
+}
+
+// domainSource returns how a tracked domain was first found
+func domainSource(entry *DomainEntry) string {
+	if entry == nil || entry.Source == "" {
+		return sourceCertstream
+	}
+	return entry.Source
+}
+
+// trackScanDomains tracks subdomains a scan found under its target: those puredns
+// reported, and output lines of a tool that are host names, like subfinder's. It
+// returns the domains not tracked before.
+func trackScanDomains(scanType, target string, findings []Finding, lines []string) []string {
+	var names []string
+	if scanType == sourcePuredns {
+		for _, f := range findings {
+			if f.Kind == findingSubdomain {
+				names = append(names, f.Name)
+			}
+		}
+	} else if toolNamed(scanType) != nil {
+		for _, line := range lines {
+			names = append(names, strings.ToLower(strings.TrimSuffix(strings.TrimSpace(line), ".")))
+		}
+	}
+
+	var found []string
+	for _, name := range names {
+		if !scanHostPattern.MatchString(name) || IsWildcardDomain(name) || !matchesTarget(name, target) {
+			continue
+		}
+		found = append(found, name)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return GetDomainTracker().AddDomains(found, scanType)
+}
+
+// BuildSourceStats counts domains by the source that first found them, most first
+func BuildSourceStats(entries map[string]*DomainEntry) []SourceStats {
+	counts := GetFindingsStore().CountDomains()
+	weekAgo := time.Now().AddDate(0, 0, -7)
+
+	bySource := make(map[string]*SourceStats)
+	for _, entry := range entries {
+		source := domainSource(entry)
+		s, ok := bySource[source]
+		if !ok {
+			s = &SourceStats{Source: source}
+			bySource[source] = s
+		}
+		s.Domains++
+		if entry.FirstSeen.After(weekAgo) {
+			s.Last7d++
+		}
+		if entry.AssetState == assetLive {
+			s.Live++
+		}
+		if entry.RiskScore >= 50 {
+			s.HighRisk++
+		}
+		if counts[entry.Domain] > 0 {
+			s.Findings++
+		}
+	}
+
+	stats := make([]SourceStats, 0, len(bySource))
+	for _, s := range bySource {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Domains != stats[j].Domains {
+			return stats[i].Domains > stats[j].Domains
+		}
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+// batchSources returns the distinct sources that first found a batch of domains
+func batchSources(domains []string) string {
+	dt := GetDomainTracker()
+	seen := make(map[string]bool)
+	var sources []string
+
+	for _, domain := range domains {
+		source := domainSource(dt.GetDomainInfo(domain))
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		sources = append(sources, source)
+	}
+
+	return strings.Join(sources, ", ")
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 )
@@ -16,6 +19,17 @@ type StatsTracker struct {
 	failedScans           int
 	discoveryTimeline     []DiscoveryPoint
 	targetActivity        map[string]int
+	notifyLatencies       []int64 // Recent time-to-notify samples in milliseconds
+}
+
+// statsSnapshot is the part of StatsTracker kept across restarts; live scan and
+// CT log counts are rebuilt at startup
+type statsSnapshot struct {
+	CompletedScans    int              `json:"completed_scans"`
+	FailedScans       int              `json:"failed_scans"`
+	DiscoveryTimeline []DiscoveryPoint `json:"discovery_timeline"`
+	TargetActivity    map[string]int   `json:"target_activity"`
+	NotifyLatencies   []int64          `json:"notify_latency_ms"`
 }
 
 // DiscoveryPoint represents domain discoveries in a time window
@@ -50,9 +64,14 @@ func GetStatsTracker() *StatsTracker {
 // RecordCTLogStatus updates CT log health stats
 func (st *StatsTracker) RecordCTLogStatus(active, disconnected int) {
 	st.mu.Lock()
-	defer st.mu.Unlock()
+	changed := st.activeCTLogs != active || st.disconnectedCTLogs != disconnected
 	st.activeCTLogs = active
 	st.disconnectedCTLogs = disconnected
+	st.mu.Unlock()
+
+	if changed {
+		publishLive(liveCTHealth, map[string]int{"active": active, "disconnected": disconnected})
+	}
 }
 
 // IncrementActiveFeroxScans increments feroxbuster scan counter
@@ -93,6 +112,15 @@ func (st *StatsTracker) DecrementActivePurednsScans(success bool) {
 	}
 }
 
+// RemoveTarget forgets a target's discovery count and reports whether it had one
+func (st *StatsTracker) RemoveTarget(target string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, found := st.targetActivity[target]
+	delete(st.targetActivity, target)
+	return found
+}
+
 // RecordDiscovery records a domain discovery for rate tracking
 func (st *StatsTracker) RecordDiscovery(target string) {
 	st.mu.Lock()
@@ -195,3 +223,61 @@ func (st *StatsTracker) GetEnumSuccessRate() (completed, failed int, rate float6
 	rate = float64(st.completedScans) / float64(total) * 100
 	return st.completedScans, st.failedScans, rate
 }
+
+// statsPath returns where stats are saved between runs
+func statsPath() string {
+	configDir, _ := getConfigDir()
+	return filepath.Join(configDir, "stats.json")
+}
+
+// Load restores counters saved by a previous run
+func (st *StatsTracker) Load() error {
+	data, err := os.ReadFile(statsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snapshot statsSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.completedScans = snapshot.CompletedScans
+	st.failedScans = snapshot.FailedScans
+	st.discoveryTimeline = snapshot.DiscoveryTimeline
+	if snapshot.TargetActivity != nil {
+		st.targetActivity = snapshot.TargetActivity
+	}
+	st.notifyLatencies = snapshot.NotifyLatencies
+	return nil
+}
+
+// Save writes counters to disk so a restart doesn't reset the dashboard
+func (st *StatsTracker) Save() error {
+	if isDryRun() {
+		return nil
+	}
+	st.mu.RLock()
+	data, err := json.Marshal(statsSnapshot{
+		CompletedScans:    st.completedScans,
+		FailedScans:       st.failedScans,
+		DiscoveryTimeline: st.discoveryTimeline,
+		TargetActivity:    st.targetActivity,
+		NotifyLatencies:   st.notifyLatencies,
+	})
+	st.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	path := statsPath()
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StorageConfig selects where scan outputs and screenshots are kept. Tools still write
+// to local disk; with the s3 backend finished files are uploaded to the bucket.
+type StorageConfig struct {
+	Backend   string `yaml:"backend"`  // "local" (default) or "s3"
+	Endpoint  string `yaml:"endpoint"` // e.g. http://minio:9000; defaults to AWS for the region
+	Region    string `yaml:"region"`   // Defaults to us-east-1
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"` // Prepended to every object key, e.g. "crtmon/"
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	KeepLocal bool   `yaml:"keep_local"` // Keep the local file after uploading it
+}
+
+// Artifact kinds, used as key prefixes in the bucket
+const (
+	artifactScans       = "scans"
+	artifactScreenshots = "screenshots"
+)
+
+// s3RefPrefix marks artifact references that point into the bucket instead of local disk
+const s3RefPrefix = "s3://"
+
+var storageConfig *StorageConfig
+var storageMutex sync.Mutex
+
+// SetStorageConfig sets the artifact storage configuration
+func SetStorageConfig(cfg *StorageConfig) {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+	storageConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = "local"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Backend == "s3" && (cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "") {
+		logger.Warn("s3 storage needs bucket, access_key and secret_key, keeping artifacts on local disk")
+		cfg.Backend = "local"
+	}
+}
+
+// GetStorageConfig returns the artifact storage configuration
+func GetStorageConfig() *StorageConfig {
+	storageMutex.Lock()
+	defer storageMutex.Unlock()
+	return storageConfig
+}
+
+// s3Storage returns the storage config when artifacts go to a bucket, or nil
+func s3Storage() *StorageConfig {
+	cfg := GetStorageConfig()
+	if cfg == nil || cfg.Backend != "s3" {
+		return nil
+	}
+	return cfg
+}
+
+// storeArtifact uploads a finished local file to the bucket and returns its reference.
+// With local storage, or when the upload fails, the local path is returned unchanged.
+func storeArtifact(kind, path, contentType string) string {
+	cfg := s3Storage()
+	if cfg == nil {
+		return path
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return path
+	}
+
+	key := cfg.Prefix + kind + "/" + filepath.Base(path)
+	if err := cfg.put(key, data, contentType); err != nil {
+		logger.Warn("failed to upload artifact, keeping it on local disk", "path", path, "error", err)
+		RecordError(errCategoryStorage, fmt.Sprintf("upload %s: %v", filepath.Base(path), err))
+		return path
+	}
+	if !cfg.KeepLocal {
+		os.Remove(path)
+	}
+	logger.Debug("artifact uploaded", "path", path, "key", key)
+	return s3RefPrefix + cfg.Bucket + "/" + key
+}
+
+// readArtifact returns the contents of a local path or bucket reference
+func readArtifact(ref string) ([]byte, error) {
+	key, ok := artifactKey(ref)
+	if !ok {
+		return os.ReadFile(ref)
+	}
+	cfg := s3Storage()
+	if cfg == nil {
+		return nil, fmt.Errorf("%s is in object storage, which is not configured", ref)
+	}
+	resp, err := cfg.do(http.MethodGet, key, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// artifactExists reports whether a local path or bucket reference still exists
+func artifactExists(ref string) bool {
+	key, ok := artifactKey(ref)
+	if !ok {
+		_, err := os.Stat(ref)
+		return err == nil
+	}
+	cfg := s3Storage()
+	if cfg == nil {
+		return false
+	}
+	resp, err := cfg.do(http.MethodHead, key, nil, nil, "")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// removeArtifact deletes a local path or bucket reference
+func removeArtifact(ref string) error {
+	key, ok := artifactKey(ref)
+	if !ok {
+		return os.Remove(ref)
+	}
+	cfg := s3Storage()
+	if cfg == nil {
+		return fmt.Errorf("%s is in object storage, which is not configured", ref)
+	}
+	resp, err := cfg.do(http.MethodDelete, key, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// listArtifacts returns references to every object of a kind in the bucket
+func listArtifacts(kind string) ([]string, error) {
+	cfg := s3Storage()
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var refs []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {cfg.Prefix + kind + "/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := cfg.do(http.MethodGet, "", query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			refs = append(refs, s3RefPrefix+cfg.Bucket+"/"+obj.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return refs, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// artifactKey returns the object key of a bucket reference
+func artifactKey(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, s3RefPrefix) {
+		return "", false
+	}
+	rest := strings.TrimPrefix(ref, s3RefPrefix)
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return "", false
+	}
+	return rest[i+1:], true
+}
+
+// put uploads an object
+func (cfg *StorageConfig) put(key string, data []byte, contentType string) error {
+	resp, err := cfg.do(http.MethodPut, key, nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// do sends a path-style request signed with AWS Signature Version 4, which both
+// AWS S3 and MinIO accept. An empty key addresses the bucket itself.
+func (cfg *StorageConfig) do(method, key string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	req, err := cfg.newRequest(method, key, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	cfg.sign(req, sha256Hex(body), time.Now())
+	return downloadClient().Do(req)
+}
+
+// newRequest builds an unsigned path-style request for a key of the bucket
+func (cfg *StorageConfig) newRequest(method, key string, query url.Values, body []byte) (*http.Request, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage endpoint: %w", err)
+	}
+
+	// Joined escaped, after any path the endpoint has, and signed as it is sent
+	elems := []string{s3Escape(cfg.Bucket, false)}
+	if key != "" {
+		elems = append(elems, s3Escape(key, false))
+	}
+	target := endpoint.JoinPath(elems...)
+	target.RawQuery = s3CanonicalQuery(query)
+
+	return http.NewRequest(method, target.String(), bytes.NewReader(body))
+}
+
+// sign adds the SigV4 headers to a request whose query is already in canonical order.
+// The path is signed as it is sent.
+func (cfg *StorageConfig) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders, signedHeaders, payloadHash}, "\n")
+
+	scope := day + "/" + cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := []byte("AWS4" + cfg.SecretKey)
+	for _, part := range []string{day, cfg.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3Escape percent-encodes everything but unreserved characters, and slashes unless encodeSlash
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery encodes a query string sorted by key, as SigV4 requires
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Error turns an unexpected response into an error with the service's message
+func s3Error(resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("s3 status %d: %s: %s", resp.StatusCode, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3 status %d", resp.StatusCode)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -1,68 +1,302 @@
 package main
 
 import (
+	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+// defaultSummarySchedule sends the daily summary at 00:01
+const defaultSummarySchedule = "1 0 * * *"
+
+// ScheduledReport is a summary sent on its own cron schedule, in addition to the daily one
+type ScheduledReport struct {
+	Name        string `yaml:"name"`
+	Schedule    string `yaml:"schedule"`     // Cron expression, e.g. "0 9 * * 1-5"
+	Timezone    string `yaml:"timezone"`     // IANA zone, defaults to summary_timezone
+	Program     string `yaml:"program"`      // Only this program's domains
+	Webhook     string `yaml:"webhook"`      // Defaults to the program's summary webhook, then daily_summary_webhook
+	PeriodHours int    `yaml:"period_hours"` // Domains seen within this window, default 24
+}
+
+// summaryJob is a parsed schedule and what it sends
+type summaryJob struct {
+	name     string
+	schedule *cronSchedule
+	run      func()
+}
+
+var summaryJobs []summaryJob
+var summaryStop chan struct{}
+var summaryStarted bool
 var summaryMutex sync.Mutex
-var lastSummarySent time.Time
 
-// StartDailySummaryScheduler starts the daily summary scheduler
-func StartDailySummaryScheduler() {
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			now := time.Now()
-			// Send summary at 00:01 every day
-			if now.Hour() == 0 && now.Minute() >= 0 && now.Minute() < 5 {
-				summaryMutex.Lock()
-				if time.Since(lastSummarySent) > 12*time.Hour {
-					summaryMutex.Unlock()
-					GenerateAndSendDailySummary()
-					summaryMutex.Lock()
-					lastSummarySent = time.Now()
-				}
-				summaryMutex.Unlock()
+// SetSummarySchedules sets when the daily summary and the scheduled reports are sent,
+// restarting the scheduler if it runs. Invalid schedules are logged and skipped; an
+// invalid daily schedule falls back to 00:01.
+func SetSummarySchedules(schedule, timezone string, reports []ScheduledReport) {
+	loc, err := loadScheduleLocation(timezone)
+	if err != nil {
+		logger.Error("invalid summary_timezone, using local time", "timezone", timezone, "error", err)
+		loc = time.Local
+	}
+	if strings.TrimSpace(schedule) == "" {
+		schedule = defaultSummarySchedule
+	}
+	daily, err := parseCron(schedule, loc)
+	if err != nil {
+		logger.Error("invalid summary_schedule, using default", "schedule", schedule, "default", defaultSummarySchedule, "error", err)
+		daily, _ = parseCron(defaultSummarySchedule, loc)
+	}
+	jobs := []summaryJob{{name: "daily", schedule: daily, run: GenerateAndSendDailySummary}}
+
+	for i, report := range reports {
+		report := report
+		if report.Name == "" {
+			report.Name = fmt.Sprintf("report-%d", i+1)
+		}
+		reportLoc := loc
+		if report.Timezone != "" {
+			if reportLoc, err = loadScheduleLocation(report.Timezone); err != nil {
+				logger.Error("invalid scheduled report timezone, skipping it", "report", report.Name, "timezone", report.Timezone, "error", err)
+				continue
 			}
 		}
-	}()
+		sched, err := parseCron(report.Schedule, reportLoc)
+		if err != nil {
+			logger.Error("invalid scheduled report schedule, skipping it", "report", report.Name, "schedule", report.Schedule, "error", err)
+			continue
+		}
+		jobs = append(jobs, summaryJob{name: report.Name, schedule: sched, run: func() { sendScheduledReport(report) }})
+	}
+
+	summaryMutex.Lock()
+	summaryJobs = jobs
+	started := summaryStarted
+	summaryMutex.Unlock()
+	if started {
+		startSummaryJobs()
+	}
+}
+
+// loadScheduleLocation returns the time zone with the given IANA name, or local time
+func loadScheduleLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// StartDailySummaryScheduler sends the daily summary and scheduled reports on their
+// cron schedules
+func StartDailySummaryScheduler() {
+	summaryMutex.Lock()
+	summaryStarted = true
+	if summaryJobs == nil {
+		daily, _ := parseCron(defaultSummarySchedule, time.Local)
+		summaryJobs = []summaryJob{{name: "daily", schedule: daily, run: GenerateAndSendDailySummary}}
+	}
+	summaryMutex.Unlock()
+	startSummaryJobs()
+}
+
+// startSummaryJobs stops the running schedules and starts the configured ones
+func startSummaryJobs() {
+	summaryMutex.Lock()
+	defer summaryMutex.Unlock()
+	if summaryStop != nil {
+		close(summaryStop)
+	}
+	summaryStop = make(chan struct{})
+	for _, job := range summaryJobs {
+		go runSummaryJob(job, summaryStop)
+	}
+}
+
+// runSummaryJob runs a job each time its schedule fires until stop is closed
+func runSummaryJob(job summaryJob, stop <-chan struct{}) {
+	var last time.Time
+	for {
+		// Never schedule before the last run, in case the wall clock stepped back
+		from := time.Now()
+		if from.Before(last) {
+			from = last
+		}
+		next := job.schedule.Next(from)
+		if next.IsZero() {
+			logger.Warn("summary schedule never fires", "report", job.name)
+			return
+		}
+		logger.Debug("summary scheduled", "report", job.name, "next", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		last = next
+		job.run()
+	}
+}
+
+// sendScheduledReport sends a summary of the domains seen within a report's period
+func sendScheduledReport(report ScheduledReport) {
+	var members map[string]bool
+	webhook := strings.TrimSpace(report.Webhook)
+	if report.Program != "" {
+		var ok bool
+		if members, ok = programMembers(report.Program); !ok {
+			logger.Error("scheduled report names an unknown program", "report", report.Name, "program", report.Program)
+			return
+		}
+		if program := GetProgram(report.Program); webhook == "" && program != nil {
+			webhook = program.SummaryWebhook
+		}
+	}
+	if webhook == "" {
+		if cfg := GetWebhookConfig(); cfg != nil {
+			webhook = cfg.DailySummary
+		}
+	}
+
+	period := report.PeriodHours
+	if period <= 0 {
+		period = 24
+	}
+	dt := GetDomainTracker()
+	summary := buildDailySummary(dt.GetDomainsSeenSince(time.Now().Add(-time.Duration(period)*time.Hour)),
+		dt.GetBlacklistedDomains(), BuildIssuanceReport(time.Now().AddDate(0, 0, -1)), members)
+	summary["name"] = report.Name
+	if report.Program != "" {
+		summary["program"] = report.Program
+	}
+	writeSummaryReport(summary)
+
+	if webhook == "" {
+		logger.Warn("scheduled report has no webhook", "report", report.Name)
+		return
+	}
+	payload := buildDailySummaryPayload(summary)
+	if err := SendToWebhook(webhook, payload); err != nil {
+		GetRetryQueue().AddPayload(webhook, payload, err)
+		logger.Error("failed to send scheduled report", "report", report.Name, "error", err)
+		RecordError(errCategoryWebhook, fmt.Sprintf("scheduled report %s: %v", report.Name, err))
+		return
+	}
+	logger.Info("scheduled report sent", "report", report.Name, "domains_count", summary["discovered_count"])
 }
 
-// GenerateAndSendDailySummary generates and sends the daily summary
+// GenerateAndSendDailySummary generates and sends the daily summary, and one per
+// program with a summary webhook
 func GenerateAndSendDailySummary() {
 	dt := GetDomainTracker()
 
-	// Get all domains discovered today
-	domainsToday := dt.GetDomainsDiscoveredToday()
-	discoveredCount := len(domainsToday)
+	// Get all domains seen since the last daily summary
+	domainsToday := dt.GetDomainsSeenSince(time.Now().Add(-24 * time.Hour))
 
 	// Get blacklisted domains
 	blacklistedDomains := dt.GetBlacklistedDomains()
+	issuance := BuildIssuanceReport(time.Now().AddDate(0, 0, -1))
+
+	summary := buildDailySummary(domainsToday, blacklistedDomains, issuance, nil)
+	summary["cleanup"] = TakeCleanupReport()
+	writeSummaryReport(summary)
+
+	if err := SendDailySummary(summary); err != nil {
+		logger.Error("failed to send daily summary", "error", err)
+	} else {
+		logger.Info("daily summary sent", "domains_count", summary["discovered_count"], "blacklisted", summary["blacklisted_count"])
+	}
+
+	for _, name := range programNames() {
+		program := GetProgram(name)
+		if program == nil || (program.SummaryWebhook == "" && !isReportEnabled()) {
+			continue
+		}
+		members, _ := programMembers(name)
+		summary := buildDailySummary(domainsToday, blacklistedDomains, issuance, members)
+		summary["program"] = name
+		writeSummaryReport(summary)
+		if program.SummaryWebhook == "" {
+			continue
+		}
+
+		payload := buildDailySummaryPayload(summary)
+		if err := SendToWebhook(program.SummaryWebhook, payload); err != nil {
+			GetRetryQueue().AddPayload(program.SummaryWebhook, payload, err)
+			logger.Error("failed to send program daily summary", "program", name, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("daily summary for %s: %v", name, err))
+		} else {
+			logger.Info("program daily summary sent", "program", name, "domains_count", summary["discovered_count"])
+		}
+	}
+}
+
+// buildDailySummary summarizes the day's domains, limited to a program's targets when
+// members is set
+func buildDailySummary(domainsToday, blacklistedDomains []*DomainEntry, issuance []IssuanceRow, members map[string]bool) map[string]interface{} {
+	var discovered []*DomainEntry
+	for _, entry := range domainsToday {
+		if inProgram(entry, members) {
+			discovered = append(discovered, entry)
+		}
+	}
+
 	var blacklistedNames []string
 	for _, domain := range blacklistedDomains {
-		blacklistedNames = append(blacklistedNames, domain.Domain)
+		if inProgram(domain, members) {
+			blacklistedNames = append(blacklistedNames, domain.Domain)
+		}
 	}
 
-	// Get top domains by hit count (organized by root domain)
-	topHitsByRoot := getTopDomainsByRoot(domainsToday)
+	if members != nil {
+		var rows []IssuanceRow
+		for _, row := range issuance {
+			if members[row.Target] {
+				rows = append(rows, row)
+			}
+		}
+		issuance = rows
+	}
 
-	summary := map[string]interface{}{
-		"discovered_count":  discoveredCount,
-		"blacklisted_count": len(blacklistedNames),
+	return map[string]interface{}{
+		"discovered_count":    len(discovered),
+		"blacklisted_count":   len(blacklistedNames),
 		"blacklisted_domains": blacklistedNames,
-		"top_hit_domains":   topHitsByRoot,
-		"timestamp":         time.Now().Unix(),
+		// Top domains by hit count, organized by root domain
+		"top_hit_domains": getTopDomainsByRoot(discovered),
+		"timestamp":       time.Now().Unix(),
+		"issuance":        issuance,
+		"domains":         discovered,
 	}
+}
 
-	if err := SendDailySummary(summary); err != nil {
-		logger.Error("failed to send daily summary", "error", err)
-	} else {
-		logger.Info("daily summary sent", "domains_count", discoveredCount, "blacklisted", len(blacklistedNames))
+// summaryTitle returns the name of a summary: a scheduled report's, or Daily Summary
+func summaryTitle(summary map[string]interface{}) string {
+	if name, ok := summary["name"].(string); ok && name != "" {
+		return name
+	}
+	return "Daily Summary"
+}
+
+// writeSummaryReport writes a summary to the reports directory when enabled and adds
+// the link to it
+func writeSummaryReport(summary map[string]interface{}) {
+	if !isReportEnabled() {
+		return
+	}
+	name, err := WriteDailyReport(summary)
+	if err != nil {
+		logger.Error("failed to write daily report", "error", err)
+		return
 	}
+	logger.Info("daily report written", "file", name)
+	summary["report"] = name
 }
 
 // getTopDomainsByRoot gets top domains organized by root domain
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TakeoverConfig holds dangling CNAME detection settings
+type TakeoverConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Webhook string `yaml:"webhook"` // Falls back to the main webhook when empty
+}
+
+// takeoverService describes where an unclaimed resource of a hosting service can be claimed by anyone
+type takeoverService struct {
+	name    string
+	pattern *regexp.Regexp // Matched against each name in the CNAME chain
+	// Text in the HTTP response for an unclaimed resource. Empty when an unclaimed
+	// resource stops resolving instead.
+	fingerprint string
+}
+
+var takeoverServices = []takeoverService{
+	{
+		name:        "AWS S3",
+		pattern:     regexp.MustCompile(`(^|\.)s3([.-][a-z0-9-]+)*\.amazonaws\.com$`),
+		fingerprint: "NoSuchBucket",
+	},
+	{
+		name:        "GitHub Pages",
+		pattern:     regexp.MustCompile(`\.github\.io$`),
+		fingerprint: "There isn't a GitHub Pages site here",
+	},
+	{
+		name:    "Azure",
+		pattern: regexp.MustCompile(`\.(azurewebsites\.net|cloudapp\.net|cloudapp\.azure\.com|trafficmanager\.net|blob\.core\.windows\.net|azureedge\.net|azure-api\.net|azurecontainer\.io)$`),
+	},
+}
+
+const maxTakeoverBody = 64 * 1024
+
+var takeoverConfig *TakeoverConfig
+var takeoverMutex sync.Mutex
+
+// takeoverClient returns the outbound client, with its proxy and TLS settings, that
+// stops at the first response, since that is where the unclaimed page is served
+func takeoverClient() *http.Client {
+	client := *outboundClient()
+	client.Timeout = 10 * time.Second
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &client
+}
+
+// SetTakeoverConfig sets the dangling CNAME detection configuration
+func SetTakeoverConfig(cfg *TakeoverConfig) {
+	takeoverMutex.Lock()
+	defer takeoverMutex.Unlock()
+	takeoverConfig = cfg
+}
+
+// GetTakeoverConfig returns the dangling CNAME detection configuration
+func GetTakeoverConfig() *TakeoverConfig {
+	takeoverMutex.Lock()
+	defer takeoverMutex.Unlock()
+	return takeoverConfig
+}
+
+// CheckTakeover records a domain's CNAME chain and, when detection is enabled, flags and
+// alerts on chains that end at an unclaimed resource
+func CheckTakeover(domain string, resolves bool) {
+	chain := LookupCNAMEChain(domain)
+	dt := GetDomainTracker()
+	dt.RecordDomainCNAMEs(domain, chain)
+
+	cfg := GetTakeoverConfig()
+	if cfg == nil || !cfg.Enabled {
+		return
+	}
+	service := danglingService(domain, chain, resolves)
+	if !dt.RecordTakeover(domain, service) {
+		return
+	}
+	logger.Warn("possible subdomain takeover", "domain", domain, "service", service, "cnames", chain)
+	sendTakeoverAlert(domain, service, chain)
+}
+
+// danglingService returns the service a domain's CNAME chain points at when the
+// resource there is unclaimed, or ""
+func danglingService(domain string, chain []string, resolves bool) string {
+	for _, svc := range takeoverServices {
+		for _, name := range chain {
+			if !svc.pattern.MatchString(name) {
+				continue
+			}
+			if !resolves || (svc.fingerprint != "" && responseContains(domain, svc.fingerprint)) {
+				return svc.name
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// responseContains reports whether the page served for a domain contains text
+func responseContains(domain, text string) bool {
+	resp, err := takeoverClient().Get("http://" + domain + "/")
+	if err != nil {
+		logger.Debug("takeover check request failed", "domain", domain, "error", err)
+		return false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTakeoverBody))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(body), text)
+}
+
+// sendTakeoverAlert sends a dedicated high-priority alert for a takeover candidate
+// to Discord and ntfy, separate from the batched discovery notifications
+func sendTakeoverAlert(domain, service string, chain []string) {
+	if isDryRun() {
+		logger.Info("dry run: would send takeover alert", "domain", domain, "service", service)
+		return
+	}
+	cfg := GetTakeoverConfig()
+	if alertConfigured(cfg.Webhook) {
+		if err := sendAlert(cfg.Webhook, buildTakeoverPayload(domain, service, chain)); err != nil {
+			logger.Error("failed to send takeover alert", "domain", domain, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("takeover alert for %s: %v", domain, err))
+		}
+	}
+	if isNtfyConfigured() {
+		body := fmt.Sprintf("%s -> %s\nService: %s", domain, strings.Join(chain, " -> "), service)
+		if err := sendUrgentNtfy("Possible subdomain takeover: "+domain, body, domain); err != nil {
+			logger.Error("failed to send takeover ntfy alert", "domain", domain, "error", err)
+			RecordError(errCategoryWebhook, fmt.Sprintf("takeover ntfy alert for %s: %v", domain, err))
+		}
+	}
+}
+
+// buildTakeoverPayload builds a Discord embed for a takeover candidate
+func buildTakeoverPayload(domain, service string, chain []string) map[string]interface{} {
+	embed := map[string]interface{}{
+		"title":       "Possible subdomain takeover: " + domain,
+		"description": fmt.Sprintf("**Service**: %s\n**CNAME chain**:\n```\n%s\n```", service, strings.Join(append([]string{domain}, chain...), "\n-> ")),
+		"color":       15158332, // Red
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if link := dashboardDomainLink(domain); link != "" {
+		embed["url"] = link
+	}
+	return map[string]interface{}{
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BulkTargetResult reports what a bulk add or remove changed
+type BulkTargetResult struct {
+	Changed   []string `json:"changed"`   // Added or removed
+	Unchanged []string `json:"unchanged"` // Already present, or not found when removing
+	Invalid   []string `json:"invalid"`
+}
+
+// addTargets normalizes and adds targets, saving the config once
+func addTargets(list []string) BulkTargetResult {
+	var result BulkTargetResult
+	existing := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		existing[t] = true
+	}
+
+	for _, raw := range list {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		t, err := normalizeTarget(raw)
+		if err != nil || t == "" {
+			result.Invalid = append(result.Invalid, raw)
+			continue
+		}
+		if existing[t] {
+			result.Unchanged = append(result.Unchanged, t)
+			continue
+		}
+		existing[t] = true
+		targets = append(targets, t)
+		result.Changed = append(result.Changed, t)
+	}
+
+	if len(result.Changed) > 0 {
+		saveTargets()
+	}
+	return result
+}
+
+// removeTargets removes targets, saving the config once
+func removeTargets(list []string) BulkTargetResult {
+	var result BulkTargetResult
+	remove := make(map[string]bool)
+	for _, raw := range list {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		t, err := normalizeTarget(raw)
+		if err != nil || t == "" {
+			result.Invalid = append(result.Invalid, raw)
+			continue
+		}
+		remove[t] = true
+	}
+
+	var kept []string
+	for _, t := range targets {
+		if remove[t] {
+			result.Changed = append(result.Changed, t)
+			delete(remove, t)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	for t := range remove {
+		result.Unchanged = append(result.Unchanged, t)
+	}
+
+	if len(result.Changed) > 0 {
+		targets = kept
+		saveTargets()
+	}
+	return result
+}
+
+// saveTargets writes the current target list to the config file
+func saveTargets() {
+	cfg := getConfig()
+	if cfg == nil {
+		return
+	}
+	cfg.Targets = targets
+	if err := SaveConfig(); err != nil {
+		logger.Error("failed to save config after updating targets", "error", err)
+	}
+}
+
+// runTargets implements crtmon targets import <file|-> and crtmon targets export
+func runTargets(args []string) int {
+	usage := "usage: crtmon targets import [-config path] <file|->\n       crtmon targets export [-config path] [-o file]"
+	if len(args) == 0 || (args[0] != "import" && args[0] != "export") {
+		fmt.Fprintln(os.Stderr, usage)
+		return 1
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("targets "+action, flag.ContinueOnError)
+	targetsConfig := fs.String("config", "", "path to configuration file")
+	output := fs.String("o", "", "write to file instead of stdout (export)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 1
+	}
+
+	cfg, err := loadCommandConfig(*targetsConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if action == "export" {
+		data := strings.Join(targets, "\n")
+		if len(targets) > 0 {
+			data += "\n"
+		}
+		if *output == "" {
+			fmt.Print(data)
+			return 0
+		}
+		if err := os.WriteFile(*output, []byte(data), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "exported %d targets to %s\n", len(targets), *output)
+		return 0
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 1
+	}
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, "no configuration file to import into")
+		return 1
+	}
+	globalConfig = cfg
+
+	// A running instance would save its own target list over the import
+	configDir, err := getConfigDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if pid := runningInstance(configDir); pid != 0 {
+		fmt.Fprintf(os.Stderr, "crtmon is running (pid %d), stop it or import with POST /api/targets/bulk\n", pid)
+		return 1
+	}
+
+	path := fs.Arg(0)
+	if path != "-" {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	list, err := resolveTargetFlag(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	result := addTargets(list)
+	for _, t := range result.Invalid {
+		fmt.Fprintf(os.Stderr, "invalid target: %s\n", t)
+	}
+	fmt.Printf("imported %d targets, %d already present, %d invalid\n", len(result.Changed), len(result.Unchanged), len(result.Invalid))
+	if len(result.Invalid) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ToolConfig declares an external tool run against new domains, e.g. katana, gau or httpx
+type ToolConfig struct {
+	Name    string   `yaml:"name"`     // Names the jobs and results, e.g. katana
+	Command string   `yaml:"command"`  // Program and arguments; {{domain}}, {{url}}, {{output}} and {{target}} are replaced
+	On      []string `yaml:"on"`       // new-domain, wildcard or high-risk, default new-domain
+	MinRisk int      `yaml:"min_risk"` // Lowest risk score of high-risk, default 60
+	Targets []string `yaml:"targets"`  // Only domains of these targets, default all
+	Timeout int      `yaml:"timeout"`  // Seconds, defaults to enumeration.scan_timeout
+	Webhook string   `yaml:"webhook"`  // Where results go, defaults to the main webhook
+	Enabled *bool    `yaml:"enabled"`  // Default true
+}
+
+// Tool triggers
+const (
+	toolOnNewDomain = "new-domain"
+	toolOnWildcard  = "wildcard"
+	toolOnHighRisk  = "high-risk"
+)
+
+// builtinScanTypes are the job types of the built-in scanners, which tools can't be named
+var builtinScanTypes = []string{"feroxbuster", "puredns", "nuclei"}
+
+// toolNamePattern keeps tool names usable in job IDs and output file names
+var toolNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// safeHostPattern is a lowercase host name of letters, digits, dots and hyphens whose
+// labels don't start with a hyphen, so it can't be read as an option or a path
+var safeHostPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*(\.[a-z0-9][a-z0-9-]*)*$`)
+
+// isSafeHost reports whether a host from a certificate can go into a command line or
+// a file name
+func isSafeHost(host string) bool {
+	return len(host) <= 253 && safeHostPattern.MatchString(host)
+}
+
+var toolsConfig []ToolConfig
+var toolsMutex sync.Mutex
+
+// SetToolsConfig sets the external tools run against new domains
+func SetToolsConfig(tools []ToolConfig) {
+	toolsMutex.Lock()
+	defer toolsMutex.Unlock()
+	for i := range tools {
+		tool := &tools[i]
+		tool.Name = strings.ToLower(strings.TrimSpace(tool.Name))
+		tool.Command = strings.TrimSpace(tool.Command)
+		tool.Webhook = strings.TrimSpace(tool.Webhook)
+		if len(tool.On) == 0 {
+			tool.On = []string{toolOnNewDomain}
+		}
+		for j, on := range tool.On {
+			tool.On[j] = strings.ToLower(strings.TrimSpace(on))
+		}
+		if tool.MinRisk <= 0 {
+			tool.MinRisk = 60
+		}
+	}
+	toolsConfig = tools
+}
+
+// GetToolsConfig returns the external tools run against new domains
+func GetToolsConfig() []ToolConfig {
+	toolsMutex.Lock()
+	defer toolsMutex.Unlock()
+	return toolsConfig
+}
+
+// toolNamed returns the configured tool with a name, or nil
+func toolNamed(name string) *ToolConfig {
+	for _, tool := range GetToolsConfig() {
+		if tool.Name == name {
+			return &tool
+		}
+	}
+	return nil
+}
+
+// triggers reports whether a notified domain of a target sets off the tool
+func (tool ToolConfig) triggers(domain, target string, riskScore int) bool {
+	if tool.Enabled != nil && !*tool.Enabled {
+		return false
+	}
+	if len(tool.Targets) > 0 {
+		found := false
+		for _, t := range tool.Targets {
+			if strings.EqualFold(strings.TrimSpace(t), target) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, on := range tool.On {
+		switch {
+		case on == toolOnNewDomain && !IsWildcardDomain(domain):
+			return true
+		case on == toolOnWildcard && IsWildcardDomain(domain):
+			return true
+		case on == toolOnHighRisk && riskScore >= tool.MinRisk:
+			return true
+		}
+	}
+	return false
+}
+
+// RunTools queues every configured tool a newly notified domain sets off. Results are
+// sent when each job completes, like those of the built-in scanners.
+func RunTools(domain, target string) {
+	tools := GetToolsConfig()
+	if len(tools) == 0 {
+		return
+	}
+	domain = strings.ToLower(domain)
+	if !isSafeHost(ExtractBaseDomain(domain)) {
+		logger.Warn("not running tools on unusual domain name", "domain", domain)
+		return
+	}
+	riskScore := 0
+	if info := GetDomainTracker().GetDomainInfo(domain); info != nil {
+		riskScore = info.RiskScore
+	}
+
+	for _, tool := range tools {
+		if !tool.triggers(domain, target, riskScore) {
+			continue
+		}
+		if _, err := runTool(tool, domain, target); err != nil {
+			logger.Error("failed to start tool", "tool", tool.Name, "domain", domain, "error", err)
+			RecordError(errCategoryScan, fmt.Sprintf("%s %s: %v", tool.Name, domain, err))
+		}
+	}
+}
+
+// runTool queues one tool for a domain and returns its output file. Wildcards are run
+// against the domain under them.
+func runTool(tool ToolConfig, domain, target string) (string, error) {
+	args, err := splitCommand(tool.Command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("no command")
+	}
+
+	host := ExtractBaseDomain(domain)
+	if !isSafeHost(host) {
+		return "", fmt.Errorf("unsafe host name %q", host)
+	}
+	outputFile := fmt.Sprintf("%s.%s.txt", strings.ReplaceAll(host, ".", "_"), tool.Name)
+	// Replaced after splitting, so a value can't add arguments
+	replacer := strings.NewReplacer(
+		"{{domain}}", host,
+		"{{url}}", "https://"+host,
+		"{{output}}", outputFile,
+		"{{target}}", target,
+	)
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+
+	timeout := tool.Timeout
+	if timeout <= 0 {
+		cfg, _ := targetEnumConfig(target)
+		timeout = cfg.ScanTimeout
+	}
+	if _, err := GetJobManager().Submit(tool.Name, host, target, args[0], args[1:], outputFile, timeout); err != nil {
+		return "", err
+	}
+	return outputFile, nil
+}
+
+// splitCommand splits a command line into arguments at spaces outside single or
+// double quotes. There is no shell; run one with sh -c for pipes and redirects.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// scanOutputPatterns returns globs matching the output files of the built-in
+// scanners and the configured tools
+func scanOutputPatterns() []string {
+	patterns := append([]string(nil), scanFilePatterns...)
+	for _, tool := range GetToolsConfig() {
+		if toolNamePattern.MatchString(tool.Name) {
+			patterns = append(patterns, "*."+tool.Name+".txt")
+		}
+	}
+	return patterns
+}
+
+// checkToolsConfig reports tools that cannot run as configured
+func checkToolsConfig(tools []ToolConfig) []doctorCheck {
+	var checks []doctorCheck
+	seen := make(map[string]bool)
+	for i, tool := range tools {
+		name := strings.ToLower(strings.TrimSpace(tool.Name))
+		label := fmt.Sprintf("tools[%d]", i)
+		if name != "" {
+			label = name
+		}
+		switch {
+		case !toolNamePattern.MatchString(name):
+			checks = append(checks, doctorCheck{"tool", doctorFail, label + ": name must be lowercase letters, digits, - or _"})
+		case seen[name]:
+			checks = append(checks, doctorCheck{"tool", doctorFail, label + ": listed twice"})
+		}
+		for _, builtin := range builtinScanTypes {
+			if name == builtin {
+				checks = append(checks, doctorCheck{"tool", doctorFail, label + ": name is taken by the built-in scanner"})
+			}
+		}
+		seen[name] = true
+
+		args, err := splitCommand(tool.Command)
+		switch {
+		case err != nil:
+			checks = append(checks, doctorCheck{"tool", doctorFail, label + ": command: " + err.Error()})
+		case len(args) == 0:
+			checks = append(checks, doctorCheck{"tool", doctorFail, label + ": needs a command"})
+		case !strings.Contains(tool.Command, "{{domain}}") && !strings.Contains(tool.Command, "{{url}}"):
+			checks = append(checks, doctorCheck{"tool", doctorWarn, label + ": command has no {{domain}} or {{url}}"})
+		}
+		for j := 1; j < len(args); j++ {
+			// The script of sh -c is run by a shell, so values must come in as "$1"
+			if args[j-1] == "-c" && strings.Contains(args[j], "{{") {
+				checks = append(checks, doctorCheck{"tool", doctorWarn, label + ": pass placeholders to sh -c as positional arguments, not inside the script"})
+			}
+		}
+		for _, on := range tool.On {
+			switch strings.ToLower(strings.TrimSpace(on)) {
+			case toolOnNewDomain, toolOnWildcard, toolOnHighRisk:
+			default:
+				checks = append(checks, doctorCheck{"tool", doctorFail, label + ": on: unknown trigger " + on})
+			}
+		}
+	}
+	return checks
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,23 +25,58 @@ type DomainEntry struct {
 	FirstSeen           time.Time           `json:"first_seen"`
 	LastSeen            time.Time           `json:"last_seen"`
 	LastNotified        time.Time           `json:"last_notified"`
+	NotifiedToday       int                 `json:"notified_today,omitempty"` // Notifications on the day of LastNotified
 	Resolved            bool                `json:"resolved"`
 	Blacklisted         bool                `json:"blacklisted"`
 	BlacklistedDate     time.Time           `json:"blacklisted_date"`
 	DailyHits           map[string]int      `json:"daily_hits"`            // Date (YYYY-MM-DD) -> hit count
-	HighHitDays         int                 `json:"high_hit_days_consecutive"` // Consecutive days with >10 hits
+	HighHitDays         int                 `json:"high_hit_days_consecutive"` // Consecutive days over the blacklist hit threshold
 	LastHighHitDate     string              `json:"last_high_hit_date"`
 	HttpStatusCode      int                 `json:"http_status_code"`
 	ResponseSize        int                 `json:"response_size"`
 	ResponseLineCount   int                 `json:"response_line_count"`
 	ResponseWordCount   int                 `json:"response_word_count"`
 	// Risk indicators
-	RiskLabels          []string            `json:"risk_labels"`           // Tags: "wildcard", "status-anomaly", "issuer-change", "high-frequency"
+	RiskLabels          []string            `json:"risk_labels"`           // Tags: "wildcard", "status-anomaly", "issuer-change", "high-frequency", or from risk rules
 	RiskScore           int                 `json:"risk_score"`            // 0-100 composite score
 	CertIssuer          string              `json:"cert_issuer"`           // Last seen certificate issuer
 	PreviousIssuer      string              `json:"previous_issuer"`       // Track issuer changes
 	StatusCodeHistory   []int               `json:"status_code_history"`   // Recent status codes
 	IsDuplicate         bool                `json:"is_duplicate"`          // Marked as duplicate/noise
+	Certificate         *CertDetails        `json:"certificate,omitempty"` // Last seen certificate
+	DiscordMessageID    string              `json:"discord_message_id,omitempty"` // Receipt of the last discovery notification
+	CertExpiry          time.Time           `json:"cert_expiry"`           // Latest not-after date seen
+	ExpiryNotified      time.Time           `json:"expiry_notified"`       // Not-after date an expiry alert was sent for
+	Probe               *ProbeResult        `json:"probe,omitempty"`       // Last built-in HTTP probe
+	Screenshot          string              `json:"screenshot,omitempty"`  // Path of the last captured screenshot
+	EvidenceFirst       string              `json:"evidence_first,omitempty"`  // PEM of the first certificate seen
+	EvidenceLatest      string              `json:"evidence_latest,omitempty"` // PEM of the most recently issued certificate
+	EscalatedAt         time.Time           `json:"escalated_at,omitempty"` // When on-call was paged about this domain
+	VantageAnswers      map[string][]string `json:"vantage_answers,omitempty"` // Vantage resolver -> IPv4 answers
+	Source              string              `json:"source,omitempty"`      // How the domain was first found, e.g. "certstream", "sni" or "permutation"
+	NotifyLatencyMs     int64               `json:"notify_latency_ms,omitempty"` // CT log timestamp to delivery of the last notification
+	Targets             []string            `json:"targets,omitempty"`     // Every target the domain was matched under
+	Addrs               []string            `json:"addrs,omitempty"`       // A and AAAA records the domain last resolved to
+	SnoozedUntil        time.Time           `json:"snoozed_until,omitempty"` // Notifications are suppressed until then
+	ASNs                map[string]string   `json:"asns,omitempty"`        // Address -> origin ASN, e.g. "AS13335 CLOUDFLARENET, US"
+	CNAMEs              []string            `json:"cnames,omitempty"`      // Names the domain is an alias for, in order
+	Takeover            string              `json:"takeover,omitempty"`    // Service a dangling CNAME points at, e.g. "GitHub Pages"
+	Providers           []string            `json:"providers,omitempty"`   // Hosting providers of the addresses, e.g. "aws", or "other"
+	Countries           []string            `json:"countries,omitempty"`   // ISO codes of the countries the addresses are in
+	UnexpectedIssuer    string              `json:"unexpected_issuer,omitempty"` // Issuer of a certificate outside the target's issuer policy
+	CAAViolation        string              `json:"caa_violation,omitempty"` // Issuer of a certificate the CAA records don't authorize, and why
+	SNISeen             bool                `json:"sni_seen,omitempty"`    // Listed in the SNI dataset at the last refresh
+	SNIFirstSeen        time.Time           `json:"sni_first_seen,omitempty"` // When the SNI dataset first listed the domain
+	SNIRemovedAt        time.Time           `json:"sni_removed_at,omitempty"` // When the domain dropped out of the SNI dataset
+	Shodan              *ShodanSummary      `json:"shodan,omitempty"`      // Open ports, services and exposures Shodan saw on the addresses
+	Reputation          *Reputation         `json:"reputation,omitempty"`  // VirusTotal and urlscan.io verdicts, with their scan IDs
+	CodeHits            []CodeHit           `json:"code_hits,omitempty"`   // GitHub and GitLab files mentioning the domain
+	PortScan            *PortScanResult     `json:"port_scan,omitempty"`   // Open ports found by the last port scan
+	LastRescan          time.Time           `json:"last_rescan,omitempty"` // Last scheduled rescan
+	AssetState          string              `json:"asset_state,omitempty"`   // new, live, offline or retired
+	LastLive            time.Time           `json:"last_live,omitempty"`     // Last resolved or answered a probe
+	StateChanged        time.Time           `json:"state_changed,omitempty"` // When AssetState last changed
+	FailedChecks        int                 `json:"failed_checks,omitempty"` // Resolution checks failed in a row
 }
 
 var tracker *DomainTracker
@@ -58,6 +95,9 @@ func InitDomainTracker(configDir string) error {
 		logger.Error("failed to load domain tracking data", "error", err)
 		// Continue with empty tracker rather than failing
 	}
+	t.importLegacySNIResults(filepath.Join(configDir, "sni.txt.previous"))
+	t.initAssetStates()
+	t.initSources()
 
 	tracker = t
 	logger.Info("domain tracker initialized", "path", filePath)
@@ -77,9 +117,9 @@ func GetDomainTracker() *DomainTracker {
 // ShouldNotifyDomain checks if a domain should be notified
 // Returns true if:
 // - Domain hasn't been notified before, OR
-// - Domain is not blacklisted, AND
-// - Last notification was more than 7 days ago, AND
-// - Only notifies once per day regardless
+// - Domain is not blacklisted or snoozed, AND
+// - Last notification was longer ago than the cooldown (7 days by default), AND
+// - The domain hasn't reached its notifications for today (1 by default)
 func (dt *DomainTracker) ShouldNotifyDomain(domain string) bool {
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
 
@@ -91,13 +131,14 @@ func (dt *DomainTracker) ShouldNotifyDomain(domain string) bool {
 		// New domain
 		today := time.Now().Format("2006-01-02")
 		dt.domains[d] = &DomainEntry{
-			Domain:       d,
-			HitCount:     1,
-			FirstSeen:    time.Now(),
-			LastSeen:     time.Now(),
-			LastNotified: time.Now(),
-			Resolved:     false,
-			DailyHits:    map[string]int{today: 1},
+			Domain:        d,
+			HitCount:      1,
+			FirstSeen:     time.Now(),
+			LastSeen:      time.Now(),
+			LastNotified:  time.Now(),
+			NotifiedToday: 1,
+			Resolved:      false,
+			DailyHits:     map[string]int{today: 1},
 		}
 		return true
 	}
@@ -110,16 +151,11 @@ func (dt *DomainTracker) ShouldNotifyDomain(domain string) bool {
 		   return false
 	}
 
-	// Check 7-day cooldown
-	timeSinceNotified := time.Since(entry.LastNotified)
-	today := time.Now().Format("2006-01-02")
-	lastNotifiedDate := entry.LastNotified.Format("2006-01-02")
-
-	// Only notify once per day, and check 7-day cooldown
-	if timeSinceNotified >= 7*24*time.Hour && today != lastNotifiedDate {
+	// Check the cooldown and per-day limit
+	if notifyCooldownExpired(entry) {
 		entry.HitCount++
 		entry.LastSeen = time.Now()
-		entry.LastNotified = time.Now()
+		markNotified(entry)
 		dt.updateDailyHits(entry)
 		return true
 	}
@@ -131,8 +167,76 @@ func (dt *DomainTracker) ShouldNotifyDomain(domain string) bool {
 	return false
 }
 
-// RecordDomainResolution records whether a domain resolved successfully
-func (dt *DomainTracker) RecordDomainResolution(domain string, resolved bool) {
+// WouldNotifyDomain reports whether ShouldNotifyDomain would notify, without recording a hit
+func (dt *DomainTracker) WouldNotifyDomain(domain string) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.RLock()
+	defer dt.mu.RUnlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return true
+	}
+	if entry.Blacklisted {
+		return false
+	}
+	return notifyCooldownExpired(entry)
+}
+
+// notifyCooldownExpired checks the cooldown and per-day limit, and that the domain isn't snoozed
+func notifyCooldownExpired(entry *DomainEntry) bool {
+	if isSnoozed(entry) {
+		return false
+	}
+	policy := GetDedupConfig()
+	if time.Since(entry.LastNotified) < time.Duration(cooldownHoursFor(entry.Targets, policy.CooldownHours))*time.Hour {
+		return false
+	}
+	return notifiedToday(entry) < policy.MaxPerDay
+}
+
+// notifiedToday returns how many notifications a domain has had today
+func notifiedToday(entry *DomainEntry) int {
+	if entry.LastNotified.Format("2006-01-02") != time.Now().Format("2006-01-02") {
+		return 0
+	}
+	// Entries tracked before the count was kept were notified once
+	if entry.NotifiedToday < 1 {
+		return 1
+	}
+	return entry.NotifiedToday
+}
+
+// markNotified records a notification for a domain
+func markNotified(entry *DomainEntry) {
+	entry.NotifiedToday = notifiedToday(entry) + 1
+	entry.LastNotified = time.Now()
+}
+
+// isSnoozed reports whether notifications for a domain are currently suppressed
+func isSnoozed(entry *DomainEntry) bool {
+	return time.Now().Before(entry.SnoozedUntil)
+}
+
+// SnoozeDomain suppresses notifications for a domain until a time; the zero time unsnoozes it
+func (dt *DomainTracker) SnoozeDomain(domain string, until time.Time) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return false
+	}
+	entry.SnoozedUntil = until
+	dt.save()
+	return true
+}
+
+// RecordDomainResolution records the addresses a domain resolved to, none if it didn't resolve
+func (dt *DomainTracker) RecordDomainResolution(domain string, addrs []string) {
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
 
 	dt.mu.Lock()
@@ -148,7 +252,99 @@ func (dt *DomainTracker) RecordDomainResolution(domain string, resolved bool) {
 		dt.domains[d] = entry
 	}
 
-	entry.Resolved = resolved
+	entry.Resolved = len(addrs) > 0
+	if entry.Resolved {
+		entry.Addrs = addrs
+	}
+	dt.observeLiveness(entry, entry.Resolved)
+	dt.save()
+}
+
+// RecordDomainCNAMEs records the CNAME chain of a domain
+func (dt *DomainTracker) RecordDomainCNAMEs(domain string, cnames []string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.CNAMEs = cnames
+		dt.save()
+	}
+}
+
+// RecordDomainCountries records the countries a domain's addresses are in
+func (dt *DomainTracker) RecordDomainCountries(domain string, countries []string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Countries = countries
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordTakeover flags a domain whose CNAME points at an unclaimed resource of a service,
+// or clears the flag when service is empty. It reports whether the domain was newly flagged.
+func (dt *DomainTracker) RecordTakeover(domain, service string) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists || entry.Takeover == service {
+		return false
+	}
+	flagged := entry.Takeover == ""
+	entry.Takeover = service
+	if service == "" {
+		labels := entry.RiskLabels[:0]
+		for _, l := range entry.RiskLabels {
+			if l != "takeover-candidate" {
+				labels = append(labels, l)
+			}
+		}
+		entry.RiskLabels = labels
+	}
+	dt.calculateRisk(entry)
+	dt.save()
+	return flagged
+}
+
+// RecordUnexpectedIssuer flags a domain with a certificate from an issuer outside its
+// targets' issuer policy
+func (dt *DomainTracker) RecordUnexpectedIssuer(domain, issuer string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return
+	}
+	entry.UnexpectedIssuer = issuer
+	dt.calculateRisk(entry)
+	dt.save()
+}
+
+// RecordCAAViolation flags a domain with a certificate its CAA records don't authorize
+func (dt *DomainTracker) RecordCAAViolation(domain, detail string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return
+	}
+	entry.CAAViolation = detail
+	dt.calculateRisk(entry)
 	dt.save()
 }
 
@@ -193,7 +389,7 @@ func (dt *DomainTracker) GetAllDomains() map[string]*DomainEntry {
 	return result
 }
 
-// ClearOldEntries removes domain entries that haven't been seen in 30 days
+// ClearOldEntries removes domain entries that haven't been seen within maxAge
 func (dt *DomainTracker) ClearOldEntries(maxAge time.Duration) int {
 	dt.mu.Lock()
 	defer dt.mu.Unlock()
@@ -216,6 +412,106 @@ func (dt *DomainTracker) ClearOldEntries(maxAge time.Duration) int {
 	return removed
 }
 
+// RemoveTargetDomains deletes every entry under a target and returns the removed entries
+func (dt *DomainTracker) RemoveTargetDomains(target string) ([]*DomainEntry, error) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	var removed []*DomainEntry
+	for domain, entry := range dt.domains {
+		if entryMatchesTarget(entry, target) {
+			removed = append(removed, entry)
+			delete(dt.domains, domain)
+		}
+	}
+
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	return removed, dt.save()
+}
+
+// RecordSNIResults records the domains the SNI dataset lists under a target, tracking
+// those not seen before, and returns the domains that appeared in the dataset and
+// those that dropped out of it since the last call
+func (dt *DomainTracker) RecordSNIResults(target string, domains []string) (added, removed []string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	now := time.Now()
+	current := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if current[d] || !matchesTarget(d, target) {
+			continue
+		}
+		current[d] = true
+
+		entry, exists := dt.domains[d]
+		if !exists {
+			entry = &DomainEntry{
+				Domain:    d,
+				FirstSeen: now,
+				LastSeen:  now,
+				Resolved:  true,
+				DailyHits: make(map[string]int),
+				Source:    sourceSNI,
+			}
+			dt.domains[d] = entry
+		}
+		if entry.SNISeen {
+			continue
+		}
+		entry.SNISeen = true
+		entry.SNIRemovedAt = time.Time{}
+		if entry.SNIFirstSeen.IsZero() {
+			entry.SNIFirstSeen = now
+		}
+		added = append(added, d)
+	}
+
+	for d, entry := range dt.domains {
+		if entry.SNISeen && !current[d] && matchesTarget(d, target) {
+			entry.SNISeen = false
+			entry.SNIRemovedAt = now
+			removed = append(removed, d)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	if len(added) > 0 || len(removed) > 0 {
+		dt.save()
+	}
+	return added, removed
+}
+
+// importLegacySNIResults moves the per-target SNI results of older versions, kept as
+// target|domain1,domain2 lines, into the tracker
+func (dt *DomainTracker) importLegacySNIResults(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	imported := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, "|", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		added, _ := dt.RecordSNIResults(parts[0], strings.Split(parts[1], ","))
+		imported += len(added)
+	}
+	if isDryRun() {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		logger.Warn("failed to remove legacy SNI results", "path", path, "error", err)
+		return
+	}
+	logger.Info("imported SNI results into the domain tracker", "domains", imported)
+}
+
 // load loads the tracking data from disk
 func (dt *DomainTracker) load() error {
 	data, err := os.ReadFile(dt.filePath)
@@ -228,6 +524,9 @@ func (dt *DomainTracker) load() error {
 
 // save saves the tracking data to disk
 func (dt *DomainTracker) save() error {
+	if isDryRun() {
+		return nil
+	}
 	data, err := json.Marshal(dt.domains)
 	if err != nil {
 		return err
@@ -242,7 +541,14 @@ func (dt *DomainTracker) save() error {
 	return os.Rename(tempPath, dt.filePath)
 }
 
-// ForceNotifyDomain allows forcing a notification even within the 7-day window
+// Persist writes the tracking data to disk, used on shutdown
+func (dt *DomainTracker) Persist() error {
+	dt.mu.RLock()
+	defer dt.mu.RUnlock()
+	return dt.save()
+}
+
+// ForceNotifyDomain allows forcing a notification even within the cooldown
 func (dt *DomainTracker) ForceNotifyDomain(domain string) {
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
 
@@ -253,18 +559,19 @@ func (dt *DomainTracker) ForceNotifyDomain(domain string) {
 	if !exists {
 		today := time.Now().Format("2006-01-02")
 		entry = &DomainEntry{
-			Domain:       d,
-			HitCount:     1,
-			FirstSeen:    time.Now(),
-			LastSeen:     time.Now(),
-			LastNotified: time.Now(),
-			DailyHits:    map[string]int{today: 1},
+			Domain:        d,
+			HitCount:      1,
+			FirstSeen:     time.Now(),
+			LastSeen:      time.Now(),
+			LastNotified:  time.Now(),
+			NotifiedToday: 1,
+			DailyHits:     map[string]int{today: 1},
 		}
 		dt.domains[d] = entry
 	} else {
 		entry.HitCount++
 		entry.LastSeen = time.Now()
-		entry.LastNotified = time.Now()
+		markNotified(entry)
 		dt.updateDailyHits(entry)
 	}
 
@@ -280,8 +587,9 @@ func (dt *DomainTracker) updateDailyHits(entry *DomainEntry) {
 	today := time.Now().Format("2006-01-02")
 	entry.DailyHits[today]++
 
-	// Check for blacklist trigger (>10 hits per day for 3+ consecutive days)
-	if entry.DailyHits[today] > 10 {
+	// Check for blacklist trigger (by default >10 hits per day for 3+ consecutive days)
+	policy := GetDedupConfig()
+	if entry.DailyHits[today] > policy.BlacklistHitsPerDay {
 		if entry.LastHighHitDate != today {
 			// New high-hit day
 			yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
@@ -294,11 +602,11 @@ func (dt *DomainTracker) updateDailyHits(entry *DomainEntry) {
 			}
 			entry.LastHighHitDate = today
 
-			// Blacklist if 3+ consecutive days
-			if entry.HighHitDays >= 3 {
+			// Blacklist after enough consecutive days
+			if entry.HighHitDays >= policy.BlacklistDays {
 				entry.Blacklisted = true
 				entry.BlacklistedDate = time.Now()
-				logger.Info("domain blacklisted", "domain", entry.Domain, "reason", "10+ hits for 3 consecutive days")
+				logger.Info("domain blacklisted", "domain", entry.Domain, "reason", fmt.Sprintf("%d+ hits for %d consecutive days", policy.BlacklistHitsPerDay, policy.BlacklistDays))
 			}
 		}
 	}
@@ -334,15 +642,14 @@ func (dt *DomainTracker) GetBlacklistedDomains() []*DomainEntry {
 	return blacklisted
 }
 
-// GetDomainsDiscoveredToday returns domains discovered today
-func (dt *DomainTracker) GetDomainsDiscoveredToday() []*DomainEntry {
-	t := time.Now().Format("2006-01-02")
+// GetDomainsSeenSince returns domains seen in certificates since a time
+func (dt *DomainTracker) GetDomainsSeenSince(since time.Time) []*DomainEntry {
 	dt.mu.RLock()
 	defer dt.mu.RUnlock()
 
 	var discovered []*DomainEntry
 	for _, entry := range dt.domains {
-		if _, exists := entry.DailyHits[t]; exists && entry.DailyHits[t] > 0 {
+		if !entry.LastSeen.Before(since) {
 			copy := *entry
 			discovered = append(discovered, &copy)
 		}
@@ -379,6 +686,240 @@ func (dt *DomainTracker) RecordDomainMetadata(domain string, statusCode, respons
 	}
 }
 
+// RecordDomainProbe records the result of a built-in HTTP probe
+func (dt *DomainTracker) RecordDomainProbe(domain string, result *ProbeResult) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Probe = result
+		dt.observeLiveness(entry, true)
+
+		entry.StatusCodeHistory = append(entry.StatusCodeHistory, result.StatusCode)
+		if len(entry.StatusCodeHistory) > 10 {
+			entry.StatusCodeHistory = entry.StatusCodeHistory[len(entry.StatusCodeHistory)-10:]
+		}
+		entry.HttpStatusCode = result.StatusCode
+		entry.ResponseSize = result.ContentLength
+
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordDomainShodan records what Shodan knows about a domain's addresses
+func (dt *DomainTracker) RecordDomainShodan(domain string, summary *ShodanSummary) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Shodan = summary
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordDomainPortScan records the open ports found on a domain
+func (dt *DomainTracker) RecordDomainPortScan(domain string, result *PortScanResult) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.PortScan = result
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordDomainReputation records the VirusTotal and urlscan.io verdicts on a domain
+func (dt *DomainTracker) RecordDomainReputation(domain string, reputation *Reputation) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Reputation = reputation
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordCodeHits records the files mentioning a domain and returns those not recorded before
+func (dt *DomainTracker) RecordCodeHits(domain string, hits []CodeHit) []CodeHit {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return nil
+	}
+	known := make(map[string]bool, len(entry.CodeHits))
+	for _, hit := range entry.CodeHits {
+		known[hit.URL] = true
+	}
+	var added []CodeHit
+	for _, hit := range hits {
+		if !known[hit.URL] {
+			known[hit.URL] = true
+			added = append(added, hit)
+		}
+	}
+	if len(added) > 0 {
+		entry.CodeHits = append(entry.CodeHits, added...)
+		dt.save()
+	}
+	return added
+}
+
+// RecordDomainVantages records per-vantage resolution answers
+func (dt *DomainTracker) RecordDomainVantages(domain string, answers map[string][]string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.VantageAnswers = answers
+		dt.calculateRisk(entry)
+		dt.save()
+	}
+}
+
+// RecordDomainScreenshot records the path of a captured screenshot
+func (dt *DomainTracker) RecordDomainScreenshot(domain string, path string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Screenshot = path
+		dt.save()
+	}
+}
+
+// SetBlacklisted manually blacklists or unblacklists a tracked domain
+func (dt *DomainTracker) SetBlacklisted(domain string, blacklisted bool) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return false
+	}
+	entry.Blacklisted = blacklisted
+	entry.BlacklistedDate = time.Time{}
+	if blacklisted {
+		entry.BlacklistedDate = time.Now()
+	}
+	dt.save()
+	return true
+}
+
+// AddDomain tracks a domain found outside CT logs, returning false if it is already tracked
+func (dt *DomainTracker) AddDomain(domain, source string) bool {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if _, exists := dt.domains[d]; exists {
+		return false
+	}
+	now := time.Now()
+	dt.domains[d] = &DomainEntry{
+		Domain:    d,
+		FirstSeen: now,
+		LastSeen:  now,
+		Resolved:  true,
+		DailyHits: make(map[string]int),
+		Source:    source,
+	}
+	dt.save()
+	return true
+}
+
+// AddDomains tracks domains found outside CT logs at once, returning those not tracked before
+func (dt *DomainTracker) AddDomains(domains []string, source string) []string {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	now := time.Now()
+	var added []string
+	for _, domain := range domains {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if _, exists := dt.domains[d]; exists {
+			continue
+		}
+		entry := &DomainEntry{
+			Domain:    d,
+			FirstSeen: now,
+			LastSeen:  now,
+			Resolved:  true,
+			DailyHits: make(map[string]int),
+			Source:    source,
+		}
+		dt.observeLiveness(entry, true)
+		dt.domains[d] = entry
+		added = append(added, d)
+	}
+	if len(added) > 0 {
+		dt.save()
+	}
+	return added
+}
+
+// MarkDomainRescanned records that a scheduled rescan of a domain ran
+func (dt *DomainTracker) MarkDomainRescanned(domain string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.LastRescan = time.Now()
+		dt.save()
+	}
+}
+
+// MarkDomainSource records how a domain was found, unless a source is already set
+func (dt *DomainTracker) MarkDomainSource(domain, source string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists && entry.Source == "" && source != "" {
+		entry.Source = source
+		dt.save()
+	}
+}
+
+// RecordDomainEvidence records the paths of stored certificate evidence
+func (dt *DomainTracker) RecordDomainEvidence(domain, first, latest string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists && (entry.EvidenceFirst != first || entry.EvidenceLatest != latest) {
+		entry.EvidenceFirst = first
+		entry.EvidenceLatest = latest
+		dt.save()
+	}
+}
+
 // RecordDomainIssuer records certificate issuer information
 func (dt *DomainTracker) RecordDomainIssuer(domain string, issuer string) {
 	d := strings.ToLower(strings.TrimSuffix(domain, "."))
@@ -398,57 +939,245 @@ func (dt *DomainTracker) RecordDomainIssuer(domain string, issuer string) {
 	}
 }
 
+// RecordDomainCertificate records the metadata of the last certificate seen for a domain
+func (dt *DomainTracker) RecordDomainCertificate(domain string, details *CertDetails) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Certificate = details
+		// Older certificates can show up in logs after a renewal, keep the latest expiry
+		if details.NotAfter.After(entry.CertExpiry) {
+			entry.CertExpiry = details.NotAfter
+		}
+		dt.save()
+	}
+}
+
+// GetExpiringDomains returns domains whose latest certificate expires within the window
+func (dt *DomainTracker) GetExpiringDomains(within time.Duration) []*DomainEntry {
+	dt.mu.RLock()
+	defer dt.mu.RUnlock()
+
+	now := time.Now()
+	cutoff := now.Add(within)
+
+	var expiring []*DomainEntry
+	for _, entry := range dt.domains {
+		if entry.CertExpiry.IsZero() || entry.Blacklisted || isSnoozed(entry) {
+			continue
+		}
+		if entry.CertExpiry.After(now) && !entry.CertExpiry.After(cutoff) {
+			copy := *entry
+			expiring = append(expiring, &copy)
+		}
+	}
+	return expiring
+}
+
+// MarkExpiryNotified records that an expiry alert was sent for a domain's current certificate
+func (dt *DomainTracker) MarkExpiryNotified(domains []string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	for _, domain := range domains {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if entry, exists := dt.domains[d]; exists {
+			entry.ExpiryNotified = entry.CertExpiry
+		}
+	}
+	dt.save()
+}
+
+// RecordDomainMessageID stores the Discord message a batch of domains was notified in
+func (dt *DomainTracker) RecordDomainMessageID(domains []string, messageID string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	for _, domain := range domains {
+		d := strings.ToLower(strings.TrimSuffix(domain, "."))
+		if entry, exists := dt.domains[d]; exists {
+			entry.DiscordMessageID = messageID
+		}
+	}
+	dt.save()
+}
+
+// RecordDomainTargets adds targets a domain matched under, keeping earlier ones
+func (dt *DomainTracker) RecordDomainTargets(domain string, targets []string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	entry, exists := dt.domains[d]
+	if !exists {
+		return
+	}
+	changed := false
+	for _, target := range targets {
+		known := false
+		for _, t := range entry.Targets {
+			if t == target {
+				known = true
+				break
+			}
+		}
+		if !known {
+			entry.Targets = append(entry.Targets, target)
+			changed = true
+		}
+	}
+	if changed {
+		dt.save()
+	}
+}
+
+// RecordDomainNotifyLatency stores how long the last notification for a domain took after its certificate was logged
+func (dt *DomainTracker) RecordDomainNotifyLatency(domain string, latency time.Duration) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.NotifyLatencyMs = latency.Milliseconds()
+		dt.save()
+	}
+}
+
+// RecordDomainAddrs records the addresses a domain resolved to, their ASNs and hosting providers
+func (dt *DomainTracker) RecordDomainAddrs(domain string, addrs []string, asns map[string]string) {
+	d := strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if entry, exists := dt.domains[d]; exists {
+		entry.Addrs = addrs
+		entry.ASNs = asns
+		entry.Providers = hostingProviders(asns)
+		dt.save()
+	}
+}
+
 // calculateRisk computes risk score and labels for a domain
 func (dt *DomainTracker) calculateRisk(entry *DomainEntry) {
 	if entry.RiskLabels == nil {
 		entry.RiskLabels = []string{}
 	}
 	
+	cfg := GetRiskConfig()
 	score := 0
 	
 	// Check for wildcard
 	if IsWildcardDomain(entry.Domain) {
 		dt.addRiskLabel(entry, "wildcard")
-		score += 30
+		score += cfg.points("wildcard")
 	}
 	
 	// Check for status anomalies (multiple different status codes)
-	if len(entry.StatusCodeHistory) >= 3 {
+	if len(entry.StatusCodeHistory) >= cfg.StatusAnomalyCodes {
 		uniqueStatuses := make(map[int]bool)
 		for _, status := range entry.StatusCodeHistory {
 			uniqueStatuses[status] = true
 		}
-		if len(uniqueStatuses) >= 3 {
+		if len(uniqueStatuses) >= cfg.StatusAnomalyCodes {
 			dt.addRiskLabel(entry, "status-anomaly")
-			score += 20
+			score += cfg.points("status-anomaly")
 		}
 	}
 	
-	// Check for high frequency (>50 hits or high daily average)
-	if entry.HitCount > 50 || entry.HighHitDays >= 2 {
+	// Check for high frequency (many hits or several high-hit days in a row)
+	if entry.HitCount > cfg.HighFrequencyHits || entry.HighHitDays >= cfg.HighFrequencyDays {
 		dt.addRiskLabel(entry, "high-frequency")
-		score += 25
+		score += cfg.points("high-frequency")
+	}
+	
+	// A CNAME left pointing at an unclaimed resource can be taken over by anyone
+	if entry.Takeover != "" {
+		dt.addRiskLabel(entry, "takeover-candidate")
+		score += cfg.points("takeover-candidate")
+	}
+	
+	// A certificate from a CA the target doesn't use may be mis-issued
+	if entry.UnexpectedIssuer != "" {
+		dt.addRiskLabel(entry, "unexpected-issuer")
+		score += cfg.points("unexpected-issuer")
+	}
+	
+	// CAs must check CAA before issuing, so a violation means mis-issuance
+	if entry.CAAViolation != "" {
+		dt.addRiskLabel(entry, "caa-violation")
+		score += cfg.points("caa-violation")
+	}
+	
+	// Databases, remote desktops and the like reachable from the internet
+	if entry.Shodan != nil && len(entry.Shodan.Notable) > 0 {
+		dt.addRiskLabel(entry, "exposed-service")
+		score += cfg.points("exposed-service")
+	}
+	
+	// Flagged by VirusTotal engines or urlscan.io, likely phishing or malware
+	if entry.Reputation.malicious() {
+		dt.addRiskLabel(entry, "malicious-reputation")
+		score += cfg.points("malicious-reputation")
+	}
+	
+	// Assets hosted outside the expected countries raise notification priority
+	if len(unexpectedCountries(entry.Countries)) > 0 {
+		dt.addRiskLabel(entry, "unexpected-country")
+		score += cfg.points("unexpected-country")
 	}
 	
 	// Issuer change is already tracked in RecordDomainIssuer
 	if entry.PreviousIssuer != "" {
-		score += 15
+		score += cfg.points("issuer-change")
+	}
+	
+	// Answers that differ per vantage suggest split-horizon DNS or regional targeting
+	switch classifyVantageAnswers(entry.VantageAnswers) {
+	case "split-horizon":
+		dt.addRiskLabel(entry, "split-horizon")
+		score += cfg.points("split-horizon")
+	case "geo-variance":
+		dt.addRiskLabel(entry, "geo-variance")
+		score += cfg.points("geo-variance")
 	}
 	
 	// Suspicious response patterns (very small or very large responses)
 	if entry.ResponseSize > 0 {
-		if entry.ResponseSize < 100 || entry.ResponseSize > 1000000 {
+		if entry.ResponseSize < cfg.ResponseMinBytes || entry.ResponseSize > cfg.ResponseMaxBytes {
 			dt.addRiskLabel(entry, "response-anomaly")
-			score += 10
+			score += cfg.points("response-anomaly")
 		}
 	}
 	
-	// Cap score at 100
+	// Custom rules from the config
+	for _, rule := range cfg.Rules {
+		if rule.matches(entry) {
+			dt.addRiskLabel(entry, rule.Label)
+			score += rule.Points
+		}
+	}
+	
+	// Cap score at 0-100
 	if score > 100 {
 		score = 100
 	}
+	if score < 0 {
+		score = 0
+	}
 	
 	entry.RiskScore = score
+
+	// Page on-call once, the first time a domain meets the escalation criteria
+	if entry.EscalatedAt.IsZero() && !entry.Blacklisted && shouldEscalate(entry.Domain, entry.Targets, score, entry.RiskLabels) {
+		entry.EscalatedAt = time.Now()
+		go Escalate(entry.Domain, score, append([]string(nil), entry.RiskLabels...))
+	}
 	
 	// Auto-mark as duplicate if very high frequency and minimal content
 	if entry.HitCount > 100 && entry.ResponseSize < 500 {
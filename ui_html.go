@@ -9,6 +9,7 @@ func init() {
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <title>CRTMon - Admin Panel</title>
     <script src="https://cdn.jsdelivr.net/npm/chart.js"></script>
+    <script src="https://cdn.jsdelivr.net/npm/vis-network/standalone/umd/vis-network.min.js"></script>
     <style>
         * {
             margin: 0;
@@ -279,6 +280,10 @@ func init() {
             background: rgba(59, 130, 246, 0.05);
         }
 
+        tr.domain-row {
+            cursor: pointer;
+        }
+
         .badge {
             display: inline-block;
             padding: 4px 12px;
@@ -303,6 +308,11 @@ func init() {
             color: #fdba74;
         }
 
+        .badge-info {
+            background: rgba(59, 130, 246, 0.2);
+            color: #93c5fd;
+        }
+
         .action-buttons {
             display: flex;
             gap: 8px;
@@ -432,16 +442,23 @@ func init() {
     <div id="dashboardScreen" class="dashboard">
         <div class="header">
             <h1>CRTMon Admin Panel</h1>
+            <select id="programFilter" onchange="switchProgram()">
+                <option value="">All programs</option>
+            </select>
             <button class="logout-btn" onclick="logout()">Logout</button>
         </div>
 
         <div class="sidebar">
             <a href="#" onclick="switchTab('dashboard')" class="nav-link active" data-tab="dashboard">Dashboard</a>
+            <a href="#" onclick="switchTab('activity')" class="nav-link" data-tab="activity">Activity</a>
             <a href="#" onclick="switchTab('domains')" class="nav-link" data-tab="domains">Domains</a>
+            <a href="#" onclick="switchTab('assets')" class="nav-link" data-tab="assets">Assets</a>
+            <a href="#" onclick="switchTab('graph')" class="nav-link" data-tab="graph">Graph</a>
             <a href="#" onclick="switchTab('targets')" class="nav-link" data-tab="targets">Targets</a>
             <a href="#" onclick="switchTab('blacklist')" class="nav-link" data-tab="blacklist">Blacklist</a>
             <a href="#" onclick="switchTab('config')" class="nav-link" data-tab="config">Configuration</a>
             <a href="#" onclick="switchTab('webhooks')" class="nav-link" data-tab="webhooks">Webhooks</a>
+            <a href="#" onclick="switchTab('issues')" class="nav-link" data-tab="issues">Issues</a>
         </div>
 
         <div class="main-content">
@@ -524,6 +541,11 @@ func init() {
                         <div class="stat-value" id="enumSuccessValue">--</div>
                         <div class="stat-unit">scan completion rate</div>
                     </div>
+                    <div class="stat-card">
+                        <div class="stat-label">Time to Notify</div>
+                        <div class="stat-value" id="notifyLatencyValue">--</div>
+                        <div class="stat-unit">P50 / P95, CT log to delivery</div>
+                    </div>
                 </div>
 
                 <div class="chart-container">
@@ -546,11 +568,42 @@ func init() {
                     <div class="chart-title">Top Targets by Subdomain Count</div>
                     <canvas id="topTargetsChart"></canvas>
                 </div>
+
+                <h3 style="margin-top: 20px;">Discovery Sources</h3>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Source</th>
+                                <th>Domains</th>
+                                <th>Last 7 Days</th>
+                                <th>Live</th>
+                                <th>High Risk</th>
+                                <th>With Findings</th>
+                            </tr>
+                        </thead>
+                        <tbody id="sourcesTable">
+                            <tr><td colspan="6" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
             </div>
 
             <!-- Domains Section -->
             <div id="domains" class="content-section">
                 <h2>Tracked Domains</h2>
+                <div class="action-buttons" style="justify-content: flex-end; margin-bottom: 12px;">
+                    <select id="domainsProvider" onchange="loadDomains()">
+                        <option value="">All networks</option>
+                        <option value="aws">AWS</option>
+                        <option value="gcp">GCP</option>
+                        <option value="azure">Azure</option>
+                        <option value="digitalocean">DigitalOcean</option>
+                        <option value="other">Other networks</option>
+                    </select>
+                    <button type="button" class="action-btn action-btn-primary" onclick="exportDomains('csv')">Export CSV</button>
+                    <button type="button" class="action-btn action-btn-primary" onclick="exportDomains('json')">Export JSON</button>
+                </div>
                 <div class="table-container">
                     <table>
                         <thead>
@@ -559,13 +612,82 @@ func init() {
                                 <th>Hits</th>
                                 <th>Risk</th>
                                 <th>Labels</th>
+                                <th>Network</th>
                                 <th>First Seen</th>
                                 <th>Last Seen</th>
                                 <th>Status</th>
+                                <th>Screenshot</th>
+                                <th>Actions</th>
                             </tr>
                         </thead>
                         <tbody id="domainsTable">
-                            <tr><td colspan="7" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                            <tr><td colspan="10" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+
+            <!-- Assets Section -->
+            <div id="assets" class="content-section">
+                <h2>Asset Inventory</h2>
+                <div class="action-buttons" style="justify-content: flex-end; margin-bottom: 12px;">
+                    <select id="assetsTarget" onchange="loadAssets()">
+                        <option value="">All targets</option>
+                    </select>
+                    <select id="assetsState" onchange="loadAssets()">
+                        <option value="">All states</option>
+                        <option value="new">New</option>
+                        <option value="live">Live</option>
+                        <option value="offline">Offline</option>
+                        <option value="retired">Retired</option>
+                    </select>
+                    <button type="button" class="action-btn action-btn-primary" onclick="loadAssets()">Refresh</button>
+                </div>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Domain</th>
+                                <th>State</th>
+                                <th>Since</th>
+                                <th>Last Live</th>
+                                <th>HTTP</th>
+                                <th>Risk</th>
+                            </tr>
+                        </thead>
+                        <tbody id="assetsTable">
+                            <tr><td colspan="6" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+
+            <!-- Domain Detail Section -->
+            <div id="domainDetail" class="content-section">
+                <h2 id="domainDetailTitle">Domain</h2>
+                <div class="action-buttons" style="justify-content: flex-end; margin-bottom: 12px;">
+                    <button class="action-btn action-btn-primary" onclick="domainAction('notify')">Force Notify</button>
+                    <select id="detailScanType">
+                        <option value="all">All scans</option>
+                        <option value="ferox">Directories (feroxbuster)</option>
+                        <option value="puredns">DNS brute force (puredns)</option>
+                        <option value="nuclei">Vulnerabilities (nuclei)</option>
+                    </select>
+                    <button class="action-btn action-btn-primary" onclick="scanDomain()">Scan</button>
+                    <button class="action-btn action-btn-primary" id="detailSnoozeBtn" onclick="domainAction('snooze')">Snooze</button>
+                    <button class="action-btn action-btn-danger" id="detailBlacklistBtn" onclick="domainAction('blacklist')">Blacklist</button>
+                </div>
+                <div class="success-message" id="domainDetailSuccess"></div>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Field</th>
+                                <th>Value</th>
+                            </tr>
+                        </thead>
+                        <tbody id="domainDetailTable">
+                            <tr><td colspan="2" style="text-align: center; padding: 20px;">Loading...</td></tr>
                         </tbody>
                     </table>
                 </div>
@@ -578,7 +700,7 @@ func init() {
                     <h3 style="margin-bottom: 15px; color: #60a5fa;">Add New Target</h3>
                     <div class="success-message" id="targetSuccess"></div>
                     <form id="addTargetForm" style="display: flex; gap: 10px;">
-                        <input type="text" id="newTarget" placeholder="example.com" style="flex: 1;" required>
+                        <input type="text" id="newTarget" placeholder="example.com, contains:acme or regex:^vpn\..*\.acme\.com$" style="flex: 1;" required>
                         <button type="submit" class="btn" style="width: auto;">Add</button>
                     </form>
                 </div>
@@ -589,11 +711,12 @@ func init() {
                         <thead>
                             <tr>
                                 <th>Target</th>
+                                <th>Freshness</th>
                                 <th>Actions</th>
                             </tr>
                         </thead>
                         <tbody id="targetsTable">
-                            <tr><td colspan="2" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                            <tr><td colspan="3" style="text-align: center; padding: 20px;">Loading...</td></tr>
                         </tbody>
                     </table>
                 </div>
@@ -618,6 +741,94 @@ func init() {
                         </tbody>
                     </table>
                 </div>
+                <h2 style="margin-top: 24px;">Proposed Exclusions</h2>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Pattern</th>
+                                <th>Target</th>
+                                <th>Learned From</th>
+                                <th>Would Suppress</th>
+                                <th>Actions</th>
+                            </tr>
+                        </thead>
+                        <tbody id="noiseProposalsTable">
+                            <tr><td colspan="5" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+
+            <!-- Activity Section -->
+            <div id="activity" class="content-section">
+                <h2>Activity <span id="liveStatus" class="badge badge-warning">Connecting...</span></h2>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Time</th>
+                                <th>Event</th>
+                                <th>Details</th>
+                            </tr>
+                        </thead>
+                        <tbody id="activityFeed">
+                            <tr class="feed-empty"><td colspan="3" style="text-align: center; padding: 20px;">Waiting for events...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+
+            <!-- Graph Section -->
+            <div id="graph" class="content-section">
+                <h2>Asset Graph</h2>
+                <div class="action-buttons" style="justify-content: flex-end; margin-bottom: 12px;">
+                    <select id="graphTarget" onchange="loadGraph()">
+                        <option value="">All targets</option>
+                    </select>
+                    <button type="button" class="action-btn action-btn-primary" onclick="loadGraph()">Refresh</button>
+                </div>
+                <div id="graphNote" style="margin-bottom: 12px; color: #94a3b8;"></div>
+                <div class="chart-container" style="height: 600px;">
+                    <div id="graphCanvas" style="height: 100%;"></div>
+                </div>
+            </div>
+
+            <!-- Issues Section -->
+            <div id="issues" class="content-section">
+                <h2>Issues</h2>
+                <div class="action-buttons" style="justify-content: flex-end; margin-bottom: 12px;">
+                    <button type="button" class="action-btn action-btn-danger" onclick="clearIssues()">Clear</button>
+                </div>
+                <div class="table-container" style="margin-bottom: 20px;">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Category</th>
+                                <th>Count</th>
+                                <th>Last Seen</th>
+                                <th>Last Message</th>
+                            </tr>
+                        </thead>
+                        <tbody id="issuesSummaryTable">
+                            <tr><td colspan="4" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Time</th>
+                                <th>Category</th>
+                                <th>Message</th>
+                            </tr>
+                        </thead>
+                        <tbody id="issuesTable">
+                            <tr><td colspan="3" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
             </div>
 
             <!-- Config Section -->
@@ -636,6 +847,56 @@ func init() {
                         </tbody>
                     </table>
                 </div>
+
+                <h2 style="margin-top: 24px;">Notification Policy</h2>
+                <div class="form-wrapper">
+                    <div class="success-message" id="dedupSaved"></div>
+                    <form id="dedupForm" style="display: grid; grid-template-columns: 1fr 1fr; gap: 12px;">
+                        <div class="form-group">
+                            <label for="dedupCooldown">Cooldown between notifications (hours)</label>
+                            <input type="number" id="dedupCooldown" min="1" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="dedupMaxPerDay">Notifications per domain per day</label>
+                            <input type="number" id="dedupMaxPerDay" min="1" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="dedupHitsPerDay">Blacklist after more hits per day than</label>
+                            <input type="number" id="dedupHitsPerDay" min="1" required>
+                        </div>
+                        <div class="form-group">
+                            <label for="dedupDays">for this many consecutive days</label>
+                            <input type="number" id="dedupDays" min="1" required>
+                        </div>
+                        <button type="submit" class="btn" style="grid-column: span 2;">Save</button>
+                    </form>
+                </div>
+
+                <h2 style="margin-top: 24px;">API Keys</h2>
+                <div class="form-wrapper">
+                    <h3 style="margin-bottom: 15px; color: #60a5fa;">Create Key</h3>
+                    <div class="success-message" id="apiKeyCreated"></div>
+                    <form id="apiKeyForm" style="display: flex; gap: 10px;">
+                        <input type="text" id="apiKeyName" placeholder="Name, e.g. recon-cron" style="flex: 1;" required>
+                        <button type="submit" class="btn" style="width: auto;">Create</button>
+                    </form>
+                </div>
+                <div class="table-container">
+                    <table>
+                        <thead>
+                            <tr>
+                                <th>Name</th>
+                                <th>Key</th>
+                                <th>Created</th>
+                                <th>Last Used</th>
+                                <th>Actions</th>
+                            </tr>
+                        </thead>
+                        <tbody id="apiKeysTable">
+                            <tr><td colspan="5" style="text-align: center; padding: 20px;">Loading...</td></tr>
+                        </tbody>
+                    </table>
+                </div>
             </div>
 
             <!-- Webhooks Section -->
@@ -696,6 +957,13 @@ func init() {
                                 <button type="button" class="action-btn action-btn-primary" onclick="testWebhook('daily_summary')">Test</button>
                             </div>
                         </div>
+                        <div class="form-group">
+                            <label for="nucleiFindingsWebhook">Nuclei Findings Webhook</label>
+                            <input type="text" id="nucleiFindingsWebhook" placeholder="https://...">
+                            <div class="action-buttons" style="justify-content: flex-end; margin-top: 8px;">
+                                <button type="button" class="action-btn action-btn-primary" onclick="testWebhook('nuclei_findings')">Test</button>
+                            </div>
+                        </div>
 
                         <div style="grid-column: span 2; display: flex; justify-content: flex-end; margin-top: 10px;">
                             <button type="submit" class="btn" style="width: auto;">Save Changes</button>
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VantageConfig holds the resolvers used to compare answers across vantage points
+type VantageConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Resolvers []string `yaml:"resolvers"` // "system", "1.1.1.1", "8.8.8.8:53" or a DoH JSON endpoint like "https://dns.google/resolve"
+	Timeout   int      `yaml:"timeout"`   // Seconds per lookup
+}
+
+var vantageConfig *VantageConfig
+var vantageMutex sync.Mutex
+
+// SetVantageConfig sets the multi-vantage resolution configuration
+func SetVantageConfig(cfg *VantageConfig) {
+	vantageMutex.Lock()
+	defer vantageMutex.Unlock()
+	vantageConfig = cfg
+	if cfg == nil {
+		return
+	}
+	if len(cfg.Resolvers) == 0 {
+		cfg.Resolvers = []string{"system", "1.1.1.1", "https://dns.google/resolve"}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5
+	}
+}
+
+// GetVantageConfig returns the multi-vantage resolution configuration
+func GetVantageConfig() *VantageConfig {
+	vantageMutex.Lock()
+	defer vantageMutex.Unlock()
+	return vantageConfig
+}
+
+// isVantageEnabled reports whether multi-vantage resolution is configured with at least two resolvers
+func isVantageEnabled() bool {
+	cfg := GetVantageConfig()
+	return cfg != nil && cfg.Enabled && len(cfg.Resolvers) >= 2
+}
+
+// CheckVantages resolves a domain from every vantage and records the answers
+func CheckVantages(domain string) map[string][]string {
+	cfg := GetVantageConfig()
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	answers := make(map[string][]string)
+
+	for _, vantage := range cfg.Resolvers {
+		wg.Add(1)
+		go func(vantage string) {
+			defer wg.Done()
+			ips, err := lookupFromVantage(vantage, domain, timeout)
+			if err != nil {
+				// Unreachable vantages are left out rather than counted as "does not resolve"
+				logger.Debug("vantage lookup failed", "vantage", vantage, "domain", domain, "error", err)
+				return
+			}
+			mu.Lock()
+			answers[vantage] = ips
+			mu.Unlock()
+		}(vantage)
+	}
+	wg.Wait()
+
+	GetDomainTracker().RecordDomainVantages(domain, answers)
+	if label := classifyVantageAnswers(answers); label != "" {
+		logger.Warn("domain resolves differently per vantage", "domain", domain, "label", label, "answers", answers)
+	}
+	return answers
+}
+
+// lookupFromVantage returns the sorted IPv4 answers for a domain from one vantage.
+// An empty answer means the name does not exist there.
+func lookupFromVantage(vantage, domain string, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if strings.HasPrefix(vantage, "https://") {
+		return lookupDoH(ctx, vantage, domain, "A")
+	}
+
+	resolver := net.DefaultResolver
+	if vantage != "system" {
+		resolver = resolverForServer(vantage)
+	}
+
+	ips, err := resolver.LookupIP(ctx, "ip4", domain)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	result := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		result = append(result, ip.String())
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// resolverForServer returns a resolver that sends every query to one DNS server
+func resolverForServer(server string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 3 * time.Second}
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// dohTypes maps the record types looked up over DoH to their numeric type
+var dohTypes = map[string]int{"A": 1, "AAAA": 28, "CNAME": 5, "CAA": 257}
+
+// lookupDoH queries a DNS-over-HTTPS JSON endpoint (Google and Cloudflare style) for A, AAAA, CNAME or CAA records
+func lookupDoH(ctx context.Context, endpoint, domain, qtype string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?name="+url.QueryEscape(domain)+"&type="+qtype, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	resp, err := outboundClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status int `json:"Status"`
+		Answer []struct {
+			Type int    `json:"type"`
+			Data string `json:"data"`
+		} `json:"Answer"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	switch result.Status {
+	case 0: // NOERROR
+	case 3: // NXDOMAIN
+		return []string{}, nil
+	default:
+		return nil, fmt.Errorf("rcode %d", result.Status)
+	}
+
+	want := dohTypes[qtype]
+	records := []string{}
+	for _, answer := range result.Answer {
+		if answer.Type == want { // Other records in the chain are skipped
+			records = append(records, answer.Data)
+		}
+	}
+	sort.Strings(records)
+	return records, nil
+}
+
+// classifyVantageAnswers returns "split-horizon" when a name exists or is private at
+// some vantages but not others, "geo-variance" when public answers don't overlap at all
+// (typical of GeoDNS/CDNs, so scored lower), or "" when answers agree.
+func classifyVantageAnswers(answers map[string][]string) string {
+	if len(answers) < 2 {
+		return ""
+	}
+
+	resolved, private := 0, 0
+	for _, ips := range answers {
+		if len(ips) > 0 {
+			resolved++
+		}
+		if hasPrivateIP(ips) {
+			private++
+		}
+	}
+	if resolved > 0 && resolved < len(answers) {
+		return "split-horizon"
+	}
+	if private > 0 && private < len(answers) {
+		return "split-horizon"
+	}
+	if resolved == 0 {
+		return ""
+	}
+
+	// Any shared address means the vantages see the same deployment
+	seen := make(map[string]int)
+	for _, ips := range answers {
+		for _, ip := range ips {
+			seen[ip]++
+		}
+	}
+	for _, count := range seen {
+		if count > 1 {
+			return ""
+		}
+	}
+	return "geo-variance"
+}
+
+// hasPrivateIP reports whether any answer is a private or loopback address
+func hasPrivateIP(ips []string) bool {
+	for _, s := range ips {
+		if ip := net.ParseIP(s); ip != nil && (ip.IsPrivate() || ip.IsLoopback()) {
+			return true
+		}
+	}
+	return false
+}
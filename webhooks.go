@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +20,18 @@ type WebhookConfig struct {
 	SubdomainScans   string `yaml:"subdomain_scans_webhook"`
 	DirectoryScans   string `yaml:"directory_scans_webhook"`
 	DailySummary     string `yaml:"daily_summary_webhook"`
+	NucleiFindings   string `yaml:"nuclei_findings_webhook"`
+	// Append scan results to the original discovery message instead of posting a new one
+	EditDiscoveryMessage bool `yaml:"edit_discovery_message"`
+	// Scan results longer than this many characters are uploaded as a file instead of
+	// split into several messages; 0 uses one message's worth, -1 always sends inline
+	AttachResultsOver int `yaml:"attach_results_over"`
+}
+
+// discordMessage is the subset of a Discord message returned with ?wait=true
+type discordMessage struct {
+	ID     string                   `json:"id"`
+	Embeds []map[string]interface{} `json:"embeds"`
 }
 
 var webhookConfig *WebhookConfig
@@ -36,20 +51,73 @@ func GetWebhookConfig() *WebhookConfig {
 	return webhookConfig
 }
 
+// attachResultsThreshold returns the length above which scan results are sent as a
+// file, or 0 when they are always sent inline
+func attachResultsThreshold() int {
+	cfg := GetWebhookConfig()
+	switch {
+	case cfg == nil || cfg.AttachResultsOver == 0:
+		return maxBatchChars
+	case cfg.AttachResultsOver < 0:
+		return 0
+	}
+	return cfg.AttachResultsOver
+}
+
+// alertConfigured reports whether sendAlert has somewhere to send an alert
+func alertConfigured(webhook string) bool {
+	return strings.TrimSpace(webhook) != "" || discordConfigured()
+}
+
+// sendAlert sends an alert to its own webhook or, without one, to the bot's channel
+// in bot mode or the main webhook, like discovery notifications
+func sendAlert(webhook string, payload map[string]interface{}) error {
+	if webhook = strings.TrimSpace(webhook); webhook != "" {
+		return SendToWebhook(webhook, payload)
+	}
+	if isDiscordBotEnabled() {
+		_, err := sendDiscordBotPayload(payload)
+		return err
+	}
+	return SendToWebhook(webhookURL, payload)
+}
+
 // SendToWebhook sends a payload to a specific webhook
 func SendToWebhook(webhookURL string, payload map[string]interface{}) error {
+	_, err := SendToWebhookWithReceipt(webhookURL, payload)
+	return err
+}
+
+// SendToWebhookWithReceipt sends a payload to a specific webhook and returns
+// the Discord message ID when the webhook is a Discord webhook
+func SendToWebhookWithReceipt(webhookURL string, payload map[string]interface{}) (string, error) {
 	if webhookURL == "" {
-		return fmt.Errorf("webhook URL not configured")
+		return "", fmt.Errorf("webhook URL not configured")
 	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		logger.Error("failed to marshal webhook payload", "error", err)
-		return err
+		return "", err
+	}
+	return sendWebhookBody(webhookURL, jsonData, "application/json")
+}
+
+// sendWebhookBody posts an encoded JSON or multipart body to a webhook and returns
+// the Discord message ID
+func sendWebhookBody(webhookURL string, body []byte, contentType string) (string, error) {
+	if webhookURL == "" {
+		return "", fmt.Errorf("webhook URL not configured")
 	}
 
 	for attempt := 0; attempt < 3; attempt++ {
-		resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+		var resp *http.Response
+		var err error
+		if isDiscordWebhook(webhookURL) {
+			resp, err = discordPost(withWait(webhookURL), contentType, body)
+		} else {
+			resp, err = outboundClient().Post(webhookURL, contentType, bytes.NewBuffer(body))
+		}
 		if err != nil {
 			logger.Error("failed to send webhook", "attempt", attempt+1, "error", err)
 			if attempt < 2 {
@@ -60,32 +128,231 @@ func SendToWebhook(webhookURL string, payload map[string]interface{}) error {
 
 		switch resp.StatusCode {
 		case http.StatusOK, http.StatusNoContent:
+			messageID := decodeMessageID(resp.Body)
 			resp.Body.Close()
-			return nil
+			return messageID, nil
 		case http.StatusTooManyRequests:
-			resp.Body.Close()
-			if attempt < 2 {
-				time.Sleep(2 * time.Second)
+			if isDiscordWebhook(webhookURL) {
+				backoff(providerDiscord, discordBucket(webhookURL), resp)
+			} else if attempt < 2 {
+				time.Sleep(rateLimitWait)
 			}
+			resp.Body.Close()
 		default:
 			bodyBytes, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 			logger.Error("webhook returned error", "status", resp.StatusCode, "body", string(bodyBytes))
-			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+			RecordError(errCategoryWebhook, fmt.Sprintf("webhook returned status %d", resp.StatusCode))
+			return "", fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+	}
+
+	RecordError(errCategoryWebhook, "failed to send webhook after retries")
+	return "", fmt.Errorf("failed to send webhook after retries")
+}
+
+// attachDiscordScreenshots adds an image embed per screenshot to a Discord payload
+// and returns the multipart body and content type for uploading them
+func attachDiscordScreenshots(payload map[string]interface{}, screenshots map[string]string) ([]byte, string, error) {
+	embeds, _ := payload["embeds"].([]map[string]interface{})
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	i := 0
+	for domain, path := range screenshots {
+		data, err := readArtifact(path)
+		if err != nil {
+			logger.Warn("failed to read screenshot", "domain", domain, "error", err)
+			continue
+		}
+
+		name := filepath.Base(path)
+		part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", i), name)
+		if err != nil {
+			return nil, "", err
 		}
+		part.Write(data)
+
+		embeds = append(embeds, map[string]interface{}{
+			"title": domain,
+			"color": 2829617,
+			"image": map[string]string{"url": "attachment://" + name},
+		})
+		i++
+	}
+	payload["embeds"] = embeds
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writer.WriteField("payload_json", string(jsonData)); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// attachDiscordFile encodes a Discord payload with a file attached
+func attachDiscordFile(payload map[string]interface{}, name string, data []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	part, err := writer.CreateFormFile("files[0]", name)
+	if err != nil {
+		return nil, "", err
+	}
+	part.Write(data)
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writer.WriteField("payload_json", string(jsonData)); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// isDiscordWebhook reports whether a URL points at the Discord webhook API
+func isDiscordWebhook(webhookURL string) bool {
+	return strings.Contains(webhookURL, "discord.com/api/webhooks/") || strings.Contains(webhookURL, "discordapp.com/api/webhooks/")
+}
+
+// withWait asks Discord to return the created message so its ID can be stored
+func withWait(webhookURL string) string {
+	if !isDiscordWebhook(webhookURL) {
+		return webhookURL
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return webhookURL
+	}
+	q := u.Query()
+	q.Set("wait", "true")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// decodeMessageID reads the message ID from a Discord webhook response body
+func decodeMessageID(body io.Reader) string {
+	var msg discordMessage
+	if err := json.NewDecoder(body).Decode(&msg); err != nil {
+		return ""
 	}
+	return msg.ID
+}
 
-	return fmt.Errorf("failed to send webhook after retries")
+// discordMessageURL returns the URL of a message previously sent through a webhook
+func discordMessageURL(webhookURL, messageID string) (string, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/messages/" + messageID
+	q := u.Query()
+	q.Del("wait")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// GetDiscordMessage fetches a message previously sent through a webhook
+func GetDiscordMessage(webhookURL, messageID string) (*discordMessage, error) {
+	messageURL, err := discordMessageURL(webhookURL, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, messageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := discordDo(req, discordBucket(webhookURL))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discord returned status %d", resp.StatusCode)
+	}
+
+	var msg discordMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// EditDiscordMessage replaces the payload of a message previously sent through a webhook
+func EditDiscordMessage(webhookURL, messageID string, payload map[string]interface{}) error {
+	messageURL, err := discordMessageURL(webhookURL, messageID)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, messageURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := discordDo(req, discordBucket(webhookURL))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		backoff(providerDiscord, discordBucket(webhookURL), resp)
+		return fmt.Errorf("discord rate limited")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("discord returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// AppendDiscordEmbed adds an embed to a message previously sent through a webhook
+func AppendDiscordEmbed(webhookURL, messageID string, embed map[string]interface{}) error {
+	msg, err := GetDiscordMessage(webhookURL, messageID)
+	if err != nil {
+		return err
+	}
+
+	// Discord allows at most 10 embeds per message
+	if len(msg.Embeds) >= 10 {
+		return fmt.Errorf("message already has %d embeds", len(msg.Embeds))
+	}
+
+	embeds := append(msg.Embeds, embed)
+	return EditDiscordMessage(webhookURL, messageID, map[string]interface{}{"embeds": embeds})
 }
 
 // SendNewDomainNotification sends a new domain notification
-func SendNewDomainNotification(domain string, rootDomain string, statusCode int, responseSize int, lineCount int, wordCount int) error {
+func SendNewDomainNotification(domain string, rootDomain string, statusCode int, responseSize int, lineCount int, wordCount int, cert *CertDetails) error {
 	cfg := GetWebhookConfig()
 	if cfg == nil || cfg.NewDomains == "" {
 		return fmt.Errorf("new domains webhook not configured")
 	}
 
-	payload := buildNewDomainPayload(domain, rootDomain, statusCode, responseSize, lineCount, wordCount)
+	payload := buildNewDomainPayload(domain, rootDomain, statusCode, responseSize, lineCount, wordCount, cert)
 	return SendToWebhook(cfg.NewDomains, payload)
 }
 
@@ -111,6 +378,17 @@ func SendDirectoryScanResults(domain string, results []string) error {
 	return SendToWebhook(cfg.DirectoryScans, payload)
 }
 
+// SendNucleiFindings sends nuclei vulnerability scan findings
+func SendNucleiFindings(domain string, results []string) error {
+	cfg := GetWebhookConfig()
+	if cfg == nil || cfg.NucleiFindings == "" {
+		return fmt.Errorf("nuclei findings webhook not configured")
+	}
+
+	payload := buildNucleiFindingsPayload(domain, results)
+	return SendToWebhook(cfg.NucleiFindings, payload)
+}
+
 // SendDailySummary sends the daily summary
 func SendDailySummary(summary map[string]interface{}) error {
 	cfg := GetWebhookConfig()
@@ -119,11 +397,15 @@ func SendDailySummary(summary map[string]interface{}) error {
 	}
 
 	payload := buildDailySummaryPayload(summary)
-	return SendToWebhook(cfg.DailySummary, payload)
+	if err := SendToWebhook(cfg.DailySummary, payload); err != nil {
+		GetRetryQueue().AddPayload(cfg.DailySummary, payload, err)
+		return err
+	}
+	return nil
 }
 
 // buildNewDomainPayload builds a Discord embed for new domain notification
-func buildNewDomainPayload(domain string, rootDomain string, statusCode int, responseSize int, lineCount int, wordCount int) map[string]interface{} {
+func buildNewDomainPayload(domain string, rootDomain string, statusCode int, responseSize int, lineCount int, wordCount int, cert *CertDetails) map[string]interface{} {
 	statusStr := fmt.Sprintf("%d", statusCode)
 	if statusCode == 0 {
 		statusStr = "Unknown"
@@ -132,16 +414,23 @@ func buildNewDomainPayload(domain string, rootDomain string, statusCode int, res
 	description := fmt.Sprintf("```\nStatus Code: %s\nResponse Size: %d bytes\nLine Count: %d\nWord Count: %d\n```\n`%s`",
 		statusStr, responseSize, lineCount, wordCount, domain)
 
+	if cert != nil {
+		description += fmt.Sprintf("\n```\n%s\n```", describeCertificate(cert))
+	}
+
+	embed := map[string]interface{}{
+		"title":       rootDomain,
+		"description": description,
+		"color":       3447003, // Blue
+		"timestamp":   time.Now().Format(time.RFC3339),
+	}
+	if link := dashboardDomainLink(domain); link != "" {
+		embed["url"] = link
+	}
+
 	return map[string]interface{}{
-		"tts": false,
-		"embeds": []map[string]interface{}{
-			{
-				"title":       rootDomain,
-				"description": description,
-				"color":       3447003, // Blue
-				"timestamp":   time.Now().Format(time.RFC3339),
-			},
-		},
+		"tts":    false,
+		"embeds": []map[string]interface{}{embed},
 	}
 }
 
@@ -191,9 +480,38 @@ func buildDirectoryScanPayload(domain string, results []string) map[string]inter
 	}
 }
 
+// buildNucleiFindingsPayload builds a Discord embed for nuclei findings
+func buildNucleiFindingsPayload(domain string, results []string) map[string]interface{} {
+	resultList := strings.Join(results, "\n")
+	if len(resultList) > 4000 {
+		resultList = resultList[:4000] + "\n... (truncated)"
+	}
+
+	return map[string]interface{}{
+		"tts": false,
+		"embeds": []map[string]interface{}{
+			{
+				"title":       fmt.Sprintf("Nuclei Findings: %s", domain),
+				"description": fmt.Sprintf("```\n%s\n```", resultList),
+				"color":       15158332, // Red
+				"footer": map[string]string{
+					"text": fmt.Sprintf("%d findings", len(results)),
+				},
+				"timestamp": time.Now().Format(time.RFC3339),
+			},
+		},
+	}
+}
+
 // buildDailySummaryPayload builds a Discord embed for daily summary
 func buildDailySummaryPayload(summary map[string]interface{}) map[string]interface{} {
-	description := "**Daily Summary**\n"
+	name := summaryTitle(summary)
+	description := fmt.Sprintf("**%s**\n", name)
+	title := name + " - " + time.Now().Format("2006-01-02")
+	if program, ok := summary["program"].(string); ok && program != "" {
+		description = fmt.Sprintf("**%s: %s**\n", name, program)
+		title = fmt.Sprintf("%s - %s - %s", name, program, time.Now().Format("2006-01-02"))
+	}
 
 	if domainsCount, ok := summary["discovered_count"].(int); ok {
 		description += fmt.Sprintf("📊 Domains Discovered: %d\n", domainsCount)
@@ -220,11 +538,27 @@ func buildDailySummaryPayload(summary map[string]interface{}) map[string]interfa
 		}
 	}
 
+	if issuance, ok := summary["issuance"].([]IssuanceRow); ok {
+		if lines := describeIssuance(issuance); lines != "" {
+			description += "\n🏛️ Certificates by CA:\n" + lines + "\n"
+		}
+	}
+
+	if cleanup, ok := summary["cleanup"].(CleanupReport); ok && !cleanup.LastRun.IsZero() {
+		description += fmt.Sprintf("\n🧹 Reclaimed: %s\n", describeCleanupReport(cleanup))
+	}
+
+	if name, ok := summary["report"].(string); ok {
+		if link := dashboardReportLink(name); link != "" {
+			description += fmt.Sprintf("\n📄 [Full report](%s)\n", link)
+		}
+	}
+
 	return map[string]interface{}{
 		"tts": false,
 		"embeds": []map[string]interface{}{
 			{
-				"title":       "Daily Summary - " + time.Now().Format("2006-01-02"),
+				"title":       title,
 				"description": description,
 				"color":       12745742, // Purple
 				"timestamp":   time.Now().Format(time.RFC3339),